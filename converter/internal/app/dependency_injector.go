@@ -7,8 +7,11 @@ import (
 	"os"
 
 	"github.com/you-humble/dwgtopdf/converter/internal/converter"
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
 	"github.com/you-humble/dwgtopdf/converter/internal/infra/config"
 	filestore "github.com/you-humble/dwgtopdf/converter/internal/infra/file"
+	"github.com/you-humble/dwgtopdf/converter/internal/pattern"
+	"github.com/you-humble/dwgtopdf/converter/internal/plotstyle"
 	"github.com/you-humble/dwgtopdf/converter/internal/service"
 	converterpb "github.com/you-humble/dwgtopdf/core/grpc/gen"
 	mio "github.com/you-humble/dwgtopdf/core/libs/minio"
@@ -18,9 +21,12 @@ type dependencyInjector struct {
 	cfg    *config.Config
 	logger *slog.Logger
 
-	converter service.Converter
-	fileStore converter.FileSaver
-	service   converterpb.ConverterServiceServer
+	converter  service.Converter
+	fileStore  converter.FileStore
+	fonts      *font.Set
+	patterns   *pattern.Library
+	plotStyles *plotstyle.Library
+	service    converterpb.ConverterServiceServer
 }
 
 func newDI() *dependencyInjector {
@@ -50,15 +56,46 @@ func (di *dependencyInjector) Logger() *slog.Logger {
 	return di.logger
 }
 
-func (di *dependencyInjector) DWGConverter(ctx context.Context) service.Converter {
+func (di *dependencyInjector) Converter(ctx context.Context) service.Converter {
 	if di.converter == nil {
-		di.converter = converter.NewMockConverter(di.FileStore(ctx), di.Config().BaseDir, 16)
+		di.converter = converter.NewCADConverter(di.FileStore(ctx), di.Config().BaseDir, di.Fonts(), di.Patterns(), di.PlotStyles(), 16)
 	}
 
 	return di.converter
 }
 
-func (di *dependencyInjector) FileStore(ctx context.Context) converter.FileSaver {
+func (di *dependencyInjector) Fonts() *font.Set {
+	if di.fonts == nil {
+		cfg := di.Config().Fonts
+		di.fonts = font.NewSet(cfg.Dirs, cfg.Fallback, cfg.GlyphFallbacks)
+		if di.fonts.Fallback() == nil {
+			di.Logger().Warn("fallback font not found, text without fonts uses Helvetica",
+				slog.Any("dirs", cfg.Dirs),
+				slog.String("fallback", cfg.Fallback),
+			)
+		}
+	}
+
+	return di.fonts
+}
+
+func (di *dependencyInjector) Patterns() *pattern.Library {
+	if di.patterns == nil {
+		di.patterns = pattern.NewLibrary(di.Config().Patterns.Dirs)
+	}
+
+	return di.patterns
+}
+
+func (di *dependencyInjector) PlotStyles() *plotstyle.Library {
+	if di.plotStyles == nil {
+		di.plotStyles = plotstyle.NewLibrary(di.Config().PlotStyles.Dirs)
+	}
+
+	return di.plotStyles
+}
+
+func (di *dependencyInjector) FileStore(ctx context.Context) converter.FileStore {
 	if di.fileStore == nil {
 		cfg := di.Config()
 
@@ -105,7 +142,7 @@ func (di *dependencyInjector) FileStore(ctx context.Context) converter.FileSaver
 
 func (di *dependencyInjector) Service(ctx context.Context) converterpb.ConverterServiceServer {
 	if di.service == nil {
-		di.service = service.NewConverterService(di.DWGConverter(ctx))
+		di.service = service.NewConverterService(di.Converter(ctx))
 	}
 
 	return di.service
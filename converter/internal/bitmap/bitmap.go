@@ -0,0 +1,76 @@
+// Package bitmap decodes the raster image files drawings reference: PNG
+// and JPEG with the standard library, TIFF with a decoder of its own.
+package bitmap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+var ErrFormat = errors.New("bitmap: unsupported image format")
+
+// Image is a decoded image file.
+type Image struct {
+	Pixels image.Image
+	// JPEG holds the file of JPEG images with gray or YCbCr pixels, which
+	// writers can embed as they are.
+	JPEG []byte
+	// Bilevel is set for images of one bit per pixel. Their Pixels are
+	// gray, black where the image is drawn in the color of the entity
+	// and white for the background.
+	Bilevel bool
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Decode reads a PNG, JPEG or TIFF file.
+func Decode(data []byte) (*Image, error) {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		if err := checkSize(data, png.DecodeConfig); err != nil {
+			return nil, err
+		}
+		m, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("bitmap: %w", err)
+		}
+		// The header has the bit depth at 24 and the color type at 25,
+		// which is 0 for gray.
+		return &Image{Pixels: m, Bilevel: data[24] == 1 && data[25] == 0}, nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		if err := checkSize(data, jpeg.DecodeConfig); err != nil {
+			return nil, err
+		}
+		m, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("bitmap: %w", err)
+		}
+		img := &Image{Pixels: m}
+		switch m.(type) {
+		case *image.Gray, *image.YCbCr:
+			img.JPEG = data
+		}
+		return img, nil
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return decodeTIFF(data)
+	}
+	return nil, ErrFormat
+}
+
+// checkSize reads the image size from the header, so that images too large
+// to decode are rejected before their pixels are allocated.
+func checkSize(data []byte, decodeConfig func(io.Reader) (image.Config, error)) error {
+	c, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("bitmap: %w", err)
+	}
+	if c.Width*c.Height > maxPixels {
+		return fmt.Errorf("bitmap: image of %dx%d pixels is too large", c.Width, c.Height)
+	}
+	return nil
+}
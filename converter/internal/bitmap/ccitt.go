@@ -0,0 +1,246 @@
+package bitmap
+
+import (
+	"errors"
+	"strconv"
+)
+
+var errGroup4 = errors.New("ccitt: invalid group 4 data")
+
+// bitStream reads bits from the most significant one down.
+type bitStream struct {
+	data []byte
+	pos  int
+}
+
+func (b *bitStream) bit() (int, bool) {
+	if b.pos >= len(b.data)*8 {
+		return 0, false
+	}
+	v := int(b.data[b.pos/8]>>(7-b.pos%8)) & 1
+	b.pos++
+	return v, true
+}
+
+// group4 decodes CCITT T.6 (group 4) data of h rows of w pixels into rows
+// of packed bits, one for black. Each row is coded by where its colors
+// change relative to the changes of the row above, the first row
+// relative to an all white one.
+func group4(src []byte, w, h int) ([]byte, error) {
+	rowBytes := (w + 7) / 8
+	out := make([]byte, rowBytes*h)
+	in := &bitStream{data: src}
+	// ref and cur hold the columns where the reference and the current
+	// row change color, the first from white to black.
+	var ref, cur []int
+	for y := range h {
+		cur = cur[:0]
+		a0, black := -1, false
+		for a0 < w {
+			// b1 is the first change of the row above past a0 to the color
+			// opposite of the current one, b2 the change after it.
+			i := 0
+			for i < len(ref) && (ref[i] <= a0 || (i%2 == 1) != black) {
+				i++
+			}
+			b1, b2 := w, w
+			if i < len(ref) {
+				b1 = ref[i]
+				if i+1 < len(ref) {
+					b2 = ref[i+1]
+				}
+			}
+			mode, ok := readMode(in)
+			if !ok {
+				if y > 0 && a0 < 0 {
+					// End of the data: the remaining rows stay white.
+					return out, nil
+				}
+				return out, errGroup4
+			}
+			start := max(a0, 0)
+			switch {
+			case mode == modePass:
+				a0 = b2
+			case mode == modeHorizontal:
+				r1, ok1 := readRun(in, black)
+				r2, ok2 := readRun(in, !black)
+				if !ok1 || !ok2 {
+					return out, errGroup4
+				}
+				a1 := min(start+r1, w)
+				a2 := min(a1+r2, w)
+				cur = append(cur, a1, a2)
+				a0 = a2
+			default:
+				a1 := b1 + mode
+				if a1 < start || a1 > w {
+					return out, errGroup4
+				}
+				cur = append(cur, a1)
+				a0 = a1
+				black = !black
+			}
+		}
+		row := out[y*rowBytes:]
+		for i := 0; i+1 < len(cur); i += 2 {
+			for x := cur[i]; x < cur[i+1] && x < w; x++ {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		if len(cur)%2 == 1 {
+			for x := cur[len(cur)-1]; x < w; x++ {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		ref = append(ref[:0], cur...)
+	}
+	return out, nil
+}
+
+// Coding modes other than vertical, which readMode returns as the offset
+// of a1 from b1, from -3 to 3.
+const (
+	modePass       = 100
+	modeHorizontal = 101
+)
+
+// readMode reads the code of a coding mode.
+func readMode(in *bitStream) (int, bool) {
+	code := 0
+	for n := 1; n <= 7; n++ {
+		b, ok := in.bit()
+		if !ok {
+			return 0, false
+		}
+		code = code<<1 | b
+		switch {
+		case n == 1 && code == 1:
+			return 0, true
+		case n == 3 && code == 0b011:
+			return 1, true
+		case n == 3 && code == 0b010:
+			return -1, true
+		case n == 3 && code == 0b001:
+			return modeHorizontal, true
+		case n == 4 && code == 0b0001:
+			return modePass, true
+		case n == 6 && code == 0b000011:
+			return 2, true
+		case n == 6 && code == 0b000010:
+			return -2, true
+		case n == 7 && code == 0b0000011:
+			return 3, true
+		case n == 7 && code == 0b0000010:
+			return -3, true
+		}
+	}
+	// Extensions and the end of block code are not read further.
+	return 0, false
+}
+
+// readRun reads the makeup codes and the terminating code of a run of
+// one color.
+func readRun(in *bitStream, black bool) (int, bool) {
+	codes := &whiteCodes
+	if black {
+		codes = &blackCodes
+	}
+	total := 0
+	for {
+		code, n := 0, 0
+		run := -1
+		for run < 0 {
+			b, ok := in.bit()
+			if !ok || n == 13 {
+				return 0, false
+			}
+			code = code<<1 | b
+			n++
+			run = codes[n][code]
+		}
+		total += run
+		if run < 64 {
+			return total, true
+		}
+	}
+}
+
+// runCodes maps the length and value of a run length code to its run, or
+// to -1 when no code has them.
+type runCodes [14][]int
+
+var whiteCodes, blackCodes runCodes
+
+func init() {
+	for _, c := range []struct {
+		codes *runCodes
+		table []string
+	}{
+		{&whiteCodes, whiteTable},
+		{&blackCodes, blackTable},
+	} {
+		for n := range c.codes {
+			c.codes[n] = make([]int, 1<<n)
+			for i := range c.codes[n] {
+				c.codes[n][i] = -1
+			}
+		}
+		// The makeup codes of a table follow its 64 terminating codes.
+		for i, s := range c.table {
+			run := i
+			if i >= 64 {
+				run = (i - 63) * 64
+			}
+			c.codes.add(s, run)
+		}
+		for i, s := range makeupTable {
+			c.codes.add(s, 1792+64*i)
+		}
+	}
+}
+
+func (c *runCodes) add(code string, run int) {
+	v, _ := strconv.ParseUint(code, 2, 16)
+	c[len(code)][v] = run
+}
+
+// whiteTable and blackTable list the terminating codes of runs of 0 to 63
+// pixels followed by the makeup codes of runs of 64 to 1728; makeupTable
+// the makeup codes of 1792 to 2560 both colors share.
+var whiteTable = []string{
+	"00110101", "000111", "0111", "1000", "1011", "1100", "1110", "1111",
+	"10011", "10100", "00111", "01000", "001000", "000011", "110100", "110101",
+	"101010", "101011", "0100111", "0001100", "0001000", "0010111", "0000011", "0000100",
+	"0101000", "0101011", "0010011", "0100100", "0011000", "00000010", "00000011", "00011010",
+	"00011011", "00010010", "00010011", "00010100", "00010101", "00010110", "00010111", "00101000",
+	"00101001", "00101010", "00101011", "00101100", "00101101", "00000100", "00000101", "00001010",
+	"00001011", "01010010", "01010011", "01010100", "01010101", "00100100", "00100101", "01011000",
+	"01011001", "01011010", "01011011", "01001010", "01001011", "00110010", "00110011", "00110100",
+
+	"11011", "10010", "010111", "0110111", "00110110", "00110111", "01100100", "01100101",
+	"01101000", "01100111", "011001100", "011001101", "011010010", "011010011", "011010100", "011010101",
+	"011010110", "011010111", "011011000", "011011001", "011011010", "011011011", "010011000", "010011001",
+	"010011010", "011000", "010011011",
+}
+
+var blackTable = []string{
+	"0000110111", "010", "11", "10", "011", "0011", "0010", "00011",
+	"000101", "000100", "0000100", "0000101", "0000111", "00000100", "00000111", "000011000",
+	"0000010111", "0000011000", "0000001000", "00001100111", "00001101000", "00001101100", "00000110111", "00000101000",
+	"00000010111", "00000011000", "000011001010", "000011001011", "000011001100", "000011001101", "000001101000", "000001101001",
+	"000001101010", "000001101011", "000011010010", "000011010011", "000011010100", "000011010101", "000011010110", "000011010111",
+	"000001101100", "000001101101", "000011011010", "000011011011", "000001010100", "000001010101", "000001010110", "000001010111",
+	"000001100100", "000001100101", "000001010010", "000001010011", "000000100100", "000000110111", "000000111000", "000000100111",
+	"000000101000", "000001011000", "000001011001", "000000101011", "000000101100", "000001011010", "000001100110", "000001100111",
+
+	"0000001111", "000011001000", "000011001001", "000001011011", "000000110011", "000000110100", "000000110101", "0000001101100",
+	"0000001101101", "0000001001010", "0000001001011", "0000001001100", "0000001001101", "0000001110010", "0000001110011", "0000001110100",
+	"0000001110101", "0000001110110", "0000001110111", "0000001010010", "0000001010011", "0000001010100", "0000001010101", "0000001011010",
+	"0000001011011", "0000001100100", "0000001100101",
+}
+
+var makeupTable = []string{
+	"00000001000", "00000001100", "00000001101", "000000010010", "000000010011", "000000010100", "000000010101",
+	"000000010110", "000000010111", "000000011100", "000000011101", "000000011110", "000000011111",
+}
@@ -0,0 +1,473 @@
+package bitmap
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+var errTIFF = errors.New("bitmap: invalid tiff file")
+
+// maxPixels limits the size of the images decoded.
+const maxPixels = 1 << 28
+
+// TIFF tags.
+const (
+	tagWidth           = 256
+	tagHeight          = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagPhotometric     = 262
+	tagFillOrder       = 266
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPlanarConfig    = 284
+	tagPredictor       = 317
+	tagColorMap        = 320
+	tagTileWidth       = 322
+	tagTileHeight      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagExtraSamples    = 338
+)
+
+// Compression schemes.
+const (
+	compressNone     = 1
+	compressGroup4   = 4
+	compressLZW      = 5
+	compressDeflate  = 8
+	compressPackBits = 32773
+	compressZip      = 32946
+)
+
+// Photometric interpretations.
+const (
+	whiteIsZero  = 0
+	blackIsZero  = 1
+	photoRGB     = 2
+	photoPalette = 3
+)
+
+// tiff holds the fields of the first image of a file.
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+	tags  map[int][]uint32
+
+	width, height int
+	bps, spp      int
+	// rowBytes is the size of a row of samples, padded to whole bytes.
+	rowBytes int
+}
+
+// decodeTIFF reads the first image of a TIFF file: bilevel, gray, RGB
+// or palette images, in strips or tiles, uncompressed or compressed with
+// CCITT group 4, LZW, Deflate or PackBits.
+func decodeTIFF(data []byte) (*Image, error) {
+	if len(data) < 8 {
+		return nil, errTIFF
+	}
+	t := &tiff{data: data, order: binary.LittleEndian, tags: make(map[int][]uint32)}
+	if data[0] == 'M' {
+		t.order = binary.BigEndian
+	}
+	if err := t.readIFD(int(t.order.Uint32(data[4:]))); err != nil {
+		return nil, err
+	}
+	t.width, t.height = int(t.value(tagWidth, 0)), int(t.value(tagHeight, 0))
+	t.bps, t.spp = int(t.value(tagBitsPerSample, 1)), int(t.value(tagSamplesPerPixel, 1))
+	if t.width <= 0 || t.height <= 0 || t.width*t.height > maxPixels || t.spp < 1 || t.spp > 8 {
+		return nil, errTIFF
+	}
+	switch t.bps {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("%w: %d bits per sample", ErrFormat, t.bps)
+	}
+	if t.value(tagPlanarConfig, 1) != 1 {
+		return nil, fmt.Errorf("%w: planar tiff", ErrFormat)
+	}
+	t.rowBytes = (t.width*t.spp*t.bps + 7) / 8
+
+	samples, err := t.samples()
+	if err != nil {
+		return nil, err
+	}
+	return t.image(samples)
+}
+
+// readIFD reads the entries of the image file directory at off.
+func (t *tiff) readIFD(off int) error {
+	if off < 8 || off+2 > len(t.data) {
+		return errTIFF
+	}
+	n := int(t.order.Uint16(t.data[off:]))
+	if off+2+12*n > len(t.data) {
+		return errTIFF
+	}
+	for i := range n {
+		e := t.data[off+2+12*i:]
+		tag, typ, count := int(t.order.Uint16(e)), t.order.Uint16(e[2:]), int(t.order.Uint32(e[4:]))
+		var size int
+		switch typ {
+		case 1: // BYTE
+			size = 1
+		case 3: // SHORT
+			size = 2
+		case 4: // LONG
+			size = 4
+		default:
+			continue
+		}
+		if count < 0 || count > len(t.data) {
+			return errTIFF
+		}
+		raw := e[8:12]
+		if count*size > 4 {
+			p := int(t.order.Uint32(e[8:]))
+			if p < 0 || p+count*size > len(t.data) {
+				return errTIFF
+			}
+			raw = t.data[p:]
+		}
+		v := make([]uint32, count)
+		for j := range v {
+			switch size {
+			case 1:
+				v[j] = uint32(raw[j])
+			case 2:
+				v[j] = uint32(t.order.Uint16(raw[2*j:]))
+			case 4:
+				v[j] = t.order.Uint32(raw[4*j:])
+			}
+		}
+		t.tags[tag] = v
+	}
+	return nil
+}
+
+// value returns the first value of a tag, or def when the file lacks it.
+func (t *tiff) value(tag int, def uint32) uint32 {
+	if v := t.tags[tag]; len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+// samples returns the rows of samples of the image, each rowBytes long,
+// gathered from its strips or tiles.
+func (t *tiff) samples() ([]byte, error) {
+	if offsets, ok := t.tags[tagTileOffsets]; ok {
+		tw, th := int(t.value(tagTileWidth, 0)), int(t.value(tagTileHeight, 0))
+		counts := t.tags[tagTileByteCounts]
+		// Tiles are multiples of 16 pixels, commonly 256 or 512 even for
+		// smaller images, and never need to be larger than both.
+		if tw <= 0 || th <= 0 || tw > max(t.width+15, 1024) || th > max(t.height+15, 1024) ||
+			tw*t.spp*t.bps%8 != 0 || len(counts) < len(offsets) {
+			return nil, errTIFF
+		}
+		across, down := (t.width+tw-1)/tw, (t.height+th-1)/th
+		tileRow := tw * t.spp * t.bps / 8
+		if tileRow*th > maxPixels || len(offsets) > across*down {
+			return nil, errTIFF
+		}
+		out := make([]byte, t.rowBytes*t.height)
+		for i := range offsets {
+			tile, err := t.chunk(offsets[i], counts[i], tw, th)
+			if err != nil {
+				return nil, err
+			}
+			x0, y0 := i%across*tileRow, i/across*th
+			for y := 0; y < th && y0+y < t.height; y++ {
+				if x0 >= t.rowBytes {
+					break
+				}
+				row := out[(y0+y)*t.rowBytes:]
+				copy(row[x0:t.rowBytes], tile[y*tileRow:(y+1)*tileRow])
+			}
+		}
+		return out, nil
+	}
+
+	offsets, counts := t.tags[tagStripOffsets], t.tags[tagStripByteCounts]
+	out := make([]byte, t.rowBytes*t.height)
+	rows := int(t.value(tagRowsPerStrip, uint32(t.height)))
+	if rows <= 0 || rows > t.height {
+		rows = t.height
+	}
+	if len(offsets) == 0 {
+		return nil, errTIFF
+	}
+	for i, off := range offsets {
+		y0 := i * rows
+		if y0 >= t.height {
+			break
+		}
+		n := min(rows, t.height-y0)
+		count := uint32(len(t.data))
+		if i < len(counts) {
+			count = counts[i]
+		}
+		strip, err := t.chunk(off, count, t.width, n)
+		if err != nil {
+			return nil, err
+		}
+		copy(out[y0*t.rowBytes:(y0+n)*t.rowBytes], strip)
+	}
+	return out, nil
+}
+
+// chunk decompresses a strip or tile of w by h pixels.
+func (t *tiff) chunk(off, count uint32, w, h int) ([]byte, error) {
+	if int(off) > len(t.data) {
+		return nil, errTIFF
+	}
+	src := t.data[off:min(int(off)+int(count), len(t.data))]
+	if t.value(tagFillOrder, 1) == 2 {
+		rev := make([]byte, len(src))
+		for i, b := range src {
+			rev[i] = bits.Reverse8(b)
+		}
+		src = rev
+	}
+	rowBytes := (w*t.spp*t.bps + 7) / 8
+	size := rowBytes * h
+	var out []byte
+	var err error
+	switch c := t.value(tagCompression, compressNone); c {
+	case compressNone:
+		out = src
+	case compressGroup4:
+		out, err = group4(src, w, h)
+	case compressLZW:
+		out, err = unLZW(src, size)
+	case compressDeflate, compressZip:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(src)); err == nil {
+			out, err = io.ReadAll(io.LimitReader(zr, int64(size)))
+		}
+	case compressPackBits:
+		out = unPackBits(src, size)
+	default:
+		return nil, fmt.Errorf("%w: tiff compression %d", ErrFormat, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bitmap: %w", err)
+	}
+	if len(out) < size {
+		// Truncated data leaves the rest of the chunk blank.
+		out = append(out[:len(out):len(out)], make([]byte, size-len(out))...)
+	}
+	out = out[:size]
+	if t.value(tagPredictor, 1) == 2 {
+		t.undoPredictor(out, rowBytes)
+	}
+	return out, nil
+}
+
+// undoPredictor adds up the horizontal differences that predictor 2
+// stores samples of 8 and 16 bits as.
+func (t *tiff) undoPredictor(data []byte, rowBytes int) {
+	for row := 0; row+rowBytes <= len(data); row += rowBytes {
+		r := data[row : row+rowBytes]
+		switch t.bps {
+		case 8:
+			for i := t.spp; i < len(r); i++ {
+				r[i] += r[i-t.spp]
+			}
+		case 16:
+			for i := 2 * t.spp; i+1 < len(r); i += 2 {
+				t.order.PutUint16(r[i:], t.order.Uint16(r[i:])+t.order.Uint16(r[i-2*t.spp:]))
+			}
+		}
+	}
+}
+
+// sample returns sample i of a row scaled to 8 bits.
+func (t *tiff) sample(row []byte, i int) uint8 {
+	switch t.bps {
+	case 8:
+		return row[i]
+	case 16:
+		return uint8(t.order.Uint16(row[2*i:]) >> 8)
+	}
+	return uint8(t.raw(row, i) * 255 / (1<<t.bps - 1))
+}
+
+// raw returns sample i of a row of samples of up to 8 bits.
+func (t *tiff) raw(row []byte, i int) int {
+	bit := i * t.bps
+	return int(row[bit/8]>>(8-t.bps-bit%8)) & (1<<t.bps - 1)
+}
+
+// image converts the samples to an image of their photometric
+// interpretation.
+func (t *tiff) image(samples []byte) (*Image, error) {
+	rect := image.Rect(0, 0, t.width, t.height)
+	photo := t.value(tagPhotometric, whiteIsZero)
+	extra := t.spp
+	switch photo {
+	case whiteIsZero, blackIsZero, photoPalette:
+		extra--
+	case photoRGB:
+		extra -= 3
+	default:
+		return nil, fmt.Errorf("%w: tiff photometric interpretation %d", ErrFormat, photo)
+	}
+	if extra < 0 {
+		return nil, errTIFF
+	}
+	// The first extra sample is alpha when the file says so, which it
+	// is premultiplied with when it says 1.
+	alpha := extra > 0 && t.value(tagExtraSamples, 0) != 0
+	premultiplied := t.value(tagExtraSamples, 0) == 1
+
+	if (photo == whiteIsZero || photo == blackIsZero) && !alpha {
+		m := image.NewGray(rect)
+		for y := range t.height {
+			row := samples[y*t.rowBytes:]
+			for x := range t.width {
+				v := t.sample(row, x*t.spp)
+				if photo == whiteIsZero {
+					v = 255 - v
+				}
+				m.Pix[y*m.Stride+x] = v
+			}
+		}
+		return &Image{Pixels: m, Bilevel: t.bps == 1 && t.spp == 1}, nil
+	}
+
+	var palette []color.NRGBA
+	if photo == photoPalette {
+		cm := t.tags[tagColorMap]
+		n := 1 << t.bps
+		if t.bps > 8 || len(cm) < 3*n {
+			return nil, errTIFF
+		}
+		palette = make([]color.NRGBA, n)
+		for i := range palette {
+			palette[i] = color.NRGBA{uint8(cm[i] >> 8), uint8(cm[n+i] >> 8), uint8(cm[2*n+i] >> 8), 0xFF}
+		}
+	}
+	m := image.NewNRGBA(rect)
+	for y := range t.height {
+		row := samples[y*t.rowBytes:]
+		for x := range t.width {
+			i := x * t.spp
+			var c color.NRGBA
+			switch photo {
+			case photoPalette:
+				c = palette[t.raw(row, i)]
+			case photoRGB:
+				c = color.NRGBA{t.sample(row, i), t.sample(row, i+1), t.sample(row, i+2), 0xFF}
+			default:
+				v := t.sample(row, i)
+				if photo == whiteIsZero {
+					v = 255 - v
+				}
+				c = color.NRGBA{v, v, v, 0xFF}
+			}
+			if alpha {
+				c.A = t.sample(row, t.spp-extra+i)
+				if premultiplied && c.A > 0 && c.A < 0xFF {
+					un := func(v uint8) uint8 { return uint8(min(255, int(v)*255/int(c.A))) }
+					c.R, c.G, c.B = un(c.R), un(c.G), un(c.B)
+				}
+			}
+			j := y*m.Stride + 4*x
+			m.Pix[j], m.Pix[j+1], m.Pix[j+2], m.Pix[j+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return &Image{Pixels: m}, nil
+}
+
+// unLZW decompresses TIFF's LZW, whose codes grow one entry earlier than
+// those of GIF, into at most limit bytes. Entries of the table are kept
+// as spans of the output, as each is an earlier output plus one byte.
+func unLZW(src []byte, limit int) ([]byte, error) {
+	const (
+		clear = 256
+		eoi   = 257
+	)
+	type span struct{ off, n int }
+	var table [4096]span
+	out := make([]byte, 0, limit)
+	next, width := 258, 9
+	var prev span
+	var acc uint32
+	var nacc, pos int
+	for len(out) < limit {
+		for nacc < width {
+			if pos >= len(src) {
+				return out, nil
+			}
+			acc = acc<<8 | uint32(src[pos])
+			pos++
+			nacc += 8
+		}
+		code := int(acc>>(nacc-width)) & (1<<width - 1)
+		nacc -= width
+		switch {
+		case code == clear:
+			next, width, prev = 258, 9, span{}
+			continue
+		case code == eoi:
+			return out, nil
+		}
+		cur := span{off: len(out)}
+		switch {
+		case code < clear:
+			out = append(out, byte(code))
+			cur.n = 1
+		case code < next:
+			e := table[code]
+			out = append(out, out[e.off:e.off+e.n]...)
+			cur.n = e.n
+		case code == next && prev.n > 0:
+			out = append(out, out[prev.off:prev.off+prev.n]...)
+			out = append(out, out[prev.off])
+			cur.n = prev.n + 1
+		default:
+			return out, errors.New("lzw: invalid code")
+		}
+		if prev.n > 0 && next < len(table) {
+			table[next] = span{prev.off, prev.n + 1}
+			next++
+		}
+		prev = cur
+		if next+1 >= 1<<width && width < 12 {
+			width++
+		}
+	}
+	return out[:limit], nil
+}
+
+// unPackBits decompresses PackBits runs into at most limit bytes.
+func unPackBits(src []byte, limit int) []byte {
+	out := make([]byte, 0, limit)
+	for i := 0; i < len(src) && len(out) < limit; {
+		n := int(int8(src[i]))
+		i++
+		switch {
+		case n >= 0:
+			end := min(i+n+1, len(src))
+			out = append(out, src[i:end]...)
+			i = end
+		case n != -128 && i < len(src):
+			for range 1 - n {
+				out = append(out, src[i])
+			}
+			i++
+		}
+	}
+	return out[:min(len(out), limit)]
+}
@@ -0,0 +1,104 @@
+package bitmap
+
+import (
+	"encoding/binary"
+	"image"
+	"slices"
+	"testing"
+)
+
+// tiffFile writes a little-endian TIFF whose directory has the given LONG
+// tags, with values of more than one entry stored after it, followed by
+// the image data.
+func tiffFile(tags map[int][]uint32, data []byte) []byte {
+	le := binary.LittleEndian
+	keys := make([]int, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	out := le.AppendUint32([]byte("II*\x00"), 8)
+	out = le.AppendUint16(out, uint16(len(keys)))
+	extra := 8 + 2 + 12*len(keys) + 4
+	var values []byte
+	for _, k := range keys {
+		v := tags[k]
+		out = le.AppendUint16(out, uint16(k))
+		out = le.AppendUint16(out, 4)
+		out = le.AppendUint32(out, uint32(len(v)))
+		if len(v) == 1 {
+			out = le.AppendUint32(out, v[0])
+			continue
+		}
+		out = le.AppendUint32(out, uint32(extra+len(values)))
+		for _, x := range v {
+			values = le.AppendUint32(values, x)
+		}
+	}
+	out = le.AppendUint32(out, 0)
+	return append(append(out, values...), data...)
+}
+
+func TestDecodeTiledTIFF(t *testing.T) {
+	// A 20 by 20 gray image in four 16 by 16 tiles, each filled with its
+	// index.
+	const size = 16 * 16
+	var data []byte
+	for i := range 4 {
+		data = append(data, slices.Repeat([]byte{byte(i)}, size)...)
+	}
+	header := len(tiffFile(map[int][]uint32{
+		tagWidth: {20}, tagHeight: {20}, tagBitsPerSample: {8}, tagPhotometric: {blackIsZero},
+		tagTileWidth: {16}, tagTileHeight: {16}, tagTileOffsets: {0, 0, 0, 0}, tagTileByteCounts: {0, 0, 0, 0},
+	}, nil))
+	offsets := make([]uint32, 4)
+	for i := range offsets {
+		offsets[i] = uint32(header + i*size)
+	}
+	file := tiffFile(map[int][]uint32{
+		tagWidth: {20}, tagHeight: {20}, tagBitsPerSample: {8}, tagPhotometric: {blackIsZero},
+		tagTileWidth: {16}, tagTileHeight: {16}, tagTileOffsets: offsets, tagTileByteCounts: {size, size, size, size},
+	}, data)
+
+	img, err := Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := img.Pixels.(*image.Gray)
+	if !ok {
+		t.Fatalf("pixels are %T, want *image.Gray", img.Pixels)
+	}
+	for _, p := range []struct{ x, y, want int }{{0, 0, 0}, {19, 0, 1}, {0, 19, 2}, {19, 19, 3}} {
+		if got := m.GrayAt(p.x, p.y).Y; int(got) != p.want {
+			t.Errorf("pixel %d,%d = %d, want %d", p.x, p.y, got, p.want)
+		}
+	}
+}
+
+func TestDecodeTIFFRejectsLargeTiles(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[int][]uint32
+	}{
+		{"huge tile", map[int][]uint32{
+			tagWidth: {16}, tagHeight: {16}, tagBitsPerSample: {16}, tagSamplesPerPixel: {8},
+			tagTileWidth: {60000}, tagTileHeight: {60000}, tagTileOffsets: {0}, tagTileByteCounts: {0},
+		}},
+		{"tile over the pixel limit", map[int][]uint32{
+			tagWidth: {16384}, tagHeight: {16384}, tagBitsPerSample: {16}, tagSamplesPerPixel: {8},
+			tagTileWidth: {16384}, tagTileHeight: {16384}, tagTileOffsets: {0}, tagTileByteCounts: {0},
+		}},
+		{"too many tiles", map[int][]uint32{
+			tagWidth: {16}, tagHeight: {16}, tagBitsPerSample: {8},
+			tagTileWidth: {16}, tagTileHeight: {16}, tagTileOffsets: {0, 0}, tagTileByteCounts: {0, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tiffFile(tt.tags, nil)); err == nil {
+				t.Fatal("decoding succeeded")
+			}
+		})
+	}
+}
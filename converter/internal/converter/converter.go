@@ -5,50 +5,377 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"path/filepath"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/domain"
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/dwg"
+	"github.com/you-humble/dwgtopdf/converter/internal/dxf"
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
+	"github.com/you-humble/dwgtopdf/converter/internal/pattern"
+	"github.com/you-humble/dwgtopdf/converter/internal/plotstyle"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+
+	"github.com/you-humble/dwgtopdf/core/libs/bundle"
+	"github.com/you-humble/dwgtopdf/core/libs/plot"
 )
 
-type FileSaver interface {
+type FileStore interface {
 	Save(ctx context.Context, reader io.Reader, filename string, size int64) (int64, string, error)
+	Open(ctx context.Context, filename string) (io.ReadCloser, int64, error)
 }
 
-type MockConverter struct {
-	fileStore FileSaver
-	baseDir   string
+type CADConverter struct {
+	fileStore  FileStore
+	baseDir    string
+	fonts      *font.Set
+	patterns   *pattern.Library
+	plotStyles *plotstyle.Library
 
 	sem chan struct{}
 }
 
-func NewMockConverter(fileStore FileSaver, baseDir string, maxParallel int) *MockConverter {
+func NewCADConverter(
+	fileStore FileStore,
+	baseDir string,
+	fonts *font.Set,
+	patterns *pattern.Library,
+	plotStyles *plotstyle.Library,
+	maxParallel int,
+) *CADConverter {
 	if maxParallel <= 0 {
 		maxParallel = 1
 	}
 
-	return &MockConverter{fileStore: fileStore, baseDir: baseDir, sem: make(chan struct{}, maxParallel)}
+	return &CADConverter{
+		fileStore:  fileStore,
+		baseDir:    baseDir,
+		fonts:      fonts,
+		patterns:   patterns,
+		plotStyles: plotStyles,
+		sem:        make(chan struct{}, maxParallel),
+	}
 }
 
-func (m *MockConverter) Convert(ctx context.Context, inputPath string, suggestedName string) (string, error) {
+func (c *CADConverter) Convert(ctx context.Context, p domain.ConvertParams) (domain.ConvertResult, error) {
 	select {
-	case m.sem <- struct{}{}:
-		defer func() { <-m.sem }()
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
 	case <-ctx.Done():
-		return "", fmt.Errorf("converter queue full or canceled: %w", ctx.Err())
+		return domain.ConvertResult{}, fmt.Errorf("converter queue full or canceled: %w", ctx.Err())
 	}
 
-	delay := time.Duration(500+rand.Intn(1500)) * time.Millisecond
+	opts := render.Options{
+		Margin:     10 * render.PointsPerMM,
+		Fonts:      c.fonts,
+		Patterns:   c.patterns,
+		PlotStyles: c.plotStyles,
+	}
+	output, err := outputFormat(p.Options.OutputFormat)
+	if err != nil {
+		return domain.ConvertResult{}, err
+	}
+	if err := applyOptions(&opts, p.Options, output); err != nil {
+		return domain.ConvertResult{}, err
+	}
+	rasterOpts, err := newRasterOptions(output, p.Options)
+	if err != nil {
+		return domain.ConvertResult{}, err
+	}
+	version, err := dxfVersion(p.Options.DXFVersion)
+	if err != nil {
+		return domain.ConvertResult{}, err
+	}
+	table, err := c.plotStyle(ctx, p)
+	if err != nil {
+		return domain.ConvertResult{}, err
+	}
+	opts.PlotStyle = table
+
+	d, b, path, err := c.load(ctx, p)
+	if err != nil {
+		return domain.ConvertResult{}, err
+	}
+	xrefs := newXrefLoader(b)
+	xrefs.bind(d, path)
+	images := newImageFiles(b, path)
+	opts.Images = images
+
+	name := outputName(p.InputPath, p.SuggestedName)
+	// DXF is written from the drawing itself, without drawing pages.
+	var sheets []*render.Sheet
+	var renderWarnings []string
+	if output != plot.OutputDXF {
+		sheets, renderWarnings = render.Render(d, opts)
+	}
+	meta := newMetadata(d, name, p)
+	var data []byte
+	var warnings []string
+	ext := ".pdf"
+	switch {
+	case output == plot.OutputSVG:
+		data, ext = pack(writeSVG(sheets, meta, svgOptions{links: !p.Options.NoHyperlinks}))
+	case output.Raster():
+		var files []outputFile
+		files, warnings = writeRaster(sheets, rasterOpts)
+		data, ext = pack(files)
+	case output == plot.OutputDXF:
+		data, warnings = dxf.Encode(d, version)
+		ext = ".dxf"
+	default:
+		data, err = writePDF(sheets, meta, pdfOptions{
+			layers:   p.Options.PDFLayers,
+			links:    !p.Options.NoHyperlinks,
+			archival: output == plot.OutputPDFA,
+		})
+		if err != nil {
+			return domain.ConvertResult{}, fmt.Errorf("render: %w", err)
+		}
+	}
+
+	out := bytes.NewReader(data)
+	resultName := uuid.NewString() + "_" + name + ext
+	if _, _, err := c.fileStore.Save(ctx, out, resultName, out.Size()); err != nil {
+		return domain.ConvertResult{}, err
+	}
+
+	res := domain.ConvertResult{
+		ResultName:      resultName,
+		Warnings:        slices.Concat(d.Warnings, xrefs.warnings, images.warnings, renderWarnings, warnings),
+		UnresolvedXrefs: xrefs.unresolved,
+	}
+	for _, s := range sheets {
+		res.Layouts = append(res.Layouts, s.Name)
+	}
+	return res, nil
+}
+
+// Thumbnail draws a small image of the first layout of a drawing, for
+// drawings without a preview image of their own. It is a quick drawing
+// on its own, before the conversion, so it leaves out external
+// references and raster images.
+func (c *CADConverter) Thumbnail(ctx context.Context, p domain.ThumbnailParams) (string, error) {
 	select {
-	case <-time.After(delay):
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return "", fmt.Errorf("converter queue full or canceled: %w", ctx.Err())
+	}
+
+	d, _, _, err := c.load(ctx, domain.ConvertParams{
+		InputPath:     p.InputPath,
+		SuggestedName: p.SuggestedName,
+		InputFormat:   p.InputFormat,
+	})
+	if err != nil {
+		return "", err
+	}
+	sheets, _ := render.Render(d, render.Options{
+		Margin:      10 * render.PointsPerMM,
+		Fonts:       c.fonts,
+		Patterns:    c.patterns,
+		MaxEntities: thumbnailEntities,
+	})
+	thumb := bytes.NewReader(writeThumbnail(sheets[0]))
+	name := uuid.NewString() + "_thumbnail.png"
+	if _, _, err := c.fileStore.Save(ctx, thumb, name, thumb.Size()); err != nil {
+		return "", err
 	}
+	return name, nil
+}
+
+// load reads the drawing to convert, which comes on its own or in a
+// bundle with the files it references. For a bundle it also returns the
+// bundle and the path of the drawing in it.
+func (c *CADConverter) load(ctx context.Context, p domain.ConvertParams) (*drawing.Drawing, *bundle.Bundle, string, error) {
+	rc, _, err := c.fileStore.Open(ctx, p.InputPath)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("open input: %w", err)
+	}
+	defer rc.Close()
 
-	pdf := bytes.NewReader([]byte("%PDF-1.4\n% Mock DWG->PDF\n1 0 obj<<>>endobj\ntrailer<<>>\n%%EOF\n"))
+	format := p.InputFormat
+	if format == "" {
+		format = formatOf(p.InputPath)
+	}
+	if format != domain.FormatBundle {
+		d, err := parse(rc, format)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return d, nil, "", nil
+	}
 
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("read input: %w", err)
+	}
+	b, err := bundle.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("open bundle: %w", err)
+	}
+	name, err := b.Drawing(p.SuggestedName)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if data, err = b.ReadFile(name); err != nil {
+		return nil, nil, "", err
+	}
+	d, err := parse(bytes.NewReader(data), formatOf(name))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return d, b, name, nil
+}
+
+func formatOf(name string) domain.InputFormat {
+	return domain.InputFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."))
+}
+
+func parse(r io.Reader, format domain.InputFormat) (*drawing.Drawing, error) {
+	var d *drawing.Drawing
+	var err error
+	switch format {
+	case domain.FormatDWG:
+		d, err = dwg.Read(r)
+	case domain.FormatDXF:
+		d, err = dxf.Read(r)
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse input: %w", err)
+	}
+	return d, nil
+}
+
+// plotStyle returns the plot style table a request asks for, either
+// uploaded with the drawing or installed by name; nil keeps the tables of
+// the page setups.
+func (c *CADConverter) plotStyle(ctx context.Context, p domain.ConvertParams) (*plotstyle.Table, error) {
+	if p.PlotStylePath != "" {
+		rc, _, err := c.fileStore.Open(ctx, p.PlotStylePath)
+		if err != nil {
+			return nil, fmt.Errorf("open plot style table: %w", err)
+		}
+		defer rc.Close()
+
+		t, err := plotstyle.Read(rc, p.PlotStylePath)
+		if err != nil {
+			return nil, fmt.Errorf("parse plot style table: %w", err)
+		}
+		return t, nil
+	}
+	if name := p.Options.PlotStyle; name != "" {
+		t := c.plotStyles.Lookup(name)
+		if t == nil {
+			return nil, fmt.Errorf("plot style table %q is not installed", name)
+		}
+		return t, nil
+	}
+	return nil, nil
+}
+
+// outputFormat reads the format a request asks for, PDF unless it names
+// another.
+func outputFormat(s string) (plot.Output, error) {
+	if s == "" {
+		return plot.OutputPDF, nil
+	}
+	return plot.ParseOutput(s)
+}
+
+// dxfVersion reads the version of DXF files a request asks for, 2018
+// unless it names another.
+func dxfVersion(s string) (dxf.Version, error) {
+	if s == "" {
+		return dxf.R2018, nil
+	}
+	v, err := plot.ParseDXFVersion(s)
+	if err != nil {
+		return "", err
+	}
+	switch v {
+	case plot.DXFR12:
+		return dxf.R12, nil
+	case plot.DXF2000:
+		return dxf.R2000, nil
+	}
+	return dxf.R2018, nil
+}
+
+// applyOptions sets the overrides a request asks for when converting to
+// output.
+func applyOptions(opts *render.Options, o domain.ConvertOptions, output plot.Output) error {
+	if o.PaperSize != "" {
+		p, err := plot.ParsePaper(o.PaperSize)
+		if err != nil {
+			return err
+		}
+		opts.PageWidth, opts.PageHeight = p.Width*render.PointsPerMM, p.Height*render.PointsPerMM
+	}
+	if o.Orientation != "" {
+		v, err := plot.ParseOrientation(o.Orientation)
+		if err != nil {
+			return err
+		}
+		opts.Orientation = render.Portrait
+		if v == plot.Landscape {
+			opts.Orientation = render.Landscape
+		}
+	}
+	if o.Scale != "" {
+		v, err := plot.ParseScale(o.Scale)
+		if err != nil {
+			return err
+		}
+		opts.Fit, opts.Scale = v.Fit, v.Factor
+	}
+	if o.Lineweights != "" {
+		v, err := plot.ParseLineweights(o.Lineweights)
+		if err != nil {
+			return err
+		}
+		if v == plot.DisplayLineweights {
+			opts.Lineweights = render.LineweightsDisplay
+		}
+	}
+	if o.SHXText != "" {
+		v, err := plot.ParseSHXText(o.SHXText)
+		if err != nil {
+			return err
+		}
+		switch v {
+		case plot.SHXStrokes:
+			opts.SHXText = render.SHXStrokes
+		case plot.SHXOverlay:
+			opts.SHXText = render.SHXOverlay
+		}
+	}
+	opts.IncludeLayers = layerList(o.IncludeLayers)
+	opts.ExcludeLayers = layerList(o.ExcludeLayers)
+	// Only PDF viewers can turn layers on, other outputs keep them off.
+	opts.OffLayers = o.PDFLayers && (output == plot.OutputPDF || output == plot.OutputPDFA)
+	return nil
+}
+
+// layerList trims the layer names or patterns of a request and drops the
+// empty ones.
+func layerList(names []string) []string {
+	var out []string
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func outputName(inputPath, suggestedName string) string {
 	base := suggestedName
 	if base == "" {
 		base = filepath.Base(inputPath)
@@ -58,11 +385,6 @@ func (m *MockConverter) Convert(ctx context.Context, inputPath string, suggested
 	if name == "" {
 		name = "output"
 	}
-	pdfName := uuid.NewString() + "_" + name + ".pdf"
-
-	if _, _, err := m.fileStore.Save(ctx, pdf, pdfName, pdf.Size()); err != nil {
-		return "", err
-	}
 
-	return pdfName, nil
+	return name
 }
@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// outputFile is a file of the result of a conversion, such as the image
+// of a sheet.
+type outputFile struct {
+	name string
+	data []byte
+}
+
+// pack returns the only file of a result, or a ZIP archive of several,
+// with the extension of what it returns.
+func pack(files []outputFile) ([]byte, string) {
+	if len(files) == 1 {
+		return files[0].data, filepath.Ext(files[0].name)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		// Writing to memory cannot fail.
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		_, _ = w.Write(f.data)
+	}
+	_ = zw.Close()
+	return buf.Bytes(), ".zip"
+}
+
+// fileNames names a file for each sheet after its layout, made safe for a
+// file name and unique.
+func fileNames(sheets []*render.Sheet, ext string) []string {
+	names := make([]string, len(sheets))
+	used := make(map[string]bool)
+	for i, s := range sheets {
+		base := strings.Map(func(r rune) rune {
+			if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+				return '_'
+			}
+			return r
+		}, s.Name)
+		base = strings.Trim(base, " .")
+		if base == "" {
+			base = "Layout"
+		}
+		name := base + ext
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
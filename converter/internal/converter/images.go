@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/bitmap"
+	"github.com/you-humble/dwgtopdf/converter/internal/pdf"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+
+	"github.com/you-humble/dwgtopdf/core/libs/bundle"
+)
+
+// imageFiles finds the images a drawing references in the bundle it was
+// uploaded in, and keeps a warning for each one it cannot find or read.
+type imageFiles struct {
+	bundle *bundle.Bundle
+	// drawing is the path of the drawing in the bundle, which relative
+	// references start from.
+	drawing  string
+	images   map[string]*bitmap.Image
+	warnings []string
+}
+
+// newImageFiles looks images up in b, which is nil for a drawing uploaded
+// on its own.
+func newImageFiles(b *bundle.Bundle, drawing string) *imageFiles {
+	return &imageFiles{bundle: b, drawing: drawing, images: make(map[string]*bitmap.Image)}
+}
+
+func (f *imageFiles) Image(file string) *bitmap.Image {
+	if img, ok := f.images[file]; ok {
+		return img
+	}
+	img, err := f.load(file)
+	if err != nil {
+		f.warnings = append(f.warnings, err.Error())
+	}
+	f.images[file] = img
+	return img
+}
+
+func (f *imageFiles) load(file string) (*bitmap.Image, error) {
+	var name string
+	if f.bundle != nil {
+		name = f.bundle.Find(f.drawing, file)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("image %s is missing from the upload", file)
+	}
+	data, err := f.bundle.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", file, err)
+	}
+	img, err := bitmap.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", file, err)
+	}
+	return img, nil
+}
+
+// imageObjects embeds each image once per document.
+type imageObjects struct {
+	doc  *pdf.Document
+	refs map[imageKey]pdf.Ref
+}
+
+// imageKey tells apart the objects of an image, which differ in how
+// transparent pixels are written.
+type imageKey struct {
+	bitmap      *bitmap.Image
+	transparent bool
+}
+
+func newImageObjects(doc *pdf.Document) *imageObjects {
+	return &imageObjects{doc: doc, refs: make(map[imageKey]pdf.Ref)}
+}
+
+// picture draws an image clipped to the path of its item. Bilevel images
+// are stencil masks painted in the item color, over white unless they
+// are transparent.
+func picture(images *imageObjects, page *pdf.Page, it *render.Item) {
+	c := &page.Content
+	img := it.Image
+	c.Save()
+	path(c, &it.Path)
+	if it.EvenOdd {
+		c.ClipEvenOdd()
+	} else {
+		c.Clip()
+	}
+	c.EndPath()
+	if img.Bitmap.Bilevel {
+		if !img.Transparent {
+			c.SetFillRGB(1, 1, 1)
+			path(c, &it.Path)
+			c.Fill()
+		}
+		c.SetFillRGB(channels(it.Color))
+	}
+	m := img.Matrix
+	c.Transform(m[0], m[4], m[1], m[5], m[3], m[7])
+	c.DrawXObject(page.XObject(images.ref(img)))
+	c.Restore()
+}
+
+func (o *imageObjects) ref(img *render.Image) pdf.Ref {
+	key := imageKey{img.Bitmap, img.Transparent}
+	if ref, ok := o.refs[key]; ok {
+		return ref
+	}
+	ref := o.doc.Add(o.xobject(img.Bitmap, img.Transparent))
+	o.refs[key] = ref
+	return ref
+}
+
+// xobject builds the image XObject of a bitmap. JPEG files are embedded
+// as they are; other images are written as 8-bit samples, with their
+// alpha as a soft mask when transparent is set and blended onto white
+// otherwise.
+func (o *imageObjects) xobject(bm *bitmap.Image, transparent bool) pdf.Stream {
+	b := bm.Pixels.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dict := pdf.Dict{
+		"Type":             pdf.Name("XObject"),
+		"Subtype":          pdf.Name("Image"),
+		"Width":            w,
+		"Height":           h,
+		"BitsPerComponent": 8,
+	}
+
+	if bm.Bilevel {
+		// Mask samples of 0 are painted, so set bits are the background.
+		rowBytes := (w + 7) / 8
+		data := make([]byte, rowBytes*h)
+		pix, _ := bm.Pixels.(*image.Gray)
+		for y := range h {
+			for x := range w {
+				var v uint8
+				if pix != nil {
+					v = pix.Pix[y*pix.Stride+x]
+				} else {
+					v = color.GrayModel.Convert(bm.Pixels.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+				}
+				if v >= 0x80 {
+					data[y*rowBytes+x/8] |= 0x80 >> (x % 8)
+				}
+			}
+		}
+		dict["ImageMask"] = true
+		dict["BitsPerComponent"] = 1
+		return pdf.Stream{Dict: dict, Data: data}
+	}
+
+	_, gray := bm.Pixels.(*image.Gray)
+	space := pdf.Name("DeviceRGB")
+	if gray {
+		space = "DeviceGray"
+	}
+	dict["ColorSpace"] = space
+	if bm.JPEG != nil {
+		dict["Filter"] = pdf.Name("DCTDecode")
+		return pdf.Stream{Dict: dict, Data: bm.JPEG}
+	}
+
+	var data, alpha []byte
+	translucent := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(bm.Pixels.At(x, y)).(color.NRGBA)
+			if !transparent && c.A < 0xFF {
+				over := func(v uint8) uint8 { return uint8((int(v)*int(c.A) + 255*(255-int(c.A)) + 127) / 255) }
+				c = color.NRGBA{over(c.R), over(c.G), over(c.B), 0xFF}
+			}
+			if gray {
+				data = append(data, c.R)
+			} else {
+				data = append(data, c.R, c.G, c.B)
+			}
+			alpha = append(alpha, c.A)
+			translucent = translucent || c.A < 0xFF
+		}
+	}
+	if translucent {
+		dict["SMask"] = o.doc.Add(pdf.Stream{
+			Dict: pdf.Dict{
+				"Type":             pdf.Name("XObject"),
+				"Subtype":          pdf.Name("Image"),
+				"Width":            w,
+				"Height":           h,
+				"ColorSpace":       pdf.Name("DeviceGray"),
+				"BitsPerComponent": 8,
+			},
+			Data: alpha,
+		})
+	}
+	return pdf.Stream{Dict: dict, Data: data}
+}
@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/pdf"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// layerGroups makes each layer an optional content group that viewers
+// list and let the reader turn on and off. Groups are shared by all pages
+// and start off for layers that are off in the drawing.
+type layerGroups struct {
+	doc    *pdf.Document
+	groups map[string]pdf.Ref // upper-case layer name to group
+	order  pdf.Array
+	off    pdf.Array
+}
+
+func newLayerGroups(doc *pdf.Document) *layerGroups {
+	return &layerGroups{doc: doc, groups: make(map[string]pdf.Ref)}
+}
+
+// sheet returns the group of each item layer of a sheet, by the name the
+// items use; it is nil when layers are not grouped.
+func (g *layerGroups) sheet(s *render.Sheet) map[string]pdf.Ref {
+	if g == nil {
+		return nil
+	}
+	refs := make(map[string]pdf.Ref)
+	for _, l := range s.Layers {
+		key := strings.ToUpper(l.Name)
+		ref, ok := g.groups[key]
+		if !ok {
+			ref = g.doc.Add(pdf.Dict{"Type": pdf.Name("OCG"), "Name": pdf.TextString(l.Name)})
+			g.groups[key] = ref
+			g.order = append(g.order, ref)
+			if l.Off {
+				g.off = append(g.off, ref)
+			}
+		}
+		refs[key] = ref
+	}
+	for i := range s.Items {
+		name := s.Items[i].Layer
+		if _, ok := refs[name]; !ok {
+			if ref, ok := refs[strings.ToUpper(name)]; ok {
+				refs[name] = ref
+			}
+		}
+	}
+	return refs
+}
+
+// finish lists the groups in the document catalog.
+func (g *layerGroups) finish() {
+	if g == nil || len(g.order) == 0 {
+		return
+	}
+	config := pdf.Dict{"Name": pdf.String("Layers"), "Order": g.order}
+	if len(g.off) > 0 {
+		config["OFF"] = g.off
+	}
+	g.doc.Catalog["OCProperties"] = pdf.Dict{"OCGs": g.order, "D": config}
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/pdf"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// links adds a link annotation over each entity with a hyperlink. Links to
+// a layout or named view of the drawing itself go to where the document
+// shows it, if it does; relative URLs resolve against the hyperlink base
+// of the drawing. Entities inside a linked block are annotated over it,
+// so their own links win.
+func links(doc *pdf.Document, sheets []*render.Sheet, base string) {
+	pages := doc.Pages()
+	dests := make(map[string]pdf.Array)
+	for i, s := range sheets {
+		for _, v := range s.Views {
+			if _, ok := dests[strings.ToUpper(v.Name)]; !ok {
+				dests[strings.ToUpper(v.Name)] = viewDest(pages[i], v)
+			}
+		}
+	}
+	for i, s := range sheets {
+		if _, ok := dests[strings.ToUpper(s.Name)]; !ok {
+			dests[strings.ToUpper(s.Name)] = sheetDest(pages[i])
+		}
+	}
+
+	used := false
+	for i, s := range sheets {
+		for _, l := range sortedLinks(s.Links) {
+			r := linkArea(l.Area)
+			annot := pdf.Dict{
+				"Type":    pdf.Name("Annot"),
+				"Subtype": pdf.Name("Link"),
+				"Rect":    pdf.Array{r.Min.X, r.Min.Y, r.Max.X, r.Max.Y},
+				"Border":  pdf.Array{0, 0, 0},
+				// Printable, as PDF/A wants every annotation to be.
+				"F": 4,
+			}
+			h := l.Hyperlink
+			if h.Description != "" {
+				annot["Contents"] = pdf.TextString(h.Description)
+			}
+			switch {
+			case h.URL != "":
+				uri := h.URL
+				if h.SubLocation != "" && !strings.Contains(uri, "#") {
+					uri += "#" + h.SubLocation
+				}
+				annot["A"] = pdf.Dict{"S": pdf.Name("URI"), "URI": pdf.String(uriEscape(uri))}
+				used = true
+			default:
+				dest, ok := dests[strings.ToUpper(strings.TrimSpace(h.SubLocation))]
+				if !ok {
+					continue
+				}
+				annot["Dest"] = dest
+			}
+			pages[i].Annots = append(pages[i].Annots, doc.Add(annot))
+		}
+	}
+	if used && base != "" {
+		doc.Catalog["URI"] = pdf.Dict{"Base": pdf.String(uriEscape(base))}
+	}
+}
+
+// sortedLinks orders links from the largest to the smallest, so that
+// links inside others are laid over them.
+func sortedLinks(links []render.Link) []render.Link {
+	list := slices.Clone(links)
+	slices.SortStableFunc(list, func(a, b render.Link) int {
+		return cmp.Compare(b.Area.Width()*b.Area.Height(), a.Area.Width()*a.Area.Height())
+	})
+	return list
+}
+
+// minLinkSize is the least width and height of a link in points, so that
+// links over lines and small text can be clicked.
+const minLinkSize = 6
+
+func linkArea(b geom.Box) geom.Box {
+	c := b.Center()
+	w, h := max(b.Width(), minLinkSize)/2, max(b.Height(), minLinkSize)/2
+	return geom.Box{Min: geom.Vec2{X: c.X - w, Y: c.Y - h}, Max: geom.Vec2{X: c.X + w, Y: c.Y + h}, Valid: true}
+}
+
+// uriEscape percent-encodes the bytes a PDF URI, which is ASCII, cannot
+// hold as they are.
+func uriEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7F {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
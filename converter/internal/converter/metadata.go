@@ -0,0 +1,262 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/domain"
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/pdf"
+)
+
+// xmpNamespace holds the properties that have no place in the standard
+// schemas: the drawing's custom properties and where the PDF came from.
+const xmpNamespace = "https://github.com/you-humble/dwgtopdf/ns/1.0/"
+
+// metadata describes the document: the drawing properties, with the name
+// of the output as the title when the drawing has none, and the file and
+// task it was converted from.
+type metadata struct {
+	name    string
+	summary drawing.Summary
+	created time.Time
+	updated time.Time
+	source  string
+	taskID  string
+	// archival declares the PDF/A-2b conformance of the document and
+	// describes the dwgtopdf schema, as PDF/A asks of schemas it does not
+	// know.
+	archival bool
+}
+
+func newMetadata(d *drawing.Drawing, name string, p domain.ConvertParams) metadata {
+	source := p.SuggestedName
+	if source == "" {
+		source = filepath.Base(p.InputPath)
+	}
+	return metadata{
+		name:    name,
+		summary: d.Summary,
+		created: d.Header.Created,
+		updated: d.Header.Updated,
+		source:  source,
+		taskID:  p.TaskID,
+	}
+}
+
+func (m metadata) title() string {
+	if t := strings.TrimSpace(m.summary.Title); t != "" {
+		return t
+	}
+	return m.name
+}
+
+// custom lists the properties written as extra entries of the Info
+// dictionary and in the dwgtopdf XMP schema.
+func (m metadata) custom() []drawing.Property {
+	s := m.summary
+	var props []drawing.Property
+	add := func(name, value string) {
+		if value != "" {
+			props = append(props, drawing.Property{Name: name, Value: value})
+		}
+	}
+	add("Comments", s.Comments)
+	add("LastSavedBy", s.LastSavedBy)
+	add("Revision", s.Revision)
+	add("SourceFile", m.source)
+	add("TaskID", m.taskID)
+	for _, p := range s.Custom {
+		add(p.Name, p.Value)
+	}
+	return props
+}
+
+// write fills the Info dictionary of doc and adds the same properties as
+// an XMP packet in the catalog.
+func (m metadata) write(doc *pdf.Document) {
+	s := m.summary
+	info := doc.Info
+	info["Title"] = pdf.TextString(m.title())
+	set := func(key pdf.Name, value string) {
+		if value != "" {
+			info[key] = pdf.TextString(value)
+		}
+	}
+	set("Author", s.Author)
+	set("Subject", s.Subject)
+	set("Keywords", s.Keywords)
+	if !m.created.IsZero() {
+		info["CreationDate"] = pdf.String(pdfDate(m.created))
+	}
+	if !m.updated.IsZero() {
+		info["ModDate"] = pdf.String(pdfDate(m.updated))
+	}
+	for _, p := range m.custom() {
+		// The standard entries win over custom properties of the same name.
+		if _, ok := info[pdf.Name(p.Name)]; !ok {
+			info[pdf.Name(p.Name)] = pdf.TextString(p.Value)
+		}
+	}
+
+	doc.Catalog["Metadata"] = doc.Add(pdf.Stream{
+		Dict:  pdf.Dict{"Type": pdf.Name("Metadata"), "Subtype": pdf.Name("XML")},
+		Data:  m.xmp(producer(info)),
+		Plain: true,
+	})
+}
+
+func producer(info pdf.Dict) string {
+	if p, ok := info["Producer"].(pdf.String); ok {
+		return string(p)
+	}
+	return ""
+}
+
+// xmp builds the XMP packet, with the title, author, subject and keywords
+// in Dublin Core as well as in the PDF schema.
+func (m metadata) xmp(producer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.Write(m.rdf(producer))
+	b.WriteString("</x:xmpmeta>\n")
+	// Padding lets editors update the packet in place.
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
+
+// rdf describes the document in RDF, as XMP packets and SVG metadata
+// hold it.
+func (m metadata) rdf(producer string) []byte {
+	s := m.summary
+	var b bytes.Buffer
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about=""` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:pdf="http://ns.adobe.com/pdf/1.3/"` +
+		` xmlns:xmp="http://ns.adobe.com/xap/1.0/"` +
+		` xmlns:dwgtopdf="` + xmpNamespace + `"`)
+	if m.archival {
+		b.WriteString(` xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/"` +
+			` xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/"` +
+			` xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#"` +
+			` xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#"` +
+			` xmlns:pdfaType="http://www.aiim.org/pdfa/ns/type#"` +
+			` xmlns:pdfaField="http://www.aiim.org/pdfa/ns/field#"`)
+	}
+	b.WriteString(">\n")
+
+	alt := func(tag, value string) {
+		fmt.Fprintf(&b, "<%s><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></%s>\n", tag, escape(value), tag)
+	}
+	simple := func(tag, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "<%s>%s</%s>\n", tag, escape(value), tag)
+		}
+	}
+	alt("dc:title", m.title())
+	if s.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", escape(s.Author))
+	}
+	if s.Subject != "" {
+		alt("dc:description", s.Subject)
+	}
+	if keywords := splitKeywords(s.Keywords); len(keywords) > 0 {
+		b.WriteString("<dc:subject><rdf:Bag>")
+		for _, k := range keywords {
+			fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", escape(k))
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	simple("pdf:Keywords", s.Keywords)
+	simple("pdf:Producer", producer)
+	if !m.created.IsZero() {
+		simple("xmp:CreateDate", m.created.UTC().Format(time.RFC3339))
+	}
+	if !m.updated.IsZero() {
+		simple("xmp:ModifyDate", m.updated.UTC().Format(time.RFC3339))
+	}
+	if props := m.custom(); len(props) > 0 {
+		b.WriteString("<dwgtopdf:Properties><rdf:Bag>\n")
+		for _, p := range props {
+			fmt.Fprintf(&b, "<rdf:li rdf:parseType=\"Resource\"><dwgtopdf:Name>%s</dwgtopdf:Name><dwgtopdf:Value>%s</dwgtopdf:Value></rdf:li>\n",
+				escape(p.Name), escape(p.Value))
+		}
+		b.WriteString("</rdf:Bag></dwgtopdf:Properties>\n")
+	}
+	if m.archival {
+		b.WriteString("<pdfaid:part>2</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n")
+		b.WriteString(extensionSchema)
+	}
+
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n")
+	return b.Bytes()
+}
+
+// extensionSchema describes the dwgtopdf schema: a bag of properties,
+// each a name and a value.
+const extensionSchema = `<pdfaExtension:schemas><rdf:Bag>
+<rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>dwgtopdf drawing properties</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>` + xmpNamespace + `</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>dwgtopdf</pdfaSchema:prefix>
+<pdfaSchema:property><rdf:Seq>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>Properties</pdfaProperty:name>
+<pdfaProperty:valueType>bag Property</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>Properties of the drawing and of its conversion</pdfaProperty:description>
+</rdf:li>
+</rdf:Seq></pdfaSchema:property>
+<pdfaSchema:valueType><rdf:Seq>
+<rdf:li rdf:parseType="Resource">
+<pdfaType:type>Property</pdfaType:type>
+<pdfaType:namespaceURI>` + xmpNamespace + `</pdfaType:namespaceURI>
+<pdfaType:prefix>dwgtopdf</pdfaType:prefix>
+<pdfaType:description>A named property</pdfaType:description>
+<pdfaType:field><rdf:Seq>
+<rdf:li rdf:parseType="Resource">
+<pdfaField:name>Name</pdfaField:name>
+<pdfaField:valueType>Text</pdfaField:valueType>
+<pdfaField:description>Name of the property</pdfaField:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaField:name>Value</pdfaField:name>
+<pdfaField:valueType>Text</pdfaField:valueType>
+<pdfaField:description>Value of the property</pdfaField:description>
+</rdf:li>
+</rdf:Seq></pdfaType:field>
+</rdf:li>
+</rdf:Seq></pdfaSchema:valueType>
+</rdf:li>
+</rdf:Bag></pdfaExtension:schemas>
+`
+
+// splitKeywords splits the keywords of a drawing, which users separate
+// with commas or semicolons.
+func splitKeywords(s string) []string {
+	var out []string
+	for _, k := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if k = strings.TrimSpace(k); k != "" {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// pdfDate formats t as a PDF date in UTC.
+func pdfDate(t time.Time) string {
+	return t.UTC().Format("D:20060102150405Z")
+}
@@ -0,0 +1,76 @@
+package converter
+
+import (
+	"github.com/you-humble/dwgtopdf/converter/internal/pdf"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// outline lists the sheets in the document outline, one entry per page
+// showing all of it, with an entry under it for each named view on the
+// sheet that zooms the page to the view. Entries with views start
+// closed, so long sets list only their layouts.
+func outline(doc *pdf.Document, sheets []*render.Sheet) {
+	if len(sheets) == 0 {
+		return
+	}
+	pages := doc.Pages()
+	root := doc.Reserve()
+	items := make([]pdf.Dict, len(sheets))
+	refs := make([]pdf.Ref, len(sheets))
+	for i, s := range sheets {
+		refs[i] = doc.Reserve()
+		items[i] = pdf.Dict{
+			"Title":  pdf.TextString(s.Name),
+			"Parent": root,
+			"Dest":   sheetDest(pages[i]),
+		}
+		if len(s.Views) == 0 {
+			continue
+		}
+		views := make([]pdf.Dict, len(s.Views))
+		viewRefs := make([]pdf.Ref, len(s.Views))
+		for j, v := range s.Views {
+			viewRefs[j] = doc.Reserve()
+			views[j] = pdf.Dict{
+				"Title":  pdf.TextString(v.Name),
+				"Parent": refs[i],
+				"Dest":   viewDest(pages[i], v),
+			}
+		}
+		link(doc, views, viewRefs)
+		items[i]["First"], items[i]["Last"] = viewRefs[0], viewRefs[len(viewRefs)-1]
+		items[i]["Count"] = -len(views)
+	}
+	link(doc, items, refs)
+	doc.Set(root, pdf.Dict{
+		"Type":  pdf.Name("Outlines"),
+		"First": refs[0],
+		"Last":  refs[len(refs)-1],
+		"Count": len(refs),
+	})
+	doc.Catalog["Outlines"] = root
+	doc.Catalog["PageMode"] = pdf.Name("UseOutlines")
+}
+
+// link chains sibling outline entries and stores them at their refs.
+func link(doc *pdf.Document, items []pdf.Dict, refs []pdf.Ref) {
+	for i, it := range items {
+		if i > 0 {
+			it["Prev"] = refs[i-1]
+		}
+		if i+1 < len(items) {
+			it["Next"] = refs[i+1]
+		}
+		doc.Set(refs[i], it)
+	}
+}
+
+// sheetDest shows all of a page.
+func sheetDest(page *pdf.Page) pdf.Array {
+	return pdf.Array{page.Ref(), pdf.Name("Fit")}
+}
+
+// viewDest zooms a page to a view on it.
+func viewDest(page *pdf.Page, v render.View) pdf.Array {
+	return pdf.Array{page.Ref(), pdf.Name("FitR"), v.Area.Min.X, v.Area.Min.Y, v.Area.Max.X, v.Area.Max.Y}
+}
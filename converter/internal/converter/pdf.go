@@ -0,0 +1,261 @@
+package converter
+
+import (
+	"bytes"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/pdf"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// pdfOptions are the parts of a request that only concern the PDF.
+type pdfOptions struct {
+	// layers makes the items of each layer an optional content group.
+	layers bool
+	// links adds link annotations over entities with hyperlinks.
+	links bool
+	// archival makes the PDF conform to PDF/A-2b.
+	archival bool
+}
+
+// writePDF draws every sheet on its own page, listed in the outline with
+// its named views.
+func writePDF(sheets []*render.Sheet, meta metadata, opts pdfOptions) ([]byte, error) {
+	doc := pdf.New()
+	doc.PDFA = opts.archival
+	meta.archival = opts.archival
+	meta.write(doc)
+	var groups *layerGroups
+	if opts.layers {
+		groups = newLayerGroups(doc)
+	}
+	images := newImageObjects(doc)
+	for _, s := range sheets {
+		drawSheet(doc, doc.AddPage(s.Width, s.Height), s, groups.sheet(s), images)
+	}
+	groups.finish()
+	outline(doc, sheets)
+	if opts.links {
+		links(doc, sheets, meta.summary.HyperlinkBase)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawSheet draws the items of a sheet, marking those on a layer of
+// groups as optional content of its group.
+func drawSheet(doc *pdf.Document, page *pdf.Page, s *render.Sheet, groups map[string]pdf.Ref, images *imageObjects) {
+	c := &page.Content
+	// Plotted CAD geometry uses round caps and joins.
+	c.SetLineCap(1)
+	c.SetLineJoin(1)
+	g := gstate{width: -1}
+	var clip *render.Path
+	// group is the group of the open marked content, if any. Marked
+	// content is closed around clipping, so that it nests with the
+	// graphics state.
+	var group pdf.Ref
+	for i := range s.Items {
+		it := &s.Items[i]
+		if group != 0 && (groups[it.Layer] != group || it.Clip != clip) {
+			c.EndMarked()
+			group = 0
+		}
+		if it.Clip != clip {
+			// Restoring drops the colors and width set since saving.
+			if clip != nil {
+				c.Restore()
+				g = gstate{width: -1}
+			}
+			if clip = it.Clip; clip != nil {
+				c.Save()
+				path(c, clip)
+				c.Clip()
+				c.EndPath()
+			}
+		}
+		if ref := groups[it.Layer]; ref != 0 && ref != group {
+			c.BeginMarked("OC", page.Property(ref))
+			group = ref
+		}
+		if it.Text != nil {
+			g.fill(c, it.Color)
+			text(doc, page, it.Text)
+			continue
+		}
+		if it.Gradient != nil {
+			shade(doc, page, &it.Path, it.EvenOdd, it.Gradient)
+			continue
+		}
+		if it.Image != nil {
+			picture(images, page, it)
+			continue
+		}
+		if it.Fill {
+			g.fill(c, it.Color)
+		} else {
+			g.stroke(c, it.Color, it.Width)
+		}
+		path(c, &it.Path)
+		switch {
+		case !it.Fill:
+			c.Stroke()
+		case it.EvenOdd:
+			c.FillEvenOdd()
+		default:
+			c.Fill()
+		}
+	}
+	if group != 0 {
+		c.EndMarked()
+	}
+	if clip != nil {
+		c.Restore()
+	}
+}
+
+// gstate tracks the current colors and width so unchanged values are not
+// written again for every item.
+type gstate struct {
+	strokeColor, fillColor render.RGB
+	strokeSet, fillSet     bool
+	width                  float64
+}
+
+func (g *gstate) stroke(c *pdf.Content, color render.RGB, width float64) {
+	if !g.strokeSet || g.strokeColor != color {
+		c.SetStrokeRGB(channels(color))
+		g.strokeColor, g.strokeSet = color, true
+	}
+	if g.width != width {
+		c.SetLineWidth(width)
+		g.width = width
+	}
+}
+
+func (g *gstate) fill(c *pdf.Content, color render.RGB) {
+	if !g.fillSet || g.fillColor != color {
+		c.SetFillRGB(channels(color))
+		g.fillColor, g.fillSet = color, true
+	}
+}
+
+func channels(c render.RGB) (float64, float64, float64) {
+	return float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255
+}
+
+// shade paints a gradient clipped to the path.
+func shade(doc *pdf.Document, page *pdf.Page, p *render.Path, evenOdd bool, g *render.Gradient) {
+	c := &page.Content
+	c.Save()
+	path(c, p)
+	if evenOdd {
+		c.ClipEvenOdd()
+	} else {
+		c.Clip()
+	}
+	c.EndPath()
+	c.Shade(page.Shading(doc.Add(shading(g))))
+	c.Restore()
+}
+
+// shading builds an axial or radial shading whose color function
+// interpolates linearly between the stops.
+func shading(g *render.Gradient) pdf.Dict {
+	color := func(c render.RGB) pdf.Array {
+		cr, cg, cb := channels(c)
+		return pdf.Array{cr, cg, cb}
+	}
+	var fns, bounds, encode pdf.Array
+	for i := 1; i < len(g.Stops); i++ {
+		fns = append(fns, pdf.Dict{
+			"FunctionType": 2,
+			"Domain":       pdf.Array{0, 1},
+			"C0":           color(g.Stops[i-1].Color),
+			"C1":           color(g.Stops[i].Color),
+			"N":            1,
+		})
+		if i > 1 {
+			bounds = append(bounds, g.Stops[i-1].Offset)
+		}
+		encode = append(encode, 0, 1)
+	}
+	var fn any
+	switch len(fns) {
+	case 0:
+		c := color(g.Stops[0].Color)
+		fn = pdf.Dict{"FunctionType": 2, "Domain": pdf.Array{0, 1}, "C0": c, "C1": c, "N": 1}
+	case 1:
+		fn = fns[0]
+	default:
+		fn = pdf.Dict{
+			"FunctionType": 3,
+			"Domain":       pdf.Array{0, 1},
+			"Functions":    fns,
+			"Bounds":       bounds,
+			"Encode":       encode,
+		}
+	}
+	sh := pdf.Dict{
+		"ShadingType": 2,
+		"ColorSpace":  pdf.Name("DeviceRGB"),
+		"Coords":      pdf.Array{g.From.X, g.From.Y, g.To.X, g.To.Y},
+		"Function":    fn,
+		"Extend":      pdf.Array{true, true},
+	}
+	if g.Radial {
+		sh["ShadingType"] = 3
+		sh["Coords"] = pdf.Array{g.From.X, g.From.Y, g.R0, g.To.X, g.To.Y, g.R1}
+	}
+	return sh
+}
+
+// helveticaCapHeight is used for text without a font; CAD text heights
+// measure capital letters.
+const helveticaCapHeight = 0.718
+
+func text(doc *pdf.Document, page *pdf.Page, t *render.Text) {
+	var font *pdf.Font
+	capHeight := helveticaCapHeight
+	if t.Font != nil {
+		font, capHeight = doc.EmbedFont(t.Font), t.Font.CapHeight
+	} else {
+		font = doc.StandardFont("Helvetica")
+	}
+	m := t.Matrix
+	if t.Invisible {
+		// The text render mode outlasts the text object.
+		page.Save()
+		defer page.Restore()
+	}
+	page.BeginText()
+	if t.Invisible {
+		page.SetTextRender(3)
+	}
+	page.SetFont(page.Font(font), 1/capHeight)
+	page.SetTextMatrix(m[0], m[4], m[1], m[5], m[3], m[7])
+	page.ShowText(font.Encode(t.Value))
+	page.EndText()
+}
+
+func path(c *pdf.Content, p *render.Path) {
+	pts := p.Pts
+	for _, op := range p.Ops {
+		switch op {
+		case render.MoveTo:
+			c.MoveTo(pts[0].X, pts[0].Y)
+			pts = pts[1:]
+		case render.LineTo:
+			c.LineTo(pts[0].X, pts[0].Y)
+			pts = pts[1:]
+		case render.CubicTo:
+			c.CurveTo(pts[0].X, pts[0].Y, pts[1].X, pts[1].Y, pts[2].X, pts[2].Y)
+			pts = pts[3:]
+		case render.Close:
+			c.ClosePath()
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/domain"
+	"github.com/you-humble/dwgtopdf/converter/internal/raster"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+
+	"github.com/you-humble/dwgtopdf/core/libs/plot"
+)
+
+const (
+	// defaultDPI is the resolution of images when a request gives
+	// neither a resolution nor a size.
+	defaultDPI = 150
+	// maxImagePixels bounds the memory an image takes while it is drawn;
+	// larger images are drawn at a lower resolution.
+	maxImagePixels = 40_000_000
+	jpegQuality    = 90
+	// thumbnailSize is the side in pixels of the square thumbnails are
+	// fitted in, and thumbnailEntities how many entities they show at
+	// most, which keeps them quick to draw.
+	thumbnailSize     = 256
+	thumbnailEntities = 1 << 16
+)
+
+// rasterOptions are the parts of a request that concern raster images.
+type rasterOptions struct {
+	format     plot.Output
+	dpi        float64
+	size       plot.ImageSize
+	background color.NRGBA
+	antialias  bool
+}
+
+func newRasterOptions(output plot.Output, o domain.ConvertOptions) (rasterOptions, error) {
+	opts := rasterOptions{
+		format:     output,
+		dpi:        defaultDPI,
+		background: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		antialias:  !o.NoAntialias,
+	}
+	if o.DPI != "" {
+		v, err := plot.ParseDPI(o.DPI)
+		if err != nil {
+			return opts, err
+		}
+		opts.dpi = v
+	}
+	if o.ImageSize != "" {
+		v, err := plot.ParseImageSize(o.ImageSize)
+		if err != nil {
+			return opts, err
+		}
+		opts.size = v
+	}
+	if o.Background != "" {
+		v, err := plot.ParseBackground(o.Background)
+		if err != nil {
+			return opts, err
+		}
+		opts.background = color.NRGBA{R: v.R, G: v.G, B: v.B, A: 0xFF}
+		// JPEG images have no transparency; they stay white.
+		if v.Transparent {
+			opts.background = color.NRGBA{}
+			if output == plot.OutputJPEG {
+				opts.background = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+			}
+		}
+	}
+	return opts, nil
+}
+
+// writeRaster draws each sheet as a PNG or JPEG image, named after its
+// layout, at the requested resolution or fitted into the requested size.
+// It warns about images too large to draw at that resolution.
+func writeRaster(sheets []*render.Sheet, opts rasterOptions) ([]outputFile, []string) {
+	ext := ".png"
+	if opts.format == plot.OutputJPEG {
+		ext = ".jpg"
+	}
+	names := fileNames(sheets, ext)
+	files := make([]outputFile, len(sheets))
+	var warnings []string
+	for i, s := range sheets {
+		scale := opts.scale(s)
+		w, h := pixels(s.Width, scale), pixels(s.Height, scale)
+		if w*h > maxImagePixels || w > plot.MaxImageSide || h > plot.MaxImageSide {
+			scale *= min(math.Sqrt(maxImagePixels/float64(w*h)), plot.MaxImageSide/float64(max(w, h)))
+			w, h = pixels(s.Width, scale), pixels(s.Height, scale)
+			warnings = append(warnings, fmt.Sprintf("layout %s is drawn at %.0f dpi, as it is too large for more", s.Name, scale*72))
+		}
+		img := raster.Draw(s, raster.Options{Width: w, Height: h, Background: opts.background, Antialias: opts.antialias})
+
+		var buf bytes.Buffer
+		// Encoding to memory cannot fail.
+		if opts.format == plot.OutputJPEG {
+			_ = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+			files[i] = outputFile{name: names[i], data: jfifDensity(buf.Bytes(), scale*72)}
+		} else {
+			_ = png.Encode(&buf, img)
+			files[i] = outputFile{name: names[i], data: pngDensity(buf.Bytes(), scale*72)}
+		}
+	}
+	return files, warnings
+}
+
+// writeThumbnail draws a sheet as a small PNG image for previews.
+func writeThumbnail(s *render.Sheet) []byte {
+	files, _ := writeRaster([]*render.Sheet{s}, rasterOptions{
+		format:     plot.OutputPNG,
+		size:       plot.ImageSize{Width: thumbnailSize, Height: thumbnailSize},
+		background: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		antialias:  true,
+	})
+	return files[0].data
+}
+
+// scale returns the pixels per point of the image of a sheet: those of
+// the resolution, or the most that fit the sheet in the size.
+func (o rasterOptions) scale(s *render.Sheet) float64 {
+	if o.size.Width == 0 && o.size.Height == 0 {
+		return o.dpi / 72
+	}
+	scale := math.Inf(1)
+	if o.size.Width > 0 {
+		scale = float64(o.size.Width) / s.Width
+	}
+	if o.size.Height > 0 {
+		scale = min(scale, float64(o.size.Height)/s.Height)
+	}
+	return scale
+}
+
+func pixels(points, scale float64) int {
+	return max(int(math.Round(points*scale)), 1)
+}
+
+// pngDensity adds a pHYs chunk with the resolution after the header of a
+// PNG file, which the encoder leaves out.
+func pngDensity(data []byte, dpi float64) []byte {
+	const headerEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < headerEnd {
+		return data
+	}
+	perMetre := uint32(math.Round(dpi / 0.0254))
+	chunk := binary.BigEndian.AppendUint32(nil, 9)
+	chunk = append(chunk, "pHYs"...)
+	chunk = binary.BigEndian.AppendUint32(chunk, perMetre)
+	chunk = binary.BigEndian.AppendUint32(chunk, perMetre)
+	chunk = append(chunk, 1) // unit: metre
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:headerEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[headerEnd:]...)
+}
+
+// jfifDensity adds a JFIF segment with the resolution after the start of
+// a JPEG file, which the encoder leaves out.
+func jfifDensity(data []byte, dpi float64) []byte {
+	if len(data) < 2 {
+		return data
+	}
+	density := uint16(min(math.Round(dpi), math.MaxUint16))
+	seg := []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 1}
+	seg = binary.BigEndian.AppendUint16(seg, density)
+	seg = binary.BigEndian.AppendUint16(seg, density)
+	seg = append(seg, 0, 0)
+	out := make([]byte, 0, len(data)+len(seg))
+	out = append(out, data[:2]...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
@@ -0,0 +1,414 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/bitmap"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// svgOptions are the parts of a request that concern SVG images.
+type svgOptions struct {
+	// links makes entities with hyperlinks clickable.
+	links bool
+}
+
+// writeSVG draws each sheet as an SVG image, named after its layout. The
+// items of each layer are grouped under the name of the layer, and text
+// stays text. Links to a layout or named view of the drawing go to the
+// image of the layout, zoomed to the view.
+func writeSVG(sheets []*render.Sheet, meta metadata, opts svgOptions) []outputFile {
+	names := fileNames(sheets, ".svg")
+	views := make(map[string]string)
+	for i, s := range sheets {
+		for j, v := range s.Views {
+			if _, ok := views[strings.ToUpper(v.Name)]; !ok {
+				views[strings.ToUpper(v.Name)] = names[i] + "#" + viewID(j)
+			}
+		}
+	}
+	for i, s := range sheets {
+		if _, ok := views[strings.ToUpper(s.Name)]; !ok {
+			views[strings.ToUpper(s.Name)] = names[i]
+		}
+	}
+
+	files := make([]outputFile, len(sheets))
+	for i, s := range sheets {
+		w := &svgWriter{sheet: s, images: make(map[svgImageKey]string)}
+		w.write(meta)
+		if opts.links {
+			w.links(meta.summary.HyperlinkBase, names[i], views)
+		}
+		w.b.WriteString("</g>\n</svg>\n")
+		files[i] = outputFile{name: names[i], data: w.b.Bytes()}
+	}
+	return files
+}
+
+func viewID(i int) string { return "view" + strconv.Itoa(i+1) }
+
+type svgWriter struct {
+	b      bytes.Buffer
+	sheet  *render.Sheet
+	ids    int
+	images map[svgImageKey]string
+}
+
+// svgImageKey tells apart the images written for a bitmap, which differ
+// in the color of bilevel images and in how transparent pixels are drawn.
+type svgImageKey struct {
+	bitmap      *bitmap.Image
+	color       render.RGB
+	transparent bool
+}
+
+func (w *svgWriter) id(prefix string) string {
+	w.ids++
+	return prefix + strconv.Itoa(w.ids)
+}
+
+// write opens the image and draws the items of the sheet. SVG puts the
+// origin at the top left, so a group flips the sheet, whose origin is at
+// the lower left; the group is left open for the links.
+func (w *svgWriter) write(meta metadata) {
+	s := w.sheet
+	b := &w.b
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" width="%spt" height="%spt" viewBox="0 0 %s %s">`+"\n",
+		svgNum(s.Width), svgNum(s.Height), svgNum(s.Width), svgNum(s.Height))
+	fmt.Fprintf(b, "<title>%s</title>\n", escape(meta.title()+" - "+s.Name))
+	b.WriteString("<metadata>\n")
+	b.Write(meta.rdf(svgProducer))
+	b.WriteString("</metadata>\n")
+	for i, v := range s.Views {
+		fmt.Fprintf(b, `<view id="%s" viewBox="%s %s %s %s"><title>%s</title></view>`+"\n", viewID(i),
+			svgNum(v.Area.Min.X), svgNum(s.Height-v.Area.Max.Y), svgNum(v.Area.Width()), svgNum(v.Area.Height()), escape(v.Name))
+	}
+	fmt.Fprintf(b, `<g transform="matrix(1 0 0 -1 0 %s)" stroke-linecap="round" stroke-linejoin="round">`+"\n", svgNum(s.Height))
+
+	layers := make(map[string]render.Layer)
+	for _, l := range s.Layers {
+		layers[strings.ToUpper(l.Name)] = l
+	}
+	var clip *render.Path
+	// layer is the layer of the open layer group, if any. Layer groups are
+	// closed around clipping, so that they nest in the clip groups.
+	layer, open := "", false
+	for i := range s.Items {
+		it := &s.Items[i]
+		if open && (it.Layer != layer || it.Clip != clip) {
+			b.WriteString("</g>\n")
+			open = false
+		}
+		if it.Clip != clip {
+			if clip != nil {
+				b.WriteString("</g>\n")
+			}
+			if clip = it.Clip; clip != nil {
+				fmt.Fprintf(b, "<g clip-path=\"url(#%s)\">\n", w.clipPath(clip, false))
+			}
+		}
+		if !open {
+			layer, open = it.Layer, true
+			l, ok := layers[strings.ToUpper(it.Layer)]
+			if !ok {
+				l = render.Layer{Name: it.Layer}
+			}
+			fmt.Fprintf(b, `<g class="layer" data-layer="%s"`, escape(l.Name))
+			if l.Off {
+				b.WriteString(` display="none"`)
+			}
+			b.WriteString(">\n")
+		}
+		w.item(it)
+	}
+	if open {
+		b.WriteString("</g>\n")
+	}
+	if clip != nil {
+		b.WriteString("</g>\n")
+	}
+}
+
+// svgProducer names the program in the metadata of SVG images, as the
+// Producer entry does in PDFs.
+const svgProducer = "dwgtopdf"
+
+func (w *svgWriter) item(it *render.Item) {
+	b := &w.b
+	switch {
+	case it.Text != nil:
+		w.text(it.Text, it.Color)
+	case it.Gradient != nil:
+		id := w.gradient(it.Gradient)
+		fmt.Fprintf(b, `<path d="%s" fill="url(#%s)"%s/>`+"\n", svgPath(&it.Path), id, fillRule(it.EvenOdd))
+	case it.Image != nil:
+		w.image(it)
+	case it.Fill:
+		fmt.Fprintf(b, `<path d="%s" fill="%s"%s/>`+"\n", svgPath(&it.Path), svgColor(it.Color), fillRule(it.EvenOdd))
+	case it.Width > 0:
+		fmt.Fprintf(b, `<path d="%s" fill="none" stroke="%s" stroke-width="%s"/>`+"\n",
+			svgPath(&it.Path), svgColor(it.Color), svgNum(it.Width))
+	default:
+		// Lines of no width are as thin as the device can draw them.
+		fmt.Fprintf(b, `<path d="%s" fill="none" stroke="%s" stroke-width="1" vector-effect="non-scaling-stroke"/>`+"\n",
+			svgPath(&it.Path), svgColor(it.Color))
+	}
+}
+
+// text writes a line of text in the family of its font, stretched to the
+// width the font gives it in case the viewer substitutes another font.
+// The text is flipped back upright within the flipped sheet.
+func (w *svgWriter) text(t *render.Text, c render.RGB) {
+	capHeight, family := helveticaCapHeight, "Helvetica, Arial, sans-serif"
+	if t.Font != nil {
+		capHeight = t.Font.CapHeight
+		if t.Font.Family != "" {
+			family = "'" + strings.ReplaceAll(t.Font.Family, "'", "") + "', sans-serif"
+		}
+	}
+	m := t.Matrix
+	fmt.Fprintf(&w.b, `<text transform="matrix(%s %s %s %s %s %s) scale(1 -1)" font-family="%s" font-size="%s" fill="%s"`,
+		svgNum(m[0]), svgNum(m[4]), svgNum(m[1]), svgNum(m[5]), svgNum(m[3]), svgNum(m[7]),
+		escape(family), svgNum(1/capHeight), svgColor(c))
+	if t.Width > 0 {
+		fmt.Fprintf(&w.b, ` textLength="%s" lengthAdjust="spacingAndGlyphs"`, svgNum(t.Width))
+	}
+	if t.Invisible {
+		w.b.WriteString(` fill-opacity="0"`)
+	}
+	fmt.Fprintf(&w.b, ` xml:space="preserve">%s</text>`+"\n", escape(t.Value))
+}
+
+// gradient defines a gradient in sheet coordinates and returns its id.
+func (w *svgWriter) gradient(g *render.Gradient) string {
+	id := w.id("g")
+	b := &w.b
+	if g.Radial {
+		fmt.Fprintf(b, `<radialGradient id="%s" gradientUnits="userSpaceOnUse" fx="%s" fy="%s" fr="%s" cx="%s" cy="%s" r="%s">`,
+			id, svgNum(g.From.X), svgNum(g.From.Y), svgNum(g.R0), svgNum(g.To.X), svgNum(g.To.Y), svgNum(g.R1))
+	} else {
+		fmt.Fprintf(b, `<linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`,
+			id, svgNum(g.From.X), svgNum(g.From.Y), svgNum(g.To.X), svgNum(g.To.Y))
+	}
+	for _, s := range g.Stops {
+		fmt.Fprintf(b, `<stop offset="%s" stop-color="%s"/>`, svgNum(s.Offset), svgColor(s.Color))
+	}
+	if g.Radial {
+		b.WriteString("</radialGradient>\n")
+	} else {
+		b.WriteString("</linearGradient>\n")
+	}
+	return id
+}
+
+// clipPath defines a clipping path and returns its id.
+func (w *svgWriter) clipPath(p *render.Path, evenOdd bool) string {
+	id := w.id("c")
+	rule := ""
+	if evenOdd {
+		rule = ` clip-rule="evenodd"`
+	}
+	fmt.Fprintf(&w.b, `<clipPath id="%s"><path d="%s"%s/></clipPath>`+"\n", id, svgPath(p), rule)
+	return id
+}
+
+// image draws an image clipped to the path of its item. Each image is
+// written once per file and used again where the drawing repeats it.
+// Bilevel images are drawn in the item color, over white unless they are
+// transparent.
+func (w *svgWriter) image(it *render.Item) {
+	img := it.Image
+	b := &w.b
+	fmt.Fprintf(b, "<g clip-path=\"url(#%s)\">\n", w.clipPath(&it.Path, it.EvenOdd))
+	if img.Bitmap.Bilevel && !img.Transparent {
+		fmt.Fprintf(b, `<path d="%s" fill="#ffffff"/>`+"\n", svgPath(&it.Path))
+	}
+	m := img.Matrix
+	// The unit square is flipped, as image rows run down.
+	fmt.Fprintf(b, `<g transform="matrix(%s %s %s %s %s %s) matrix(1 0 0 -1 0 1)">`,
+		svgNum(m[0]), svgNum(m[4]), svgNum(m[1]), svgNum(m[5]), svgNum(m[3]), svgNum(m[7]))
+	key := svgImageKey{bitmap: img.Bitmap, transparent: img.Transparent}
+	if img.Bitmap.Bilevel {
+		key.color = it.Color
+	}
+	if id, ok := w.images[key]; ok {
+		fmt.Fprintf(b, `<use xlink:href="#%s"/>`, id)
+	} else {
+		id := w.id("i")
+		w.images[key] = id
+		fmt.Fprintf(b, `<image id="%s" width="1" height="1" preserveAspectRatio="none" xlink:href="%s"/>`,
+			id, imageURI(img.Bitmap, it.Color, img.Transparent))
+	}
+	b.WriteString("</g>\n</g>\n")
+}
+
+// imageURI encodes an image as a data URI. JPEG files are kept as they
+// are; other images are written as PNG, with transparent pixels blended
+// onto white unless transparent is set. Bilevel images become c on a
+// background that is transparent: the item path is painted white under
+// them when it should not be.
+func imageURI(bm *bitmap.Image, c render.RGB, transparent bool) string {
+	if bm.JPEG != nil && !bm.Bilevel {
+		return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(bm.JPEG)
+	}
+	b := bm.Pixels.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var px color.NRGBA
+			if bm.Bilevel {
+				if color.GrayModel.Convert(bm.Pixels.At(x, y)).(color.Gray).Y < 0x80 {
+					px = color.NRGBA{c.R, c.G, c.B, 0xFF}
+				}
+			} else {
+				px = color.NRGBAModel.Convert(bm.Pixels.At(x, y)).(color.NRGBA)
+				if !transparent && px.A < 0xFF {
+					over := func(v uint8) uint8 { return uint8((int(v)*int(px.A) + 255*(255-int(px.A)) + 127) / 255) }
+					px = color.NRGBA{over(px.R), over(px.G), over(px.B), 0xFF}
+				}
+			}
+			out.SetNRGBA(x-b.Min.X, y-b.Min.Y, px)
+		}
+	}
+	var buf bytes.Buffer
+	// Encoding to memory cannot fail.
+	_ = png.Encode(&buf, out)
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// links lays a link over each entity with a hyperlink. Relative URLs
+// resolve against the hyperlink base; links within the drawing go to the
+// image of the layout, relative to the image of this one.
+func (w *svgWriter) links(base, name string, dests map[string]string) {
+	b := &w.b
+	for _, l := range sortedLinks(w.sheet.Links) {
+		h := l.Hyperlink
+		var href string
+		switch {
+		case h.URL != "":
+			href = resolveURL(base, h.URL)
+			if h.SubLocation != "" && !strings.Contains(href, "#") {
+				href += "#" + h.SubLocation
+			}
+		default:
+			dest, ok := dests[strings.ToUpper(strings.TrimSpace(h.SubLocation))]
+			if !ok {
+				continue
+			}
+			file, frag, _ := strings.Cut(dest, "#")
+			switch {
+			case file != name:
+				href = url.PathEscape(file)
+				if frag != "" {
+					href += "#" + frag
+				}
+			case frag != "":
+				href = "#" + frag
+			default:
+				// The layout of this image shows all of it.
+				continue
+			}
+		}
+		r := linkArea(l.Area)
+		fmt.Fprintf(b, `<a xlink:href="%s">`, escape(href))
+		if h.Description != "" {
+			fmt.Fprintf(b, "<title>%s</title>", escape(h.Description))
+		}
+		fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#ffffff" fill-opacity="0"/></a>`+"\n",
+			svgNum(r.Min.X), svgNum(r.Min.Y), svgNum(r.Width()), svgNum(r.Height()))
+	}
+}
+
+// resolveURL resolves a relative URL against the hyperlink base of the
+// drawing, leaving it as it is when either does not parse.
+func resolveURL(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil || r.IsAbs() {
+		return ref
+	}
+	if b.Scheme == "" {
+		// A folder rather than a URL.
+		return strings.TrimRight(base, `/\`) + "/" + ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+func svgPath(p *render.Path) string {
+	var b strings.Builder
+	pts := p.Pts
+	pt := func(v geom.Vec2) {
+		b.WriteString(svgNum(v.X))
+		b.WriteByte(' ')
+		b.WriteString(svgNum(v.Y))
+	}
+	for _, op := range p.Ops {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		switch op {
+		case render.MoveTo:
+			b.WriteString("M")
+			pt(pts[0])
+			pts = pts[1:]
+		case render.LineTo:
+			b.WriteString("L")
+			pt(pts[0])
+			pts = pts[1:]
+		case render.CubicTo:
+			b.WriteString("C")
+			pt(pts[0])
+			b.WriteByte(' ')
+			pt(pts[1])
+			b.WriteByte(' ')
+			pt(pts[2])
+			pts = pts[3:]
+		case render.Close:
+			b.WriteString("Z")
+		}
+	}
+	return b.String()
+}
+
+func fillRule(evenOdd bool) string {
+	if evenOdd {
+		return ` fill-rule="evenodd"`
+	}
+	return ""
+}
+
+func svgColor(c render.RGB) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgNum formats a number with at most three decimals, enough for
+// coordinates in points.
+func svgNum(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
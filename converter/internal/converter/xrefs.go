@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+
+	"github.com/you-humble/dwgtopdf/core/libs/bundle"
+)
+
+// xrefLoader binds the drawings external references point to into the
+// drawings that reference them, finding them in the bundle the drawing
+// was uploaded in.
+type xrefLoader struct {
+	bundle *bundle.Bundle
+	// chain holds the paths of the drawings being bound, which a
+	// reference back to one of them would never finish.
+	chain []string
+	// loaded keeps the drawings read by bundle path, so that a drawing
+	// several others reference is read and bound once.
+	loaded map[string]*drawing.Drawing
+	// unresolved lists the references no drawing could be bound for.
+	unresolved []string
+	warnings   []string
+}
+
+// newXrefLoader looks references up in b, which is nil for a drawing
+// uploaded on its own.
+func newXrefLoader(b *bundle.Bundle) *xrefLoader {
+	return &xrefLoader{bundle: b, loaded: make(map[string]*drawing.Drawing)}
+}
+
+// bind binds the references of d, the drawing at path from. Overlays are
+// only bound for the drawing converted, not for the references nested in
+// it, as AutoCAD does.
+func (l *xrefLoader) bind(d *drawing.Drawing, from string) {
+	l.chain = append(l.chain, from)
+	defer func() { l.chain = l.chain[:len(l.chain)-1] }()
+
+	for _, b := range slices.Clone(d.Blocks) {
+		if !b.Xref || b.XrefOverlay && len(l.chain) > 1 {
+			continue
+		}
+		var name string
+		if l.bundle != nil {
+			name = l.bundle.Find(from, b.XrefPath)
+		}
+		if name == "" || !bundle.IsDrawing(name) {
+			l.unresolve(b.XrefPath)
+			continue
+		}
+		if slices.Contains(l.chain, name) {
+			l.warnings = append(l.warnings, fmt.Sprintf("xref %s refers back to a drawing that references it", b.XrefPath))
+			continue
+		}
+		x, err := l.load(name)
+		if err != nil {
+			l.unresolve(b.XrefPath)
+			l.warnings = append(l.warnings, fmt.Sprintf("xref %s: %v", b.XrefPath, err))
+			continue
+		}
+		for _, w := range x.Warnings {
+			l.warnings = append(l.warnings, fmt.Sprintf("xref %s: %s", b.XrefPath, w))
+		}
+		d.Bind(b, x)
+	}
+}
+
+// load reads the drawing of a reference and binds its own references.
+// The paths of its images are made relative to the bundle, since they
+// start from the reference rather than from the drawing converted.
+func (l *xrefLoader) load(name string) (*drawing.Drawing, error) {
+	if x, ok := l.loaded[name]; ok {
+		return x, nil
+	}
+	data, err := l.bundle.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	x, err := parse(bytes.NewReader(data), formatOf(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range x.ImageDefs {
+		if file := l.bundle.Find(name, def.File); file != "" {
+			def.File = file
+		}
+	}
+	l.bind(x, name)
+	l.loaded[name] = x
+	return x, nil
+}
+
+func (l *xrefLoader) unresolve(ref string) {
+	if !slices.Contains(l.unresolved, ref) {
+		l.unresolved = append(l.unresolved, ref)
+	}
+}
@@ -0,0 +1,77 @@
+package domain
+
+type InputFormat string
+
+const (
+	FormatDWG InputFormat = "dwg"
+	FormatDXF InputFormat = "dxf"
+	// FormatBundle is a ZIP archive of a drawing and the files it
+	// references.
+	FormatBundle InputFormat = "zip"
+)
+
+type ConvertParams struct {
+	InputPath     string
+	SuggestedName string
+	InputFormat   InputFormat
+	Options       ConvertOptions
+	// PlotStylePath is a plot style table uploaded with the drawing,
+	// which takes precedence over Options.PlotStyle.
+	PlotStylePath string
+	// TaskID is the task the conversion is for, recorded in the PDF
+	// metadata.
+	TaskID string
+}
+
+// ThumbnailParams name a drawing to draw a preview of, for drawings
+// without a preview image of their own.
+type ThumbnailParams struct {
+	InputPath     string
+	SuggestedName string
+	InputFormat   InputFormat
+}
+
+// ConvertOptions override the page setups of the drawing; empty fields
+// keep them.
+type ConvertOptions struct {
+	PaperSize   string
+	Orientation string
+	Scale       string
+	// PlotStyle names an installed plot style table.
+	PlotStyle string
+	// Lineweights is plot or display.
+	Lineweights string
+	// IncludeLayers and ExcludeLayers are layer names and glob patterns.
+	IncludeLayers []string
+	ExcludeLayers []string
+	// PDFLayers makes each layer an optional content group of the PDF.
+	PDFLayers bool
+	// SHXText is font, strokes or overlay.
+	SHXText string
+	// NoHyperlinks leaves out the link annotations of entity hyperlinks.
+	NoHyperlinks bool
+	// OutputFormat is pdf, pdfa, svg, png, jpeg or dxf; empty means pdf.
+	OutputFormat string
+	// DPI, ImageSize, Background and NoAntialias concern png and jpeg
+	// images.
+	DPI         string
+	ImageSize   string
+	Background  string
+	NoAntialias bool
+	// DXFVersion is the version of dxf files: r12, 2000 or 2018; empty
+	// means 2018.
+	DXFVersion string
+}
+
+type ConvertResult struct {
+	// ResultName is the file the drawing was converted to.
+	ResultName string
+	// Layouts names the layout on each page of the PDF.
+	Layouts []string
+	// Warnings describe what could not be drawn, such as images missing
+	// from the upload.
+	Warnings []string
+	// UnresolvedXrefs are the paths of the external references that could
+	// not be loaded from the upload.
+	UnresolvedXrefs []string
+}
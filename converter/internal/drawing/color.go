@@ -0,0 +1,118 @@
+package drawing
+
+import "math"
+
+const (
+	ColorByBlock = 0
+	ColorByLayer = 256
+)
+
+// Color is an entity or layer color as stored in the drawing. Index is the
+// AutoCAD Color Index; when True is set RGB holds a 24-bit true color that
+// takes precedence over the index.
+type Color struct {
+	Index int16
+	RGB   uint32
+	True  bool
+	Name  string
+	Book  string
+}
+
+func (c Color) IsByLayer() bool { return !c.True && c.Index == ColorByLayer }
+func (c Color) IsByBlock() bool { return !c.True && c.Index == ColorByBlock }
+
+// Lineweight is stored in hundredths of a millimetre. Negative values are
+// the special ByLayer, ByBlock and Default weights.
+type Lineweight int16
+
+const (
+	LineweightByLayer Lineweight = -1
+	LineweightByBlock Lineweight = -2
+	LineweightDefault Lineweight = -3
+)
+
+var lineweights = [...]Lineweight{
+	0, 5, 9, 13, 15, 18, 20, 25, 30, 35, 40, 50, 53, 60, 70, 80, 90, 100, 106, 120, 140, 158, 200, 211,
+}
+
+// LineweightFromIndex decodes the 5-bit lineweight index used by DWG files.
+func LineweightFromIndex(i int) Lineweight {
+	switch {
+	case i >= 0 && i < len(lineweights):
+		return lineweights[i]
+	case i == 29:
+		return LineweightByLayer
+	case i == 30:
+		return LineweightByBlock
+	default:
+		return LineweightDefault
+	}
+}
+
+var aci [256]uint32
+
+func init() {
+	base := [...]uint32{0x000000, 0xFF0000, 0xFFFF00, 0x00FF00, 0x00FFFF, 0x0000FF, 0xFF00FF, 0xFFFFFF, 0x808080, 0xC0C0C0}
+	copy(aci[:], base[:])
+	// Indices 10-249 step the hue by 15 degrees every ten entries; within
+	// a group the value falls off in pairs of full and half saturation.
+	values := [...]float64{1, 0.8, 0.6, 0.5, 0.3}
+	for i := 10; i < 250; i++ {
+		hue := float64(i/10-1) * 15
+		sat := 1.0
+		if i%2 == 1 {
+			sat = 0.5
+		}
+		aci[i] = hsv(hue, sat, values[(i%10)/2])
+	}
+	for i, g := range [...]uint32{0x33, 0x5B, 0x84, 0xAD, 0xD6, 0xFF} {
+		aci[250+i] = g<<16 | g<<8 | g
+	}
+}
+
+// ACI returns the 24-bit RGB value of an AutoCAD Color Index entry.
+// Index 7 is white here; plotting on paper shows it as black.
+func ACI(index int) uint32 {
+	if index < 0 || index > 255 {
+		return aci[7]
+	}
+	return aci[index]
+}
+
+// NearestACI returns the ACI color closest to a 24-bit RGB value.
+func NearestACI(v uint32) int {
+	best, dist := 1, math.MaxInt
+	for i := 1; i < 256; i++ {
+		c := aci[i]
+		dr := int(v>>16&0xff) - int(c>>16&0xff)
+		dg := int(v>>8&0xff) - int(c>>8&0xff)
+		db := int(v&0xff) - int(c&0xff)
+		if d := dr*dr + dg*dg + db*db; d < dist {
+			best, dist = i, d
+		}
+	}
+	return best
+}
+
+func hsv(h, s, v float64) uint32 {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	ch := func(f float64) uint32 { return uint32(math.Floor((f+m)*255 + 1e-9)) }
+	return ch(r)<<16 | ch(g)<<8 | ch(b)
+}
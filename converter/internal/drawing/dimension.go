@@ -0,0 +1,293 @@
+package drawing
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+const (
+	DimLinear        = 0
+	DimAligned       = 1
+	DimAngular       = 2
+	DimDiameter      = 3
+	DimRadius        = 4
+	DimAngular3Point = 5
+	DimOrdinate      = 6
+	DimArcLength     = 8
+)
+
+const (
+	DimUnique    = 32
+	DimOrdinateX = 64
+	// DimUserText is set when the text was moved away from its default
+	// position, to TextMidPoint.
+	DimUserText = 128
+)
+
+// Dimension is a dimension of any type. Its graphics are normally stored
+// in the anonymous Block; the other fields allow them to be regenerated.
+//
+// The definition points depend on the type:
+//
+//	linear, aligned: DefPoint on the dimension line, DefPoint2 and
+//	    DefPoint3 the origins of the extension lines
+//	angular: DefPoint2-DefPoint3 the first line, DefPoint4-DefPoint the
+//	    second, DefPoint5 on the dimension arc
+//	angular 3-point, arc length: DefPoint on the dimension arc, DefPoint2
+//	    and DefPoint3 the ends, DefPoint4 the vertex or arc center
+//	radius, diameter: DefPoint the center or the far end of the
+//	    diameter, DefPoint4 the point on the curve
+//	ordinate: DefPoint the origin, DefPoint2 the feature, DefPoint3 the
+//	    end of the leader
+//
+// TextMidPoint and DefPoint5 are in the dimension's OCS, the others in
+// WCS. Angles are in radians.
+type Dimension struct {
+	EntityProps
+	Type  int
+	Flags int
+	Block string
+	Style string
+	// Overrides changes variables of Style for this dimension only.
+	Overrides []DimOverride
+
+	DefPoint     geom.Vec3
+	TextMidPoint geom.Vec3
+	DefPoint2    geom.Vec3
+	DefPoint3    geom.Vec3
+	DefPoint4    geom.Vec3
+	DefPoint5    geom.Vec3
+
+	// Text replaces the measurement; "<>" in it stands for the
+	// measurement and a single space suppresses the text.
+	Text          string
+	TextRotation  float64
+	HorizontalDir float64
+	Rotation      float64
+	Oblique       float64
+	LeaderLength  float64
+	Measurement   float64
+	Extrusion     geom.Vec3
+}
+
+// DimOverride sets a dimension variable by its DXF group code.
+type DimOverride struct {
+	Code  int
+	Value string
+}
+
+// DimStyle holds the dimension variables of a DIMSTYLE table entry. The
+// variable each field stands for is noted next to it; lengths are in
+// drawing units before Scale is applied.
+type DimStyle struct {
+	Handle Handle
+	Name   string
+
+	Post          string     // DIMPOST
+	Scale         float64    // DIMSCALE
+	ArrowSize     float64    // DIMASZ
+	ExtOffset     float64    // DIMEXO
+	ExtExtend     float64    // DIMEXE
+	Round         float64    // DIMRND
+	LineExtend    float64    // DIMDLE
+	TolPlus       float64    // DIMTP
+	TolMinus      float64    // DIMTM
+	TextHeight    float64    // DIMTXT
+	CenterMark    float64    // DIMCEN
+	TickSize      float64    // DIMTSZ
+	LinearFactor  float64    // DIMLFAC
+	TextVertPos   float64    // DIMTVP
+	TolScale      float64    // DIMTFAC
+	Gap           float64    // DIMGAP
+	Tol           bool       // DIMTOL
+	Limits        bool       // DIMLIM
+	InsideHoriz   bool       // DIMTIH
+	OutsideHoriz  bool       // DIMTOH
+	SuppressExt1  bool       // DIMSE1
+	SuppressExt2  bool       // DIMSE2
+	TextAbove     int        // DIMTAD
+	ZeroSuppress  int        // DIMZIN
+	AngZeroSupp   int        // DIMAZIN
+	ArcSymbol     int        // DIMARCSYM
+	LineInside    bool       // DIMTOFL
+	SeparateArrow bool       // DIMSAH
+	TextInside    bool       // DIMTIX
+	SuppressOut   bool       // DIMSOXD
+	LineColor     Color      // DIMCLRD
+	ExtColor      Color      // DIMCLRE
+	TextColor     Color      // DIMCLRT
+	AngDecimals   int        // DIMADEC
+	Decimals      int        // DIMDEC
+	TolDecimals   int        // DIMTDEC
+	AngUnits      int        // DIMAUNIT
+	Fraction      int        // DIMFRAC
+	LinearUnits   int        // DIMLUNIT
+	DecimalSep    rune       // DIMDSEP
+	TextMove      int        // DIMTMOVE
+	TextJust      int        // DIMJUST
+	SuppressLine1 bool       // DIMSD1
+	SuppressLine2 bool       // DIMSD2
+	TolJust       int        // DIMTOLJ
+	TolZeroSupp   int        // DIMTZIN
+	Fit           int        // DIMATFIT
+	TextStyle     string     // DIMTXSTY
+	LeaderArrow   string     // DIMLDRBLK
+	Arrow         string     // DIMBLK
+	Arrow1        string     // DIMBLK1
+	Arrow2        string     // DIMBLK2
+	LineWeight    Lineweight // DIMLWD
+	ExtWeight     Lineweight // DIMLWE
+}
+
+// NewDimStyle returns a style with the values AutoCAD assumes for
+// variables a file leaves out, those of the imperial Standard style.
+func NewDimStyle(name string) *DimStyle {
+	return &DimStyle{
+		Name:         name,
+		Scale:        1,
+		ArrowSize:    0.18,
+		ExtOffset:    0.0625,
+		ExtExtend:    0.18,
+		TextHeight:   0.18,
+		CenterMark:   0.09,
+		LinearFactor: 1,
+		TolScale:     1,
+		Gap:          0.09,
+		InsideHoriz:  true,
+		OutsideHoriz: true,
+		LineColor:    Color{Index: ColorByBlock},
+		ExtColor:     Color{Index: ColorByBlock},
+		TextColor:    Color{Index: ColorByBlock},
+		Decimals:     4,
+		TolDecimals:  4,
+		LinearUnits:  2,
+		DecimalSep:   '.',
+		TolJust:      1,
+		Fit:          3,
+		TextStyle:    "Standard",
+		LineWeight:   LineweightByBlock,
+		ExtWeight:    LineweightByBlock,
+	}
+}
+
+// Set assigns the variable with DXF group code code. Variables that refer
+// to text styles or blocks take their names. Unknown codes are ignored.
+func (s *DimStyle) Set(code int, value string) {
+	value = strings.TrimSpace(value)
+	f, _ := strconv.ParseFloat(value, 64)
+	i := int(f)
+	switch code {
+	case 3:
+		s.Post = value
+	case 5, 342:
+		s.Arrow = value
+	case 6, 343:
+		s.Arrow1 = value
+	case 7, 344:
+		s.Arrow2 = value
+	case 340:
+		s.TextStyle = value
+	case 341:
+		s.LeaderArrow = value
+	case 40:
+		s.Scale = f
+	case 41:
+		s.ArrowSize = f
+	case 42:
+		s.ExtOffset = f
+	case 44:
+		s.ExtExtend = f
+	case 45:
+		s.Round = f
+	case 46:
+		s.LineExtend = f
+	case 47:
+		s.TolPlus = f
+	case 48:
+		s.TolMinus = f
+	case 140:
+		s.TextHeight = f
+	case 141:
+		s.CenterMark = f
+	case 142:
+		s.TickSize = f
+	case 144:
+		s.LinearFactor = f
+	case 145:
+		s.TextVertPos = f
+	case 146:
+		s.TolScale = f
+	case 147:
+		s.Gap = f
+	case 71:
+		s.Tol = i != 0
+	case 72:
+		s.Limits = i != 0
+	case 73:
+		s.InsideHoriz = i != 0
+	case 74:
+		s.OutsideHoriz = i != 0
+	case 75:
+		s.SuppressExt1 = i != 0
+	case 76:
+		s.SuppressExt2 = i != 0
+	case 77:
+		s.TextAbove = i
+	case 78:
+		s.ZeroSuppress = i
+	case 79:
+		s.AngZeroSupp = i
+	case 90:
+		s.ArcSymbol = i
+	case 172:
+		s.LineInside = i != 0
+	case 173:
+		s.SeparateArrow = i != 0
+	case 174:
+		s.TextInside = i != 0
+	case 175:
+		s.SuppressOut = i != 0
+	case 176:
+		s.LineColor = Color{Index: int16(i)}
+	case 177:
+		s.ExtColor = Color{Index: int16(i)}
+	case 178:
+		s.TextColor = Color{Index: int16(i)}
+	case 179:
+		s.AngDecimals = i
+	case 271:
+		s.Decimals = i
+	case 272:
+		s.TolDecimals = i
+	case 275:
+		s.AngUnits = i
+	case 276:
+		s.Fraction = i
+	case 277:
+		s.LinearUnits = i
+	case 278:
+		if i > 0 {
+			s.DecimalSep = rune(i)
+		}
+	case 279:
+		s.TextMove = i
+	case 280:
+		s.TextJust = i
+	case 281:
+		s.SuppressLine1 = i != 0
+	case 282:
+		s.SuppressLine2 = i != 0
+	case 283:
+		s.TolJust = i
+	case 284:
+		s.TolZeroSupp = i
+	case 289:
+		s.Fit = i
+	case 371:
+		s.LineWeight = Lineweight(i)
+	case 372:
+		s.ExtWeight = Lineweight(i)
+	}
+}
@@ -0,0 +1,345 @@
+package drawing
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+type Handle uint64
+
+const (
+	ModelSpace = "*Model_Space"
+	PaperSpace = "*Paper_Space"
+)
+
+type Drawing struct {
+	// Version is the AutoCAD release tag, e.g. AC1015 or AC1032.
+	Version string
+	Header  Header
+	Summary Summary
+
+	Layers    []*Layer
+	Linetypes []*Linetype
+	Styles    []*TextStyle
+	DimStyles []*DimStyle
+	Blocks    []*Block
+	Layouts   []*Layout
+	Views     []*View
+	// ImageDefs are the image files IMAGE entities show.
+	ImageDefs []*ImageDef
+	// Warnings describe what the reader had to leave out.
+	Warnings []string
+
+	layers    map[string]*Layer
+	linetypes map[string]*Linetype
+	styles    map[string]*TextStyle
+	dimStyles map[string]*DimStyle
+	blocks    map[string]*Block
+	views     map[string]*View
+	imageDefs map[Handle]*ImageDef
+}
+
+type Header struct {
+	InsBase        geom.Vec3
+	ExtMin, ExtMax geom.Vec3
+	LimMin, LimMax geom.Vec2
+
+	PaperInsBase             geom.Vec3
+	PaperExtMin, PaperExtMax geom.Vec3
+	PaperLimMin, PaperLimMax geom.Vec2
+
+	LTScale   float64
+	CELTScale float64
+	PSLTScale bool
+	TextSize  float64
+	TextStyle string
+	PDMode    int
+	PDSize    float64
+	FillMode  bool
+	LWDisplay bool
+	InsUnits  int
+	// Encoding is that of the code page the drawing was made with, which
+	// SHX fonts number their characters in; nil when it is not known.
+	Encoding encoding.Encoding
+
+	Created time.Time
+	Updated time.Time
+}
+
+// Summary holds the drawing properties AutoCAD keeps apart from the header
+// variables, which users fill in with DWGPROPS.
+type Summary struct {
+	Title         string
+	Subject       string
+	Author        string
+	Keywords      string
+	Comments      string
+	LastSavedBy   string
+	Revision      string
+	HyperlinkBase string
+	// Custom are the custom properties in the order they were added.
+	Custom []Property
+}
+
+type Property struct {
+	Name, Value string
+}
+
+func New() *Drawing {
+	return &Drawing{
+		Header: Header{
+			LTScale:   1,
+			CELTScale: 1,
+			PSLTScale: true,
+			TextSize:  2.5,
+			TextStyle: "Standard",
+			FillMode:  true,
+		},
+		layers:    make(map[string]*Layer),
+		linetypes: make(map[string]*Linetype),
+		styles:    make(map[string]*TextStyle),
+		dimStyles: make(map[string]*DimStyle),
+		blocks:    make(map[string]*Block),
+		views:     make(map[string]*View),
+		imageDefs: make(map[Handle]*ImageDef),
+	}
+}
+
+func key(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func (d *Drawing) AddLayer(l *Layer) {
+	if _, ok := d.layers[key(l.Name)]; ok {
+		return
+	}
+	d.layers[key(l.Name)] = l
+	d.Layers = append(d.Layers, l)
+}
+
+func (d *Drawing) Layer(name string) *Layer {
+	return d.layers[key(name)]
+}
+
+func (d *Drawing) AddLinetype(lt *Linetype) {
+	if _, ok := d.linetypes[key(lt.Name)]; ok {
+		return
+	}
+	d.linetypes[key(lt.Name)] = lt
+	d.Linetypes = append(d.Linetypes, lt)
+}
+
+func (d *Drawing) Linetype(name string) *Linetype {
+	return d.linetypes[key(name)]
+}
+
+func (d *Drawing) AddStyle(s *TextStyle) {
+	if _, ok := d.styles[key(s.Name)]; ok {
+		return
+	}
+	d.styles[key(s.Name)] = s
+	d.Styles = append(d.Styles, s)
+}
+
+func (d *Drawing) Style(name string) *TextStyle {
+	return d.styles[key(name)]
+}
+
+func (d *Drawing) AddDimStyle(s *DimStyle) {
+	if _, ok := d.dimStyles[key(s.Name)]; ok {
+		return
+	}
+	d.dimStyles[key(s.Name)] = s
+	d.DimStyles = append(d.DimStyles, s)
+}
+
+func (d *Drawing) DimStyle(name string) *DimStyle {
+	return d.dimStyles[key(name)]
+}
+
+func (d *Drawing) AddView(v *View) {
+	if _, ok := d.views[key(v.Name)]; ok {
+		return
+	}
+	d.views[key(v.Name)] = v
+	d.Views = append(d.Views, v)
+}
+
+func (d *Drawing) View(name string) *View {
+	return d.views[key(name)]
+}
+
+func (d *Drawing) AddImageDef(def *ImageDef) {
+	if _, ok := d.imageDefs[def.Handle]; ok {
+		return
+	}
+	d.imageDefs[def.Handle] = def
+	d.ImageDefs = append(d.ImageDefs, def)
+}
+
+func (d *Drawing) ImageDef(h Handle) *ImageDef {
+	return d.imageDefs[h]
+}
+
+// AddBlock registers b, replacing an existing block of the same name.
+func (d *Drawing) AddBlock(b *Block) {
+	if old, ok := d.blocks[key(b.Name)]; ok {
+		for i := range d.Blocks {
+			if d.Blocks[i] == old {
+				d.Blocks[i] = b
+			}
+		}
+		d.blocks[key(b.Name)] = b
+		return
+	}
+	d.blocks[key(b.Name)] = b
+	d.Blocks = append(d.Blocks, b)
+}
+
+func (d *Drawing) Block(name string) *Block {
+	return d.blocks[key(name)]
+}
+
+// ModelSpace returns the model space block, creating it when the source
+// file did not define one.
+func (d *Drawing) ModelSpace() *Block {
+	if b := d.Block(ModelSpace); b != nil {
+		return b
+	}
+	b := &Block{Name: ModelSpace}
+	d.AddBlock(b)
+	return b
+}
+
+func (d *Drawing) PaperSpace() *Block {
+	if b := d.Block(PaperSpace); b != nil {
+		return b
+	}
+	b := &Block{Name: PaperSpace}
+	d.AddBlock(b)
+	return b
+}
+
+// EnsureDefaults adds the table entries every drawing is expected to have.
+func (d *Drawing) EnsureDefaults() {
+	if d.Layer("0") == nil {
+		d.AddLayer(&Layer{Name: "0", Color: Color{Index: 7}, Linetype: "Continuous", Lineweight: LineweightDefault, Plot: true})
+	}
+	for _, name := range []string{"ByBlock", "ByLayer", "Continuous"} {
+		if d.Linetype(name) == nil {
+			d.AddLinetype(&Linetype{Name: name})
+		}
+	}
+	if d.Style("Standard") == nil {
+		d.AddStyle(&TextStyle{Name: "Standard", FontFile: "txt", WidthFactor: 1})
+	}
+	if d.DimStyle("Standard") == nil {
+		d.AddDimStyle(NewDimStyle("Standard"))
+	}
+	d.ModelSpace()
+	d.ensureLayouts()
+}
+
+type Layer struct {
+	Handle     Handle
+	Name       string
+	Color      Color
+	Linetype   string
+	Lineweight Lineweight
+	PlotStyle  string
+	Off        bool
+	Frozen     bool
+	Locked     bool
+	Plot       bool
+}
+
+// ImageDef is an image file as the drawing references it, by the path it
+// had where the drawing was made. Size is in pixels.
+type ImageDef struct {
+	Handle Handle
+	File   string
+	Size   geom.Vec2
+}
+
+type Linetype struct {
+	Handle      Handle
+	Name        string
+	Description string
+	Length      float64
+	Elements    []LinetypeElement
+}
+
+// LinetypeElement is one dash of a linetype pattern. Positive lengths are
+// dashes, negative are gaps and zero is a dot. Complex elements carry a
+// shape number or text drawn with Style; shapes come from ShapeFile, the
+// font file of the unnamed style that loads it.
+type LinetypeElement struct {
+	Length    float64
+	Shape     int
+	Text      string
+	Style     string
+	ShapeFile string
+	Scale     float64
+	Rotation  float64
+	Offset    geom.Vec2
+	// Absolute rotation instead of relative to the line direction.
+	Absolute bool
+}
+
+func (lt *Linetype) IsContinuous() bool {
+	return lt == nil || len(lt.Elements) == 0 || lt.Length <= 0
+}
+
+type TextStyle struct {
+	Handle      Handle
+	Name        string
+	FontFile    string
+	BigFontFile string
+	// FontFamily is the TrueType family name stored for styles that
+	// reference a system font rather than a file.
+	FontFamily  string
+	Height      float64
+	WidthFactor float64
+	Oblique     float64
+	Vertical    bool
+	Backward    bool
+	UpsideDown  bool
+	ShapeFile   bool
+}
+
+// View is a named view. Center, Width and Height give the area seen in
+// the view's display coordinate system, which looks at Target along
+// Direction, turned by Twist radians.
+type View struct {
+	Handle     Handle
+	Name       string
+	Center     geom.Vec2
+	Width      float64
+	Height     float64
+	Target     geom.Vec3
+	Direction  geom.Vec3
+	Twist      float64
+	PaperSpace bool
+}
+
+type Block struct {
+	Handle      Handle
+	Name        string
+	Base        geom.Vec3
+	Description string
+	Anonymous   bool
+	HasAttDefs  bool
+	Xref        bool
+	XrefOverlay bool
+	XrefPath    string
+	Entities    []Entity
+}
+
+func (b *Block) IsLayout() bool {
+	n := key(b.Name)
+	return n == key(ModelSpace) || strings.HasPrefix(n, key(PaperSpace))
+}
@@ -0,0 +1,460 @@
+package drawing
+
+import "github.com/you-humble/dwgtopdf/converter/internal/geom"
+
+type Entity interface {
+	Props() *EntityProps
+}
+
+// EntityProps holds the properties shared by all graphical entities.
+type EntityProps struct {
+	Handle        Handle
+	Layer         string
+	Linetype      string
+	LinetypeScale float64
+	Color         Color
+	Lineweight    Lineweight
+	// PlotStyle names a style of a named plot style table, or is ByBlock;
+	// empty is ByLayer.
+	PlotStyle  string
+	Invisible  bool
+	PaperSpace bool
+	// Hyperlink is the link attached to the entity, if any.
+	Hyperlink *Hyperlink
+}
+
+func (p *EntityProps) Props() *EntityProps { return p }
+
+// Hyperlink is a link attached to an entity, which AutoCAD keeps in the
+// PE_URL extended data.
+type Hyperlink struct {
+	URL         string
+	Description string
+	// SubLocation is a named view or layout in the drawing linked to, or
+	// an anchor in a web page.
+	SubLocation string
+}
+
+// DefaultProps returns the property values an entity has when the source
+// file does not override them.
+func DefaultProps() EntityProps {
+	return EntityProps{
+		Layer:         "0",
+		Linetype:      "ByLayer",
+		LinetypeScale: 1,
+		Color:         Color{Index: ColorByLayer},
+		Lineweight:    LineweightByLayer,
+	}
+}
+
+type Line struct {
+	EntityProps
+	Start, End geom.Vec3
+	Thickness  float64
+	Extrusion  geom.Vec3
+}
+
+// Arc angles are in radians, counter-clockwise in the arc's OCS.
+type Arc struct {
+	EntityProps
+	Center     geom.Vec3
+	Radius     float64
+	StartAngle float64
+	EndAngle   float64
+	Thickness  float64
+	Extrusion  geom.Vec3
+}
+
+type Circle struct {
+	EntityProps
+	Center    geom.Vec3
+	Radius    float64
+	Thickness float64
+	Extrusion geom.Vec3
+}
+
+// Ellipse is defined in WCS; MajorAxis is relative to Center.
+type Ellipse struct {
+	EntityProps
+	Center     geom.Vec3
+	MajorAxis  geom.Vec3
+	Ratio      float64
+	StartParam float64
+	EndParam   float64
+	Extrusion  geom.Vec3
+}
+
+type Point struct {
+	EntityProps
+	Position  geom.Vec3
+	Thickness float64
+	XAngle    float64
+	Extrusion geom.Vec3
+}
+
+type Vertex struct {
+	Position   geom.Vec3
+	StartWidth float64
+	EndWidth   float64
+	Bulge      float64
+	Flags      int
+}
+
+// LWPolyline vertices are 2D points in the polyline's OCS at Elevation.
+type LWPolyline struct {
+	EntityProps
+	Vertices   []Vertex
+	Closed     bool
+	Plinegen   bool
+	ConstWidth float64
+	Elevation  float64
+	Thickness  float64
+	Extrusion  geom.Vec3
+}
+
+const (
+	PolylineClosed      = 1
+	PolylineCurveFit    = 2
+	PolylineSplineFit   = 4
+	Polyline3D          = 8
+	PolylineMesh        = 16
+	PolylineMeshClosedN = 32
+	PolylinePolyface    = 64
+	PolylinePlinegen    = 128
+)
+
+const (
+	VertexCurveFit     = 1
+	VertexSplineFrame  = 16
+	VertexSplineFitted = 8
+	Vertex3D           = 32
+	VertexMesh         = 64
+	VertexPolyface     = 128 | 64
+	VertexFace         = 128
+)
+
+// Polyline covers the heavy 2D and 3D polylines as well as polygon meshes
+// and polyface meshes. For polyface meshes Faces holds 1-based vertex
+// indices; a negative index marks an invisible edge.
+type Polyline struct {
+	EntityProps
+	Flags      int
+	StartWidth float64
+	EndWidth   float64
+	Elevation  float64
+	Thickness  float64
+	Extrusion  geom.Vec3
+	MCount     int
+	NCount     int
+	Vertices   []Vertex
+	Faces      [][4]int
+}
+
+func (p *Polyline) Closed() bool { return p.Flags&PolylineClosed != 0 }
+func (p *Polyline) Is3D() bool   { return p.Flags&(Polyline3D|PolylineMesh|PolylinePolyface) != 0 }
+
+type Ray struct {
+	EntityProps
+	Base      geom.Vec3
+	Direction geom.Vec3
+}
+
+type XLine struct {
+	EntityProps
+	Base      geom.Vec3
+	Direction geom.Vec3
+}
+
+const (
+	SplineClosed   = 1
+	SplinePeriodic = 2
+	SplineRational = 4
+	SplinePlanar   = 8
+)
+
+type Spline struct {
+	EntityProps
+	Flags        int
+	Degree       int
+	Knots        []float64
+	Control      []geom.Vec3
+	Weights      []float64
+	Fit          []geom.Vec3
+	StartTangent geom.Vec3
+	EndTangent   geom.Vec3
+	Normal       geom.Vec3
+}
+
+// Solid is a filled quadrilateral; the corners are in DXF order, so the
+// outline runs 1-2-4-3. TRACE entities are stored as solids too.
+type Solid struct {
+	EntityProps
+	Corners   [4]geom.Vec3
+	Thickness float64
+	Extrusion geom.Vec3
+	Trace     bool
+}
+
+type Face3D struct {
+	EntityProps
+	Corners        [4]geom.Vec3
+	InvisibleEdges int
+}
+
+const (
+	TextBackward   = 2
+	TextUpsideDown = 4
+)
+
+const (
+	HAlignLeft    = 0
+	HAlignCenter  = 1
+	HAlignRight   = 2
+	HAlignAligned = 3
+	HAlignMiddle  = 4
+	HAlignFit     = 5
+)
+
+const (
+	VAlignBaseline = 0
+	VAlignBottom   = 1
+	VAlignMiddle   = 2
+	VAlignTop      = 3
+)
+
+type Text struct {
+	EntityProps
+	Value       string
+	Position    geom.Vec3
+	AlignPoint  geom.Vec3
+	Height      float64
+	Rotation    float64
+	WidthFactor float64
+	Oblique     float64
+	Style       string
+	Generation  int
+	HAlign      int
+	VAlign      int
+	Thickness   float64
+	Extrusion   geom.Vec3
+}
+
+const (
+	AttribInvisible = 1
+	AttribConstant  = 2
+	AttribVerify    = 4
+	AttribPreset    = 8
+)
+
+type Attrib struct {
+	Text
+	Tag   string
+	Flags int
+}
+
+type AttDef struct {
+	Text
+	Tag    string
+	Prompt string
+	Flags  int
+}
+
+const (
+	MTextTopLeft      = 1
+	MTextTopCenter    = 2
+	MTextTopRight     = 3
+	MTextMiddleLeft   = 4
+	MTextMiddleCenter = 5
+	MTextMiddleRight  = 6
+	MTextBottomLeft   = 7
+	MTextBottomCenter = 8
+	MTextBottomRight  = 9
+)
+
+type MText struct {
+	EntityProps
+	Value             string
+	Position          geom.Vec3
+	XDirection        geom.Vec3
+	Extrusion         geom.Vec3
+	Height            float64
+	RectWidth         float64
+	RectHeight        float64
+	Attachment        int
+	FlowDirection     int
+	Style             string
+	LineSpacingStyle  int
+	LineSpacingFactor float64
+	ColumnType        int
+	ColumnCount       int
+	ColumnWidth       float64
+	ColumnGutter      float64
+	ColumnHeights     []float64
+}
+
+// Insert is a block reference. MINSERT arrays are expressed with Columns
+// and Rows greater than one.
+type Insert struct {
+	EntityProps
+	Block         string
+	Position      geom.Vec3
+	Scale         geom.Vec3
+	Rotation      float64
+	Extrusion     geom.Vec3
+	Columns       int
+	Rows          int
+	ColumnSpacing float64
+	RowSpacing    float64
+	Attribs       []*Attrib
+}
+
+const (
+	ImageShow         = 1
+	ImageUseClip      = 4
+	ImageTransparency = 8
+)
+
+// Image places a raster image. U and V are the WCS vectors of one pixel
+// along the rows and columns of the image; Insertion is the outer corner
+// of its bottom left pixel. Clip is in pixel coordinates, whose origin is
+// the centre of the top left pixel with Y pointing down: either two
+// opposite corners of a rectangle or the vertices of a polygon. It
+// applies when Clipping is set, hiding what is outside of it, or what is
+// inside when ClipInverted is set.
+type Image struct {
+	EntityProps
+	Insertion    geom.Vec3
+	U, V         geom.Vec3
+	Size         geom.Vec2
+	Def          Handle
+	Flags        int
+	Clipping     bool
+	ClipInverted bool
+	Clip         []geom.Vec2
+}
+
+const (
+	ViewportPerspective = 1
+	ViewportNonRectClip = 0x10000
+	ViewportOff         = 0x20000
+)
+
+// Viewport is a window in paper space onto model space. The view is a
+// plan view of the display coordinate system (DCS), whose origin is
+// ViewTarget and whose Z axis is ViewDirection; ViewCenter is in the DCS
+// and ViewHeight model units fill the viewport's Height.
+type Viewport struct {
+	EntityProps
+	Center        geom.Vec3
+	Width, Height float64
+	// ID 1 marks the viewport that shows the layout itself.
+	ID            int
+	Status        int
+	ViewCenter    geom.Vec2
+	ViewTarget    geom.Vec3
+	ViewDirection geom.Vec3
+	ViewHeight    float64
+	TwistAngle    float64
+	// FrozenLayers are hidden in this viewport only.
+	FrozenLayers []string
+	// ClipBoundary is the entity that clips the viewport when Status has
+	// ViewportNonRectClip.
+	ClipBoundary Handle
+}
+
+const (
+	HatchStyleNormal = 0
+	HatchStyleOuter  = 1
+	HatchStyleIgnore = 2
+)
+
+const (
+	HatchPatternUser       = 0
+	HatchPatternPredefined = 1
+	HatchPatternCustom     = 2
+)
+
+const (
+	HatchLoopExternal  = 1
+	HatchLoopPolyline  = 2
+	HatchLoopDerived   = 4
+	HatchLoopTextbox   = 8
+	HatchLoopOutermost = 16
+)
+
+// Hatch fills the area bounded by its loops with a solid color, a
+// pattern of dashed lines or a gradient. Loops and pattern lines are 2D,
+// in the hatch's OCS at Elevation; angles are in radians.
+type Hatch struct {
+	EntityProps
+	Pattern     string
+	Solid       bool
+	Associative bool
+	Style       int
+	PatternType int
+	Angle       float64
+	Scale       float64
+	Double      bool
+	Elevation   float64
+	Extrusion   geom.Vec3
+	Loops       []HatchLoop
+	// Lines is the pattern as the file stores it, already rotated and
+	// scaled. It is empty when only the pattern name is known.
+	Lines    []HatchLine
+	Gradient *Gradient
+}
+
+// HatchLoop is a closed boundary. Polyline loops keep their vertices,
+// other loops a chain of edges.
+type HatchLoop struct {
+	Flags    int
+	Vertices []Vertex
+	Edges    []HatchEdge
+}
+
+const (
+	EdgeLine    = 1
+	EdgeArc     = 2
+	EdgeEllipse = 3
+	EdgeSpline  = 4
+)
+
+// HatchEdge is one piece of a loop. Arcs and elliptical arcs run
+// counter-clockwise from StartAngle to EndAngle when CCW is set; for
+// clockwise edges the file stores the angles mirrored, and so do we.
+type HatchEdge struct {
+	Type       int
+	Start, End geom.Vec2
+	Center     geom.Vec2
+	Radius     float64
+	// MajorAxis is relative to Center and Ratio is the minor to major
+	// axis ratio of elliptical edges.
+	MajorAxis  geom.Vec2
+	Ratio      float64
+	StartAngle float64
+	EndAngle   float64
+	CCW        bool
+	Spline     *Spline
+}
+
+// HatchLine is one family of parallel pattern lines through Base, each
+// shifted by Offset from the previous one. Dashes alternate between
+// drawn (positive) and blank (negative) lengths; zero is a dot.
+type HatchLine struct {
+	Angle  float64
+	Base   geom.Vec2
+	Offset geom.Vec2
+	Dashes []float64
+}
+
+// Gradient is the fill of a gradient hatch. Name is one of the AutoCAD
+// gradient patterns such as LINEAR or SPHERICAL. A one-color gradient
+// blends Colors[0] towards white or black by Tint.
+type Gradient struct {
+	Name        string
+	Angle       float64
+	Shift       float64
+	SingleColor bool
+	Tint        float64
+	Colors      []Color
+}
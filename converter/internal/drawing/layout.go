@@ -0,0 +1,155 @@
+package drawing
+
+import (
+	"slices"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// Layout is a tab of the drawing: the model or a sheet of paper space.
+// Block names the block that holds the layout's entities.
+type Layout struct {
+	Handle   Handle
+	Name     string
+	TabOrder int
+	Block    string
+
+	LimMin, LimMax geom.Vec2
+	ExtMin, ExtMax geom.Vec3
+	InsBase        geom.Vec3
+
+	Plot PlotSettings
+}
+
+func (l *Layout) IsModel() bool {
+	return key(l.Block) == key(ModelSpace)
+}
+
+// PlotArea is the part of a layout that is plotted.
+type PlotArea int
+
+const (
+	PlotDisplay PlotArea = iota
+	PlotExtents
+	PlotLimits
+	PlotView
+	PlotWindow
+	PlotLayout
+)
+
+// Plot setting flags.
+const (
+	PlotCentered         = 0x4
+	PlotUseStandardScale = 0x10
+	PlotPlotStyles       = 0x20
+	PlotLineweights      = 0x80
+)
+
+// PlotSettings is the page setup of a layout. Paper sizes, margins and
+// the offset are in millimetres; the paper is fed Width by Height and the
+// plot turned by Rotation quarter turns counterclockwise.
+type PlotSettings struct {
+	PaperName                string
+	PaperWidth, PaperHeight  float64
+	MarginLeft, MarginBottom float64
+	MarginRight, MarginTop   float64
+	Origin                   geom.Vec2
+	// Inches is set when the plot scale counts inches rather than
+	// millimetres of paper.
+	Inches   bool
+	Rotation int
+	Area     PlotArea
+	// WindowMin and WindowMax bound the plotted window in drawing units.
+	WindowMin, WindowMax geom.Vec2
+	ViewName             string
+	// StyleSheet names the plot style table file, which applies when
+	// PlotPlotStyles is set.
+	StyleSheet string
+	Flags      int
+	// The scale is PaperUnits of paper to DrawingUnits of drawing unless
+	// a standard scale is used, where StandardScale 0 scales to fit and
+	// StandardFactor gives the others.
+	PaperUnits, DrawingUnits float64
+	StandardScale            int
+	StandardFactor           float64
+}
+
+// HasPaper reports whether the settings name a paper size.
+func (p *PlotSettings) HasPaper() bool {
+	return p.PaperWidth > 0 && p.PaperHeight > 0
+}
+
+// Scale returns the plot scale in paper units per drawing unit, or false
+// when the plot is scaled to fit.
+func (p *PlotSettings) Scale() (float64, bool) {
+	if p.Flags&PlotUseStandardScale != 0 {
+		if p.StandardScale == 0 || p.StandardFactor <= 0 {
+			return 0, false
+		}
+		return p.StandardFactor, true
+	}
+	if p.PaperUnits <= 0 || p.DrawingUnits <= 0 {
+		return 0, false
+	}
+	return p.PaperUnits / p.DrawingUnits, true
+}
+
+// AddLayout registers l unless a layout of the same name exists.
+func (d *Drawing) AddLayout(l *Layout) {
+	for _, o := range d.Layouts {
+		if key(o.Name) == key(l.Name) {
+			return
+		}
+	}
+	d.Layouts = append(d.Layouts, l)
+}
+
+// ModelLayout returns the layout of model space.
+func (d *Drawing) ModelLayout() *Layout {
+	for _, l := range d.Layouts {
+		if l.IsModel() {
+			return l
+		}
+	}
+	return &Layout{Name: "Model", Block: ModelSpace}
+}
+
+// PaperLayouts returns the paper space layouts in tab order.
+func (d *Drawing) PaperLayouts() []*Layout {
+	var out []*Layout
+	for _, l := range d.Layouts {
+		if !l.IsModel() {
+			out = append(out, l)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b *Layout) int { return a.TabOrder - b.TabOrder })
+	return out
+}
+
+// ensureLayouts adds the model layout and, for files from before layouts
+// existed, one layout for paper space when it has entities.
+func (d *Drawing) ensureLayouts() {
+	var model, paper bool
+	for _, l := range d.Layouts {
+		if l.IsModel() {
+			model = true
+		} else {
+			paper = true
+		}
+	}
+	if !model {
+		d.AddLayout(&Layout{Name: "Model", Block: ModelSpace})
+	}
+	if ps := d.Block(PaperSpace); !paper && ps != nil && len(ps.Entities) > 0 {
+		d.AddLayout(&Layout{
+			Name:     "Layout1",
+			TabOrder: 1,
+			Block:    PaperSpace,
+			LimMin:   d.Header.PaperLimMin,
+			LimMax:   d.Header.PaperLimMax,
+			ExtMin:   d.Header.PaperExtMin,
+			ExtMax:   d.Header.PaperExtMax,
+			InsBase:  d.Header.PaperInsBase,
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package drawing
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Bind fills the external reference block b with the model space of x,
+// the drawing it references. x is left as it is, so that a drawing
+// referenced from several others is read once and bound into each of
+// them: what is renamed is copied first.
+//
+// The table entries of x are added under the name of b followed by a
+// bar, as AutoCAD names the symbols an xref depends on, unless d already
+// has them: the states d saved for the layers of the reference win over
+// those x has itself. Entries of x that already have a bar come from
+// references nested in it and keep their names, and so do nested
+// reference blocks, layer 0, Defpoints and the ByLayer, ByBlock and
+// Continuous linetypes.
+func (d *Drawing) Bind(b *Block, x *Drawing) {
+	bd := binder{x: x, prefix: b.Name + "|", defs: make(map[Handle]Handle)}
+
+	for _, l := range x.Layers {
+		l := *l
+		l.Name = bd.layer(l.Name)
+		l.Linetype = bd.linetype(l.Linetype)
+		if d.Layer(l.Name) == nil {
+			d.AddLayer(&l)
+		}
+	}
+	for _, lt := range x.Linetypes {
+		lt := *lt
+		lt.Name = bd.linetype(lt.Name)
+		lt.Elements = slices.Clone(lt.Elements)
+		for i := range lt.Elements {
+			if lt.Elements[i].Style != "" {
+				lt.Elements[i].Style = bd.name(lt.Elements[i].Style)
+			}
+		}
+		d.AddLinetype(&lt)
+	}
+	for _, s := range x.Styles {
+		s := *s
+		s.Name = bd.name(s.Name)
+		d.AddStyle(&s)
+	}
+	for _, s := range x.DimStyles {
+		s := *s
+		s.Name = bd.name(s.Name)
+		s.TextStyle = bd.name(s.TextStyle)
+		s.LeaderArrow = bd.block(s.LeaderArrow)
+		s.Arrow = bd.block(s.Arrow)
+		s.Arrow1 = bd.block(s.Arrow1)
+		s.Arrow2 = bd.block(s.Arrow2)
+		d.AddDimStyle(&s)
+	}
+
+	// Image definitions are found by handle, so those of x get handles
+	// past the ones d uses.
+	next := Handle(1)
+	for _, def := range d.ImageDefs {
+		next = max(next, def.Handle+1)
+	}
+	for _, def := range x.ImageDefs {
+		def := *def
+		bd.defs[def.Handle] = next
+		def.Handle = next
+		next++
+		d.AddImageDef(&def)
+	}
+
+	for _, xb := range x.Blocks {
+		if xb.IsLayout() {
+			continue
+		}
+		name := bd.block(xb.Name)
+		if d.Block(name) == nil {
+			xb := *xb
+			xb.Name = name
+			xb.Entities = bd.entities(xb.Entities)
+			d.AddBlock(&xb)
+		}
+	}
+	b.Base = x.Header.InsBase
+	b.Entities = bd.entities(x.ModelSpace().Entities)
+}
+
+// binder renames the symbols of a drawing bound into another.
+type binder struct {
+	x      *Drawing
+	prefix string
+	// defs maps the old handles of image definitions to the new ones.
+	defs map[Handle]Handle
+}
+
+func (bd *binder) name(name string) string {
+	if name == "" || strings.Contains(name, "|") {
+		return name
+	}
+	return bd.prefix + name
+}
+
+func (bd *binder) layer(name string) string {
+	switch key(name) {
+	case "0", "DEFPOINTS":
+		return name
+	}
+	return bd.name(name)
+}
+
+func (bd *binder) linetype(name string) string {
+	switch key(name) {
+	case "", "BYLAYER", "BYBLOCK", "CONTINUOUS":
+		return name
+	}
+	return bd.name(name)
+}
+
+// block renames the blocks x defines; other names, such as those of the
+// built-in arrowheads, stay as they are.
+func (bd *binder) block(name string) string {
+	b := bd.x.Block(name)
+	if b == nil || b.Xref {
+		return name
+	}
+	return bd.name(name)
+}
+
+// entities returns renamed copies of the entities of x in list.
+func (bd *binder) entities(list []Entity) []Entity {
+	out := make([]Entity, len(list))
+	for i, e := range list {
+		e = clone(e)
+		p := e.Props()
+		p.Layer = bd.layer(p.Layer)
+		p.Linetype = bd.linetype(p.Linetype)
+		switch e := e.(type) {
+		case *Text:
+			e.Style = bd.name(e.Style)
+		case *AttDef:
+			e.Style = bd.name(e.Style)
+		case *MText:
+			e.Style = bd.name(e.Style)
+		case *Insert:
+			e.Block = bd.block(e.Block)
+			e.Attribs = slices.Clone(e.Attribs)
+			for j, a := range e.Attribs {
+				a := *a
+				a.Layer = bd.layer(a.Layer)
+				a.Linetype = bd.linetype(a.Linetype)
+				a.Style = bd.name(a.Style)
+				e.Attribs[j] = &a
+			}
+		case *Dimension:
+			e.Block = bd.block(e.Block)
+			e.Style = bd.name(e.Style)
+		case *Image:
+			e.Def = bd.defs[e.Def]
+		}
+		out[i] = e
+	}
+	return out
+}
+
+// clone returns a shallow copy of e, whatever its type.
+func clone(e Entity) Entity {
+	v := reflect.ValueOf(e).Elem()
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	return c.Interface().(Entity)
+}
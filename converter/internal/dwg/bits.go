@@ -0,0 +1,425 @@
+package dwg
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"unicode/utf16"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+var errShortRead = errors.New("read past end of data")
+
+// bitReader reads the bit-packed primitives described in the Open Design
+// specification. Reading past the end sets err and yields zero values, so
+// callers check err once after decoding a whole record.
+type bitReader struct {
+	buf []byte
+	pos int
+	end int
+	ver version
+	cp  codepage
+	err error
+}
+
+func newBitReader(buf []byte, ver version, cp codepage) *bitReader {
+	return &bitReader{buf: buf, end: len(buf) * 8, ver: ver, cp: cp}
+}
+
+// sub returns a reader over the same buffer positioned at bit pos.
+func (r *bitReader) sub(pos, end int) *bitReader {
+	if end > len(r.buf)*8 {
+		end = len(r.buf) * 8
+	}
+	s := &bitReader{buf: r.buf, pos: pos, end: end, ver: r.ver, cp: r.cp}
+	if pos < 0 || pos > end {
+		s.err = errShortRead
+	}
+	return s
+}
+
+func (r *bitReader) fail() {
+	if r.err == nil {
+		r.err = errShortRead
+	}
+	r.pos = r.end
+}
+
+func (r *bitReader) bit() uint8 {
+	if r.pos >= r.end {
+		r.fail()
+		return 0
+	}
+	b := r.buf[r.pos>>3] >> (7 - uint(r.pos&7)) & 1
+	r.pos++
+	return b
+}
+
+func (r *bitReader) bits(n int) uint32 {
+	var v uint32
+	for range n {
+		v = v<<1 | uint32(r.bit())
+	}
+	return v
+}
+
+func (r *bitReader) RC() uint8 {
+	if r.pos+8 > r.end {
+		r.fail()
+		return 0
+	}
+	i, sh := r.pos>>3, uint(r.pos&7)
+	r.pos += 8
+	if sh == 0 {
+		return r.buf[i]
+	}
+	return r.buf[i]<<sh | r.buf[i+1]>>(8-sh)
+}
+
+func (r *bitReader) bytes(n int) []byte {
+	if n < 0 || r.pos+n*8 > r.end {
+		r.fail()
+		return nil
+	}
+	out := make([]byte, n)
+	if r.pos&7 == 0 {
+		copy(out, r.buf[r.pos>>3:])
+		r.pos += n * 8
+		return out
+	}
+	for i := range out {
+		out[i] = r.RC()
+	}
+	return out
+}
+
+func (r *bitReader) B() bool    { return r.bit() == 1 }
+func (r *bitReader) BB() uint8  { return uint8(r.bits(2)) }
+func (r *bitReader) RS() uint16 { return binary.LittleEndian.Uint16(r.pad(2)) }
+func (r *bitReader) RL() uint32 { return binary.LittleEndian.Uint32(r.pad(4)) }
+func (r *bitReader) RLL() uint64 {
+	return binary.LittleEndian.Uint64(r.pad(8))
+}
+func (r *bitReader) RD() float64 { return math.Float64frombits(r.RLL()) }
+
+func (r *bitReader) pad(n int) []byte {
+	b := r.bytes(n)
+	if b == nil {
+		return make([]byte, n)
+	}
+	return b
+}
+
+// B3 reads the R2010+ "3B" code of up to three bits.
+func (r *bitReader) B3() uint8 {
+	var v uint8
+	for range 3 {
+		v <<= 1
+		if !r.B() {
+			break
+		}
+		v |= 1
+	}
+	return v
+}
+
+func (r *bitReader) BS() int16 {
+	switch r.BB() {
+	case 0:
+		return int16(r.RS())
+	case 1:
+		return int16(r.RC())
+	case 2:
+		return 0
+	default:
+		return 256
+	}
+}
+
+func (r *bitReader) BL() int32 {
+	switch r.BB() {
+	case 0:
+		return int32(r.RL())
+	case 1:
+		return int32(r.RC())
+	default:
+		return 0
+	}
+}
+
+func (r *bitReader) BLL() uint64 {
+	n := int(r.bits(3))
+	var v uint64
+	for i := range n {
+		v |= uint64(r.RC()) << (8 * i)
+	}
+	return v
+}
+
+func (r *bitReader) BD() float64 {
+	switch r.BB() {
+	case 0:
+		return r.RD()
+	case 1:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// DD reads a double that patches bytes of a default value.
+func (r *bitReader) DD(def float64) float64 {
+	switch r.BB() {
+	case 0:
+		return def
+	case 1:
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, math.Float64bits(def))
+		copy(b[0:4], r.pad(4))
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case 2:
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, math.Float64bits(def))
+		p := r.pad(6)
+		b[4], b[5] = p[0], p[1]
+		copy(b[0:4], p[2:6])
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	default:
+		return r.RD()
+	}
+}
+
+// MC reads a signed modular char.
+func (r *bitReader) MC() int64 {
+	var v int64
+	for shift := 0; shift < 64; shift += 7 {
+		b := r.RC()
+		if b&0x80 == 0 {
+			v |= int64(b&0x3f) << shift
+			if b&0x40 != 0 {
+				return -v
+			}
+			return v
+		}
+		v |= int64(b&0x7f) << shift
+		if r.err != nil {
+			return 0
+		}
+	}
+	return v
+}
+
+// UMC reads an unsigned modular char.
+func (r *bitReader) UMC() uint64 {
+	var v uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b := r.RC()
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 || r.err != nil {
+			break
+		}
+	}
+	return v
+}
+
+// MS reads a modular short.
+func (r *bitReader) MS() uint32 {
+	var v uint32
+	for shift := 0; shift < 32; shift += 15 {
+		w := uint32(r.RS())
+		v |= (w & 0x7fff) << shift
+		if w&0x8000 == 0 || r.err != nil {
+			break
+		}
+	}
+	return v
+}
+
+type handleRef struct {
+	code  uint8
+	value uint64
+}
+
+func (r *bitReader) H() handleRef {
+	b := r.RC()
+	h := handleRef{code: b >> 4}
+	for range b & 0x0f {
+		h.value = h.value<<8 | uint64(r.RC())
+	}
+	return h
+}
+
+// abs resolves an offset handle reference relative to the handle of the
+// object being decoded.
+func (h handleRef) abs(own uint64) uint64 {
+	switch h.code {
+	case 6:
+		return own + 1
+	case 8:
+		return own - 1
+	case 0xA:
+		return own + h.value
+	case 0xC:
+		return own - h.value
+	default:
+		return h.value
+	}
+}
+
+// TV reads a codepage string as used before R2007.
+func (r *bitReader) TV() string {
+	n := int(r.BS())
+	if n <= 0 {
+		return ""
+	}
+	b := r.bytes(n)
+	if i := indexZero(b); i >= 0 {
+		b = b[:i]
+	}
+	return r.cp.decode(b)
+}
+
+// TU reads a UTF-16 string as used since R2007.
+func (r *bitReader) TU() string {
+	return r.utf16(int(r.BS()))
+}
+
+// utf16 reads n UTF-16 code units, dropping trailing NULs.
+func (r *bitReader) utf16(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if r.pos+n*16 > r.end {
+		r.fail()
+		return ""
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = r.RS()
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
+
+// T reads a text field in the encoding used by the file version.
+func (r *bitReader) T() string {
+	if r.ver >= r2007 {
+		return r.TU()
+	}
+	return r.TV()
+}
+
+func (r *bitReader) BT() float64 {
+	if r.ver >= r2000 && r.B() {
+		return 0
+	}
+	return r.BD()
+}
+
+func (r *bitReader) BE() geom.Vec3 {
+	if r.ver >= r2000 && r.B() {
+		return geom.ZAxis
+	}
+	return r.BD3()
+}
+
+func (r *bitReader) RD2() geom.Vec2 { return geom.Vec2{X: r.RD(), Y: r.RD()} }
+func (r *bitReader) RD3() geom.Vec3 { return geom.Vec3{X: r.RD(), Y: r.RD(), Z: r.RD()} }
+func (r *bitReader) BD2() geom.Vec2 { return geom.Vec2{X: r.BD(), Y: r.BD()} }
+func (r *bitReader) BD3() geom.Vec3 { return geom.Vec3{X: r.BD(), Y: r.BD(), Z: r.BD()} }
+
+func (r *bitReader) DD2(def geom.Vec2) geom.Vec2 {
+	return geom.Vec2{X: r.DD(def.X), Y: r.DD(def.Y)}
+}
+
+func (r *bitReader) DD3(def geom.Vec3) geom.Vec3 {
+	return geom.Vec3{X: r.DD(def.X), Y: r.DD(def.Y), Z: r.DD(def.Z)}
+}
+
+// OT reads an R2010+ object type.
+func (r *bitReader) OT() int {
+	switch r.BB() {
+	case 0:
+		return int(r.RC())
+	case 1:
+		return int(r.RC()) + 0x1f0
+	default:
+		return int(r.RS())
+	}
+}
+
+// CMC reads a color as stored in table records and header variables.
+func (r *bitReader) CMC() drawing.Color { return r.cmc(r.T) }
+
+// cmc reads a CMC color whose name strings come from text, which differs
+// from the data stream for R2007+ records.
+func (r *bitReader) cmc(text func() string) drawing.Color {
+	c := drawing.Color{Index: r.BS()}
+	if r.ver < r2004 {
+		return c
+	}
+	rgb := r.BL()
+	flags := r.RC()
+	if uint32(rgb)>>24 == 0xc2 {
+		c.True = true
+		c.RGB = uint32(rgb) & 0xffffff
+	} else if uint32(rgb)>>24 == 0xc3 {
+		c.Index = int16(rgb & 0xff)
+	} else if uint32(rgb)>>24 == 0xc1 {
+		c.Index = drawing.ColorByBlock
+	} else if uint32(rgb)>>24 == 0xc0 {
+		c.Index = drawing.ColorByLayer
+	}
+	if flags&1 != 0 {
+		c.Name = text()
+	}
+	if flags&2 != 0 {
+		c.Book = text()
+	}
+	return c
+}
+
+// ENC reads the compact entity color of R2004+ files. The returned flags
+// tell whether a color book handle follows in the handle stream.
+func (r *bitReader) ENC() (drawing.Color, uint16) {
+	v := uint16(r.BS())
+	c := drawing.Color{Index: int16(v & 0x1ff)}
+	flags := v & 0xfe00
+	if flags&0x8000 != 0 {
+		rgb := uint32(r.BL())
+		switch rgb >> 24 {
+		case 0xc2:
+			c.True = true
+			c.RGB = rgb & 0xffffff
+		case 0xc3:
+			c.Index = int16(rgb & 0xff)
+		case 0xc1:
+			c.Index = drawing.ColorByBlock
+		case 0xc0:
+			c.Index = drawing.ColorByLayer
+		}
+	}
+	if flags&0x2000 != 0 {
+		r.BL() // transparency
+	}
+	return c, flags
+}
+
+func (r *bitReader) sentinel() []byte { return r.pad(16) }
+
+func (r *bitReader) alignByte() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+func indexZero(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,432 @@
+package dwg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+)
+
+// decode reads all sections of an opened file and assembles the drawing.
+// Objects that fail to decode are left out so a single unsupported record
+// does not lose the drawing; how many is reported as a warning.
+func (f *file) decode() (*drawing.Drawing, error) {
+	d := drawing.New()
+	d.Version = f.tag
+
+	refs, err := f.readHeader(&d.Header)
+	if err != nil {
+		return nil, fmt.Errorf("dwg %s: header: %w", f.tag, err)
+	}
+	d.Header.Encoding = codepages[f.cp]
+	f.readSummary(&d.Summary)
+	classes, err := f.readClasses()
+	if err != nil {
+		return nil, fmt.Errorf("dwg %s: classes: %w", f.tag, err)
+	}
+	handles, err := f.readHandles()
+	if err != nil {
+		return nil, fmt.Errorf("dwg %s: object map: %w", f.tag, err)
+	}
+	data, err := f.section(sectionObjects)
+	if err != nil {
+		return nil, fmt.Errorf("dwg %s: %w", f.tag, err)
+	}
+
+	b := &builder{f: f, d: d, refs: refs, objects: make(map[uint64]*object, len(handles))}
+	var skipped int
+	for _, ref := range handles {
+		o, err := f.readObject(data, ref, classes)
+		if err != nil {
+			skipped++
+			continue
+		}
+		b.objects[o.handle] = o
+		b.order = append(b.order, o)
+	}
+	if len(b.order) == 0 {
+		return nil, fmt.Errorf("dwg %s: no objects could be decoded", f.tag)
+	}
+	if skipped > 0 {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("%d of %d objects could not be decoded and were left out", skipped, len(handles)))
+	}
+	b.build()
+	return d, nil
+}
+
+type builder struct {
+	f       *file
+	d       *drawing.Drawing
+	refs    headerRefs
+	objects map[uint64]*object
+	order   []*object
+
+	layers    map[uint64]string
+	ltypes    map[uint64]string
+	styles    map[uint64]string
+	dimStyles map[uint64]string
+	blocks    map[uint64]string
+	apps      map[uint64]string
+	children  map[uint64][]*object
+	// plotStyles names the placeholders of the named plot styles.
+	plotStyles map[uint64]string
+}
+
+func (b *builder) build() {
+	b.layers = make(map[uint64]string)
+	b.ltypes = make(map[uint64]string)
+	b.styles = make(map[uint64]string)
+	b.dimStyles = make(map[uint64]string)
+	b.blocks = make(map[uint64]string)
+	b.apps = make(map[uint64]string)
+	b.children = make(map[uint64][]*object)
+	b.plotStyles = b.namedPlotStyles()
+
+	var layers []*layerRecord
+	var ltypes []*ltypeRecord
+	var blocks []*blockRecord
+	var dimStyles []*dimStyleRecord
+	var layouts []*layoutRecord
+	views := make(map[uint64]string)
+	// styleFiles holds the font files of the styles, which include the
+	// unnamed ones that load shape files for complex linetypes.
+	styleFiles := make(map[uint64]string)
+	for _, o := range b.order {
+		switch rec := o.rec.(type) {
+		case *layerRecord:
+			b.layers[o.handle] = rec.layer.Name
+			layers = append(layers, rec)
+		case *ltypeRecord:
+			b.ltypes[o.handle] = rec.ltype.Name
+			ltypes = append(ltypes, rec)
+		case *drawing.TextStyle:
+			b.styles[o.handle] = rec.Name
+			styleFiles[o.handle] = rec.FontFile
+			b.d.AddStyle(rec)
+		case *blockRecord:
+			b.blocks[o.handle] = rec.block.Name
+			blocks = append(blocks, rec)
+		case *dimStyleRecord:
+			b.dimStyles[o.handle] = rec.style.Name
+			dimStyles = append(dimStyles, rec)
+		case appID:
+			b.apps[o.handle] = string(rec)
+		case *layoutRecord:
+			layouts = append(layouts, rec)
+		case *drawing.View:
+			views[o.handle] = rec.Name
+			b.d.AddView(rec)
+		case *drawing.ImageDef:
+			b.d.AddImageDef(rec)
+		}
+	}
+	for _, rec := range ltypes {
+		for i, h := range rec.styles {
+			if i < len(rec.ltype.Elements) {
+				rec.ltype.Elements[i].Style = b.styles[h]
+				if rec.ltype.Elements[i].Shape != 0 {
+					rec.ltype.Elements[i].ShapeFile = styleFiles[h]
+				}
+			}
+		}
+		b.d.AddLinetype(rec.ltype)
+	}
+	for _, rec := range layers {
+		rec.layer.PlotStyle = b.plotStyles[rec.plotStyle]
+		rec.layer.Linetype = b.ltypes[rec.ltype]
+		if rec.layer.Linetype == "" {
+			rec.layer.Linetype = "Continuous"
+		}
+		b.d.AddLayer(rec.layer)
+	}
+	for _, rec := range dimStyles {
+		if name, ok := b.styles[rec.textStyle]; ok {
+			rec.style.TextStyle = name
+		}
+		// Before R2000 the record names the arrow blocks itself.
+		for i, h := range rec.arrows {
+			if name, ok := b.blocks[h]; ok {
+				rec.style.Set(341+i, name)
+			}
+		}
+		b.d.AddDimStyle(rec.style)
+	}
+	if name, ok := b.styles[b.refs.textStyle]; ok {
+		b.d.Header.TextStyle = name
+	}
+
+	ms, ps := b.refs.modelSpace, b.refs.paperSpace
+	for _, rec := range blocks {
+		switch {
+		case ms == 0 && strings.EqualFold(rec.block.Name, drawing.ModelSpace):
+			ms = uint64(rec.block.Handle)
+		case ps == 0 && strings.EqualFold(rec.block.Name, drawing.PaperSpace):
+			ps = uint64(rec.block.Handle)
+		}
+	}
+
+	for _, o := range b.order {
+		if o.hdr == nil {
+			continue
+		}
+		owner := o.owner
+		switch o.hdr.entmode {
+		case 1:
+			owner = ps
+		case 2:
+			owner = ms
+		}
+		b.children[owner] = append(b.children[owner], o)
+		b.resolveProps(o, owner == ps && ps != 0)
+	}
+
+	for _, o := range b.order {
+		switch e := o.ent.(type) {
+		case *drawing.Polyline:
+			b.buildPolyline(o, e)
+		case *drawing.Insert:
+			b.buildInsert(o, e)
+		case *drawing.Dimension:
+			b.buildDimension(o, e)
+		case *drawing.Viewport:
+			b.buildViewport(o, e)
+		}
+	}
+
+	for _, rec := range blocks {
+		blk := rec.block
+		for _, o := range ordered(b.children[uint64(blk.Handle)], rec.owned) {
+			if o.ent != nil {
+				blk.Entities = append(blk.Entities, o.ent)
+			}
+		}
+		if blk.IsLayout() {
+			numberViewports(blk)
+		}
+		b.d.AddBlock(blk)
+	}
+	for _, rec := range layouts {
+		if name, ok := views[rec.view]; ok {
+			rec.layout.Plot.ViewName = name
+		}
+		if rec.layout.Block = b.blocks[rec.block]; rec.layout.Block != "" {
+			b.d.AddLayout(rec.layout)
+		}
+	}
+	b.d.EnsureDefaults()
+}
+
+func (b *builder) resolveProps(o *object, paper bool) {
+	h := o.hdr
+	if name, ok := b.layers[h.layer]; ok {
+		h.props.Layer = name
+	}
+	if h.ltypeFlags == 3 {
+		if name, ok := b.ltypes[h.ltype]; ok {
+			h.props.Linetype = name
+		}
+	}
+	switch h.plotStyle {
+	case 1:
+		h.props.PlotStyle = "ByBlock"
+	case 3:
+		h.props.PlotStyle = b.plotStyles[h.plotStyleRef]
+	}
+	if c, ok := b.objects[h.book]; ok && h.book != 0 {
+		if book, ok := c.rec.(drawing.Color); ok {
+			h.props.Color.Name, h.props.Color.Book = book.Name, book.Book
+			if !h.props.Color.True {
+				h.props.Color.True, h.props.Color.RGB = book.True, book.RGB
+			}
+		}
+	}
+	h.props.PaperSpace = paper
+	for _, e := range o.eed {
+		if b.apps[e.app] == "PE_URL" {
+			h.props.Hyperlink = hyperlink(e.items(b.f.ver, b.f.cp))
+		}
+	}
+	if o.ent == nil {
+		return
+	}
+	*o.ent.Props() = h.props
+	style := b.styles[o.style]
+	if style == "" {
+		style = "Standard"
+	}
+	switch e := o.ent.(type) {
+	case *drawing.Text:
+		e.Style = style
+	case *drawing.Attrib:
+		e.Style = style
+	case *drawing.AttDef:
+		e.Style = style
+	case *drawing.MText:
+		e.Style = style
+	}
+}
+
+// namedPlotStyles maps the placeholder objects in the ACAD_PLOTSTYLENAME
+// dictionary to the names of the plot styles they stand for.
+func (b *builder) namedPlotStyles() map[uint64]string {
+	names := make(map[uint64]string)
+	root, ok := b.objects[b.refs.namedObjects]
+	if !ok {
+		return names
+	}
+	dict, ok := root.rec.(*dictionary)
+	if !ok {
+		return names
+	}
+	o, ok := b.objects[dict.get("ACAD_PLOTSTYLENAME")]
+	if !ok {
+		return names
+	}
+	if styles, ok := o.rec.(*dictionary); ok {
+		for i, h := range styles.items {
+			names[h] = styles.names[i]
+		}
+	}
+	return names
+}
+
+func (b *builder) buildPolyline(o *object, p *drawing.Polyline) {
+	for _, c := range ordered(b.children[o.handle], o.owned) {
+		switch v := c.rec.(type) {
+		case drawing.Vertex:
+			p.Vertices = append(p.Vertices, v)
+		case [4]int:
+			p.Faces = append(p.Faces, v)
+		}
+	}
+}
+
+func (b *builder) buildInsert(o *object, ins *drawing.Insert) {
+	ins.Block = b.blocks[o.block]
+	for _, c := range ordered(b.children[o.handle], o.owned) {
+		if a, ok := c.ent.(*drawing.Attrib); ok {
+			ins.Attribs = append(ins.Attribs, a)
+		}
+	}
+}
+
+func (b *builder) buildDimension(o *object, d *drawing.Dimension) {
+	d.Style = b.dimStyles[o.style]
+	if d.Style == "" {
+		d.Style = "Standard"
+	}
+	d.Block = b.blocks[o.block]
+	for _, e := range o.eed {
+		if b.apps[e.app] == "ACAD" {
+			d.Overrides = b.dimOverrides(e.items(b.f.ver, b.f.cp))
+		}
+	}
+}
+
+func (b *builder) buildViewport(o *object, v *drawing.Viewport) {
+	layer := func(h uint64) string { return b.layers[h] }
+	if refs, ok := o.rec.(*viewportRefs); ok {
+		for _, h := range refs.frozen {
+			if name := layer(h); name != "" {
+				v.FrozenLayers = append(v.FrozenLayers, name)
+			}
+		}
+		v.ClipBoundary = drawing.Handle(refs.clip)
+		return
+	}
+	for _, e := range o.eed {
+		if b.apps[e.app] == "ACAD" {
+			mview(v, e.items(b.f.ver, b.f.cp), layer)
+		}
+	}
+}
+
+// numberViewports gives the viewports of a layout the IDs a DXF file
+// stores: the first one, which shows the layout itself, is 1.
+func numberViewports(blk *drawing.Block) {
+	id := 1
+	for _, e := range blk.Entities {
+		if v, ok := e.(*drawing.Viewport); ok {
+			v.ID = id
+			id++
+		}
+	}
+}
+
+// hyperlink reads the PE_URL extended data: the URL, then between braces
+// the description and the sub-location.
+func hyperlink(items []eedItem) *drawing.Hyperlink {
+	var values []string
+	depth := 0
+	for _, it := range items {
+		switch {
+		case it.code == 1002 && it.value == "{":
+			depth++
+		case it.code == 1002:
+			depth--
+		case it.code == 1000 && depth <= 1:
+			values = append(values, it.value)
+		}
+	}
+	values = append(values, "", "", "")
+	h := &drawing.Hyperlink{URL: values[0], Description: values[1], SubLocation: values[2]}
+	if h.URL == "" && h.SubLocation == "" {
+		return nil
+	}
+	return h
+}
+
+// dimOverrides reads the DSTYLE overrides from the ACAD extended data of
+// a dimension: pairs of a 1070 group code and a value between braces.
+func (b *builder) dimOverrides(items []eedItem) []drawing.DimOverride {
+	var out []drawing.DimOverride
+	for i, it := range items {
+		if it.code != 1000 || it.value != "DSTYLE" {
+			continue
+		}
+		for j := i + 2; j+1 < len(items) && items[j].code == 1070; j += 2 {
+			code, _ := strconv.Atoi(items[j].value)
+			v := items[j+1]
+			if v.code == 1005 {
+				h, _ := strconv.ParseUint(v.value, 16, 64)
+				if code == 340 {
+					v.value = b.styles[h]
+				} else {
+					v.value = b.blocks[h]
+				}
+				if v.value == "" {
+					continue
+				}
+			}
+			out = append(out, drawing.DimOverride{Code: code, Value: v.value})
+		}
+		break
+	}
+	return out
+}
+
+// ordered returns the children in the order of the owner's explicit list,
+// followed by any remaining children in handle order.
+func ordered(children []*object, list []uint64) []*object {
+	if len(list) == 0 {
+		return children
+	}
+	byHandle := make(map[uint64]*object, len(children))
+	for _, c := range children {
+		byHandle[c.handle] = c
+	}
+	out := make([]*object, 0, len(children))
+	for _, h := range list {
+		if c, ok := byHandle[h]; ok {
+			out = append(out, c)
+			delete(byHandle, h)
+		}
+	}
+	for _, c := range children {
+		if _, ok := byHandle[c.handle]; ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
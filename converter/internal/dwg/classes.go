@@ -0,0 +1,62 @@
+package dwg
+
+// class describes a custom object type numbered 500 and above.
+type class struct {
+	num     int
+	dxfName string
+	cppName string
+	entity  bool
+}
+
+func (f *file) readClasses() (map[int]class, error) {
+	classes := make(map[int]class)
+	sec, err := f.section(sectionClasses)
+	if err != nil {
+		return classes, err
+	}
+	r := newBitReader(sec, f.ver, f.cp)
+	r.sentinel()
+	size := int(r.RL())
+	if f.ver >= r2010 && f.maint > 3 {
+		r.RL()
+	}
+	st := &streams{d: r, s: r, h: r}
+
+	if f.ver < r2004 {
+		end := r.pos + size*8
+		for r.pos+8 < end && r.err == nil {
+			c := class{num: int(r.BS())}
+			r.BS() // proxy flags
+			r.TV() // application name
+			c.cppName = r.TV()
+			c.dxfName = r.TV()
+			r.B() // was a zombie
+			c.entity = r.BS() == 0x1f2
+			classes[c.num] = c
+		}
+		return classes, r.err
+	}
+
+	if f.ver >= r2007 {
+		start := r.pos
+		st.s = stringStream(r, start+int(r.RL()))
+	}
+	maxNum := int(r.BS())
+	r.RC()
+	r.RC()
+	r.B()
+	for i := 0; i < maxNum-499 && r.err == nil; i++ {
+		c := class{num: int(r.BS())}
+		r.BS() // proxy flags
+		st.T() // application name
+		c.cppName = st.T()
+		c.dxfName = st.T()
+		r.B() // was a zombie
+		c.entity = r.BS() == 0x1f2
+		for range 5 { // instance count, dwg and maintenance version, unknowns
+			r.BL()
+		}
+		classes[c.num] = c
+	}
+	return classes, st.err()
+}
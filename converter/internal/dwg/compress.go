@@ -0,0 +1,413 @@
+package dwg
+
+import "errors"
+
+var errCompressed = errors.New("corrupt compressed data")
+
+// decompress2004 expands the LZ77 variant used by R2004+ section pages.
+func decompress2004(src []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxSection {
+		return nil, errCompressed
+	}
+	dst := make([]byte, 0, size)
+	i := 0
+	next := func() byte {
+		if i >= len(src) {
+			i++
+			return 0x11
+		}
+		b := src[i]
+		i++
+		return b
+	}
+	literalLength := func() (int, byte) {
+		b := next()
+		switch {
+		case b >= 0x01 && b <= 0x0f:
+			return int(b) + 3, 0
+		case b == 0:
+			total := 0x0f
+			for b = next(); b == 0 && i <= len(src); b = next() {
+				total += 0xff
+			}
+			return total + int(b) + 3, 0
+		default:
+			return 0, b
+		}
+	}
+	longOffset := func() int {
+		b := next()
+		total := 0
+		if b == 0 {
+			total = 0xff
+			for b = next(); b == 0 && i <= len(src); b = next() {
+				total += 0xff
+			}
+		}
+		return total + int(b)
+	}
+	twoByteOffset := func() (int, int) {
+		b1, b2 := next(), next()
+		return int(b1>>2) | int(b2)<<6, int(b1 & 3)
+	}
+	copyLiteral := func(n int) error {
+		if i+n > len(src) || len(dst)+n > size {
+			return errCompressed
+		}
+		dst = append(dst, src[i:i+n]...)
+		i += n
+		return nil
+	}
+
+	lit, opcode := literalLength()
+	if err := copyLiteral(lit); err != nil {
+		return nil, err
+	}
+	for i < len(src) {
+		if opcode == 0 {
+			opcode = next()
+		}
+		var compBytes, compOffset int
+		switch {
+		case opcode >= 0x40:
+			compBytes = int(opcode>>4) - 1
+			op2 := next()
+			compOffset = int(op2)<<2 | int(opcode&0x0c)>>2
+			if opcode&3 != 0 {
+				lit = int(opcode & 3)
+				opcode = 0
+			} else {
+				lit, opcode = literalLength()
+			}
+		case opcode >= 0x21:
+			compBytes = int(opcode) - 0x1e
+			compOffset, lit = twoByteOffset()
+			if lit != 0 {
+				opcode = 0
+			} else {
+				lit, opcode = literalLength()
+			}
+		case opcode == 0x20:
+			compBytes = longOffset() + 0x21
+			compOffset, lit = twoByteOffset()
+			if lit != 0 {
+				opcode = 0
+			} else {
+				lit, opcode = literalLength()
+			}
+		case opcode >= 0x12:
+			compBytes = int(opcode&0x0f) + 2
+			compOffset, lit = twoByteOffset()
+			compOffset += 0x3fff
+			if lit != 0 {
+				opcode = 0
+			} else {
+				lit, opcode = literalLength()
+			}
+		case opcode == 0x10:
+			compBytes = longOffset() + 9
+			compOffset, lit = twoByteOffset()
+			compOffset += 0x3fff
+			if lit != 0 {
+				opcode = 0
+			} else {
+				lit, opcode = literalLength()
+			}
+		case opcode == 0x11:
+			return pad(dst, size), nil
+		default:
+			return nil, errCompressed
+		}
+
+		from := len(dst) - compOffset - 1
+		if from < 0 || len(dst)+compBytes > size {
+			return nil, errCompressed
+		}
+		for k := 0; k < compBytes; k++ {
+			dst = append(dst, dst[from+k])
+		}
+		if err := copyLiteral(lit); err != nil {
+			return nil, err
+		}
+	}
+	return pad(dst, size), nil
+}
+
+func pad(b []byte, size int) []byte {
+	if len(b) < size {
+		b = append(b, make([]byte, size-len(b))...)
+	}
+	return b
+}
+
+// decompress2007 expands the compression used by R2007 pages.
+func decompress2007(src []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxSection {
+		return nil, errCompressed
+	}
+	dst := make([]byte, 0, size)
+	i := 0
+	if len(src) == 0 {
+		return pad(dst, size), nil
+	}
+	var length, offset int
+	opcode := src[i]
+	i++
+	if opcode&0xf0 == 0x20 {
+		i += 2
+		if i >= len(src) {
+			return nil, errCompressed
+		}
+		length = int(src[i] & 7)
+		i++
+		if length == 0 {
+			return nil, errCompressed
+		}
+	}
+	read := func() byte {
+		if i >= len(src) {
+			i++
+			return 0
+		}
+		b := src[i]
+		i++
+		return b
+	}
+
+	for i < len(src) {
+		if length == 0 {
+			length = int(opcode) + 8
+			if length == 0x17 {
+				n := int(read())
+				length += n
+				if n == 0xff {
+					for {
+						n = int(read())
+						n |= int(read()) << 8
+						length += n
+						if n != 0xffff || i >= len(src) {
+							break
+						}
+					}
+				}
+			}
+		}
+		if i+length > len(src) || len(dst)+length > size {
+			return nil, errCompressed
+		}
+		dst = copyCompressed2007(dst, src[i:i+length])
+		i += length
+		length = 0
+		if i >= len(src) {
+			break
+		}
+		opcode = read()
+		for {
+			switch opcode >> 4 {
+			case 0:
+				length = int(opcode&0xf) + 0x13
+				offset = int(read())
+				opcode = read()
+				length += int(opcode>>3) & 0x10
+				offset += int(opcode&0x78)<<5 + 1
+			case 1:
+				length = int(opcode&0xf) + 3
+				offset = int(read())
+				opcode = read()
+				offset += int(opcode&0xf8)<<5 + 1
+			case 2:
+				offset = int(read())
+				offset |= int(read()) << 8
+				length = int(opcode & 7)
+				if opcode&8 == 0 {
+					opcode = read()
+					length += int(opcode & 0xf8)
+				} else {
+					offset++
+					length += int(read()) << 3
+					opcode = read()
+					length += int(opcode&0xf8)<<8 + 0x100
+				}
+			default:
+				length = int(opcode >> 4)
+				offset = int(opcode & 15)
+				opcode = read()
+				offset += int(opcode&0xf8)<<1 + 1
+			}
+			from := len(dst) - offset
+			if from < 0 || len(dst)+length > size {
+				return nil, errCompressed
+			}
+			for k := 0; k < length; k++ {
+				dst = append(dst, dst[from+k])
+			}
+			length = int(opcode & 7)
+			if length != 0 || i >= len(src) {
+				break
+			}
+			opcode = read()
+			if opcode>>4 == 0 {
+				break
+			}
+			if opcode>>4 == 0x0f {
+				opcode &= 0xf
+			}
+		}
+	}
+	return pad(dst, size), nil
+}
+
+// copyCompressed2007 appends a literal run; literals are stored in
+// shuffled 32-byte blocks with a byte-reversed tail.
+func copyCompressed2007(dst, src []byte) []byte {
+	cp := func(n, off int) {
+		dst = append(dst, src[off:off+n]...)
+	}
+	c1 := func(off int) { dst = append(dst, src[off]) }
+	c2 := func(off int) { dst = append(dst, src[off+1], src[off]) }
+	c3 := func(off int) { dst = append(dst, src[off+2], src[off+1], src[off]) }
+
+	for len(src) >= 32 {
+		cp(4, 24)
+		cp(4, 28)
+		cp(4, 16)
+		cp(4, 20)
+		cp(4, 8)
+		cp(4, 12)
+		cp(4, 0)
+		cp(4, 4)
+		src = src[32:]
+	}
+	switch len(src) {
+	case 1:
+		c1(0)
+	case 2:
+		c2(0)
+	case 3:
+		c3(0)
+	case 4:
+		cp(4, 0)
+	case 5:
+		c1(4)
+		cp(4, 0)
+	case 6:
+		c1(5)
+		cp(4, 1)
+		c1(0)
+	case 7:
+		c2(5)
+		cp(4, 1)
+		c1(0)
+	case 8:
+		cp(8, 0)
+	case 9:
+		c1(8)
+		cp(8, 0)
+	case 10:
+		c1(9)
+		cp(8, 1)
+		c1(0)
+	case 11:
+		c2(9)
+		cp(8, 1)
+		c1(0)
+	case 12:
+		cp(4, 8)
+		cp(8, 0)
+	case 13:
+		c1(12)
+		cp(4, 8)
+		cp(8, 0)
+	case 14:
+		c1(13)
+		cp(4, 9)
+		cp(8, 1)
+		c1(0)
+	case 15:
+		c2(13)
+		cp(4, 9)
+		cp(8, 1)
+		c1(0)
+	case 16:
+		cp(8, 8)
+		cp(8, 0)
+	case 17:
+		cp(8, 9)
+		c1(8)
+		cp(8, 0)
+	case 18:
+		c1(17)
+		cp(8, 9)
+		cp(8, 1)
+		c1(0)
+	case 19:
+		c3(16)
+		cp(8, 8)
+		cp(8, 0)
+	case 20:
+		cp(4, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 21:
+		c1(20)
+		cp(4, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 22:
+		c2(20)
+		cp(4, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 23:
+		c3(20)
+		cp(4, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 24:
+		cp(8, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 25:
+		cp(8, 17)
+		c1(16)
+		cp(8, 8)
+		cp(8, 0)
+	case 26:
+		c1(25)
+		cp(8, 17)
+		c1(16)
+		cp(8, 8)
+		cp(8, 0)
+	case 27:
+		c2(25)
+		cp(8, 17)
+		c1(16)
+		cp(8, 8)
+		cp(8, 0)
+	case 28:
+		cp(4, 24)
+		cp(8, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 29:
+		c1(28)
+		cp(4, 24)
+		cp(8, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 30:
+		c2(28)
+		cp(4, 24)
+		cp(8, 16)
+		cp(8, 8)
+		cp(8, 0)
+	case 31:
+		c1(30)
+		cp(4, 26)
+		cp(8, 18)
+		cp(8, 10)
+		cp(8, 2)
+		c2(0)
+	}
+	return dst
+}
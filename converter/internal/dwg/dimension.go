@@ -0,0 +1,370 @@
+package dwg
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// dimStyleRecord holds a DIMSTYLE entry with the handles of its text style
+// and of the arrow blocks DIMLDRBLK, DIMBLK, DIMBLK1 and DIMBLK2.
+type dimStyleRecord struct {
+	style     *drawing.DimStyle
+	textStyle uint64
+	arrows    [4]uint64
+}
+
+// appID is the name of a registered application, which extended data is
+// filed under.
+type appID string
+
+func (f *file) decodeAppID(o *object, st *streams) error {
+	name := tableEntry(st)
+	st.d.RC() // unknown
+	o.rec = appID(name)
+	return nil
+}
+
+func (f *file) decodeDimStyle(o *object, st *streams) error {
+	r := st.d
+	ds := drawing.NewDimStyle(tableEntry(st))
+	ds.Handle = drawing.Handle(o.handle)
+	if f.ver <= r14 {
+		ds.Tol = r.B()
+		ds.Limits = r.B()
+		ds.InsideHoriz = r.B()
+		ds.OutsideHoriz = r.B()
+		ds.SuppressExt1 = r.B()
+		ds.SuppressExt2 = r.B()
+		r.B() // DIMALT
+		ds.LineInside = r.B()
+		ds.SeparateArrow = r.B()
+		ds.TextInside = r.B()
+		ds.SuppressOut = r.B()
+		r.RC() // DIMALTD
+		ds.ZeroSuppress = int(r.RC())
+		ds.SuppressLine1 = r.B()
+		ds.SuppressLine2 = r.B()
+		ds.TolJust = int(r.RC())
+		ds.TextJust = int(r.RC())
+		r.RC() // DIMFIT
+		r.B()  // DIMUPT
+		ds.TolZeroSupp = int(r.RC())
+		r.RC() // DIMALTZ
+		r.RC() // DIMALTTZ
+		ds.TextAbove = int(r.RC())
+		ds.LinearUnits = int(r.BS())
+		ds.AngUnits = int(r.BS())
+		ds.Decimals = int(r.BS())
+		ds.TolDecimals = int(r.BS())
+		r.BS() // DIMALTU
+		r.BS() // DIMALTTD
+		ds.Scale = r.BD()
+		ds.ArrowSize = r.BD()
+		ds.ExtOffset = r.BD()
+		r.BD() // DIMDLI
+		ds.ExtExtend = r.BD()
+		ds.Round = r.BD()
+		ds.LineExtend = r.BD()
+		ds.TolPlus = r.BD()
+		ds.TolMinus = r.BD()
+		ds.TextHeight = r.BD()
+		ds.CenterMark = r.BD()
+		ds.TickSize = r.BD()
+		r.BD() // DIMALTF
+		ds.LinearFactor = r.BD()
+		ds.TextVertPos = r.BD()
+		ds.TolScale = r.BD()
+		ds.Gap = r.BD()
+		ds.Post = st.T()
+		st.T() // DIMAPOST
+		ds.Arrow = st.T()
+		ds.Arrow1 = st.T()
+		ds.Arrow2 = st.T()
+		ds.LineColor = drawing.Color{Index: r.BS()}
+		ds.ExtColor = drawing.Color{Index: r.BS()}
+		ds.TextColor = drawing.Color{Index: r.BS()}
+	} else {
+		ds.Post = st.T()
+		st.T() // DIMAPOST
+		ds.Scale = r.BD()
+		ds.ArrowSize = r.BD()
+		ds.ExtOffset = r.BD()
+		r.BD() // DIMDLI
+		ds.ExtExtend = r.BD()
+		ds.Round = r.BD()
+		ds.LineExtend = r.BD()
+		ds.TolPlus = r.BD()
+		ds.TolMinus = r.BD()
+		if f.ver >= r2007 {
+			r.BD()   // DIMFXL
+			r.BD()   // DIMJOGANG
+			r.BS()   // DIMTFILL
+			st.CMC() // DIMTFILLCLR
+		}
+		ds.Tol = r.B()
+		ds.Limits = r.B()
+		ds.InsideHoriz = r.B()
+		ds.OutsideHoriz = r.B()
+		ds.SuppressExt1 = r.B()
+		ds.SuppressExt2 = r.B()
+		ds.TextAbove = int(r.BS())
+		ds.ZeroSuppress = int(r.BS())
+		ds.AngZeroSupp = int(r.BS())
+		if f.ver >= r2007 {
+			ds.ArcSymbol = int(r.BS())
+		}
+		ds.TextHeight = r.BD()
+		ds.CenterMark = r.BD()
+		ds.TickSize = r.BD()
+		r.BD() // DIMALTF
+		ds.LinearFactor = r.BD()
+		ds.TextVertPos = r.BD()
+		ds.TolScale = r.BD()
+		ds.Gap = r.BD()
+		r.BD() // DIMALTRND
+		r.B()  // DIMALT
+		r.BS() // DIMALTD
+		ds.LineInside = r.B()
+		ds.SeparateArrow = r.B()
+		ds.TextInside = r.B()
+		ds.SuppressOut = r.B()
+		ds.LineColor = st.CMC()
+		ds.ExtColor = st.CMC()
+		ds.TextColor = st.CMC()
+		ds.AngDecimals = int(r.BS())
+		ds.Decimals = int(r.BS())
+		ds.TolDecimals = int(r.BS())
+		r.BS() // DIMALTU
+		r.BS() // DIMALTTD
+		ds.AngUnits = int(r.BS())
+		ds.Fraction = int(r.BS())
+		ds.LinearUnits = int(r.BS())
+		if sep := r.BS(); sep > 0 {
+			ds.DecimalSep = rune(sep)
+		}
+		ds.TextMove = int(r.BS())
+		ds.TextJust = int(r.BS())
+		ds.SuppressLine1 = r.B()
+		ds.SuppressLine2 = r.B()
+		ds.TolJust = int(r.BS())
+		ds.TolZeroSupp = int(r.BS())
+		r.BS() // DIMALTZ
+		r.BS() // DIMALTTZ
+		r.B()  // DIMUPT
+		ds.Fit = int(r.BS())
+		if f.ver >= r2007 {
+			r.B() // DIMFXLON
+		}
+		if f.ver >= r2010 {
+			r.B()  // DIMTXTDIRECTION
+			r.BD() // DIMALTMZF
+			st.T() // DIMALTMZS
+			r.BD() // DIMMZF
+			st.T() // DIMMZS
+		}
+		ds.LineWeight = drawing.Lineweight(r.BS())
+		ds.ExtWeight = drawing.Lineweight(r.BS())
+	}
+	r.B() // unknown
+
+	rec := &dimStyleRecord{style: ds}
+	st.H() // xref
+	rec.textStyle = st.H()
+	if f.ver >= r2000 {
+		for i := range rec.arrows {
+			rec.arrows[i] = st.H()
+		}
+	}
+	o.rec = rec
+	return nil
+}
+
+func (f *file) decodeDimension(o *object, st *streams) error {
+	r := st.d
+	d := &drawing.Dimension{EntityProps: o.hdr.props}
+	if f.ver >= r2010 {
+		r.RC() // version
+	}
+	d.Extrusion = r.BD3()
+	text := r.RD2()
+	elevation := r.BD()
+	d.TextMidPoint = text.Vec3(elevation)
+	flags := r.RC()
+	if flags&1 == 0 {
+		d.Flags |= drawing.DimUserText
+	}
+	if flags&2 != 0 {
+		d.Flags |= drawing.DimUnique
+	}
+	d.Text = st.T()
+	d.TextRotation = r.BD()
+	d.HorizontalDir = r.BD()
+	r.BD3() // insertion scale
+	r.BD()  // insertion rotation
+	if f.ver >= r2000 {
+		r.BS() // attachment
+		r.BS() // line spacing style
+		r.BD() // line spacing factor
+		d.Measurement = r.BD()
+	}
+	if f.ver >= r2007 {
+		r.B() // unknown
+		r.B() // first arrow flipped
+		r.B() // second arrow flipped
+	}
+	r.RD2() // insertion point of clones
+
+	switch o.typ {
+	case typeDimOrdinate:
+		d.Type = drawing.DimOrdinate
+		d.DefPoint = r.BD3()
+		d.DefPoint2 = r.BD3()
+		d.DefPoint3 = r.BD3()
+		if r.RC()&1 != 0 {
+			d.Flags |= drawing.DimOrdinateX
+		}
+	case typeDimLinear, typeDimAligned:
+		d.Type = drawing.DimAligned
+		d.DefPoint2 = r.BD3()
+		d.DefPoint3 = r.BD3()
+		d.DefPoint = r.BD3()
+		d.Oblique = r.BD()
+		if o.typ == typeDimLinear {
+			d.Type = drawing.DimLinear
+			d.Rotation = r.BD()
+		}
+	case typeDimAng3Pt:
+		d.Type = drawing.DimAngular3Point
+		d.DefPoint = r.BD3()
+		d.DefPoint2 = r.BD3()
+		d.DefPoint3 = r.BD3()
+		d.DefPoint4 = r.BD3()
+	case typeDimAng2Ln:
+		d.Type = drawing.DimAngular
+		d.DefPoint5 = r.RD2().Vec3(elevation)
+		d.DefPoint2 = r.BD3()
+		d.DefPoint3 = r.BD3()
+		d.DefPoint4 = r.BD3()
+		d.DefPoint = r.BD3()
+	case typeDimRadius, typeDimDiameter:
+		d.Type = drawing.DimRadius
+		if o.typ == typeDimDiameter {
+			d.Type = drawing.DimDiameter
+		}
+		d.DefPoint = r.BD3()
+		d.DefPoint4 = r.BD3()
+		d.LeaderLength = r.BD()
+	case typeArcDimension:
+		d.Type = drawing.DimArcLength
+		d.DefPoint = r.BD3()
+		d.DefPoint2 = r.BD3()
+		d.DefPoint3 = r.BD3()
+		d.DefPoint4 = r.BD3()
+		r.B()   // partial
+		r.BD()  // start parameter
+		r.BD()  // end parameter
+		r.B()   // has leader
+		r.BD3() // leader start
+		r.BD3() // leader end
+	}
+	o.style = st.H()
+	o.block = st.H()
+	o.ent = d
+	return nil
+}
+
+// eedItem is one value of extended data, with its DXF group code and its
+// value formatted as in DXF. Points and reals are also kept as numbers.
+type eedItem struct {
+	code  int
+	value string
+	pt    geom.Vec3
+	real  float64
+}
+
+// items decodes extended data. Decoding stops at the first value of an
+// unknown type.
+func (e eedRecord) items(ver version, cp codepage) []eedItem {
+	var out []eedItem
+	b := e.data
+	for len(b) > 0 {
+		code := int(b[0])
+		b = b[1:]
+		var v string
+		var pt geom.Vec3
+		var real float64
+		n := 0
+		switch {
+		case code == 0:
+			if ver >= r2007 {
+				if len(b) < 2 {
+					return out
+				}
+				l := int(binary.LittleEndian.Uint16(b))
+				if len(b) < 2+2*l {
+					return out
+				}
+				u := make([]uint16, l)
+				for i := range u {
+					u[i] = binary.LittleEndian.Uint16(b[2+2*i:])
+				}
+				v, n = string(utf16.Decode(u)), 2+2*l
+			} else {
+				if len(b) < 3 || len(b) < 3+int(b[0]) {
+					return out
+				}
+				v, n = cp.decode(b[3:3+int(b[0])]), 3+int(b[0])
+			}
+		case code == 2:
+			v, n = "{", 1
+			if len(b) > 0 && b[0] != 0 {
+				v = "}"
+			}
+		case code == 3 || code == 5:
+			if len(b) < 8 {
+				return out
+			}
+			v, n = strconv.FormatUint(binary.LittleEndian.Uint64(b), 16), 8
+		case code == 4:
+			if len(b) < 1 {
+				return out
+			}
+			n = 1 + int(b[0])
+		case code >= 10 && code <= 17:
+			if len(b) < 24 {
+				return out
+			}
+			f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:])) }
+			pt, n = geom.Vec3{X: f(0), Y: f(1), Z: f(2)}, 24
+		case code >= 40 && code <= 42:
+			if len(b) < 8 {
+				return out
+			}
+			real = math.Float64frombits(binary.LittleEndian.Uint64(b))
+			v, n = strconv.FormatFloat(real, 'g', -1, 64), 8
+		case code == 70:
+			if len(b) < 2 {
+				return out
+			}
+			v, n = strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b)))), 2
+		case code == 71:
+			if len(b) < 4 {
+				return out
+			}
+			v, n = strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b)))), 4
+		default:
+			return out
+		}
+		if n > len(b) {
+			return out
+		}
+		out = append(out, eedItem{code: 1000 + code, value: v, pt: pt, real: real})
+		b = b[n:]
+	}
+	return out
+}
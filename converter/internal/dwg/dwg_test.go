@@ -0,0 +1,248 @@
+package dwg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+var update = flag.Bool("update", false, "rewrite the sample drawings in testdata")
+
+func readSample(t testing.TB, name, tag string) []byte {
+	t.Helper()
+	path := filepath.Join("testdata", name+".dwg")
+	if *update {
+		if err := os.WriteFile(path, writeSample(tag, false), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRead(t *testing.T) {
+	for _, v := range sampleVersions {
+		t.Run(v.name, func(t *testing.T) {
+			d, err := Read(bytes.NewReader(readSample(t, v.name, v.tag)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ver := versionTags[v.tag]
+			if d.Version != v.tag {
+				t.Errorf("version = %s, want %s", d.Version, v.tag)
+			}
+			if len(d.Warnings) != 0 {
+				t.Errorf("warnings = %q", d.Warnings)
+			}
+			checkHeader(t, ver, d.Header)
+			if l := d.Layer("Walls"); l == nil || l.Color.Index != 3 {
+				t.Errorf("layer Walls = %+v", l)
+			}
+			if ver >= r2004 && (d.Summary.Title != "Sample" || d.Summary.Author != "dwgtopdf") {
+				t.Errorf("summary = %+v", d.Summary)
+			}
+			checkEntities(t, ver, d.ModelSpace().Entities)
+		})
+	}
+}
+
+func checkHeader(t *testing.T, ver version, h drawing.Header) {
+	t.Helper()
+	if h.LTScale != 2.5 || h.TextSize != 3.5 || h.PDSize != 0.75 || h.CELTScale != 0.5 || h.PDMode != 3 {
+		t.Errorf("header scales = %v %v %v %v %v", h.LTScale, h.TextSize, h.PDSize, h.CELTScale, h.PDMode)
+	}
+	if !h.FillMode || !h.PSLTScale {
+		t.Errorf("header FILLMODE, PSLTSCALE = %v, %v", h.FillMode, h.PSLTScale)
+	}
+	if h.ExtMax != (geom.Vec3{X: 100, Y: 50}) || h.LimMax != (geom.Vec2{X: 420, Y: 297}) || h.PaperLimMax != (geom.Vec2{X: 12, Y: 9}) {
+		t.Errorf("header extents = %v %v %v", h.ExtMax, h.LimMax, h.PaperLimMax)
+	}
+	if !h.Created.Equal(julian(2460000, 0)) || !h.Updated.Equal(julian(2460100, 0)) {
+		t.Errorf("header dates = %v %v", h.Created, h.Updated)
+	}
+	if ver >= r2000 && h.InsUnits != 4 {
+		t.Errorf("header INSUNITS = %d", h.InsUnits)
+	}
+	if h.Encoding != charmap.Windows1252 {
+		t.Errorf("header encoding = %v", h.Encoding)
+	}
+}
+
+func checkEntities(t *testing.T, ver version, entities []drawing.Entity) {
+	t.Helper()
+	want := 3
+	if ver >= r14 {
+		want = 4
+	}
+	if len(entities) != want {
+		t.Fatalf("model space has %d entities, want %d", len(entities), want)
+	}
+
+	l, ok := entities[0].(*drawing.Line)
+	if !ok {
+		t.Fatalf("entity 0 is %T, want LINE", entities[0])
+	}
+	if l.Start != (geom.Vec3{X: 10, Y: 20}) || l.End != (geom.Vec3{X: 90, Y: 20}) || l.Layer != "Walls" || l.Color.Index != drawing.ColorByLayer {
+		t.Errorf("line = %v %v on %s color %d", l.Start, l.End, l.Layer, l.Color.Index)
+	}
+
+	c, ok := entities[1].(*drawing.Circle)
+	if !ok {
+		t.Fatalf("entity 1 is %T, want CIRCLE", entities[1])
+	}
+	if c.Center != (geom.Vec3{X: 50, Y: 25}) || c.Radius != 12.5 || c.Layer != "0" || c.Color.Index != 1 {
+		t.Errorf("circle = %v r %v on %s color %d", c.Center, c.Radius, c.Layer, c.Color.Index)
+	}
+
+	tx, ok := entities[2].(*drawing.Text)
+	if !ok {
+		t.Fatalf("entity 2 is %T, want TEXT", entities[2])
+	}
+	rotation := 0.0
+	if ver >= r2000 {
+		rotation = math.Pi / 2
+	}
+	if tx.Value != "Hello DWG" || tx.Height != 3.5 || tx.Rotation != rotation || tx.Position != (geom.Vec3{X: 5, Y: 40}) {
+		t.Errorf("text = %q height %v rotation %v at %v", tx.Value, tx.Height, tx.Rotation, tx.Position)
+	}
+
+	if ver < r14 {
+		return
+	}
+	p, ok := entities[3].(*drawing.LWPolyline)
+	if !ok {
+		t.Fatalf("entity 3 is %T, want LWPOLYLINE", entities[3])
+	}
+	if len(p.Vertices) != 3 || !p.Closed || p.Color.Index != 5 || p.Vertices[2].Position != (geom.Vec3{X: 30, Y: 15}) {
+		t.Errorf("polyline = %+v", p)
+	}
+}
+
+func TestReadSkipsBrokenObjects(t *testing.T) {
+	for _, v := range sampleVersions {
+		t.Run(v.name, func(t *testing.T) {
+			d, err := Decode(writeSample(v.tag, true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects := 6
+			if versionTags[v.tag] >= r14 {
+				objects = 7
+			}
+			want := fmt.Sprintf("1 of %d objects could not be decoded and were left out", objects+1)
+			if len(d.Warnings) != 1 || d.Warnings[0] != want {
+				t.Errorf("warnings = %q, want %q", d.Warnings, want)
+			}
+			checkEntities(t, versionTags[v.tag], d.ModelSpace().Entities)
+		})
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	for _, v := range sampleVersions {
+		t.Run(v.name, func(t *testing.T) {
+			data := readSample(t, v.name, v.tag)
+			for n := range len(data) {
+				if _, err := Decode(data[:n]); err == nil {
+					t.Fatalf("reading the first %d of %d bytes succeeded", n, len(data))
+				}
+			}
+		})
+	}
+}
+
+func TestReadRejectsHugeSizes(t *testing.T) {
+	le := binary.LittleEndian
+	tests := []struct {
+		name  string
+		tag   string
+		patch func(data []byte)
+	}{
+		{"r2004 section size", "AC1018", func(data []byte) {
+			typ := le.AppendUint32(nil, 0x4163003b)
+			sm := bytes.Index(data, typ) + 20
+			le.PutUint64(data[sm+20:], 1<<40)
+		}},
+		{"r2004 page size", "AC1018", func(data []byte) {
+			typ := le.AppendUint32(nil, 0x4163003b)
+			le.PutUint32(data[bytes.Index(data, typ)+4:], 0xffffffff)
+		}},
+		{"r2007 page map size", "AC1021", func(data []byte) {
+			// The file header is interleaved over three blocks.
+			for i, b := range le.AppendUint64(nil, 1<<40) {
+				idx := 32 + 11*8 + i
+				data[0x80+idx/239+idx%239*3] = b
+			}
+		}},
+		{"r2007 page map repeat", "AC1021", func(data []byte) {
+			for i, b := range le.AppendUint64(nil, math.MaxInt64) {
+				idx := 32 + 3*8 + i
+				data[0x80+idx/239+idx%239*3] = b
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := writeSample(tt.tag, false)
+			tt.patch(data)
+			if _, err := Decode(data); err == nil {
+				t.Fatal("decoding succeeded")
+			}
+		})
+	}
+}
+
+func TestDecompress2004(t *testing.T) {
+	// A literal run of four bytes, then five bytes copied from four back.
+	src := []byte{0x01, 'a', 'b', 'c', 'd', 0x6c, 0x00, 0x11}
+	got, err := decompress2004(src, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("abcdabcda\x00\x00\x00"); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := decompress2004([]byte{0x01, 'a', 'b', 'c', 'd', 0x6c, 0x08}, 12); err == nil {
+		t.Error("a copy from before the start succeeded")
+	}
+	if _, err := decompress2004(src, -1); err == nil {
+		t.Error("a negative size succeeded")
+	}
+}
+
+func TestDecompress2007(t *testing.T) {
+	// A literal run of eight bytes, then five bytes copied from four back.
+	src := []byte{0x00, 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 0x12, 0x03, 0x00}
+	got, err := decompress2007(src, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("abcdefghefghe\x00\x00\x00"); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := decompress2007(src, 10); err == nil {
+		t.Error("output past the size succeeded")
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, v := range sampleVersions {
+		f.Add(readSample(f, v.name, v.tag))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(data)
+	})
+}
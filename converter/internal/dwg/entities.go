@@ -0,0 +1,723 @@
+package dwg
+
+import (
+	"fmt"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+func (f *file) readText(st *streams, t *drawing.Text) {
+	r := st.d
+	t.WidthFactor = 1
+	if f.ver <= r14 {
+		elevation := r.BD()
+		t.Position = r.RD2().Vec3(elevation)
+		t.AlignPoint = r.RD2().Vec3(elevation)
+		t.Extrusion = r.BE()
+		t.Thickness = r.BT()
+		t.Oblique = r.BD()
+		t.Rotation = r.BD()
+		t.Height = r.BD()
+		t.WidthFactor = r.BD()
+		t.Value = st.T()
+		t.Generation = int(r.BS())
+		t.HAlign = int(r.BS())
+		t.VAlign = int(r.BS())
+	} else {
+		flags := r.RC()
+		var elevation float64
+		if flags&0x01 == 0 {
+			elevation = r.RD()
+		}
+		ins := r.RD2()
+		t.Position = ins.Vec3(elevation)
+		t.AlignPoint = t.Position
+		if flags&0x02 == 0 {
+			t.AlignPoint = r.DD2(ins).Vec3(elevation)
+		}
+		t.Extrusion = r.BE()
+		t.Thickness = r.BT()
+		if flags&0x04 == 0 {
+			t.Oblique = r.RD()
+		}
+		if flags&0x08 == 0 {
+			t.Rotation = r.RD()
+		}
+		t.Height = r.RD()
+		if flags&0x10 == 0 {
+			t.WidthFactor = r.RD()
+		}
+		t.Value = st.T()
+		if flags&0x20 == 0 {
+			t.Generation = int(r.BS())
+		}
+		if flags&0x40 == 0 {
+			t.HAlign = int(r.BS())
+		}
+		if flags&0x80 == 0 {
+			t.VAlign = int(r.BS())
+		}
+	}
+}
+
+func (f *file) decodeText(o *object, st *streams) error {
+	t := &drawing.Text{EntityProps: o.hdr.props}
+	f.readText(st, t)
+	o.style = st.H()
+	o.ent = t
+	return nil
+}
+
+// readAttribFields reads the fields ATTRIB and ATTDEF add to TEXT. It
+// returns false for R2018 multiline attributes, whose embedded MTEXT data
+// is not decoded.
+func (f *file) readAttribFields(st *streams) (tag string, flags int, ok bool) {
+	r := st.d
+	if f.ver >= r2010 {
+		r.RC() // version
+	}
+	if f.ver >= r2018 && r.RC() > 1 {
+		return "", 0, false
+	}
+	tag = st.T()
+	r.BS() // field length
+	flags = int(r.RC())
+	if f.ver >= r2007 {
+		r.B() // lock position
+	}
+	return tag, flags, true
+}
+
+func (f *file) decodeAttrib(o *object, st *streams) error {
+	a := &drawing.Attrib{Text: drawing.Text{EntityProps: o.hdr.props}}
+	f.readText(st, &a.Text)
+	tag, flags, ok := f.readAttribFields(st)
+	a.Tag, a.Flags = tag, flags
+	if ok {
+		o.style = st.H()
+	}
+	o.ent = a
+	return nil
+}
+
+func (f *file) decodeAttDef(o *object, st *streams) error {
+	a := &drawing.AttDef{Text: drawing.Text{EntityProps: o.hdr.props}}
+	f.readText(st, &a.Text)
+	tag, flags, ok := f.readAttribFields(st)
+	a.Tag, a.Flags = tag, flags
+	if ok {
+		if f.ver >= r2010 {
+			st.d.RC() // version
+		}
+		a.Prompt = st.T()
+		o.style = st.H()
+	}
+	o.ent = a
+	return nil
+}
+
+func (f *file) decodeInsert(o *object, st *streams) error {
+	r := st.d
+	ins := &drawing.Insert{EntityProps: o.hdr.props, Columns: 1, Rows: 1}
+	ins.Position = r.BD3()
+	if f.ver <= r14 {
+		ins.Scale = r.BD3()
+	} else {
+		switch r.BB() {
+		case 0:
+			x := r.RD()
+			ins.Scale = geom.Vec3{X: x, Y: r.DD(x), Z: r.DD(x)}
+		case 1:
+			ins.Scale = geom.Vec3{X: 1, Y: r.DD(1), Z: r.DD(1)}
+		case 2:
+			x := r.RD()
+			ins.Scale = geom.Vec3{X: x, Y: x, Z: x}
+		default:
+			ins.Scale = geom.Vec3{X: 1, Y: 1, Z: 1}
+		}
+	}
+	ins.Rotation = r.BD()
+	ins.Extrusion = r.BD3()
+	hasAttribs := r.B()
+	var owned int
+	if f.ver >= r2004 && hasAttribs {
+		owned = int(r.BL())
+	}
+	if o.typ == typeMInsert {
+		ins.Columns = int(r.BS())
+		ins.Rows = int(r.BS())
+		ins.ColumnSpacing = r.BD()
+		ins.RowSpacing = r.BD()
+	}
+	o.block = st.H()
+	if hasAttribs {
+		o.owned = f.ownedHandles(st, owned)
+	}
+	o.ent = ins
+	return nil
+}
+
+// ownedHandles reads the sub-entity references of inserts and polylines.
+// Before R2004 only the first and last entity are stored; those are not
+// returned since the children are then found through their owner.
+func (f *file) ownedHandles(st *streams, n int) []uint64 {
+	var out []uint64
+	if f.ver < r2004 {
+		st.H()
+		st.H()
+	} else {
+		if n < 0 || n > st.h.end-st.h.pos {
+			st.h.fail()
+			return nil
+		}
+		for i := 0; i < n && st.h.err == nil; i++ {
+			out = append(out, st.H())
+		}
+	}
+	st.H() // SEQEND
+	return out
+}
+
+func (f *file) decodeVertex(o *object, st *streams) error {
+	r := st.d
+	v := drawing.Vertex{Flags: int(r.RC())}
+	v.Position = r.BD3()
+	if o.typ == typeVertex2D {
+		v.StartWidth = r.BD()
+		if v.StartWidth < 0 {
+			v.StartWidth = -v.StartWidth
+			v.EndWidth = v.StartWidth
+		} else {
+			v.EndWidth = r.BD()
+		}
+		v.Bulge = r.BD()
+		if f.ver >= r2010 {
+			r.BL() // vertex id
+		}
+		r.BD() // tangent direction
+	}
+	o.rec = v
+	return nil
+}
+
+func (f *file) decodeFaceRecord(o *object, st *streams) error {
+	var face [4]int
+	for i := range face {
+		face[i] = int(st.d.BS())
+	}
+	o.rec = face
+	return nil
+}
+
+func (f *file) decodePolyline(o *object, st *streams) error {
+	r := st.d
+	p := &drawing.Polyline{EntityProps: o.hdr.props, Extrusion: geom.ZAxis}
+	switch o.typ {
+	case typePolyline2D:
+		p.Flags = int(r.BS()) &^ (drawing.Polyline3D | drawing.PolylineMesh | drawing.PolylinePolyface)
+		r.BS() // curve type
+		p.StartWidth = r.BD()
+		p.EndWidth = r.BD()
+		p.Thickness = r.BT()
+		p.Elevation = r.BD()
+		p.Extrusion = r.BE()
+	case typePolyline3D:
+		r.RC() // spline flags
+		p.Flags = drawing.Polyline3D
+		if r.RC()&1 != 0 {
+			p.Flags |= drawing.PolylineClosed
+		}
+	case typePolylinePFace:
+		p.Flags = drawing.PolylinePolyface
+		p.MCount = int(r.BS())
+		p.NCount = int(r.BS())
+	case typePolylineMesh:
+		p.Flags = int(r.BS()) | drawing.PolylineMesh
+		r.BS() // curve type
+		p.MCount = int(r.BS())
+		p.NCount = int(r.BS())
+		r.BS() // M density
+		r.BS() // N density
+	}
+	var owned int
+	if f.ver >= r2004 {
+		owned = int(r.BL())
+	}
+	o.owned = f.ownedHandles(st, owned)
+	o.ent = p
+	return nil
+}
+
+func (f *file) decodeArc(o *object, st *streams) error {
+	r := st.d
+	a := &drawing.Arc{EntityProps: o.hdr.props}
+	a.Center = r.BD3()
+	a.Radius = r.BD()
+	a.Thickness = r.BT()
+	a.Extrusion = r.BE()
+	a.StartAngle = r.BD()
+	a.EndAngle = r.BD()
+	o.ent = a
+	return nil
+}
+
+func (f *file) decodeCircle(o *object, st *streams) error {
+	r := st.d
+	c := &drawing.Circle{EntityProps: o.hdr.props}
+	c.Center = r.BD3()
+	c.Radius = r.BD()
+	c.Thickness = r.BT()
+	c.Extrusion = r.BE()
+	o.ent = c
+	return nil
+}
+
+func (f *file) decodeLine(o *object, st *streams) error {
+	r := st.d
+	l := &drawing.Line{EntityProps: o.hdr.props}
+	if f.ver <= r14 {
+		l.Start = r.BD3()
+		l.End = r.BD3()
+	} else {
+		zZero := r.B()
+		l.Start.X = r.RD()
+		l.End.X = r.DD(l.Start.X)
+		l.Start.Y = r.RD()
+		l.End.Y = r.DD(l.Start.Y)
+		if !zZero {
+			l.Start.Z = r.RD()
+			l.End.Z = r.DD(l.Start.Z)
+		}
+	}
+	l.Thickness = r.BT()
+	l.Extrusion = r.BE()
+	o.ent = l
+	return nil
+}
+
+func (f *file) decodePoint(o *object, st *streams) error {
+	r := st.d
+	p := &drawing.Point{EntityProps: o.hdr.props}
+	p.Position = r.BD3()
+	p.Thickness = r.BT()
+	p.Extrusion = r.BE()
+	p.XAngle = r.BD()
+	o.ent = p
+	return nil
+}
+
+func (f *file) decodeFace3D(o *object, st *streams) error {
+	r := st.d
+	face := &drawing.Face3D{EntityProps: o.hdr.props}
+	if f.ver <= r14 {
+		for i := range face.Corners {
+			face.Corners[i] = r.BD3()
+		}
+		face.InvisibleEdges = int(r.BS())
+	} else {
+		noFlags := r.B()
+		zZero := r.B()
+		c := geom.Vec3{X: r.RD(), Y: r.RD()}
+		if !zZero {
+			c.Z = r.RD()
+		}
+		face.Corners[0] = c
+		for i := 1; i < 4; i++ {
+			face.Corners[i] = r.DD3(face.Corners[i-1])
+		}
+		if !noFlags {
+			face.InvisibleEdges = int(r.BS())
+		}
+	}
+	o.ent = face
+	return nil
+}
+
+func (f *file) decodeSolid(o *object, st *streams) error {
+	r := st.d
+	s := &drawing.Solid{EntityProps: o.hdr.props, Trace: o.typ == typeTrace}
+	s.Thickness = r.BT()
+	elevation := r.BD()
+	for i := range s.Corners {
+		s.Corners[i] = r.RD2().Vec3(elevation)
+	}
+	s.Extrusion = r.BE()
+	o.ent = s
+	return nil
+}
+
+func (f *file) decodeEllipse(o *object, st *streams) error {
+	r := st.d
+	e := &drawing.Ellipse{EntityProps: o.hdr.props}
+	e.Center = r.BD3()
+	e.MajorAxis = r.BD3()
+	e.Extrusion = r.BD3()
+	e.Ratio = r.BD()
+	e.StartParam = r.BD()
+	e.EndParam = r.BD()
+	o.ent = e
+	return nil
+}
+
+func (f *file) decodeSpline(o *object, st *streams) error {
+	r := st.d
+	s := &drawing.Spline{EntityProps: o.hdr.props, Normal: geom.ZAxis}
+	scenario := r.BL()
+	if f.ver >= r2013 {
+		flags := r.BL()
+		knotParam := r.BL()
+		if flags&1 != 0 {
+			scenario = 2
+		}
+		if knotParam == 15 {
+			scenario = 1
+		}
+	}
+	s.Degree = int(r.BL())
+	var numKnots, numControl, numFit int
+	var weighted bool
+	if scenario&2 != 0 {
+		r.BD() // fit tolerance
+		s.StartTangent = r.BD3()
+		s.EndTangent = r.BD3()
+		numFit = int(r.BL())
+	}
+	if scenario&1 != 0 {
+		if r.B() {
+			s.Flags |= drawing.SplineRational
+		}
+		if r.B() {
+			s.Flags |= drawing.SplineClosed
+		}
+		if r.B() {
+			s.Flags |= drawing.SplinePeriodic
+		}
+		r.BD() // knot tolerance
+		r.BD() // control point tolerance
+		numKnots = int(r.BL())
+		numControl = int(r.BL())
+		weighted = r.B()
+	}
+	if numKnots < 0 || numControl < 0 || numFit < 0 || numKnots+numControl+numFit > r.end-r.pos {
+		return errShortRead
+	}
+	s.Knots = make([]float64, numKnots)
+	for i := range s.Knots {
+		s.Knots[i] = r.BD()
+	}
+	s.Control = make([]geom.Vec3, numControl)
+	for i := range s.Control {
+		s.Control[i] = r.BD3()
+		if weighted {
+			s.Weights = append(s.Weights, r.BD())
+		}
+	}
+	s.Fit = make([]geom.Vec3, numFit)
+	for i := range s.Fit {
+		s.Fit[i] = r.BD3()
+	}
+	o.ent = s
+	return nil
+}
+
+func (f *file) decodeRay(o *object, st *streams) error {
+	r := st.d
+	base, dir := r.BD3(), r.BD3()
+	if o.typ == typeRay {
+		o.ent = &drawing.Ray{EntityProps: o.hdr.props, Base: base, Direction: dir}
+	} else {
+		o.ent = &drawing.XLine{EntityProps: o.hdr.props, Base: base, Direction: dir}
+	}
+	return nil
+}
+
+func (f *file) decodeMText(o *object, st *streams) error {
+	r := st.d
+	m := &drawing.MText{EntityProps: o.hdr.props, LineSpacingFactor: 1}
+	m.Position = r.BD3()
+	m.Extrusion = r.BD3()
+	m.XDirection = r.BD3()
+	m.RectWidth = r.BD()
+	if f.ver >= r2007 {
+		m.RectHeight = r.BD()
+	}
+	m.Height = r.BD()
+	m.Attachment = int(r.BS())
+	m.FlowDirection = int(r.BS())
+	r.BD() // extents height
+	r.BD() // extents width
+	m.Value = st.T()
+	if f.ver >= r2000 {
+		m.LineSpacingStyle = int(r.BS())
+		m.LineSpacingFactor = r.BD()
+		r.B()
+	}
+	if f.ver >= r2004 {
+		fill := r.BL()
+		if fill&1 != 0 || (f.ver >= r2018 && fill&0x10 != 0) {
+			r.BL()   // fill scale
+			st.CMC() // fill color
+			r.BL()   // fill transparency
+		}
+	}
+	o.style = st.H()
+	if f.ver >= r2018 && !r.B() {
+		r.BS() // version
+		r.B()  // default flag
+		st.H() // registered application
+		r.BL() // attachment
+		r.BD3()
+		r.BD3()
+		r.BD() // rect width
+		r.BD() // rect height
+		r.BD() // extents width
+		r.BD() // extents height
+		m.ColumnType = int(r.BS())
+		if m.ColumnType != 0 {
+			n := int(r.BL())
+			m.ColumnCount = n
+			m.ColumnWidth = r.BD()
+			m.ColumnGutter = r.BD()
+			auto := r.B()
+			r.B() // flow reversed
+			if !auto && m.ColumnType == 2 && n >= 0 && n < r.end-r.pos {
+				m.ColumnHeights = make([]float64, n)
+				for i := range m.ColumnHeights {
+					m.ColumnHeights[i] = r.BD()
+				}
+			}
+		}
+	}
+	o.ent = m
+	return nil
+}
+
+func (f *file) decodeLWPolyline(o *object, st *streams) error {
+	r := st.d
+	p := &drawing.LWPolyline{EntityProps: o.hdr.props, Extrusion: geom.ZAxis}
+	flags := int(r.BS())
+	if flags&4 != 0 {
+		p.ConstWidth = r.BD()
+	}
+	if flags&8 != 0 {
+		p.Elevation = r.BD()
+	}
+	if flags&2 != 0 {
+		p.Thickness = r.BD()
+	}
+	if flags&1 != 0 {
+		p.Extrusion = r.BD3()
+	}
+	numPoints := int(r.BL())
+	var numBulges, numIDs, numWidths int
+	if flags&16 != 0 {
+		numBulges = int(r.BL())
+	}
+	if f.ver >= r2010 && flags&1024 != 0 {
+		numIDs = int(r.BL())
+	}
+	if flags&32 != 0 {
+		numWidths = int(r.BL())
+	}
+	p.Closed = flags&512 != 0
+	p.Plinegen = flags&256 != 0
+	if numPoints < 0 || numBulges < 0 || numIDs < 0 || numWidths < 0 ||
+		numPoints+numBulges+numIDs+numWidths > r.end-r.pos {
+		return errShortRead
+	}
+	p.Vertices = make([]drawing.Vertex, numPoints)
+	var prev geom.Vec2
+	for i := range p.Vertices {
+		var pt geom.Vec2
+		if f.ver <= r14 || i == 0 {
+			pt = r.RD2()
+		} else {
+			pt = r.DD2(prev)
+		}
+		prev = pt
+		p.Vertices[i].Position = pt.Vec3(p.Elevation)
+		p.Vertices[i].StartWidth = p.ConstWidth
+		p.Vertices[i].EndWidth = p.ConstWidth
+	}
+	for i := 0; i < numBulges && r.err == nil; i++ {
+		b := r.BD()
+		if i < numPoints {
+			p.Vertices[i].Bulge = b
+		}
+	}
+	for i := 0; i < numIDs && r.err == nil; i++ {
+		r.BL()
+	}
+	for i := 0; i < numWidths && r.err == nil; i++ {
+		sw, ew := r.BD(), r.BD()
+		if i < numPoints {
+			p.Vertices[i].StartWidth = sw
+			p.Vertices[i].EndWidth = ew
+		}
+	}
+	o.ent = p
+	return nil
+}
+
+func (f *file) decodeHatch(o *object, st *streams) error {
+	r := st.d
+	h := &drawing.Hatch{EntityProps: o.hdr.props, Scale: 1}
+	// short reports a count that cannot fit in what is left of the object.
+	short := func(n int) bool { return n < 0 || n > r.end-r.pos }
+	if f.ver >= r2004 {
+		g := &drawing.Gradient{}
+		gradient := r.BL() != 0
+		r.BL() // reserved
+		g.Angle = r.BD()
+		g.Shift = r.BD()
+		g.SingleColor = r.BL() != 0
+		g.Tint = r.BD()
+		n := int(r.BL())
+		if short(n) {
+			return errShortRead
+		}
+		for i := 0; i < n && r.err == nil; i++ {
+			r.BD() // shift value
+			g.Colors = append(g.Colors, st.CMC())
+		}
+		g.Name = st.T()
+		if gradient {
+			h.Gradient = g
+		}
+	}
+	h.Elevation = r.BD()
+	h.Extrusion = r.BD3()
+	h.Pattern = st.T()
+	h.Solid = r.B()
+	h.Associative = r.B()
+	numLoops := int(r.BL())
+	if short(numLoops) {
+		return errShortRead
+	}
+	derived := false
+	for i := 0; i < numLoops && r.err == nil; i++ {
+		l := drawing.HatchLoop{Flags: int(r.BL())}
+		derived = derived || l.Flags&drawing.HatchLoopDerived != 0
+		if l.Flags&drawing.HatchLoopPolyline != 0 {
+			bulges := r.B()
+			r.B() // closed
+			n := int(r.BL())
+			if short(n) {
+				return errShortRead
+			}
+			l.Vertices = make([]drawing.Vertex, n)
+			for i := range l.Vertices {
+				l.Vertices[i].Position = r.RD2().Vec3(0)
+				if bulges {
+					l.Vertices[i].Bulge = r.BD()
+				}
+			}
+		} else {
+			n := int(r.BL())
+			if short(n) {
+				return errShortRead
+			}
+			for i := 0; i < n && r.err == nil; i++ {
+				e, err := f.hatchEdge(r)
+				if err != nil {
+					return err
+				}
+				l.Edges = append(l.Edges, e)
+			}
+		}
+		r.BL() // source boundary objects, in the handle stream
+		h.Loops = append(h.Loops, l)
+	}
+	h.Style = int(r.BS())
+	h.PatternType = int(r.BS())
+	if !h.Solid {
+		h.Angle = r.BD()
+		h.Scale = r.BD()
+		h.Double = r.B()
+		n := int(r.BS())
+		if short(n) {
+			return errShortRead
+		}
+		for range n {
+			l := drawing.HatchLine{Angle: r.BD(), Base: r.BD2(), Offset: r.BD2()}
+			dashes := int(r.BS())
+			if short(dashes) {
+				return errShortRead
+			}
+			for range dashes {
+				l.Dashes = append(l.Dashes, r.BD())
+			}
+			h.Lines = append(h.Lines, l)
+		}
+	}
+	if derived {
+		r.BD() // pixel size
+	}
+	o.ent = h
+	return nil
+}
+
+func (f *file) hatchEdge(r *bitReader) (drawing.HatchEdge, error) {
+	e := drawing.HatchEdge{Type: int(r.RC()), CCW: true}
+	switch e.Type {
+	case drawing.EdgeLine:
+		e.Start = r.RD2()
+		e.End = r.RD2()
+	case drawing.EdgeArc:
+		e.Center = r.RD2()
+		e.Radius = r.BD()
+		e.StartAngle = r.BD()
+		e.EndAngle = r.BD()
+		e.CCW = r.B()
+	case drawing.EdgeEllipse:
+		e.Center = r.RD2()
+		e.MajorAxis = r.RD2()
+		e.Ratio = r.BD()
+		e.StartAngle = r.BD()
+		e.EndAngle = r.BD()
+		e.CCW = r.B()
+	case drawing.EdgeSpline:
+		s := &drawing.Spline{Degree: int(r.BL()), Normal: geom.ZAxis}
+		rational := r.B()
+		if rational {
+			s.Flags |= drawing.SplineRational
+		}
+		if r.B() {
+			s.Flags |= drawing.SplinePeriodic
+		}
+		numKnots, numControl := int(r.BL()), int(r.BL())
+		if numKnots < 0 || numControl < 0 || numKnots+numControl > r.end-r.pos {
+			return e, errShortRead
+		}
+		s.Knots = make([]float64, numKnots)
+		for i := range s.Knots {
+			s.Knots[i] = r.BD()
+		}
+		s.Control = make([]geom.Vec3, numControl)
+		for i := range s.Control {
+			s.Control[i] = r.RD2().Vec3(0)
+			if rational {
+				s.Weights = append(s.Weights, r.BD())
+			}
+		}
+		if f.ver >= r2010 {
+			n := int(r.BL())
+			if n < 0 || n > r.end-r.pos {
+				return e, errShortRead
+			}
+			if n > 0 {
+				s.Fit = make([]geom.Vec3, n)
+				for i := range s.Fit {
+					s.Fit[i] = r.RD2().Vec3(0)
+				}
+				s.StartTangent = r.RD2().Vec3(0)
+				s.EndTangent = r.RD2().Vec3(0)
+			}
+		}
+		e.Spline = s
+	default:
+		return e, fmt.Errorf("hatch edge type %d", e.Type)
+	}
+	return e, nil
+}
@@ -0,0 +1,344 @@
+package dwg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+)
+
+type version int
+
+const (
+	r13 version = iota
+	r14
+	r2000
+	r2004
+	r2007
+	r2010
+	r2013
+	r2018
+)
+
+var versionTags = map[string]version{
+	"AC1012": r13,
+	"AC1014": r14,
+	"AC1015": r2000,
+	"AC1018": r2004,
+	"AC1021": r2007,
+	"AC1024": r2010,
+	"AC1027": r2013,
+	"AC1032": r2018,
+}
+
+var (
+	ErrNotDWG             = errors.New("not a DWG file")
+	ErrUnsupportedVersion = errors.New("unsupported DWG version")
+)
+
+const (
+	sectionHeader  = "AcDb:Header"
+	sectionClasses = "AcDb:Classes"
+	sectionHandles = "AcDb:Handles"
+	sectionObjects = "AcDb:AcDbObjects"
+)
+
+// A corrupt size field must not make the reader allocate far more than
+// the file can hold. Compressed pages rarely expand beyond maxExpansion
+// times the input, so decoded sections are held to that, with a floor for
+// small files and a ceiling for all.
+const (
+	maxExpansion = 64
+	minLimit     = 16 << 20
+	maxSection   = 1 << 30
+)
+
+type file struct {
+	data  []byte
+	tag   string
+	ver   version
+	maint uint8
+	cp    codepage
+
+	sections map[string][]byte
+}
+
+// Version returns the release tag at the start of a DWG file, e.g. AC1027.
+func Version(data []byte) (string, error) {
+	if len(data) < 6 || !bytes.HasPrefix(data, []byte("AC")) {
+		return "", ErrNotDWG
+	}
+	tag := string(data[:6])
+	if _, ok := versionTags[tag]; !ok {
+		return tag, fmt.Errorf("%w: %s", ErrUnsupportedVersion, tag)
+	}
+	return tag, nil
+}
+
+// Read parses a DWG file from R13 (AC1012) to R2018 (AC1032).
+func Read(r io.Reader) (*drawing.Drawing, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read dwg: %w", err)
+	}
+	return Decode(data)
+}
+
+func Decode(data []byte) (*drawing.Drawing, error) {
+	f, err := open(data)
+	if err != nil {
+		return nil, err
+	}
+	return f.decode()
+}
+
+func open(data []byte) (*file, error) {
+	tag, err := Version(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 0x100 {
+		return nil, fmt.Errorf("%w: file too short", ErrNotDWG)
+	}
+	f := &file{
+		data:     data,
+		tag:      tag,
+		ver:      versionTags[tag],
+		maint:    data[0x0b],
+		cp:       codepage(binary.LittleEndian.Uint16(data[0x13:])),
+		sections: make(map[string][]byte),
+	}
+
+	switch {
+	case f.ver <= r2000:
+		err = f.readLocators()
+	case f.ver == r2007:
+		err = f.read2007()
+	default:
+		err = f.read2004()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dwg %s: %w", tag, err)
+	}
+	return f, nil
+}
+
+// readLocators reads the section locator records of R13-R2000 files.
+func (f *file) readLocators() error {
+	n := int(binary.LittleEndian.Uint32(f.data[0x15:]))
+	if n < 3 || 0x19+n*9 > len(f.data) {
+		return fmt.Errorf("bad section locator count %d", n)
+	}
+	names := map[uint8]string{0: sectionHeader, 1: sectionClasses, 2: sectionHandles}
+	for i := range n {
+		rec := f.data[0x19+i*9:]
+		num := rec[0]
+		addr := int(binary.LittleEndian.Uint32(rec[1:]))
+		size := int(binary.LittleEndian.Uint32(rec[5:]))
+		name, ok := names[num]
+		if !ok {
+			continue
+		}
+		if addr < 0 || size < 0 || addr+size > len(f.data) {
+			return fmt.Errorf("section %s out of range", name)
+		}
+		f.sections[name] = f.data[addr : addr+size]
+	}
+	// Object offsets in the handle map are absolute file positions.
+	f.sections[sectionObjects] = f.data
+	return nil
+}
+
+// limit returns the largest size accepted for a decoded page or section.
+func (f *file) limit() int {
+	return min(max(len(f.data)*maxExpansion, minLimit), maxSection)
+}
+
+func (f *file) section(name string) ([]byte, error) {
+	s, ok := f.sections[name]
+	if !ok {
+		return nil, fmt.Errorf("missing section %s", name)
+	}
+	return s, nil
+}
+
+type r2004Section struct {
+	name       string
+	size       int
+	maxSize    int
+	compressed bool
+	encrypted  bool
+	pages      []r2004Page
+}
+
+type r2004Page struct {
+	number int32
+	size   int
+	offset int
+}
+
+func decryptHeader2004(b []byte) []byte {
+	out := make([]byte, len(b))
+	seed := uint32(1)
+	for i := range b {
+		seed = seed*0x343fd + 0x269ec3
+		out[i] = b[i] ^ byte(seed>>16)
+	}
+	return out
+}
+
+func (f *file) read2004() error {
+	if len(f.data) < 0x80+0x6c {
+		return errors.New("file header truncated")
+	}
+	hdr := decryptHeader2004(f.data[0x80 : 0x80+0x6c])
+	if !bytes.HasPrefix(hdr, []byte("AcFssFcAJMB")) {
+		return errors.New("bad file header signature")
+	}
+	le := binary.LittleEndian
+	pageMapAddr := int(le.Uint64(hdr[0x54:])) + 0x100
+	sectionMapID := int32(le.Uint32(hdr[0x5c:]))
+
+	pm, err := f.systemPage2004(pageMapAddr, 0x41630e3b)
+	if err != nil {
+		return fmt.Errorf("page map: %w", err)
+	}
+	pages := make(map[int32]int)
+	addr := 0x100
+	for p := 0; p+8 <= len(pm); {
+		num := int32(le.Uint32(pm[p:]))
+		size := int(le.Uint32(pm[p+4:]))
+		p += 8
+		if num < 0 {
+			p += 16
+		} else {
+			pages[num] = addr
+		}
+		addr += size
+	}
+
+	smAddr, ok := pages[sectionMapID]
+	if !ok {
+		return errors.New("section map page not found")
+	}
+	sm, err := f.systemPage2004(smAddr, 0x4163003b)
+	if err != nil {
+		return fmt.Errorf("section map: %w", err)
+	}
+	if len(sm) < 20 {
+		return errors.New("section map truncated")
+	}
+	count := int(le.Uint32(sm))
+	p := 20
+	for range count {
+		if p+96 > len(sm) {
+			return errors.New("section map truncated")
+		}
+		s := r2004Section{
+			size:       int(le.Uint64(sm[p:])),
+			maxSize:    int(le.Uint32(sm[p+12:])),
+			compressed: le.Uint32(sm[p+20:]) == 2,
+			encrypted:  le.Uint32(sm[p+28:]) == 1,
+			name:       string(bytes.TrimRight(sm[p+32:p+96], "\x00")),
+		}
+		n := int(le.Uint32(sm[p+8:]))
+		p += 96
+		for range n {
+			if p+16 > len(sm) {
+				return errors.New("section map truncated")
+			}
+			s.pages = append(s.pages, r2004Page{
+				number: int32(le.Uint32(sm[p:])),
+				size:   int(le.Uint32(sm[p+4:])),
+				offset: int(le.Uint64(sm[p+8:])),
+			})
+			p += 16
+		}
+		if s.name == "" || s.encrypted {
+			continue
+		}
+		data, err := f.dataSection2004(s, pages)
+		if err != nil {
+			return fmt.Errorf("section %s: %w", s.name, err)
+		}
+		f.sections[s.name] = data
+	}
+	return nil
+}
+
+func (f *file) systemPage2004(addr int, typ uint32) ([]byte, error) {
+	if addr < 0 || addr > len(f.data)-20 {
+		return nil, errShortRead
+	}
+	le := binary.LittleEndian
+	h := f.data[addr:]
+	if le.Uint32(h) != typ {
+		return nil, fmt.Errorf("bad page type %#x", le.Uint32(h))
+	}
+	decomp := int(le.Uint32(h[4:]))
+	comp := int(le.Uint32(h[8:]))
+	if comp > len(f.data)-addr-20 {
+		return nil, errShortRead
+	}
+	if decomp > f.limit() {
+		return nil, fmt.Errorf("page size %d too large", decomp)
+	}
+	src := f.data[addr+20 : addr+20+comp]
+	if le.Uint32(h[12:]) != 2 {
+		return src, nil
+	}
+	return decompress2004(src, decomp)
+}
+
+func (f *file) dataSection2004(s r2004Section, pages map[int32]int) ([]byte, error) {
+	le := binary.LittleEndian
+	limit := f.limit()
+	if s.size < 0 || s.size > limit || s.maxSize > limit {
+		return nil, fmt.Errorf("size %d too large", max(s.size, s.maxSize))
+	}
+	size := s.size
+	for _, pg := range s.pages {
+		if pg.offset < 0 || pg.offset > limit-s.maxSize {
+			return nil, fmt.Errorf("page %d out of range", pg.number)
+		}
+		if end := pg.offset + s.maxSize; end > size {
+			size = end
+		}
+	}
+	out := make([]byte, size)
+	for _, pg := range s.pages {
+		addr, ok := pages[pg.number]
+		if !ok {
+			return nil, fmt.Errorf("page %d not found", pg.number)
+		}
+		if addr < 0 || addr+32 > len(f.data) {
+			return nil, errShortRead
+		}
+		mask := 0x4164536b ^ uint32(addr)
+		var hdr [8]uint32
+		for i := range hdr {
+			hdr[i] = le.Uint32(f.data[addr+i*4:]) ^ mask
+		}
+		compSize := int(hdr[2])
+		if compSize > len(f.data)-addr-32 || pg.offset > len(out) {
+			return nil, errShortRead
+		}
+		src := f.data[addr+32 : addr+32+compSize]
+		if s.compressed {
+			dec, err := decompress2004(src, s.maxSize)
+			if err != nil {
+				return nil, err
+			}
+			copy(out[pg.offset:], dec)
+		} else {
+			copy(out[pg.offset:], src)
+		}
+	}
+	if s.size > 0 && s.size < len(out) {
+		out = out[:s.size]
+	}
+	return out, nil
+}
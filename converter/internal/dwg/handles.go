@@ -0,0 +1,54 @@
+package dwg
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// readHandles decodes the object map: handle to offset of the object
+// record. Offsets are returned in handle order.
+func (f *file) readHandles() ([]objectRef, error) {
+	sec, err := f.section(sectionHandles)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uint64]int)
+	var refs []objectRef
+	p := 0
+	for p+2 <= len(sec) {
+		size := int(binary.BigEndian.Uint16(sec[p:]))
+		if size <= 2 {
+			break
+		}
+		end := p + size
+		if end > len(sec) {
+			return refs, errors.New("object map truncated")
+		}
+		r := newBitReader(sec[:end], f.ver, f.cp)
+		r.pos = (p + 2) * 8
+		var handle uint64
+		var loc int64
+		for r.pos < end*8 && r.err == nil {
+			handle += r.UMC()
+			loc += r.MC()
+			if r.err != nil {
+				break
+			}
+			if i, ok := seen[handle]; ok {
+				refs[i].offset = loc
+				continue
+			}
+			seen[handle] = len(refs)
+			refs = append(refs, objectRef{handle: handle, offset: loc})
+		}
+		p = end + 2 // CRC
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].handle < refs[j].handle })
+	return refs, nil
+}
+
+type objectRef struct {
+	handle uint64
+	offset int64
+}
@@ -0,0 +1,352 @@
+package dwg
+
+import (
+	"time"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+)
+
+// headerRefs keeps the handles from the header that are resolved after
+// the objects have been decoded.
+type headerRefs struct {
+	textStyle    uint64
+	namedObjects uint64
+	layouts      uint64
+	paperSpace   uint64
+	modelSpace   uint64
+}
+
+func julian(days, ms int32) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	sec := (int64(days)-2440588)*86400 + int64(ms)/1000
+	return time.Unix(sec, 0).UTC()
+}
+
+// readHeader decodes the header variables section. The variables are a
+// fixed, version dependent sequence, so every field up to the last one of
+// interest has to be consumed.
+func (f *file) readHeader(h *drawing.Header) (headerRefs, error) {
+	var refs headerRefs
+	sec, err := f.section(sectionHeader)
+	if err != nil {
+		return refs, err
+	}
+	r := newBitReader(sec, f.ver, f.cp)
+	r.sentinel()
+	r.RL()
+	if f.ver >= r2010 && f.maint > 3 {
+		r.RL()
+	}
+	st := &streams{d: r, s: r, h: r}
+	if f.ver >= r2007 {
+		start := r.pos
+		end := start + int(r.RL())
+		st.h = r.sub(end, r.end)
+		st.s = stringStream(r, end)
+	}
+	v := f.ver
+	if v >= r2013 {
+		r.BLL()
+	}
+
+	for range 4 {
+		r.BD()
+	}
+	for range 4 {
+		st.T()
+	}
+	r.BL()
+	r.BL()
+	if v <= r14 {
+		r.BS()
+	}
+	if v < r2004 {
+		st.H()
+	}
+	r.B() // DIMASO
+	r.B() // DIMSHO
+	if v <= r14 {
+		r.B() // DIMSAV
+	}
+	r.B() // PLINEGEN
+	r.B() // ORTHOMODE
+	r.B() // REGENMODE
+	h.FillMode = r.B()
+	r.B() // QTEXTMODE
+	h.PSLTScale = r.B()
+	r.B() // LIMCHECK
+	if v <= r14 {
+		r.B() // BLIPMODE
+	}
+	if v >= r2004 {
+		r.B()
+	}
+	r.B() // USRTIMER
+	r.B() // SKPOLY
+	r.B() // ANGDIR
+	r.B() // SPLFRAME
+	if v <= r14 {
+		r.B() // ATTREQ
+		r.B() // ATTDIA
+	}
+	r.B() // MIRRTEXT
+	r.B() // WORLDVIEW
+	if v <= r14 {
+		r.B() // WIREFRAME
+	}
+	r.B() // TILEMODE
+	r.B() // PLIMCHECK
+	r.B() // VISRETAIN
+	if v <= r14 {
+		r.B() // DELOBJ
+	}
+	r.B()  // DISPSILH
+	r.B()  // PELLIPSE
+	r.BS() // PROXYGRAPHICS
+	if v <= r14 {
+		r.BS() // DRAGMODE
+	}
+	for range 5 { // TREEDEPTH LUNITS LUPREC AUNITS AUPREC
+		r.BS()
+	}
+	if v <= r14 {
+		r.BS() // OSMODE
+	}
+	r.BS() // ATTMODE
+	if v <= r14 {
+		r.BS() // COORDS
+	}
+	h.PDMode = int(r.BS())
+	if v <= r14 {
+		r.BS() // PICKSTYLE
+	}
+	if v >= r2004 {
+		r.BL()
+		r.BL()
+		r.BL()
+	}
+	for range 5 + 14 { // USERI1-5, SPLINESEGS .. TEXTQLTY
+		r.BS()
+	}
+	h.LTScale = r.BD()
+	h.TextSize = r.BD()
+	for range 5 { // TRACEWID SKETCHINC FILLETRAD THICKNESS ANGBASE
+		r.BD()
+	}
+	h.PDSize = r.BD()
+	for range 1 + 5 + 4 + 2 { // PLINEWID USERR1-5 CHAMFERA-D FACETRES CMLSCALE
+		r.BD()
+	}
+	h.CELTScale = r.BD()
+	if v < r2007 {
+		st.T() // MENUNAME
+	}
+	h.Created = julian(r.BL(), r.BL())
+	h.Updated = julian(r.BL(), r.BL())
+	if v >= r2004 {
+		r.BL()
+		r.BL()
+		r.BL()
+	}
+	r.BL() // TDINDWG
+	r.BL()
+	r.BL() // TDUSRTIMER
+	r.BL()
+	st.CMC() // CECOLOR
+	r.H()    // HANDSEED, always in the data stream
+	st.H()   // CLAYER
+	refs.textStyle = st.H()
+	st.H() // CELTYPE
+	if v >= r2007 {
+		st.H() // CMATERIAL
+	}
+	st.H() // DIMSTYLE
+	st.H() // CMLSTYLE
+	if v >= r2000 {
+		r.BD() // PSVPSCALE
+	}
+
+	h.PaperInsBase = r.BD3()
+	h.PaperExtMin = r.BD3()
+	h.PaperExtMax = r.BD3()
+	h.PaperLimMin = r.RD2()
+	h.PaperLimMax = r.RD2()
+	f.skipUCS(r, st)
+
+	h.InsBase = r.BD3()
+	h.ExtMin = r.BD3()
+	h.ExtMax = r.BD3()
+	h.LimMin = r.RD2()
+	h.LimMax = r.RD2()
+	f.skipUCS(r, st)
+	if v >= r2000 {
+		st.T() // DIMPOST
+		st.T() // DIMAPOST
+	}
+
+	if v <= r14 {
+		for range 11 { // DIMTOL .. DIMSOXD
+			r.B()
+		}
+		r.RC()        // DIMALTD
+		r.RC()        // DIMZIN
+		r.B()         // DIMSD1
+		r.B()         // DIMSD2
+		r.RC()        // DIMTOLJ
+		r.RC()        // DIMJUST
+		r.RC()        // DIMFIT
+		r.B()         // DIMUPT
+		for range 4 { // DIMTZIN DIMALTZ DIMALTTZ DIMTAD
+			r.RC()
+		}
+		for range 6 { // DIMUNIT DIMAUNIT DIMDEC DIMTDEC DIMALTU DIMALTTD
+			r.BS()
+		}
+		st.H() // DIMTXSTY
+	}
+	for range 9 { // DIMSCALE .. DIMTM
+		r.BD()
+	}
+	if v >= r2007 {
+		r.BD()   // DIMFXL
+		r.BD()   // DIMJOGANG
+		r.BS()   // DIMTFILL
+		st.CMC() // DIMTFILLCLR
+	}
+	if v >= r2000 {
+		for range 6 { // DIMTOL DIMLIM DIMTIH DIMTOH DIMSE1 DIMSE2
+			r.B()
+		}
+		r.BS() // DIMTAD
+		r.BS() // DIMZIN
+		r.BS() // DIMAZIN
+	}
+	if v >= r2007 {
+		r.BS() // DIMARCSYM
+	}
+	for range 8 { // DIMTXT .. DIMGAP
+		r.BD()
+	}
+	if v <= r14 {
+		for range 5 { // DIMPOST DIMAPOST DIMBLK DIMBLK1 DIMBLK2
+			st.T()
+		}
+	}
+	if v >= r2000 {
+		r.BD()        // DIMALTRND
+		r.B()         // DIMALT
+		r.BS()        // DIMALTD
+		for range 4 { // DIMTOFL DIMSAH DIMTIX DIMSOXD
+			r.B()
+		}
+	}
+	st.CMC() // DIMCLRD
+	st.CMC() // DIMCLRE
+	st.CMC() // DIMCLRT
+	if v >= r2000 {
+		for range 11 { // DIMADEC .. DIMJUST
+			r.BS()
+		}
+		r.B()         // DIMSD1
+		r.B()         // DIMSD2
+		for range 4 { // DIMTOLJ DIMTZIN DIMALTZ DIMALTTZ
+			r.BS()
+		}
+		r.B()  // DIMUPT
+		r.BS() // DIMATFIT
+	}
+	if v >= r2007 {
+		r.B() // DIMFXLON
+	}
+	if v >= r2010 {
+		r.B()  // DIMTXTDIRECTION
+		r.BD() // DIMALTMZF
+		st.T() // DIMALTMZS
+		r.BD() // DIMMZF
+		st.T() // DIMMZS
+	}
+	if v >= r2000 {
+		for range 5 { // DIMTXSTY DIMLDRBLK DIMBLK DIMBLK1 DIMBLK2
+			st.H()
+		}
+	}
+	if v >= r2007 {
+		for range 3 { // DIMLTYPE DIMLTEX1 DIMLTEX2
+			st.H()
+		}
+	}
+	if v >= r2000 {
+		r.BS() // DIMLWD
+		r.BS() // DIMLWE
+	}
+
+	for range 9 { // table control objects
+		st.H()
+	}
+	if v <= r2000 {
+		st.H() // VPORT entity header control
+	}
+	st.H() // ACAD_GROUP
+	st.H() // ACAD_MLINESTYLE
+	refs.namedObjects = st.H()
+	if v >= r2000 {
+		r.BS() // TSTACKALIGN
+		r.BS() // TSTACKSIZE
+		st.T() // HYPERLINKBASE
+		st.T() // STYLESHEET
+		refs.layouts = st.H()
+		st.H() // PLOTSETTINGS
+		st.H() // PLOTSTYLES
+	}
+	if v >= r2004 {
+		st.H() // MATERIALS
+		st.H() // COLORS
+	}
+	if v >= r2007 {
+		st.H() // VISUALSTYLE
+	}
+	if v >= r2013 {
+		st.H()
+	}
+	if v >= r2000 {
+		flags := r.BL()
+		h.LWDisplay = flags&0x200 == 0
+		h.InsUnits = int(r.BS())
+		if r.BS() == 3 { // CEPSNTYPE
+			st.H()
+		}
+		st.T() // FINGERPRINTGUID
+		st.T() // VERSIONGUID
+	}
+	if v >= r2004 {
+		for range 6 { // SORTENTS .. HALOGAP
+			r.RC()
+		}
+		r.BS() // OBSCUREDCOLOR
+		r.BS() // INTERSECTIONCOLOR
+		r.RC() // OBSCUREDLTYPE
+		r.RC() // INTERSECTIONDISPLAY
+		st.T() // PROJECTNAME
+	}
+	refs.paperSpace = st.H()
+	refs.modelSpace = st.H()
+	return refs, st.err()
+}
+
+func (f *file) skipUCS(r *bitReader, st *streams) {
+	r.BD()  // ELEVATION
+	r.BD3() // UCSORG
+	r.BD3() // UCSXDIR
+	r.BD3() // UCSYDIR
+	st.H()  // UCSNAME
+	if f.ver >= r2000 {
+		st.H() // UCSORTHOREF
+		r.BS() // UCSORTHOVIEW
+		st.H() // UCSBASE
+		for range 6 {
+			r.BD3()
+		}
+	}
+}
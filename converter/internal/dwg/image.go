@@ -0,0 +1,48 @@
+package dwg
+
+import (
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+)
+
+func (f *file) decodeImage(o *object, st *streams) error {
+	r := st.d
+	img := &drawing.Image{EntityProps: o.hdr.props}
+	r.BL() // class version
+	img.Insertion = r.BD3()
+	img.U = r.BD3()
+	img.V = r.BD3()
+	img.Size = r.RD2()
+	img.Flags = int(r.BS())
+	img.Clipping = r.B()
+	r.RC() // brightness
+	r.RC() // contrast
+	r.RC() // fade
+	if f.ver >= r2010 {
+		img.ClipInverted = r.B()
+	}
+	if r.BS() == 1 {
+		img.Clip = append(img.Clip, r.RD2(), r.RD2())
+	} else {
+		n := int(r.BL())
+		if n < 0 || n > r.end-r.pos {
+			return errShortRead
+		}
+		for range n {
+			img.Clip = append(img.Clip, r.RD2())
+		}
+	}
+	img.Def = drawing.Handle(st.H())
+	st.H() // reactor
+	o.ent = img
+	return nil
+}
+
+func (f *file) decodeImageDef(o *object, st *streams) error {
+	r := st.d
+	def := &drawing.ImageDef{Handle: drawing.Handle(o.handle)}
+	r.BL() // class version
+	def.Size = r.RD2()
+	def.File = st.T()
+	o.rec = def
+	return nil
+}
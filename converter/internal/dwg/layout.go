@@ -0,0 +1,159 @@
+package dwg
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// layoutRecord holds a LAYOUT object with the handles of its block record
+// and of the view it plots.
+type layoutRecord struct {
+	layout *drawing.Layout
+	block  uint64
+	view   uint64
+}
+
+// viewportRefs holds the layers a viewport freezes and its clip boundary.
+type viewportRefs struct {
+	frozen []uint64
+	clip   uint64
+}
+
+func (f *file) decodeLayout(o *object, st *streams) error {
+	r := st.d
+	var ps drawing.PlotSettings
+	st.T() // page setup name
+	st.T() // printer or configuration file
+	ps.Flags = int(r.BS())
+	ps.MarginLeft = r.BD()
+	ps.MarginBottom = r.BD()
+	ps.MarginRight = r.BD()
+	ps.MarginTop = r.BD()
+	ps.PaperWidth = r.BD()
+	ps.PaperHeight = r.BD()
+	ps.PaperName = st.T()
+	ps.Origin = r.BD2()
+	ps.Inches = r.BS() == 0
+	ps.Rotation = int(r.BS()) & 3
+	ps.Area = drawing.PlotArea(r.BS())
+	ps.WindowMin = r.BD2()
+	ps.WindowMax = r.BD2()
+	if f.ver <= r2000 {
+		ps.ViewName = st.T()
+	}
+	ps.PaperUnits = r.BD()
+	ps.DrawingUnits = r.BD()
+	ps.StyleSheet = st.T()
+	ps.StandardScale = int(r.BS())
+	ps.StandardFactor = r.BD()
+	r.BD2() // paper image origin
+	if f.ver >= r2004 {
+		r.BS() // shade plot mode
+		r.BS() // shade plot resolution
+		r.BS() // shade plot DPI
+	}
+
+	l := &drawing.Layout{Handle: drawing.Handle(o.handle), Plot: ps}
+	l.Name = st.T()
+	l.TabOrder = int(r.BS())
+	r.BS() // flags
+	l.InsBase = r.BD3()
+	l.LimMin = r.RD2()
+	l.LimMax = r.RD2()
+	r.BD3() // UCS origin
+	r.BD3() // UCS X axis
+	r.BD3() // UCS Y axis
+	r.BD()  // UCS elevation
+	r.BS()  // orthographic view type
+	l.ExtMin = r.BD3()
+	l.ExtMax = r.BD3()
+	if f.ver >= r2004 {
+		r.BL() // viewports
+	}
+
+	rec := &layoutRecord{layout: l}
+	if f.ver >= r2004 {
+		rec.view = st.H()
+	}
+	if f.ver >= r2007 {
+		st.H() // visual style
+	}
+	rec.block = st.H()
+	o.rec = rec
+	return nil
+}
+
+func (f *file) decodeViewport(o *object, st *streams) error {
+	r := st.d
+	v := &drawing.Viewport{EntityProps: o.hdr.props, ViewDirection: geom.ZAxis}
+	v.Center = r.BD3()
+	v.Width = r.BD()
+	v.Height = r.BD()
+	o.ent = v
+	// Before R2000 the view is kept in the MVIEW extended data.
+	if f.ver < r2000 {
+		return nil
+	}
+	v.ViewTarget = r.BD3()
+	v.ViewDirection = r.BD3()
+	v.TwistAngle = r.BD()
+	v.ViewHeight = r.BD()
+	r.BD() // lens length
+	r.BD() // front clip
+	r.BD() // back clip
+	r.BD() // snap angle
+	v.ViewCenter = r.RD2()
+	r.RD2() // snap base
+	r.RD2() // snap spacing
+	r.RD2() // grid spacing
+	r.BS()  // circle zoom
+	if f.ver >= r2007 {
+		r.BS() // major grid lines
+	}
+	frozen := int(r.BL())
+	v.Status = int(r.BL())
+
+	if frozen < 0 || frozen > st.h.end-st.h.pos {
+		return errShortRead
+	}
+	refs := &viewportRefs{}
+	for i := 0; i < frozen && st.h.err == nil; i++ {
+		refs.frozen = append(refs.frozen, st.H())
+	}
+	refs.clip = st.H()
+	o.rec = refs
+	return nil
+}
+
+// mview reads the view of a viewport from the MVIEW extended data that
+// files before R2000 keep it in. Frozen layers are given by handle and
+// named by layer.
+func mview(v *drawing.Viewport, items []eedItem, layer func(h uint64) string) {
+	if len(items) == 0 || items[0].code != 1000 || !strings.EqualFold(items[0].value, "MVIEW") {
+		return
+	}
+	var pts []geom.Vec3
+	var reals []float64
+	for _, it := range items {
+		switch it.code {
+		case 1010:
+			pts = append(pts, it.pt)
+		case 1040:
+			reals = append(reals, it.real)
+		case 1003:
+			h, _ := strconv.ParseUint(it.value, 16, 64)
+			if name := layer(h); name != "" {
+				v.FrozenLayers = append(v.FrozenLayers, name)
+			}
+		}
+	}
+	if len(pts) < 2 || len(reals) < 4 {
+		return
+	}
+	v.ViewTarget, v.ViewDirection = pts[0], pts[1]
+	v.TwistAngle, v.ViewHeight = reals[0], reals[1]
+	v.ViewCenter = geom.Vec2{X: reals[2], Y: reals[3]}
+}
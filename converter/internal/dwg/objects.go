@@ -0,0 +1,417 @@
+package dwg
+
+import (
+	"fmt"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+)
+
+// Fixed object type numbers. Types from 500 up are looked up in the class
+// section by their DXF name.
+const (
+	typeText            = 0x01
+	typeAttrib          = 0x02
+	typeAttDef          = 0x03
+	typeBlock           = 0x04
+	typeEndBlk          = 0x05
+	typeSeqEnd          = 0x06
+	typeInsert          = 0x07
+	typeMInsert         = 0x08
+	typeVertex2D        = 0x0a
+	typeVertex3D        = 0x0b
+	typeVertexMesh      = 0x0c
+	typeVertexPFace     = 0x0d
+	typeVertexPFaceFace = 0x0e
+	typePolyline2D      = 0x0f
+	typePolyline3D      = 0x10
+	typeArc             = 0x11
+	typeCircle          = 0x12
+	typeLine            = 0x13
+	typeDimOrdinate     = 0x14
+	typeDimLinear       = 0x15
+	typeDimAligned      = 0x16
+	typeDimAng3Pt       = 0x17
+	typeDimAng2Ln       = 0x18
+	typeDimRadius       = 0x19
+	typeDimDiameter     = 0x1a
+	typePoint           = 0x1b
+	typeFace3D          = 0x1c
+	typePolylinePFace   = 0x1d
+	typePolylineMesh    = 0x1e
+	typeSolid           = 0x1f
+	typeTrace           = 0x20
+	typeViewport        = 0x22
+	typeEllipse         = 0x23
+	typeSpline          = 0x24
+	typeRay             = 0x28
+	typeXLine           = 0x29
+	typeDictionary      = 0x2a
+	typeMText           = 0x2c
+	typeBlockHeader     = 0x31
+	typeLayer           = 0x33
+	typeStyle           = 0x35
+	typeLtype           = 0x39
+	typeView            = 0x3d
+	typeAppID           = 0x43
+	typeDimStyle        = 0x45
+	typeLWPolyline      = 0x4d
+	typeHatch           = 0x4e
+	typeDictionaryWDFLT = 0x1000
+	typeArcDimension    = 0x1001
+	typeLayout          = 0x1002
+	typeDBColor         = 0x1003
+	typeImage           = 0x1004
+	typeImageDef        = 0x1005
+	typeUnknown         = -1
+	firstClassType      = 500
+)
+
+var classTypes = map[string]int{
+	"LWPOLYLINE":          typeLWPolyline,
+	"HATCH":               typeHatch,
+	"ACDBDICTIONARYWDFLT": typeDictionaryWDFLT,
+	"ARC_DIMENSION":       typeArcDimension,
+	"LAYOUT":              typeLayout,
+	"DBCOLOR":             typeDBColor,
+	"IMAGE":               typeImage,
+	"IMAGEDEF":            typeImageDef,
+}
+
+type eedRecord struct {
+	app  uint64
+	data []byte
+}
+
+// entityHeader holds the common entity fields that reference other objects
+// and are resolved once every object has been decoded.
+type entityHeader struct {
+	props      drawing.EntityProps
+	entmode    uint8
+	layer      uint64
+	ltype      uint64
+	ltypeFlags uint8
+
+	links        bool
+	colorBook    bool
+	material     bool
+	visualStyles int
+
+	// book is the DBCOLOR object of a color from a color book.
+	book uint64
+	// plotStyle is ByLayer, ByBlock, the default style or one named by
+	// plotStyleRef, as 0 to 3.
+	plotStyle    uint8
+	plotStyleRef uint64
+}
+
+type object struct {
+	handle uint64
+	typ    int
+	owner  uint64
+	xdic   uint64
+	eed    []eedRecord
+	hdr    *entityHeader
+
+	// ent is the decoded entity; rec the decoded non-entity object, or
+	// what an entity refers to when that is more than the fields below.
+	ent drawing.Entity
+	rec any
+
+	// style is the text style of text entities, block the block record
+	// of inserts and owned the sub-entities of polylines and inserts.
+	style uint64
+	block uint64
+	owned []uint64
+}
+
+type decodeFunc func(o *object, st *streams) error
+
+func (f *file) readObject(data []byte, ref objectRef, classes map[int]class) (*object, error) {
+	if ref.offset < 0 || ref.offset >= int64(len(data)) {
+		return nil, fmt.Errorf("object %X: offset out of range", ref.handle)
+	}
+	r := newBitReader(data, f.ver, f.cp)
+	r.pos = int(ref.offset) * 8
+	size := int(r.MS())
+	var hsize int
+	if f.ver >= r2010 {
+		hsize = int(r.UMC())
+	}
+	start := r.pos
+	end := start + size*8
+	if r.err != nil || end > len(data)*8 {
+		return nil, fmt.Errorf("object %X: bad size", ref.handle)
+	}
+	r = r.sub(start, end)
+
+	o := &object{handle: ref.handle}
+	if f.ver >= r2010 {
+		o.typ = r.OT()
+	} else {
+		o.typ = int(r.BS())
+	}
+	if o.typ >= firstClassType {
+		t, ok := classTypes[classes[o.typ].dxfName]
+		if !ok {
+			t = typeUnknown
+		}
+		o.typ = t
+	}
+	decode, entity := f.decoder(o.typ)
+	if decode == nil {
+		return o, nil
+	}
+
+	hstart := -1
+	switch {
+	case f.ver >= r2010:
+		hstart = end - hsize
+	case f.ver >= r2000:
+		hstart = start + int(r.RL())
+	}
+	r.H() // own handle
+	for n := int(r.BS()); n > 0 && r.err == nil; n = int(r.BS()) {
+		app := r.H().value
+		o.eed = append(o.eed, eedRecord{app: app, data: r.bytes(n)})
+	}
+
+	st := &streams{d: r, s: r, own: ref.handle}
+	var reactors int
+	var xdicMissing bool
+	if entity {
+		o.hdr = &entityHeader{props: drawing.DefaultProps()}
+		o.hdr.props.Handle = drawing.Handle(ref.handle)
+		hstart = f.entityCommon(r, o.hdr, hstart, start, &reactors, &xdicMissing)
+	} else {
+		if f.ver <= r14 {
+			hstart = start + int(r.RL())
+		}
+		reactors = int(r.BL())
+		if f.ver >= r2004 {
+			xdicMissing = r.B()
+		}
+		if f.ver >= r2013 {
+			r.B()
+		}
+	}
+	if hstart < start || hstart > end {
+		return nil, fmt.Errorf("object %X: bad handle stream offset", ref.handle)
+	}
+	st.h = r.sub(hstart, end)
+	if f.ver >= r2007 {
+		st.s = stringStream(r, hstart)
+	}
+	if reactors < 0 || reactors > st.h.end-st.h.pos {
+		return nil, fmt.Errorf("object %X: %w", ref.handle, errShortRead)
+	}
+
+	if entity {
+		f.entityHandles(st, o, reactors, xdicMissing)
+	} else {
+		o.owner = st.H()
+		for i := 0; i < reactors && st.h.err == nil; i++ {
+			st.H()
+		}
+		if !xdicMissing {
+			o.xdic = st.H()
+		}
+	}
+	if err := decode(o, st); err != nil {
+		return nil, fmt.Errorf("object %X: %w", ref.handle, err)
+	}
+	if err := st.err(); err != nil {
+		return nil, fmt.Errorf("object %X (type %d): %w", ref.handle, o.typ, err)
+	}
+	return o, nil
+}
+
+// entityCommon reads the common entity data and returns the start of the
+// handle stream, which R13-R14 only store at this point.
+func (f *file) entityCommon(r *bitReader, h *entityHeader, hstart, start int, reactors *int, xdicMissing *bool) int {
+	if r.B() {
+		var n int
+		if f.ver >= r2010 {
+			n = int(r.BLL())
+		} else {
+			n = int(r.RL())
+		}
+		r.bytes(n)
+	}
+	if f.ver <= r14 {
+		hstart = start + int(r.RL())
+	}
+	h.entmode = r.BB()
+	*reactors = int(r.BL())
+	if f.ver >= r2004 {
+		*xdicMissing = r.B()
+	}
+	if f.ver >= r2013 {
+		r.B()
+	}
+	if f.ver <= r14 {
+		if r.B() {
+			h.ltypeFlags = 0
+		} else {
+			h.ltypeFlags = 3
+		}
+	}
+	if f.ver <= r2000 {
+		h.links = !r.B()
+	}
+	if f.ver >= r2004 {
+		var flags uint16
+		h.props.Color, flags = r.ENC()
+		h.colorBook = flags&0x4000 != 0
+	} else {
+		h.props.Color = drawing.Color{Index: r.BS()}
+	}
+	h.props.LinetypeScale = r.BD()
+	if f.ver >= r2000 {
+		h.ltypeFlags = r.BB()
+		h.plotStyle = r.BB()
+	}
+	if f.ver >= r2007 {
+		h.material = r.BB() == 3
+		r.RC() // shadow flags
+	}
+	if f.ver >= r2010 {
+		for range 3 {
+			if r.B() {
+				h.visualStyles++
+			}
+		}
+	}
+	h.props.Invisible = r.BS()&1 != 0
+	if f.ver >= r2000 {
+		h.props.Lineweight = drawing.LineweightFromIndex(int(r.RC()))
+	}
+	return hstart
+}
+
+func (f *file) entityHandles(st *streams, o *object, reactors int, xdicMissing bool) {
+	h := o.hdr
+	if h.entmode == 0 {
+		o.owner = st.H()
+	}
+	for i := 0; i < reactors && st.h.err == nil; i++ {
+		st.H()
+	}
+	if !xdicMissing {
+		o.xdic = st.H()
+	}
+	if f.ver <= r14 {
+		h.layer = st.H()
+		if h.ltypeFlags == 3 {
+			h.ltype = st.H()
+		}
+	}
+	if h.links {
+		st.H() // previous entity
+		st.H() // next entity
+	}
+	if h.colorBook {
+		h.book = st.H()
+	}
+	if f.ver >= r2000 {
+		h.layer = st.H()
+		if h.ltypeFlags == 3 {
+			h.ltype = st.H()
+		}
+	}
+	if h.material {
+		st.H()
+	}
+	if h.plotStyle == 3 {
+		h.plotStyleRef = st.H()
+	}
+	for range h.visualStyles {
+		st.H()
+	}
+	switch h.ltypeFlags & 3 {
+	case 0:
+		h.props.Linetype = "ByLayer"
+	case 1:
+		h.props.Linetype = "ByBlock"
+	case 2:
+		h.props.Linetype = "Continuous"
+	}
+}
+
+// decoder returns the decode function for a type and whether the type is
+// an entity.
+func (f *file) decoder(typ int) (decodeFunc, bool) {
+	switch typ {
+	case typeText:
+		return f.decodeText, true
+	case typeAttrib:
+		return f.decodeAttrib, true
+	case typeAttDef:
+		return f.decodeAttDef, true
+	case typeBlock, typeEndBlk, typeSeqEnd:
+		return decodeNothing, true
+	case typeInsert, typeMInsert:
+		return f.decodeInsert, true
+	case typeVertex2D, typeVertex3D, typeVertexMesh, typeVertexPFace:
+		return f.decodeVertex, true
+	case typeVertexPFaceFace:
+		return f.decodeFaceRecord, true
+	case typePolyline2D, typePolyline3D, typePolylinePFace, typePolylineMesh:
+		return f.decodePolyline, true
+	case typeArc:
+		return f.decodeArc, true
+	case typeCircle:
+		return f.decodeCircle, true
+	case typeLine:
+		return f.decodeLine, true
+	case typePoint:
+		return f.decodePoint, true
+	case typeFace3D:
+		return f.decodeFace3D, true
+	case typeSolid, typeTrace:
+		return f.decodeSolid, true
+	case typeEllipse:
+		return f.decodeEllipse, true
+	case typeSpline:
+		return f.decodeSpline, true
+	case typeRay, typeXLine:
+		return f.decodeRay, true
+	case typeMText:
+		return f.decodeMText, true
+	case typeLWPolyline:
+		return f.decodeLWPolyline, true
+	case typeHatch:
+		return f.decodeHatch, true
+	case typeDimOrdinate, typeDimLinear, typeDimAligned, typeDimAng3Pt, typeDimAng2Ln,
+		typeDimRadius, typeDimDiameter, typeArcDimension:
+		return f.decodeDimension, true
+	case typeViewport:
+		return f.decodeViewport, true
+	case typeImage:
+		return f.decodeImage, true
+	case typeDictionary, typeDictionaryWDFLT:
+		return f.decodeDictionary, false
+	case typeBlockHeader:
+		return f.decodeBlockHeader, false
+	case typeLayer:
+		return f.decodeLayer, false
+	case typeStyle:
+		return f.decodeStyle, false
+	case typeLtype:
+		return f.decodeLtype, false
+	case typeView:
+		return f.decodeView, false
+	case typeAppID:
+		return f.decodeAppID, false
+	case typeDimStyle:
+		return f.decodeDimStyle, false
+	case typeLayout:
+		return f.decodeLayout, false
+	case typeDBColor:
+		return f.decodeDBColor, false
+	case typeImageDef:
+		return f.decodeImageDef, false
+	}
+	return nil, false
+}
+
+func decodeNothing(*object, *streams) error { return nil }
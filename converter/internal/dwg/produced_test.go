@@ -0,0 +1,95 @@
+package dwg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// produced holds what a drawing saved by AutoCAD or the ODA File Converter
+// in testdata/produced must read as. Each NAME.dwg there comes with a
+// NAME.json giving the version tag the file was saved in, layers it
+// defines and how many entities of each type its model space holds, by
+// the names of the drawing package types, such as Line or LWPolyline.
+type produced struct {
+	Version  string         `json:"version"`
+	Layers   []string       `json:"layers"`
+	Entities map[string]int `json:"entities"`
+}
+
+// families are the containers of the versions: the sample drawings are
+// written by this package's tests, so each needs a file from another
+// program as well.
+var families = []struct {
+	name string
+	tags []string
+}{
+	{"R13 to 2000", []string{"AC1012", "AC1014", "AC1015"}},
+	{"2004", []string{"AC1018"}},
+	{"2007", []string{"AC1021"}},
+	{"2010 and later", []string{"AC1024", "AC1027", "AC1032"}},
+}
+
+func TestReadProduced(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "produced", "*.dwg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dwg")
+		t.Run(name, func(t *testing.T) {
+			var want produced
+			data, err := os.ReadFile(strings.TrimSuffix(file, ".dwg") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			found[want.Version] = true
+
+			data, err = os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := Read(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Version != want.Version {
+				t.Errorf("version = %s, want %s", d.Version, want.Version)
+			}
+			if len(d.Warnings) != 0 {
+				t.Errorf("warnings = %q", d.Warnings)
+			}
+			for _, l := range want.Layers {
+				if d.Layer(l) == nil {
+					t.Errorf("no layer %s", l)
+				}
+			}
+			got := make(map[string]int)
+			for _, e := range d.ModelSpace().Entities {
+				got[strings.TrimPrefix(fmt.Sprintf("%T", e), "*drawing.")]++
+			}
+			for typ, n := range want.Entities {
+				if got[typ] != n {
+					t.Errorf("model space has %d %s entities, want %d", got[typ], typ, n)
+				}
+			}
+		})
+	}
+	for _, f := range families {
+		if !slices.ContainsFunc(f.tags, func(tag string) bool { return found[tag] }) {
+			t.Logf("no drawing saved by AutoCAD or ODA in testdata/produced is %s", f.name)
+		}
+	}
+	if len(files) == 0 {
+		t.Skip("testdata/produced holds no drawings")
+	}
+}
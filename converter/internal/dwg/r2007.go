@@ -0,0 +1,198 @@
+package dwg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// R2007 files protect every page with Reed-Solomon coding. The data bytes
+// are interleaved with the parity; the decoder only de-interleaves and
+// trusts the payload instead of correcting errors.
+func decodeRS(src []byte, blocks, k int) []byte {
+	out := make([]byte, 0, blocks*k)
+	for i := range blocks {
+		for j := range k {
+			idx := i + j*blocks
+			if idx >= len(src) {
+				out = append(out, 0)
+				continue
+			}
+			out = append(out, src[idx])
+		}
+	}
+	return out
+}
+
+type r2007Page struct {
+	id     int64
+	size   int64
+	offset int64
+}
+
+type r2007SectionPage struct {
+	id         int64
+	offset     int64
+	size       int64
+	uncompSize int64
+	compSize   int64
+}
+
+func (f *file) read2007() error {
+	const base = 0x480
+	if len(f.data) < base {
+		return errShortRead
+	}
+	le := binary.LittleEndian
+	pe := decodeRS(f.data[0x80:0x80+0x3d8], 3, 239)
+	compLen := int(int32(le.Uint32(pe[24:])))
+	var hdr []byte
+	if compLen > 0 {
+		if 32+compLen > len(pe) {
+			compLen = len(pe) - 32
+		}
+		var err error
+		hdr, err = decompress2007(pe[32:32+compLen], 0x110)
+		if err != nil {
+			return fmt.Errorf("file header: %w", err)
+		}
+	} else {
+		hdr = pad(append([]byte(nil), pe[32:]...), 0x110)
+	}
+	q := func(i int) int64 { return int64(le.Uint64(hdr[i*8:])) }
+	pagesMapOffset := q(7)
+	pagesMapSizeComp := q(10)
+	pagesMapSizeUncomp := q(11)
+	pagesMapCorrection := q(3)
+	sectionsMapSizeComp := q(22)
+	sectionsMapID := q(24)
+	sectionsMapSizeUncomp := q(25)
+	sectionsMapCorrection := q(27)
+
+	pm, err := f.systemPage2007(base+pagesMapOffset, pagesMapSizeComp, pagesMapSizeUncomp, pagesMapCorrection)
+	if err != nil {
+		return fmt.Errorf("page map: %w", err)
+	}
+	pages := make(map[int64]r2007Page)
+	var offset int64
+	for p := 0; p+16 <= len(pm); p += 16 {
+		pg := r2007Page{size: int64(le.Uint64(pm[p:])), id: int64(le.Uint64(pm[p+8:])), offset: offset}
+		offset += pg.size
+		id := pg.id
+		if id < 0 {
+			id = -id
+		}
+		pages[id] = pg
+	}
+
+	smPage, ok := pages[sectionsMapID]
+	if !ok {
+		return errors.New("section map page not found")
+	}
+	sm, err := f.systemPage2007(base+smPage.offset, sectionsMapSizeComp, sectionsMapSizeUncomp, sectionsMapCorrection)
+	if err != nil {
+		return fmt.Errorf("section map: %w", err)
+	}
+
+	for p := 0; p+64 <= len(sm); {
+		dataSize := int64(le.Uint64(sm[p:]))
+		nameLen := int(le.Uint64(sm[p+32:]))
+		numPages := int(le.Uint64(sm[p+56:]))
+		p += 64
+		if nameLen < 0 || p+nameLen > len(sm) {
+			return errors.New("section map truncated")
+		}
+		u := make([]uint16, 0, nameLen/2)
+		for i := 0; i+1 < nameLen; i += 2 {
+			c := le.Uint16(sm[p+i:])
+			if c == 0 {
+				break
+			}
+			u = append(u, c)
+		}
+		name := string(utf16.Decode(u))
+		p += nameLen
+		var sp []r2007SectionPage
+		for range numPages {
+			if p+56 > len(sm) {
+				return errors.New("section map truncated")
+			}
+			sp = append(sp, r2007SectionPage{
+				offset:     int64(le.Uint64(sm[p:])),
+				size:       int64(le.Uint64(sm[p+8:])),
+				id:         int64(le.Uint64(sm[p+16:])),
+				uncompSize: int64(le.Uint64(sm[p+24:])),
+				compSize:   int64(le.Uint64(sm[p+32:])),
+			})
+			p += 56
+		}
+		if name == "" || dataSize <= 0 {
+			continue
+		}
+		if dataSize > int64(f.limit()) {
+			return fmt.Errorf("section %s: size %d too large", name, dataSize)
+		}
+		out := make([]byte, dataSize)
+		for _, s := range sp {
+			pg, ok := pages[s.id]
+			if !ok {
+				return fmt.Errorf("section %s: page %d not found", name, s.id)
+			}
+			if s.offset < 0 || s.uncompSize < 0 || s.compSize < 0 || s.offset > dataSize-s.uncompSize {
+				return fmt.Errorf("section %s: page out of range", name)
+			}
+			if err := f.dataPage2007(out[s.offset:s.offset+s.uncompSize], base+pg.offset, pg.size, s.compSize); err != nil {
+				return fmt.Errorf("section %s: %w", name, err)
+			}
+		}
+		f.sections[name] = out
+	}
+	return nil
+}
+
+func (f *file) systemPage2007(addr, sizeComp, sizeUncomp, repeat int64) ([]byte, error) {
+	size := int64(len(f.data))
+	if sizeComp < 0 || sizeComp > size || repeat < 0 || repeat > size {
+		return nil, errShortRead
+	}
+	if sizeUncomp < 0 || sizeUncomp > int64(f.limit()) {
+		return nil, fmt.Errorf("page size %d too large", sizeUncomp)
+	}
+	pesize := ((sizeComp + 7) &^ 7) * repeat
+	blocks := (pesize + 238) / 239
+	pageSize := (blocks*255 + 7) &^ 7
+	if addr < 0 || pageSize > size || addr > size-pageSize {
+		return nil, errShortRead
+	}
+	pe := decodeRS(f.data[addr:addr+pageSize], int(blocks), 239)
+	if sizeComp < sizeUncomp {
+		n := min(pesize, sizeComp)
+		return decompress2007(pe[:n], int(sizeUncomp))
+	}
+	return pad(pe[:min(int64(len(pe)), sizeUncomp)], int(sizeUncomp)), nil
+}
+
+func (f *file) dataPage2007(dst []byte, addr, pageSize, sizeComp int64) error {
+	size := int64(len(f.data))
+	if addr < 0 || pageSize < 0 || pageSize > size || addr > size-pageSize || sizeComp > pageSize {
+		return errShortRead
+	}
+	// The coded page holds 255 bytes for every 251 of payload.
+	pesize := (sizeComp + 7) &^ 7
+	blocks := (pesize + 250) / 251
+	if blocks*255 > pageSize {
+		return errShortRead
+	}
+	pe := decodeRS(f.data[addr:addr+pageSize], int(blocks), 251)
+	if sizeComp < int64(len(dst)) {
+		out, err := decompress2007(pe[:min(int64(len(pe)), sizeComp)], len(dst))
+		if err != nil {
+			return err
+		}
+		copy(dst, out)
+		return nil
+	}
+	copy(dst, pe)
+	return nil
+}
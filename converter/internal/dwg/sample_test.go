@@ -0,0 +1,1185 @@
+package dwg
+
+import (
+	"encoding/binary"
+	"math"
+	"unicode/utf16"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// This file writes the sample drawings under testdata. Each holds the same
+// small drawing in the container and record layout of its version: two
+// layers, a line, a circle, a text and, from R14, a lightweight polyline
+// that goes through the class section. The writer only produces what the
+// reader needs, uncompressed except for R2004+ data pages, and the tests
+// regenerate the files with -update.
+
+const (
+	hLayer0     = 0x10
+	hLayerWalls = 0x11
+	hModelSpace = 0x1f
+	hLine       = 0x20
+	hCircle     = 0x21
+	hText       = 0x22
+	hPolyline   = 0x23
+
+	sampleCodepage = 30
+	// sampleBroken is the handle of an object map entry that points past
+	// the object data.
+	sampleBroken = 0x30
+)
+
+var sampleVersions = []struct {
+	name string
+	tag  string
+}{
+	{"r14", "AC1014"},
+	{"r13", "AC1012"},
+	{"r2000", "AC1015"},
+	{"r2004", "AC1018"},
+	{"r2007", "AC1021"},
+	{"r2010", "AC1024"},
+	{"r2013", "AC1027"},
+	{"r2018", "AC1032"},
+}
+
+// bitWriter is the counterpart of bitReader.
+type bitWriter struct {
+	buf []byte
+	n   int
+	ver version
+}
+
+func (w *bitWriter) bit(b bool) {
+	if w.n&7 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if b {
+		w.buf[w.n>>3] |= 0x80 >> uint(w.n&7)
+	}
+	w.n++
+}
+
+func (w *bitWriter) bits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bit(v>>uint(i)&1 == 1)
+	}
+}
+
+// patchRL overwrites the RL written at bit pos.
+func (w *bitWriter) patchRL(pos int, v uint32) {
+	for i := range 32 {
+		at := pos + (i/8)*8 + 7 - i%8
+		mask := byte(0x80) >> uint(at&7)
+		if v>>uint(i)&1 == 1 {
+			w.buf[at>>3] |= mask
+		} else {
+			w.buf[at>>3] &^= mask
+		}
+	}
+}
+
+func (w *bitWriter) append(o *bitWriter) {
+	for i := range o.n {
+		w.bit(o.buf[i>>3]>>uint(7-i&7)&1 == 1)
+	}
+}
+
+func (w *bitWriter) B(b bool)        { w.bit(b) }
+func (w *bitWriter) BB(v uint8)      { w.bits(uint64(v), 2) }
+func (w *bitWriter) RC(v uint8)      { w.bits(uint64(v), 8) }
+func (w *bitWriter) RS(v uint16)     { w.RC(uint8(v)); w.RC(uint8(v >> 8)) }
+func (w *bitWriter) RL(v uint32)     { w.RS(uint16(v)); w.RS(uint16(v >> 16)) }
+func (w *bitWriter) RD(v float64)    { b := math.Float64bits(v); w.RL(uint32(b)); w.RL(uint32(b >> 32)) }
+func (w *bitWriter) RD2(v geom.Vec2) { w.RD(v.X); w.RD(v.Y) }
+func (w *bitWriter) BD3(v geom.Vec3) { w.BD(v.X); w.BD(v.Y); w.BD(v.Z) }
+
+func (w *bitWriter) bytes(b []byte) {
+	for _, c := range b {
+		w.RC(c)
+	}
+}
+
+func (w *bitWriter) BS(v int16) {
+	switch {
+	case v == 0:
+		w.BB(2)
+	case v > 0 && v < 256:
+		w.BB(1)
+		w.RC(uint8(v))
+	case v == 256:
+		w.BB(3)
+	default:
+		w.BB(0)
+		w.RS(uint16(v))
+	}
+}
+
+func (w *bitWriter) BL(v uint32) {
+	switch {
+	case v == 0:
+		w.BB(2)
+	case v < 256:
+		w.BB(1)
+		w.RC(uint8(v))
+	default:
+		w.BB(0)
+		w.RL(v)
+	}
+}
+
+func (w *bitWriter) BLL(v uint64) {
+	var b []byte
+	for ; v != 0; v >>= 8 {
+		b = append(b, byte(v))
+	}
+	w.bits(uint64(len(b)), 3)
+	w.bytes(b)
+}
+
+func (w *bitWriter) BD(v float64) {
+	switch v {
+	case 0:
+		w.BB(2)
+	case 1:
+		w.BB(1)
+	default:
+		w.BB(0)
+		w.RD(v)
+	}
+}
+
+func (w *bitWriter) DD(v, def float64) {
+	if v == def {
+		w.BB(0)
+		return
+	}
+	w.BB(3)
+	w.RD(v)
+}
+
+func (w *bitWriter) BT(v float64) {
+	if w.ver >= r2000 {
+		w.B(v == 0)
+		if v == 0 {
+			return
+		}
+	}
+	w.BD(v)
+}
+
+func (w *bitWriter) BE(v geom.Vec3) {
+	if w.ver >= r2000 {
+		w.B(v == geom.ZAxis)
+		if v == geom.ZAxis {
+			return
+		}
+	}
+	w.BD3(v)
+}
+
+func (w *bitWriter) H(code uint8, v uint64) {
+	var b []byte
+	for ; v != 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	w.RC(code<<4 | uint8(len(b)))
+	w.bytes(b)
+}
+
+func (w *bitWriter) T(s string) {
+	if s == "" {
+		w.BS(0)
+		return
+	}
+	if w.ver >= r2007 {
+		u := utf16.Encode([]rune(s))
+		w.BS(int16(len(u) + 1))
+		for _, c := range u {
+			w.RS(c)
+		}
+		w.RS(0)
+		return
+	}
+	w.BS(int16(len(s) + 1))
+	w.bytes([]byte(s))
+	w.RC(0)
+}
+
+func (w *bitWriter) CMC(index int16) {
+	w.BS(index)
+	if w.ver < r2004 {
+		return
+	}
+	switch index {
+	case 0:
+		w.BL(0xc1000000)
+	case 256:
+		w.BL(0xc0000000)
+	default:
+		w.BL(0xc3000000 | uint32(index))
+	}
+	w.RC(0)
+}
+
+func (w *bitWriter) OT(t int) {
+	switch {
+	case t < 256:
+		w.BB(0)
+		w.RC(uint8(t))
+	case t >= 0x1f0 && t < 0x2f0:
+		w.BB(1)
+		w.RC(uint8(t - 0x1f0))
+	default:
+		w.BB(2)
+		w.RS(uint16(t))
+	}
+}
+
+func appendMS(b []byte, v int) []byte {
+	for v >= 0x8000 {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v&0x7fff|0x8000))
+		v >>= 15
+	}
+	return binary.LittleEndian.AppendUint16(b, uint16(v))
+}
+
+func appendUMC(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v&0x7f|0x80))
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendMC(b []byte, v int64) []byte {
+	var sign byte
+	if v < 0 {
+		sign, v = 0x40, -v
+	}
+	for v >= 0x40 {
+		b = append(b, byte(v&0x7f|0x80))
+		v >>= 7
+	}
+	return append(b, byte(v)|sign)
+}
+
+// record collects the streams of a header, class or object record; they
+// share one writer where the version keeps them together.
+type record struct {
+	ver     version
+	d, s, h *bitWriter
+}
+
+func newRecord(ver version, handles bool) *record {
+	d := &bitWriter{ver: ver}
+	r := &record{ver: ver, d: d, s: d, h: d}
+	if ver >= r2007 {
+		r.s = &bitWriter{ver: ver}
+	}
+	if handles {
+		r.h = &bitWriter{ver: ver}
+	}
+	return r
+}
+
+func (r *record) T(s string)             { r.s.T(s) }
+func (r *record) H(code uint8, v uint64) { r.h.H(code, v) }
+
+// trailer is the number of bits the R2007+ string stream adds after the
+// strings.
+func (r *record) trailer() int {
+	switch {
+	case r.ver < r2007:
+		return 0
+	case r.s.n > 0:
+		return 17
+	}
+	return 1
+}
+
+// strings appends the R2007+ string stream with its size and flag.
+func (r *record) strings() {
+	if r.ver < r2007 {
+		return
+	}
+	if r.s.n == 0 {
+		r.d.B(false)
+		return
+	}
+	n := r.s.n
+	r.d.append(r.s)
+	r.d.RS(uint16(n))
+	r.d.B(true)
+}
+
+var sampleSentinel = make([]byte, 16)
+
+func writeHeader(ver version) []byte {
+	r := newRecord(ver, ver >= r2007)
+	w := r.d
+	w.bytes(sampleSentinel)
+	sizeAt := w.n
+	w.RL(0)
+	bitsAt := w.n
+	if ver >= r2007 {
+		w.RL(0)
+	}
+	if ver >= r2013 {
+		w.BLL(0)
+	}
+	for range 4 {
+		w.BD(0)
+	}
+	for range 4 {
+		r.T("")
+	}
+	w.BL(0)
+	w.BL(0)
+	if ver <= r14 {
+		w.BS(0)
+	}
+	if ver < r2004 {
+		r.H(5, 0)
+	}
+	bits := func(n int) {
+		for range n {
+			w.B(false)
+		}
+	}
+	shorts := func(n int) {
+		for range n {
+			w.BS(0)
+		}
+	}
+	doubles := func(n int) {
+		for range n {
+			w.BD(0)
+		}
+	}
+	longs := func(n int) {
+		for range n {
+			w.BL(0)
+		}
+	}
+	handles := func(n int) {
+		for range n {
+			r.H(5, 0)
+		}
+	}
+	bits(2) // DIMASO DIMSHO
+	if ver <= r14 {
+		bits(1)
+	}
+	bits(3)    // PLINEGEN ORTHOMODE REGENMODE
+	w.B(true)  // FILLMODE
+	w.B(false) // QTEXTMODE
+	w.B(true)  // PSLTSCALE
+	bits(1)    // LIMCHECK
+	if ver <= r14 {
+		bits(1)
+	}
+	if ver >= r2004 {
+		bits(1)
+	}
+	bits(4)
+	if ver <= r14 {
+		bits(2)
+	}
+	bits(2)
+	if ver <= r14 {
+		bits(1)
+	}
+	bits(3)
+	if ver <= r14 {
+		bits(1)
+	}
+	bits(2)
+	shorts(1)
+	if ver <= r14 {
+		shorts(1)
+	}
+	shorts(5)
+	if ver <= r14 {
+		shorts(1)
+	}
+	shorts(1)
+	if ver <= r14 {
+		shorts(1)
+	}
+	w.BS(3) // PDMODE
+	if ver <= r14 {
+		shorts(1)
+	}
+	if ver >= r2004 {
+		longs(3)
+	}
+	shorts(19)
+	w.BD(2.5) // LTSCALE
+	w.BD(3.5) // TEXTSIZE
+	doubles(5)
+	w.BD(0.75) // PDSIZE
+	doubles(12)
+	w.BD(0.5) // CELTSCALE
+	if ver < r2007 {
+		r.T("acad")
+	}
+	w.BL(2460000) // TDCREATE
+	w.BL(0)
+	w.BL(2460100) // TDUPDATE
+	w.BL(0)
+	if ver >= r2004 {
+		longs(3)
+	}
+	longs(4)
+	w.CMC(256)
+	w.H(0, 0x40) // HANDSEED
+	handles(3)
+	if ver >= r2007 {
+		handles(1)
+	}
+	handles(2)
+	if ver >= r2000 {
+		doubles(1)
+	}
+	ucs := func() {
+		w.BD(0)
+		w.BD3(geom.Vec3{})
+		w.BD3(geom.Vec3{X: 1})
+		w.BD3(geom.Vec3{Y: 1})
+		handles(1)
+		if ver >= r2000 {
+			handles(1)
+			shorts(1)
+			handles(1)
+			for range 6 {
+				w.BD3(geom.Vec3{})
+			}
+		}
+	}
+	w.BD3(geom.Vec3{})
+	w.BD3(geom.Vec3{})
+	w.BD3(geom.Vec3{})
+	w.RD2(geom.Vec2{})
+	w.RD2(geom.Vec2{X: 12, Y: 9})
+	ucs()
+	w.BD3(geom.Vec3{})
+	w.BD3(geom.Vec3{})
+	w.BD3(geom.Vec3{X: 100, Y: 50})
+	w.RD2(geom.Vec2{})
+	w.RD2(geom.Vec2{X: 420, Y: 297})
+	ucs()
+	if ver >= r2000 {
+		r.T("")
+		r.T("")
+	}
+	if ver <= r14 {
+		bits(11)
+		w.RC(0)
+		w.RC(0)
+		bits(2)
+		for range 3 {
+			w.RC(0)
+		}
+		bits(1)
+		for range 4 {
+			w.RC(0)
+		}
+		shorts(6)
+		handles(1)
+	}
+	doubles(9)
+	if ver >= r2007 {
+		doubles(2)
+		shorts(1)
+		w.CMC(0)
+	}
+	if ver >= r2000 {
+		bits(6)
+		shorts(3)
+	}
+	if ver >= r2007 {
+		shorts(1)
+	}
+	doubles(8)
+	if ver <= r14 {
+		for range 5 {
+			r.T("")
+		}
+	}
+	if ver >= r2000 {
+		doubles(1)
+		bits(1)
+		shorts(1)
+		bits(4)
+	}
+	for range 3 {
+		w.CMC(0)
+	}
+	if ver >= r2000 {
+		shorts(11)
+		bits(2)
+		shorts(4)
+		bits(1)
+		shorts(1)
+	}
+	if ver >= r2007 {
+		bits(1)
+	}
+	if ver >= r2010 {
+		bits(1)
+		doubles(1)
+		r.T("")
+		doubles(1)
+		r.T("")
+	}
+	if ver >= r2000 {
+		handles(5)
+	}
+	if ver >= r2007 {
+		handles(3)
+	}
+	if ver >= r2000 {
+		shorts(2)
+	}
+	handles(9)
+	if ver <= r2000 {
+		handles(1)
+	}
+	handles(3)
+	if ver >= r2000 {
+		shorts(2)
+		r.T("")
+		r.T("")
+		handles(3)
+	}
+	if ver >= r2004 {
+		handles(2)
+	}
+	if ver >= r2007 {
+		handles(1)
+	}
+	if ver >= r2013 {
+		handles(1)
+	}
+	if ver >= r2000 {
+		w.BL(0)
+		w.BS(4) // INSUNITS
+		w.BS(0)
+		r.T("")
+		r.T("")
+	}
+	if ver >= r2004 {
+		for range 6 {
+			w.RC(0)
+		}
+		shorts(2)
+		w.RC(0)
+		w.RC(0)
+		r.T("")
+	}
+	r.H(5, 0)           // paper space
+	r.H(5, hModelSpace) // model space
+
+	r.strings()
+	if ver >= r2007 {
+		w.patchRL(bitsAt, uint32(w.n-bitsAt))
+		w.append(r.h)
+	}
+	w.patchRL(sizeAt, uint32(len(w.buf)-(sizeAt/8+4)))
+	return append(w.buf, 0, 0)
+}
+
+func writeClasses(ver version) []byte {
+	r := newRecord(ver, false)
+	w := r.d
+	w.bytes(sampleSentinel)
+	sizeAt := w.n
+	w.RL(0)
+	if ver == r13 {
+		w.patchRL(sizeAt, 0)
+		return append(w.buf, 0, 0)
+	}
+	if ver < r2004 {
+		w.BS(500)
+		w.BS(0)
+		w.T("ObjectDBX Classes")
+		w.T("AcDbPolyline")
+		w.T("LWPOLYLINE")
+		w.B(false)
+		w.BS(0x1f2)
+		w.patchRL(sizeAt, uint32(len(w.buf)-(sizeAt/8+4)))
+		return append(w.buf, 0, 0)
+	}
+	bitsAt := w.n
+	if ver >= r2007 {
+		w.RL(0)
+	}
+	w.BS(500)
+	w.RC(0)
+	w.RC(0)
+	w.B(true)
+	w.BS(500)
+	w.BS(0)
+	r.T("ObjectDBX Classes")
+	r.T("AcDbPolyline")
+	r.T("LWPOLYLINE")
+	w.B(false)
+	w.BS(0x1f2)
+	w.BL(1)
+	for range 4 {
+		w.BL(0)
+	}
+	r.strings()
+	if ver >= r2007 {
+		w.patchRL(bitsAt, uint32(w.n-bitsAt))
+	}
+	w.patchRL(sizeAt, uint32(len(w.buf)-(sizeAt/8+4)))
+	return append(w.buf, 0, 0)
+}
+
+// entity holds the common entity fields the samples vary.
+type entity struct {
+	layer uint64
+	color int16
+}
+
+func writeObject(ver version, typ int, handle uint64, e *entity, fill func(r *record)) []byte {
+	r := newRecord(ver, true)
+	w := r.d
+	if ver >= r2010 {
+		w.OT(typ)
+	} else {
+		w.BS(int16(typ))
+	}
+	bitsAt := -1
+	if ver >= r2000 && ver < r2010 {
+		bitsAt = w.n
+		w.RL(0)
+	}
+	w.H(0, handle)
+	w.BS(0) // no extended data
+	if e != nil {
+		w.B(false) // no graphics
+		if ver <= r14 {
+			bitsAt = w.n
+			w.RL(0)
+		}
+		w.BB(2) // in model space
+		w.BL(0) // reactors
+		if ver >= r2004 {
+			w.B(true) // no extension dictionary
+		}
+		if ver >= r2013 {
+			w.B(false)
+		}
+		if ver <= r14 {
+			w.B(true) // linetype ByLayer
+		}
+		if ver <= r2000 {
+			w.B(true) // no links
+		}
+		w.BS(e.color)
+		w.BD(1)
+		if ver >= r2000 {
+			w.BB(0) // linetype ByLayer
+			w.BB(0) // plot style ByLayer
+		}
+		if ver >= r2007 {
+			w.BB(0)
+			w.RC(0)
+		}
+		if ver >= r2010 {
+			w.B(false)
+			w.B(false)
+			w.B(false)
+		}
+		w.BS(0)
+		if ver >= r2000 {
+			w.RC(29) // lineweight ByLayer
+		}
+		if ver < r2004 {
+			r.H(3, 0) // extension dictionary
+		}
+		r.H(5, e.layer)
+	} else {
+		if ver <= r14 {
+			bitsAt = w.n
+			w.RL(0)
+		}
+		w.BL(0)
+		if ver >= r2004 {
+			w.B(true)
+		}
+		if ver >= r2013 {
+			w.B(false)
+		}
+		r.H(4, 0) // owner
+		if ver < r2004 {
+			r.H(3, 0)
+		}
+	}
+	fill(r)
+	if ver >= r2010 {
+		// The handle stream ends the record, so the padding goes before
+		// the strings.
+		for (w.n+r.s.n+r.trailer()+r.h.n)&7 != 0 {
+			w.B(false)
+		}
+	}
+	r.strings()
+	if bitsAt >= 0 {
+		w.patchRL(bitsAt, uint32(w.n))
+	}
+	hsize := r.h.n
+	w.append(r.h)
+
+	out := appendMS(nil, len(w.buf))
+	if ver >= r2010 {
+		out = appendUMC(out, uint64(hsize))
+	}
+	out = append(out, w.buf...)
+	return append(out, 0, 0)
+}
+
+func writeTableEntry(r *record, name string) {
+	r.T(name)
+	r.d.B(false)
+	r.d.BS(0)
+	r.d.B(false)
+}
+
+func writeLayer(ver version, handle uint64, name string, color int16) []byte {
+	return writeObject(ver, typeLayer, handle, nil, func(r *record) {
+		w := r.d
+		writeTableEntry(r, name)
+		if ver <= r14 {
+			w.B(false)
+			w.B(true) // on
+			w.B(false)
+			w.B(false)
+		} else {
+			w.BS(16 | 31<<5) // plotted, default lineweight
+		}
+		w.CMC(color)
+		r.H(5, 0)
+		if ver >= r2000 {
+			r.H(5, 0)
+		}
+		if ver >= r2007 {
+			r.H(5, 0)
+		}
+		r.H(5, 0)
+	})
+}
+
+func writeModelSpace(ver version, owned []uint64) []byte {
+	return writeObject(ver, typeBlockHeader, hModelSpace, nil, func(r *record) {
+		w := r.d
+		writeTableEntry(r, "*Model_Space")
+		for range 4 {
+			w.B(false)
+		}
+		if ver >= r2000 {
+			w.B(false)
+		}
+		if ver >= r2004 {
+			w.BL(uint32(len(owned)))
+		}
+		w.BD3(geom.Vec3{})
+		r.T("")
+		if ver >= r2000 {
+			w.RC(0)
+			r.T("")
+			w.BL(0)
+		}
+		if ver >= r2007 {
+			w.BS(0)
+			w.B(true)
+			w.RC(0)
+		}
+		r.H(5, 0)
+		r.H(3, 0)
+		if ver < r2004 {
+			r.H(4, owned[0])
+			r.H(4, owned[len(owned)-1])
+		} else {
+			for _, h := range owned {
+				r.H(4, h)
+			}
+		}
+		r.H(3, 0)
+		if ver >= r2000 {
+			r.H(5, 0)
+		}
+	})
+}
+
+func writeLine(ver version) []byte {
+	start, end := geom.Vec3{X: 10, Y: 20}, geom.Vec3{X: 90, Y: 20}
+	return writeObject(ver, typeLine, hLine, &entity{layer: hLayerWalls, color: 256}, func(r *record) {
+		w := r.d
+		if ver <= r14 {
+			w.BD3(start)
+			w.BD3(end)
+		} else {
+			w.B(true)
+			w.RD(start.X)
+			w.DD(end.X, start.X)
+			w.RD(start.Y)
+			w.DD(end.Y, start.Y)
+		}
+		w.BT(0)
+		w.BE(geom.ZAxis)
+	})
+}
+
+func writeCircle(ver version) []byte {
+	return writeObject(ver, typeCircle, hCircle, &entity{layer: hLayer0, color: 1}, func(r *record) {
+		w := r.d
+		w.BD3(geom.Vec3{X: 50, Y: 25})
+		w.BD(12.5)
+		w.BT(0)
+		w.BE(geom.ZAxis)
+	})
+}
+
+func writeText(ver version) []byte {
+	pos := geom.Vec2{X: 5, Y: 40}
+	return writeObject(ver, typeText, hText, &entity{layer: hLayer0, color: 256}, func(r *record) {
+		w := r.d
+		if ver <= r14 {
+			w.BD(0)
+			w.RD2(pos)
+			w.RD2(pos)
+			w.BE(geom.ZAxis)
+			w.BT(0)
+			w.BD(0)
+			w.BD(0)
+			w.BD(3.5)
+			w.BD(1)
+			r.T("Hello DWG")
+			w.BS(0)
+			w.BS(0)
+			w.BS(0)
+		} else {
+			w.RC(0xff &^ 0x08)
+			w.RD2(pos)
+			w.BE(geom.ZAxis)
+			w.BT(0)
+			w.RD(math.Pi / 2)
+			w.RD(3.5)
+			r.T("Hello DWG")
+		}
+		r.H(5, 0)
+	})
+}
+
+func writePolyline(ver version) []byte {
+	pts := []geom.Vec2{{X: 0, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 15}}
+	return writeObject(ver, 500, hPolyline, &entity{layer: hLayerWalls, color: 5}, func(r *record) {
+		w := r.d
+		w.BS(512)
+		w.BL(uint32(len(pts)))
+		for i, p := range pts {
+			if ver <= r14 || i == 0 {
+				w.RD2(p)
+			} else {
+				w.DD(p.X, pts[i-1].X)
+				w.DD(p.Y, pts[i-1].Y)
+			}
+		}
+	})
+}
+
+// writeObjects returns the object records laid out from base and the
+// object map pointing at them.
+func writeObjects(ver version, base int, broken bool) (objects, handles []byte) {
+	entities := []uint64{hLine, hCircle, hText}
+	if ver >= r14 {
+		entities = append(entities, hPolyline)
+	}
+	records := []struct {
+		handle uint64
+		data   []byte
+	}{
+		{hLayer0, writeLayer(ver, hLayer0, "0", 7)},
+		{hLayerWalls, writeLayer(ver, hLayerWalls, "Walls", 3)},
+		{hModelSpace, writeModelSpace(ver, entities)},
+		{hLine, writeLine(ver)},
+		{hCircle, writeCircle(ver)},
+		{hText, writeText(ver)},
+	}
+	if ver >= r14 {
+		records = append(records, struct {
+			handle uint64
+			data   []byte
+		}{hPolyline, writePolyline(ver)})
+	}
+
+	var body []byte
+	var prevHandle uint64
+	var prevOffset int64
+	entry := func(handle uint64, offset int64) {
+		body = appendUMC(body, handle-prevHandle)
+		body = appendMC(body, offset-prevOffset)
+		prevHandle, prevOffset = handle, offset
+	}
+	for _, rec := range records {
+		entry(rec.handle, int64(base+len(objects)))
+		objects = append(objects, rec.data...)
+	}
+	if broken {
+		entry(sampleBroken, int64(base+len(objects)+1000))
+	}
+	handles = binary.BigEndian.AppendUint16(nil, uint16(len(body)+2))
+	handles = append(handles, body...)
+	handles = append(handles, 0, 0)
+	return objects, append(handles, 0, 2, 0, 0)
+}
+
+func writeSummary(ver version) []byte {
+	var b []byte
+	str := func(s string) {
+		if ver >= r2007 {
+			u := utf16.Encode([]rune(s))
+			b = binary.LittleEndian.AppendUint16(b, uint16(len(u)+1))
+			for _, c := range u {
+				b = binary.LittleEndian.AppendUint16(b, c)
+			}
+			b = append(b, 0, 0)
+			return
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(s)+1))
+		b = append(append(b, s...), 0)
+	}
+	for _, s := range []string{"Sample", "", "dwgtopdf", "", "", "", "", ""} {
+		str(s)
+	}
+	b = append(b, make([]byte, 24)...)
+	return binary.LittleEndian.AppendUint16(b, 0)
+}
+
+// writeSample returns the sample drawing in the given version; broken
+// adds an object map entry no object can be decoded from.
+func writeSample(tag string, broken bool) []byte {
+	ver := versionTags[tag]
+	head := make([]byte, 0x100)
+	copy(head, tag)
+	binary.LittleEndian.PutUint16(head[0x13:], sampleCodepage)
+
+	switch {
+	case ver <= r2000:
+		return writeFile2000(ver, head, broken)
+	case ver == r2007:
+		return writeFile2007(ver, head, broken)
+	}
+	return writeFile2004(ver, head, broken)
+}
+
+func writeFile2000(ver version, head []byte, broken bool) []byte {
+	header, classes := writeHeader(ver), writeClasses(ver)
+	data := append(head, header...)
+	data = append(data, classes...)
+	objects, handles := writeObjects(ver, len(data), broken)
+	data = append(data, objects...)
+	locators := []struct {
+		num        uint8
+		addr, size int
+	}{
+		{0, 0x100, len(header)},
+		{1, 0x100 + len(header), len(classes)},
+		{2, len(data), len(handles)},
+	}
+	data = append(data, handles...)
+	binary.LittleEndian.PutUint32(data[0x15:], uint32(len(locators)))
+	for i, l := range locators {
+		rec := data[0x19+i*9:]
+		rec[0] = l.num
+		binary.LittleEndian.PutUint32(rec[1:], uint32(l.addr))
+		binary.LittleEndian.PutUint32(rec[5:], uint32(l.size))
+	}
+	return data
+}
+
+// sampleSections returns the sections of an R2004+ sample in the order
+// they are stored.
+func sampleSections(ver version, broken bool) []struct {
+	name string
+	data []byte
+} {
+	objects, handles := writeObjects(ver, 0, broken)
+	return []struct {
+		name string
+		data []byte
+	}{
+		{sectionHeader, writeHeader(ver)},
+		{sectionClasses, writeClasses(ver)},
+		{sectionObjects, objects},
+		{sectionHandles, handles},
+		{sectionSummary, writeSummary(ver)},
+	}
+}
+
+// compress2004 stores b as a single literal run.
+func compress2004(b []byte) []byte {
+	var out []byte
+	if n := len(b); n <= 18 {
+		out = append(out, byte(n-3))
+	} else {
+		n -= 18
+		out = append(out, 0)
+		for ; n > 255; n -= 255 {
+			out = append(out, 0)
+		}
+		out = append(out, byte(n))
+	}
+	out = append(out, b...)
+	return append(out, 0x11)
+}
+
+func writeFile2004(ver version, head []byte, broken bool) []byte {
+	le := binary.LittleEndian
+	data := head
+	type page struct{ number, size int }
+	var pages []page
+	var sm []byte
+	sm = le.AppendUint32(sm, 0)
+	sm = append(sm, make([]byte, 16)...)
+	sections := sampleSections(ver, broken)
+	le.PutUint32(sm, uint32(len(sections)))
+	for i, s := range sections {
+		addr := len(data)
+		src := compress2004(s.data)
+		mask := 0x4164536b ^ uint32(addr)
+		hdr := [8]uint32{0x4163043b, uint32(i + 1), uint32(len(src)), uint32(len(s.data))}
+		for _, v := range hdr {
+			data = le.AppendUint32(data, v^mask)
+		}
+		data = append(data, src...)
+		pages = append(pages, page{number: i + 1, size: len(data) - addr})
+
+		desc := make([]byte, 96)
+		le.PutUint64(desc, uint64(len(s.data)))
+		le.PutUint32(desc[8:], 1)
+		le.PutUint32(desc[12:], uint32(len(s.data)))
+		le.PutUint32(desc[20:], 2)
+		le.PutUint32(desc[24:], uint32(i+1))
+		copy(desc[32:], s.name)
+		sm = append(sm, desc...)
+		sm = le.AppendUint32(sm, uint32(i+1))
+		sm = le.AppendUint32(sm, uint32(len(s.data)))
+		sm = le.AppendUint64(sm, 0)
+	}
+	system := func(typ uint32, payload []byte) {
+		for _, v := range []uint32{typ, uint32(len(payload)), uint32(len(payload)), 1, 0} {
+			data = le.AppendUint32(data, v)
+		}
+		data = append(data, payload...)
+	}
+	smNumber := len(pages) + 1
+	addr := len(data)
+	system(0x4163003b, sm)
+	pages = append(pages, page{number: smNumber, size: len(data) - addr})
+
+	pmAddr := len(data)
+	pmSize := 20 + 8*(len(pages)+1)
+	pages = append(pages, page{number: smNumber + 1, size: pmSize})
+	var pm []byte
+	for _, p := range pages {
+		pm = le.AppendUint32(pm, uint32(p.number))
+		pm = le.AppendUint32(pm, uint32(p.size))
+	}
+	system(0x41630e3b, pm)
+
+	hdr := make([]byte, 0x6c)
+	copy(hdr, "AcFssFcAJMB")
+	le.PutUint64(hdr[0x54:], uint64(pmAddr-0x100))
+	le.PutUint32(hdr[0x5c:], uint32(smNumber))
+	copy(data[0x80:], decryptHeader2004(hdr))
+	return data
+}
+
+// encodeRS interleaves b into blocks of k data bytes, leaving the parity
+// bytes zero as the reader does not check them.
+func encodeRS(b []byte, blocks, k int) []byte {
+	out := make([]byte, blocks*255)
+	for i := range blocks {
+		for j := range k {
+			if i*k+j < len(b) {
+				out[i+j*blocks] = b[i*k+j]
+			}
+		}
+	}
+	return out
+}
+
+func writeFile2007(ver version, head []byte, broken bool) []byte {
+	const base = 0x480
+	le := binary.LittleEndian
+	sections := sampleSections(ver, broken)
+
+	// Data pages are numbered from 3, after the page and section maps.
+	var sm []byte
+	var dataPages [][]byte
+	for i, s := range sections {
+		size := (len(s.data) + 7) &^ 7
+		coded := encodeRS(s.data, (size+250)/251, 251)
+		dataPages = append(dataPages, coded)
+
+		name := utf16.Encode([]rune(s.name + "\x00"))
+		desc := make([]byte, 64)
+		le.PutUint64(desc, uint64(len(s.data)))
+		le.PutUint64(desc[32:], uint64(2*len(name)))
+		le.PutUint64(desc[56:], 1)
+		sm = append(sm, desc...)
+		for _, c := range name {
+			sm = le.AppendUint16(sm, c)
+		}
+		pg := make([]byte, 56)
+		le.PutUint64(pg[8:], uint64(len(coded)))
+		le.PutUint64(pg[16:], uint64(i+3))
+		le.PutUint64(pg[24:], uint64(len(s.data)))
+		le.PutUint64(pg[32:], uint64(len(s.data)))
+		sm = append(sm, pg...)
+	}
+	system := func(b []byte) []byte {
+		coded := encodeRS(b, ((len(b)+7)&^7+238)/239, 239)
+		return pad(coded, (len(coded)+7)&^7)
+	}
+	smPage := system(sm)
+	pmLen := 16 * (2 + len(dataPages))
+	pmPageSize := len(system(make([]byte, pmLen)))
+	var pm []byte
+	pm = le.AppendUint64(pm, uint64(pmPageSize))
+	pm = le.AppendUint64(pm, 1)
+	pm = le.AppendUint64(pm, uint64(len(smPage)))
+	pm = le.AppendUint64(pm, 2)
+	for i, p := range dataPages {
+		pm = le.AppendUint64(pm, uint64(len(p)))
+		pm = le.AppendUint64(pm, uint64(i+3))
+	}
+
+	data := pad(head, base)
+	data = append(data, system(pm)...)
+	data = append(data, smPage...)
+	for _, p := range dataPages {
+		data = append(data, p...)
+	}
+
+	hdr := make([]byte, 0x110)
+	q := func(i int, v int) { le.PutUint64(hdr[i*8:], uint64(v)) }
+	q(3, 1)
+	q(7, 0)
+	q(10, len(pm))
+	q(11, len(pm))
+	q(22, len(sm))
+	q(24, 2)
+	q(25, len(sm))
+	q(27, 1)
+	pe := make([]byte, 32, 3*239)
+	pe = append(pe, hdr...)
+	copy(data[0x80:0x80+0x3d8], encodeRS(pe, 3, 239))
+	return data
+}
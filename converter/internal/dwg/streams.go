@@ -0,0 +1,61 @@
+package dwg
+
+import "github.com/you-humble/dwgtopdf/converter/internal/drawing"
+
+// streams bundles the three bit streams a record is split into: plain
+// data, strings (separate since R2007) and handle references (separate for
+// objects since R13 and for the header since R2007).
+type streams struct {
+	d *bitReader
+	s *bitReader
+	h *bitReader
+	// own is the handle of the record, used to resolve relative references.
+	own uint64
+}
+
+func (st *streams) T() string {
+	if st.s == nil {
+		return ""
+	}
+	return st.s.T()
+}
+
+func (st *streams) H() uint64 {
+	return st.h.H().abs(st.own)
+}
+
+func (st *streams) CMC() drawing.Color { return st.d.cmc(st.T) }
+
+func (st *streams) err() error {
+	for _, r := range []*bitReader{st.d, st.s, st.h} {
+		if r != nil && r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+// stringStream locates the R2007+ string stream that ends right before
+// endBit. It returns nil when the record has no strings.
+func stringStream(r *bitReader, endBit int) *bitReader {
+	if endBit <= 0 {
+		return nil
+	}
+	f := r.sub(endBit-1, endBit)
+	if !f.B() || f.err != nil {
+		return nil
+	}
+	pos := endBit - 1 - 16
+	sz := r.sub(pos, endBit)
+	size := int(sz.RS())
+	if size&0x8000 != 0 {
+		pos -= 16
+		hi := r.sub(pos, endBit)
+		size = size&0x7fff | int(hi.RS())<<15
+	}
+	start := pos - size
+	if start < 0 || sz.err != nil {
+		return nil
+	}
+	return r.sub(start, pos)
+}
@@ -0,0 +1,48 @@
+package dwg
+
+import "github.com/you-humble/dwgtopdf/converter/internal/drawing"
+
+const sectionSummary = "AcDb:SummaryInfo"
+
+// readSummary decodes the drawing properties of R2004 and later files:
+// eight strings, the editing time and the creation and update dates,
+// then the custom properties as name and value pairs. Strings have a
+// 16-bit length and are in the code page before R2007 and UTF-16 since.
+func (f *file) readSummary(s *drawing.Summary) {
+	sec, ok := f.sections[sectionSummary]
+	if !ok {
+		return
+	}
+	r := newBitReader(sec, f.ver, f.cp)
+	str := func() string {
+		n := int(r.RS())
+		if f.ver < r2007 {
+			b := r.bytes(n)
+			if i := indexZero(b); i >= 0 {
+				b = b[:i]
+			}
+			return f.cp.decode(b)
+		}
+		return r.utf16(n)
+	}
+
+	var sum drawing.Summary
+	for _, p := range []*string{
+		&sum.Title, &sum.Subject, &sum.Author, &sum.Keywords,
+		&sum.Comments, &sum.LastSavedBy, &sum.Revision, &sum.HyperlinkBase,
+	} {
+		*p = str()
+	}
+	// Editing time, creation and update dates.
+	r.bytes(24)
+	for n := int(r.RS()); n > 0 && r.err == nil; n-- {
+		name, value := str(), str()
+		if name != "" && r.err == nil {
+			sum.Custom = append(sum.Custom, drawing.Property{Name: name, Value: value})
+		}
+	}
+	if r.err != nil && sum.Title == "" && sum.Author == "" {
+		return
+	}
+	*s = sum
+}
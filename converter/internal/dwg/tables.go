@@ -0,0 +1,301 @@
+package dwg
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+type blockRecord struct {
+	block  *drawing.Block
+	owned  []uint64
+	layout uint64
+}
+
+type layerRecord struct {
+	layer     *drawing.Layer
+	ltype     uint64
+	plotStyle uint64
+}
+
+type ltypeRecord struct {
+	ltype  *drawing.Linetype
+	styles []uint64
+}
+
+type dictionary struct {
+	names []string
+	items []uint64
+}
+
+func (d *dictionary) get(name string) uint64 {
+	for i, n := range d.names {
+		if n == name && i < len(d.items) {
+			return d.items[i]
+		}
+	}
+	return 0
+}
+
+// tableEntry reads the fields shared by all symbol table records and
+// returns the record name.
+func tableEntry(st *streams) string {
+	name := st.T()
+	st.d.B()  // 64-flag
+	st.d.BS() // xref index
+	st.d.B()  // xref dependent
+	return name
+}
+
+func (f *file) decodeBlockHeader(o *object, st *streams) error {
+	r := st.d
+	name := tableEntry(st)
+	b := &drawing.Block{Handle: drawing.Handle(o.handle), Name: name}
+	b.Anonymous = r.B()
+	b.HasAttDefs = r.B()
+	b.Xref = r.B()
+	b.XrefOverlay = r.B()
+	if b.XrefOverlay {
+		b.Xref = true
+	}
+	if f.ver >= r2000 {
+		r.B() // loaded xref
+	}
+	var owned int
+	if f.ver >= r2004 && !b.Xref {
+		owned = int(r.BL())
+	}
+	b.Base = r.BD3()
+	b.XrefPath = st.T()
+	var inserts int
+	if f.ver >= r2000 {
+		for r.RC() != 0 && r.err == nil {
+			inserts++
+		}
+		b.Description = st.T()
+		if n := int(r.BL()); n > 0 {
+			r.bytes(n)
+		}
+	}
+	if f.ver >= r2007 {
+		r.BS() // insert units
+		r.B()  // explodable
+		r.RC() // block scaling
+	}
+
+	rec := &blockRecord{block: b}
+	st.H() // xref
+	st.H() // BLOCK entity
+	if !b.Xref {
+		if f.ver < r2004 {
+			st.H() // first entity
+			st.H() // last entity
+		} else {
+			if owned < 0 || owned > st.h.end-st.h.pos {
+				return errShortRead
+			}
+			for i := 0; i < owned && st.h.err == nil; i++ {
+				rec.owned = append(rec.owned, st.H())
+			}
+		}
+	}
+	st.H() // ENDBLK
+	if f.ver >= r2000 {
+		for i := 0; i < inserts && st.h.err == nil; i++ {
+			st.H()
+		}
+		rec.layout = st.H()
+	}
+	o.rec = rec
+	return nil
+}
+
+func (f *file) decodeLayer(o *object, st *streams) error {
+	r := st.d
+	name := tableEntry(st)
+	l := &drawing.Layer{Handle: drawing.Handle(o.handle), Name: name, Plot: true, Lineweight: drawing.LineweightDefault}
+	if f.ver <= r14 {
+		l.Frozen = r.B()
+		l.Off = !r.B()
+		r.B() // frozen in new viewports
+		l.Locked = r.B()
+	} else {
+		flags := int(r.BS())
+		l.Frozen = flags&1 != 0
+		l.Off = flags&2 != 0
+		l.Locked = flags&8 != 0
+		l.Plot = flags&16 != 0
+		l.Lineweight = drawing.LineweightFromIndex((flags & 0x3e0) >> 5)
+	}
+	l.Color = st.CMC()
+	if l.Color.Index < 0 {
+		l.Off = true
+		l.Color.Index = -l.Color.Index
+	}
+
+	rec := &layerRecord{layer: l}
+	st.H() // xref
+	if f.ver >= r2000 {
+		rec.plotStyle = st.H()
+	}
+	if f.ver >= r2007 {
+		st.H() // material
+	}
+	rec.ltype = st.H()
+	o.rec = rec
+	return nil
+}
+
+func (f *file) decodeView(o *object, st *streams) error {
+	r := st.d
+	v := &drawing.View{Handle: drawing.Handle(o.handle), Name: tableEntry(st)}
+	v.Height = r.BD()
+	v.Width = r.BD()
+	v.Center = r.RD2()
+	v.Target = r.BD3()
+	v.Direction = r.BD3()
+	v.Twist = r.BD()
+	r.BD()    // lens length
+	r.BD()    // front clip
+	r.BD()    // back clip
+	r.bits(4) // view mode
+	if f.ver >= r2000 {
+		r.RC() // render mode
+	}
+	if f.ver >= r2007 {
+		r.B()  // default lighting
+		r.RC() // default lighting type
+		r.BD() // brightness
+		r.BD() // contrast
+		st.CMC()
+	}
+	v.PaperSpace = r.B()
+	if v.Direction.IsZero() {
+		v.Direction = geom.ZAxis
+	}
+	o.rec = v
+	return nil
+}
+
+func (f *file) decodeStyle(o *object, st *streams) error {
+	r := st.d
+	name := tableEntry(st)
+	s := &drawing.TextStyle{Handle: drawing.Handle(o.handle), Name: name}
+	s.Vertical = r.B()
+	s.ShapeFile = r.B()
+	s.Height = r.BD()
+	s.WidthFactor = r.BD()
+	s.Oblique = r.BD()
+	gen := r.RC()
+	s.Backward = gen&drawing.TextBackward != 0
+	s.UpsideDown = gen&drawing.TextUpsideDown != 0
+	r.BD() // last height
+	s.FontFile = st.T()
+	s.BigFontFile = st.T()
+	st.H() // xref
+	o.rec = s
+	return nil
+}
+
+func (f *file) decodeLtype(o *object, st *streams) error {
+	r := st.d
+	name := tableEntry(st)
+	lt := &drawing.Linetype{Handle: drawing.Handle(o.handle), Name: name}
+	lt.Description = st.T()
+	lt.Length = r.BD()
+	r.RC() // alignment
+	n := int(r.RC())
+	var hasText bool
+	texts := make(map[int]int)
+	for i := range n {
+		e := drawing.LinetypeElement{Length: r.BD()}
+		code := int(r.BS())
+		e.Offset.X = r.RD()
+		e.Offset.Y = r.RD()
+		e.Scale = r.BD()
+		e.Rotation = r.BD()
+		flags := r.BS()
+		e.Absolute = flags&1 != 0
+		if flags&2 != 0 {
+			hasText = true
+			texts[i] = code
+		} else if flags&4 != 0 {
+			e.Shape = code
+		}
+		lt.Elements = append(lt.Elements, e)
+	}
+	var area []byte
+	if f.ver <= r2004 {
+		area = r.bytes(256)
+	} else if hasText {
+		area = r.bytes(512)
+	}
+	for i, off := range texts {
+		lt.Elements[i].Text = f.ltypeText(area, off)
+	}
+
+	rec := &ltypeRecord{ltype: lt}
+	st.H() // xref
+	for range n {
+		rec.styles = append(rec.styles, st.H())
+	}
+	o.rec = rec
+	return nil
+}
+
+// ltypeText extracts a NUL terminated text of a complex linetype from the
+// strings area of the LTYPE record.
+func (f *file) ltypeText(area []byte, off int) string {
+	if off < 0 || off >= len(area) {
+		return ""
+	}
+	if f.ver < r2007 {
+		b := area[off:]
+		if i := indexZero(b); i >= 0 {
+			b = b[:i]
+		}
+		return f.cp.decode(b)
+	}
+	var u []uint16
+	for p := off; p+1 < len(area); p += 2 {
+		c := binary.LittleEndian.Uint16(area[p:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// decodeDBColor reads a color of a color book, which entities refer to
+// for its name.
+func (f *file) decodeDBColor(o *object, st *streams) error {
+	o.rec = st.CMC()
+	return nil
+}
+
+func (f *file) decodeDictionary(o *object, st *streams) error {
+	r := st.d
+	n := int(r.BL())
+	if f.ver == r14 {
+		r.RC()
+	}
+	if f.ver >= r2000 {
+		r.BS() // cloning flag
+		r.RC() // hard owner
+	}
+	if n < 0 || n > r.end-r.pos {
+		return errShortRead
+	}
+	d := &dictionary{}
+	for range n {
+		d.names = append(d.names, st.T())
+	}
+	for range n {
+		d.items = append(d.items, st.H())
+	}
+	o.rec = d
+	return nil
+}
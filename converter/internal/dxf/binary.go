@@ -0,0 +1,165 @@
+package dxf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var binarySentinel = []byte("AutoCAD Binary DXF\r\n\x1a\x00")
+
+func isBinary(data []byte) bool {
+	return bytes.HasPrefix(data, binarySentinel)
+}
+
+// readBinary splits a binary DXF file into tags, formatting numeric values
+// the way an ASCII file stores them so both readers share the parser.
+// Files before R14 store group codes in one byte, with 255 escaping a
+// following 16-bit code; later files always use 16 bits.
+func readBinary(data []byte) ([]tag, error) {
+	r := &binReader{data: data, pos: len(binarySentinel)}
+	// The first tag is always 0/SECTION: with 16-bit codes the byte after
+	// the code is the second half of the code instead of 'S'.
+	r.wide = len(data) > r.pos+1 && data[r.pos+1] == 0
+
+	var tags []tag
+	for r.pos < len(r.data) {
+		code, err := r.code()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.value(code)
+		if err != nil {
+			return nil, fmt.Errorf("offset %d: group code %d: %w", r.pos, code, err)
+		}
+		tags = append(tags, tag{code: code, value: value})
+		if tags[len(tags)-1].is(0, "EOF") {
+			break
+		}
+	}
+	return tags, nil
+}
+
+type binReader struct {
+	data []byte
+	pos  int
+	wide bool
+}
+
+func (r *binReader) take(n int) ([]byte, error) {
+	if r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("offset %d: unexpected end of data", r.pos)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *binReader) code() (int, error) {
+	if r.wide {
+		b, err := r.take(2)
+		if err != nil {
+			return 0, err
+		}
+		return int(int16(binary.LittleEndian.Uint16(b))), nil
+	}
+	b, err := r.take(1)
+	if err != nil {
+		return 0, err
+	}
+	if b[0] != 255 {
+		return int(b[0]), nil
+	}
+	b, err = r.take(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(int16(binary.LittleEndian.Uint16(b))), nil
+}
+
+func (r *binReader) value(code int) (string, error) {
+	switch valueType(code) {
+	case typeFloat:
+		b, err := r.take(8)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)), 'g', -1, 64), nil
+	case typeInt16:
+		b, err := r.take(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b)))), nil
+	case typeInt32:
+		b, err := r.take(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b)))), nil
+	case typeInt64:
+		b, err := r.take(8)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(b)), 10), nil
+	case typeBool:
+		b, err := r.take(1)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(b[0])), nil
+	case typeBinary:
+		n, err := r.take(1)
+		if err != nil {
+			return "", err
+		}
+		b, err := r.take(int(n[0]))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%X", b), nil
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s, nil
+}
+
+type groupType int
+
+const (
+	typeString groupType = iota
+	typeFloat
+	typeInt16
+	typeInt32
+	typeInt64
+	typeBool
+	typeBinary
+)
+
+// valueType reports how the value of a group code is stored.
+func valueType(code int) groupType {
+	switch {
+	case code >= 10 && code <= 59, code >= 110 && code <= 149, code >= 210 && code <= 239,
+		code >= 460 && code <= 469, code >= 1010 && code <= 1059:
+		return typeFloat
+	case code >= 60 && code <= 79, code >= 170 && code <= 179, code >= 270 && code <= 289,
+		code >= 370 && code <= 389, code >= 400 && code <= 409, code >= 1060 && code <= 1070:
+		return typeInt16
+	case code >= 90 && code <= 99, code >= 420 && code <= 429, code >= 440 && code <= 459, code == 1071:
+		return typeInt32
+	case code >= 160 && code <= 169:
+		return typeInt64
+	case code >= 290 && code <= 299:
+		return typeBool
+	case code >= 310 && code <= 319, code == 1004:
+		return typeBinary
+	}
+	return typeString
+}
@@ -0,0 +1,202 @@
+package dxf
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the binary files in testdata from the ASCII ones")
+
+// TestReadBinary reads every file in testdata/ascii and its binary copy in
+// testdata/binary, which must give the same drawing.
+func TestReadBinary(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "ascii", "*.dxf"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no ASCII files: %v", err)
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", "binary", name)
+			if *update {
+				if err := os.WriteFile(path, toBinary(t, text), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isBinary(data) {
+				t.Fatalf("%s is not a binary DXF file", path)
+			}
+
+			want, err := Decode(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Error("the binary file reads differently from the ASCII one")
+			}
+		})
+	}
+}
+
+// groupTypes are the value types of group codes as the DXF reference
+// lists them, written out here rather than taken from valueType so that
+// the binary files do not follow the reader's own mistakes. The first
+// range a code falls in wins.
+var groupTypes = []struct {
+	from, to int
+	kind     string
+}{
+	{0, 9, "string"},
+	{10, 59, "double"},
+	{60, 79, "int16"},
+	{90, 99, "int32"},
+	{100, 109, "string"},
+	{110, 149, "double"},
+	{160, 169, "int64"},
+	{170, 179, "int16"},
+	{210, 239, "double"},
+	{270, 289, "int16"},
+	{290, 299, "bool"},
+	{300, 309, "string"},
+	{310, 319, "binary"},
+	{320, 369, "string"},
+	{370, 389, "int16"},
+	{390, 399, "string"},
+	{400, 409, "int16"},
+	{410, 419, "string"},
+	{420, 429, "int32"},
+	{430, 439, "string"},
+	{440, 459, "int32"},
+	{460, 469, "double"},
+	{470, 481, "string"},
+	{999, 999, "string"},
+	{1004, 1004, "binary"},
+	{1000, 1009, "string"},
+	{1010, 1059, "double"},
+	{1060, 1070, "int16"},
+	{1071, 1071, "int32"},
+}
+
+func codeKind(code int) string {
+	for _, g := range groupTypes {
+		if code >= g.from && code <= g.to {
+			return g.kind
+		}
+	}
+	return ""
+}
+
+func TestValueType(t *testing.T) {
+	kinds := map[groupType]string{
+		typeString: "string", typeFloat: "double", typeInt16: "int16", typeInt32: "int32",
+		typeInt64: "int64", typeBool: "bool", typeBinary: "binary",
+	}
+	for code := 0; code <= 1071; code++ {
+		want := codeKind(code)
+		if want == "" {
+			continue
+		}
+		if got := kinds[valueType(code)]; got != want {
+			t.Errorf("group code %d reads as %s, want %s", code, got, want)
+		}
+	}
+}
+
+// toBinary writes the code and value lines of an ASCII file in the binary
+// format, with one byte group codes up to R12 as AutoCAD does.
+func toBinary(t *testing.T, text []byte) []byte {
+	lines := strings.Split(strings.ReplaceAll(string(text), "\r\n", "\n"), "\n")
+	if n := len(lines); n%2 == 1 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	if len(lines)%2 == 1 {
+		t.Fatal("the file ends in the middle of a group")
+	}
+	type group struct {
+		code  int
+		value string
+	}
+	groups := make([]group, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		code, err := strconv.Atoi(strings.TrimSpace(lines[i]))
+		if err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		groups = append(groups, group{code, lines[i+1]})
+	}
+	wide := true
+	for i, g := range groups[:len(groups)-1] {
+		if g.code == 9 && strings.TrimSpace(g.value) == "$ACADVER" {
+			wide = strings.TrimSpace(groups[i+1].value) > "AC1009"
+		}
+	}
+
+	le := binary.LittleEndian
+	out := []byte("AutoCAD Binary DXF\r\n\x1a\x00")
+	for _, g := range groups {
+		switch {
+		case wide:
+			out = le.AppendUint16(out, uint16(g.code))
+		case g.code < 255:
+			out = append(out, byte(g.code))
+		default:
+			out = le.AppendUint16(append(out, 255), uint16(g.code))
+		}
+		v := strings.TrimSpace(g.value)
+		kind := codeKind(g.code)
+		switch kind {
+		case "double":
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatalf("group code %d: %v", g.code, err)
+			}
+			out = le.AppendUint64(out, math.Float64bits(f))
+		case "int16", "int32", "int64", "bool":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				t.Fatalf("group code %d: %v", g.code, err)
+			}
+			switch kind {
+			case "int16":
+				out = le.AppendUint16(out, uint16(n))
+			case "int32":
+				out = le.AppendUint32(out, uint32(n))
+			case "int64":
+				out = le.AppendUint64(out, uint64(n))
+			default:
+				out = append(out, byte(n))
+			}
+		case "binary":
+			b, err := hex.DecodeString(v)
+			if err != nil {
+				t.Fatalf("group code %d: %v", g.code, err)
+			}
+			out = append(append(out, byte(len(b))), b...)
+		case "string":
+			out = append(append(out, g.value...), 0)
+		default:
+			t.Fatalf("group code %d has no type", g.code)
+		}
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package dxf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// pendingDimStyle holds the text style and arrow block handles of a
+// DIMSTYLE entry; BLOCK_RECORD follows DIMSTYLE in the TABLES section, so
+// they are resolved once the file is read.
+type pendingDimStyle struct {
+	ds      *drawing.DimStyle
+	handles map[int]string
+}
+
+func (p *parser) dimStyle(rec []tag) {
+	ds := drawing.NewDimStyle(str(rec, 2))
+	h, _ := strconv.ParseUint(strings.TrimSpace(str(rec, 105)), 16, 64)
+	ds.Handle = drawing.Handle(h)
+	pd := &pendingDimStyle{ds: ds, handles: make(map[int]string)}
+	group := false
+	for _, t := range rec {
+		if t.code == 1001 {
+			break
+		}
+		if t.code == 102 {
+			group = strings.HasPrefix(strings.TrimSpace(t.value), "{")
+			continue
+		}
+		switch {
+		case group, t.code == 2, t.code == 70, t.code == 105:
+		case t.code >= 340 && t.code <= 344:
+			pd.handles[t.code] = strings.TrimSpace(t.value)
+		default:
+			ds.Set(t.code, t.value)
+		}
+	}
+	if ds.Name == "" {
+		return
+	}
+	p.dimStyles = append(p.dimStyles, pd)
+	p.d.AddDimStyle(ds)
+}
+
+// resolveDimVar returns the name of the text style or block a handle
+// valued dimension variable refers to.
+func (p *parser) resolveDimVar(code int, h string) string {
+	if code == 340 {
+		return p.styles[h]
+	}
+	return p.blocks[h]
+}
+
+func (p *parser) dimension(typ string, rec []tag) *drawing.Dimension {
+	d := &drawing.Dimension{
+		EntityProps:   props(rec),
+		Block:         str(rec, 2),
+		Style:         strOr(rec, 3, "Standard"),
+		DefPoint:      point(rec, 10),
+		TextMidPoint:  point(rec, 11),
+		DefPoint2:     point(rec, 13),
+		DefPoint3:     point(rec, 14),
+		DefPoint4:     point(rec, 15),
+		DefPoint5:     point(rec, 16),
+		Text:          str(rec, 1),
+		TextRotation:  geom.Rad(float(rec, 53)),
+		HorizontalDir: geom.Rad(float(rec, 51)),
+		Rotation:      geom.Rad(float(rec, 50)),
+		Oblique:       geom.Rad(float(rec, 52)),
+		LeaderLength:  float(rec, 40),
+		Measurement:   float(rec, 42),
+		Extrusion:     extrusion(rec),
+	}
+	flags := integer(rec, 70)
+	d.Type, d.Flags = flags&7, flags&^7
+	if typ == "ARC_DIMENSION" {
+		d.Type = drawing.DimArcLength
+	}
+
+	// The overrides are code-value pairs between braces after the
+	// DSTYLE string.
+	x := xdata(rec, "ACAD")
+	for i := 0; i < len(x); i++ {
+		if x[i].code != 1000 || !strings.EqualFold(strings.TrimSpace(x[i].value), "DSTYLE") {
+			continue
+		}
+		for i += 2; i+1 < len(x) && x[i].code == 1070; i += 2 {
+			code, v := x[i].int(), strings.TrimSpace(x[i+1].value)
+			if x[i+1].code == 1005 {
+				if v = p.resolveDimVar(code, v); v == "" {
+					continue
+				}
+			}
+			d.Overrides = append(d.Overrides, drawing.DimOverride{Code: code, Value: v})
+		}
+		break
+	}
+	return d
+}
@@ -0,0 +1,396 @@
+package dxf
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// Entities that older versions lack are written as the ones they have
+// that look the same: curves as polylines through points on them, text
+// as lines of TEXT, hatches as their boundaries.
+
+// curveSegments is the number of segments of a full turn of a curve.
+const curveSegments = 64
+
+// splineSamples is the number of segments of a span between two knots.
+const splineSamples = 16
+
+func (w *writer) ellipseR12(e *drawing.Ellipse) {
+	w.lose("ELLIPSE entities were written as POLYLINE")
+	n := e.Extrusion
+	if n.IsZero() {
+		n = geom.ZAxis
+	}
+	minor := n.Unit().Cross(e.MajorAxis).Unit().Scale(e.MajorAxis.Len() * e.Ratio)
+	t0, t1 := e.StartParam, e.EndParam
+	for t1 <= t0 {
+		t1 += 2 * math.Pi
+	}
+	full := t1-t0 >= 2*math.Pi-1e-9
+	pts := ellipsePoints(e.Center, e.MajorAxis, minor, t0, t1)
+	if full {
+		pts = pts[:len(pts)-1]
+	}
+	w.polyline(through(e.EntityProps, pts, full))
+}
+
+func (w *writer) splineR12(e *drawing.Spline) {
+	w.lose("SPLINE entities were written as POLYLINE")
+	pts := splinePoints(e)
+	if len(pts) < 2 {
+		return
+	}
+	closed := e.Flags&drawing.SplineClosed != 0 && pts[0].Sub(pts[len(pts)-1]).IsZero()
+	if closed {
+		pts = pts[:len(pts)-1]
+	}
+	w.polyline(through(e.EntityProps, pts, closed))
+}
+
+// through returns a polyline through pts: a 2D one at their elevation
+// when they all have the same Z, else a 3D one.
+func through(p drawing.EntityProps, pts []geom.Vec3, closed bool) *drawing.Polyline {
+	pl := &drawing.Polyline{EntityProps: p}
+	if closed {
+		pl.Flags |= drawing.PolylineClosed
+	}
+	if len(pts) > 0 {
+		pl.Elevation = pts[0].Z
+	}
+	for _, q := range pts {
+		if math.Abs(q.Z-pl.Elevation) > 1e-9 {
+			pl.Flags |= drawing.Polyline3D
+			pl.Elevation = 0
+			break
+		}
+	}
+	for _, q := range pts {
+		pl.Vertices = append(pl.Vertices, drawing.Vertex{Position: q})
+	}
+	return pl
+}
+
+// ellipsePoints samples c + u·cos t + v·sin t from t0 to t1.
+func ellipsePoints(c, u, v geom.Vec3, t0, t1 float64) []geom.Vec3 {
+	n := max(4, int(math.Ceil(math.Abs(t1-t0)/(2*math.Pi)*curveSegments)))
+	pts := make([]geom.Vec3, 0, n+1)
+	for i := range n + 1 {
+		t := t0 + (t1-t0)*float64(i)/float64(n)
+		pts = append(pts, c.Add(u.Scale(math.Cos(t))).Add(v.Scale(math.Sin(t))))
+	}
+	return pts
+}
+
+// splinePoints samples a spline from its control points, or returns its
+// fit points when it has no valid control polygon.
+func splinePoints(s *drawing.Spline) []geom.Vec3 {
+	deg, ctrl, knots := s.Degree, s.Control, s.Knots
+	if deg < 1 || len(ctrl) <= deg || len(knots) != len(ctrl)+deg+1 {
+		return s.Fit
+	}
+	var weights []float64
+	if len(s.Weights) == len(ctrl) {
+		weights = s.Weights
+	}
+	u0, u1 := knots[deg], knots[len(ctrl)]
+	n := splineSamples * (len(ctrl) - deg)
+	pts := make([]geom.Vec3, 0, n+1)
+	for i := range n + 1 {
+		u := u0 + (u1-u0)*float64(i)/float64(n)
+		pts = append(pts, geom.NURBS(deg, knots, ctrl, weights, u))
+	}
+	return pts
+}
+
+// hatchR12 writes the loops of a hatch as closed polylines.
+func (w *writer) hatchR12(e *drawing.Hatch) {
+	w.lose("HATCH entities were written as their boundaries")
+	for _, l := range e.Loops {
+		vs := loopVertices(l)
+		if len(vs) < 2 {
+			continue
+		}
+		for i := range vs {
+			vs[i].Position.Z = e.Elevation
+		}
+		w.polyline(&drawing.Polyline{
+			EntityProps: e.EntityProps,
+			Flags:       drawing.PolylineClosed,
+			Elevation:   e.Elevation,
+			Extrusion:   e.Extrusion,
+			Vertices:    vs,
+		})
+	}
+}
+
+// loopVertices returns the vertices of a closed polyline along a loop:
+// arcs become bulges, other curves points on them. The edges are chained
+// end to start, turned round where they run against the chain, like the
+// renderer does.
+func loopVertices(l drawing.HatchLoop) []drawing.Vertex {
+	if l.Flags&drawing.HatchLoopPolyline != 0 {
+		return slices.Clone(l.Vertices)
+	}
+	var ring []drawing.Vertex
+	for i, e := range l.Edges {
+		vs := edgeVertices(e)
+		if len(vs) == 0 {
+			continue
+		}
+		if len(ring) > 0 {
+			last := ring[len(ring)-1].Position
+			if dist(last, vs[0]) > dist(last, vs[len(vs)-1]) {
+				vs = reverseChain(vs)
+			}
+			// The first edge may run against the chain too.
+			if i == 1 && dist(ring[0].Position, vs[0]) < dist(last, vs[0]) {
+				ring = reverseChain(ring)
+				if last = ring[len(ring)-1].Position; dist(last, vs[0]) > dist(last, vs[len(vs)-1]) {
+					vs = reverseChain(vs)
+				}
+			}
+			if dist(last, vs[0]) < 1e-9 {
+				ring[len(ring)-1].Bulge = vs[0].Bulge
+				vs = vs[1:]
+			}
+		}
+		ring = append(ring, vs...)
+	}
+	if n := len(ring); n > 1 && dist(ring[0].Position, ring[n-1]) < 1e-9 {
+		ring = ring[:n-1]
+	}
+	return ring
+}
+
+func dist(p geom.Vec3, v drawing.Vertex) float64 {
+	return p.XY().Dist(v.Position.XY())
+}
+
+// reverseChain turns a chain of vertices round, the bulge of each segment
+// moving to its other end and changing sign.
+func reverseChain(vs []drawing.Vertex) []drawing.Vertex {
+	out := make([]drawing.Vertex, len(vs))
+	for i := range vs {
+		out[i] = vs[len(vs)-1-i]
+		out[i].Bulge = 0
+		if j := len(vs) - 2 - i; j >= 0 {
+			out[i].Bulge = -vs[j].Bulge
+		}
+	}
+	return out
+}
+
+// edgeVertices returns one loop edge as vertices in its own direction.
+func edgeVertices(e drawing.HatchEdge) []drawing.Vertex {
+	var pts []geom.Vec3
+	switch e.Type {
+	case drawing.EdgeLine:
+		pts = []geom.Vec3{e.Start.Vec3(0), e.End.Vec3(0)}
+	case drawing.EdgeArc:
+		t0, t1 := edgeAngles(e)
+		// Arcs over a half turn are split, as a bulge cannot hold them.
+		n := int(math.Ceil(math.Abs(t1-t0) / math.Pi))
+		var vs []drawing.Vertex
+		for i := range n + 1 {
+			t := t0 + (t1-t0)*float64(i)/float64(n)
+			v := drawing.Vertex{Position: e.Center.Add(geom.Polar(t, e.Radius)).Vec3(0)}
+			if i < n {
+				v.Bulge = math.Tan((t1 - t0) / float64(n) / 4)
+			}
+			vs = append(vs, v)
+		}
+		return vs
+	case drawing.EdgeEllipse:
+		t0, t1 := edgeAngles(e)
+		pts = ellipsePoints(e.Center.Vec3(0), e.MajorAxis.Vec3(0), e.MajorAxis.Perp().Scale(e.Ratio).Vec3(0), t0, t1)
+	case drawing.EdgeSpline:
+		if e.Spline != nil {
+			pts = splinePoints(e.Spline)
+		}
+	}
+	var vs []drawing.Vertex
+	for _, p := range pts {
+		vs = append(vs, drawing.Vertex{Position: p})
+	}
+	return vs
+}
+
+// edgeAngles returns where an arc edge starts and ends, the end below
+// the start when it runs clockwise. Clockwise edges store their angles
+// mirrored.
+func edgeAngles(e drawing.HatchEdge) (float64, float64) {
+	t0, t1 := e.StartAngle, e.EndAngle
+	if e.CCW {
+		for t1 <= t0 {
+			t1 += 2 * math.Pi
+		}
+		return t0, t1
+	}
+	t0, t1 = -t0, -t1
+	for t1 >= t0 {
+		t1 -= 2 * math.Pi
+	}
+	return t0, t1
+}
+
+// mtextR12 writes each line of a paragraph text as TEXT, its formatting
+// left out, placed as the attachment point of the MTEXT would place it.
+func (w *writer) mtextR12(e *drawing.MText) {
+	w.lose("MTEXT entities were written as TEXT")
+	lines := mtextLines(e.Value)
+	factor := e.LineSpacingFactor
+	if factor <= 0 {
+		factor = 1
+	}
+	spacing := e.Height * 5 / 3 * factor
+	total := e.Height + float64(len(lines)-1)*spacing
+
+	att := min(max(e.Attachment, drawing.MTextTopLeft), drawing.MTextBottomRight) - 1
+	halign := []int{drawing.HAlignLeft, drawing.HAlignCenter, drawing.HAlignRight}[att%3]
+	top := []float64{0, total / 2, total}[att/3]
+
+	pos, dir := e.Position, e.XDirection
+	if dir.IsZero() {
+		dir = geom.Vec3{X: 1}
+	}
+	// TEXT is placed in its OCS and MTEXT in WCS.
+	if !e.Extrusion.IsZero() && !e.Extrusion.Sub(geom.ZAxis).IsZero() {
+		if m, ok := geom.OCS(e.Extrusion).Inverse(); ok {
+			pos, dir = m.Apply(pos), m.ApplyVec(dir)
+		}
+	}
+	rotation := dir.XY().Angle()
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		at := pos.Add(geom.Polar(rotation+math.Pi/2, top-float64(i)*spacing).Vec3(0))
+		w.text("TEXT", &drawing.Text{
+			EntityProps: e.EntityProps,
+			Value:       line,
+			Position:    at,
+			AlignPoint:  at,
+			Height:      e.Height,
+			Rotation:    rotation,
+			WidthFactor: 1,
+			Style:       e.Style,
+			HAlign:      halign,
+			VAlign:      drawing.VAlignTop,
+			Extrusion:   e.Extrusion,
+		}, nil)
+	}
+}
+
+// mtextLines returns the lines of paragraph text with the formatting
+// codes taken out. Stacked fractions are written a/b.
+func mtextLines(s string) []string {
+	var lines []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '{', '}':
+			continue
+		case '\r', '\n':
+			lines = append(lines, b.String())
+			b.Reset()
+			if c == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			continue
+		case '\\':
+		default:
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 >= len(s) {
+			break
+		}
+		i++
+		switch s[i] {
+		case 'P':
+			lines = append(lines, b.String())
+			b.Reset()
+		case '~':
+			b.WriteByte(' ')
+		case '\\', '{', '}':
+			b.WriteByte(s[i])
+		case 'S':
+			end := strings.IndexByte(s[i:], ';')
+			if end < 0 {
+				end = len(s) - i
+			}
+			b.WriteString(strings.NewReplacer("^", "/", "#", "/").Replace(s[i+1 : i+end]))
+			i += end
+		case 'A', 'C', 'c', 'F', 'f', 'H', 'h', 'Q', 'q', 'T', 't', 'W', 'w', 'p':
+			end := strings.IndexByte(s[i:], ';')
+			if end < 0 {
+				end = len(s) - i
+			}
+			i += end
+		case 'U':
+			if i+5 < len(s) && s[i+1] == '+' {
+				if r, err := strconv.ParseUint(s[i+2:i+6], 16, 32); err == nil && utf8.ValidRune(rune(r)) {
+					b.WriteRune(rune(r))
+					i += 5
+				}
+			}
+		case 'L', 'l', 'O', 'o', 'K', 'k', 'N':
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return append(lines, b.String())
+}
+
+// dimensionBlock writes a dimension the version has no entity for as a
+// reference to the block that holds its graphics.
+func (w *writer) dimensionBlock(e *drawing.Dimension) {
+	if e.Block == "" || w.d.Block(e.Block) == nil {
+		w.lose("arc length dimensions without a block were left out")
+		return
+	}
+	w.lose("arc length dimensions were written as block references")
+	w.insert(&drawing.Insert{
+		EntityProps: e.EntityProps,
+		Block:       e.Block,
+		Scale:       geom.Vec3{X: 1, Y: 1, Z: 1},
+	})
+}
+
+// mview writes the view of a viewport in files of R12, which keep it in
+// extended data.
+func (w *writer) mview(e *drawing.Viewport) {
+	w.tag(1001, "ACAD")
+	w.tag(1000, "MVIEW")
+	w.tag(1002, "{")
+	w.int(1070, 16)
+	w.point(1010, e.ViewTarget)
+	w.point(1010, e.ViewDirection)
+	for _, v := range []float64{e.TwistAngle, e.ViewHeight, e.ViewCenter.X, e.ViewCenter.Y, 50, 0, 0} {
+		w.float(1040, v)
+	}
+	// View mode, circle zoom, fast zoom, UCS icon, snap, grid, snap style
+	// and isometric plane.
+	for _, v := range []int{0, 1000, 1, 0, 0, 0, 0, 0} {
+		w.int(1070, v)
+	}
+	// Snap angle and base, snap and grid spacing.
+	for _, v := range []float64{0, 0, 0, 1, 1, 1, 1} {
+		w.float(1040, v)
+	}
+	w.int(1070, 0)
+	w.tag(1002, "{")
+	for _, name := range e.FrozenLayers {
+		w.str(1003, name)
+	}
+	w.tag(1002, "}")
+	w.tag(1002, "}")
+}
@@ -0,0 +1,376 @@
+package dxf
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+func props(rec []tag) drawing.EntityProps {
+	p := drawing.DefaultProps()
+	p.Handle = handle(rec)
+	if v := str(rec, 8); v != "" {
+		p.Layer = v
+	}
+	if v := str(rec, 6); v != "" {
+		p.Linetype = v
+	}
+	if has(rec, 48) {
+		p.LinetypeScale = float(rec, 48)
+	}
+	p.Color = color(rec)
+	if has(rec, 370) {
+		p.Lineweight = drawing.Lineweight(integer(rec, 370))
+	}
+	// Named plot styles are kept as the handle of their placeholder until
+	// the objects section has been read.
+	if integer(rec, 380) == 1 {
+		p.PlotStyle = "ByBlock"
+	} else if v := str(rec, 390); v != "" {
+		p.PlotStyle = v
+	}
+	p.Invisible = integer(rec, 60) != 0
+	p.PaperSpace = integer(rec, 67) != 0
+	p.Hyperlink = hyperlink(xdata(rec, "PE_URL"))
+	return p
+}
+
+// hyperlink reads the PE_URL extended data: the URL, then between braces
+// the description and the sub-location.
+func hyperlink(x []tag) *drawing.Hyperlink {
+	var values []string
+	depth := 0
+	for _, t := range x {
+		switch {
+		case t.code == 1002 && strings.TrimSpace(t.value) == "{":
+			depth++
+		case t.code == 1002:
+			depth--
+		case t.code == 1000 && depth <= 1:
+			values = append(values, t.value)
+		}
+	}
+	values = append(values, "", "", "")
+	h := &drawing.Hyperlink{URL: values[0], Description: values[1], SubLocation: values[2]}
+	if h.URL == "" && h.SubLocation == "" {
+		return nil
+	}
+	return h
+}
+
+// entity decodes the entities that are complete in a single record.
+func entity(typ string, rec []tag) drawing.Entity {
+	switch typ {
+	case "LINE":
+		return &drawing.Line{
+			EntityProps: props(rec),
+			Start:       point(rec, 10),
+			End:         point(rec, 11),
+			Thickness:   float(rec, 39),
+			Extrusion:   extrusion(rec),
+		}
+	case "ARC":
+		return &drawing.Arc{
+			EntityProps: props(rec),
+			Center:      point(rec, 10),
+			Radius:      float(rec, 40),
+			StartAngle:  geom.Rad(float(rec, 50)),
+			EndAngle:    geom.Rad(float(rec, 51)),
+			Thickness:   float(rec, 39),
+			Extrusion:   extrusion(rec),
+		}
+	case "CIRCLE":
+		return &drawing.Circle{
+			EntityProps: props(rec),
+			Center:      point(rec, 10),
+			Radius:      float(rec, 40),
+			Thickness:   float(rec, 39),
+			Extrusion:   extrusion(rec),
+		}
+	case "ELLIPSE":
+		return &drawing.Ellipse{
+			EntityProps: props(rec),
+			Center:      point(rec, 10),
+			MajorAxis:   point(rec, 11),
+			Ratio:       floatOr(rec, 40, 1),
+			StartParam:  float(rec, 41),
+			EndParam:    floatOr(rec, 42, 2*math.Pi),
+			Extrusion:   extrusion(rec),
+		}
+	case "POINT":
+		return &drawing.Point{
+			EntityProps: props(rec),
+			Position:    point(rec, 10),
+			Thickness:   float(rec, 39),
+			XAngle:      geom.Rad(float(rec, 50)),
+			Extrusion:   extrusion(rec),
+		}
+	case "RAY":
+		return &drawing.Ray{EntityProps: props(rec), Base: point(rec, 10), Direction: point(rec, 11)}
+	case "XLINE":
+		return &drawing.XLine{EntityProps: props(rec), Base: point(rec, 10), Direction: point(rec, 11)}
+	case "SOLID", "TRACE":
+		s := &drawing.Solid{EntityProps: props(rec), Thickness: float(rec, 39), Extrusion: extrusion(rec), Trace: typ == "TRACE"}
+		for i := range s.Corners {
+			s.Corners[i] = point(rec, 10+i)
+		}
+		if !has(rec, 13) {
+			s.Corners[3] = s.Corners[2]
+		}
+		return s
+	case "3DFACE":
+		f := &drawing.Face3D{EntityProps: props(rec), InvisibleEdges: integer(rec, 70)}
+		for i := range f.Corners {
+			f.Corners[i] = point(rec, 10+i)
+		}
+		if !has(rec, 13) {
+			f.Corners[3] = f.Corners[2]
+		}
+		return f
+	case "LWPOLYLINE":
+		return lwpolyline(rec)
+	case "SPLINE":
+		return spline(rec)
+	case "TEXT":
+		t := text(rec, 73)
+		return &t
+	case "ATTDEF":
+		a := &drawing.AttDef{Text: text(rec, 74), Tag: str(rec, 2), Prompt: str(rec, 3), Flags: integer(rec, 70)}
+		return a
+	case "MTEXT":
+		return mtext(rec)
+	case "HATCH":
+		return hatch(rec)
+	case "IMAGE":
+		return image(rec)
+	}
+	return nil
+}
+
+func image(rec []tag) *drawing.Image {
+	h, _ := strconv.ParseUint(strings.TrimSpace(str(rec, 340)), 16, 64)
+	img := &drawing.Image{
+		EntityProps:  props(rec),
+		Insertion:    point(rec, 10),
+		U:            point(rec, 11),
+		V:            point(rec, 12),
+		Size:         point(rec, 13).XY(),
+		Def:          drawing.Handle(h),
+		Flags:        integer(rec, 70),
+		Clipping:     integer(rec, 280) != 0,
+		ClipInverted: integer(rec, 290) != 0,
+	}
+	for i, t := range rec {
+		if t.code == 1001 {
+			break
+		}
+		if t.code == 14 {
+			v := geom.Vec2{X: t.float()}
+			if i+1 < len(rec) && rec[i+1].code == 24 {
+				v.Y = rec[i+1].float()
+			}
+			img.Clip = append(img.Clip, v)
+		}
+	}
+	return img
+}
+
+func lwpolyline(rec []tag) *drawing.LWPolyline {
+	p := &drawing.LWPolyline{
+		EntityProps: props(rec),
+		ConstWidth:  float(rec, 43),
+		Elevation:   float(rec, 38),
+		Thickness:   float(rec, 39),
+		Extrusion:   extrusion(rec),
+	}
+	flags := integer(rec, 70)
+	p.Closed = flags&1 != 0
+	p.Plinegen = flags&128 != 0
+	var v *drawing.Vertex
+	for _, t := range rec {
+		switch t.code {
+		case 10:
+			p.Vertices = append(p.Vertices, drawing.Vertex{StartWidth: p.ConstWidth, EndWidth: p.ConstWidth})
+			v = &p.Vertices[len(p.Vertices)-1]
+			v.Position = geom.Vec3{X: t.float(), Z: p.Elevation}
+		case 20:
+			if v != nil {
+				v.Position.Y = t.float()
+			}
+		case 40:
+			if v != nil {
+				v.StartWidth = t.float()
+			}
+		case 41:
+			if v != nil {
+				v.EndWidth = t.float()
+			}
+		case 42:
+			if v != nil {
+				v.Bulge = t.float()
+			}
+		case 1001:
+			return p
+		}
+	}
+	return p
+}
+
+func polyline(rec []tag) *drawing.Polyline {
+	return &drawing.Polyline{
+		EntityProps: props(rec),
+		Flags:       integer(rec, 70),
+		StartWidth:  float(rec, 40),
+		EndWidth:    float(rec, 41),
+		Elevation:   float(rec, 30),
+		Thickness:   float(rec, 39),
+		Extrusion:   extrusion(rec),
+		MCount:      integer(rec, 71),
+		NCount:      integer(rec, 72),
+	}
+}
+
+func spline(rec []tag) *drawing.Spline {
+	s := &drawing.Spline{
+		EntityProps:  props(rec),
+		Flags:        integer(rec, 70),
+		Degree:       integer(rec, 71),
+		StartTangent: point(rec, 12),
+		EndTangent:   point(rec, 13),
+		Normal:       extrusion(rec),
+	}
+	var ctrl, fit *geom.Vec3
+	for _, t := range rec {
+		switch t.code {
+		case 40:
+			s.Knots = append(s.Knots, t.float())
+		case 41:
+			s.Weights = append(s.Weights, t.float())
+		case 10:
+			s.Control = append(s.Control, geom.Vec3{X: t.float()})
+			ctrl = &s.Control[len(s.Control)-1]
+		case 20:
+			if ctrl != nil {
+				ctrl.Y = t.float()
+			}
+		case 30:
+			if ctrl != nil {
+				ctrl.Z = t.float()
+			}
+		case 11:
+			s.Fit = append(s.Fit, geom.Vec3{X: t.float()})
+			fit = &s.Fit[len(s.Fit)-1]
+		case 21:
+			if fit != nil {
+				fit.Y = t.float()
+			}
+		case 31:
+			if fit != nil {
+				fit.Z = t.float()
+			}
+		case 1001:
+			return s
+		}
+	}
+	return s
+}
+
+// text reads TEXT and the text part of ATTRIB and ATTDEF, which store the
+// vertical alignment under valign instead of 73.
+func text(rec []tag, valign int) drawing.Text {
+	t := drawing.Text{
+		EntityProps: props(rec),
+		Value:       str(rec, 1),
+		Position:    point(rec, 10),
+		Height:      float(rec, 40),
+		Rotation:    geom.Rad(float(rec, 50)),
+		WidthFactor: floatOr(rec, 41, 1),
+		Oblique:     geom.Rad(float(rec, 51)),
+		Style:       strOr(rec, 7, "Standard"),
+		Generation:  integer(rec, 71),
+		HAlign:      integer(rec, 72),
+		VAlign:      integer(rec, valign),
+		Thickness:   float(rec, 39),
+		Extrusion:   extrusion(rec),
+	}
+	t.AlignPoint = t.Position
+	if has(rec, 11) {
+		t.AlignPoint = point(rec, 11)
+	}
+	return t
+}
+
+func attrib(rec []tag) *drawing.Attrib {
+	return &drawing.Attrib{Text: text(rec, 74), Tag: str(rec, 2), Flags: integer(rec, 70)}
+}
+
+func mtext(rec []tag) *drawing.MText {
+	m := &drawing.MText{
+		EntityProps:       props(rec),
+		Position:          point(rec, 10),
+		Extrusion:         extrusion(rec),
+		Height:            float(rec, 40),
+		RectWidth:         float(rec, 41),
+		RectHeight:        float(rec, 46),
+		Attachment:        integer(rec, 71),
+		FlowDirection:     integer(rec, 72),
+		Style:             strOr(rec, 7, "Standard"),
+		LineSpacingStyle:  integer(rec, 73),
+		LineSpacingFactor: floatOr(rec, 44, 1),
+	}
+	var b strings.Builder
+	columns := false
+	for _, t := range rec {
+		switch t.code {
+		case 3:
+			b.WriteString(t.value)
+		case 75:
+			columns = true
+			m.ColumnType = t.int()
+		case 76:
+			m.ColumnCount = t.int()
+		case 48:
+			m.ColumnWidth = t.float()
+		case 49:
+			m.ColumnGutter = t.float()
+		case 50:
+			if columns {
+				m.ColumnHeights = append(m.ColumnHeights, t.float())
+			}
+		}
+	}
+	b.WriteString(str(rec, 1))
+	m.Value = b.String()
+	switch {
+	case has(rec, 11):
+		m.XDirection = point(rec, 11)
+	default:
+		m.XDirection = geom.Polar(float(rec, 50), 1).Vec3(0)
+	}
+	return m
+}
+
+func insert(rec []tag) *drawing.Insert {
+	return &drawing.Insert{
+		EntityProps:   props(rec),
+		Block:         str(rec, 2),
+		Position:      point(rec, 10),
+		Scale:         geom.Vec3{X: floatOr(rec, 41, 1), Y: floatOr(rec, 42, 1), Z: floatOr(rec, 43, 1)},
+		Rotation:      geom.Rad(float(rec, 50)),
+		Extrusion:     extrusion(rec),
+		Columns:       max(integer(rec, 70), 1),
+		Rows:          max(integer(rec, 71), 1),
+		ColumnSpacing: float(rec, 44),
+		RowSpacing:    float(rec, 45),
+	}
+}
+
+func strOr(rec []tag, code int, def string) string {
+	if v := str(rec, code); v != "" {
+		return v
+	}
+	return def
+}
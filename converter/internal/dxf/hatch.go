@@ -0,0 +1,231 @@
+package dxf
+
+import (
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// cursor reads a record in order. HATCH reuses group codes between its
+// loops, edges and pattern lines, so their meaning depends on position.
+type cursor struct {
+	rec []tag
+	i   int
+}
+
+func (c *cursor) done() bool { return c.i >= len(c.rec) || c.rec[c.i].code == 1001 }
+
+// peek returns the code of the tag n places ahead, or -1 past the end.
+func (c *cursor) peek(n int) int {
+	if c.i+n >= len(c.rec) {
+		return -1
+	}
+	return c.rec[c.i+n].code
+}
+
+// take consumes the next tag if it has code; missing optional tags leave
+// the cursor where it is.
+func (c *cursor) take(code int) (tag, bool) {
+	if c.peek(0) != code {
+		return tag{}, false
+	}
+	c.i++
+	return c.rec[c.i-1], true
+}
+
+func (c *cursor) float(code int) float64 {
+	t, _ := c.take(code)
+	return t.float()
+}
+
+func (c *cursor) int(code int) int {
+	t, _ := c.take(code)
+	return t.int()
+}
+
+func (c *cursor) vec2(code int) geom.Vec2 {
+	return geom.Vec2{X: c.float(code), Y: c.float(code + 10)}
+}
+
+// count reads a count and limits it to the tags left in the record, so a
+// corrupt count cannot make a reader allocate without bound.
+func (c *cursor) count(code int) int {
+	return max(0, min(c.int(code), len(c.rec)-c.i))
+}
+
+func hatch(rec []tag) *drawing.Hatch {
+	h := &drawing.Hatch{
+		EntityProps: props(rec),
+		Pattern:     str(rec, 2),
+		Solid:       integer(rec, 70) != 0,
+		Associative: integer(rec, 71) != 0,
+		Scale:       1,
+		Elevation:   float(rec, 30),
+		Extrusion:   extrusion(rec),
+	}
+	c := &cursor{rec: rec}
+	var g *drawing.Gradient
+	for !c.done() {
+		t := c.rec[c.i]
+		c.i++
+		switch t.code {
+		case 102:
+			// Skip application groups such as the reactors.
+			if len(t.value) > 0 && t.value[0] == '{' {
+				for !c.done() && c.rec[c.i].code != 102 {
+					c.i++
+				}
+				c.i++
+			}
+		case 91:
+			for range max(0, min(t.int(), len(rec))) {
+				h.Loops = append(h.Loops, c.loop())
+			}
+		case 75:
+			h.Style = t.int()
+		case 76:
+			h.PatternType = t.int()
+		case 52:
+			h.Angle = geom.Rad(t.float())
+		case 41:
+			h.Scale = t.float()
+		case 77:
+			h.Double = t.int() != 0
+		case 78:
+			for range max(0, min(t.int(), len(rec))) {
+				h.Lines = append(h.Lines, c.patternLine())
+			}
+		case 450:
+			if t.int() != 0 {
+				g = &drawing.Gradient{}
+				h.Gradient = g
+			}
+		case 452:
+			if g != nil {
+				g.SingleColor = t.int() != 0
+			}
+		case 460:
+			if g != nil {
+				g.Angle = t.float()
+			}
+		case 461:
+			if g != nil {
+				g.Shift = t.float()
+			}
+		case 462:
+			if g != nil {
+				g.Tint = t.float()
+			}
+		case 463:
+			if g != nil {
+				g.Colors = append(g.Colors, drawing.Color{Index: drawing.ColorByLayer})
+			}
+		case 63:
+			if g != nil && len(g.Colors) > 0 {
+				g.Colors[len(g.Colors)-1].Index = int16(t.int())
+			}
+		case 421:
+			if g != nil && len(g.Colors) > 0 {
+				col := &g.Colors[len(g.Colors)-1]
+				col.True, col.RGB = true, uint32(t.int())&0xffffff
+			}
+		case 470:
+			if g != nil {
+				g.Name = t.value
+			}
+		}
+	}
+	return h
+}
+
+func (c *cursor) loop() drawing.HatchLoop {
+	l := drawing.HatchLoop{Flags: c.int(92)}
+	if l.Flags&drawing.HatchLoopPolyline != 0 {
+		bulges := c.int(72) != 0
+		c.take(73) // closed; loops always are
+		for range c.count(93) {
+			v := drawing.Vertex{Position: c.vec2(10).Vec3(0)}
+			if bulges {
+				v.Bulge = c.float(42)
+			}
+			l.Vertices = append(l.Vertices, v)
+		}
+	} else {
+		for range c.count(93) {
+			l.Edges = append(l.Edges, c.edge())
+		}
+	}
+	for range c.count(97) {
+		c.take(330)
+	}
+	return l
+}
+
+func (c *cursor) edge() drawing.HatchEdge {
+	e := drawing.HatchEdge{Type: c.int(72), CCW: true}
+	switch e.Type {
+	case drawing.EdgeLine:
+		e.Start = c.vec2(10)
+		e.End = c.vec2(11)
+	case drawing.EdgeArc:
+		e.Center = c.vec2(10)
+		e.Radius = c.float(40)
+		e.StartAngle = geom.Rad(c.float(50))
+		e.EndAngle = geom.Rad(c.float(51))
+		e.CCW = c.int(73) != 0
+	case drawing.EdgeEllipse:
+		e.Center = c.vec2(10)
+		e.MajorAxis = c.vec2(11)
+		e.Ratio = c.float(40)
+		e.StartAngle = geom.Rad(c.float(50))
+		e.EndAngle = geom.Rad(c.float(51))
+		e.CCW = c.int(73) != 0
+	case drawing.EdgeSpline:
+		s := &drawing.Spline{Degree: c.int(94), Normal: geom.ZAxis}
+		if c.int(73) != 0 {
+			s.Flags |= drawing.SplineRational
+		}
+		if c.int(74) != 0 {
+			s.Flags |= drawing.SplinePeriodic
+		}
+		knots, ctrl := c.count(95), c.count(96)
+		for range knots {
+			s.Knots = append(s.Knots, c.float(40))
+		}
+		for range ctrl {
+			s.Control = append(s.Control, c.vec2(10).Vec3(0))
+			if _, ok := c.take(42); ok {
+				s.Weights = append(s.Weights, c.rec[c.i-1].float())
+			}
+		}
+		// Fit data only exists from AutoCAD 2010 on; the 97 that follows
+		// the edges otherwise counts the source boundary objects. Without
+		// fit points the next edge may follow it.
+		if c.peek(0) == 97 {
+			if next := c.peek(1); next == 11 || next == 12 || next == 72 || next == 97 {
+				for range c.count(97) {
+					s.Fit = append(s.Fit, c.vec2(11).Vec3(0))
+				}
+				if c.peek(0) == 12 {
+					s.StartTangent = c.vec2(12).Vec3(0)
+				}
+				if c.peek(0) == 13 {
+					s.EndTangent = c.vec2(13).Vec3(0)
+				}
+			}
+		}
+		e.Spline = s
+	}
+	return e
+}
+
+func (c *cursor) patternLine() drawing.HatchLine {
+	l := drawing.HatchLine{
+		Angle:  geom.Rad(c.float(53)),
+		Base:   geom.Vec2{X: c.float(43), Y: c.float(44)},
+		Offset: geom.Vec2{X: c.float(45), Y: c.float(46)},
+	}
+	for range c.count(79) {
+		l.Dashes = append(l.Dashes, c.float(49))
+	}
+	return l
+}
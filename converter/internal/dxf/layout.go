@@ -0,0 +1,249 @@
+package dxf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+func (p *parser) objects() {
+	for !p.eof() && !p.peek().is(0, "ENDSEC") {
+		t := p.next()
+		if t.code != 0 {
+			continue
+		}
+		rec := p.record()
+		switch strings.ToUpper(strings.TrimSpace(t.value)) {
+		case "LAYOUT":
+			p.layout(rec)
+		case "DICTIONARY", "ACDBDICTIONARYWDFLT":
+			p.dictionary(rec)
+		case "IMAGEDEF":
+			p.d.AddImageDef(&drawing.ImageDef{Handle: handle(rec), File: str(rec, 1), Size: point(rec, 10).XY()})
+		case "XRECORD":
+			p.summary(rec)
+		}
+	}
+}
+
+// summary reads the drawing properties from the record AutoCAD keeps them
+// in, which starts with the DWGPROPS cookie. Custom properties are stored
+// as name=value and the hyperlink base follows the times in a second 1.
+func (p *parser) summary(rec []tag) {
+	start := -1
+	for i, t := range rec {
+		if t.code == 1 && strings.TrimSpace(t.value) == "DWGPROPS COOKIE" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return
+	}
+	s := &p.d.Summary
+	for _, t := range rec[start:] {
+		switch {
+		case t.code == 1:
+			s.HyperlinkBase = t.value
+		case t.code == 2:
+			s.Title = t.value
+		case t.code == 3:
+			s.Subject = t.value
+		case t.code == 4:
+			s.Author = t.value
+		case t.code == 6:
+			s.Comments = t.value
+		case t.code == 7:
+			s.Keywords = t.value
+		case t.code == 8:
+			s.LastSavedBy = t.value
+		case t.code == 9:
+			s.Revision = t.value
+		case t.code >= 300 && t.code <= 309:
+			if name, value, ok := strings.Cut(t.value, "="); ok && strings.TrimSpace(name) != "" {
+				s.Custom = append(s.Custom, drawing.Property{Name: strings.TrimSpace(name), Value: value})
+			}
+		}
+	}
+}
+
+// dictionary records the entries of a dictionary: names in group code 3,
+// each followed by the handle of its object.
+func (p *parser) dictionary(rec []tag) {
+	h := strings.ToUpper(strings.TrimSpace(str(rec, 5)))
+	entries := make(map[string]string)
+	for i := 0; i+1 < len(rec); i++ {
+		if rec[i].code == 3 && (rec[i+1].code == 350 || rec[i+1].code == 360) {
+			entries[rec[i].value] = strings.ToUpper(strings.TrimSpace(rec[i+1].value))
+		}
+	}
+	if p.rootDict == "" {
+		p.rootDict = h
+	}
+	p.dicts[h] = entries
+}
+
+// namePlotStyles replaces the placeholder handles of named plot styles
+// on layers and entities with the names the ACAD_PLOTSTYLENAME dictionary
+// gives them.
+func (p *parser) namePlotStyles() {
+	names := make(map[string]string)
+	for name, h := range p.dicts[p.dicts[p.rootDict]["ACAD_PLOTSTYLENAME"]] {
+		names[h] = name
+	}
+	lookup := func(v string) string {
+		if v == "" || v == "ByBlock" {
+			return v
+		}
+		return names[strings.ToUpper(strings.TrimSpace(v))]
+	}
+	for _, l := range p.d.Layers {
+		l.PlotStyle = lookup(l.PlotStyle)
+	}
+	for _, b := range p.d.Blocks {
+		for _, e := range b.Entities {
+			e.Props().PlotStyle = lookup(e.Props().PlotStyle)
+			if ins, ok := e.(*drawing.Insert); ok {
+				for _, a := range ins.Attribs {
+					a.PlotStyle = lookup(a.PlotStyle)
+				}
+			}
+		}
+	}
+}
+
+// layout reads the AcDbLayout part of a LAYOUT object; the plot settings
+// before it use some of the same group codes.
+func (p *parser) layout(rec []tag) {
+	sub := subclass(rec, "AcDbLayout")
+	if sub == nil {
+		return
+	}
+	l := &drawing.Layout{
+		Handle:   handle(rec),
+		Name:     str(sub, 1),
+		TabOrder: integer(sub, 71),
+		Block:    p.blocks[strings.TrimSpace(str(sub, 330))],
+		LimMin:   point(sub, 10).XY(),
+		LimMax:   point(sub, 11).XY(),
+		ExtMin:   point(sub, 14),
+		ExtMax:   point(sub, 15),
+		InsBase:  point(sub, 12),
+		Plot:     plotSettings(subclass(rec, "AcDbPlotSettings")),
+	}
+	if l.Name == "" || l.Block == "" {
+		return
+	}
+	p.d.AddLayout(l)
+}
+
+func plotSettings(rec []tag) drawing.PlotSettings {
+	return drawing.PlotSettings{
+		PaperName:      str(rec, 4),
+		PaperWidth:     float(rec, 44),
+		PaperHeight:    float(rec, 45),
+		MarginLeft:     float(rec, 40),
+		MarginBottom:   float(rec, 41),
+		MarginRight:    float(rec, 42),
+		MarginTop:      float(rec, 43),
+		Origin:         geom.Vec2{X: float(rec, 46), Y: float(rec, 47)},
+		Inches:         integer(rec, 72) == 0,
+		Rotation:       integer(rec, 73) & 3,
+		Area:           drawing.PlotArea(integer(rec, 74)),
+		WindowMin:      geom.Vec2{X: float(rec, 48), Y: float(rec, 49)},
+		WindowMax:      geom.Vec2{X: float(rec, 140), Y: float(rec, 141)},
+		ViewName:       str(rec, 6),
+		StyleSheet:     str(rec, 7),
+		Flags:          integer(rec, 70),
+		PaperUnits:     float(rec, 142),
+		DrawingUnits:   float(rec, 143),
+		StandardScale:  integer(rec, 75),
+		StandardFactor: float(rec, 147),
+	}
+}
+
+// subclass returns the tags of the named subclass marker up to the next
+// marker.
+func subclass(rec []tag, name string) []tag {
+	for i, t := range rec {
+		if t.code != 100 || !strings.EqualFold(strings.TrimSpace(t.value), name) {
+			continue
+		}
+		end := i + 1
+		for end < len(rec) && rec[end].code != 100 && rec[end].code != 1001 {
+			end++
+		}
+		return rec[i+1 : end]
+	}
+	return nil
+}
+
+func (p *parser) viewport(rec []tag) *drawing.Viewport {
+	v := &drawing.Viewport{
+		EntityProps:   props(rec),
+		Center:        point(rec, 10),
+		Width:         float(rec, 40),
+		Height:        float(rec, 41),
+		ID:            integer(rec, 69),
+		Status:        integer(rec, 90),
+		ViewCenter:    point(rec, 12).XY(),
+		ViewTarget:    point(rec, 17),
+		ViewDirection: geom.ZAxis,
+		ViewHeight:    float(rec, 45),
+		TwistAngle:    geom.Rad(float(rec, 51)),
+	}
+	if has(rec, 16) {
+		v.ViewDirection = point(rec, 16)
+	}
+	h, _ := strconv.ParseUint(strings.TrimSpace(str(rec, 340)), 16, 64)
+	v.ClipBoundary = drawing.Handle(h)
+	for _, t := range rec {
+		if t.code == 1001 {
+			break
+		}
+		if t.code == 331 {
+			if name := p.layers[strings.TrimSpace(t.value)]; name != "" {
+				v.FrozenLayers = append(v.FrozenLayers, name)
+			}
+		}
+	}
+	if !has(rec, 45) {
+		mview(v, xdata(rec, "ACAD"))
+	}
+	return v
+}
+
+// mview reads the view of a viewport from a file older than R13, which
+// keeps it in the MVIEW extended data.
+func mview(v *drawing.Viewport, x []tag) {
+	if len(x) == 0 || x[0].code != 1000 || !strings.EqualFold(strings.TrimSpace(x[0].value), "MVIEW") {
+		return
+	}
+	var pts []geom.Vec3
+	var reals []float64
+	for i, t := range x {
+		switch t.code {
+		case 1010:
+			pt := geom.Vec3{X: t.float()}
+			if i+1 < len(x) && x[i+1].code == 1020 {
+				pt.Y = x[i+1].float()
+			}
+			if i+2 < len(x) && x[i+2].code == 1030 {
+				pt.Z = x[i+2].float()
+			}
+			pts = append(pts, pt)
+		case 1040:
+			reals = append(reals, t.float())
+		case 1003:
+			v.FrozenLayers = append(v.FrozenLayers, strings.TrimSpace(t.value))
+		}
+	}
+	if len(pts) < 2 || len(reals) < 4 {
+		return
+	}
+	v.ViewTarget, v.ViewDirection = pts[0], pts[1]
+	v.TwistAngle, v.ViewHeight = reals[0], reals[1]
+	v.ViewCenter = geom.Vec2{X: reals[2], Y: reals[3]}
+}
@@ -0,0 +1,595 @@
+package dxf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+var ErrNotDXF = errors.New("not a DXF file")
+
+// Read parses an ASCII or binary DXF file.
+func Read(r io.Reader) (*drawing.Drawing, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read dxf: %w", err)
+	}
+	return Decode(data)
+}
+
+func Decode(data []byte) (*drawing.Drawing, error) {
+	var tags []tag
+	var err error
+	if isBinary(data) {
+		tags, err = readBinary(data)
+	} else {
+		tags, err = readASCII(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotDXF, err)
+	}
+	if len(tags) == 0 || !tags[0].is(0, "SECTION") {
+		return nil, ErrNotDXF
+	}
+	decodeStrings(tags)
+
+	p := &parser{
+		tags:       tags,
+		d:          drawing.New(),
+		layers:     make(map[string]string),
+		styles:     make(map[string]string),
+		styleFiles: make(map[string]string),
+		blocks:     make(map[string]string),
+		dicts:      make(map[string]map[string]string),
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.d, nil
+}
+
+type parser struct {
+	tags []tag
+	pos  int
+	d    *drawing.Drawing
+
+	// layers maps LAYER handles to names for viewports; styles maps
+	// STYLE handles to names for complex linetypes, and styleFiles to
+	// their font files; blocks maps BLOCK_RECORD handles to names.
+	layers     map[string]string
+	styles     map[string]string
+	styleFiles map[string]string
+	blocks     map[string]string
+	ltypes     []*pendingLtype
+	dimStyles  []*pendingDimStyle
+	// dicts holds the entries of each dictionary by handle, the first
+	// of which is the root dictionary.
+	dicts    map[string]map[string]string
+	rootDict string
+}
+
+type pendingLtype struct {
+	lt     *drawing.Linetype
+	styles []string
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.tags) }
+
+func (p *parser) peek() tag {
+	if p.eof() {
+		return tag{code: 0, value: "EOF"}
+	}
+	return p.tags[p.pos]
+}
+
+func (p *parser) next() tag {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// record collects the tags of one table entry or entity, up to the next
+// group code 0.
+func (p *parser) record() []tag {
+	start := p.pos
+	for !p.eof() && p.tags[p.pos].code != 0 {
+		p.pos++
+	}
+	return p.tags[start:p.pos]
+}
+
+func (p *parser) parse() error {
+	for !p.eof() {
+		t := p.next()
+		if t.is(0, "EOF") {
+			break
+		}
+		if !t.is(0, "SECTION") {
+			return fmt.Errorf("dxf: expected SECTION, got %d/%q", t.code, t.value)
+		}
+		name := p.next()
+		if name.code != 2 {
+			return fmt.Errorf("dxf: section without name")
+		}
+		switch strings.ToUpper(strings.TrimSpace(name.value)) {
+		case "HEADER":
+			p.header()
+		case "TABLES":
+			p.tables()
+		case "BLOCKS":
+			p.blocksSection()
+		case "OBJECTS":
+			p.objects()
+		case "ENTITIES":
+			ents := p.entities("ENDSEC")
+			for _, e := range ents {
+				if e.Props().PaperSpace {
+					p.d.PaperSpace().Entities = append(p.d.PaperSpace().Entities, e)
+				} else {
+					p.d.ModelSpace().Entities = append(p.d.ModelSpace().Entities, e)
+				}
+			}
+		default:
+			p.skipSection()
+		}
+		if t := p.next(); !t.is(0, "ENDSEC") {
+			return fmt.Errorf("dxf: section %s not terminated", name.value)
+		}
+	}
+	for _, pl := range p.ltypes {
+		for i, h := range pl.styles {
+			if h != "" && i < len(pl.lt.Elements) {
+				pl.lt.Elements[i].Style = p.styles[h]
+				if pl.lt.Elements[i].Shape != 0 {
+					pl.lt.Elements[i].ShapeFile = p.styleFiles[h]
+				}
+			}
+		}
+	}
+	for _, pd := range p.dimStyles {
+		for code, h := range pd.handles {
+			if name := p.resolveDimVar(code, h); name != "" {
+				pd.ds.Set(code, name)
+			}
+		}
+	}
+	p.namePlotStyles()
+	p.d.EnsureDefaults()
+	return nil
+}
+
+func (p *parser) skipSection() {
+	for !p.eof() && !p.peek().is(0, "ENDSEC") {
+		p.pos++
+	}
+}
+
+func (p *parser) header() {
+	h := &p.d.Header
+	for !p.eof() && !p.peek().is(0, "ENDSEC") {
+		t := p.next()
+		if t.code != 9 {
+			continue
+		}
+		// A variable's values run up to the next group code 9.
+		start := p.pos
+		for !p.eof() && p.peek().code != 9 && p.peek().code != 0 {
+			p.pos++
+		}
+		vals := p.tags[start:p.pos]
+		first := func() tag {
+			if len(vals) == 0 {
+				return tag{}
+			}
+			return vals[0]
+		}
+		switch strings.TrimSpace(t.value) {
+		case "$ACADVER":
+			p.d.Version = strings.TrimSpace(first().value)
+		case "$INSBASE":
+			h.InsBase = point(vals, 10)
+		case "$EXTMIN":
+			h.ExtMin = point(vals, 10)
+		case "$EXTMAX":
+			h.ExtMax = point(vals, 10)
+		case "$LIMMIN":
+			h.LimMin = point(vals, 10).XY()
+		case "$LIMMAX":
+			h.LimMax = point(vals, 10).XY()
+		case "$PINSBASE":
+			h.PaperInsBase = point(vals, 10)
+		case "$PEXTMIN":
+			h.PaperExtMin = point(vals, 10)
+		case "$PEXTMAX":
+			h.PaperExtMax = point(vals, 10)
+		case "$PLIMMIN":
+			h.PaperLimMin = point(vals, 10).XY()
+		case "$PLIMMAX":
+			h.PaperLimMax = point(vals, 10).XY()
+		case "$LTSCALE":
+			h.LTScale = first().float()
+		case "$CELTSCALE":
+			h.CELTScale = first().float()
+		case "$PSLTSCALE":
+			h.PSLTScale = first().int() != 0
+		case "$TEXTSIZE":
+			h.TextSize = first().float()
+		case "$TEXTSTYLE":
+			h.TextStyle = strings.TrimSpace(first().value)
+		case "$PDMODE":
+			h.PDMode = first().int()
+		case "$PDSIZE":
+			h.PDSize = first().float()
+		case "$FILLMODE":
+			h.FillMode = first().int() != 0
+		case "$LWDISPLAY":
+			h.LWDisplay = first().int() != 0
+		case "$INSUNITS":
+			h.InsUnits = first().int()
+		case "$DWGCODEPAGE":
+			h.Encoding = codepages[strings.ToUpper(strings.TrimSpace(first().value))]
+		case "$TDCREATE":
+			h.Created = julian(first().float())
+		case "$TDUPDATE":
+			h.Updated = julian(first().float())
+		}
+	}
+}
+
+func julian(days float64) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	sec := (days - 2440587.5) * 86400
+	return time.Unix(int64(math.Round(sec)), 0).UTC()
+}
+
+func (p *parser) tables() {
+	for !p.eof() && !p.peek().is(0, "ENDSEC") {
+		t := p.next()
+		if t.code != 0 {
+			continue
+		}
+		switch strings.ToUpper(strings.TrimSpace(t.value)) {
+		case "LAYER":
+			p.layer(p.record())
+		case "LTYPE":
+			p.ltype(p.record())
+		case "STYLE":
+			p.style(p.record())
+		case "DIMSTYLE":
+			p.dimStyle(p.record())
+		case "VIEW":
+			p.view(p.record())
+		case "BLOCK_RECORD":
+			rec := p.record()
+			p.blocks[str(rec, 5)] = str(rec, 2)
+		default:
+			p.record()
+		}
+	}
+}
+
+func (p *parser) layer(rec []tag) {
+	l := &drawing.Layer{Name: str(rec, 2), Linetype: "Continuous", Lineweight: drawing.LineweightDefault, Plot: true}
+	l.Handle = handle(rec)
+	p.layers[str(rec, 5)] = l.Name
+	l.Color = color(rec)
+	if l.Color.Index < 0 {
+		l.Off = true
+		l.Color.Index = -l.Color.Index
+	}
+	if l.Color.IsByLayer() || l.Color.IsByBlock() {
+		l.Color.Index = 7
+	}
+	flags := integer(rec, 70)
+	l.Frozen = flags&1 != 0
+	l.Locked = flags&4 != 0
+	if lt := str(rec, 6); lt != "" {
+		l.Linetype = lt
+	}
+	if has(rec, 290) {
+		l.Plot = integer(rec, 290) != 0
+	}
+	if has(rec, 370) {
+		l.Lineweight = drawing.Lineweight(integer(rec, 370))
+	}
+	l.PlotStyle = str(rec, 390)
+	p.d.AddLayer(l)
+}
+
+func (p *parser) view(rec []tag) {
+	v := &drawing.View{
+		Handle:     handle(rec),
+		Name:       str(rec, 2),
+		Center:     point(rec, 10).XY(),
+		Height:     float(rec, 40),
+		Width:      float(rec, 41),
+		Target:     point(rec, 12),
+		Direction:  geom.ZAxis,
+		Twist:      geom.Rad(float(rec, 50)),
+		PaperSpace: integer(rec, 70)&1 != 0,
+	}
+	if has(rec, 11) {
+		v.Direction = point(rec, 11)
+	}
+	if v.Name != "" {
+		p.d.AddView(v)
+	}
+}
+
+func (p *parser) ltype(rec []tag) {
+	lt := &drawing.Linetype{Name: str(rec, 2), Description: str(rec, 3), Length: float(rec, 40)}
+	lt.Handle = handle(rec)
+	pl := &pendingLtype{lt: lt}
+	var e *drawing.LinetypeElement
+	for _, t := range rec {
+		if t.code == 49 {
+			lt.Elements = append(lt.Elements, drawing.LinetypeElement{Length: t.float(), Scale: 1})
+			pl.styles = append(pl.styles, "")
+			e = &lt.Elements[len(lt.Elements)-1]
+			continue
+		}
+		if e == nil {
+			continue
+		}
+		switch t.code {
+		case 74:
+			e.Absolute = t.int()&1 != 0
+		case 75:
+			e.Shape = t.int()
+		case 340:
+			pl.styles[len(pl.styles)-1] = strings.TrimSpace(t.value)
+		case 46:
+			e.Scale = t.float()
+		case 50:
+			e.Rotation = geom.Rad(t.float())
+		case 44:
+			e.Offset.X = t.float()
+		case 45:
+			e.Offset.Y = t.float()
+		case 9:
+			e.Text = t.value
+		}
+	}
+	p.ltypes = append(p.ltypes, pl)
+	p.d.AddLinetype(lt)
+}
+
+func (p *parser) style(rec []tag) {
+	s := &drawing.TextStyle{Name: str(rec, 2), WidthFactor: 1}
+	s.Handle = handle(rec)
+	flags := integer(rec, 70)
+	s.ShapeFile = flags&1 != 0
+	s.Vertical = flags&4 != 0
+	s.Height = float(rec, 40)
+	if has(rec, 41) {
+		s.WidthFactor = float(rec, 41)
+	}
+	s.Oblique = geom.Rad(float(rec, 50))
+	gen := integer(rec, 71)
+	s.Backward = gen&drawing.TextBackward != 0
+	s.UpsideDown = gen&drawing.TextUpsideDown != 0
+	s.FontFile = str(rec, 3)
+	s.BigFontFile = str(rec, 4)
+	if x := xdata(rec, "ACAD"); len(x) > 0 {
+		s.FontFamily = str(x, 1000)
+	}
+	p.styles[str(rec, 5)] = s.Name
+	p.styleFiles[str(rec, 5)] = s.FontFile
+	if s.Name == "" {
+		return
+	}
+	p.d.AddStyle(s)
+}
+
+func (p *parser) blocksSection() {
+	for !p.eof() && !p.peek().is(0, "ENDSEC") {
+		t := p.next()
+		if !t.is(0, "BLOCK") {
+			p.record()
+			continue
+		}
+		rec := p.record()
+		b := &drawing.Block{Name: str(rec, 2), Base: point(rec, 10), Description: str(rec, 4), XrefPath: str(rec, 1)}
+		b.Handle = handle(rec)
+		if b.Name == "" {
+			b.Name = str(rec, 3)
+		}
+		if b.Name == "" {
+			b.Name = p.blocks[str(rec, 330)]
+		}
+		flags := integer(rec, 70)
+		b.Anonymous = flags&1 != 0
+		b.HasAttDefs = flags&2 != 0
+		b.Xref = flags&4 != 0
+		b.XrefOverlay = flags&8 != 0
+		b.Entities = p.entities("ENDBLK")
+		if p.peek().is(0, "ENDBLK") {
+			p.next()
+			p.record()
+		}
+		p.d.AddBlock(b)
+	}
+}
+
+// entities parses entities until a group 0 with the given terminator, which
+// is not consumed.
+func (p *parser) entities(end string) []drawing.Entity {
+	var out []drawing.Entity
+	for !p.eof() {
+		t := p.peek()
+		if t.is(0, end) || t.is(0, "ENDSEC") || t.is(0, "EOF") {
+			break
+		}
+		p.next()
+		if t.code != 0 {
+			continue
+		}
+		typ := strings.ToUpper(strings.TrimSpace(t.value))
+		rec := p.record()
+		var e drawing.Entity
+		switch typ {
+		case "POLYLINE":
+			e = p.polyline(rec)
+		case "INSERT":
+			e = p.insert(rec)
+		case "DIMENSION", "ARC_DIMENSION":
+			e = p.dimension(typ, rec)
+		case "VIEWPORT":
+			e = p.viewport(rec)
+		default:
+			e = entity(typ, rec)
+		}
+		if e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (p *parser) polyline(rec []tag) drawing.Entity {
+	pl := polyline(rec)
+	for p.peek().is(0, "VERTEX") {
+		p.next()
+		v := p.record()
+		flags := integer(v, 70)
+		if flags&drawing.VertexFace != 0 && flags&drawing.VertexPolyface == 0 {
+			pl.Faces = append(pl.Faces, [4]int{integer(v, 71), integer(v, 72), integer(v, 73), integer(v, 74)})
+			continue
+		}
+		pl.Vertices = append(pl.Vertices, drawing.Vertex{
+			Position:   point(v, 10),
+			StartWidth: floatOr(v, 40, pl.StartWidth),
+			EndWidth:   floatOr(v, 41, pl.EndWidth),
+			Bulge:      float(v, 42),
+			Flags:      flags,
+		})
+	}
+	if p.peek().is(0, "SEQEND") {
+		p.next()
+		p.record()
+	}
+	return pl
+}
+
+func (p *parser) insert(rec []tag) drawing.Entity {
+	ins := insert(rec)
+	if integer(rec, 66) == 0 {
+		return ins
+	}
+	for p.peek().is(0, "ATTRIB") {
+		p.next()
+		ins.Attribs = append(ins.Attribs, attrib(p.record()))
+	}
+	if p.peek().is(0, "SEQEND") {
+		p.next()
+		p.record()
+	}
+	return ins
+}
+
+func has(rec []tag, code int) bool {
+	_, ok := find(rec, code)
+	return ok
+}
+
+// find returns the first tag with code, skipping application groups and
+// stopping at extended data.
+func find(rec []tag, code int) (tag, bool) {
+	group := false
+	for _, t := range rec {
+		switch {
+		case t.code == 1001:
+			return tag{}, false
+		case t.code == 102:
+			group = strings.HasPrefix(strings.TrimSpace(t.value), "{")
+		case group:
+		case t.code == code:
+			return t, true
+		}
+	}
+	return tag{}, false
+}
+
+func str(rec []tag, code int) string {
+	t, _ := find(rec, code)
+	return t.value
+}
+
+func float(rec []tag, code int) float64 {
+	t, _ := find(rec, code)
+	return t.float()
+}
+
+func floatOr(rec []tag, code int, def float64) float64 {
+	if t, ok := find(rec, code); ok {
+		return t.float()
+	}
+	return def
+}
+
+func integer(rec []tag, code int) int {
+	t, _ := find(rec, code)
+	return t.int()
+}
+
+// point reads the coordinate triple starting at code, e.g. 10/20/30.
+func point(rec []tag, code int) geom.Vec3 {
+	return geom.Vec3{X: float(rec, code), Y: float(rec, code+10), Z: float(rec, code+20)}
+}
+
+func extrusion(rec []tag) geom.Vec3 {
+	if !has(rec, 210) {
+		return geom.ZAxis
+	}
+	return point(rec, 210)
+}
+
+func handle(rec []tag) drawing.Handle {
+	h, _ := strconv.ParseUint(strings.TrimSpace(str(rec, 5)), 16, 64)
+	return drawing.Handle(h)
+}
+
+func color(rec []tag) drawing.Color {
+	c := drawing.Color{Index: drawing.ColorByLayer}
+	if t, ok := find(rec, 62); ok {
+		c.Index = int16(t.int())
+	}
+	if t, ok := find(rec, 420); ok {
+		c.True = true
+		c.RGB = uint32(t.int()) & 0xffffff
+	}
+	if t, ok := find(rec, 430); ok {
+		name, book, found := strings.Cut(t.value, "$")
+		if found {
+			c.Book, c.Name = name, book
+		} else {
+			c.Name = name
+		}
+	}
+	return c
+}
+
+// xdata returns the extended data tags registered to app.
+func xdata(rec []tag, app string) []tag {
+	for i, t := range rec {
+		if t.code != 1001 || !strings.EqualFold(strings.TrimSpace(t.value), app) {
+			continue
+		}
+		end := i + 1
+		for end < len(rec) && rec[end].code != 1001 {
+			end++
+		}
+		return rec[i+1 : end]
+	}
+	return nil
+}
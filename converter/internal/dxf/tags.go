@@ -0,0 +1,165 @@
+package dxf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// tag is a single group code / value pair. Values are kept as text; the
+// binary reader formats numbers the same way an ASCII file stores them.
+type tag struct {
+	code  int
+	value string
+}
+
+func (t tag) float() float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(t.value), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func (t tag) int() int {
+	s := strings.TrimSpace(t.value)
+	if v, err := strconv.Atoi(s); err == nil {
+		return v
+	}
+	return int(t.float())
+}
+
+func (t tag) is(code int, value string) bool {
+	return t.code == code && strings.EqualFold(strings.TrimSpace(t.value), value)
+}
+
+// readASCII splits an ASCII DXF file into tags.
+func readASCII(data []byte) ([]tag, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var tags []tag
+	line := 0
+	for sc.Scan() {
+		line++
+		codeLine := strings.TrimSpace(sc.Text())
+		if codeLine == "" && len(tags) > 0 && tags[len(tags)-1].is(0, "EOF") {
+			break
+		}
+		code, err := strconv.Atoi(codeLine)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad group code %q", line, codeLine)
+		}
+		if !sc.Scan() {
+			return nil, fmt.Errorf("line %d: missing value for group code %d", line, code)
+		}
+		line++
+		tags = append(tags, tag{code: code, value: strings.TrimRight(sc.Text(), "\r")})
+		if tags[len(tags)-1].is(0, "EOF") {
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return tags, nil
+}
+
+var codepages = map[string]encoding.Encoding{
+	"ANSI_874":  charmap.Windows874,
+	"ANSI_932":  japanese.ShiftJIS,
+	"ANSI_936":  simplifiedchinese.GBK,
+	"ANSI_949":  korean.EUCKR,
+	"ANSI_950":  traditionalchinese.Big5,
+	"ANSI_1250": charmap.Windows1250,
+	"ANSI_1251": charmap.Windows1251,
+	"ANSI_1252": charmap.Windows1252,
+	"ANSI_1253": charmap.Windows1253,
+	"ANSI_1254": charmap.Windows1254,
+	"ANSI_1255": charmap.Windows1255,
+	"ANSI_1256": charmap.Windows1256,
+	"ANSI_1257": charmap.Windows1257,
+	"ANSI_1258": charmap.Windows1258,
+	"DOS437":    charmap.CodePage437,
+	"DOS850":    charmap.CodePage850,
+	"DOS852":    charmap.CodePage852,
+	"DOS855":    charmap.CodePage855,
+	"DOS866":    charmap.CodePage866,
+}
+
+// decodeStrings converts the raw values of files older than R2007 from
+// their $DWGCODEPAGE to UTF-8 and expands \U+XXXX escapes in all files.
+func decodeStrings(tags []tag) {
+	var version, cp string
+	for i := 0; i+1 < len(tags) && !tags[i].is(0, "ENDSEC"); i++ {
+		if tags[i].code != 9 {
+			continue
+		}
+		switch strings.TrimSpace(tags[i].value) {
+		case "$ACADVER":
+			version = strings.TrimSpace(tags[i+1].value)
+		case "$DWGCODEPAGE":
+			cp = strings.ToUpper(strings.TrimSpace(tags[i+1].value))
+		}
+	}
+	var dec *encoding.Decoder
+	if version < "AC1021" {
+		if enc, ok := codepages[cp]; ok {
+			dec = enc.NewDecoder()
+		} else {
+			dec = charmap.Windows1252.NewDecoder()
+		}
+	}
+	for i := range tags {
+		v := tags[i].value
+		if dec != nil && !isASCII(v) && !utf8.ValidString(v) {
+			if out, err := dec.String(v); err == nil {
+				v = out
+			}
+		}
+		if strings.Contains(v, `\U+`) {
+			v = unescape(v)
+		}
+		tags[i].value = v
+	}
+}
+
+// unescape expands the \U+XXXX sequences AutoCAD writes for characters the
+// file codepage cannot represent. Escaped backslashes are left alone.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == '\\' {
+			b.WriteString(`\\`)
+			i++
+			continue
+		}
+		if s[i] == '\\' && i+7 <= len(s) && s[i+1] == 'U' && s[i+2] == '+' {
+			if r, err := strconv.ParseUint(s[i+3:i+7], 16, 32); err == nil {
+				b.WriteRune(rune(r))
+				i += 6
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
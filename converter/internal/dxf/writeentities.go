@@ -0,0 +1,982 @@
+package dxf
+
+import (
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+func (w *writer) entityList(list []drawing.Entity) {
+	for _, e := range list {
+		w.entity(e)
+	}
+}
+
+func (w *writer) entity(e drawing.Entity) {
+	switch e := e.(type) {
+	case *drawing.Line:
+		w.line(e)
+	case *drawing.Arc:
+		w.arc(e)
+	case *drawing.Circle:
+		w.circle(e)
+	case *drawing.Ellipse:
+		w.ellipse(e)
+	case *drawing.Point:
+		w.point3(e)
+	case *drawing.LWPolyline:
+		w.lwpolyline(e)
+	case *drawing.Polyline:
+		w.polyline(e)
+	case *drawing.Ray:
+		w.ray("RAY", "AcDbRay", &e.EntityProps, e.Base, e.Direction)
+	case *drawing.XLine:
+		w.ray("XLINE", "AcDbXline", &e.EntityProps, e.Base, e.Direction)
+	case *drawing.Spline:
+		w.spline(e)
+	case *drawing.Solid:
+		w.solid(e)
+	case *drawing.Face3D:
+		w.face(e)
+	case *drawing.Text:
+		w.text("TEXT", e, nil)
+	case *drawing.AttDef:
+		w.attDef(e)
+	case *drawing.Attrib:
+		w.attrib(e)
+	case *drawing.MText:
+		w.mtext(e)
+	case *drawing.Insert:
+		w.insert(e)
+	case *drawing.Dimension:
+		w.dimension(e)
+	case *drawing.Viewport:
+		w.viewport(e)
+	case *drawing.Hatch:
+		w.hatch(e)
+	case *drawing.Image:
+		w.image(e)
+	}
+}
+
+// start begins an entity owned by the current block with the properties
+// all entities have, and returns its handle.
+func (w *writer) start(typ string, p *drawing.EntityProps) string {
+	return w.startIn(typ, w.owner, p)
+}
+
+func (w *writer) startIn(typ, owner string, p *drawing.EntityProps) string {
+	h := w.clips[p.Handle]
+	if h == "" {
+		h = w.next()
+	}
+	w.tag(0, typ)
+	w.handle(5, h)
+	w.handle(330, owner)
+	w.subclass("AcDbEntity")
+	if w.paper {
+		w.int(67, 1)
+	}
+	w.str(8, p.Layer)
+	if lt := w.linetypeName(p.Linetype, ""); lt != "" && key(lt) != "BYLAYER" {
+		w.str(6, lt)
+	}
+	if c := p.Color; !c.IsByLayer() {
+		w.int(62, w.colorIndex(c))
+		if w.v == R2018 && c.True {
+			w.int(420, int(c.RGB))
+			if c.Name != "" {
+				w.str(430, c.Book+"$"+c.Name)
+			}
+		}
+	}
+	if w.v == R12 {
+		return h
+	}
+	if p.Lineweight != drawing.LineweightByLayer {
+		w.int(370, int(p.Lineweight))
+	}
+	if p.LinetypeScale > 0 && p.LinetypeScale != 1 {
+		w.float(48, p.LinetypeScale)
+	}
+	if p.Invisible {
+		w.int(60, 1)
+	}
+	switch {
+	case p.PlotStyle == "ByBlock":
+		w.int(380, 1)
+	case w.plotStyles[p.PlotStyle] != "" && p.PlotStyle != "":
+		w.int(380, 3)
+		w.tag(390, w.plotStyles[p.PlotStyle])
+	}
+	return h
+}
+
+// child returns the properties of the vertices and other records that
+// belong to an entity, which share only its layer.
+func child(p *drawing.EntityProps) *drawing.EntityProps {
+	c := drawing.DefaultProps()
+	c.Layer = p.Layer
+	return &c
+}
+
+func (w *writer) seqend(owner string, p *drawing.EntityProps) {
+	w.startIn("SEQEND", owner, child(p))
+}
+
+func (w *writer) thickness(t float64) {
+	if t != 0 {
+		w.float(39, t)
+	}
+}
+
+// extrusion writes the normal of an entity unless it is the Z axis, which
+// files assume.
+func (w *writer) extrusion(n geom.Vec3) {
+	if !n.IsZero() && !n.Sub(geom.ZAxis).IsZero() {
+		w.point(210, n)
+	}
+}
+
+// hyperlink writes the link of an entity as the PE_URL extended data
+// AutoCAD keeps it in. It comes last of all the data of the entity.
+func (w *writer) hyperlink(p *drawing.EntityProps) {
+	l := p.Hyperlink
+	if l == nil {
+		return
+	}
+	w.tag(1001, "PE_URL")
+	w.str(1000, l.URL)
+	w.tag(1002, "{")
+	w.str(1000, l.Description)
+	w.tag(1002, "{")
+	w.str(1000, l.SubLocation)
+	w.tag(1002, "}")
+	w.tag(1002, "{")
+	w.int(1071, 1)
+	w.tag(1002, "}")
+	w.tag(1002, "}")
+}
+
+func (w *writer) line(e *drawing.Line) {
+	w.start("LINE", &e.EntityProps)
+	w.subclass("AcDbLine")
+	w.thickness(e.Thickness)
+	w.point(10, e.Start)
+	w.point(11, e.End)
+	w.extrusion(e.Extrusion)
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) arc(e *drawing.Arc) {
+	w.start("ARC", &e.EntityProps)
+	w.subclass("AcDbCircle")
+	w.thickness(e.Thickness)
+	w.point(10, e.Center)
+	w.float(40, e.Radius)
+	w.extrusion(e.Extrusion)
+	w.subclass("AcDbArc")
+	w.angle(50, e.StartAngle)
+	w.angle(51, e.EndAngle)
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) circle(e *drawing.Circle) {
+	w.start("CIRCLE", &e.EntityProps)
+	w.subclass("AcDbCircle")
+	w.thickness(e.Thickness)
+	w.point(10, e.Center)
+	w.float(40, e.Radius)
+	w.extrusion(e.Extrusion)
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) ellipse(e *drawing.Ellipse) {
+	if w.v == R12 {
+		w.ellipseR12(e)
+		return
+	}
+	w.start("ELLIPSE", &e.EntityProps)
+	w.subclass("AcDbEllipse")
+	w.point(10, e.Center)
+	w.point(11, e.MajorAxis)
+	w.extrusion(e.Extrusion)
+	w.float(40, e.Ratio)
+	w.float(41, e.StartParam)
+	w.float(42, e.EndParam)
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) point3(e *drawing.Point) {
+	w.start("POINT", &e.EntityProps)
+	w.subclass("AcDbPoint")
+	w.point(10, e.Position)
+	w.thickness(e.Thickness)
+	w.extrusion(e.Extrusion)
+	if e.XAngle != 0 {
+		w.angle(50, e.XAngle)
+	}
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) lwpolyline(e *drawing.LWPolyline) {
+	if w.v == R12 {
+		w.lose("LWPOLYLINE entities were written as POLYLINE")
+		w.polyline(heavy(e))
+		return
+	}
+	w.start("LWPOLYLINE", &e.EntityProps)
+	w.subclass("AcDbPolyline")
+	w.int(90, len(e.Vertices))
+	flags := 0
+	if e.Closed {
+		flags |= drawing.PolylineClosed
+	}
+	if e.Plinegen {
+		flags |= drawing.PolylinePlinegen
+	}
+	w.int(70, flags)
+	constant := true
+	for _, v := range e.Vertices {
+		constant = constant && v.StartWidth == e.ConstWidth && v.EndWidth == e.ConstWidth
+	}
+	if constant && e.ConstWidth != 0 {
+		w.float(43, e.ConstWidth)
+	}
+	if e.Elevation != 0 {
+		w.float(38, e.Elevation)
+	}
+	w.thickness(e.Thickness)
+	for _, v := range e.Vertices {
+		w.point2(10, v.Position.XY())
+		if !constant && (v.StartWidth != 0 || v.EndWidth != 0) {
+			w.float(40, v.StartWidth)
+			w.float(41, v.EndWidth)
+		}
+		if v.Bulge != 0 {
+			w.float(42, v.Bulge)
+		}
+	}
+	w.extrusion(e.Extrusion)
+	w.hyperlink(&e.EntityProps)
+}
+
+// heavy returns the POLYLINE a lightweight polyline stands for.
+func heavy(e *drawing.LWPolyline) *drawing.Polyline {
+	p := &drawing.Polyline{
+		EntityProps: e.EntityProps,
+		Elevation:   e.Elevation,
+		Thickness:   e.Thickness,
+		Extrusion:   e.Extrusion,
+		StartWidth:  e.ConstWidth,
+		EndWidth:    e.ConstWidth,
+	}
+	if e.Closed {
+		p.Flags |= drawing.PolylineClosed
+	}
+	if e.Plinegen {
+		p.Flags |= drawing.PolylinePlinegen
+	}
+	for _, v := range e.Vertices {
+		v.Position.Z = e.Elevation
+		p.Vertices = append(p.Vertices, v)
+	}
+	return p
+}
+
+func (w *writer) polyline(e *drawing.Polyline) {
+	sub, vertexSub, vertexFlags := "AcDb2dPolyline", "AcDb2dVertex", 0
+	switch {
+	case e.Flags&drawing.PolylinePolyface != 0:
+		sub, vertexSub, vertexFlags = "AcDbPolyFaceMesh", "AcDbPolyFaceMeshVertex", drawing.VertexPolyface
+	case e.Flags&drawing.PolylineMesh != 0:
+		sub, vertexSub, vertexFlags = "AcDbPolygonMesh", "AcDbPolygonMeshVertex", drawing.VertexMesh
+	case e.Flags&drawing.Polyline3D != 0:
+		sub, vertexSub, vertexFlags = "AcDb3dPolyline", "AcDb3dPolylineVertex", drawing.Vertex3D
+	}
+	h := w.start("POLYLINE", &e.EntityProps)
+	w.subclass(sub)
+	w.int(66, 1)
+	w.point(10, geom.Vec3{Z: e.Elevation})
+	w.thickness(e.Thickness)
+	w.int(70, e.Flags)
+	if e.StartWidth != 0 || e.EndWidth != 0 {
+		w.float(40, e.StartWidth)
+		w.float(41, e.EndWidth)
+	}
+	switch {
+	case e.Flags&drawing.PolylinePolyface != 0:
+		w.int(71, len(e.Vertices))
+		w.int(72, len(e.Faces))
+	case e.Flags&drawing.PolylineMesh != 0:
+		w.int(71, e.MCount)
+		w.int(72, e.NCount)
+	}
+	w.extrusion(e.Extrusion)
+	w.hyperlink(&e.EntityProps)
+
+	for _, v := range e.Vertices {
+		w.startIn("VERTEX", h, child(&e.EntityProps))
+		w.subclass("AcDbVertex")
+		w.subclass(vertexSub)
+		pos := v.Position
+		if !e.Is3D() {
+			pos.Z = e.Elevation
+		}
+		w.point(10, pos)
+		if v.StartWidth != e.StartWidth || v.EndWidth != e.EndWidth {
+			w.float(40, v.StartWidth)
+			w.float(41, v.EndWidth)
+		}
+		if v.Bulge != 0 {
+			w.float(42, v.Bulge)
+		}
+		w.int(70, v.Flags|vertexFlags)
+	}
+	for _, f := range e.Faces {
+		w.startIn("VERTEX", h, child(&e.EntityProps))
+		w.subclass("AcDbFaceRecord")
+		w.point(10, geom.Vec3{})
+		w.int(70, drawing.VertexFace)
+		for i, v := range f {
+			if v != 0 || i < 3 {
+				w.int(71+i, v)
+			}
+		}
+	}
+	w.seqend(h, &e.EntityProps)
+}
+
+func (w *writer) ray(typ, sub string, p *drawing.EntityProps, base, dir geom.Vec3) {
+	if w.v == R12 {
+		w.lose(typ + " entities were left out")
+		return
+	}
+	w.start(typ, p)
+	w.subclass(sub)
+	w.point(10, base)
+	w.point(11, dir)
+	w.hyperlink(p)
+}
+
+func (w *writer) spline(e *drawing.Spline) {
+	if w.v == R12 {
+		w.splineR12(e)
+		return
+	}
+	w.start("SPLINE", &e.EntityProps)
+	w.subclass("AcDbSpline")
+	if !e.Normal.IsZero() {
+		w.point(210, e.Normal)
+	}
+	weights := len(e.Weights) == len(e.Control) && len(e.Weights) > 0
+	flags := e.Flags
+	if weights {
+		flags |= drawing.SplineRational
+	}
+	w.int(70, flags)
+	w.int(71, e.Degree)
+	w.int(72, len(e.Knots))
+	w.int(73, len(e.Control))
+	w.int(74, len(e.Fit))
+	w.float(42, 1e-10)
+	w.float(43, 1e-10)
+	if len(e.Fit) > 0 {
+		w.float(44, 1e-10)
+		if !e.StartTangent.IsZero() {
+			w.point(12, e.StartTangent)
+		}
+		if !e.EndTangent.IsZero() {
+			w.point(13, e.EndTangent)
+		}
+	}
+	for _, k := range e.Knots {
+		w.float(40, k)
+	}
+	if weights {
+		for _, v := range e.Weights {
+			w.float(41, v)
+		}
+	}
+	for _, p := range e.Control {
+		w.point(10, p)
+	}
+	for _, p := range e.Fit {
+		w.point(11, p)
+	}
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) solid(e *drawing.Solid) {
+	typ, sub := "SOLID", "AcDbTrace"
+	if e.Trace {
+		typ = "TRACE"
+	}
+	w.start(typ, &e.EntityProps)
+	w.subclass(sub)
+	for i, c := range e.Corners {
+		w.point(10+i, c)
+	}
+	w.thickness(e.Thickness)
+	w.extrusion(e.Extrusion)
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) face(e *drawing.Face3D) {
+	w.start("3DFACE", &e.EntityProps)
+	w.subclass("AcDbFace")
+	for i, c := range e.Corners {
+		w.point(10+i, c)
+	}
+	if e.InvisibleEdges != 0 {
+		w.int(70, e.InvisibleEdges)
+	}
+	w.hyperlink(&e.EntityProps)
+}
+
+// text writes the part TEXT, ATTRIB and ATTDEF entities share; the
+// writers of the latter two add theirs in more.
+func (w *writer) text(typ string, t *drawing.Text, more func()) {
+	w.textIn(typ, w.owner, t, more)
+}
+
+func (w *writer) textIn(typ, owner string, t *drawing.Text, more func()) {
+	w.startIn(typ, owner, &t.EntityProps)
+	w.subclass("AcDbText")
+	w.thickness(t.Thickness)
+	w.point(10, t.Position)
+	w.float(40, t.Height)
+	w.str(1, t.Value)
+	if t.Rotation != 0 {
+		w.angle(50, t.Rotation)
+	}
+	if t.WidthFactor != 1 && t.WidthFactor > 0 {
+		w.float(41, t.WidthFactor)
+	}
+	if t.Oblique != 0 {
+		w.angle(51, t.Oblique)
+	}
+	w.str(7, w.styleName(t.Style))
+	if t.Generation != 0 {
+		w.int(71, t.Generation)
+	}
+	if t.HAlign != 0 {
+		w.int(72, t.HAlign)
+	}
+	if t.HAlign != 0 || t.VAlign != 0 {
+		w.point(11, t.AlignPoint)
+	}
+	w.extrusion(t.Extrusion)
+	if more == nil {
+		w.subclass("AcDbText")
+		if t.VAlign != 0 {
+			w.int(73, t.VAlign)
+		}
+		w.hyperlink(&t.EntityProps)
+		return
+	}
+	more()
+	w.hyperlink(&t.EntityProps)
+}
+
+func (w *writer) attDef(a *drawing.AttDef) {
+	w.text("ATTDEF", &a.Text, func() {
+		w.subclass("AcDbAttributeDefinition")
+		if w.v == R2018 {
+			w.int(280, 0)
+		}
+		w.str(3, a.Prompt)
+		w.str(2, a.Tag)
+		w.int(70, a.Flags)
+		if a.VAlign != 0 {
+			w.int(74, a.VAlign)
+		}
+	})
+}
+
+func (w *writer) attrib(a *drawing.Attrib) {
+	w.attribIn(w.owner, a)
+}
+
+func (w *writer) attribIn(owner string, a *drawing.Attrib) {
+	w.textIn("ATTRIB", owner, &a.Text, func() {
+		w.subclass("AcDbAttribute")
+		if w.v == R2018 {
+			w.int(280, 0)
+		}
+		w.str(2, a.Tag)
+		w.int(70, a.Flags)
+		if a.VAlign != 0 {
+			w.int(74, a.VAlign)
+		}
+	})
+}
+
+var paragraphs = strings.NewReplacer("\r\n", `\P`, "\r", `\P`, "\n", `\P`)
+
+func (w *writer) mtext(e *drawing.MText) {
+	if w.v == R12 {
+		w.mtextR12(e)
+		return
+	}
+	w.start("MTEXT", &e.EntityProps)
+	w.subclass("AcDbMText")
+	w.point(10, e.Position)
+	w.float(40, e.Height)
+	w.float(41, e.RectWidth)
+	if w.v == R2018 && e.RectHeight > 0 {
+		w.float(46, e.RectHeight)
+	}
+	w.int(71, max(e.Attachment, drawing.MTextTopLeft))
+	w.int(72, max(e.FlowDirection, 1))
+	// Values longer than 250 bytes go in pieces, all but the last with
+	// group code 3.
+	chunks := w.chunks(paragraphs.Replace(e.Value), 250)
+	for _, c := range chunks[:len(chunks)-1] {
+		w.tag(3, c)
+	}
+	w.tag(1, chunks[len(chunks)-1])
+	w.str(7, w.styleName(e.Style))
+	w.extrusion(e.Extrusion)
+	if !e.XDirection.IsZero() {
+		w.point(11, e.XDirection)
+	}
+	if e.LineSpacingStyle != 0 {
+		w.int(73, e.LineSpacingStyle)
+	}
+	if e.LineSpacingFactor > 0 {
+		w.float(44, e.LineSpacingFactor)
+	}
+	// Columns were added in R2013.
+	if w.v == R2018 && e.ColumnType != 0 {
+		w.int(75, e.ColumnType)
+		w.int(76, e.ColumnCount)
+		w.int(78, 0)
+		w.int(79, 0)
+		w.float(48, e.ColumnWidth)
+		w.float(49, e.ColumnGutter)
+		for _, h := range e.ColumnHeights {
+			w.float(50, h)
+		}
+	}
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) insert(e *drawing.Insert) {
+	b := w.d.Block(e.Block)
+	if b == nil || w.v == R12 && b.IsLayout() {
+		w.lose("block references to blocks the drawing lacks were left out")
+		return
+	}
+	sub := "AcDbBlockReference"
+	if e.Columns > 1 || e.Rows > 1 {
+		sub = "AcDbMInsertBlock"
+	}
+	h := w.start("INSERT", &e.EntityProps)
+	w.subclass(sub)
+	if len(e.Attribs) > 0 {
+		w.int(66, 1)
+	}
+	w.str(2, b.Name)
+	w.point(10, e.Position)
+	scale := e.Scale
+	if scale.IsZero() {
+		scale = geom.Vec3{X: 1, Y: 1, Z: 1}
+	}
+	if scale.X != 1 {
+		w.float(41, scale.X)
+	}
+	if scale.Y != 1 {
+		w.float(42, scale.Y)
+	}
+	if scale.Z != 1 {
+		w.float(43, scale.Z)
+	}
+	if e.Rotation != 0 {
+		w.angle(50, e.Rotation)
+	}
+	if e.Columns > 1 || e.Rows > 1 {
+		w.int(70, max(e.Columns, 1))
+		w.int(71, max(e.Rows, 1))
+		w.float(44, e.ColumnSpacing)
+		w.float(45, e.RowSpacing)
+	}
+	w.extrusion(e.Extrusion)
+	w.hyperlink(&e.EntityProps)
+	if len(e.Attribs) == 0 {
+		return
+	}
+	for _, a := range e.Attribs {
+		w.attribIn(h, a)
+	}
+	w.seqend(h, &e.EntityProps)
+}
+
+func (w *writer) dimension(e *drawing.Dimension) {
+	typ := "DIMENSION"
+	if e.Type == drawing.DimArcLength {
+		if w.v != R2018 {
+			w.dimensionBlock(e)
+			return
+		}
+		typ = "ARC_DIMENSION"
+	}
+	w.start(typ, &e.EntityProps)
+	w.subclass("AcDbDimension")
+	if w.v == R2018 {
+		w.int(280, 0)
+	}
+	if e.Block != "" {
+		w.str(2, e.Block)
+	}
+	w.point(10, e.DefPoint)
+	w.point(11, e.TextMidPoint)
+	if typ == "ARC_DIMENSION" {
+		// Arc length dimensions are typed as 3-point angular ones.
+		w.int(70, drawing.DimAngular3Point|e.Flags)
+	} else {
+		w.int(70, e.Type|e.Flags)
+	}
+	if e.Text != "" {
+		w.str(1, e.Text)
+	}
+	if e.TextRotation != 0 {
+		w.angle(53, e.TextRotation)
+	}
+	if e.HorizontalDir != 0 {
+		w.angle(51, e.HorizontalDir)
+	}
+	if w.v != R12 {
+		w.float(42, e.Measurement)
+	}
+	w.extrusion(e.Extrusion)
+	w.str(3, w.dimStyleName(e.Style))
+
+	switch e.Type {
+	case drawing.DimLinear, drawing.DimAligned:
+		w.subclass("AcDbAlignedDimension")
+		w.point(13, e.DefPoint2)
+		w.point(14, e.DefPoint3)
+		if e.Type == drawing.DimLinear {
+			w.angle(50, e.Rotation)
+		}
+		if e.Oblique != 0 {
+			w.angle(52, e.Oblique)
+		}
+		if e.Type == drawing.DimLinear {
+			w.subclass("AcDbRotatedDimension")
+		}
+	case drawing.DimAngular:
+		w.subclass("AcDb2LineAngularDimension")
+		w.point(13, e.DefPoint2)
+		w.point(14, e.DefPoint3)
+		w.point(15, e.DefPoint4)
+		w.point(16, e.DefPoint5)
+	case drawing.DimAngular3Point:
+		w.subclass("AcDb3PointAngularDimension")
+		w.point(13, e.DefPoint2)
+		w.point(14, e.DefPoint3)
+		w.point(15, e.DefPoint4)
+	case drawing.DimDiameter, drawing.DimRadius:
+		sub := "AcDbRadialDimension"
+		if e.Type == drawing.DimDiameter {
+			sub = "AcDbDiametricDimension"
+		}
+		w.subclass(sub)
+		w.point(15, e.DefPoint4)
+		w.float(40, e.LeaderLength)
+	case drawing.DimOrdinate:
+		w.subclass("AcDbOrdinateDimension")
+		w.point(13, e.DefPoint2)
+		w.point(14, e.DefPoint3)
+	case drawing.DimArcLength:
+		center := e.DefPoint4
+		w.subclass("AcDbArcDimension")
+		w.point(13, e.DefPoint2)
+		w.point(14, e.DefPoint3)
+		w.point(15, center)
+		w.int(70, 0)
+		w.angle(40, e.DefPoint2.Sub(center).XY().Angle())
+		w.angle(41, e.DefPoint3.Sub(center).XY().Angle())
+		w.int(71, 0)
+		w.point(16, geom.Vec3{})
+		w.point(17, geom.Vec3{})
+	}
+
+	if len(e.Overrides) > 0 {
+		w.tag(1001, "ACAD")
+		w.tag(1000, "DSTYLE")
+		w.tag(1002, "{")
+		for _, o := range e.Overrides {
+			code := o.Code
+			if code >= 340 && w.v == R12 || !w.hasDimVar(code) {
+				continue
+			}
+			w.int(1070, code)
+			switch {
+			case code >= 340:
+				h := w.dimVarHandle(code, o.Value)
+				if h == "" {
+					h = "0"
+				}
+				w.tag(1005, h)
+			case code == 3:
+				w.str(1000, o.Value)
+			case code < 70 || code >= 140 && code < 150:
+				w.tag(1040, o.Value)
+			default:
+				w.tag(1070, o.Value)
+			}
+		}
+		w.tag(1002, "}")
+	}
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) viewport(e *drawing.Viewport) {
+	w.start("VIEWPORT", &e.EntityProps)
+	w.subclass("AcDbViewport")
+	w.point(10, e.Center)
+	w.float(40, e.Width)
+	w.float(41, e.Height)
+	on := 1
+	if e.Status&drawing.ViewportOff != 0 {
+		on = 0
+	}
+	w.int(68, on)
+	w.int(69, e.ID)
+	if w.v == R12 {
+		w.mview(e)
+		w.hyperlink(&e.EntityProps)
+		return
+	}
+	w.point2(12, e.ViewCenter)
+	w.point2(13, geom.Vec2{})
+	w.point2(14, geom.Vec2{X: 10, Y: 10})
+	w.point2(15, geom.Vec2{X: 10, Y: 10})
+	w.point(16, e.ViewDirection)
+	w.point(17, e.ViewTarget)
+	w.float(42, 50)
+	w.float(43, 0)
+	w.float(44, 0)
+	w.float(45, e.ViewHeight)
+	w.float(50, 0)
+	w.angle(51, e.TwistAngle)
+	w.int(72, 1000)
+	for _, name := range e.FrozenLayers {
+		if h := w.layers[key(name)]; h != "" {
+			w.tag(331, h)
+		}
+	}
+	status := e.Status
+	clip := ""
+	if status&drawing.ViewportNonRectClip != 0 {
+		if clip = w.clips[e.ClipBoundary]; clip == "" {
+			status &^= drawing.ViewportNonRectClip
+		}
+	}
+	w.int(90, status)
+	w.handle(340, clip)
+	w.tag(1, "")
+	w.int(281, 0)
+	w.int(71, 1)
+	w.int(74, 0)
+	w.point(110, geom.Vec3{})
+	w.point(111, geom.Vec3{X: 1})
+	w.point(112, geom.Vec3{Y: 1})
+	w.int(79, 0)
+	w.float(146, 0)
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) hatch(e *drawing.Hatch) {
+	if w.v == R12 {
+		w.hatchR12(e)
+		return
+	}
+	solid, pattern := e.Solid, e.Pattern
+	gradient := e.Gradient != nil && len(e.Gradient.Colors) > 0
+	if gradient && w.v != R2018 {
+		w.lose("gradient fills were written as solid fills")
+		solid, pattern, gradient = true, "SOLID", false
+	}
+	if solid && pattern == "" {
+		pattern = "SOLID"
+	}
+	w.start("HATCH", &e.EntityProps)
+	w.subclass("AcDbHatch")
+	w.point(10, geom.Vec3{Z: e.Elevation})
+	n := e.Extrusion
+	if n.IsZero() {
+		n = geom.ZAxis
+	}
+	w.point(210, n)
+	w.str(2, pattern)
+	w.bool(70, solid)
+	w.bool(71, e.Associative)
+	w.int(91, len(e.Loops))
+	for _, l := range e.Loops {
+		w.hatchLoop(l)
+	}
+	w.int(75, e.Style)
+	w.int(76, e.PatternType)
+	if !solid {
+		w.angle(52, e.Angle)
+		w.float(41, e.Scale)
+		w.bool(77, e.Double)
+		w.int(78, len(e.Lines))
+		for _, l := range e.Lines {
+			w.angle(53, l.Angle)
+			w.float(43, l.Base.X)
+			w.float(44, l.Base.Y)
+			w.float(45, l.Offset.X)
+			w.float(46, l.Offset.Y)
+			w.int(79, len(l.Dashes))
+			for _, d := range l.Dashes {
+				w.float(49, d)
+			}
+		}
+	}
+	w.int(98, 0)
+	if gradient {
+		g := e.Gradient
+		w.int(450, 1)
+		w.int(451, 0)
+		w.bool(452, g.SingleColor)
+		w.int(453, len(g.Colors))
+		w.float(460, g.Angle)
+		w.float(461, g.Shift)
+		w.float(462, g.Tint)
+		for i, c := range g.Colors {
+			w.float(463, float64(i))
+			w.int(63, w.colorIndex(c))
+			if c.True {
+				w.int(421, int(c.RGB))
+			}
+		}
+		w.str(470, g.Name)
+	}
+	w.hyperlink(&e.EntityProps)
+}
+
+func (w *writer) hatchLoop(l drawing.HatchLoop) {
+	if l.Flags&drawing.HatchLoopPolyline != 0 {
+		w.int(92, l.Flags)
+		bulges := false
+		for _, v := range l.Vertices {
+			bulges = bulges || v.Bulge != 0
+		}
+		w.bool(72, bulges)
+		w.int(73, 1)
+		w.int(93, len(l.Vertices))
+		for _, v := range l.Vertices {
+			w.point2(10, v.Position.XY())
+			if bulges {
+				w.float(42, v.Bulge)
+			}
+		}
+		w.int(97, 0)
+		return
+	}
+	w.int(92, l.Flags)
+	w.int(93, len(l.Edges))
+	for _, e := range l.Edges {
+		w.int(72, e.Type)
+		switch e.Type {
+		case drawing.EdgeLine:
+			w.point2(10, e.Start)
+			w.point2(11, e.End)
+		case drawing.EdgeArc:
+			w.point2(10, e.Center)
+			w.float(40, e.Radius)
+			w.angle(50, e.StartAngle)
+			w.angle(51, e.EndAngle)
+			w.bool(73, e.CCW)
+		case drawing.EdgeEllipse:
+			w.point2(10, e.Center)
+			w.point2(11, e.MajorAxis)
+			w.float(40, e.Ratio)
+			w.angle(50, e.StartAngle)
+			w.angle(51, e.EndAngle)
+			w.bool(73, e.CCW)
+		case drawing.EdgeSpline:
+			w.splineEdge(e.Spline)
+		}
+	}
+	w.int(97, 0)
+}
+
+func (w *writer) splineEdge(s *drawing.Spline) {
+	if s == nil {
+		s = &drawing.Spline{}
+	}
+	weights := len(s.Weights) == len(s.Control) && len(s.Weights) > 0
+	w.int(94, s.Degree)
+	w.bool(73, weights)
+	w.bool(74, s.Flags&drawing.SplinePeriodic != 0)
+	w.int(95, len(s.Knots))
+	w.int(96, len(s.Control))
+	for _, k := range s.Knots {
+		w.float(40, k)
+	}
+	for i, p := range s.Control {
+		w.point2(10, p.XY())
+		if weights {
+			w.float(42, s.Weights[i])
+		}
+	}
+	// Fit data was added in R2010.
+	if w.v != R2018 {
+		return
+	}
+	w.int(97, len(s.Fit))
+	for _, p := range s.Fit {
+		w.point2(11, p.XY())
+	}
+	if len(s.Fit) > 0 {
+		w.point2(12, s.StartTangent.XY())
+		w.point2(13, s.EndTangent.XY())
+	}
+}
+
+func (w *writer) image(e *drawing.Image) {
+	def := w.imageDefs[e.Def]
+	if w.v == R12 || def == "" {
+		w.lose("IMAGE entities were left out")
+		return
+	}
+	h := w.start("IMAGE", &e.EntityProps)
+	r := reactor{handle: w.next(), image: h, def: e.Def}
+	w.reactors = append(w.reactors, r)
+	w.subclass("AcDbRasterImage")
+	w.int(90, 0)
+	w.point(10, e.Insertion)
+	w.point(11, e.U)
+	w.point(12, e.V)
+	w.point2(13, e.Size)
+	w.tag(340, def)
+	w.int(70, e.Flags)
+	w.bool(280, e.Clipping)
+	w.int(281, 50)
+	w.int(282, 50)
+	w.int(283, 0)
+	w.tag(360, r.handle)
+	clip := e.Clip
+	if len(clip) < 2 {
+		clip = []geom.Vec2{{X: -0.5, Y: -0.5}, e.Size.Sub(geom.Vec2{X: 0.5, Y: 0.5})}
+	}
+	if len(clip) == 2 {
+		w.int(71, 1)
+	} else {
+		w.int(71, 2)
+	}
+	w.int(91, len(clip))
+	for _, p := range clip {
+		w.point2(14, p)
+	}
+	if w.v == R2018 {
+		w.bool(290, e.ClipInverted)
+	}
+	w.hyperlink(&e.EntityProps)
+}
@@ -0,0 +1,1384 @@
+package dxf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// Version is the release whose DXF format a file is written in, named by
+// its $ACADVER tag.
+type Version string
+
+const (
+	R12   Version = "AC1009"
+	R2000 Version = "AC1015"
+	R2018 Version = "AC1032"
+)
+
+func (v Version) String() string {
+	switch v {
+	case R12:
+		return "R12"
+	case R2000:
+		return "2000"
+	}
+	return "2018"
+}
+
+// Encode writes d as an ASCII DXF file of version v. What v has no place
+// for is converted to what it has or left out, and the warnings say so.
+func Encode(d *drawing.Drawing, v Version) ([]byte, []string) {
+	w := newWriter(d, v)
+	var body bytes.Buffer
+	w.out = &body
+	if v != R12 {
+		w.section("CLASSES", w.classSection)
+	}
+	w.section("TABLES", w.tableSection)
+	w.section("BLOCKS", w.blockSection)
+	w.section("ENTITIES", w.entitySection)
+	if v != R12 {
+		w.section("OBJECTS", w.objectSection)
+	}
+
+	// The header gives the next free handle, so it is written last.
+	var file bytes.Buffer
+	w.out = &file
+	w.section("HEADER", w.headerSection)
+	file.Write(body.Bytes())
+	w.tag(0, "EOF")
+	return file.Bytes(), w.warnings()
+}
+
+// writer writes one drawing. Files of R12 have no handles; the writer
+// gives them out all the same and leaves them out of the file.
+type writer struct {
+	d   *drawing.Drawing
+	v   Version
+	out *bytes.Buffer
+	// enc encodes strings in the code page files before R2007 are
+	// written in, which codepage names; later files are UTF-8.
+	enc      *encoding.Encoder
+	codepage string
+
+	seed uint64
+	// owner is the block record of the entities being written, and paper
+	// is set while they are in paper space.
+	owner string
+	paper bool
+
+	model, paperSpace *drawing.Block
+	// blocks are all blocks, model and paper space first, and layouts
+	// the layouts in tab order.
+	blocks  []*drawing.Block
+	layouts []*layout
+	// extraLayers are the layers entities are on that the drawing has no
+	// entry for.
+	extraLayers []*drawing.Layer
+
+	// Handles given out ahead, as entries are referred to before they are
+	// written: table entries by upper case name, shape files by the file
+	// name, block records by block, image definitions and the entities
+	// that clip viewports by their handle in the drawing, and plot
+	// styles by name.
+	tables     map[string]string
+	layers     map[string]string
+	linetypes  map[string]string
+	styles     map[string]string
+	shapes     map[string]string
+	dimStyles  map[string]string
+	records    map[*drawing.Block]string
+	imageDefs  map[drawing.Handle]string
+	clips      map[drawing.Handle]string
+	plotStyles map[string]string
+	// plotStyleNames are the plot styles in the order they are written,
+	// the default Normal first.
+	plotStyleNames []string
+
+	rootDict, groupDict, layoutDict, plotStyleDict string
+	imageDict, imageVars, summary                  string
+	// reactors tie the images written to their definitions.
+	reactors []reactor
+	// counts are the numbers of instances of the classes.
+	counts map[string]int
+
+	lost      map[string]int
+	lostOrder []string
+}
+
+type layout struct {
+	l      *drawing.Layout
+	block  *drawing.Block
+	handle string
+}
+
+type reactor struct {
+	handle, image string
+	def           drawing.Handle
+}
+
+func newWriter(d *drawing.Drawing, v Version) *writer {
+	w := &writer{
+		d:          d,
+		v:          v,
+		codepage:   "ANSI_1252",
+		tables:     make(map[string]string),
+		layers:     make(map[string]string),
+		linetypes:  make(map[string]string),
+		styles:     make(map[string]string),
+		shapes:     make(map[string]string),
+		dimStyles:  make(map[string]string),
+		records:    make(map[*drawing.Block]string),
+		imageDefs:  make(map[drawing.Handle]string),
+		clips:      make(map[drawing.Handle]string),
+		plotStyles: make(map[string]string),
+		counts:     make(map[string]int),
+		lost:       make(map[string]int),
+	}
+	if v != R2018 {
+		enc := d.Header.Encoding
+		name := codepageName(enc)
+		if name == "" {
+			enc, name = charmap.Windows1252, "ANSI_1252"
+		}
+		w.enc, w.codepage = enc.NewEncoder(), name
+	}
+	w.plan()
+	return w
+}
+
+func codepageName(enc encoding.Encoding) string {
+	if enc == nil {
+		return ""
+	}
+	for name, e := range codepages {
+		if e == enc {
+			return name
+		}
+	}
+	return ""
+}
+
+// plan lays out the blocks and layouts and gives out the handles of what
+// is referred to before it is written.
+func (w *writer) plan() {
+	d := w.d
+	w.model = d.ModelSpace()
+	w.paperSpace = d.Block(drawing.PaperSpace)
+	if w.paperSpace == nil {
+		w.paperSpace = &drawing.Block{Name: drawing.PaperSpace}
+	}
+	w.blocks = []*drawing.Block{w.model, w.paperSpace}
+	for _, b := range d.Blocks {
+		if b != w.model && b != w.paperSpace {
+			w.blocks = append(w.blocks, b)
+		}
+	}
+
+	w.layouts = []*layout{{l: d.ModelLayout(), block: w.model}}
+	active := false
+	for _, l := range d.PaperLayouts() {
+		b := d.Block(l.Block)
+		if b == nil {
+			continue
+		}
+		active = active || b == w.paperSpace
+		w.layouts = append(w.layouts, &layout{l: l, block: b})
+	}
+	if !active {
+		w.layouts = append(w.layouts, &layout{
+			l:     &drawing.Layout{Name: w.layoutName(), TabOrder: len(w.layouts), Block: drawing.PaperSpace},
+			block: w.paperSpace,
+		})
+	}
+
+	for _, name := range []string{"VPORT", "LTYPE", "LAYER", "STYLE", "VIEW", "UCS", "APPID", "DIMSTYLE", "BLOCK_RECORD"} {
+		w.tables[name] = w.next()
+	}
+	for _, l := range d.Layers {
+		w.layers[key(l.Name)] = w.next()
+	}
+	for _, lt := range d.Linetypes {
+		w.linetypes[key(lt.Name)] = w.next()
+	}
+	for _, s := range d.Styles {
+		w.styles[key(s.Name)] = w.next()
+	}
+	for _, lt := range d.Linetypes {
+		for _, e := range lt.Elements {
+			if e.Shape != 0 && e.ShapeFile != "" && w.shapes[key(e.ShapeFile)] == "" {
+				w.shapes[key(e.ShapeFile)] = w.next()
+			}
+		}
+	}
+	for _, s := range d.DimStyles {
+		w.dimStyles[key(s.Name)] = w.next()
+	}
+	for _, b := range w.blocks {
+		w.records[b] = w.next()
+	}
+	for _, l := range w.layouts {
+		l.handle = w.next()
+	}
+	for _, def := range d.ImageDefs {
+		w.imageDefs[def.Handle] = w.next()
+	}
+	w.rootDict, w.groupDict, w.layoutDict, w.plotStyleDict = w.next(), w.next(), w.next(), w.next()
+
+	clips := make(map[drawing.Handle]bool)
+	plotStyles := map[string]bool{"Normal": true}
+	for _, l := range d.Layers {
+		plotStyles[l.PlotStyle] = true
+	}
+	w.walk(func(e drawing.Entity) {
+		p := e.Props()
+		plotStyles[p.PlotStyle] = true
+		if w.layers[key(p.Layer)] == "" {
+			l := &drawing.Layer{Name: p.Layer, Color: drawing.Color{Index: 7}, Linetype: "Continuous", Lineweight: drawing.LineweightDefault, Plot: true}
+			w.extraLayers = append(w.extraLayers, l)
+			w.layers[key(l.Name)] = w.next()
+		}
+		switch e := e.(type) {
+		case *drawing.Viewport:
+			if e.Status&drawing.ViewportNonRectClip != 0 && e.ClipBoundary != 0 {
+				clips[e.ClipBoundary] = true
+			}
+		case *drawing.Image:
+			if w.imageDefs[e.Def] != "" {
+				w.counts["IMAGE"]++
+			}
+		case *drawing.Dimension:
+			if e.Type == drawing.DimArcLength {
+				w.counts["ARC_DIMENSION"]++
+			}
+		}
+	})
+	w.walk(func(e drawing.Entity) {
+		if h := e.Props().Handle; clips[h] && w.clips[h] == "" {
+			w.clips[h] = w.next()
+		}
+	})
+
+	delete(plotStyles, "")
+	delete(plotStyles, "ByBlock")
+	delete(plotStyles, "Normal")
+	w.plotStyleNames = append([]string{"Normal"}, sortedKeys(plotStyles)...)
+	for _, name := range w.plotStyleNames {
+		w.plotStyles[name] = w.next()
+	}
+	if w.counts["IMAGE"] > 0 {
+		w.imageDict, w.imageVars = w.next(), w.next()
+		w.counts["IMAGEDEF"] = len(d.ImageDefs)
+		w.counts["IMAGEDEF_REACTOR"] = w.counts["IMAGE"]
+		w.counts["RASTERVARIABLES"] = 1
+	}
+	if !isEmpty(d.Summary) {
+		w.summary = w.next()
+	}
+	w.counts["ACDBDICTIONARYWDFLT"] = 1
+	w.counts["ACDBPLACEHOLDER"] = len(w.plotStyleNames)
+	w.counts["LAYOUT"] = len(w.layouts)
+}
+
+// walk calls f for the entities of all blocks and the attributes of
+// block references.
+func (w *writer) walk(f func(e drawing.Entity)) {
+	for _, b := range w.blocks {
+		for _, e := range b.Entities {
+			f(e)
+			if ins, ok := e.(*drawing.Insert); ok {
+				for _, a := range ins.Attribs {
+					f(a)
+				}
+			}
+		}
+	}
+}
+
+// layoutName returns a name for the layout added for paper space that no
+// layout has.
+func (w *writer) layoutName() string {
+	for i := 1; ; i++ {
+		name := "Layout" + strconv.Itoa(i)
+		if !slices.ContainsFunc(w.layouts, func(l *layout) bool { return key(l.l.Name) == key(name) }) {
+			return name
+		}
+	}
+}
+
+func (w *writer) next() string {
+	w.seed++
+	return strings.ToUpper(strconv.FormatUint(w.seed, 16))
+}
+
+// lose notes something the version written has no place for.
+func (w *writer) lose(what string) {
+	if w.lost[what] == 0 {
+		w.lostOrder = append(w.lostOrder, what)
+	}
+	w.lost[what]++
+}
+
+func (w *writer) warnings() []string {
+	var out []string
+	for _, what := range w.lostOrder {
+		out = append(out, fmt.Sprintf("dxf %s: %d %s", w.v, w.lost[what], what))
+	}
+	return out
+}
+
+func (w *writer) tag(code int, value string) {
+	fmt.Fprintf(w.out, "%3d\r\n%s\r\n", code, value)
+}
+
+// str writes a string that stays on its line, encoded for the file.
+func (w *writer) str(code int, s string) {
+	w.tag(code, w.encode(lineBreaks.Replace(s)))
+}
+
+func (w *writer) int(code, v int) {
+	w.tag(code, strconv.Itoa(v))
+}
+
+func (w *writer) bool(code int, v bool) {
+	if v {
+		w.int(code, 1)
+	} else {
+		w.int(code, 0)
+	}
+}
+
+func (w *writer) float(code int, v float64) {
+	w.tag(code, number(v))
+}
+
+func (w *writer) angle(code int, rad float64) {
+	w.float(code, geom.Deg(rad))
+}
+
+func (w *writer) point(code int, p geom.Vec3) {
+	w.float(code, p.X)
+	w.float(code+10, p.Y)
+	w.float(code+20, p.Z)
+}
+
+func (w *writer) point2(code int, p geom.Vec2) {
+	w.float(code, p.X)
+	w.float(code+10, p.Y)
+}
+
+// handle writes a reference to another object, which files of R12 do
+// not have.
+func (w *writer) handle(code int, h string) {
+	if w.v != R12 && h != "" {
+		w.tag(code, h)
+	}
+}
+
+// subclass writes the marker of a part of an object, which files of R12
+// do not have.
+func (w *writer) subclass(name string) {
+	if w.v != R12 {
+		w.tag(100, name)
+	}
+}
+
+func (w *writer) section(name string, body func()) {
+	w.tag(0, "SECTION")
+	w.tag(2, name)
+	body()
+	w.tag(0, "ENDSEC")
+}
+
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// encode converts s to the code page of the file, writing the characters
+// it lacks as \U+XXXX.
+func (w *writer) encode(s string) string {
+	if w.enc == nil || isASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteString(w.char(r))
+	}
+	return b.String()
+}
+
+func (w *writer) char(r rune) string {
+	if r < utf8.RuneSelf || w.enc == nil {
+		return string(r)
+	}
+	if out, err := w.enc.String(string(r)); err == nil {
+		return out
+	}
+	if r > 0xFFFF {
+		return "?"
+	}
+	return fmt.Sprintf(`\U+%04X`, r)
+}
+
+// chunks encodes s in pieces of at most n bytes, without splitting a
+// character, as long values are stored.
+func (w *writer) chunks(s string, n int) []string {
+	var out []string
+	var b strings.Builder
+	for _, r := range s {
+		c := w.char(r)
+		if b.Len()+len(c) > n {
+			out = append(out, b.String())
+			b.Reset()
+		}
+		b.WriteString(c)
+	}
+	return append(out, b.String())
+}
+
+// number formats a real the way AutoCAD does, with a decimal point and
+// an exponent only for magnitudes far from one.
+func number(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		v = 0
+	}
+	if a := math.Abs(v); a != 0 && (a < 1e-10 || a >= 1e15) {
+		return strconv.FormatFloat(v, 'E', -1, 64)
+	}
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func key(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func isEmpty(s drawing.Summary) bool {
+	return s.Title == "" && s.Subject == "" && s.Author == "" && s.Keywords == "" &&
+		s.Comments == "" && s.LastSavedBy == "" && s.Revision == "" && s.HyperlinkBase == "" && len(s.Custom) == 0
+}
+
+// julianDate converts t to the days since noon of 1 January 4713 BC that
+// dates are stored as.
+func julianDate(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+func (w *writer) headerSection() {
+	h := &w.d.Header
+	w.tag(9, "$ACADVER")
+	w.tag(1, string(w.v))
+	w.tag(9, "$DWGCODEPAGE")
+	w.tag(3, w.codepage)
+	for _, v := range []struct {
+		name string
+		p    geom.Vec3
+	}{
+		{"$INSBASE", h.InsBase},
+		{"$EXTMIN", h.ExtMin},
+		{"$EXTMAX", h.ExtMax},
+	} {
+		w.tag(9, v.name)
+		w.point(10, v.p)
+	}
+	w.tag(9, "$LIMMIN")
+	w.point2(10, h.LimMin)
+	w.tag(9, "$LIMMAX")
+	w.point2(10, h.LimMax)
+	w.tag(9, "$LTSCALE")
+	w.float(40, h.LTScale)
+	if w.v != R12 {
+		w.tag(9, "$CELTSCALE")
+		w.float(40, h.CELTScale)
+	}
+	w.tag(9, "$PSLTSCALE")
+	w.bool(70, h.PSLTScale)
+	w.tag(9, "$TEXTSIZE")
+	w.float(40, h.TextSize)
+	w.tag(9, "$TEXTSTYLE")
+	w.str(7, w.styleName(h.TextStyle))
+	w.tag(9, "$PDMODE")
+	w.int(70, h.PDMode)
+	w.tag(9, "$PDSIZE")
+	w.float(40, h.PDSize)
+	w.tag(9, "$FILLMODE")
+	w.bool(70, h.FillMode)
+	if w.v != R12 {
+		w.tag(9, "$LWDISPLAY")
+		w.bool(290, h.LWDisplay)
+		w.tag(9, "$INSUNITS")
+		w.int(70, h.InsUnits)
+	}
+	if !h.Created.IsZero() {
+		w.tag(9, "$TDCREATE")
+		w.float(40, julianDate(h.Created))
+	}
+	if !h.Updated.IsZero() {
+		w.tag(9, "$TDUPDATE")
+		w.float(40, julianDate(h.Updated))
+	}
+	w.tag(9, "$PINSBASE")
+	w.point(10, h.PaperInsBase)
+	w.tag(9, "$PEXTMIN")
+	w.point(10, h.PaperExtMin)
+	w.tag(9, "$PEXTMAX")
+	w.point(10, h.PaperExtMax)
+	w.tag(9, "$PLIMMIN")
+	w.point2(10, h.PaperLimMin)
+	w.tag(9, "$PLIMMAX")
+	w.point2(10, h.PaperLimMax)
+	if w.v == R12 {
+		w.tag(9, "$HANDLING")
+		w.int(70, 0)
+	} else {
+		w.tag(9, "$HANDSEED")
+		w.tag(5, strings.ToUpper(strconv.FormatUint(w.seed+1, 16)))
+	}
+}
+
+// classes describe the objects that are not built into the format:
+// their DXF and C++ class names, the application that defines them, what
+// a program without it may do with them and whether they are entities.
+var classes = []struct {
+	name, class, app string
+	flags            int
+	entity           bool
+}{
+	{"ACDBDICTIONARYWDFLT", "AcDbDictionaryWithDefault", "ObjectDBX Classes", 0, false},
+	{"ACDBPLACEHOLDER", "AcDbPlaceHolder", "ObjectDBX Classes", 0, false},
+	{"LAYOUT", "AcDbLayout", "ObjectDBX Classes", 0, false},
+	{"IMAGE", "AcDbRasterImage", "ISM", 2175, true},
+	{"IMAGEDEF", "AcDbRasterImageDef", "ISM", 0, false},
+	{"IMAGEDEF_REACTOR", "AcDbRasterImageDefReactor", "ISM", 1, false},
+	{"RASTERVARIABLES", "AcDbRasterVariables", "ISM", 0, false},
+	{"ARC_DIMENSION", "AcDbArcDimension", "ObjectDBX Classes", 1025, true},
+}
+
+func (w *writer) classSection() {
+	for _, c := range classes {
+		n := w.counts[c.name]
+		if n == 0 || c.name == "ARC_DIMENSION" && w.v != R2018 {
+			continue
+		}
+		w.tag(0, "CLASS")
+		w.tag(1, c.name)
+		w.tag(2, c.class)
+		w.tag(3, c.app)
+		w.int(90, c.flags)
+		if w.v == R2018 {
+			w.int(91, n)
+		}
+		w.int(280, 0)
+		w.bool(281, c.entity)
+	}
+}
+
+func (w *writer) tableSection() {
+	d := w.d
+	w.table("VPORT", 1, w.vport)
+
+	var linetypes []*drawing.Linetype
+	for _, lt := range d.Linetypes {
+		// Files of R12 have no entries for ByLayer and ByBlock.
+		if w.v != R12 || key(lt.Name) != "BYLAYER" && key(lt.Name) != "BYBLOCK" {
+			linetypes = append(linetypes, lt)
+		}
+	}
+	w.table("LTYPE", len(linetypes), func() {
+		for _, lt := range linetypes {
+			w.linetype(lt)
+		}
+	})
+
+	layers := slices.Concat(d.Layers, w.extraLayers)
+	w.table("LAYER", len(layers), func() {
+		for _, l := range layers {
+			w.layer(l)
+		}
+	})
+
+	shapes := sortedKeys(func() map[string]bool {
+		m := make(map[string]bool)
+		for f := range w.shapes {
+			m[f] = true
+		}
+		return m
+	}())
+	if w.v == R12 {
+		shapes = nil
+	}
+	w.table("STYLE", len(d.Styles)+len(shapes), func() {
+		for _, s := range d.Styles {
+			w.style(s, w.styles[key(s.Name)])
+		}
+		for _, f := range shapes {
+			w.style(&drawing.TextStyle{FontFile: w.shapeFile(f), WidthFactor: 1, ShapeFile: true}, w.shapes[f])
+		}
+	})
+
+	w.table("VIEW", len(d.Views), func() {
+		for _, v := range d.Views {
+			w.view(v)
+		}
+	})
+	w.table("UCS", 0, func() {})
+	apps := []string{"ACAD", "PE_URL"}
+	w.table("APPID", len(apps), func() {
+		for _, name := range apps {
+			w.entry("APPID", "AcDbRegAppTableRecord", w.next())
+			w.tag(2, name)
+			w.int(70, 0)
+		}
+	})
+	w.table("DIMSTYLE", len(d.DimStyles), func() {
+		for _, s := range d.DimStyles {
+			w.dimStyle(s)
+		}
+	})
+	if w.v != R12 {
+		w.table("BLOCK_RECORD", len(w.blocks), func() {
+			for _, b := range w.blocks {
+				w.entry("BLOCK_RECORD", "AcDbBlockTableRecord", w.records[b])
+				w.str(2, b.Name)
+				if l := w.layoutOf(b); l != nil {
+					w.handle(340, l.handle)
+				}
+			}
+		})
+	}
+}
+
+// shapeFile returns the file name of a shape file as the linetypes that
+// load it give it.
+func (w *writer) shapeFile(k string) string {
+	for _, lt := range w.d.Linetypes {
+		for _, e := range lt.Elements {
+			if key(e.ShapeFile) == k {
+				return e.ShapeFile
+			}
+		}
+	}
+	return k
+}
+
+func (w *writer) layoutOf(b *drawing.Block) *layout {
+	for _, l := range w.layouts {
+		if l.block == b {
+			return l
+		}
+	}
+	return nil
+}
+
+func (w *writer) table(name string, count int, entries func()) {
+	w.tag(0, "TABLE")
+	w.tag(2, name)
+	w.handle(5, w.tables[name])
+	w.handle(330, "0")
+	w.subclass("AcDbSymbolTable")
+	w.int(70, count)
+	if name == "DIMSTYLE" && w.v != R12 {
+		w.tag(100, "AcDbDimStyleTable")
+		w.int(71, count)
+	}
+	entries()
+	w.tag(0, "ENDTAB")
+}
+
+// entry starts a table entry; the subclass is that of its table.
+func (w *writer) entry(table, subclass, h string) {
+	w.tag(0, table)
+	if w.v == R12 {
+		return
+	}
+	if table == "DIMSTYLE" {
+		w.tag(105, h)
+	} else {
+		w.tag(5, h)
+	}
+	w.tag(330, w.tables[table])
+	w.tag(100, "AcDbSymbolTableRecord")
+	w.tag(100, subclass)
+}
+
+// vport writes the viewport the drawing opens in, showing its extents or
+// else its limits.
+func (w *writer) vport() {
+	h := &w.d.Header
+	var box geom.Box
+	if h.ExtMax.X > h.ExtMin.X && h.ExtMax.Y > h.ExtMin.Y && h.ExtMax.X < 1e15 {
+		box.Add(h.ExtMin.XY())
+		box.Add(h.ExtMax.XY())
+	} else {
+		box.Add(h.LimMin)
+		box.Add(h.LimMax)
+	}
+	height, aspect := box.Height()*1.1, 1.5
+	if box.Height() > 0 {
+		aspect = max(box.Width()/box.Height(), 0.1)
+	}
+	if height <= 0 {
+		height = 10
+	}
+
+	w.entry("VPORT", "AcDbViewportTableRecord", w.next())
+	w.tag(2, "*ACTIVE")
+	w.int(70, 0)
+	w.point2(10, geom.Vec2{})
+	w.point2(11, geom.Vec2{X: 1, Y: 1})
+	w.point2(12, box.Center())
+	w.point2(13, geom.Vec2{})
+	w.point2(14, geom.Vec2{X: 1, Y: 1})
+	w.point2(15, geom.Vec2{X: 1, Y: 1})
+	w.point(16, geom.ZAxis)
+	w.point(17, geom.Vec3{})
+	w.float(40, height)
+	w.float(41, aspect)
+	w.float(42, 50)
+	w.float(43, 0)
+	w.float(44, 0)
+	w.float(50, 0)
+	w.float(51, 0)
+	w.int(71, 0)
+	w.int(72, 1000)
+	w.int(73, 1)
+	w.int(74, 3)
+	w.int(75, 0)
+	w.int(76, 0)
+	w.int(77, 0)
+	w.int(78, 0)
+}
+
+// Flags of the elements of complex linetypes.
+const (
+	elementAbsolute = 1
+	elementText     = 2
+	elementShape    = 4
+)
+
+func (w *writer) linetype(lt *drawing.Linetype) {
+	w.entry("LTYPE", "AcDbLinetypeTableRecord", w.linetypes[key(lt.Name)])
+	name := lt.Name
+	if w.v == R12 {
+		name = strings.ToUpper(name)
+	}
+	w.str(2, name)
+	w.int(70, 0)
+	w.str(3, lt.Description)
+	w.int(72, 'A')
+	w.int(73, len(lt.Elements))
+	w.float(40, lt.Length)
+	for _, e := range lt.Elements {
+		w.float(49, e.Length)
+		// Shapes and text only exist from R13 on.
+		if w.v == R12 {
+			continue
+		}
+		flags, style := 0, ""
+		switch {
+		case e.Text != "":
+			flags, style = elementText, w.styles[key(e.Style)]
+		case e.Shape != 0:
+			flags, style = elementShape, w.shapes[key(e.ShapeFile)]
+		}
+		if e.Absolute {
+			flags |= elementAbsolute
+		}
+		w.int(74, flags)
+		if style == "" {
+			continue
+		}
+		w.int(75, e.Shape)
+		w.handle(340, style)
+		w.float(46, e.Scale)
+		w.angle(50, e.Rotation)
+		w.float(44, e.Offset.X)
+		w.float(45, e.Offset.Y)
+		if e.Text != "" {
+			w.str(9, e.Text)
+		}
+	}
+}
+
+func (w *writer) layer(l *drawing.Layer) {
+	w.entry("LAYER", "AcDbLayerTableRecord", w.layers[key(l.Name)])
+	w.str(2, l.Name)
+	flags := 0
+	if l.Frozen {
+		flags |= 1
+	}
+	if l.Locked {
+		flags |= 4
+	}
+	w.int(70, flags)
+	c := w.colorIndex(l.Color)
+	if c <= 0 || c >= drawing.ColorByLayer {
+		c = 7
+	}
+	if l.Off {
+		c = -c
+	}
+	w.int(62, c)
+	if w.v == R2018 && l.Color.True {
+		w.int(420, int(l.Color.RGB))
+	}
+	w.str(6, w.linetypeName(l.Linetype, "Continuous"))
+	if w.v == R12 {
+		return
+	}
+	w.bool(290, l.Plot)
+	w.int(370, int(l.Lineweight))
+	if h := w.plotStyles[l.PlotStyle]; h != "" {
+		w.handle(390, h)
+	} else {
+		w.handle(390, w.plotStyles["Normal"])
+	}
+}
+
+// Flags of text styles.
+const (
+	styleShapeFile = 1
+	styleVertical  = 4
+)
+
+func (w *writer) style(s *drawing.TextStyle, h string) {
+	w.entry("STYLE", "AcDbTextStyleTableRecord", h)
+	w.str(2, s.Name)
+	flags := 0
+	if s.ShapeFile {
+		flags |= styleShapeFile
+	}
+	if s.Vertical {
+		flags |= styleVertical
+	}
+	w.int(70, flags)
+	w.float(40, s.Height)
+	w.float(41, s.WidthFactor)
+	w.angle(50, s.Oblique)
+	gen := 0
+	if s.Backward {
+		gen |= drawing.TextBackward
+	}
+	if s.UpsideDown {
+		gen |= drawing.TextUpsideDown
+	}
+	w.int(71, gen)
+	last := s.Height
+	if last <= 0 {
+		last = w.d.Header.TextSize
+	}
+	w.float(42, last)
+	font := s.FontFile
+	if font == "" && s.FontFamily == "" {
+		font = "txt"
+	}
+	w.str(3, font)
+	w.str(4, s.BigFontFile)
+	if s.FontFamily != "" && w.v != R12 {
+		w.tag(1001, "ACAD")
+		w.str(1000, s.FontFamily)
+		w.int(1071, 0)
+	}
+}
+
+func (w *writer) view(v *drawing.View) {
+	w.entry("VIEW", "AcDbViewTableRecord", w.next())
+	w.str(2, v.Name)
+	w.bool(70, v.PaperSpace)
+	w.float(40, v.Height)
+	w.point2(10, v.Center)
+	w.float(41, v.Width)
+	w.point(11, v.Direction)
+	w.point(12, v.Target)
+	w.float(42, 50)
+	w.float(43, 0)
+	w.float(44, 0)
+	w.angle(50, v.Twist)
+	w.int(71, 0)
+}
+
+func (w *writer) dimStyle(s *drawing.DimStyle) {
+	w.entry("DIMSTYLE", "AcDbDimStyleTableRecord", w.dimStyles[key(s.Name)])
+	w.str(2, s.Name)
+	w.int(70, 0)
+	for _, v := range dimVars(s) {
+		if !w.hasDimVar(v.code) {
+			continue
+		}
+		switch {
+		case v.code >= 340:
+			if h := w.dimVarHandle(v.code, v.value); h != "" {
+				w.handle(v.code, h)
+			} else if w.v == R12 && v.code >= 342 && v.value != "" {
+				// R12 names the arrow blocks.
+				w.str(v.code-337, v.value)
+			}
+		default:
+			w.str(v.code, v.value)
+		}
+	}
+}
+
+// hasDimVar tells whether the version has the dimension variable with
+// group code code.
+func (w *writer) hasDimVar(code int) bool {
+	switch {
+	case code == 90:
+		return w.v == R2018
+	case code >= 340:
+		return w.v != R12 || code >= 342
+	case code == 79 || code > 178:
+		return w.v != R12
+	}
+	return true
+}
+
+// dimVarHandle returns the handle of the text style or block a dimension
+// variable names.
+func (w *writer) dimVarHandle(code int, name string) string {
+	if name == "" || w.v == R12 {
+		return ""
+	}
+	if code == 340 {
+		return w.styles[key(name)]
+	}
+	if b := w.d.Block(name); b != nil {
+		return w.records[b]
+	}
+	return ""
+}
+
+type dimVar struct {
+	code  int
+	value string
+}
+
+// dimVars lists the variables of a dimension style by group code, the
+// other way round from DimStyle.Set.
+func dimVars(s *drawing.DimStyle) []dimVar {
+	f := func(v float64) string { return number(v) }
+	i := strconv.Itoa
+	b := func(v bool) string {
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return []dimVar{
+		{3, s.Post},
+		{40, f(s.Scale)},
+		{41, f(s.ArrowSize)},
+		{42, f(s.ExtOffset)},
+		{44, f(s.ExtExtend)},
+		{45, f(s.Round)},
+		{46, f(s.LineExtend)},
+		{47, f(s.TolPlus)},
+		{48, f(s.TolMinus)},
+		{140, f(s.TextHeight)},
+		{141, f(s.CenterMark)},
+		{142, f(s.TickSize)},
+		{144, f(s.LinearFactor)},
+		{145, f(s.TextVertPos)},
+		{146, f(s.TolScale)},
+		{147, f(s.Gap)},
+		{71, b(s.Tol)},
+		{72, b(s.Limits)},
+		{73, b(s.InsideHoriz)},
+		{74, b(s.OutsideHoriz)},
+		{75, b(s.SuppressExt1)},
+		{76, b(s.SuppressExt2)},
+		{77, i(s.TextAbove)},
+		{78, i(s.ZeroSuppress)},
+		{79, i(s.AngZeroSupp)},
+		{90, i(s.ArcSymbol)},
+		{172, b(s.LineInside)},
+		{173, b(s.SeparateArrow)},
+		{174, b(s.TextInside)},
+		{175, b(s.SuppressOut)},
+		{176, i(int(s.LineColor.Index))},
+		{177, i(int(s.ExtColor.Index))},
+		{178, i(int(s.TextColor.Index))},
+		{179, i(s.AngDecimals)},
+		{271, i(s.Decimals)},
+		{272, i(s.TolDecimals)},
+		{275, i(s.AngUnits)},
+		{276, i(s.Fraction)},
+		{277, i(s.LinearUnits)},
+		{278, i(int(s.DecimalSep))},
+		{279, i(s.TextMove)},
+		{280, i(s.TextJust)},
+		{281, b(s.SuppressLine1)},
+		{282, b(s.SuppressLine2)},
+		{283, i(s.TolJust)},
+		{284, i(s.TolZeroSupp)},
+		{289, i(s.Fit)},
+		{340, s.TextStyle},
+		{341, s.LeaderArrow},
+		{342, s.Arrow},
+		{343, s.Arrow1},
+		{344, s.Arrow2},
+		{371, i(int(s.LineWeight))},
+		{372, i(int(s.ExtWeight))},
+	}
+}
+
+// blocks writes the block definitions. Those of model space and of the
+// paper space layout that is active hold no entities, which are in the
+// ENTITIES section instead; files of R12 have neither, nor other
+// layouts. External references that were bound are written as the blocks
+// they became.
+func (w *writer) blockSection() {
+	for _, b := range w.blocks {
+		if w.v == R12 && b.IsLayout() {
+			if b != w.model && b != w.paperSpace && len(b.Entities) > 0 {
+				w.lose("paper space layouts were left out, as R12 has one")
+			}
+			continue
+		}
+		w.owner, w.paper = w.records[b], b.IsLayout() && b != w.model
+		flags := 0
+		if b.Anonymous {
+			flags |= 1
+		}
+		if b.HasAttDefs {
+			flags |= 2
+		}
+		xref := b.Xref && len(b.Entities) == 0
+		if xref {
+			flags |= 4
+			if b.XrefOverlay {
+				flags |= 8
+			}
+		}
+
+		w.tag(0, "BLOCK")
+		w.handle(5, w.next())
+		w.handle(330, w.owner)
+		w.subclass("AcDbEntity")
+		if w.paper {
+			w.int(67, 1)
+		}
+		w.tag(8, "0")
+		w.subclass("AcDbBlockBegin")
+		w.str(2, b.Name)
+		w.int(70, flags)
+		w.point(10, b.Base)
+		w.str(3, b.Name)
+		if xref {
+			w.str(1, b.XrefPath)
+		}
+		if b.Description != "" && w.v != R12 {
+			w.str(4, b.Description)
+		}
+		if b != w.model && b != w.paperSpace {
+			w.entityList(b.Entities)
+		}
+		w.tag(0, "ENDBLK")
+		w.handle(5, w.next())
+		w.handle(330, w.owner)
+		w.subclass("AcDbEntity")
+		if w.paper {
+			w.int(67, 1)
+		}
+		w.tag(8, "0")
+		w.subclass("AcDbBlockEnd")
+	}
+}
+
+func (w *writer) entitySection() {
+	w.owner, w.paper = w.records[w.model], false
+	w.entityList(w.model.Entities)
+	w.owner, w.paper = w.records[w.paperSpace], true
+	w.entityList(w.paperSpace.Entities)
+}
+
+func (w *writer) objectSection() {
+	d := w.d
+	w.tag(0, "DICTIONARY")
+	w.tag(5, w.rootDict)
+	w.tag(330, "0")
+	w.tag(100, "AcDbDictionary")
+	w.int(281, 1)
+	entries := []struct{ name, h string }{
+		{"ACAD_GROUP", w.groupDict},
+		{"ACAD_IMAGE_DICT", w.imageDict},
+		{"ACAD_IMAGE_VARS", w.imageVars},
+		{"ACAD_LAYOUT", w.layoutDict},
+		{"ACAD_PLOTSTYLENAME", w.plotStyleDict},
+		{"DWGPROPS", w.summary},
+	}
+	for _, e := range entries {
+		if e.h != "" {
+			w.tag(3, e.name)
+			w.tag(350, e.h)
+		}
+	}
+
+	w.dictionary("DICTIONARY", w.groupDict, nil)
+	var layouts []dictEntry
+	for _, l := range w.layouts {
+		layouts = append(layouts, dictEntry{l.l.Name, l.handle})
+	}
+	w.dictionary("DICTIONARY", w.layoutDict, layouts)
+	var styles []dictEntry
+	for _, name := range w.plotStyleNames {
+		styles = append(styles, dictEntry{name, w.plotStyles[name]})
+	}
+	w.dictionary("ACDBDICTIONARYWDFLT", w.plotStyleDict, styles)
+	w.tag(100, "AcDbDictionaryWithDefault")
+	w.tag(340, w.plotStyles["Normal"])
+	for _, name := range w.plotStyleNames {
+		w.tag(0, "ACDBPLACEHOLDER")
+		w.tag(5, w.plotStyles[name])
+		w.tag(330, w.plotStyleDict)
+	}
+	for _, l := range w.layouts {
+		w.layout(l)
+	}
+
+	if w.imageDict != "" {
+		var images []dictEntry
+		names := make(map[string]bool)
+		for _, def := range d.ImageDefs {
+			base := strings.TrimSuffix(path.Base(strings.ReplaceAll(def.File, `\`, "/")), path.Ext(def.File))
+			name := base
+			for i := 2; base == "" || names[key(name)]; i++ {
+				name = base + strconv.Itoa(i)
+				base = strings.TrimSuffix(name, strconv.Itoa(i))
+			}
+			names[key(name)] = true
+			images = append(images, dictEntry{name, w.imageDefs[def.Handle]})
+		}
+		w.dictionary("DICTIONARY", w.imageDict, images)
+		for _, def := range d.ImageDefs {
+			w.imageDef(def)
+		}
+		for _, r := range w.reactors {
+			w.tag(0, "IMAGEDEF_REACTOR")
+			w.tag(5, r.handle)
+			w.tag(330, r.image)
+			w.tag(100, "AcDbRasterImageDefReactor")
+			w.int(90, 2)
+			w.tag(330, r.image)
+		}
+		w.tag(0, "RASTERVARIABLES")
+		w.tag(5, w.imageVars)
+		w.tag(330, w.rootDict)
+		w.tag(100, "AcDbRasterVariables")
+		w.int(90, 0)
+		w.int(70, 1)
+		w.int(71, 1)
+		w.int(72, 0)
+	}
+	if w.summary != "" {
+		w.summaryRecord()
+	}
+}
+
+type dictEntry struct {
+	name, h string
+}
+
+func (w *writer) dictionary(typ, h string, entries []dictEntry) {
+	w.tag(0, typ)
+	w.tag(5, h)
+	w.tag(330, w.rootDict)
+	w.tag(100, "AcDbDictionary")
+	w.int(281, 1)
+	for _, e := range entries {
+		w.str(3, e.name)
+		w.tag(350, e.h)
+	}
+}
+
+// layout writes a LAYOUT object: its page setup, then the layout itself.
+func (w *writer) layout(lo *layout) {
+	l, p := lo.l, lo.l.Plot
+	// A page setup without a scale plots to fit.
+	if p.PaperUnits <= 0 && p.DrawingUnits <= 0 && p.Flags&drawing.PlotUseStandardScale == 0 {
+		p.Flags |= drawing.PlotUseStandardScale
+		p.StandardScale = 0
+	}
+	w.tag(0, "LAYOUT")
+	w.tag(5, lo.handle)
+	w.tag(330, w.layoutDict)
+	w.tag(100, "AcDbPlotSettings")
+	w.tag(1, "")
+	w.tag(2, "none_device")
+	w.str(4, p.PaperName)
+	w.str(6, p.ViewName)
+	w.float(40, p.MarginLeft)
+	w.float(41, p.MarginBottom)
+	w.float(42, p.MarginRight)
+	w.float(43, p.MarginTop)
+	w.float(44, p.PaperWidth)
+	w.float(45, p.PaperHeight)
+	w.float(46, p.Origin.X)
+	w.float(47, p.Origin.Y)
+	w.float(48, p.WindowMin.X)
+	w.float(49, p.WindowMin.Y)
+	w.float(140, p.WindowMax.X)
+	w.float(141, p.WindowMax.Y)
+	w.float(142, p.PaperUnits)
+	w.float(143, p.DrawingUnits)
+	w.int(70, p.Flags)
+	if p.Inches {
+		w.int(72, 0)
+	} else {
+		w.int(72, 1)
+	}
+	w.int(73, p.Rotation)
+	w.int(74, int(p.Area))
+	w.str(7, p.StyleSheet)
+	w.int(75, p.StandardScale)
+	w.float(147, p.StandardFactor)
+	w.int(76, 0)
+	w.int(77, 2)
+	w.int(78, 300)
+	w.float(148, 0)
+	w.float(149, 0)
+
+	w.tag(100, "AcDbLayout")
+	w.str(1, l.Name)
+	w.bool(70, w.d.Header.PSLTScale)
+	w.int(71, l.TabOrder)
+	w.point2(10, l.LimMin)
+	w.point2(11, l.LimMax)
+	w.point(12, l.InsBase)
+	w.point(14, l.ExtMin)
+	w.point(15, l.ExtMax)
+	w.float(146, 0)
+	w.point(13, geom.Vec3{})
+	w.point(16, geom.Vec3{X: 1})
+	w.point(17, geom.Vec3{Y: 1})
+	w.int(76, 0)
+	w.tag(330, w.records[lo.block])
+}
+
+func (w *writer) imageDef(def *drawing.ImageDef) {
+	h := w.imageDefs[def.Handle]
+	w.tag(0, "IMAGEDEF")
+	w.tag(5, h)
+	w.tag(330, w.imageDict)
+	var reactors []string
+	for _, r := range w.reactors {
+		if r.def == def.Handle {
+			reactors = append(reactors, r.handle)
+		}
+	}
+	if len(reactors) > 0 {
+		w.tag(102, "{ACAD_REACTORS")
+		for _, r := range reactors {
+			w.tag(330, r)
+		}
+		w.tag(102, "}")
+	}
+	w.tag(100, "AcDbRasterImageDef")
+	w.int(90, 0)
+	w.str(1, def.File)
+	w.point2(10, def.Size)
+	w.point2(11, geom.Vec2{X: 1, Y: 1})
+	w.int(280, 1)
+	w.int(281, 0)
+}
+
+// summaryRecord writes the drawing properties as the record AutoCAD
+// keeps them in, which the reader takes apart in summary.
+func (w *writer) summaryRecord() {
+	s := &w.d.Summary
+	w.tag(0, "XRECORD")
+	w.tag(5, w.summary)
+	w.tag(330, w.rootDict)
+	w.tag(100, "AcDbXrecord")
+	w.int(280, 1)
+	w.tag(1, "DWGPROPS COOKIE")
+	w.str(2, s.Title)
+	w.str(3, s.Subject)
+	w.str(4, s.Author)
+	w.str(6, s.Comments)
+	w.str(7, s.Keywords)
+	w.str(8, s.LastSavedBy)
+	w.str(9, s.Revision)
+	for i, p := range s.Custom {
+		if i == 10 {
+			w.lose("custom drawing properties past the tenth were left out")
+			continue
+		}
+		w.str(300+i, p.Name+"="+p.Value)
+	}
+	w.float(40, 0)
+	w.float(41, w.dateOr(w.d.Header.Created))
+	w.float(42, w.dateOr(w.d.Header.Updated))
+	w.str(1, s.HyperlinkBase)
+}
+
+func (w *writer) dateOr(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return julianDate(t)
+}
+
+// Names of tables entries that stand for no entry.
+func (w *writer) linetypeName(name, def string) string {
+	switch key(name) {
+	case "BYLAYER", "BYBLOCK":
+		if w.v == R12 {
+			return key(name)
+		}
+		return name
+	}
+	if w.linetypes[key(name)] == "" {
+		return def
+	}
+	if w.v == R12 {
+		return key(name)
+	}
+	return name
+}
+
+func (w *writer) styleName(name string) string {
+	if w.styles[key(name)] == "" {
+		return "Standard"
+	}
+	return name
+}
+
+func (w *writer) dimStyleName(name string) string {
+	if w.dimStyles[key(name)] == "" {
+		return "Standard"
+	}
+	return name
+}
+
+// colorIndex returns the ACI color to write for c: true colors also keep
+// the nearest index, which is all files before R2004 have of them.
+func (w *writer) colorIndex(c drawing.Color) int {
+	if c.True && (c.Index <= 0 || c.Index >= drawing.ColorByLayer) {
+		return drawing.NearestACI(c.RGB)
+	}
+	return int(c.Index)
+}
@@ -0,0 +1,176 @@
+package font
+
+import "github.com/you-humble/dwgtopdf/converter/internal/geom"
+
+// OutlinePoint is a point of a glyph contour in em units. Points off the
+// curve are the control points of quadratic curves; between two of them
+// lies an implied point on the curve, halfway.
+type OutlinePoint struct {
+	geom.Vec2
+	On bool
+}
+
+// maxComponentDepth limits how deep composite glyphs may nest.
+const maxComponentDepth = 8
+
+// Outline returns the closed contours of glyph g, filled by the nonzero
+// winding rule. Blank glyphs and glyphs that cannot be read have none.
+func (f *Font) Outline(g uint16) [][]OutlinePoint {
+	return f.outline(g, 0)
+}
+
+func (f *Font) outline(g uint16, depth int) [][]OutlinePoint {
+	data := f.glyph(g)
+	if len(data) < 10 || depth > maxComponentDepth {
+		return nil
+	}
+	if n := int(int16(u16(data, 0))); n >= 0 {
+		return f.simple(data, n)
+	}
+	return f.composite(data, depth)
+}
+
+// Flags of the points of simple glyphs.
+const (
+	flagOnCurve = 1 << iota
+	flagShortX
+	flagShortY
+	flagRepeat
+	flagSameX
+	flagSameY
+)
+
+func (f *Font) simple(data []byte, contours int) [][]OutlinePoint {
+	off := 10
+	ends := make([]int, contours)
+	for i := range ends {
+		ends[i] = int(u16(data, off))
+		off += 2
+	}
+	if contours == 0 || off+2 > len(data) {
+		return nil
+	}
+	n := ends[contours-1] + 1
+	off += 2 + int(u16(data, off))
+
+	flags := make([]byte, 0, n)
+	for len(flags) < n && off < len(data) {
+		fl := data[off]
+		off++
+		flags = append(flags, fl)
+		if fl&flagRepeat != 0 && off < len(data) {
+			for k := int(data[off]); k > 0 && len(flags) < n; k-- {
+				flags = append(flags, fl)
+			}
+			off++
+		}
+	}
+	if len(flags) < n {
+		return nil
+	}
+	coords := func(short, same byte) []float64 {
+		out := make([]float64, n)
+		var v int
+		for i, fl := range flags {
+			switch {
+			case fl&short != 0:
+				if off >= len(data) {
+					return nil
+				}
+				d := int(data[off])
+				off++
+				if fl&same == 0 {
+					d = -d
+				}
+				v += d
+			case fl&same == 0:
+				v += int(int16(u16(data, off)))
+				off += 2
+			}
+			out[i] = float64(v)
+		}
+		return out
+	}
+	xs := coords(flagShortX, flagSameX)
+	ys := coords(flagShortY, flagSameY)
+	if xs == nil || ys == nil || off > len(data) {
+		return nil
+	}
+
+	em := float64(f.UnitsPerEm)
+	out := make([][]OutlinePoint, 0, contours)
+	start := 0
+	for _, end := range ends {
+		if end < start || end >= n {
+			return nil
+		}
+		c := make([]OutlinePoint, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			c = append(c, OutlinePoint{Vec2: geom.Vec2{X: xs[i] / em, Y: ys[i] / em}, On: flags[i]&flagOnCurve != 0})
+		}
+		out = append(out, c)
+		start = end + 1
+	}
+	return out
+}
+
+// Flags of the components of composite glyphs.
+const (
+	componentWords    = 0x0001
+	componentXY       = 0x0002
+	componentScale    = 0x0008
+	componentMore     = 0x0020
+	componentXYScale  = 0x0040
+	componentTwoByTwo = 0x0080
+)
+
+// f2dot14One is 1 in the 2.14 fixed point numbers of component scales.
+const f2dot14One = 1 << 14
+
+// composite places the outlines of the components of a glyph. Components
+// placed by matching points are left where they are.
+func (f *Font) composite(data []byte, depth int) [][]OutlinePoint {
+	var out [][]OutlinePoint
+	off := 10
+	em := float64(f.UnitsPerEm)
+	f2dot14 := func(o int) float64 { return float64(int16(u16(data, o))) / f2dot14One }
+	for off+6 <= len(data) {
+		flags := u16(data, off)
+		g := u16(data, off+2)
+		off += 4
+		var dx, dy float64
+		if flags&componentWords != 0 {
+			dx, dy = float64(int16(u16(data, off))), float64(int16(u16(data, off+2)))
+			off += 4
+		} else {
+			dx, dy = float64(int8(data[off])), float64(int8(data[off+1]))
+			off += 2
+		}
+		if flags&componentXY == 0 {
+			dx, dy = 0, 0
+		}
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		switch {
+		case flags&componentScale != 0:
+			a = f2dot14(off)
+			d = a
+			off += 2
+		case flags&componentXYScale != 0:
+			a, d = f2dot14(off), f2dot14(off+2)
+			off += 4
+		case flags&componentTwoByTwo != 0:
+			a, b, c, d = f2dot14(off), f2dot14(off+2), f2dot14(off+4), f2dot14(off+6)
+			off += 8
+		}
+		for _, contour := range f.outline(g, depth+1) {
+			for i, p := range contour {
+				contour[i].Vec2 = geom.Vec2{X: a*p.X + c*p.Y + dx/em, Y: b*p.X + d*p.Y + dy/em}
+			}
+			out = append(out, contour)
+		}
+		if flags&componentMore == 0 {
+			break
+		}
+	}
+	return out
+}
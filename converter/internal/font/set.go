@@ -0,0 +1,237 @@
+package font
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// aliases maps common Windows fonts to metric compatible free fonts that
+// servers are more likely to have.
+var aliases = map[string][]string{
+	"arial":           {"liberation sans", "arimo"},
+	"helvetica":       {"liberation sans", "arimo"},
+	"times new roman": {"liberation serif", "tinos"},
+	"courier new":     {"liberation mono", "cousine"},
+	"calibri":         {"carlito"},
+	"cambria":         {"caladea"},
+}
+
+// Set finds fonts by file or family name in a list of directories and
+// keeps them loaded. It is safe for concurrent use.
+type Set struct {
+	dirs     []string
+	fallback string
+	// glyphFallbacks name the fonts that fill in for missing characters.
+	glyphFallbacks []string
+
+	mu       sync.Mutex
+	files    map[string]string  // lower-case file name to path
+	families map[string]string  // lower-case family name to path
+	fonts    map[string]*Font   // path to font, nil when it failed to load
+	shapes   map[string]*Shapes // path to shape file, nil when it failed to load
+}
+
+// NewSet searches dirs, recursively, for fonts. Fallback is a file name
+// in one of the directories or a path; it is used for SHX fonts and fonts
+// that are not installed. GlyphFallbacks, named the same way, are tried
+// in order for characters a font does not have, such as CJK ones in a
+// Latin font.
+func NewSet(dirs []string, fallback string, glyphFallbacks []string) *Set {
+	return &Set{
+		dirs:           dirs,
+		fallback:       fallback,
+		glyphFallbacks: glyphFallbacks,
+		fonts:          make(map[string]*Font),
+		shapes:         make(map[string]*Shapes),
+	}
+}
+
+// Shapes returns the SHX shape file or font with the file name, or nil
+// when it is not installed.
+func (s *Set) Shapes(file string) *Shapes {
+	if s == nil || file == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(file, `\`, "/")))
+	if filepath.Ext(name) == "" {
+		name += ".shx"
+	}
+	path := s.index()[name]
+	if path == "" {
+		return nil
+	}
+	if sh, ok := s.shapes[path]; ok {
+		return sh
+	}
+	var sh *Shapes
+	if data, err := os.ReadFile(path); err == nil {
+		if sh, err = ParseShapes(data); err == nil {
+			sh.Name = name
+		}
+	}
+	s.shapes[path] = sh
+	return sh
+}
+
+// Lookup returns the font of a text style, given its font file and the
+// TrueType family name it may store instead. It returns the fallback,
+// which may be nil, when neither is available.
+func (s *Set) Lookup(file, family string) *Font {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if family != "" {
+		if f := s.byFamily(family); f != nil {
+			return f
+		}
+	}
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case "", ".shx":
+		// SHX fonts are drawn with the fallback.
+	default:
+		if f := s.load(s.index()[strings.ToLower(filepath.Base(file))]); f != nil {
+			return f
+		}
+		if f := s.byFamily(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))); f != nil {
+			return f
+		}
+	}
+	return s.fallbackFont()
+}
+
+// Fallback returns the fallback font, or nil when it cannot be loaded.
+func (s *Set) Fallback() *Font {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fallbackFont()
+}
+
+// Covering returns f when it has a glyph for r, else the first of the
+// fallback fonts that has one, else f.
+func (s *Set) Covering(f *Font, r rune) *Font {
+	if f != nil {
+		if _, ok := f.Glyph(r); ok {
+			return f
+		}
+	}
+	if s == nil || r < ' ' {
+		return f
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range append([]string{s.fallback}, s.glyphFallbacks...) {
+		if fb := s.named(name); fb != nil && fb != f {
+			if _, ok := fb.Glyph(r); ok {
+				return fb
+			}
+		}
+	}
+	return f
+}
+
+func (s *Set) fallbackFont() *Font {
+	return s.named(s.fallback)
+}
+
+// named loads a font given by file name or path.
+func (s *Set) named(name string) *Font {
+	if name == "" {
+		return nil
+	}
+	if strings.ContainsRune(name, filepath.Separator) {
+		return s.load(name)
+	}
+	return s.load(s.index()[strings.ToLower(name)])
+}
+
+func (s *Set) byFamily(name string) *Font {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if s.families == nil {
+		s.indexFamilies()
+	}
+	if f := s.load(s.families[name]); f != nil {
+		return f
+	}
+	for _, alias := range aliases[name] {
+		if f := s.load(s.families[alias]); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// index lists the font files in the directories on first use.
+func (s *Set) index() map[string]string {
+	if s.files != nil {
+		return s.files
+	}
+	s.files = make(map[string]string)
+	for _, dir := range s.dirs {
+		filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+			if err != nil || e.IsDir() {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".ttf", ".ttc", ".otf", ".shx":
+				name := strings.ToLower(e.Name())
+				if _, dup := s.files[name]; !dup {
+					s.files[name] = path
+				}
+			}
+			return nil
+		})
+	}
+	return s.files
+}
+
+// indexFamilies reads the family name of every font file. It only runs
+// when a style names a font by family or by a file that is not installed.
+func (s *Set) indexFamilies() {
+	s.families = make(map[string]string)
+	for _, path := range s.index() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		f := &Font{data: data, tables: make(map[string][]byte)}
+		if f.readDirectory() != nil {
+			continue
+		}
+		f.readNames()
+		family := strings.ToLower(f.Family)
+		// Prefer the regular face, which usually has the shortest name.
+		prev, ok := s.families[family]
+		if family != "" && (!ok || len(path) < len(prev) || len(path) == len(prev) && path < prev) {
+			s.families[family] = path
+		}
+	}
+}
+
+func (s *Set) load(path string) *Font {
+	if path == "" {
+		return nil
+	}
+	if f, ok := s.fonts[path]; ok {
+		return f
+	}
+	var f *Font
+	if data, err := os.ReadFile(path); err == nil {
+		if f, err = Parse(data); err != nil || !f.Embeddable {
+			f = nil
+		}
+	}
+	s.fonts[path] = f
+	return f
+}
@@ -0,0 +1,365 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+var ErrInvalidShapes = errors.New("invalid shx file")
+
+// Shapes is a compiled SHX shape file or font. Each shape is a program
+// for a pen that draws vectors and arcs; Strokes runs it.
+type Shapes struct {
+	Name string
+	// Above is the height of capitals in shape units and Below the depth
+	// of descenders; both are zero for shape files that are not fonts.
+	Above, Below float64
+
+	unicode bool
+	defs    map[int][]byte
+}
+
+// ParseShapes reads the shapes 1.0 and 1.1, unifont and bigfont formats.
+func ParseShapes(data []byte) (*Shapes, error) {
+	sig := bytes.Index(data[:min(len(data), 40)], []byte("\r\n\x1a"))
+	if sig < 0 || !bytes.HasPrefix(data, []byte("AutoCAD-86 ")) {
+		return nil, ErrInvalidShapes
+	}
+	kind := string(data[len("AutoCAD-86 "):sig])
+	body := data[sig+3:]
+	s := &Shapes{defs: make(map[int][]byte)}
+	var ok bool
+	switch {
+	case kind == "shapes 1.0" || kind == "shapes 1.1":
+		ok = s.readShapes(body)
+	case kind == "unifont 1.0":
+		s.unicode = true
+		ok = s.readUnifont(body)
+	case kind == "bigfont 1.0":
+		ok = s.readBigfont(body)
+	}
+	if !ok {
+		return nil, ErrInvalidShapes
+	}
+	if info, found := s.defs[0]; found {
+		if len(info) >= 2 {
+			s.Above, s.Below = float64(info[0]), float64(info[1])
+		}
+		delete(s.defs, 0)
+	}
+	return s, nil
+}
+
+// readShapes reads the index of shape numbers and sizes that precedes the
+// definitions, each a name and the program.
+func (s *Shapes) readShapes(b []byte) bool {
+	if len(b) < 6 {
+		return false
+	}
+	n := int(binary.LittleEndian.Uint16(b[4:]))
+	pos := 6 + 4*n
+	if pos > len(b) {
+		return false
+	}
+	for i := range n {
+		code := int(binary.LittleEndian.Uint16(b[6+4*i:]))
+		size := int(binary.LittleEndian.Uint16(b[8+4*i:]))
+		if pos+size > len(b) {
+			return false
+		}
+		s.define(code, b[pos:pos+size])
+		pos += size
+	}
+	return true
+}
+
+// readUnifont reads the font information and the shapes that follow it,
+// each with its number and size.
+func (s *Shapes) readUnifont(b []byte) bool {
+	if len(b) < 6 {
+		return false
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	size := int(binary.LittleEndian.Uint16(b[4:]))
+	pos := 6
+	if pos+size > len(b) {
+		return false
+	}
+	s.define(0, b[pos:pos+size])
+	pos += size
+	for i := 1; i < n && pos+4 <= len(b); i++ {
+		code := int(binary.LittleEndian.Uint16(b[pos:]))
+		size := int(binary.LittleEndian.Uint16(b[pos+2:]))
+		pos += 4
+		if pos+size > len(b) {
+			return false
+		}
+		s.define(code, b[pos:pos+size])
+		pos += size
+	}
+	return true
+}
+
+// readBigfont reads the index of a font for double-byte character sets:
+// the escape code ranges, then the number, size and offset of each shape.
+func (s *Shapes) readBigfont(b []byte) bool {
+	if len(b) < 6 {
+		return false
+	}
+	n := int(binary.LittleEndian.Uint16(b[2:]))
+	ranges := int(binary.LittleEndian.Uint16(b[4:]))
+	pos := 6 + 4*ranges
+	for range n {
+		if pos+8 > len(b) {
+			return false
+		}
+		code := int(binary.LittleEndian.Uint16(b[pos:]))
+		size := int(binary.LittleEndian.Uint16(b[pos+2:]))
+		off := int(binary.LittleEndian.Uint32(b[pos+4:])) - len("AutoCAD-86 bigfont 1.0\r\n\x1a")
+		pos += 8
+		if size == 0 || off < 0 || off+size > len(b) {
+			continue
+		}
+		s.define(code, b[off:off+size])
+	}
+	return true
+}
+
+// define stores the program of a shape, which follows its NUL terminated
+// name.
+func (s *Shapes) define(code int, def []byte) {
+	if i := bytes.IndexByte(def, 0); i >= 0 {
+		s.defs[code] = def[i+1:]
+	}
+}
+
+// Unicode reports whether the shapes of a font are numbered by Unicode
+// code point rather than by the code page of the drawing.
+func (s *Shapes) Unicode() bool { return s.unicode }
+
+// Has reports whether the file defines a shape.
+func (s *Shapes) Has(code int) bool {
+	_, ok := s.defs[code]
+	return ok
+}
+
+// Strokes runs the program of a shape from the origin and returns the
+// polylines the pen draws and where it ends, which is the advance of a
+// character, all in shape units.
+func (s *Shapes) Strokes(code int) ([][]geom.Vec2, geom.Vec2, bool) {
+	def, ok := s.defs[code]
+	if !ok {
+		return nil, geom.Vec2{}, false
+	}
+	p := &shapePen{scale: 1, down: true}
+	s.run(p, def, 0)
+	p.up()
+	return p.lines, p.pos, true
+}
+
+// maxSubshapes limits the nesting of shapes that draw other shapes.
+const maxSubshapes = 8
+
+// shapePen is the state of a running shape program.
+type shapePen struct {
+	pos   geom.Vec2
+	scale float64
+	down  bool
+	stack []geom.Vec2
+	lines [][]geom.Vec2
+	cur   []geom.Vec2
+}
+
+func (p *shapePen) moveTo(v geom.Vec2) {
+	if p.down {
+		if len(p.cur) == 0 {
+			p.cur = append(p.cur, p.pos)
+		}
+		p.cur = append(p.cur, v)
+	}
+	p.pos = v
+}
+
+func (p *shapePen) up() {
+	if len(p.cur) > 1 {
+		p.lines = append(p.lines, p.cur)
+	}
+	p.cur = nil
+}
+
+// arc draws the arc around c from angle a0 through sweep, in radians.
+func (p *shapePen) arc(c geom.Vec2, radius, a0, sweep float64) {
+	steps := max(2, int(math.Ceil(math.Abs(sweep)/(math.Pi/16))))
+	for i := 1; i <= steps; i++ {
+		p.moveTo(c.Add(geom.Polar(a0+sweep*float64(i)/float64(steps), radius)))
+	}
+}
+
+// bulge draws the arc to the point d away whose bulge is b/127, as
+// polyline bulges are the tangent of a quarter of the included angle.
+func (p *shapePen) bulge(d geom.Vec2, b int8) {
+	end := p.pos.Add(d)
+	if b == 0 || d.Len() == 0 {
+		p.moveTo(end)
+		return
+	}
+	sweep := 4 * math.Atan(float64(b)/127)
+	mid := p.pos.Lerp(end, 0.5)
+	c := mid.Add(d.Perp().Unit().Scale(d.Len() / 2 / math.Tan(sweep/2)))
+	p.arc(c, c.Dist(p.pos), p.pos.Sub(c).Angle(), sweep)
+	p.pos = end
+}
+
+// vectors are the 16 directions of the one-byte vector codes.
+var vectors = [16]geom.Vec2{
+	{X: 1}, {X: 1, Y: 0.5}, {X: 1, Y: 1}, {X: 0.5, Y: 1},
+	{Y: 1}, {X: -0.5, Y: 1}, {X: -1, Y: 1}, {X: -1, Y: 0.5},
+	{X: -1}, {X: -1, Y: -0.5}, {X: -1, Y: -1}, {X: -0.5, Y: -1},
+	{Y: -1}, {X: 0.5, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: -0.5},
+}
+
+func (s *Shapes) run(p *shapePen, def []byte, depth int) {
+	for i := 0; i < len(def); {
+		if def[i] == 0 {
+			return
+		}
+		i = s.step(p, def, i, depth, false)
+	}
+}
+
+// step runs the command at i and returns the index of the next one. When
+// skip is set the command is only read, as code 14 asks for commands
+// meant for vertical text.
+func (s *Shapes) step(p *shapePen, def []byte, i, depth int, skip bool) int {
+	arg := func(k int) byte {
+		if i+k < len(def) {
+			return def[i+k]
+		}
+		return 0
+	}
+	sarg := func(k int) float64 { return float64(int8(arg(k))) }
+	c := def[i]
+	if c > 0x0f {
+		if !skip {
+			p.moveTo(p.pos.Add(vectors[c&0x0f].Scale(float64(c>>4) * p.scale)))
+		}
+		return i + 1
+	}
+	switch c {
+	case 1:
+		if !skip {
+			p.down = true
+		}
+	case 2:
+		if !skip {
+			p.up()
+			p.down = false
+		}
+	case 3, 4:
+		if !skip && arg(1) != 0 {
+			if c == 3 {
+				p.scale /= float64(arg(1))
+			} else {
+				p.scale *= float64(arg(1))
+			}
+		}
+		return i + 2
+	case 5:
+		if !skip {
+			p.stack = append(p.stack, p.pos)
+		}
+	case 6:
+		if !skip && len(p.stack) > 0 {
+			p.up()
+			p.pos = p.stack[len(p.stack)-1]
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+	case 7:
+		code, n := int(arg(1)), 2
+		if s.unicode {
+			code, n = int(arg(1))<<8|int(arg(2)), 3
+		}
+		if sub, ok := s.defs[code]; ok && !skip && depth < maxSubshapes {
+			s.run(p, sub, depth+1)
+		}
+		return i + n
+	case 8:
+		if !skip {
+			p.moveTo(p.pos.Add(geom.Vec2{X: sarg(1), Y: sarg(2)}.Scale(p.scale)))
+		}
+		return i + 3
+	case 9, 13:
+		for i++; i+1 < len(def); {
+			d := geom.Vec2{X: float64(int8(def[i])), Y: float64(int8(def[i+1]))}
+			if d.X == 0 && d.Y == 0 {
+				return i + 2
+			}
+			if c == 9 {
+				if !skip {
+					p.moveTo(p.pos.Add(d.Scale(p.scale)))
+				}
+				i += 2
+				continue
+			}
+			if !skip && i+2 < len(def) {
+				p.bulge(d.Scale(p.scale), int8(def[i+2]))
+			}
+			i += 3
+		}
+		return len(def)
+	case 10:
+		if !skip {
+			radius := float64(arg(1)) * p.scale
+			a0, sweep := octants(int8(arg(2)), 0, 0)
+			center := p.pos.Sub(geom.Polar(a0, radius))
+			p.arc(center, radius, a0, sweep)
+		}
+		return i + 3
+	case 11:
+		if !skip {
+			radius := float64(int(arg(3))<<8|int(arg(4))) * p.scale
+			a0, sweep := octants(int8(arg(5)), float64(arg(1)), float64(arg(2)))
+			center := p.pos.Sub(geom.Polar(a0, radius))
+			p.arc(center, radius, a0, sweep)
+		}
+		return i + 6
+	case 12:
+		if !skip {
+			p.bulge(geom.Vec2{X: sarg(1), Y: sarg(2)}.Scale(p.scale), int8(arg(3)))
+		}
+		return i + 4
+	case 14:
+		return s.step(p, def, i+1, depth, true)
+	}
+	return i + 1
+}
+
+// octants decodes the start octant, span and direction of an arc code,
+// with the fractional start and end offsets in 256ths of an octant, into
+// a start angle and a signed sweep in radians. A span of zero is a full
+// circle.
+func octants(o int8, startOff, endOff float64) (float64, float64) {
+	const octant = math.Pi / 4
+	dir := 1.0
+	if o < 0 {
+		dir = -1
+	}
+	v := int(o)
+	if v < 0 {
+		v = -v
+	}
+	start, span := float64(v>>4&7), float64(v&7)
+	if span == 0 {
+		span = 8
+	}
+	a0 := start*octant + dir*startOff/256*octant
+	sweep := span * octant
+	if endOff != 0 {
+		sweep = (span-1)*octant + endOff/256*octant - startOff/256*octant
+	}
+	return a0, dir * sweep
+}
@@ -0,0 +1,162 @@
+package font
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// subsetTables are the tables kept in a subset: those a PDF reader needs
+// to draw glyphs by index, with an empty cmap and a post table without
+// glyph names so that the file is still a valid TrueType font.
+var subsetTables = []string{"OS/2", "cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "post", "prep"}
+
+// emptyCmap is a Windows Unicode cmap whose only segment is the one that
+// format 4 requires to end the table.
+var emptyCmap = []byte{
+	0, 0, 0, 1, // version, one subtable
+	0, 3, 0, 1, 0, 0, 0, 12, // platform 3, encoding 1, offset
+	0, 4, 0, 24, 0, 0, // format 4, length, language
+	0, 2, 0, 2, 0, 0, 0, 0, // segCountX2, searchRange, entrySelector, rangeShift
+	0xff, 0xff, 0, 0, 0xff, 0xff, 0, 1, 0, 0, // end, pad, start, delta, range offset
+}
+
+// Subset returns a font file with only the outlines of the given glyphs,
+// and the glyphs their composites refer to. Glyph indices are kept so the
+// subset can be addressed with the same ids as the full font.
+func (f *Font) Subset(glyphs []uint16) []byte {
+	keep := make(map[uint16]bool, len(glyphs)+1)
+	var visit func(g uint16)
+	visit = func(g uint16) {
+		if keep[g] || int(g) >= f.NumGlyphs() {
+			return
+		}
+		keep[g] = true
+		for _, c := range components(f.glyph(g)) {
+			visit(c)
+		}
+	}
+	visit(0)
+	for _, g := range glyphs {
+		visit(g)
+	}
+
+	var glyf []byte
+	loca := make([]byte, 4*(f.NumGlyphs()+1))
+	for g := range f.NumGlyphs() {
+		binary.BigEndian.PutUint32(loca[4*g:], uint32(len(glyf)))
+		if keep[uint16(g)] {
+			glyf = append(glyf, f.glyph(uint16(g))...)
+			// Glyph data stays 4-byte aligned.
+			for len(glyf)%4 != 0 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*f.NumGlyphs():], uint32(len(glyf)))
+
+	head := slices.Clone(f.tables["head"])
+	binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment
+	binary.BigEndian.PutUint16(head[50:], 1) // long loca offsets
+
+	tables := map[string][]byte{"glyf": glyf, "loca": loca, "head": head, "cmap": emptyCmap}
+	if post := f.tables["post"]; len(post) >= 32 {
+		post = slices.Clone(post[:32])
+		binary.BigEndian.PutUint32(post, 0x00030000)
+		tables["post"] = post
+	}
+	var tags []string
+	for _, tag := range subsetTables {
+		if tables[tag] == nil {
+			tables[tag] = f.tables[tag]
+		}
+		if tables[tag] != nil {
+			tags = append(tags, tag)
+		}
+	}
+	out := assemble(tags, tables)
+
+	// The head checksum adjustment makes the whole file sum to a magic
+	// number.
+	adj := 0xb1b0afba - checksum(out)
+	headOff := int(binary.BigEndian.Uint32(out[12+16*slices.Index(tags, "head")+8:]))
+	binary.BigEndian.PutUint32(out[headOff+8:], adj)
+	return out
+}
+
+func assemble(tags []string, tables map[string][]byte) []byte {
+	n := len(tags)
+	entry := 1
+	for entry*2 <= n {
+		entry *= 2
+	}
+	shift := 0
+	for 1<<shift < entry {
+		shift++
+	}
+	out := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(out, 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	binary.BigEndian.PutUint16(out[6:], uint16(entry*16))
+	binary.BigEndian.PutUint16(out[8:], uint16(shift))
+	binary.BigEndian.PutUint16(out[10:], uint16(n*16-entry*16))
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := out[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], checksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+func checksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		var w [4]byte
+		copy(w[:], b[i:])
+		sum += binary.BigEndian.Uint32(w[:])
+	}
+	return sum
+}
+
+// components lists the glyphs a composite glyph is built from.
+func components(data []byte) []uint16 {
+	if len(data) < 10 || int16(u16(data, 0)) >= 0 {
+		return nil
+	}
+	const (
+		argWords = 0x0001
+		scale    = 0x0008
+		more     = 0x0020
+		scaleXY  = 0x0040
+		twoByTwo = 0x0080
+	)
+	var out []uint16
+	for off := 10; off+4 <= len(data); {
+		flags := u16(data, off)
+		out = append(out, u16(data, off+2))
+		off += 4
+		if flags&argWords != 0 {
+			off += 4
+		} else {
+			off += 2
+		}
+		switch {
+		case flags&scale != 0:
+			off += 2
+		case flags&scaleXY != 0:
+			off += 4
+		case flags&twoByTwo != 0:
+			off += 8
+		}
+		if flags&more == 0 {
+			break
+		}
+	}
+	return out
+}
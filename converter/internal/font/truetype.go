@@ -0,0 +1,389 @@
+// Package font reads TrueType fonts for text layout and embedding: glyph
+// mapping, metrics and subsets.
+package font
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var (
+	ErrInvalid     = errors.New("invalid truetype font")
+	ErrUnsupported = errors.New("unsupported font")
+)
+
+// Font is a parsed TrueType font. It is read-only after Parse and may be
+// shared between goroutines.
+type Font struct {
+	// Family and PostScriptName come from the name table.
+	Family         string
+	PostScriptName string
+
+	UnitsPerEm int
+	// Ascent, Descent and CapHeight are in em units; Descent is negative.
+	// CapHeight is always set since CAD text heights measure capitals.
+	Ascent, Descent, CapHeight float64
+	// BBox is the font bounding box in font units.
+	BBox        [4]int
+	ItalicAngle float64
+	FixedPitch  bool
+	Bold        bool
+	// Embeddable is false when the license forbids embedding.
+	Embeddable bool
+
+	data     []byte
+	tables   map[string][]byte
+	advances []uint16
+	cmap     map[rune]uint16
+	symbolic bool
+	loca     []uint32
+}
+
+// Parse reads a TrueType font or the first font of a collection. Fonts
+// with CFF outlines are not supported.
+func Parse(data []byte) (*Font, error) {
+	f := &Font{data: data, tables: make(map[string][]byte)}
+	if err := f.readDirectory(); err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap", "loca", "glyf"} {
+		if f.tables[tag] == nil {
+			if tag == "glyf" || tag == "loca" {
+				return nil, fmt.Errorf("%w: no glyf outlines", ErrUnsupported)
+			}
+			return nil, fmt.Errorf("%w: missing %s table", ErrInvalid, tag)
+		}
+	}
+	if err := f.readMetrics(); err != nil {
+		return nil, err
+	}
+	if err := f.readCmap(); err != nil {
+		return nil, err
+	}
+	f.readNames()
+	if f.CapHeight <= 0 {
+		// Older fonts do not record it; measure the capital H instead.
+		f.CapHeight = 0.7
+		if g, ok := f.Glyph('H'); ok {
+			if _, _, _, top := f.GlyphBounds(g); top > 0 {
+				f.CapHeight = top
+			}
+		}
+	}
+	return f, nil
+}
+
+func (f *Font) readDirectory() error {
+	d := f.data
+	if len(d) < 12 {
+		return ErrInvalid
+	}
+	off := 0
+	if string(d[:4]) == "ttcf" {
+		if len(d) < 16 {
+			return ErrInvalid
+		}
+		off = int(u32(d, 12))
+	}
+	if off+12 > len(d) {
+		return ErrInvalid
+	}
+	switch v := u32(d, off); v {
+	case 0x00010000, 0x74727565: // 1.0 or 'true'
+	case 0x4f54544f: // 'OTTO'
+		return fmt.Errorf("%w: CFF outlines", ErrUnsupported)
+	default:
+		return fmt.Errorf("%w: version %#x", ErrInvalid, v)
+	}
+	n := int(u16(d, off+4))
+	if off+12+16*n > len(d) {
+		return ErrInvalid
+	}
+	for i := range n {
+		rec := off + 12 + 16*i
+		start, length := int(u32(d, rec+8)), int(u32(d, rec+12))
+		if start < 0 || length < 0 || start+length > len(d) {
+			return fmt.Errorf("%w: table %q out of range", ErrInvalid, d[rec:rec+4])
+		}
+		f.tables[string(d[rec:rec+4])] = d[start : start+length]
+	}
+	return nil
+}
+
+func (f *Font) readMetrics() error {
+	head, hhea, maxp := f.tables["head"], f.tables["hhea"], f.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return ErrInvalid
+	}
+	f.UnitsPerEm = int(u16(head, 18))
+	if f.UnitsPerEm == 0 {
+		return fmt.Errorf("%w: zero units per em", ErrInvalid)
+	}
+	for i := range f.BBox {
+		f.BBox[i] = int(int16(u16(head, 36+2*i)))
+	}
+	em := float64(f.UnitsPerEm)
+	f.Ascent = float64(int16(u16(hhea, 4))) / em
+	f.Descent = float64(int16(u16(hhea, 6))) / em
+
+	glyphs := int(u16(maxp, 4))
+	metrics := int(u16(hhea, 34))
+	hmtx := f.tables["hmtx"]
+	if metrics == 0 || metrics > glyphs || len(hmtx) < 4*metrics {
+		return fmt.Errorf("%w: hmtx", ErrInvalid)
+	}
+	f.advances = make([]uint16, glyphs)
+	for i := range f.advances {
+		f.advances[i] = u16(hmtx, 4*min(i, metrics-1))
+	}
+
+	long := int16(u16(head, 50)) == 1
+	loca := f.tables["loca"]
+	f.loca = make([]uint32, glyphs+1)
+	for i := range f.loca {
+		switch {
+		case long && 4*i+4 <= len(loca):
+			f.loca[i] = u32(loca, 4*i)
+		case !long && 2*i+2 <= len(loca):
+			f.loca[i] = 2 * uint32(u16(loca, 2*i))
+		case i > 0:
+			f.loca[i] = f.loca[i-1]
+		}
+	}
+
+	f.Embeddable = true
+	if os2 := f.tables["OS/2"]; len(os2) >= 78 {
+		fsType := u16(os2, 8)
+		// Restricted license without the preview & print or editable bits.
+		f.Embeddable = fsType&0x000f != 0x0002
+		f.Bold = u16(os2, 4) >= 600
+		if typo := float64(int16(u16(os2, 68))); typo > 0 {
+			f.Ascent = typo / em
+			f.Descent = float64(int16(u16(os2, 70))) / em
+		}
+		if u16(os2, 0) >= 2 && len(os2) >= 90 {
+			f.CapHeight = float64(int16(u16(os2, 88))) / em
+		}
+	}
+	if post := f.tables["post"]; len(post) >= 16 {
+		f.ItalicAngle = float64(int32(u32(post, 4))) / 65536
+		f.FixedPitch = u32(post, 12) != 0
+	}
+	return nil
+}
+
+func (f *Font) readCmap() error {
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return ErrInvalid
+	}
+	// Prefer the full Unicode subtable, then the BMP one, then symbol.
+	var best []byte
+	rank := 0
+	n := int(u16(cmap, 2))
+	for i := range n {
+		rec := 4 + 8*i
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform, encoding := u16(cmap, rec), u16(cmap, rec+2)
+		off := int(u32(cmap, rec+4))
+		if off+4 > len(cmap) {
+			continue
+		}
+		sub := cmap[off:]
+		r := 0
+		switch format := u16(sub, 0); {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			r = 4
+		case format == 4 && (platform == 3 && encoding == 1 || platform == 0):
+			r = 3
+		case format == 4 && platform == 3 && encoding == 0:
+			r = 2
+		}
+		if r > rank {
+			best, rank = sub, r
+			f.symbolic = platform == 3 && encoding == 0
+		}
+	}
+	if best == nil {
+		return fmt.Errorf("%w: no unicode cmap", ErrUnsupported)
+	}
+	f.cmap = make(map[rune]uint16)
+	if u16(best, 0) == 12 {
+		return f.readFormat12(best)
+	}
+	return f.readFormat4(best)
+}
+
+func (f *Font) readFormat4(sub []byte) error {
+	if len(sub) < 14 {
+		return ErrInvalid
+	}
+	segs := int(u16(sub, 6)) / 2
+	ends, starts := 14, 16+2*segs
+	deltas, ranges := starts+2*segs, starts+4*segs
+	if ranges+2*segs > len(sub) {
+		return fmt.Errorf("%w: cmap format 4", ErrInvalid)
+	}
+	for i := range segs {
+		end, start := u16(sub, ends+2*i), u16(sub, starts+2*i)
+		delta, ro := u16(sub, deltas+2*i), int(u16(sub, ranges+2*i))
+		for c := uint32(start); c <= uint32(end) && c != 0xffff; c++ {
+			var g uint16
+			if ro == 0 {
+				g = uint16(c) + delta
+			} else {
+				at := ranges + 2*i + ro + 2*int(c-uint32(start))
+				if at+2 > len(sub) {
+					continue
+				}
+				if g = u16(sub, at); g != 0 {
+					g += delta
+				}
+			}
+			if g != 0 && int(g) < len(f.advances) {
+				f.cmap[rune(c)] = g
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Font) readFormat12(sub []byte) error {
+	if len(sub) < 16 {
+		return ErrInvalid
+	}
+	n := int(u32(sub, 12))
+	if 16+12*n > len(sub) {
+		return fmt.Errorf("%w: cmap format 12", ErrInvalid)
+	}
+	for i := range n {
+		rec := 16 + 12*i
+		start, end, g := u32(sub, rec), u32(sub, rec+4), u32(sub, rec+8)
+		if end > 0x10ffff || end < start {
+			continue
+		}
+		for c := start; c <= end; c++ {
+			if gid := g + c - start; gid < uint32(len(f.advances)) {
+				f.cmap[rune(c)] = uint16(gid)
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Font) readNames() {
+	name := f.tables["name"]
+	if len(name) < 6 {
+		return
+	}
+	n, storage := int(u16(name, 2)), int(u16(name, 4))
+	// Windows Unicode names win over Macintosh Roman ones; the typographic
+	// family (16) over the legacy one (1).
+	found := map[uint16]int{}
+	for i := range n {
+		rec := 6 + 12*i
+		if rec+12 > len(name) {
+			break
+		}
+		platform, id := u16(name, rec), u16(name, rec+6)
+		length, off := int(u16(name, rec+8)), storage+int(u16(name, rec+10))
+		if id != 1 && id != 6 && id != 16 || off+length > len(name) {
+			continue
+		}
+		raw := name[off : off+length]
+		var s string
+		var rank int
+		switch platform {
+		case 3, 0:
+			u := make([]uint16, len(raw)/2)
+			for j := range u {
+				u[j] = u16(raw, 2*j)
+			}
+			s, rank = string(utf16.Decode(u)), 2
+		case 1:
+			s, rank = string(raw), 1
+		default:
+			continue
+		}
+		if rank <= found[id] || s == "" {
+			continue
+		}
+		found[id] = rank
+		switch id {
+		case 1:
+			if found[16] == 0 {
+				f.Family = s
+			}
+		case 16:
+			f.Family = s
+		case 6:
+			f.PostScriptName = s
+		}
+	}
+}
+
+// Glyph returns the glyph index of r, or 0 (.notdef) when the font has
+// no glyph for it.
+func (f *Font) Glyph(r rune) (uint16, bool) {
+	if g, ok := f.cmap[r]; ok {
+		return g, true
+	}
+	if f.symbolic && r < 0x100 {
+		g, ok := f.cmap[0xf000|r]
+		return g, ok
+	}
+	return 0, false
+}
+
+// NumGlyphs returns the number of glyphs in the font.
+func (f *Font) NumGlyphs() int { return len(f.advances) }
+
+// Advance returns the advance width of glyph g in em units.
+func (f *Font) Advance(g uint16) float64 {
+	if int(g) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[g]) / float64(f.UnitsPerEm)
+}
+
+// glyph returns the outline data of glyph g, empty for blank glyphs.
+func (f *Font) glyph(g uint16) []byte {
+	if int(g)+1 >= len(f.loca) {
+		return nil
+	}
+	glyf := f.tables["glyf"]
+	start, end := f.loca[g], f.loca[g+1]
+	if end <= start || int(end) > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// GlyphBounds returns the bounding box of glyph g in em units.
+func (f *Font) GlyphBounds(g uint16) (xMin, yMin, xMax, yMax float64) {
+	data := f.glyph(g)
+	if len(data) < 10 {
+		return 0, 0, 0, 0
+	}
+	em := float64(f.UnitsPerEm)
+	return float64(int16(u16(data, 2))) / em, float64(int16(u16(data, 4))) / em,
+		float64(int16(u16(data, 6))) / em, float64(int16(u16(data, 8))) / em
+}
+
+func u16(b []byte, off int) uint16 {
+	if off+2 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b[off:])
+}
+
+func u32(b []byte, off int) uint32 {
+	if off+4 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b[off:])
+}
@@ -0,0 +1,265 @@
+package geom
+
+import "math"
+
+const eps = 1e-12
+
+type Vec2 struct {
+	X, Y float64
+}
+
+func (v Vec2) Add(o Vec2) Vec2      { return Vec2{v.X + o.X, v.Y + o.Y} }
+func (v Vec2) Sub(o Vec2) Vec2      { return Vec2{v.X - o.X, v.Y - o.Y} }
+func (v Vec2) Scale(s float64) Vec2 { return Vec2{v.X * s, v.Y * s} }
+func (v Vec2) Dot(o Vec2) float64   { return v.X*o.X + v.Y*o.Y }
+func (v Vec2) Cross(o Vec2) float64 { return v.X*o.Y - v.Y*o.X }
+func (v Vec2) Len() float64         { return math.Hypot(v.X, v.Y) }
+func (v Vec2) Angle() float64       { return math.Atan2(v.Y, v.X) }
+func (v Vec2) Perp() Vec2           { return Vec2{-v.Y, v.X} }
+func (v Vec2) Vec3(z float64) Vec3  { return Vec3{v.X, v.Y, z} }
+func (v Vec2) Dist(o Vec2) float64  { return v.Sub(o).Len() }
+func (v Vec2) Lerp(o Vec2, t float64) Vec2 {
+	return Vec2{v.X + (o.X-v.X)*t, v.Y + (o.Y-v.Y)*t}
+}
+
+func (v Vec2) Unit() Vec2 {
+	l := v.Len()
+	if l < eps {
+		return Vec2{}
+	}
+	return Vec2{v.X / l, v.Y / l}
+}
+
+func (v Vec2) Rotate(a float64) Vec2 {
+	s, c := math.Sincos(a)
+	return Vec2{v.X*c - v.Y*s, v.X*s + v.Y*c}
+}
+
+func Polar(a, r float64) Vec2 {
+	s, c := math.Sincos(a)
+	return Vec2{c * r, s * r}
+}
+
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+var ZAxis = Vec3{0, 0, 1}
+
+func (v Vec3) Add(o Vec3) Vec3      { return Vec3{v.X + o.X, v.Y + o.Y, v.Z + o.Z} }
+func (v Vec3) Sub(o Vec3) Vec3      { return Vec3{v.X - o.X, v.Y - o.Y, v.Z - o.Z} }
+func (v Vec3) Scale(s float64) Vec3 { return Vec3{v.X * s, v.Y * s, v.Z * s} }
+func (v Vec3) Dot(o Vec3) float64   { return v.X*o.X + v.Y*o.Y + v.Z*o.Z }
+func (v Vec3) Len() float64         { return math.Sqrt(v.Dot(v)) }
+func (v Vec3) XY() Vec2             { return Vec2{v.X, v.Y} }
+func (v Vec3) IsZero() bool         { return math.Abs(v.X) < eps && math.Abs(v.Y) < eps && math.Abs(v.Z) < eps }
+
+func (v Vec3) Cross(o Vec3) Vec3 {
+	return Vec3{v.Y*o.Z - v.Z*o.Y, v.Z*o.X - v.X*o.Z, v.X*o.Y - v.Y*o.X}
+}
+
+func (v Vec3) Unit() Vec3 {
+	l := v.Len()
+	if l < eps {
+		return Vec3{}
+	}
+	return v.Scale(1 / l)
+}
+
+// Matrix is an affine transform of 3D space stored row-major:
+// x' = M[0]*x + M[1]*y + M[2]*z + M[3], and so on for y' and z'.
+type Matrix [12]float64
+
+func Identity() Matrix {
+	return Matrix{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0}
+}
+
+func Translate(v Vec3) Matrix {
+	return Matrix{1, 0, 0, v.X, 0, 1, 0, v.Y, 0, 0, 1, v.Z}
+}
+
+func Scale(s Vec3) Matrix {
+	return Matrix{s.X, 0, 0, 0, 0, s.Y, 0, 0, 0, 0, s.Z, 0}
+}
+
+func RotateZ(a float64) Matrix {
+	s, c := math.Sincos(a)
+	return Matrix{c, -s, 0, 0, s, c, 0, 0, 0, 0, 1, 0}
+}
+
+// Axes builds the matrix that maps local coordinates along the given
+// axes into the parent space.
+func Axes(x, y, z Vec3) Matrix {
+	return Matrix{x.X, y.X, z.X, 0, x.Y, y.Y, z.Y, 0, x.Z, y.Z, z.Z, 0}
+}
+
+// OCS returns the transform from an object coordinate system with the
+// given extrusion direction to WCS, using the DXF arbitrary axis algorithm.
+func OCS(extrusion Vec3) Matrix {
+	n := extrusion.Unit()
+	if n.IsZero() || (math.Abs(n.X) < eps && math.Abs(n.Y) < eps && n.Z > 0) {
+		return Identity()
+	}
+	var ax Vec3
+	if math.Abs(n.X) < 1.0/64 && math.Abs(n.Y) < 1.0/64 {
+		ax = Vec3{0, 1, 0}.Cross(n).Unit()
+	} else {
+		ax = ZAxis.Cross(n).Unit()
+	}
+	ay := n.Cross(ax).Unit()
+	return Axes(ax, ay, n)
+}
+
+// Mul returns m*o, i.e. o is applied first.
+func (m Matrix) Mul(o Matrix) Matrix {
+	var r Matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 4; j++ {
+			v := m[i*4+0]*o[0+j] + m[i*4+1]*o[4+j] + m[i*4+2]*o[8+j]
+			if j == 3 {
+				v += m[i*4+3]
+			}
+			r[i*4+j] = v
+		}
+	}
+	return r
+}
+
+func (m Matrix) Apply(p Vec3) Vec3 {
+	return Vec3{
+		m[0]*p.X + m[1]*p.Y + m[2]*p.Z + m[3],
+		m[4]*p.X + m[5]*p.Y + m[6]*p.Z + m[7],
+		m[8]*p.X + m[9]*p.Y + m[10]*p.Z + m[11],
+	}
+}
+
+func (m Matrix) Apply2(p Vec2) Vec2 {
+	return m.Apply(Vec3{p.X, p.Y, 0}).XY()
+}
+
+// ApplyVec transforms a direction, ignoring translation.
+func (m Matrix) ApplyVec(v Vec3) Vec3 {
+	return Vec3{
+		m[0]*v.X + m[1]*v.Y + m[2]*v.Z,
+		m[4]*v.X + m[5]*v.Y + m[6]*v.Z,
+		m[8]*v.X + m[9]*v.Y + m[10]*v.Z,
+	}
+}
+
+// Det2 is the determinant of the XY part; a negative value means the
+// transform mirrors the plane.
+func (m Matrix) Det2() float64 {
+	return m[0]*m[5] - m[1]*m[4]
+}
+
+// ScaleXY is the mean linear scale factor of the XY part.
+func (m Matrix) ScaleXY() float64 {
+	return math.Sqrt(math.Abs(m.Det2()))
+}
+
+func (m Matrix) Inverse() (Matrix, bool) {
+	a, b, c := m[0], m[1], m[2]
+	d, e, f := m[4], m[5], m[6]
+	g, h, i := m[8], m[9], m[10]
+	det := a*(e*i-f*h) - b*(d*i-f*g) + c*(d*h-e*g)
+	if math.Abs(det) < eps {
+		return Identity(), false
+	}
+	inv := Matrix{
+		(e*i - f*h) / det, (c*h - b*i) / det, (b*f - c*e) / det, 0,
+		(f*g - d*i) / det, (a*i - c*g) / det, (c*d - a*f) / det, 0,
+		(d*h - e*g) / det, (b*g - a*h) / det, (a*e - b*d) / det, 0,
+	}
+	t := inv.ApplyVec(Vec3{m[3], m[7], m[11]})
+	inv[3], inv[7], inv[11] = -t.X, -t.Y, -t.Z
+	return inv, true
+}
+
+type Box struct {
+	Min, Max Vec2
+	Valid    bool
+}
+
+func (b *Box) Add(p Vec2) {
+	if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+		return
+	}
+	if !b.Valid {
+		b.Min, b.Max, b.Valid = p, p, true
+		return
+	}
+	b.Min.X = math.Min(b.Min.X, p.X)
+	b.Min.Y = math.Min(b.Min.Y, p.Y)
+	b.Max.X = math.Max(b.Max.X, p.X)
+	b.Max.Y = math.Max(b.Max.Y, p.Y)
+}
+
+func (b *Box) Union(o Box) {
+	if o.Valid {
+		b.Add(o.Min)
+		b.Add(o.Max)
+	}
+}
+
+func (b Box) Width() float64  { return b.Max.X - b.Min.X }
+func (b Box) Height() float64 { return b.Max.Y - b.Min.Y }
+func (b Box) Center() Vec2    { return b.Min.Lerp(b.Max, 0.5) }
+
+func (b Box) Contains(p Vec2) bool {
+	return b.Valid && p.X >= b.Min.X && p.X <= b.Max.X && p.Y >= b.Min.Y && p.Y <= b.Max.Y
+}
+
+func (b Box) Intersects(o Box) bool {
+	return b.Valid && o.Valid &&
+		b.Min.X <= o.Max.X && o.Min.X <= b.Max.X &&
+		b.Min.Y <= o.Max.Y && o.Min.Y <= b.Max.Y
+}
+
+// NormalizeAngle maps a to [0, 2π).
+func NormalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+func Deg(rad float64) float64 { return rad * 180 / math.Pi }
+func Rad(deg float64) float64 { return deg * math.Pi / 180 }
+
+// NURBS evaluates the curve at u with de Boor's algorithm in homogeneous
+// coordinates. There must be len(ctrl)+deg+1 knots, and weights are
+// either nil or one per control point.
+func NURBS(deg int, knots []float64, ctrl []Vec3, weights []float64, u float64) Vec3 {
+	k := deg
+	for k < len(ctrl)-1 && u >= knots[k+1] {
+		k++
+	}
+	d := make([][4]float64, deg+1)
+	for j := range d {
+		i := k - deg + j
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		c := ctrl[i]
+		d[j] = [4]float64{c.X * w, c.Y * w, c.Z * w, w}
+	}
+	for r := 1; r <= deg; r++ {
+		for j := deg; j >= r; j-- {
+			i := k - deg + j
+			a := 0.0
+			if den := knots[i+deg-r+1] - knots[i]; den != 0 {
+				a = (u - knots[i]) / den
+			}
+			for c := range d[j] {
+				d[j][c] = (1-a)*d[j-1][c] + a*d[j][c]
+			}
+		}
+	}
+	p := d[deg]
+	if p[3] == 0 {
+		p[3] = 1
+	}
+	return Vec3{X: p[0] / p[3], Y: p[1] / p[3], Z: p[2] / p[3]}
+}
@@ -16,7 +16,10 @@ type Config struct {
 	QueueCapacity int `yaml:"queue_capacity"`
 	PoolSize      int `yaml:"pool_size"`
 
-	MinIO MinIO `yaml:"minio"`
+	MinIO      MinIO      `yaml:"minio"`
+	Fonts      Fonts      `yaml:"fonts"`
+	Patterns   Patterns   `yaml:"patterns"`
+	PlotStyles PlotStyles `yaml:"plot_styles"`
 }
 
 type MinIO struct {
@@ -27,6 +30,28 @@ type MinIO struct {
 	Bucket          string `yaml:"bucket"`
 }
 
+// Fonts configures where TrueType fonts referenced by drawings are looked
+// up, along with SHX fonts and shape files. Fallback is used for SHX
+// fonts and fonts that are not installed; GlyphFallbacks fill in, in
+// order, for the characters a font does not have, such as CJK ones.
+type Fonts struct {
+	Dirs           []string `yaml:"dirs"`
+	Fallback       string   `yaml:"fallback"`
+	GlyphFallbacks []string `yaml:"glyph_fallbacks"`
+}
+
+// Patterns lists directories of .pat files with hatch patterns beyond the
+// standard ones, for drawings that name a pattern without storing it.
+type Patterns struct {
+	Dirs []string `yaml:"dirs"`
+}
+
+// PlotStyles lists directories of .ctb and .stb plot style tables that
+// page setups and requests can name.
+type PlotStyles struct {
+	Dirs []string `yaml:"dirs"`
+}
+
 func MustLoad() *Config {
 	cfgPath := configPath()
 	data, err := os.ReadFile(cfgPath)
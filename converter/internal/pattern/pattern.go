@@ -0,0 +1,201 @@
+// Package pattern reads hatch pattern definitions in the .pat format and
+// keeps a library of the standard patterns and those of user files.
+package pattern
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+//go:embed acad.pat
+var standard string
+
+// Pattern is a named hatch pattern.
+type Pattern struct {
+	Name        string
+	Description string
+	Lines       []Line
+}
+
+// Line is one family of pattern lines as a .pat file defines it: Offset
+// is measured along (X) and across (Y) the line direction, which is at
+// Angle radians.
+type Line struct {
+	Angle  float64
+	Base   geom.Vec2
+	Offset geom.Vec2
+	Dashes []float64
+}
+
+// Place returns the lines of the pattern for a hatch that rotates it by
+// angle and scales it by scale, with offsets in the hatch's coordinates.
+func (p *Pattern) Place(angle, scale float64) []drawing.HatchLine {
+	lines := make([]drawing.HatchLine, len(p.Lines))
+	rot := geom.RotateZ(angle)
+	for i, l := range p.Lines {
+		dir := l.Angle + angle
+		dashes := make([]float64, len(l.Dashes))
+		for j, d := range l.Dashes {
+			dashes[j] = d * scale
+		}
+		lines[i] = drawing.HatchLine{
+			Angle:  dir,
+			Base:   rot.Apply2(l.Base.Scale(scale)),
+			Offset: geom.RotateZ(dir).Apply2(l.Offset.Scale(scale)),
+			Dashes: dashes,
+		}
+	}
+	return lines
+}
+
+// Parse reads the patterns of a .pat file. Malformed lines are skipped;
+// the first of them is reported together with the patterns that could be
+// read.
+func Parse(r io.Reader) ([]*Pattern, error) {
+	var (
+		out      []*Pattern
+		cur      *Pattern
+		firstErr error
+	)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		s := sc.Text()
+		if i := strings.IndexByte(s, ';'); i >= 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(strings.TrimPrefix(s, "\ufeff"))
+		if s == "" {
+			continue
+		}
+		if s[0] == '*' {
+			name, desc, _ := strings.Cut(s[1:], ",")
+			cur = &Pattern{Name: strings.TrimSpace(name), Description: strings.TrimSpace(desc)}
+			out = append(out, cur)
+			continue
+		}
+		l, err := parseLine(s)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("line %d: %w", n, err)
+		case cur == nil:
+			err = fmt.Errorf("line %d: pattern line before the first pattern name", n)
+		default:
+			cur.Lines = append(cur.Lines, l)
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return out, err
+	}
+	return out, firstErr
+}
+
+// parseLine reads "angle, x,y, dx,dy [,dash...]".
+func parseLine(s string) (Line, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) < 5 {
+		return Line{}, fmt.Errorf("%d fields, want at least 5", len(fields))
+	}
+	v := make([]float64, len(fields))
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return Line{}, err
+		}
+		v[i] = x
+	}
+	return Line{
+		Angle:  geom.Rad(v[0]),
+		Base:   geom.Vec2{X: v[1], Y: v[2]},
+		Offset: geom.Vec2{X: v[3], Y: v[4]},
+		Dashes: v[5:],
+	}, nil
+}
+
+// Library looks patterns up by name: first in the .pat files of its
+// directories, then among the standard patterns. It is safe for
+// concurrent use.
+type Library struct {
+	dirs []string
+
+	once     sync.Once
+	patterns map[string]*Pattern // upper-case name to pattern
+}
+
+// NewLibrary searches dirs, recursively, for .pat files on first use.
+func NewLibrary(dirs []string) *Library {
+	return &Library{dirs: dirs}
+}
+
+// Lookup returns the pattern called name, or nil. A nil library only
+// knows the standard patterns.
+func (l *Library) Lookup(name string) *Pattern {
+	if l == nil {
+		return builtin()[strings.ToUpper(name)]
+	}
+	l.once.Do(l.load)
+	return l.patterns[strings.ToUpper(name)]
+}
+
+func (l *Library) load() {
+	l.patterns = make(map[string]*Pattern)
+	for k, p := range builtin() {
+		l.patterns[k] = p
+	}
+	for _, dir := range l.dirs {
+		filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+			if err != nil || e.IsDir() || !strings.EqualFold(filepath.Ext(path), ".pat") {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return nil
+			}
+			defer f.Close()
+			patterns, _ := Parse(f)
+			for _, p := range patterns {
+				if len(p.Lines) > 0 {
+					l.patterns[strings.ToUpper(p.Name)] = p
+				}
+			}
+			return nil
+		})
+	}
+}
+
+var builtin = sync.OnceValue(func() map[string]*Pattern {
+	patterns, err := Parse(strings.NewReader(standard))
+	if err != nil {
+		panic("pattern: acad.pat: " + err.Error())
+	}
+	m := make(map[string]*Pattern, len(patterns))
+	for _, p := range patterns {
+		m[p.Name] = p
+	}
+	return m
+})
+
+// User returns the pattern of a user-defined hatch: parallel lines at
+// angle spaced by spacing, crossed by a second family when double is set.
+func User(angle, spacing float64, double bool) []drawing.HatchLine {
+	lines := []drawing.HatchLine{{Angle: angle, Offset: geom.Polar(angle+math.Pi/2, spacing)}}
+	if double {
+		lines = append(lines, drawing.HatchLine{Angle: angle + math.Pi/2, Offset: geom.Polar(angle+math.Pi, spacing)})
+	}
+	return lines
+}
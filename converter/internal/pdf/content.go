@@ -0,0 +1,130 @@
+package pdf
+
+import (
+	"bytes"
+)
+
+// Content builds a page content stream. Each method appends one operator.
+type Content struct {
+	buf bytes.Buffer
+}
+
+func (c *Content) Bytes() []byte { return c.buf.Bytes() }
+
+func (c *Content) Len() int { return c.buf.Len() }
+
+func (c *Content) op(name string, args ...float64) {
+	for _, a := range args {
+		c.buf.WriteString(num(a))
+		c.buf.WriteByte(' ')
+	}
+	c.buf.WriteString(name)
+	c.buf.WriteByte('\n')
+}
+
+// Graphics state.
+
+func (c *Content) Save()    { c.op("q") }
+func (c *Content) Restore() { c.op("Q") }
+
+func (c *Content) Transform(a, b, cc, d, e, f float64) { c.op("cm", a, b, cc, d, e, f) }
+
+func (c *Content) SetLineWidth(w float64) { c.op("w", w) }
+func (c *Content) SetLineCap(v int)       { c.op("J", float64(v)) }
+func (c *Content) SetLineJoin(v int)      { c.op("j", float64(v)) }
+func (c *Content) SetMiterLimit(v float64) {
+	c.op("M", v)
+}
+
+// SetDash sets the dash pattern; an empty pattern draws solid lines.
+func (c *Content) SetDash(pattern []float64, phase float64) {
+	c.buf.WriteByte('[')
+	for i, v := range pattern {
+		if i > 0 {
+			c.buf.WriteByte(' ')
+		}
+		c.buf.WriteString(num(v))
+	}
+	c.buf.WriteString("] ")
+	c.op("d", phase)
+}
+
+func (c *Content) SetStrokeRGB(r, g, b float64) { c.op("RG", r, g, b) }
+func (c *Content) SetFillRGB(r, g, b float64)   { c.op("rg", r, g, b) }
+func (c *Content) SetStrokeGray(g float64)      { c.op("G", g) }
+func (c *Content) SetFillGray(g float64)        { c.op("g", g) }
+
+// SetExtGState applies a graphics state registered with Page.ExtGState.
+func (c *Content) SetExtGState(name Name) { c.named("gs", name) }
+
+// Path construction.
+
+func (c *Content) MoveTo(x, y float64) { c.op("m", x, y) }
+func (c *Content) LineTo(x, y float64) { c.op("l", x, y) }
+func (c *Content) CurveTo(x1, y1, x2, y2, x3, y3 float64) {
+	c.op("c", x1, y1, x2, y2, x3, y3)
+}
+func (c *Content) ClosePath()              { c.op("h") }
+func (c *Content) Rect(x, y, w, h float64) { c.op("re", x, y, w, h) }
+
+// Path painting.
+
+func (c *Content) Stroke()            { c.op("S") }
+func (c *Content) CloseStroke()       { c.op("s") }
+func (c *Content) Fill()              { c.op("f") }
+func (c *Content) FillEvenOdd()       { c.op("f*") }
+func (c *Content) FillStroke()        { c.op("B") }
+func (c *Content) FillEvenOddStroke() { c.op("B*") }
+func (c *Content) EndPath()           { c.op("n") }
+
+// Clip intersects the clipping path with the current path; it takes
+// effect after the next painting operator, usually EndPath.
+func (c *Content) Clip()        { c.op("W") }
+func (c *Content) ClipEvenOdd() { c.op("W*") }
+
+// Shade fills the clipping region with a shading registered with
+// Page.Shading.
+func (c *Content) Shade(name Name) { c.named("sh", name) }
+
+// Text.
+
+func (c *Content) BeginText() { c.op("BT") }
+func (c *Content) EndText()   { c.op("ET") }
+
+func (c *Content) SetFont(name Name, size float64) {
+	writeName(&c.buf, name)
+	c.buf.WriteByte(' ')
+	c.op("Tf", size)
+}
+
+func (c *Content) SetTextMatrix(a, b, cc, d, e, f float64) { c.op("Tm", a, b, cc, d, e, f) }
+func (c *Content) SetCharSpacing(v float64)                { c.op("Tc", v) }
+func (c *Content) SetHorizScale(percent float64)           { c.op("Tz", percent) }
+
+// SetTextRender selects the text rendering mode; 3 draws invisible text.
+func (c *Content) SetTextRender(mode int) { c.op("Tr", float64(mode)) }
+
+// ShowText shows text already encoded for the current font.
+func (c *Content) ShowText(encoded []byte) {
+	writeString(&c.buf, encoded)
+	c.buf.WriteString(" Tj\n")
+}
+
+// XObjects and marked content.
+
+func (c *Content) DrawXObject(name Name) { c.named("Do", name) }
+
+func (c *Content) BeginMarked(tag, properties Name) {
+	writeName(&c.buf, tag)
+	c.buf.WriteByte(' ')
+	c.named("BDC", properties)
+}
+
+func (c *Content) EndMarked() { c.op("EMC") }
+
+func (c *Content) named(op string, name Name) {
+	writeName(&c.buf, name)
+	c.buf.WriteByte(' ')
+	c.buf.WriteString(op)
+	c.buf.WriteByte('\n')
+}
@@ -0,0 +1,299 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// objectsPerStream limits how many objects share one object stream.
+const objectsPerStream = 200
+
+// Document collects objects and pages and writes them as a PDF file.
+type Document struct {
+	// Compress enables Flate on streams. ObjectStreams packs all objects
+	// that are not streams into compressed object streams and writes a
+	// cross-reference stream instead of the classic table.
+	Compress      bool
+	ObjectStreams bool
+	// PDFA makes the document conform to PDF/A-2b: Write adds an sRGB
+	// output intent and checks the document before writing it, failing
+	// with ErrNotPDFA when it does not conform. Callers provide the XMP
+	// metadata that declares the conformance. It is set before fonts are
+	// added.
+	PDFA bool
+
+	// Catalog and Info are written as the document catalog and the
+	// information dictionary; callers may add entries before Write.
+	Catalog Dict
+	Info    Dict
+
+	objects  []any
+	pages    []*Page
+	pagesRef Ref
+	fonts    map[string]*Font
+	embedded []*Font
+}
+
+func New() *Document {
+	d := &Document{
+		Compress:      true,
+		ObjectStreams: true,
+		Info:          Dict{"Producer": String("dwgtopdf")},
+		fonts:         make(map[string]*Font),
+	}
+	d.pagesRef = d.Reserve()
+	d.Catalog = Dict{"Type": Name("Catalog"), "Pages": d.pagesRef}
+	return d
+}
+
+// Add stores an object and returns its reference.
+func (d *Document) Add(obj any) Ref {
+	d.objects = append(d.objects, obj)
+	return Ref(len(d.objects))
+}
+
+// Reserve returns a reference whose object is filled in later with Set.
+func (d *Document) Reserve() Ref { return d.Add(nil) }
+
+func (d *Document) Set(ref Ref, obj any) { d.objects[ref-1] = obj }
+
+func (d *Document) Pages() []*Page { return d.pages }
+
+// AddPage appends a page of the given size in points.
+func (d *Document) AddPage(width, height float64) *Page {
+	p := &Page{
+		Width:     width,
+		Height:    height,
+		ref:       d.Reserve(),
+		resources: make(map[Name]Dict),
+	}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Page is a single page with its content stream and resources.
+type Page struct {
+	Content
+
+	Width, Height float64
+	// Annots and Dict hold annotations and extra page dictionary entries.
+	Annots Array
+	Dict   Dict
+
+	ref       Ref
+	resources map[Name]Dict
+}
+
+func (p *Page) Ref() Ref { return p.ref }
+
+// Font registers a font with the page and returns its resource name.
+func (p *Page) Font(f *Font) Name { return p.resource("Font", "F", f.ref) }
+
+func (p *Page) XObject(ref Ref) Name { return p.resource("XObject", "X", ref) }
+
+func (p *Page) ExtGState(ref Ref) Name { return p.resource("ExtGState", "GS", ref) }
+
+func (p *Page) Shading(ref Ref) Name { return p.resource("Shading", "Sh", ref) }
+
+// Property registers a marked-content property list, such as an optional
+// content group.
+func (p *Page) Property(ref Ref) Name { return p.resource("Properties", "P", ref) }
+
+func (p *Page) resource(category Name, prefix string, ref Ref) Name {
+	res := p.resources[category]
+	if res == nil {
+		res = make(Dict)
+		p.resources[category] = res
+	}
+	for name, v := range res {
+		if v == ref {
+			return name
+		}
+	}
+	name := Name(prefix + strconv.Itoa(len(res)+1))
+	res[name] = ref
+	return name
+}
+
+// finish turns the pages into objects and builds the page tree.
+func (d *Document) finish() {
+	kids := make(Array, 0, len(d.pages))
+	for _, p := range d.pages {
+		res := Dict{}
+		for k, v := range p.resources {
+			res[k] = v
+		}
+		page := Dict{
+			"Type":      Name("Page"),
+			"Parent":    d.pagesRef,
+			"MediaBox":  Array{0, 0, p.Width, p.Height},
+			"Resources": res,
+			"Contents":  d.Add(Stream{Data: p.Content.Bytes()}),
+		}
+		if len(p.Annots) > 0 {
+			page["Annots"] = p.Annots
+		}
+		for k, v := range p.Dict {
+			page[k] = v
+		}
+		d.Set(p.ref, page)
+		kids = append(kids, p.ref)
+	}
+	d.Set(d.pagesRef, Dict{"Type": Name("Pages"), "Kids": kids, "Count": len(kids)})
+}
+
+// Write finishes the document and writes it out. It must be called once.
+func (d *Document) Write(w io.Writer) error {
+	d.writeFonts()
+	d.finish()
+	if d.PDFA {
+		d.Catalog["OutputIntents"] = Array{d.outputIntent()}
+		if err := d.checkPDFA(); err != nil {
+			return err
+		}
+	}
+	catalog := d.Add(d.Catalog)
+	var info Ref
+	if len(d.Info) > 0 {
+		info = d.Add(d.Info)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	// entries holds the cross-reference entries: type 1 with an offset or
+	// type 2 with the object stream number and index.
+	entries := make([]xrefEntry, len(d.objects)+1)
+	var packed []Ref
+	for i, obj := range d.objects {
+		ref := Ref(i + 1)
+		if _, ok := obj.(Stream); !ok && d.ObjectStreams {
+			packed = append(packed, ref)
+			continue
+		}
+		entries[ref] = xrefEntry{typ: 1, a: b.Len()}
+		d.writeObject(&b, ref, obj)
+	}
+	for len(packed) > 0 {
+		n := min(len(packed), objectsPerStream)
+		d.writeObjectStream(&b, packed[:n], &entries)
+		packed = packed[n:]
+	}
+
+	id := md5.Sum(b.Bytes())
+	trailer := Dict{
+		"Root": catalog,
+		"ID":   Array{HexString(id[:]), HexString(id[:])},
+	}
+	if info != 0 {
+		trailer["Info"] = info
+	}
+
+	if d.ObjectStreams {
+		d.writeXRefStream(&b, trailer, entries)
+	} else {
+		d.writeXRefTable(&b, trailer, entries)
+	}
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("write pdf: %w", err)
+	}
+	return nil
+}
+
+type xrefEntry struct {
+	typ  int
+	a, b int
+}
+
+func (d *Document) writeObject(b *bytes.Buffer, ref Ref, obj any) {
+	fmt.Fprintf(b, "%d 0 obj\n", int(ref))
+	if s, ok := obj.(Stream); ok {
+		dict, data := d.encodeStream(s)
+		writeValue(b, dict)
+		b.WriteString("\nstream\n")
+		b.Write(data)
+		b.WriteString("\nendstream")
+	} else {
+		writeValue(b, obj)
+	}
+	b.WriteString("\nendobj\n")
+}
+
+func (d *Document) encodeStream(s Stream) (Dict, []byte) {
+	dict := make(Dict, len(s.Dict)+2)
+	for k, v := range s.Dict {
+		dict[k] = v
+	}
+	data := s.Data
+	if _, filtered := dict["Filter"]; d.Compress && !filtered && !s.Plain {
+		data = deflate(data)
+		dict["Filter"] = Name("FlateDecode")
+	}
+	dict["Length"] = len(data)
+	return dict, data
+}
+
+func (d *Document) writeObjectStream(b *bytes.Buffer, refs []Ref, entries *[]xrefEntry) {
+	ref := d.Add(nil)
+	*entries = append(*entries, xrefEntry{})
+
+	var header, body bytes.Buffer
+	for i, r := range refs {
+		fmt.Fprintf(&header, "%d %d ", int(r), body.Len())
+		writeValue(&body, d.objects[r-1])
+		body.WriteByte('\n')
+		(*entries)[r] = xrefEntry{typ: 2, a: int(ref), b: i}
+	}
+	(*entries)[ref] = xrefEntry{typ: 1, a: b.Len()}
+	d.writeObject(b, ref, Stream{
+		Dict: Dict{"Type": Name("ObjStm"), "N": len(refs), "First": header.Len()},
+		Data: append(header.Bytes(), body.Bytes()...),
+	})
+}
+
+func (d *Document) writeXRefStream(b *bytes.Buffer, trailer Dict, entries []xrefEntry) {
+	ref := d.Add(nil)
+	entries = append(entries, xrefEntry{typ: 1, a: b.Len()})
+
+	data := make([]byte, 0, len(entries)*7)
+	for i, e := range entries {
+		if i == 0 {
+			data = append(data, 0, 0, 0, 0, 0, 0xff, 0xff)
+			continue
+		}
+		data = append(data, byte(e.typ),
+			byte(e.a>>24), byte(e.a>>16), byte(e.a>>8), byte(e.a),
+			byte(e.b>>8), byte(e.b))
+	}
+	dict := Dict{"Type": Name("XRef"), "Size": len(entries), "W": Array{1, 4, 2}}
+	for k, v := range trailer {
+		dict[k] = v
+	}
+	d.writeObject(b, ref, Stream{Dict: dict, Data: data})
+	fmt.Fprintf(b, "startxref\n%d\n%%%%EOF\n", entries[ref].a)
+}
+
+func (d *Document) writeXRefTable(b *bytes.Buffer, trailer Dict, entries []xrefEntry) {
+	start := b.Len()
+	fmt.Fprintf(b, "xref\n0 %d\n0000000000 65535 f \n", len(entries))
+	for _, e := range entries[1:] {
+		fmt.Fprintf(b, "%010d 00000 n \n", e.a)
+	}
+	trailer["Size"] = len(entries)
+	b.WriteString("trailer\n")
+	writeValue(b, trailer)
+	fmt.Fprintf(b, "\nstartxref\n%d\n%%%%EOF\n", start)
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+	return b.Bytes()
+}
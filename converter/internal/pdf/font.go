@@ -0,0 +1,236 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
+)
+
+// Font is a font resource shared by all pages that use it.
+type Font struct {
+	ref Ref
+	// ttf is set for embedded fonts, which are written with the glyphs in
+	// use when the document is finished.
+	ttf  *font.Font
+	used map[uint16]bool
+	// chars maps the glyphs used to the character they were first used
+	// for, which the ToUnicode map gives back to readers that search and
+	// copy text.
+	chars map[uint16]rune
+	// missing holds the characters the font has no glyph for, which PDF/A
+	// documents may not show.
+	missing map[rune]bool
+}
+
+// StandardFont returns one of the 14 standard Type 1 fonts, which readers
+// provide without embedding. Text is encoded as WinAnsi.
+func (d *Document) StandardFont(name string) *Font {
+	if f, ok := d.fonts[name]; ok {
+		return f
+	}
+	f := &Font{ref: d.Add(Dict{
+		"Type":     Name("Font"),
+		"Subtype":  Name("Type1"),
+		"BaseFont": Name(name),
+		"Encoding": Name("WinAnsiEncoding"),
+	})}
+	d.fonts[name] = f
+	return f
+}
+
+// EmbedFont returns a font that embeds the subset of ttf used in the
+// document. Text is encoded as two-byte glyph ids.
+func (d *Document) EmbedFont(ttf *font.Font) *Font {
+	for _, f := range d.embedded {
+		if f.ttf == ttf {
+			return f
+		}
+	}
+	f := &Font{
+		ref:     d.Reserve(),
+		ttf:     ttf,
+		used:    make(map[uint16]bool),
+		chars:   make(map[uint16]rune),
+		missing: make(map[rune]bool),
+	}
+	d.embedded = append(d.embedded, f)
+	return f
+}
+
+// Encode converts text to the font encoding. Standard fonts replace
+// characters they cannot represent with '?', embedded fonts with their
+// missing glyph, which fails the PDF/A check.
+func (f *Font) Encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if f.ttf != nil {
+			g, ok := f.ttf.Glyph(r)
+			if !ok {
+				f.missing[r] = true
+			}
+			f.used[g] = true
+			if _, seen := f.chars[g]; ok && !seen {
+				f.chars[g] = r
+			}
+			out = append(out, byte(g>>8), byte(g))
+			continue
+		}
+		c, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			c = '?'
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// writeFonts writes the embedded fonts as Type 0 fonts with a TrueType
+// descendant, subset to the glyphs the pages show, and with a ToUnicode
+// map so their text can be searched and copied.
+func (d *Document) writeFonts() {
+	for _, f := range d.embedded {
+		ttf := f.ttf
+		glyphs := slices.Sorted(maps.Keys(f.used))
+		name := Name(subsetTag(ttf, glyphs) + "+" + baseName(ttf))
+		scale := 1000 / float64(ttf.UnitsPerEm)
+
+		flags := 32 // nonsymbolic
+		if ttf.FixedPitch {
+			flags |= 1
+		}
+		if ttf.ItalicAngle != 0 {
+			flags |= 64
+		}
+		stemV := 80
+		if ttf.Bold {
+			stemV = 140
+		}
+		file := ttf.Subset(glyphs)
+		descriptor := d.Add(Dict{
+			"Type":     Name("FontDescriptor"),
+			"FontName": name,
+			"Flags":    flags,
+			"FontBBox": Array{
+				float64(ttf.BBox[0]) * scale, float64(ttf.BBox[1]) * scale,
+				float64(ttf.BBox[2]) * scale, float64(ttf.BBox[3]) * scale,
+			},
+			"ItalicAngle": ttf.ItalicAngle,
+			"Ascent":      ttf.Ascent * 1000,
+			"Descent":     ttf.Descent * 1000,
+			"CapHeight":   ttf.CapHeight * 1000,
+			"StemV":       stemV,
+			"FontFile2":   d.Add(Stream{Dict: Dict{"Length1": len(file)}, Data: file}),
+		})
+
+		// Widths are grouped in runs of consecutive glyph ids.
+		var widths Array
+		for i := 0; i < len(glyphs); {
+			j := i + 1
+			for j < len(glyphs) && glyphs[j] == glyphs[j-1]+1 {
+				j++
+			}
+			run := make(Array, 0, j-i)
+			for _, g := range glyphs[i:j] {
+				run = append(run, ttf.Advance(g)*1000)
+			}
+			widths = append(widths, int(glyphs[i]), run)
+			i = j
+		}
+
+		cid := d.Add(Dict{
+			"Type":     Name("Font"),
+			"Subtype":  Name("CIDFontType2"),
+			"BaseFont": name,
+			"CIDSystemInfo": Dict{
+				"Registry":   String("Adobe"),
+				"Ordering":   String("Identity"),
+				"Supplement": 0,
+			},
+			"FontDescriptor": descriptor,
+			"W":              widths,
+			"CIDToGIDMap":    Name("Identity"),
+		})
+		d.Set(f.ref, Dict{
+			"Type":            Name("Font"),
+			"Subtype":         Name("Type0"),
+			"BaseFont":        name,
+			"Encoding":        Name("Identity-H"),
+			"DescendantFonts": Array{cid},
+			"ToUnicode":       d.Add(Stream{Data: toUnicode(f.chars)}),
+		})
+	}
+}
+
+// toUnicode writes a CMap from two-byte glyph ids to the UTF-16
+// characters they show.
+func toUnicode(chars map[uint16]rune) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n" +
+		"12 dict begin\n" +
+		"begincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n" +
+		"/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	glyphs := slices.Sorted(maps.Keys(chars))
+	// A section maps at most 100 codes.
+	for len(glyphs) > 0 {
+		n := min(len(glyphs), 100)
+		fmt.Fprintf(&b, "%d beginbfchar\n", n)
+		for _, g := range glyphs[:n] {
+			fmt.Fprintf(&b, "<%04X> <", g)
+			for _, u := range utf16.Encode([]rune{chars[g]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+		glyphs = glyphs[n:]
+	}
+	b.WriteString("endcmap\n" +
+		"CMapName currentdict /CMap defineresource pop\n" +
+		"end\n" +
+		"end\n")
+	return b.Bytes()
+}
+
+// subsetTag derives the six letter prefix that marks a subset font from
+// the font and its glyphs, so equal subsets get equal names.
+func subsetTag(ttf *font.Font, glyphs []uint16) string {
+	h := md5.New()
+	h.Write([]byte(ttf.PostScriptName))
+	for _, g := range glyphs {
+		h.Write([]byte{byte(g >> 8), byte(g)})
+	}
+	sum := h.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	return string(tag)
+}
+
+// baseName returns the PostScript name, which must not contain spaces.
+func baseName(ttf *font.Font) string {
+	name := ttf.PostScriptName
+	if name == "" {
+		name = ttf.Family
+	}
+	out := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c > ' ' && c < 0x7f && c != '/' && c != '%' && c != '(' && c != ')' {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return "Font"
+	}
+	return string(out)
+}
@@ -0,0 +1,95 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// sRGBCurvePoints is the number of samples of the tone curve of sRGB.
+const sRGBCurvePoints = 1024
+
+// SRGBProfile builds an ICC version 2 display profile of sRGB: its
+// primaries adapted to D50 and its tone curve sampled, the colors all
+// pages are drawn in.
+func SRGBProfile() []byte {
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	curve := []byte("curv\x00\x00\x00\x00")
+	curve = binary.BigEndian.AppendUint32(curve, sRGBCurvePoints)
+	for i := range sRGBCurvePoints {
+		v := float64(i) / (sRGBCurvePoints - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = binary.BigEndian.AppendUint16(curve, uint16(math.Round(v*0xFFFF)))
+	}
+	const name = "sRGB IEC61966-2.1"
+	desc := []byte("desc\x00\x00\x00\x00")
+	desc = binary.BigEndian.AppendUint32(desc, uint32(len(name)+1))
+	desc = append(desc, name+"\x00"...)
+	// No Unicode and no ScriptCode description.
+	desc = append(desc, make([]byte, 4+4+2+1+67)...)
+	cprt := []byte("text\x00\x00\x00\x00No copyright, use freely\x00")
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc},
+		{"cprt", cprt},
+		{"wtpt", xyz(0.9642, 1, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	const headerSize = 128
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	var data []byte
+	offset := headerSize + 4 + 12*len(tags)
+	// The three tone curves share their data.
+	var curveOffset int
+	for _, t := range tags {
+		at := offset + len(data)
+		if t.sig[1:] == "TRC" && curveOffset != 0 {
+			at = curveOffset
+		} else {
+			if t.sig[1:] == "TRC" {
+				curveOffset = at
+			}
+			data = append(data, t.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		table = append(table, t.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(at))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+	}
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:], uint32(headerSize+len(table)+len(data)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	for i, v := range []uint16{2024, 1, 1} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	// The D50 illuminant of the profile connection space.
+	copy(header[68:], xyz(0.9642, 1, 0.8249)[8:])
+
+	out := append(header, table...)
+	return append(out, data...)
+}
@@ -0,0 +1,163 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// The PDF object model. Values are written by writeValue; Go ints, floats
+// and bools map to PDF numbers and booleans, nil to null.
+type (
+	Name      string
+	String    string
+	HexString []byte
+	Array     []any
+	Dict      map[Name]any
+	Ref       int
+)
+
+// TextString returns s as a PDF text string: a literal string when it is
+// ASCII, and UTF-16BE with a byte order mark otherwise.
+func TextString(s string) any {
+	ascii := true
+	for i := 0; i < len(s) && ascii; i++ {
+		ascii = s[i] < 0x80
+	}
+	if ascii {
+		return String(s)
+	}
+	b := []byte{0xFE, 0xFF}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return HexString(b)
+}
+
+// Stream is written with its Dict plus /Length, compressed with Flate
+// unless the dictionary already names a filter or Plain is set, as for
+// metadata that tools read without parsing the PDF.
+type Stream struct {
+	Dict  Dict
+	Data  []byte
+	Plain bool
+}
+
+func writeValue(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteString(num(v))
+	case Name:
+		writeName(b, v)
+	case String:
+		writeString(b, []byte(v))
+	case HexString:
+		fmt.Fprintf(b, "<%X>", []byte(v))
+	case Ref:
+		fmt.Fprintf(b, "%d 0 R", int(v))
+	case Array:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeValue(b, e)
+		}
+		b.WriteByte(']')
+	case Dict:
+		b.WriteString("<<")
+		for _, k := range keys(v) {
+			writeName(b, k)
+			b.WriteByte(' ')
+			writeValue(b, v[k])
+		}
+		b.WriteString(">>")
+	default:
+		panic(fmt.Sprintf("pdf: unsupported value %T", v))
+	}
+}
+
+// keys returns the dictionary keys sorted, with /Type first, so output is
+// deterministic and easy to read.
+func keys(d Dict) []Name {
+	ks := make([]Name, 0, len(d))
+	for k := range d {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		if (ks[i] == "Type") != (ks[j] == "Type") {
+			return ks[i] == "Type"
+		}
+		return ks[i] < ks[j]
+	})
+	return ks
+}
+
+func writeName(b *bytes.Buffer, n Name) {
+	b.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c < '!' || c > '~' || bytes.IndexByte([]byte("#/()<>[]{}%"), c) >= 0 {
+			fmt.Fprintf(b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
+
+func writeString(b *bytes.Buffer, s []byte) {
+	b.WriteByte('(')
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+}
+
+// num formats a real number with at most four decimals, enough for
+// coordinates in points.
+func num(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	s = trimZeros(s)
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func trimZeros(s string) string {
+	if bytes.IndexByte([]byte(s), '.') < 0 {
+		return s
+	}
+	i := len(s)
+	for i > 0 && s[i-1] == '0' {
+		i--
+	}
+	if i > 0 && s[i-1] == '.' {
+		i--
+	}
+	return s[:i]
+}
@@ -0,0 +1,385 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ErrNotPDFA is returned by Write for a PDF/A document that does not
+// conform, with the reason.
+var ErrNotPDFA = errors.New("document cannot conform to PDF/A-2b")
+
+// Limits PDF/A-2 sets on the objects of a file and the size of pages.
+const (
+	maxNameLength   = 127
+	maxStringLength = 32767
+	minPageSide     = 3
+	maxPageSide     = 14400
+)
+
+// XMP namespaces of the PDF/A identification and extension schemas.
+const (
+	pdfaIDNamespace     = "http://www.aiim.org/pdfa/ns/id/"
+	pdfaSchemaNamespace = "http://www.aiim.org/pdfa/ns/schema#"
+)
+
+// predefinedSchemas are the XMP schemas PDF/A files may use without
+// describing them in an extension schema.
+var predefinedSchemas = []string{
+	"http://purl.org/dc/elements/1.1/",
+	"http://ns.adobe.com/xap/1.0/",
+	"http://ns.adobe.com/xap/1.0/rights/",
+	"http://ns.adobe.com/xap/1.0/mm/",
+	"http://ns.adobe.com/xap/1.0/bj/",
+	"http://ns.adobe.com/xap/1.0/t/pg/",
+	"http://ns.adobe.com/xmp/1.0/DynamicMedia/",
+	"http://ns.adobe.com/pdf/1.3/",
+	"http://ns.adobe.com/photoshop/1.0/",
+	"http://ns.adobe.com/camera-raw-settings/1.0/",
+	"http://ns.adobe.com/tiff/1.0/",
+	"http://ns.adobe.com/exif/1.0/",
+	"http://ns.adobe.com/exif/1.0/aux/",
+	pdfaIDNamespace,
+	"http://www.aiim.org/pdfa/ns/extension/",
+}
+
+// infoProperties are the XMP properties that must repeat the entries of
+// the Info dictionary.
+var infoProperties = map[Name]xml.Name{
+	"Title":        {Space: "http://purl.org/dc/elements/1.1/", Local: "title"},
+	"Author":       {Space: "http://purl.org/dc/elements/1.1/", Local: "creator"},
+	"Subject":      {Space: "http://purl.org/dc/elements/1.1/", Local: "description"},
+	"Keywords":     {Space: "http://ns.adobe.com/pdf/1.3/", Local: "Keywords"},
+	"Producer":     {Space: "http://ns.adobe.com/pdf/1.3/", Local: "Producer"},
+	"Creator":      {Space: "http://ns.adobe.com/xap/1.0/", Local: "CreatorTool"},
+	"CreationDate": {Space: "http://ns.adobe.com/xap/1.0/", Local: "CreateDate"},
+	"ModDate":      {Space: "http://ns.adobe.com/xap/1.0/", Local: "ModifyDate"},
+}
+
+// Actions PDF/A does not allow, as they run code or play media.
+var forbiddenActions = []Name{
+	"Launch", "Sound", "Movie", "ResetForm", "ImportData", "JavaScript",
+	"Hide", "SetOCGState", "Rendition", "Trans", "GoTo3DView",
+}
+
+var blendModes = []Name{
+	"Normal", "Compatible", "Multiply", "Screen", "Overlay", "Darken",
+	"Lighten", "ColorDodge", "ColorBurn", "HardLight", "SoftLight",
+	"Difference", "Exclusion", "Hue", "Saturation", "Color", "Luminosity",
+}
+
+// Annotation flags.
+const (
+	annotInvisible = 1 << 0
+	annotHidden    = 1 << 1
+	annotPrint     = 1 << 2
+	annotNoView    = 1 << 5
+)
+
+// outputIntent declares sRGB, the color space pages are drawn in, as the
+// space device colors mean.
+func (d *Document) outputIntent() Dict {
+	const condition = "sRGB IEC61966-2.1"
+	return Dict{
+		"Type":                      Name("OutputIntent"),
+		"S":                         Name("GTS_PDFA1"),
+		"OutputConditionIdentifier": String(condition),
+		"Info":                      String(condition),
+		"RegistryName":              String("http://www.color.org"),
+		"DestOutputProfile":         d.Add(Stream{Dict: Dict{"N": 3}, Data: SRGBProfile()}),
+	}
+}
+
+// checkPDFA checks the finished document against what PDF/A-2b asks of
+// its structure: XMP metadata that identifies it and agrees with the Info
+// dictionary, an output intent, embedded fonts that show no missing
+// glyphs, and no external content, code, hidden annotations or features
+// archives cannot keep. Files are never encrypted and always have an ID.
+func (d *Document) checkPDFA() error {
+	if err := d.checkCatalog(); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotPDFA, err)
+	}
+	for _, f := range d.embedded {
+		if len(f.missing) > 0 {
+			chars := slices.Sorted(maps.Keys(f.missing))
+			return fmt.Errorf("%w: font %s has no glyph for %q", ErrNotPDFA, baseName(f.ttf), string(chars))
+		}
+	}
+	for _, obj := range d.objects {
+		if err := d.checkValue(obj); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotPDFA, err)
+		}
+	}
+	return nil
+}
+
+func (d *Document) checkCatalog() error {
+	if _, ok := d.Catalog["AA"]; ok {
+		return errors.New("the document has additional actions")
+	}
+	if names, ok := d.resolve(d.Catalog["Names"]).(Dict); ok {
+		if _, ok := names["JavaScript"]; ok {
+			return errors.New("the document has JavaScript")
+		}
+		if _, ok := names["EmbeddedFiles"]; ok {
+			return errors.New("the document has embedded files")
+		}
+	}
+	intents, _ := d.resolve(d.Catalog["OutputIntents"]).(Array)
+	if !slices.ContainsFunc(intents, func(v any) bool {
+		intent, _ := d.resolve(v).(Dict)
+		_, profile := intent["DestOutputProfile"]
+		return intent["S"] == Name("GTS_PDFA1") && profile
+	}) {
+		return errors.New("the document has no output intent with an ICC profile")
+	}
+	if oc, ok := d.resolve(d.Catalog["OCProperties"]).(Dict); ok {
+		configs, _ := d.resolve(oc["Configs"]).(Array)
+		for _, c := range append(Array{oc["D"]}, configs...) {
+			config, _ := d.resolve(c).(Dict)
+			if _, ok := config["Name"]; !ok {
+				return errors.New("an optional content configuration has no name")
+			}
+			if _, ok := config["AS"]; ok {
+				return errors.New("an optional content configuration changes groups automatically")
+			}
+		}
+	}
+
+	meta, ok := d.resolve(d.Catalog["Metadata"]).(Stream)
+	if !ok {
+		return errors.New("the document has no XMP metadata")
+	}
+	if _, filtered := meta.Dict["Filter"]; filtered || d.Compress && !meta.Plain {
+		return errors.New("the XMP metadata is compressed")
+	}
+	return d.checkXMP(meta.Data)
+}
+
+// checkXMP checks that the XMP metadata declares PDF/A-2b, has the
+// entries of the Info dictionary and describes the schemas that are not
+// predefined.
+func (d *Document) checkXMP(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var part, conformance string
+	var described []string
+	used := make(map[string]bool)
+	properties := make(map[xml.Name]bool)
+	// depth counts the elements open inside rdf:Description, whose
+	// children are the properties.
+	depth := -1
+	var text *string
+	// id tells where the value of a PDF/A identification property goes.
+	id := func(n xml.Name) *string {
+		switch {
+		case n.Space != pdfaIDNamespace:
+		case n.Local == "part":
+			return &part
+		case n.Local == "conformance":
+			return &conformance
+		}
+		return nil
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("the XMP metadata is not well-formed: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			text = nil
+			switch {
+			case depth < 0 && t.Name.Local == "Description":
+				// Properties can be given as attributes too.
+				for _, a := range t.Attr {
+					if a.Name.Space != "xmlns" && a.Name.Space != "" && a.Name.Local != "about" {
+						properties[a.Name] = true
+						used[a.Name.Space] = true
+						if p := id(a.Name); p != nil {
+							*p = a.Value
+						}
+					}
+				}
+			case depth == 0:
+				properties[t.Name] = true
+				used[t.Name.Space] = true
+				text = id(t.Name)
+			case t.Name.Space == pdfaSchemaNamespace && t.Name.Local == "namespaceURI":
+				described = append(described, "")
+				text = &described[len(described)-1]
+			}
+			if depth >= 0 || t.Name.Local == "Description" {
+				depth++
+			}
+		case xml.CharData:
+			if text != nil {
+				*text += strings.TrimSpace(string(t))
+			}
+		case xml.EndElement:
+			text = nil
+			if depth >= 0 {
+				depth--
+			}
+		}
+	}
+
+	if part != "2" || !strings.EqualFold(conformance, "B") {
+		return errors.New("the XMP metadata does not identify the file as PDF/A-2b")
+	}
+	for ns := range used {
+		if !slices.Contains(predefinedSchemas, ns) && !slices.Contains(described, ns) {
+			return fmt.Errorf("the XMP schema %s has no extension schema", ns)
+		}
+	}
+	for key, prop := range infoProperties {
+		if _, ok := d.Info[key]; ok && !properties[prop] {
+			return fmt.Errorf("the XMP metadata has no %s property for the %s entry of the Info dictionary", prop.Local, key)
+		}
+	}
+	return nil
+}
+
+// checkValue checks an object and the direct objects it holds.
+func (d *Document) checkValue(v any) error {
+	switch v := v.(type) {
+	case Name:
+		if len(v) > maxNameLength {
+			return fmt.Errorf("name %.20s... is longer than %d bytes", v, maxNameLength)
+		}
+	case String:
+		if len(v) > maxStringLength {
+			return fmt.Errorf("a string is longer than %d bytes", maxStringLength)
+		}
+	case HexString:
+		if len(v) > maxStringLength {
+			return fmt.Errorf("a string is longer than %d bytes", maxStringLength)
+		}
+	case Array:
+		for _, e := range v {
+			if err := d.checkValue(e); err != nil {
+				return err
+			}
+		}
+	case Stream:
+		for _, key := range []Name{"F", "FFilter", "FDecodeParms"} {
+			if _, ok := v.Dict[key]; ok {
+				return errors.New("a stream refers to an external file")
+			}
+		}
+		filters := Array{v.Dict["Filter"]}
+		if a, ok := v.Dict["Filter"].(Array); ok {
+			filters = a
+		}
+		if slices.Contains(filters, any(Name("LZWDecode"))) {
+			return errors.New("a stream is compressed with LZW")
+		}
+		return d.checkDict(v.Dict)
+	case Dict:
+		return d.checkDict(v)
+	}
+	return nil
+}
+
+func (d *Document) checkDict(v Dict) error {
+	for k, e := range v {
+		if len(k) > maxNameLength {
+			return fmt.Errorf("name %.20s... is longer than %d bytes", k, maxNameLength)
+		}
+		if err := d.checkValue(e); err != nil {
+			return err
+		}
+	}
+	if _, ok := v["AA"]; ok {
+		return errors.New("a page or annotation has additional actions")
+	}
+	if s, ok := v["S"].(Name); ok && slices.Contains(forbiddenActions, s) {
+		return fmt.Errorf("the document has a %s action", s)
+	}
+
+	switch v["Type"] {
+	case Name("Page"):
+		box, _ := v["MediaBox"].(Array)
+		if len(box) == 4 {
+			w, h := number(box[2])-number(box[0]), number(box[3])-number(box[1])
+			if w < minPageSide || h < minPageSide || w > maxPageSide || h > maxPageSide {
+				return fmt.Errorf("a page of %.0f by %.0f points is outside the %d to %d points PDF/A allows", w, h, minPageSide, maxPageSide)
+			}
+		}
+	case Name("Font"):
+		switch v["Subtype"] {
+		case Name("Type1"), Name("MMType1"), Name("TrueType"), Name("CIDFontType0"), Name("CIDFontType2"):
+			fd, _ := d.resolve(v["FontDescriptor"]).(Dict)
+			_, f1 := fd["FontFile"]
+			_, f2 := fd["FontFile2"]
+			_, f3 := fd["FontFile3"]
+			if !f1 && !f2 && !f3 {
+				return fmt.Errorf("font %s is not embedded, as no font with its glyphs is installed", v["BaseFont"])
+			}
+		}
+	case Name("ExtGState"):
+		if _, ok := v["TR"]; ok {
+			return errors.New("a graphics state has a transfer function")
+		}
+		if tr, ok := v["TR2"]; ok && tr != Name("Default") {
+			return errors.New("a graphics state has a transfer function")
+		}
+		if bm, ok := v["BM"].(Name); ok && !slices.Contains(blendModes, bm) {
+			return fmt.Errorf("a graphics state has the blend mode %s", bm)
+		}
+	case Name("Annot"):
+		subtype := v["Subtype"]
+		if _, ok := v["AP"]; !ok && subtype != Name("Link") && subtype != Name("Popup") {
+			return fmt.Errorf("a %s annotation has no appearance", subtype)
+		}
+		flags, _ := v["F"].(int)
+		if subtype != Name("Popup") && (flags&annotPrint == 0 || flags&(annotInvisible|annotHidden|annotNoView) != 0) {
+			return fmt.Errorf("a %s annotation is hidden or not printed", subtype)
+		}
+	}
+
+	if v["Subtype"] == Name("Image") {
+		if v["Interpolate"] == true {
+			return errors.New("an image asks to be interpolated")
+		}
+		if _, ok := v["Alternates"]; ok {
+			return errors.New("an image has alternates")
+		}
+		if _, ok := v["OPI"]; ok {
+			return errors.New("an image has OPI comments")
+		}
+		if v["ColorSpace"] == Name("DeviceCMYK") {
+			return errors.New("an image is in CMYK, which the sRGB output intent does not cover")
+		}
+	}
+	if v["Subtype"] == Name("PS") {
+		return errors.New("the document has PostScript")
+	}
+	return nil
+}
+
+// resolve returns the object a reference points to, or v itself.
+func (d *Document) resolve(v any) any {
+	if r, ok := v.(Ref); ok && r > 0 && int(r) <= len(d.objects) {
+		return d.objects[r-1]
+	}
+	return v
+}
+
+func number(v any) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
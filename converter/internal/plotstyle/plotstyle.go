@@ -0,0 +1,312 @@
+// Package plotstyle reads plot style tables, the .ctb files that map
+// colors to pens and the .stb files of named plot styles, and keeps a
+// library of the tables installed on the server.
+package plotstyle
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Table is a plot style table. Color-dependent tables hold a style for
+// each of the 255 ACI colors, style i for color i+1; named tables hold
+// styles that layers and entities refer to by name.
+type Table struct {
+	Name        string
+	Description string
+	Named       bool
+	Styles      []*Style
+}
+
+// Style is one pen of a table. Lineweight is in millimetres; a negative
+// Lineweight keeps the weight of the object, as ObjectColor keeps its
+// color.
+type Style struct {
+	Name        string
+	Color       uint32
+	ObjectColor bool
+	Grayscale   bool
+	// Screen is the ink intensity in percent; less than 100 fades the
+	// color towards white.
+	Screen     int
+	Lineweight float64
+}
+
+// Plot returns the color an object of color c plots with.
+func (s *Style) Plot(c uint32) uint32 {
+	if s == nil {
+		return c
+	}
+	if !s.ObjectColor {
+		c = s.Color
+	}
+	r, g, b := float64(c>>16&0xFF), float64(c>>8&0xFF), float64(c&0xFF)
+	if s.Grayscale {
+		y := 0.299*r + 0.587*g + 0.114*b
+		r, g, b = y, y, y
+	}
+	if s.Screen >= 0 && s.Screen < 100 {
+		k := float64(s.Screen) / 100
+		fade := func(v float64) float64 { return 255 - (255-v)*k }
+		r, g, b = fade(r), fade(g), fade(b)
+	}
+	ch := func(v float64) uint32 { return uint32(math.Round(math.Min(math.Max(v, 0), 255))) }
+	return ch(r)<<16 | ch(g)<<8 | ch(b)
+}
+
+// ByColor returns the style of an ACI color in a color-dependent table,
+// or nil when the table has none.
+func (t *Table) ByColor(aci int) *Style {
+	if t == nil || t.Named || aci < 1 || aci > len(t.Styles) {
+		return nil
+	}
+	return t.Styles[aci-1]
+}
+
+// ByName returns the named style, or nil when the table has none. The
+// style Normal, which every named table starts with, plots objects as
+// they are.
+func (t *Table) ByName(name string) *Style {
+	if t == nil || !t.Named {
+		return nil
+	}
+	for _, s := range t.Styles {
+		if strings.EqualFold(s.Name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Monochrome returns the built-in table that plots every color black
+// with the lineweight of the object.
+func Monochrome() *Table {
+	t := &Table{Name: "monochrome.ctb", Description: "Monochrome"}
+	for i := 1; i <= 255; i++ {
+		t.Styles = append(t.Styles, &Style{Name: "Color_" + strconv.Itoa(i), Screen: 100, Lineweight: -1})
+	}
+	return t
+}
+
+var errFormat = errors.New("plotstyle: not a plot style table")
+
+// Read parses a table. The files start with a header line followed by
+// the zlib compressed text of the table; plain text tables are read as
+// well.
+func Read(r io.Reader, name string) (*Table, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	named := strings.EqualFold(filepath.Ext(name), ".stb")
+	if bytes.HasPrefix(data, []byte("PIAFILEVERSION")) {
+		head, _, _ := bytes.Cut(data, []byte("\n"))
+		named = bytes.Contains(head, []byte("STBVER"))
+		// The header line is followed by the codec name and three 32-bit
+		// words: a checksum and the unpacked and packed sizes.
+		const headerSize = 60
+		if len(data) < headerSize {
+			return nil, errFormat
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data[headerSize:]))
+		if err != nil {
+			return nil, fmt.Errorf("plotstyle: %w", err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("plotstyle: %w", err)
+		}
+	}
+	root, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := root.values["aci_table_available"]; ok {
+		named = !strings.EqualFold(v, "TRUE")
+	}
+	styles := root.child("plot_style")
+	if styles == nil {
+		return nil, errFormat
+	}
+
+	t := &Table{
+		Name:        filepath.Base(name),
+		Description: root.str("description"),
+		Named:       named,
+	}
+	weights := defaultLineweights
+	if lw := root.child("custom_lineweight_table"); lw != nil {
+		weights = make([]float64, len(lw.values))
+		for k, v := range lw.values {
+			i, err := strconv.Atoi(k)
+			if err == nil && i >= 0 && i < len(weights) {
+				weights[i], _ = strconv.ParseFloat(v, 64)
+			}
+		}
+	}
+	children := slices.Clone(styles.children)
+	slices.SortStableFunc(children, func(a, b *node) int { return atoi(a.name) - atoi(b.name) })
+	for _, n := range children {
+		t.Styles = append(t.Styles, n.style(weights))
+	}
+	return t, nil
+}
+
+// defaultLineweights are the lineweights of tables without a table of
+// their own, in millimetres.
+var defaultLineweights = []float64{
+	0, 0.05, 0.09, 0.1, 0.13, 0.15, 0.18, 0.2, 0.25, 0.3, 0.35, 0.4, 0.45, 0.5,
+	0.53, 0.6, 0.65, 0.7, 0.8, 0.9, 1, 1.06, 1.2, 1.4, 1.58, 2, 2.11,
+}
+
+// style reads a plot_style entry. A color of -1, or of 0xC3FFFFFF as
+// newer files write it, keeps the object color; other colors hold RGB in
+// their low 24 bits. Lineweights index the lineweight table, and indices
+// past its end keep the object's weight.
+func (n *node) style(weights []float64) *Style {
+	s := &Style{Name: n.str("name"), Screen: 100, Lineweight: -1}
+	c := uint32(atoi(n.values["color"]))
+	s.ObjectColor = n.values["color"] == "" || c == 0xFFFFFFFF || c == 0xC3FFFFFF
+	s.Color = c & 0xFFFFFF
+	s.Grayscale = atoi(n.values["color_policy"])&2 != 0
+	if v, ok := n.values["screen"]; ok {
+		s.Screen = atoi(v)
+	}
+	if v, ok := n.values["lineweight"]; ok {
+		if i := atoi(v); i >= 0 && i < len(weights) {
+			s.Lineweight = weights[i]
+		}
+	}
+	return s
+}
+
+// node is a section of the table text: key=value lines and nested
+// sections opened by name{ and closed by }.
+type node struct {
+	name     string
+	values   map[string]string
+	children []*node
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// str returns a string value, which the format opens with a quote it
+// never closes.
+func (n *node) str(key string) string {
+	return strings.TrimPrefix(n.values[key], `"`)
+}
+
+func parse(data []byte) (*node, error) {
+	root := &node{values: make(map[string]string)}
+	stack := []*node{root}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.Trim(sc.Text(), " \t\r\x00")
+		top := stack[len(stack)-1]
+		switch {
+		case line == "":
+		case line == "}":
+			if len(stack) == 1 {
+				return nil, errFormat
+			}
+			stack = stack[:len(stack)-1]
+		case strings.HasSuffix(line, "{"):
+			n := &node{name: strings.TrimSpace(strings.TrimSuffix(line, "{")), values: make(map[string]string)}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		default:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				top.values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("plotstyle: %w", err)
+	}
+	return root, nil
+}
+
+// atoi reads a decimal integer, which may be a negative 32-bit value
+// standing for an unsigned one.
+func atoi(s string) int {
+	v, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return int(v)
+}
+
+// Library looks tables up by file name among the .ctb and .stb files of
+// its directories, and has a built-in monochrome.ctb for servers that do
+// not install one.
+type Library struct {
+	dirs []string
+
+	once   sync.Once
+	tables map[string]*Table // lower-case file name to table
+}
+
+// NewLibrary searches dirs, recursively, for tables on first use.
+func NewLibrary(dirs []string) *Library {
+	return &Library{dirs: dirs}
+}
+
+// Lookup returns the table with the file name, with or without its
+// extension, or nil when there is none.
+func (l *Library) Lookup(name string) *Table {
+	key := strings.ToLower(strings.TrimSpace(filepath.Base(name)))
+	if l == nil {
+		if key == "monochrome" || key == "monochrome.ctb" {
+			return Monochrome()
+		}
+		return nil
+	}
+	l.once.Do(l.load)
+	if t, ok := l.tables[key]; ok {
+		return t
+	}
+	if filepath.Ext(key) == "" {
+		if t, ok := l.tables[key+".ctb"]; ok {
+			return t
+		}
+		return l.tables[key+".stb"]
+	}
+	return nil
+}
+
+func (l *Library) load() {
+	l.tables = map[string]*Table{"monochrome.ctb": Monochrome()}
+	for _, dir := range l.dirs {
+		filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+			ext := strings.ToLower(filepath.Ext(path))
+			if err != nil || e.IsDir() || ext != ".ctb" && ext != ".stb" {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return nil
+			}
+			defer f.Close()
+			if t, err := Read(f, path); err == nil {
+				l.tables[strings.ToLower(t.Name)] = t
+			}
+			return nil
+		})
+	}
+}
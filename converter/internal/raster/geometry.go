@@ -0,0 +1,198 @@
+package raster
+
+import (
+	"math"
+	"slices"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+// tolerance is how far in pixels flattened curves may stray from the
+// curves.
+const tolerance = 0.2
+
+// polyline is a flattened subpath in pixels.
+type polyline struct {
+	pts    []geom.Vec2
+	closed bool
+}
+
+// flatten turns the subpaths of p into polylines in pixels.
+func flatten(p *render.Path, m func(geom.Vec2) geom.Vec2) []polyline {
+	var out []polyline
+	var cur polyline
+	// reopen is where a subpath drawn on after closing starts.
+	var reopen *geom.Vec2
+	flush := func() {
+		if len(cur.pts) > 0 {
+			out = append(out, cur)
+		}
+		cur = polyline{}
+	}
+	add := func(v geom.Vec2) {
+		if len(cur.pts) == 0 && reopen != nil {
+			cur.pts = append(cur.pts, *reopen)
+		}
+		reopen = nil
+		cur.pts = append(cur.pts, v)
+	}
+	pts := p.Pts
+	for _, op := range p.Ops {
+		switch op {
+		case render.MoveTo:
+			flush()
+			reopen = nil
+			cur.pts = append(cur.pts, m(pts[0]))
+			pts = pts[1:]
+		case render.LineTo:
+			add(m(pts[0]))
+			pts = pts[1:]
+		case render.CubicTo:
+			if len(cur.pts) == 0 {
+				if reopen == nil {
+					pts = pts[3:]
+					continue
+				}
+				cur.pts = append(cur.pts, *reopen)
+			}
+			reopen = nil
+			cur.pts = cubic(cur.pts, cur.pts[len(cur.pts)-1], m(pts[0]), m(pts[1]), m(pts[2]))
+			pts = pts[3:]
+		case render.Close:
+			if len(cur.pts) > 0 {
+				cur.closed = true
+				start := cur.pts[0]
+				flush()
+				reopen = &start
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+// cubic appends the points of the cubic Bézier curve from p0, with as
+// many segments as keep it within tolerance.
+func cubic(out []geom.Vec2, p0, p1, p2, p3 geom.Vec2) []geom.Vec2 {
+	dd := max(p0.Sub(p1.Scale(2)).Add(p2).Len(), p1.Sub(p2.Scale(2)).Add(p3).Len())
+	n := int(math.Ceil(math.Sqrt(0.75 * dd / tolerance)))
+	n = min(max(n, 1), 256)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		out = append(out, geom.Vec2{
+			X: u*u*u*p0.X + 3*u*u*t*p1.X + 3*u*t*t*p2.X + t*t*t*p3.X,
+			Y: u*u*u*p0.Y + 3*u*u*t*p1.Y + 3*u*t*t*p2.Y + t*t*t*p3.Y,
+		})
+	}
+	return out
+}
+
+// fillPath adds the subpaths of a path to fill, each closed.
+func (s *scanner) fillPath(lines []polyline) {
+	for _, l := range lines {
+		if len(l.pts) > 1 {
+			s.polygon(l.pts)
+		}
+	}
+}
+
+// stroke adds the outline of the polylines stroked with round caps and
+// joins and the given width in pixels: a rectangle for each segment and
+// a disc at each vertex, all wound the same way so that the nonzero rule
+// unites them.
+func (s *scanner) stroke(lines []polyline, width float64) {
+	r := width / 2
+	// Joins of lines this thin cannot be seen.
+	discs := r >= 1
+	for _, l := range lines {
+		pts := l.pts
+		if len(pts) < 2 {
+			continue
+		}
+		if l.closed && len(pts) > 1 && pts[0] != pts[len(pts)-1] {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		for i := 1; i < len(pts); i++ {
+			a, b := pts[i-1], pts[i]
+			d := b.Sub(a)
+			if d.Len() == 0 {
+				continue
+			}
+			n := d.Unit().Perp().Scale(r)
+			s.polygon([]geom.Vec2{a.Add(n), b.Add(n), b.Sub(n), a.Sub(n)})
+		}
+		switch {
+		case discs:
+			for _, p := range pts {
+				s.disc(p, r)
+			}
+		case len(pts) == 2 && pts[0] == pts[1]:
+			// A dot, as round caps draw lines of no length.
+			s.disc(pts[0], r)
+		}
+	}
+}
+
+// disc adds a circle wound like the segments of strokes.
+func (s *scanner) disc(c geom.Vec2, r float64) {
+	n := min(max(int(math.Ceil(math.Pi*r)), 8), 256)
+	pts := make([]geom.Vec2, n)
+	for i := range pts {
+		a := -2 * math.Pi * float64(i) / float64(n)
+		pts[i] = geom.Vec2{X: c.X + r*math.Cos(a), Y: c.Y + r*math.Sin(a)}
+	}
+	s.polygon(pts)
+}
+
+// glyph adds the outline of a glyph; m maps em units to pixels.
+func (s *scanner) glyph(contours [][]font.OutlinePoint, m func(geom.Vec2) geom.Vec2) {
+	for _, c := range contours {
+		if len(c) < 2 {
+			continue
+		}
+		// Start on the curve, at an implied point if no point is on it.
+		var start geom.Vec2
+		var rest []font.OutlinePoint
+		if i := slices.IndexFunc(c, func(p font.OutlinePoint) bool { return p.On }); i >= 0 {
+			start = c[i].Vec2
+			rest = append(slices.Clone(c[i+1:]), c[:i]...)
+		} else {
+			start = c[0].Lerp(c[1].Vec2, 0.5)
+			rest = append(slices.Clone(c[1:]), c[0])
+		}
+		rest = append(rest, font.OutlinePoint{Vec2: start, On: true})
+		pts := []geom.Vec2{m(start)}
+		cur := start
+		var ctrl *geom.Vec2
+		for _, p := range rest {
+			if !p.On {
+				if ctrl != nil {
+					mid := ctrl.Lerp(p.Vec2, 0.5)
+					pts = quad(pts, m(cur), m(*ctrl), m(mid))
+					cur = mid
+				}
+				v := p.Vec2
+				ctrl = &v
+				continue
+			}
+			if ctrl != nil {
+				pts = quad(pts, m(cur), m(*ctrl), m(p.Vec2))
+				ctrl = nil
+			} else {
+				pts = append(pts, m(p.Vec2))
+			}
+			cur = p.Vec2
+		}
+		s.polygon(pts)
+	}
+}
+
+// quad appends the points of a quadratic Bézier curve as a cubic one.
+func quad(out []geom.Vec2, p0, p1, p2 geom.Vec2) []geom.Vec2 {
+	c1 := p0.Add(p1.Sub(p0).Scale(2.0 / 3))
+	c2 := p2.Add(p1.Sub(p2).Scale(2.0 / 3))
+	return cubic(out, p0, c1, c2, p2)
+}
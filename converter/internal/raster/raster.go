@@ -0,0 +1,302 @@
+// Package raster draws sheets as raster images, filling paths, strokes,
+// glyph outlines, gradients and images with a scanline rasterizer of its
+// own.
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/bitmap"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/render"
+)
+
+type Options struct {
+	// Width and Height are the size of the image in pixels, which the
+	// sheet is stretched to.
+	Width, Height int
+	// Background is the color the sheet is drawn on; it is transparent
+	// when its alpha is zero.
+	Background color.NRGBA
+	// Antialias smooths edges by the part of each pixel they cover.
+	// Without it pixels are either painted or not.
+	Antialias bool
+}
+
+// Draw draws the items of a sheet. Lines are at least a pixel wide, and
+// text without a font is left out, as there are no glyphs to draw it
+// with.
+func Draw(s *render.Sheet, opts Options) *image.RGBA {
+	w, h := max(opts.Width, 1), max(opts.Height, 1)
+	c := &canvas{
+		img:     image.NewRGBA(image.Rect(0, 0, w, h)),
+		height:  s.Height,
+		sx:      float64(w) / s.Width,
+		sy:      float64(h) / s.Height,
+		scan:    newScanner(w, h, opts.Antialias),
+		bitmaps: make(map[*bitmap.Image]*image.NRGBA),
+	}
+	if opts.Background.A > 0 {
+		draw.Draw(c.img, c.img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	for i := range s.Items {
+		c.item(&s.Items[i])
+	}
+	return c.img
+}
+
+type canvas struct {
+	img *image.RGBA
+	// height is the height of the sheet in points, and sx and sy the
+	// pixels per point.
+	height, sx, sy float64
+	scan           *scanner
+	// mask is the coverage of clip, nil when items are not clipped.
+	clip    *render.Path
+	mask    []uint8
+	bitmaps map[*bitmap.Image]*image.NRGBA
+}
+
+// pixel maps a point of the sheet to the image, whose origin is at the
+// top left.
+func (c *canvas) pixel(p geom.Vec2) geom.Vec2 {
+	return geom.Vec2{X: p.X * c.sx, Y: (c.height - p.Y) * c.sy}
+}
+
+// point maps the center of a pixel to the sheet.
+func (c *canvas) point(x, y int) geom.Vec2 {
+	return geom.Vec2{X: (float64(x) + 0.5) / c.sx, Y: c.height - (float64(y)+0.5)/c.sy}
+}
+
+func (c *canvas) item(it *render.Item) {
+	c.setClip(it.Clip)
+	switch {
+	case it.Text != nil:
+		if it.Text.Invisible || it.Text.Font == nil {
+			return
+		}
+		c.text(it.Text)
+		c.paint(false, solid(it.Color))
+	case it.Gradient != nil:
+		c.scan.fillPath(flatten(&it.Path, c.pixel))
+		c.paint(it.EvenOdd, c.gradient(it.Gradient))
+	case it.Image != nil:
+		c.image(it)
+	case it.Fill:
+		c.scan.fillPath(flatten(&it.Path, c.pixel))
+		c.paint(it.EvenOdd, solid(it.Color))
+	default:
+		width := it.Width * (c.sx + c.sy) / 2
+		c.scan.stroke(flatten(&it.Path, c.pixel), max(width, 1))
+		c.paint(false, solid(it.Color))
+	}
+}
+
+// setClip computes the coverage of the clipping path of the items that
+// follow, when it changes.
+func (c *canvas) setClip(p *render.Path) {
+	if p == c.clip {
+		return
+	}
+	c.clip = p
+	if p == nil {
+		c.mask = nil
+		return
+	}
+	b := c.img.Bounds()
+	if c.mask == nil {
+		c.mask = make([]uint8, b.Dx()*b.Dy())
+	} else {
+		clear(c.mask)
+	}
+	c.scan.fillPath(flatten(p, c.pixel))
+	c.scan.fill(false, func(x, y int, coverage []float32) {
+		row := c.mask[y*b.Dx()+x:]
+		for i, v := range coverage {
+			row[i] = alpha(v)
+		}
+	})
+}
+
+// text adds the glyphs of a line of text, placed one after the other by
+// their advances.
+func (c *canvas) text(t *render.Text) {
+	f := t.Font
+	var x float64
+	for _, r := range t.Value {
+		g, _ := f.Glyph(r)
+		offset := x
+		c.scan.glyph(f.Outline(g), func(p geom.Vec2) geom.Vec2 {
+			return c.pixel(t.Matrix.Apply2(geom.Vec2{X: offset + p.X/f.CapHeight, Y: p.Y / f.CapHeight}))
+		})
+		x += f.Advance(g) / f.CapHeight
+	}
+}
+
+// source gives the premultiplied color of a pixel.
+type source func(x, y int) color.RGBA
+
+func solid(c render.RGB) source {
+	v := color.RGBA{c.R, c.G, c.B, 0xFF}
+	return func(int, int) color.RGBA { return v }
+}
+
+// paint fills what was added to the scanner with the colors of src,
+// within the clipping path.
+func (c *canvas) paint(evenOdd bool, src source) {
+	stride := c.img.Bounds().Dx()
+	c.scan.fill(evenOdd, func(x, y int, coverage []float32) {
+		pix := c.img.Pix[y*c.img.Stride+4*x:]
+		for i, v := range coverage {
+			a := uint32(alpha(v))
+			if c.mask != nil {
+				a = a * uint32(c.mask[y*stride+x+i]) / 0xFF
+			}
+			if a == 0 {
+				continue
+			}
+			s := src(x+i, y)
+			sa := uint32(s.A) * a / 0xFF
+			p := pix[4*i : 4*i+4 : 4*i+4]
+			p[0] = uint8((uint32(s.R)*a + uint32(p[0])*(0xFF-sa)) / 0xFF)
+			p[1] = uint8((uint32(s.G)*a + uint32(p[1])*(0xFF-sa)) / 0xFF)
+			p[2] = uint8((uint32(s.B)*a + uint32(p[2])*(0xFF-sa)) / 0xFF)
+			p[3] = uint8((uint32(s.A)*a + uint32(p[3])*(0xFF-sa)) / 0xFF)
+		}
+	})
+}
+
+// alpha converts a coverage to 8 bits.
+func alpha(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	return uint8(min(v, 1)*0xFF + 0.5)
+}
+
+// gradient colors pixels by where they fall on the axis of a linear
+// gradient, or on the circles of a radial one; the end colors extend
+// beyond them.
+func (c *canvas) gradient(g *render.Gradient) source {
+	return func(x, y int) color.RGBA {
+		p := c.point(x, y)
+		var t float64
+		if g.Radial {
+			t = radial(g, p)
+		} else {
+			axis := g.To.Sub(g.From)
+			if l := axis.Dot(axis); l > 0 {
+				t = p.Sub(g.From).Dot(axis) / l
+			}
+		}
+		v := stopColor(g.Stops, min(max(t, 0), 1))
+		return color.RGBA{v.R, v.G, v.B, 0xFF}
+	}
+}
+
+// radial finds the largest t whose circle, between the start and end
+// circles, passes through p.
+func radial(g *render.Gradient, p geom.Vec2) float64 {
+	cd := g.To.Sub(g.From)
+	pd := p.Sub(g.From)
+	dr := g.R1 - g.R0
+	a := cd.Dot(cd) - dr*dr
+	b := pd.Dot(cd) + g.R0*dr
+	cc := pd.Dot(pd) - g.R0*g.R0
+	if math.Abs(a) < 1e-9 {
+		if b == 0 {
+			return 0
+		}
+		return cc / (2 * b)
+	}
+	disc := b*b - a*cc
+	if disc < 0 {
+		return 0
+	}
+	t := (b + math.Sqrt(disc)) / a
+	if g.R0+t*dr < 0 {
+		t = (b - math.Sqrt(disc)) / a
+	}
+	return t
+}
+
+func stopColor(stops []render.Stop, t float64) render.RGB {
+	if len(stops) == 0 {
+		return render.RGB{}
+	}
+	if t <= stops[0].Offset {
+		return stops[0].Color
+	}
+	for i := 1; i < len(stops); i++ {
+		a, b := stops[i-1], stops[i]
+		if t > b.Offset {
+			continue
+		}
+		f := 0.0
+		if b.Offset > a.Offset {
+			f = (t - a.Offset) / (b.Offset - a.Offset)
+		}
+		mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f + 0.5) }
+		return render.RGB{R: mix(a.Color.R, b.Color.R), G: mix(a.Color.G, b.Color.G), B: mix(a.Color.B, b.Color.B)}
+	}
+	return stops[len(stops)-1].Color
+}
+
+// image draws an image clipped to the path of its item, sampling the
+// pixel of the image under each pixel. Bilevel images are drawn in the
+// item color, over white unless they are transparent; other images are
+// blended onto white unless they are transparent.
+func (c *canvas) image(it *render.Item) {
+	img := it.Image
+	lines := flatten(&it.Path, c.pixel)
+	if img.Bitmap.Bilevel && !img.Transparent {
+		c.scan.fillPath(lines)
+		c.paint(it.EvenOdd, solid(render.RGB{R: 0xFF, G: 0xFF, B: 0xFF}))
+	}
+	m := img.Matrix
+	det := m.Det2()
+	if det == 0 {
+		return
+	}
+	pix := c.pixels(img.Bitmap)
+	w, h := pix.Bounds().Dx(), pix.Bounds().Dy()
+	ink := color.RGBA{it.Color.R, it.Color.G, it.Color.B, 0xFF}
+	c.scan.fillPath(lines)
+	c.paint(it.EvenOdd, func(x, y int) color.RGBA {
+		p := c.point(x, y)
+		dx, dy := p.X-m[3], p.Y-m[7]
+		u, v := (m[5]*dx-m[1]*dy)/det, (m[0]*dy-m[4]*dx)/det
+		col, row := int(math.Floor(u*float64(w))), int(math.Floor((1-v)*float64(h)))
+		if col < 0 || row < 0 || col >= w || row >= h {
+			return color.RGBA{}
+		}
+		px := pix.NRGBAAt(col, row)
+		if img.Bitmap.Bilevel {
+			if px.R < 0x80 {
+				return ink
+			}
+			return color.RGBA{}
+		}
+		if !img.Transparent {
+			over := func(v uint8) uint8 { return uint8((int(v)*int(px.A) + 0xFF*(0xFF-int(px.A)) + 0x7F) / 0xFF) }
+			return color.RGBA{over(px.R), over(px.G), over(px.B), 0xFF}
+		}
+		pre := func(v uint8) uint8 { return uint8((int(v)*int(px.A) + 0x7F) / 0xFF) }
+		return color.RGBA{pre(px.R), pre(px.G), pre(px.B), px.A}
+	})
+}
+
+// pixels converts a bitmap to NRGBA once, for sampling.
+func (c *canvas) pixels(bm *bitmap.Image) *image.NRGBA {
+	if p, ok := c.bitmaps[bm]; ok {
+		return p
+	}
+	b := bm.Pixels.Bounds()
+	p := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(p, p.Bounds(), bm.Pixels, b.Min, draw.Src)
+	c.bitmaps[bm] = p
+	return p
+}
@@ -0,0 +1,188 @@
+package raster
+
+import (
+	"math"
+	"slices"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// subsamples is the number of scanlines sampled per row of pixels when
+// anti-aliasing. Coverage along the scanlines is exact.
+const subsamples = 8
+
+// edge is a polygon edge in pixels, from top to bottom. Dir is +1 for
+// edges that went down and -1 for those that went up, for the winding.
+type edge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+type crossing struct {
+	x   float64
+	dir int
+}
+
+// scanner fills polygons, computing how much of each pixel they cover.
+type scanner struct {
+	w, h      int
+	antialias bool
+	edges     []edge
+	active    []edge
+	crossings []crossing
+	// cover holds the coverage of pixels partly covered on a row, delta
+	// the changes of the coverage of pixels fully covered from one pixel
+	// to the next.
+	cover, delta []float32
+	row          []float32
+}
+
+func newScanner(w, h int, antialias bool) *scanner {
+	return &scanner{
+		w:         w,
+		h:         h,
+		antialias: antialias,
+		cover:     make([]float32, w+1),
+		delta:     make([]float32, w+2),
+		row:       make([]float32, w),
+	}
+}
+
+// polygon adds a closed polygon in pixels.
+func (s *scanner) polygon(pts []geom.Vec2) {
+	for i, a := range pts {
+		s.line(a, pts[(i+1)%len(pts)])
+	}
+}
+
+func (s *scanner) line(a, b geom.Vec2) {
+	if a.Y == b.Y || math.IsNaN(a.X+a.Y+b.X+b.Y) || math.IsInf(a.X+a.Y+b.X+b.Y, 0) {
+		return
+	}
+	e := edge{x0: a.X, y0: a.Y, x1: b.X, y1: b.Y, dir: 1}
+	if a.Y > b.Y {
+		e = edge{x0: b.X, y0: b.Y, x1: a.X, y1: a.Y, dir: -1}
+	}
+	s.edges = append(s.edges, e)
+}
+
+// fill fills the polygons added since the last fill by the nonzero or
+// the even-odd rule and calls span for each row with the coverage of
+// the pixels from x on, from 0 to 1. The coverage slice is reused.
+func (s *scanner) fill(evenOdd bool, span func(x, y int, coverage []float32)) {
+	defer func() { s.edges = s.edges[:0] }()
+	if len(s.edges) == 0 {
+		return
+	}
+	slices.SortFunc(s.edges, func(a, b edge) int {
+		switch {
+		case a.y0 < b.y0:
+			return -1
+		case a.y0 > b.y0:
+			return 1
+		}
+		return 0
+	})
+	top := max(0, int(math.Floor(s.edges[0].y0)))
+	bottom := 0.0
+	for _, e := range s.edges {
+		bottom = max(bottom, e.y1)
+	}
+	end := min(s.h, int(math.Ceil(bottom)))
+
+	samples, weight := 1, float32(1)
+	if s.antialias {
+		samples, weight = subsamples, 1/float32(subsamples)
+	}
+	s.active = s.active[:0]
+	next := 0
+	for y := top; y < end; y++ {
+		minX, maxX := s.w, -1
+		for k := range samples {
+			sy := float64(y) + (float64(k)+0.5)/float64(samples)
+			for next < len(s.edges) && s.edges[next].y0 <= sy {
+				s.active = append(s.active, s.edges[next])
+				next++
+			}
+			s.active = slices.DeleteFunc(s.active, func(e edge) bool { return e.y1 <= sy })
+			s.crossings = s.crossings[:0]
+			for _, e := range s.active {
+				x := e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+				s.crossings = append(s.crossings, crossing{x, e.dir})
+			}
+			slices.SortFunc(s.crossings, func(a, b crossing) int {
+				switch {
+				case a.x < b.x:
+					return -1
+				case a.x > b.x:
+					return 1
+				}
+				return 0
+			})
+			winding := 0
+			for i, c := range s.crossings {
+				winding += c.dir
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if !inside || i+1 == len(s.crossings) {
+					continue
+				}
+				x0, x1 := s.span(c.x, s.crossings[i+1].x, weight)
+				minX, maxX = min(minX, x0), max(maxX, x1)
+			}
+		}
+		if minX > maxX {
+			continue
+		}
+		s.emit(y, minX, maxX, span)
+	}
+}
+
+// span adds the coverage of a span of a scanline and returns the first
+// and last pixel it touches.
+func (s *scanner) span(xa, xb float64, weight float32) (int, int) {
+	xa, xb = max(xa, 0), min(xb, float64(s.w))
+	if xb <= xa {
+		return s.w, -1
+	}
+	if !s.antialias {
+		// Pixels whose centers are in the span are covered.
+		i0, i1 := int(math.Ceil(xa-0.5)), int(math.Ceil(xb-0.5))
+		if i1 <= i0 {
+			return s.w, -1
+		}
+		s.delta[i0] += weight
+		s.delta[i1] -= weight
+		return i0, i1 - 1
+	}
+	i0, i1 := int(xa), int(xb)
+	if i0 == i1 {
+		s.cover[i0] += weight * float32(xb-xa)
+		return i0, i0
+	}
+	s.cover[i0] += weight * float32(float64(i0+1)-xa)
+	s.delta[i0+1] += weight
+	s.delta[i1] -= weight
+	if i1 < s.w {
+		s.cover[i1] += weight * float32(xb-float64(i1))
+		return i0, i1
+	}
+	return i0, i1 - 1
+}
+
+// emit sums the coverage of a row, clears it for the next one and hands
+// it to span.
+func (s *scanner) emit(y, minX, maxX int, span func(x, y int, coverage []float32)) {
+	row := s.row[minX : maxX+1]
+	var run float32
+	for i := range row {
+		x := minX + i
+		run += s.delta[x]
+		row[i] = min(s.cover[x]+run, 1)
+		s.cover[x], s.delta[x] = 0, 0
+	}
+	s.delta[maxX+1] = 0
+	span(minX, y, row)
+}
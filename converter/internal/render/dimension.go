@@ -0,0 +1,875 @@
+package render
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// dimension draws the anonymous block that holds the graphics of a
+// dimension, in WCS. Without one the graphics are regenerated from the
+// definition points and the dimension style.
+func (r *renderer) dimension(e *drawing.Dimension, s scope, st style) {
+	inner := scope{
+		m:          s.m,
+		layer:      st.layer,
+		color:      st.color,
+		lineweight: st.lineweight,
+		linetype:   st.linetype,
+		plotStyle:  st.plotStyle,
+	}
+	b := r.d.Block(e.Block)
+	if b == nil || len(b.Entities) == 0 {
+		b = &drawing.Block{Name: e.Block, Entities: r.regenerate(e)}
+		inner.m = ocs(s, e.Extrusion)
+	}
+	if len(s.blocks) >= maxNesting || slices.Contains(s.blocks, b) {
+		return
+	}
+	inner.blocks = append(s.blocks[:len(s.blocks):len(s.blocks)], b)
+	r.block(b, inner)
+}
+
+// dimStyle returns the style of a dimension with its overrides applied.
+func (r *renderer) dimStyle(e *drawing.Dimension) *drawing.DimStyle {
+	ds := r.d.DimStyle(e.Style)
+	if ds == nil {
+		ds = r.d.DimStyle("Standard")
+	}
+	if ds == nil {
+		ds = drawing.NewDimStyle("Standard")
+	}
+	c := *ds
+	for _, o := range e.Overrides {
+		c.Set(o.Code, o.Value)
+	}
+	return &c
+}
+
+// dimGen collects the entities of a regenerated dimension. They lie in
+// the dimension's OCS at elevation z, on layer 0 and ByBlock unless the
+// style gives them colors and lineweights of their own.
+type dimGen struct {
+	r    *renderer
+	e    *drawing.Dimension
+	ds   *drawing.DimStyle
+	z    float64
+	ents []drawing.Entity
+
+	// Sizes multiplied by DIMSCALE.
+	arrow, text, gap, exo, exe, tick, dle float64
+}
+
+// regenerate builds the graphics of a dimension that has no block.
+func (r *renderer) regenerate(e *drawing.Dimension) []drawing.Entity {
+	ds := r.dimStyle(e)
+	k := ds.Scale
+	if k <= 0 {
+		k = 1
+	}
+	g := &dimGen{
+		r:     r,
+		e:     e,
+		ds:    ds,
+		arrow: ds.ArrowSize * k,
+		text:  ds.TextHeight * k,
+		gap:   math.Abs(ds.Gap) * k,
+		exo:   ds.ExtOffset * k,
+		exe:   ds.ExtExtend * k,
+		tick:  ds.TickSize * k,
+		dle:   ds.LineExtend * k,
+	}
+	inv, _ := geom.OCS(e.Extrusion).Inverse()
+	pt := func(p geom.Vec3) geom.Vec2 { return inv.Apply(p).XY() }
+	g.z = inv.Apply(e.DefPoint).Z
+
+	switch e.Type {
+	case drawing.DimLinear, drawing.DimAligned:
+		g.linear(pt(e.DefPoint2), pt(e.DefPoint3), pt(e.DefPoint))
+	case drawing.DimAngular:
+		g.angular(pt(e.DefPoint2), pt(e.DefPoint3), pt(e.DefPoint4), pt(e.DefPoint), e.DefPoint5.XY())
+	case drawing.DimAngular3Point, drawing.DimArcLength:
+		g.angular3(pt(e.DefPoint4), pt(e.DefPoint2), pt(e.DefPoint3), pt(e.DefPoint))
+	case drawing.DimRadius:
+		g.radial(pt(e.DefPoint), pt(e.DefPoint4), false)
+	case drawing.DimDiameter:
+		p, q := pt(e.DefPoint4), pt(e.DefPoint)
+		g.radial(p.Lerp(q, 0.5), p, true)
+	case drawing.DimOrdinate:
+		g.ordinate(pt(e.DefPoint), pt(e.DefPoint2), pt(e.DefPoint3))
+	}
+	return g.ents
+}
+
+func (g *dimGen) linear(p1, p2, d geom.Vec2) {
+	dir := geom.Polar(g.e.Rotation, 1)
+	if g.e.Type == drawing.DimAligned && p2.Dist(p1) > 1e-12 {
+		dir = p2.Sub(p1).Unit()
+	}
+	ext := dir.Perp()
+	if o := geom.Polar(g.e.Oblique, 1); g.e.Oblique != 0 && math.Abs(o.Cross(dir)) > 1e-9 {
+		ext = o
+	}
+	a1, a2 := meet(p1, ext, d, dir), meet(p2, ext, d, dir)
+	if !g.ds.SuppressExt1 {
+		g.extension(p1, a1, ext)
+	}
+	if !g.ds.SuppressExt2 {
+		g.extension(p2, a2, ext)
+	}
+	n := a1.Dist(a2)
+	u := dir
+	if n > 1e-12 {
+		u = a2.Sub(a1).Scale(1 / n)
+	}
+	g.dimLine(track{a1: a1, u: u, n: n}, dimLabel(g.e, g.ds, n, false), p1.Lerp(p2, 0.5))
+}
+
+// meet returns where the line through p along u crosses the line through
+// q along v.
+func meet(p, u, q, v geom.Vec2) geom.Vec2 {
+	return p.Add(u.Scale(q.Sub(p).Cross(v) / u.Cross(v)))
+}
+
+// extension draws the extension line from the origin p to the point a on
+// the dimension line, offset from p and extended past a.
+func (g *dimGen) extension(p, a, dir geom.Vec2) {
+	u := dir
+	if a.Dist(p) > 1e-12 {
+		u = a.Sub(p).Unit()
+	}
+	g.line(p.Add(u.Scale(g.exo)), a.Add(u.Scale(g.exe)), true)
+}
+
+// angular draws the angle between the lines l1a-l1b and l2a-l2b, in the
+// quadrant that holds the arc point.
+func (g *dimGen) angular(l1a, l1b, l2a, l2b, arc geom.Vec2) {
+	d1, d2 := l1b.Sub(l1a), l2b.Sub(l2a)
+	if math.Abs(d1.Cross(d2)) < 1e-12 {
+		return
+	}
+	c := meet(l1a, d1, l2a, d2)
+	t := arc.Sub(c).Angle()
+	seg1, seg2 := [2]geom.Vec2{l1a, l1b}, [2]geom.Vec2{l2a, l2b}
+	for _, r1 := range []float64{d1.Angle(), d1.Angle() + math.Pi} {
+		for _, r2 := range []float64{d2.Angle(), d2.Angle() + math.Pi} {
+			sweep := geom.NormalizeAngle(r2 - r1)
+			switch {
+			case sweep < math.Pi && within(t, r1, sweep):
+				g.arcDim(c, arc.Dist(c), r1, sweep, seg1, seg2, 0)
+				return
+			case sweep > math.Pi && within(t, r2, 2*math.Pi-sweep):
+				g.arcDim(c, arc.Dist(c), r2, 2*math.Pi-sweep, seg2, seg1, 0)
+				return
+			}
+		}
+	}
+}
+
+// angular3 draws a 3-point angular or an arc length dimension around the
+// vertex or arc center c, between the directions to p1 and p2 that hold
+// the arc point.
+func (g *dimGen) angular3(c, p1, p2, arc geom.Vec2) {
+	a1, a2 := p1.Sub(c).Angle(), p2.Sub(c).Angle()
+	sweep := geom.NormalizeAngle(a2 - a1)
+	if !within(arc.Sub(c).Angle(), a1, sweep) {
+		a1, sweep = a2, 2*math.Pi-sweep
+		p1, p2 = p2, p1
+	}
+	length := 0.0
+	if g.e.Type == drawing.DimArcLength {
+		length = p1.Dist(c) * sweep
+		c1, c2 := [2]geom.Vec2{p1, p1}, [2]geom.Vec2{p2, p2}
+		g.arcDim(c, arc.Dist(c), a1, sweep, c1, c2, length)
+		return
+	}
+	g.arcDim(c, arc.Dist(c), a1, sweep, [2]geom.Vec2{c, p1}, [2]geom.Vec2{c, p2}, 0)
+}
+
+// within reports whether the angle a lies in the sweep from a0.
+func within(a, a0, sweep float64) bool {
+	return geom.NormalizeAngle(a-a0) <= sweep+1e-12
+}
+
+// arcDim draws a dimension arc around c from a0 by sweep, with extension
+// lines to the segments on its end rays. A non-zero length makes it an
+// arc length dimension; it otherwise measures the angle.
+func (g *dimGen) arcDim(c geom.Vec2, rad, a0, sweep float64, seg1, seg2 [2]geom.Vec2, length float64) {
+	if rad < 1e-12 {
+		return
+	}
+	if !g.ds.SuppressExt1 {
+		g.arcExtension(c, a0, rad, seg1)
+	}
+	if !g.ds.SuppressExt2 {
+		g.arcExtension(c, a0+sweep, rad, seg2)
+	}
+	k := track{c: c, rad: rad, a0: a0, n: rad * sweep, curved: true}
+	if length == 0 {
+		g.dimLine(k, dimLabel(g.e, g.ds, sweep, true), c)
+		return
+	}
+	label := dimLabel(g.e, g.ds, length, false)
+	if label != "" && g.ds.ArcSymbol == 0 {
+		// Room for the symbol before the text; leading spaces would be
+		// dropped.
+		label = `\~\~` + label
+	}
+	pos, ang, w, h := g.dimLine(k, label, c)
+	if label != "" {
+		g.arcSymbol(pos, ang, w, h)
+	}
+}
+
+// arcSymbol draws the arc length symbol at the start of the text centered
+// on pos, which leaves room for it, or above the text, as DIMARCSYM says.
+func (g *dimGen) arcSymbol(pos geom.Vec2, ang, w, h float64) {
+	x, y := geom.Polar(ang, 1), geom.Polar(ang+math.Pi/2, 1)
+	rad := 0.35 * g.text
+	var c geom.Vec2
+	switch g.ds.ArcSymbol {
+	case 0:
+		c = pos.Sub(x.Scale(w/2 - rad)).Sub(y.Scale(rad / 2))
+	case 1:
+		c = pos.Add(y.Scale(h/2 + g.text*0.1))
+	default:
+		return
+	}
+	g.ents = append(g.ents, &drawing.Arc{
+		EntityProps: g.props(g.ds.TextColor, g.ds.LineWeight),
+		Center:      c.Vec3(g.z),
+		Radius:      rad,
+		StartAngle:  ang,
+		EndAngle:    ang + math.Pi,
+		Extrusion:   geom.ZAxis,
+	})
+}
+
+// arcExtension draws the extension line along the ray from c at angle a
+// out to the dimension arc, from the nearer end of the segment on the
+// ray. No line is needed when the arc crosses the segment.
+func (g *dimGen) arcExtension(c geom.Vec2, a, rad float64, seg [2]geom.Vec2) {
+	u := geom.Polar(a, 1)
+	t0, t1 := seg[0].Sub(c).Dot(u), seg[1].Sub(c).Dot(u)
+	if t0 > t1 {
+		t0, t1 = t1, t0
+	}
+	switch {
+	case rad > t1:
+		g.line(c.Add(u.Scale(t1+g.exo)), c.Add(u.Scale(rad+g.exe)), true)
+	case rad < t0:
+		g.line(c.Add(u.Scale(t0-g.exo)), c.Add(u.Scale(rad-g.exe)), true)
+	}
+}
+
+// track is the path of a dimension line of length n: straight from a1
+// along u, or an arc around c with radius rad from the angle a0. Positions
+// on it are distances from its start.
+type track struct {
+	a1, u   geom.Vec2
+	c       geom.Vec2
+	rad, a0 float64
+	n       float64
+	curved  bool
+}
+
+func (k track) at(t float64) geom.Vec2 {
+	if k.curved {
+		return k.c.Add(geom.Polar(k.a0+t/k.rad, k.rad))
+	}
+	return k.a1.Add(k.u.Scale(t))
+}
+
+// dir returns the direction of the track at t.
+func (k track) dir(t float64) geom.Vec2 {
+	if k.curved {
+		return geom.Polar(k.a0+t/k.rad+math.Pi/2, 1)
+	}
+	return k.u
+}
+
+// locate returns the position on the track nearest to p and the distance
+// of p from it.
+func (k track) locate(p geom.Vec2) (t, off float64) {
+	if k.curved {
+		d := p.Sub(k.c)
+		a := geom.NormalizeAngle(d.Angle() - k.a0)
+		// Angles beyond the far end that are nearer to the start are
+		// before it.
+		if a > (k.n/k.rad+2*math.Pi)/2 {
+			a -= 2 * math.Pi
+		}
+		return a * k.rad, d.Len() - k.rad
+	}
+	d := p.Sub(k.a1)
+	return d.Dot(k.u), k.u.Cross(d)
+}
+
+// dimLine draws a dimension line with its arrowheads and text. Both go
+// between the extension lines when there is room, and the line is broken
+// where the text sits on it. side is a point on the side of the line that
+// DIMTAD 2 keeps the text away from. It returns where the text went, its
+// rotation and its size.
+func (g *dimGen) dimLine(k track, label string, side geom.Vec2) (pos geom.Vec2, ang, w, h float64) {
+	ds, n := g.ds, k.n
+	w, h = g.textSize(label)
+	ticks := g.ticks()
+	arrows := 2 * g.arrow
+	if ticks {
+		arrows = 0
+	}
+	room := 0.0
+	if label != "" {
+		room = w + 2*g.gap
+	}
+	arrowsIn, textIn := g.fit(n, arrows, room)
+
+	user := g.e.Flags&drawing.DimUserText != 0
+	pos = g.e.TextMidPoint.XY()
+	if user {
+		t, _ := k.locate(pos)
+		textIn = t >= 0 && t <= n
+	}
+	at := n / 2
+	if !textIn {
+		at = n
+	}
+	ang = g.textAngle(k.dir(at).Angle(), textIn)
+	hx := halfExtent(w, h, ang-k.dir(at).Angle())
+	hy := halfExtent(h, w, ang-k.dir(at).Angle())
+	if !user {
+		pos = k.at(n / 2)
+		if !textIn {
+			out := hx + g.gap
+			if !arrowsIn {
+				out += arrows
+			}
+			pos = k.at(n).Add(k.dir(n).Scale(out))
+		}
+		pos = pos.Add(g.raise(ang, k.dir(at), h, side, pos))
+	}
+
+	ext := 0.0
+	if ticks {
+		ext = g.dle
+	}
+	var segs [][2]float64
+	if arrowsIn || ds.LineInside {
+		segs = append(segs, [2]float64{-ext, n + ext})
+	}
+	if !arrowsIn && !ds.SuppressOut {
+		segs = append(segs, [2]float64{-arrows, 0}, [2]float64{n, n + arrows})
+	}
+	if label != "" {
+		t, off := k.locate(pos)
+		// Text outside runs on an extension of the line, and the line is
+		// broken where the text sits on it.
+		if !textIn && math.Abs(off) < hy+h+2*g.gap {
+			if t > n {
+				segs = append(segs, [2]float64{n, t + hx})
+			} else if t < 0 {
+				segs = append(segs, [2]float64{t - hx, 0})
+			}
+		}
+		if math.Abs(off) < hy {
+			segs = cut(segs, t-hx-g.gap, t+hx+g.gap)
+		}
+	}
+	if ds.SuppressLine1 {
+		segs = cut(segs, math.Inf(-1), n/2)
+	}
+	if ds.SuppressLine2 {
+		segs = cut(segs, n/2, math.Inf(1))
+	}
+	for _, s := range segs {
+		g.trace(k, s[0], s[1])
+	}
+
+	first, second := g.arrowNames()
+	d0, d1 := k.dir(0).Angle(), k.dir(n).Angle()
+	if !arrowsIn {
+		d0, d1 = d0+math.Pi, d1+math.Pi
+	}
+	if !ds.SuppressLine1 {
+		g.arrowhead(first, k.at(0), d0+math.Pi)
+	}
+	if !ds.SuppressLine2 {
+		g.arrowhead(second, k.at(n), d1)
+	}
+	g.label(label, pos, ang)
+	return pos, ang, w, h
+}
+
+// fit decides whether the arrowheads and the text, which need the room
+// given, go between extension lines n apart, moving them out as DIMATFIT
+// and DIMTIX say.
+func (g *dimGen) fit(n, arrows, text float64) (arrowsIn, textIn bool) {
+	switch {
+	case n >= arrows+text:
+		return true, true
+	case g.ds.TextInside:
+		return false, true
+	case g.ds.Fit == 0:
+		return false, false
+	case g.ds.Fit == 1 && n >= text:
+		return false, true
+	case g.ds.Fit != 1 && n >= arrows:
+		return true, false
+	case n >= text:
+		return false, true
+	}
+	return false, false
+}
+
+// textAngle returns the rotation of text along a line in direction a:
+// aligned with it and readable from the bottom or the right, or
+// horizontal as DIMTIH and DIMTOH ask.
+func (g *dimGen) textAngle(a float64, inside bool) float64 {
+	switch {
+	case g.e.TextRotation != 0:
+		return g.e.TextRotation
+	case inside && g.ds.InsideHoriz, !inside && g.ds.OutsideHoriz:
+		return 0
+	}
+	return readable(a)
+}
+
+// readable turns a text direction by half a turn when text along it would
+// be upside down, so that it lies in (-90°, 90°].
+func readable(a float64) float64 {
+	a = geom.NormalizeAngle(a)
+	switch {
+	case a > 3*math.Pi/2+1e-9:
+		return a - 2*math.Pi
+	case a > math.Pi/2+1e-9:
+		return a - math.Pi
+	}
+	return a
+}
+
+// raise returns the offset of text at angle ang from a dimension line in
+// direction u, for DIMTAD and DIMTVP. Text that is not aligned with the
+// line stays centered on it.
+func (g *dimGen) raise(ang float64, u geom.Vec2, h float64, side, at geom.Vec2) geom.Vec2 {
+	if math.Abs(math.Sin(ang-u.Angle())) > 1e-6 {
+		return geom.Vec2{}
+	}
+	up := geom.Polar(ang+math.Pi/2, 1)
+	off := h/2 + g.gap
+	switch g.ds.TextAbove {
+	case 0:
+		off = g.ds.TextVertPos * g.text
+	case 2:
+		if side.Sub(at).Dot(up) > 0 {
+			off = -off
+		}
+	case 4:
+		off = -off
+	}
+	return up.Scale(off)
+}
+
+// halfExtent returns half the extent along a direction of a w×h box
+// turned by a against it.
+func halfExtent(w, h, a float64) float64 {
+	return math.Abs(w/2*math.Cos(a)) + math.Abs(h/2*math.Sin(a))
+}
+
+// cut removes the interval from t0 to t1 from the segments.
+func cut(segs [][2]float64, t0, t1 float64) [][2]float64 {
+	var out [][2]float64
+	for _, s := range segs {
+		if s[0] < t0 {
+			out = append(out, [2]float64{s[0], min(s[1], t0)})
+		}
+		if s[1] > t1 {
+			out = append(out, [2]float64{max(s[0], t1), s[1]})
+		}
+	}
+	return out
+}
+
+// radial draws a radius or diameter dimension of the curve around c
+// through p. With the text inside the line runs from the center, or
+// across the diameter; outside, a leader runs from p to the text.
+func (g *dimGen) radial(c, p geom.Vec2, diameter bool) {
+	ds := g.ds
+	rad := p.Dist(c)
+	u := geom.Vec2{X: 1}
+	if rad > 1e-12 {
+		u = p.Sub(c).Scale(1 / rad)
+	}
+	m := rad
+	if diameter {
+		m = 2 * rad
+	}
+	label := dimLabel(g.e, ds, m, false)
+	w, h := g.textSize(label)
+	first, _ := g.arrowNames()
+
+	inside := ds.TextInside
+	pos := g.e.TextMidPoint.XY()
+	user := g.e.Flags&drawing.DimUserText != 0
+	if user {
+		inside = pos.Dist(c) < rad
+	}
+	ang := g.textAngle(u.Angle(), inside)
+	hx := halfExtent(w, h, ang-u.Angle())
+	from := c
+	if diameter {
+		from = c.Sub(p.Sub(c))
+	}
+	if inside {
+		if !user {
+			pos = from.Lerp(p, 0.5)
+		}
+		k := track{a1: from, u: u, n: from.Dist(p)}
+		t, off := k.locate(pos)
+		segs := [][2]float64{{0, k.n}}
+		if math.Abs(off) < halfExtent(h, w, ang-u.Angle()) {
+			segs = cut(segs, t-hx-g.gap, t+hx+g.gap)
+		}
+		for _, s := range segs {
+			g.trace(k, s[0], s[1])
+		}
+		g.arrowhead(first, p, u.Angle())
+		if diameter {
+			g.arrowhead(first, from, u.Angle()+math.Pi)
+		}
+		g.label(label, pos, ang)
+		return
+	}
+
+	// A leader runs out from p along the radius, and on to the side of
+	// the text that faces it. Text that is not aligned with the radius
+	// sits beside a horizontal landing.
+	aligned := math.Abs(math.Sin(ang-u.Angle())) < 1e-6
+	elbow := p
+	if !user {
+		elbow = p.Add(u.Scale(2 * g.arrow))
+		pos = elbow.Add(u.Scale(g.gap + hx))
+		if !aligned {
+			x := geom.Polar(ang, 1)
+			if u.Dot(x) < 0 {
+				x = x.Scale(-1)
+			}
+			pos = elbow.Add(x.Scale(g.arrow + g.gap + w/2))
+		}
+	}
+	end := pos.Sub(pos.Sub(p).Unit().Scale(hx + g.gap))
+	if !aligned {
+		dx := w/2 + g.gap
+		if pos.Sub(p).Dot(geom.Polar(ang, 1)) < 0 {
+			dx = -dx
+		}
+		end = pos.Sub(geom.Polar(ang, dx))
+	}
+	if aligned {
+		elbow = end
+	}
+	toward := elbow
+	if elbow.Dist(p) < 1e-12 {
+		toward = end
+	}
+	g.line(p, elbow, false)
+	g.line(elbow, end, false)
+	g.arrowhead(first, p, p.Sub(toward).Angle())
+	if ds.LineInside {
+		g.line(from, p, false)
+		if diameter {
+			g.arrowhead(first, from, u.Angle()+math.Pi)
+		}
+	} else {
+		g.centerMark(c, rad)
+	}
+	g.label(label, pos, ang)
+}
+
+// centerMark draws the DIMCEN mark at the center of a circle or arc, with
+// center lines out past the curve when DIMCEN is negative.
+func (g *dimGen) centerMark(c geom.Vec2, rad float64) {
+	k := g.ds.Scale
+	if k <= 0 {
+		k = 1
+	}
+	m := g.ds.CenterMark * k
+	s := math.Abs(m)
+	if s == 0 {
+		return
+	}
+	for _, d := range []geom.Vec2{{X: 1}, {Y: 1}} {
+		g.line(c.Sub(d.Scale(s)), c.Add(d.Scale(s)), false)
+		if m < 0 && rad > 2*s {
+			g.line(c.Add(d.Scale(2*s)), c.Add(d.Scale(rad+s)), false)
+			g.line(c.Sub(d.Scale(2*s)), c.Sub(d.Scale(rad+s)), false)
+		}
+	}
+}
+
+// ordinate draws the leader from a feature to the end point l, with a jog
+// when l is off the feature's axis, and the X or Y distance from the
+// origin o.
+func (g *dimGen) ordinate(o, f, l geom.Vec2) {
+	var a geom.Vec2
+	var m float64
+	if g.e.Flags&drawing.DimOrdinateX != 0 {
+		m, a = f.X-o.X, geom.Vec2{Y: 1}
+	} else {
+		m, a = f.Y-o.Y, geom.Vec2{X: 1}
+	}
+	if l.Sub(f).Dot(a) < 0 {
+		a = a.Scale(-1)
+	}
+	label := dimLabel(g.e, g.ds, math.Abs(m), false)
+
+	along := l.Sub(f).Dot(a)
+	jog := l.Sub(f.Add(a.Scale(along)))
+	start := f.Add(a.Scale(g.exo))
+	if jog.Len() > 1e-9 && along > 2*g.arrow {
+		j1 := f.Add(a.Scale(along - 2*g.arrow))
+		j2 := j1.Add(jog).Add(a.Scale(g.arrow))
+		g.line(start, j1, true)
+		g.line(j1, j2, true)
+		g.line(j2, l, true)
+	} else {
+		g.line(start, l, true)
+	}
+
+	w, h := g.textSize(label)
+	ang := readable(a.Angle())
+	if g.e.TextRotation != 0 {
+		ang = g.e.TextRotation
+	}
+	pos := g.e.TextMidPoint.XY()
+	if g.e.Flags&drawing.DimUserText == 0 {
+		pos = l.Add(a.Scale(g.gap + halfExtent(w, h, ang-a.Angle())))
+	}
+	g.label(label, pos, ang)
+}
+
+// ticks reports whether the dimension line ends in ticks rather than
+// arrowheads; it then needs no room for them and extends by DIMDLE.
+func (g *dimGen) ticks() bool {
+	if g.tick > 0 {
+		return true
+	}
+	first, second := g.arrowNames()
+	return isTick(first) && isTick(second)
+}
+
+func isTick(name string) bool {
+	switch arrowKey(name) {
+	case "OBLIQUE", "ARCHTICK", "INTEGRAL", "NONE":
+		return true
+	}
+	return false
+}
+
+func arrowKey(name string) string {
+	return strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(name), "_"))
+}
+
+func (g *dimGen) arrowNames() (first, second string) {
+	if g.ds.SeparateArrow {
+		return g.ds.Arrow1, g.ds.Arrow2
+	}
+	return g.ds.Arrow, g.ds.Arrow
+}
+
+// arrowhead draws the arrowhead called name with its tip at tip, pointing
+// at the angle a. A block of that name takes precedence over the built-in
+// shapes, which like the standard blocks are one unit long and point
+// along +X to the origin.
+func (g *dimGen) arrowhead(name string, tip geom.Vec2, a float64) {
+	size := g.arrow
+	if g.tick > 0 {
+		name, size = "_OBLIQUE", g.tick
+	}
+	p := g.props(g.ds.LineColor, g.ds.LineWeight)
+	if b := g.r.d.Block(name); name != "" && b != nil && len(b.Entities) > 0 {
+		g.ents = append(g.ents, &drawing.Insert{
+			EntityProps: p,
+			Block:       b.Name,
+			Position:    tip.Vec3(g.z),
+			Scale:       geom.Vec3{X: size, Y: size, Z: size},
+			Rotation:    a,
+			Extrusion:   geom.ZAxis,
+			Columns:     1,
+			Rows:        1,
+		})
+		return
+	}
+
+	m := geom.Translate(tip.Vec3(g.z)).Mul(geom.RotateZ(a)).Mul(geom.Scale(geom.Vec3{X: size, Y: size, Z: 1}))
+	at := func(x, y float64) geom.Vec3 { return m.Apply(geom.Vec3{X: x, Y: y}) }
+	lines := func(pts ...geom.Vec3) {
+		for i := 1; i < len(pts); i++ {
+			g.ents = append(g.ents, &drawing.Line{EntityProps: p, Start: pts[i-1], End: pts[i], Extrusion: geom.ZAxis})
+		}
+	}
+	solid := func(a, b, c, d geom.Vec3) {
+		g.ents = append(g.ents, &drawing.Solid{EntityProps: p, Corners: [4]geom.Vec3{a, b, d, c}, Extrusion: geom.ZAxis})
+	}
+	circle := func(r float64) {
+		g.ents = append(g.ents, &drawing.Circle{EntityProps: p, Center: at(0, 0), Radius: r * size, Extrusion: geom.ZAxis})
+	}
+	disk := func(r float64) {
+		c := at(0, 0).XY()
+		g.ents = append(g.ents, &drawing.Hatch{
+			EntityProps: p,
+			Pattern:     "SOLID",
+			Solid:       true,
+			Scale:       1,
+			Elevation:   g.z,
+			Extrusion:   geom.ZAxis,
+			Loops: []drawing.HatchLoop{{
+				Flags: drawing.HatchLoopExternal,
+				Edges: []drawing.HatchEdge{{Type: drawing.EdgeArc, Center: c, Radius: r * size, EndAngle: 2 * math.Pi, CCW: true}},
+			}},
+		})
+	}
+
+	const w = 1.0 / 6
+	switch arrowKey(name) {
+	case "NONE":
+	case "OBLIQUE":
+		lines(at(-0.5, -0.5), at(0.5, 0.5))
+	case "ARCHTICK":
+		width := 0.15 * size
+		g.ents = append(g.ents, &drawing.LWPolyline{
+			EntityProps: p,
+			Vertices: []drawing.Vertex{
+				{Position: at(-0.5, -0.5), StartWidth: width, EndWidth: width},
+				{Position: at(0.5, 0.5), StartWidth: width, EndWidth: width},
+			},
+			Elevation: g.z,
+			Extrusion: geom.ZAxis,
+		})
+	case "OPEN":
+		lines(at(-1, w), at(0, 0), at(-1, -w))
+	case "OPEN90":
+		lines(at(-0.5, 0.5), at(0, 0), at(-0.5, -0.5))
+	case "OPEN30":
+		t := math.Tan(geom.Rad(15))
+		lines(at(-1, t), at(0, 0), at(-1, -t))
+	case "CLOSEDBLANK":
+		lines(at(0, 0), at(-1, w), at(-1, -w), at(0, 0))
+	case "CLOSED":
+		lines(at(0, 0), at(-1, w), at(-1, -w), at(0, 0))
+		lines(at(-1, 0), at(0, 0))
+	case "DOT":
+		disk(0.25)
+	case "DOTSMALL":
+		disk(1.0 / 16)
+	case "DOTBLANK":
+		circle(0.25)
+	case "SMALL":
+		circle(1.0 / 16)
+	case "ORIGIN":
+		circle(0.5)
+	case "ORIGIN2":
+		circle(0.5)
+		circle(0.25)
+	case "BOXBLANK":
+		lines(at(-0.5, -0.5), at(0.5, -0.5), at(0.5, 0.5), at(-0.5, 0.5), at(-0.5, -0.5))
+	case "BOXFILLED":
+		solid(at(-0.5, -0.5), at(0.5, -0.5), at(0.5, 0.5), at(-0.5, 0.5))
+	case "DATUMBLANK":
+		lines(at(0, 0.5), at(-1, 0), at(0, -0.5), at(0, 0.5))
+	case "DATUMFILLED":
+		solid(at(0, 0.5), at(-1, 0), at(0, -0.5), at(0, -0.5))
+	default:
+		solid(at(0, 0), at(-1, w), at(-1, -w), at(-1, -w))
+	}
+}
+
+func (g *dimGen) props(c drawing.Color, lw drawing.Lineweight) drawing.EntityProps {
+	p := drawing.DefaultProps()
+	p.Linetype = "ByBlock"
+	p.Color = c
+	p.Lineweight = lw
+	return p
+}
+
+// line adds a piece of dimension line, or of an extension line.
+func (g *dimGen) line(a, b geom.Vec2, extension bool) {
+	if a.Dist(b) < 1e-12 {
+		return
+	}
+	p := g.props(g.ds.LineColor, g.ds.LineWeight)
+	if extension {
+		p = g.props(g.ds.ExtColor, g.ds.ExtWeight)
+	}
+	g.ents = append(g.ents, &drawing.Line{EntityProps: p, Start: a.Vec3(g.z), End: b.Vec3(g.z), Extrusion: geom.ZAxis})
+}
+
+// trace adds the part of the track from t0 to t1.
+func (g *dimGen) trace(k track, t0, t1 float64) {
+	if t1-t0 < 1e-9 {
+		return
+	}
+	if !k.curved {
+		g.line(k.at(t0), k.at(t1), false)
+		return
+	}
+	g.ents = append(g.ents, &drawing.Arc{
+		EntityProps: g.props(g.ds.LineColor, g.ds.LineWeight),
+		Center:      k.c.Vec3(g.z),
+		Radius:      k.rad,
+		StartAngle:  k.a0 + t0/k.rad,
+		EndAngle:    k.a0 + t1/k.rad,
+		Extrusion:   geom.ZAxis,
+	})
+}
+
+// label adds the dimension text centered on pos.
+func (g *dimGen) label(value string, pos geom.Vec2, ang float64) {
+	if value == "" {
+		return
+	}
+	g.ents = append(g.ents, &drawing.MText{
+		EntityProps:       g.props(g.ds.TextColor, g.ds.LineWeight),
+		Value:             value,
+		Position:          pos.Vec3(g.z),
+		XDirection:        geom.Polar(ang, 1).Vec3(0),
+		Extrusion:         geom.ZAxis,
+		Height:            g.text,
+		Attachment:        drawing.MTextMiddleCenter,
+		Style:             g.ds.TextStyle,
+		LineSpacingFactor: 1,
+	})
+}
+
+// textSize estimates the width and height of dimension text, with
+// stacked tolerances and fractions.
+func (g *dimGen) textSize(value string) (w, h float64) {
+	if value == "" {
+		return 0, 0
+	}
+	f := format{font: g.r.font(g.ds.TextStyle), shx: g.r.shx(g.ds.TextStyle), fonts: g.r.fonts}
+	h = g.text
+	value = strings.ReplaceAll(value, `\~`, " ")
+	for {
+		before, rest, stacked := strings.Cut(value, `\S`)
+		w += f.units(before)
+		if !stacked {
+			break
+		}
+		body, after, _ := strings.Cut(rest, ";")
+		upper, lower := body, ""
+		if i := strings.IndexAny(body, "^/#"); i >= 0 {
+			upper, lower = body[:i], body[i+1:]
+		}
+		w += max(f.units(upper), f.units(lower)) * stackScale
+		h = g.text * (2*stackScale + stackGap)
+		value = after
+	}
+	width := 1.0
+	if ts := g.r.d.Style(g.ds.TextStyle); ts != nil && ts.WidthFactor > 0 {
+		width = ts.WidthFactor
+	}
+	return w * g.text * width, h
+}
@@ -0,0 +1,218 @@
+package render
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// dimLabel returns the MTEXT of a dimension: the measurement formatted by
+// the style with prefix, limits or tolerances and DIMPOST applied, as the
+// dimension's own text uses it. It is empty when the text is suppressed.
+// The arc length symbol is drawn apart from the text, see arcSymbol.
+func dimLabel(e *drawing.Dimension, ds *drawing.DimStyle, measurement float64, angular bool) string {
+	num := func(v float64, tol bool) string {
+		if angular {
+			return angle(ds, v)
+		}
+		v *= math.Abs(ds.LinearFactor)
+		if tol {
+			return length(ds, v, ds.TolDecimals, ds.TolZeroSupp)
+		}
+		return length(ds, v, ds.Decimals, ds.ZeroSuppress)
+	}
+	v := num(measurement, false)
+	if ds.Limits {
+		v = "\\S" + num(measurement+ds.TolPlus, true) + "^" + num(measurement-ds.TolMinus, true) + ";"
+	}
+	switch e.Type {
+	case drawing.DimRadius:
+		v = "R" + v
+	case drawing.DimDiameter:
+		v = "Ø" + v
+	}
+	if post, _, _ := strings.Cut(ds.Post, "["); post != "" {
+		if strings.Contains(post, "<>") {
+			v = strings.Replace(post, "<>", v, 1)
+		} else {
+			v += post
+		}
+	}
+	if ds.Tol && !ds.Limits {
+		signed := func(t float64) string {
+			if t < 0 {
+				return "-" + num(-t, true)
+			}
+			return "+" + num(t, true)
+		}
+		if ds.TolPlus == ds.TolMinus {
+			v += "±" + num(ds.TolPlus, true)
+		} else {
+			v += "\\S" + signed(ds.TolPlus) + "^" + signed(-ds.TolMinus) + ";"
+		}
+	}
+
+	switch e.Text {
+	case " ":
+		return ""
+	case "":
+		return v
+	}
+	return strings.Replace(e.Text, "<>", v, 1)
+}
+
+// length formats a distance in the units of DIMLUNIT.
+func length(ds *drawing.DimStyle, v float64, decimals, zin int) string {
+	if ds.Round > 0 {
+		v = math.Round(v/ds.Round) * ds.Round
+	}
+	decimals = min(max(decimals, 0), 8)
+	switch ds.LinearUnits {
+	case 1:
+		return decimal(strconv.FormatFloat(v, 'E', decimals, 64), ds.DecimalSep, zin)
+	case 3, 4:
+		return feetInches(ds, v, decimals, zin)
+	case 5:
+		return fraction(ds, v, decimals)
+	}
+	return decimal(strconv.FormatFloat(v, 'f', decimals, 64), ds.DecimalSep, zin)
+}
+
+// decimal applies the decimal separator and the suppression of leading
+// (zin bit 4) and trailing (bit 8) zeros to a formatted number.
+func decimal(s string, sep rune, zin int) string {
+	mant, exp, _ := strings.Cut(s, "E")
+	if zin&8 != 0 && strings.Contains(mant, ".") {
+		mant = strings.TrimRight(strings.TrimRight(mant, "0"), ".")
+	}
+	if zin&4 != 0 {
+		switch {
+		case strings.HasPrefix(mant, "0."):
+			mant = mant[1:]
+		case strings.HasPrefix(mant, "-0."):
+			mant = "-" + mant[2:]
+		}
+	}
+	if sep != '.' && sep != 0 {
+		mant = strings.Replace(mant, ".", string(sep), 1)
+	}
+	if mant == "" || mant == "-" {
+		mant += "0"
+	}
+	if exp != "" {
+		return mant + "E" + exp
+	}
+	return mant
+}
+
+// feetInches formats inches as feet and inches, the inches either decimal
+// (engineering units) or with a fraction (architectural units). The low
+// bits of zin choose whether zero feet and zero inches are shown.
+func feetInches(ds *drawing.DimStyle, v float64, decimals, zin int) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	var inches string
+	feet := math.Floor(v / 12)
+	rest := v - feet*12
+	if ds.LinearUnits == 4 {
+		inches = fraction(ds, rest, decimals)
+		if inches == "12" {
+			feet, inches = feet+1, "0"
+		}
+	} else {
+		inches = decimal(strconv.FormatFloat(rest, 'f', decimals, 64), ds.DecimalSep, zin)
+		if r, _ := strconv.ParseFloat(strings.Replace(inches, string(ds.DecimalSep), ".", 1), 64); r >= 12 {
+			feet, inches = feet+1, "0"
+		}
+	}
+	zeroInches := strings.Trim(inches, "0.") == ""
+	switch {
+	case feet == 0 && zin&3 != 1 && zin&3 != 2:
+		return sign + inches + `"`
+	case zeroInches && (zin&3 == 0 || zin&3 == 2):
+		return sign + strconv.FormatFloat(feet, 'f', 0, 64) + "'"
+	}
+	return sign + strconv.FormatFloat(feet, 'f', 0, 64) + "'-" + inches + `"`
+}
+
+// fraction formats v as a whole number and a fraction with a denominator
+// of up to 2^decimals, stacked as DIMFRAC says.
+func fraction(ds *drawing.DimStyle, v float64, decimals int) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	den := 1 << decimals
+	whole := math.Floor(v)
+	num := int(math.Round((v - whole) * float64(den)))
+	if num == den {
+		whole, num = whole+1, 0
+	}
+	w := strconv.FormatFloat(whole, 'f', 0, 64)
+	if num == 0 {
+		return sign + w
+	}
+	for num%2 == 0 {
+		num, den = num/2, den/2
+	}
+	n, d := strconv.Itoa(num), strconv.Itoa(den)
+	var frac string
+	switch ds.Fraction {
+	case 1:
+		frac = "\\S" + n + "#" + d + ";"
+	case 2:
+		frac = n + "/" + d
+	default:
+		frac = "\\S" + n + "/" + d + ";"
+	}
+	if whole == 0 {
+		return sign + frac
+	}
+	return sign + w + " " + frac
+}
+
+// angle formats an angle given in radians in the units of DIMAUNIT.
+func angle(ds *drawing.DimStyle, a float64) string {
+	decimals := ds.AngDecimals
+	if decimals < 0 {
+		decimals = ds.Decimals
+	}
+	decimals = min(max(decimals, 0), 8)
+	// DIMAZIN uses bits 1 and 2 where DIMZIN uses 4 and 8.
+	zin := ds.AngZeroSupp << 2
+	deg := geom.Deg(a)
+	switch ds.AngUnits {
+	case 1, 4:
+		return dms(deg, decimals)
+	case 2:
+		return decimal(strconv.FormatFloat(deg/0.9, 'f', decimals, 64), ds.DecimalSep, zin) + "g"
+	case 3:
+		return decimal(strconv.FormatFloat(a, 'f', decimals, 64), ds.DecimalSep, zin) + "r"
+	}
+	return decimal(strconv.FormatFloat(deg, 'f', decimals, 64), ds.DecimalSep, zin) + "°"
+}
+
+// dms formats degrees as degrees, minutes and seconds; the precision
+// decides which are shown, with decimals of seconds above 4.
+func dms(deg float64, decimals int) string {
+	switch {
+	case decimals == 0:
+		return strconv.FormatFloat(math.Round(deg), 'f', 0, 64) + "°"
+	case decimals <= 2:
+		m := math.Round(deg * 60)
+		return strconv.Itoa(int(m)/60) + "°" + strconv.Itoa(int(m)%60) + "'"
+	}
+	frac := max(decimals-4, 0)
+	scale := math.Pow(10, float64(frac))
+	s := math.Round(deg*3600*scale) / scale
+	d := math.Floor(s / 3600)
+	m := math.Floor((s - d*3600) / 60)
+	s -= d*3600 + m*60
+	return strconv.FormatFloat(d, 'f', 0, 64) + "°" + strconv.FormatFloat(m, 'f', 0, 64) + "'" +
+		strconv.FormatFloat(s, 'f', frac, 64) + `"`
+}
@@ -0,0 +1,412 @@
+package render
+
+import (
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+func (r *renderer) entity(e drawing.Entity, s scope) {
+	if h := e.Props().Hyperlink; h != nil && !r.continuous {
+		defer r.link(h, len(r.items))
+	}
+	if ins, ok := e.(*drawing.Insert); ok {
+		r.insert(ins, s)
+		return
+	}
+	if v, ok := e.(*drawing.Viewport); ok {
+		r.viewport(v, s)
+		return
+	}
+	st, ok := r.resolve(e.Props(), s)
+	if !ok {
+		return
+	}
+	switch e := e.(type) {
+	case *drawing.Line:
+		var p Path
+		n := pen{&p, s.m}
+		n.moveTo(e.Start)
+		n.lineTo(e.End)
+		r.stroke(p, st)
+	case *drawing.Arc:
+		end := e.EndAngle
+		for end <= e.StartAngle {
+			end += 2 * math.Pi
+		}
+		var p Path
+		n := pen{&p, ocs(s, e.Extrusion)}
+		n.ellipse(e.Center, geom.Vec3{X: e.Radius}, geom.Vec3{Y: e.Radius}, e.StartAngle, end, true)
+		r.stroke(p, st)
+	case *drawing.Circle:
+		var p Path
+		pen{&p, ocs(s, e.Extrusion)}.circle(e.Center, e.Radius)
+		r.stroke(p, st)
+	case *drawing.Ellipse:
+		r.ellipse(e, s, st)
+	case *drawing.Point:
+		r.point(e, s, st)
+	case *drawing.LWPolyline:
+		n := pen{m: ocs(s, e.Extrusion)}
+		verts := make([]drawing.Vertex, len(e.Vertices))
+		for i, v := range e.Vertices {
+			v.Position.Z = e.Elevation
+			verts[i] = v
+		}
+		r.polyline2D(n, verts, e.Closed, st)
+	case *drawing.Polyline:
+		r.polyline(e, s, st)
+	case *drawing.Ray:
+		r.infinite(e.Base, e.Direction, false, s, st)
+	case *drawing.XLine:
+		r.infinite(e.Base, e.Direction, true, s, st)
+	case *drawing.Spline:
+		var p Path
+		spline(pen{&p, s.m}, e)
+		r.stroke(p, st)
+	case *drawing.Solid:
+		var p Path
+		c := e.Corners
+		pen{&p, ocs(s, e.Extrusion)}.polygon(c[0], c[1], c[3], c[2])
+		r.area(p, st)
+	case *drawing.Text:
+		r.text(e, e.Value, s, st)
+	case *drawing.MText:
+		r.mtext(e, s, st)
+	case *drawing.Hatch:
+		r.hatch(e, s, st)
+	case *drawing.Dimension:
+		r.dimension(e, s, st)
+	case *drawing.AttDef:
+		r.attdef(e, s, st)
+	case *drawing.Image:
+		r.image(e, s, st)
+	case *drawing.Face3D:
+		var p Path
+		n := pen{&p, s.m}
+		corners := e.Corners[:]
+		if e.Corners[3] == e.Corners[2] {
+			corners = corners[:3]
+		}
+		for i, c := range corners {
+			if e.InvisibleEdges&(1<<i) != 0 {
+				continue
+			}
+			n.moveTo(c)
+			n.lineTo(corners[(i+1)%len(corners)])
+		}
+		r.stroke(p, st)
+	}
+}
+
+func ocs(s scope, extrusion geom.Vec3) geom.Matrix {
+	return s.m.Mul(geom.OCS(extrusion))
+}
+
+// area fills a closed path, or outlines it when FILLMODE is off.
+func (r *renderer) area(p Path, st style) {
+	if r.d.Header.FillMode {
+		r.fill(p, st)
+	} else {
+		r.stroke(p, st)
+	}
+}
+
+func (r *renderer) ellipse(e *drawing.Ellipse, s scope, st style) {
+	normal := e.Extrusion
+	if normal.IsZero() {
+		normal = geom.ZAxis
+	}
+	minor := normal.Unit().Cross(e.MajorAxis).Scale(e.Ratio)
+	t0, t1 := e.StartParam, e.EndParam
+	for t1 <= t0 {
+		t1 += 2 * math.Pi
+	}
+	var p Path
+	n := pen{&p, s.m}
+	n.ellipse(e.Center, e.MajorAxis, minor, t0, t1, true)
+	if t1-t0 >= 2*math.Pi-1e-9 {
+		n.close()
+	}
+	r.stroke(p, st)
+}
+
+// point draws the PDMODE symbol. Its size depends on the view when
+// PDSIZE is zero or negative, so it is drawn once the extents are known.
+func (r *renderer) point(e *drawing.Point, s scope, st style) {
+	mode := r.d.Header.PDMode
+	if mode&7 == 1 && mode&(32|64) == 0 {
+		return
+	}
+	pos := s.m.Apply(e.Position)
+	r.extra.Add(pos.XY())
+	r.late = append(r.late, func(extents geom.Box) {
+		size := r.d.Header.PDSize
+		switch {
+		case size == 0:
+			size = 0.05 * extents.Height()
+		case size < 0:
+			size = -size / 100 * extents.Height()
+		}
+		h := size / 2
+		var p Path
+		n := pen{&p, geom.Translate(pos)}
+		switch mode & 7 {
+		case 0:
+			n.moveTo(geom.Vec3{})
+			n.lineTo(geom.Vec3{})
+		case 2:
+			n.moveTo(geom.Vec3{X: -h})
+			n.lineTo(geom.Vec3{X: h})
+			n.moveTo(geom.Vec3{Y: -h})
+			n.lineTo(geom.Vec3{Y: h})
+		case 3:
+			n.moveTo(geom.Vec3{X: -h, Y: -h})
+			n.lineTo(geom.Vec3{X: h, Y: h})
+			n.moveTo(geom.Vec3{X: -h, Y: h})
+			n.lineTo(geom.Vec3{X: h, Y: -h})
+		case 4:
+			n.moveTo(geom.Vec3{})
+			n.lineTo(geom.Vec3{Y: h})
+		}
+		if mode&32 != 0 {
+			n.circle(geom.Vec3{}, h)
+		}
+		if mode&64 != 0 {
+			n.polygon(geom.Vec3{X: -h, Y: -h}, geom.Vec3{X: h, Y: -h}, geom.Vec3{X: h, Y: h}, geom.Vec3{X: -h, Y: h})
+		}
+		r.stroke(p, st)
+	})
+}
+
+// infinite draws a ray or an xline clipped to the extents of the rest of
+// the drawing.
+func (r *renderer) infinite(base, dir geom.Vec3, both bool, s scope, st style) {
+	b := s.m.Apply(base).XY()
+	d := s.m.ApplyVec(dir).XY()
+	r.extra.Add(b)
+	r.late = append(r.late, func(extents geom.Box) {
+		t0, t1 := 0.0, math.Inf(1)
+		if both {
+			t0 = math.Inf(-1)
+		}
+		t0, t1, ok := clipLine(b, d, extents, t0, t1)
+		if !ok {
+			return
+		}
+		var p Path
+		p.MoveTo(b.Add(d.Scale(t0)))
+		p.LineTo(b.Add(d.Scale(t1)))
+		r.stroke(p, st)
+	})
+}
+
+// clipLine narrows the parameter range of b + t·d to the part inside box
+// (Liang–Barsky).
+func clipLine(b, d geom.Vec2, box geom.Box, t0, t1 float64) (float64, float64, bool) {
+	if !box.Valid || d.Len() == 0 {
+		return 0, 0, false
+	}
+	check := func(p, q float64) bool {
+		if p == 0 {
+			return q >= 0
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		return t0 <= t1
+	}
+	ok := check(-d.X, b.X-box.Min.X) && check(d.X, box.Max.X-b.X) &&
+		check(-d.Y, b.Y-box.Min.Y) && check(d.Y, box.Max.Y-b.Y)
+	return t0, t1, ok && !math.IsInf(t0, 0) && !math.IsInf(t1, 0)
+}
+
+func (r *renderer) polyline(e *drawing.Polyline, s scope, st style) {
+	switch {
+	case e.Flags&drawing.PolylinePolyface != 0:
+		var p Path
+		n := pen{&p, s.m}
+		for _, f := range e.Faces {
+			idx := make([]int, 0, 4)
+			for _, i := range f {
+				if i != 0 {
+					idx = append(idx, i)
+				}
+			}
+			for k, i := range idx {
+				j := abs(idx[(k+1)%len(idx)])
+				if i < 0 || i > len(e.Vertices) || j > len(e.Vertices) {
+					continue
+				}
+				n.moveTo(e.Vertices[i-1].Position)
+				n.lineTo(e.Vertices[j-1].Position)
+			}
+		}
+		r.stroke(p, st)
+	case e.Flags&drawing.PolylineMesh != 0:
+		r.stroke(mesh(pen{&Path{}, s.m}, e), st)
+	case e.Is3D():
+		var p Path
+		n := pen{&p, s.m}
+		for i, v := range e.Vertices {
+			if i == 0 {
+				n.moveTo(v.Position)
+			} else {
+				n.lineTo(v.Position)
+			}
+		}
+		if e.Closed() {
+			n.close()
+		}
+		r.stroke(p, st)
+	default:
+		verts := make([]drawing.Vertex, 0, len(e.Vertices))
+		for _, v := range e.Vertices {
+			if v.Flags&drawing.VertexSplineFrame != 0 {
+				continue
+			}
+			v.Position.Z = e.Elevation
+			verts = append(verts, v)
+		}
+		r.polyline2D(pen{m: ocs(s, e.Extrusion)}, verts, e.Closed(), st)
+	}
+}
+
+func mesh(n pen, e *drawing.Polyline) Path {
+	m, cols := e.MCount, e.NCount
+	if m*cols > len(e.Vertices) || m == 0 || cols == 0 {
+		return Path{}
+	}
+	at := func(i, j int) geom.Vec3 { return e.Vertices[i*cols+j].Position }
+	line := func(count int, closed bool, pt func(int) geom.Vec3) {
+		n.moveTo(pt(0))
+		for k := 1; k < count; k++ {
+			n.lineTo(pt(k))
+		}
+		if closed {
+			n.close()
+		}
+	}
+	for i := range m {
+		line(cols, e.Flags&drawing.PolylineMeshClosedN != 0, func(j int) geom.Vec3 { return at(i, j) })
+	}
+	for j := range cols {
+		line(m, e.Closed(), func(i int) geom.Vec3 { return at(i, j) })
+	}
+	return *n.p
+}
+
+// polyline2D draws a 2D polyline given in OCS. Segments without width are
+// stroked with the lineweight; wide segments become filled outlines.
+func (r *renderer) polyline2D(n pen, verts []drawing.Vertex, closed bool, st style) {
+	count := len(verts) - 1
+	if closed {
+		count = len(verts)
+	}
+	var thin, wide Path
+	n.p = &thin
+	w := pen{&wide, n.m}
+	open := false
+	for i := range count {
+		a, b := verts[i], verts[(i+1)%len(verts)]
+		if a.StartWidth == 0 && a.EndWidth == 0 {
+			if !open {
+				n.moveTo(a.Position)
+				open = true
+			}
+			segment(n, a.Position, b.Position, a.Bulge)
+			continue
+		}
+		open = false
+		w.polygon(widthOutline(a, b)...)
+	}
+	if closed && len(verts) > 1 && allThin(verts) {
+		thin.Close()
+	}
+	if len(verts) == 1 {
+		n.moveTo(verts[0].Position)
+		n.lineTo(verts[0].Position)
+	}
+	r.stroke(thin, st)
+	r.area(wide, st)
+}
+
+func allThin(verts []drawing.Vertex) bool {
+	for _, v := range verts {
+		if v.StartWidth != 0 || v.EndWidth != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// segment continues the current subpath from a to b, along an arc when
+// the bulge is non-zero.
+func segment(n pen, a, b geom.Vec3, bulge float64) {
+	if math.Abs(bulge) < 1e-9 || a == b {
+		n.lineTo(b)
+		return
+	}
+	c, radius, start, sweep := bulgeArc(a, b, bulge)
+	n.ellipse(c, geom.Vec3{X: radius}, geom.Vec3{Y: radius}, start, start+sweep, false)
+}
+
+// bulgeArc returns the arc between a and b whose included angle is
+// 4·atan(bulge), counter-clockwise for a positive bulge.
+func bulgeArc(a, b geom.Vec3, bulge float64) (center geom.Vec3, radius, start, sweep float64) {
+	sweep = 4 * math.Atan(bulge)
+	chord := b.XY().Sub(a.XY())
+	d := chord.Len()
+	mid := a.XY().Lerp(b.XY(), 0.5)
+	c := mid.Add(chord.Perp().Unit().Scale(d / 2 / math.Tan(sweep/2)))
+	radius = c.Dist(a.XY())
+	start = a.XY().Sub(c).Angle()
+	return c.Vec3(a.Z), radius, start, sweep
+}
+
+// widthOutline returns the outline of a wide segment: the left edge
+// forwards, then the right edge back.
+func widthOutline(a, b drawing.Vertex) []geom.Vec3 {
+	type sample struct {
+		p, left geom.Vec2
+		t       float64
+	}
+	var samples []sample
+	if math.Abs(a.Bulge) < 1e-9 {
+		left := b.Position.XY().Sub(a.Position.XY()).Unit().Perp()
+		samples = []sample{{a.Position.XY(), left, 0}, {b.Position.XY(), left, 1}}
+	} else {
+		c, radius, start, sweep := bulgeArc(a.Position, b.Position, a.Bulge)
+		steps := max(8, int(math.Ceil(math.Abs(sweep)/(math.Pi/32))))
+		dir := math.Copysign(1, sweep)
+		for i := 0; i <= steps; i++ {
+			t := float64(i) / float64(steps)
+			ang := start + sweep*t
+			tangent := geom.Polar(ang+dir*math.Pi/2, 1)
+			samples = append(samples, sample{c.XY().Add(geom.Polar(ang, radius)), tangent.Perp(), t})
+		}
+	}
+	z := a.Position.Z
+	out := make([]geom.Vec3, 0, 2*len(samples))
+	for _, s := range samples {
+		hw := (a.StartWidth + (a.EndWidth-a.StartWidth)*s.t) / 2
+		out = append(out, s.p.Add(s.left.Scale(hw)).Vec3(z))
+	}
+	for i := len(samples) - 1; i >= 0; i-- {
+		s := samples[i]
+		hw := (a.StartWidth + (a.EndWidth-a.StartWidth)*s.t) / 2
+		out = append(out, s.p.Sub(s.left.Scale(hw)).Vec3(z))
+	}
+	return out
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,395 @@
+package render
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/pattern"
+)
+
+// Pattern limits. AutoCAD refuses to draw patterns that are too dense; we
+// fill them solid instead, which is what they look like on paper.
+const (
+	maxPatternLines    = 10000
+	maxPatternSegments = 500000
+)
+
+// arcStep is the angle each line segment of a flattened hatch arc spans.
+const arcStep = math.Pi / 90
+
+func (r *renderer) hatch(h *drawing.Hatch, s scope, st style) {
+	loops := hatchLoops(h)
+	if len(loops) == 0 {
+		return
+	}
+	m := ocs(s, h.Extrusion)
+	var area Path
+	n := pen{&area, m}
+	for _, l := range loops {
+		for i, v := range l {
+			if i == 0 {
+				n.moveTo(v.Vec3(h.Elevation))
+			} else {
+				n.lineTo(v.Vec3(h.Elevation))
+			}
+		}
+		n.close()
+	}
+
+	switch {
+	case !r.d.Header.FillMode && (h.Solid || h.Gradient != nil):
+		r.stroke(area, st)
+	case h.Gradient != nil:
+		g := gradient(h.Gradient, loops, st)
+		g.transform(m.Mul(geom.Translate(geom.Vec3{Z: h.Elevation})))
+		r.items = append(r.items, Item{Path: area, Fill: true, EvenOdd: true, Color: g.Stops[0].Color, Layer: st.layer, Gradient: g})
+	case h.Solid || strings.EqualFold(h.Pattern, "SOLID"):
+		r.items = append(r.items, Item{Path: area, Fill: true, EvenOdd: true, Color: st.rgb(), Layer: st.layer})
+	default:
+		lines := h.Lines
+		if len(lines) == 0 {
+			lines = r.patternLines(h)
+		}
+		segs, ok := patternSegments(loops, lines)
+		if !ok {
+			r.items = append(r.items, Item{Path: area, Fill: true, EvenOdd: true, Color: st.rgb(), Layer: st.layer})
+			return
+		}
+		var p Path
+		n := pen{&p, m}
+		for _, sg := range segs {
+			n.moveTo(sg[0].Vec3(h.Elevation))
+			n.lineTo(sg[1].Vec3(h.Elevation))
+		}
+		r.stroke(p, st.continuous())
+	}
+}
+
+// patternLines returns the pattern of a hatch that only names it.
+func (r *renderer) patternLines(h *drawing.Hatch) []drawing.HatchLine {
+	scale := h.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	if h.PatternType == drawing.HatchPatternUser {
+		return pattern.User(h.Angle, scale, h.Double)
+	}
+	if p := r.patterns.Lookup(h.Pattern); p != nil {
+		return p.Place(h.Angle, scale)
+	}
+	return nil
+}
+
+// hatchLoops flattens the boundary loops to polygons in the hatch's OCS
+// and keeps those the island style fills: every loop for normal style,
+// alternating between filled and empty areas, the outer loops and their
+// first islands for outer style, and only the outer loops when islands
+// are ignored.
+func hatchLoops(h *drawing.Hatch) [][]geom.Vec2 {
+	var loops [][]geom.Vec2
+	for i := range h.Loops {
+		if l := loopPolygon(&h.Loops[i]); len(l) >= 3 {
+			loops = append(loops, l)
+		}
+	}
+	maxDepth := math.MaxInt
+	switch h.Style {
+	case drawing.HatchStyleOuter:
+		maxDepth = 1
+	case drawing.HatchStyleIgnore:
+		maxDepth = 0
+	}
+	if maxDepth == math.MaxInt {
+		return loops
+	}
+	var kept [][]geom.Vec2
+	for i, l := range loops {
+		depth := 0
+		for j, o := range loops {
+			if i != j && inside(l[0], o) {
+				depth++
+			}
+		}
+		if depth <= maxDepth {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+func loopPolygon(l *drawing.HatchLoop) []geom.Vec2 {
+	if l.Flags&drawing.HatchLoopPolyline != 0 {
+		var p Path
+		n := pen{&p, geom.Identity()}
+		for i, v := range l.Vertices {
+			if i == 0 {
+				n.moveTo(v.Position)
+			}
+			segment(n, v.Position, l.Vertices[(i+1)%len(l.Vertices)].Position, v.Bulge)
+		}
+		return closeRing(slices.Concat(p.Flatten()...))
+	}
+	var ring []geom.Vec2
+	for i, e := range l.Edges {
+		pts := edgePoints(e)
+		if len(pts) == 0 {
+			continue
+		}
+		if len(ring) > 0 {
+			last := ring[len(ring)-1]
+			if last.Dist(pts[0]) > last.Dist(pts[len(pts)-1]) {
+				slices.Reverse(pts)
+			}
+			// The first edge may run against the chain too.
+			if i == 1 && ring[0].Dist(pts[0]) < last.Dist(pts[0]) {
+				slices.Reverse(ring)
+				if last = ring[len(ring)-1]; last.Dist(pts[0]) > last.Dist(pts[len(pts)-1]) {
+					slices.Reverse(pts)
+				}
+			}
+			if last.Dist(pts[0]) < 1e-9 {
+				pts = pts[1:]
+			}
+		}
+		ring = append(ring, pts...)
+	}
+	return closeRing(ring)
+}
+
+// closeRing drops the last point when it repeats the first.
+func closeRing(ring []geom.Vec2) []geom.Vec2 {
+	if n := len(ring); n > 1 && ring[0].Dist(ring[n-1]) < 1e-9 {
+		ring = ring[:n-1]
+	}
+	return ring
+}
+
+// edgePoints flattens one loop edge in its own direction.
+func edgePoints(e drawing.HatchEdge) []geom.Vec2 {
+	switch e.Type {
+	case drawing.EdgeLine:
+		return []geom.Vec2{e.Start, e.End}
+	case drawing.EdgeArc:
+		u := geom.Vec2{X: e.Radius}
+		return arcPoints(e.Center, u, u.Perp(), e.StartAngle, e.EndAngle, e.CCW)
+	case drawing.EdgeEllipse:
+		return arcPoints(e.Center, e.MajorAxis, e.MajorAxis.Perp().Scale(e.Ratio), e.StartAngle, e.EndAngle, e.CCW)
+	case drawing.EdgeSpline:
+		if e.Spline == nil {
+			return nil
+		}
+		var p Path
+		spline(pen{&p, geom.Identity()}, e.Spline)
+		return slices.Concat(p.Flatten()...)
+	}
+	return nil
+}
+
+// arcPoints samples c + u·cos t + v·sin t. Clockwise edges store their
+// angles mirrored, so they run clockwise from -t0 to -t1.
+func arcPoints(c, u, v geom.Vec2, t0, t1 float64, ccw bool) []geom.Vec2 {
+	if ccw {
+		for t1 <= t0 {
+			t1 += 2 * math.Pi
+		}
+	} else {
+		t0, t1 = -t0, -t1
+		for t1 >= t0 {
+			t1 -= 2 * math.Pi
+		}
+	}
+	steps := max(2, int(math.Ceil(math.Abs(t1-t0)/arcStep)))
+	pts := make([]geom.Vec2, steps+1)
+	for i := range pts {
+		s, co := math.Sincos(t0 + (t1-t0)*float64(i)/float64(steps))
+		pts[i] = c.Add(u.Scale(co)).Add(v.Scale(s))
+	}
+	return pts
+}
+
+// inside tests p against a polygon with the even-odd rule.
+func inside(p geom.Vec2, poly []geom.Vec2) bool {
+	in := false
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			in = !in
+		}
+	}
+	return in
+}
+
+// patternSegments clips every pattern line to the loops with the
+// even-odd rule and cuts it into dashes, which start at the line's base
+// point. Dots come out as segments of zero length. It reports false when
+// the pattern is too dense to draw.
+func patternSegments(loops [][]geom.Vec2, lines []drawing.HatchLine) ([][2]geom.Vec2, bool) {
+	var segs [][2]geom.Vec2
+	budget := maxPatternSegments
+	count := 0
+	for _, l := range lines {
+		dir := geom.Polar(l.Angle, 1)
+		normal := dir.Perp()
+		spacing := l.Offset.Dot(normal)
+		if math.Abs(spacing) < 1e-12 {
+			continue
+		}
+		lo, hi := math.Inf(1), math.Inf(-1)
+		along := math.Inf(-1)
+		for _, loop := range loops {
+			for _, v := range loop {
+				d := v.Sub(l.Base)
+				lo, hi = math.Min(lo, d.Dot(normal)), math.Max(hi, d.Dot(normal))
+				along = math.Max(along, math.Abs(d.Dot(dir)))
+			}
+		}
+		k0, k1 := math.Ceil(lo/spacing), math.Floor(hi/spacing)
+		if spacing < 0 {
+			k0, k1 = math.Ceil(hi/spacing), math.Floor(lo/spacing)
+		}
+		if count += int(k1-k0) + 1; count > maxPatternLines {
+			return nil, false
+		}
+		var period float64
+		for _, d := range l.Dashes {
+			period += math.Abs(d)
+		}
+		if period > 0 {
+			// Estimate the dashes before cutting them.
+			if budget -= int((k1-k0+1)*(2*along/period+1)) * len(l.Dashes); budget < 0 {
+				return nil, false
+			}
+		}
+
+		var ts []float64
+		for k := k0; k <= k1; k++ {
+			origin := l.Base.Add(l.Offset.Scale(k))
+			ts = ts[:0]
+			for _, loop := range loops {
+				for i, a := range loop {
+					b := loop[(i+1)%len(loop)]
+					da, db := a.Sub(origin).Dot(normal), b.Sub(origin).Dot(normal)
+					if (da > 0) == (db > 0) {
+						continue
+					}
+					ts = append(ts, a.Sub(origin).Dot(dir)+b.Sub(a).Dot(dir)*da/(da-db))
+				}
+			}
+			slices.Sort(ts)
+			at := func(t float64) geom.Vec2 { return origin.Add(dir.Scale(t)) }
+			for i := 0; i+1 < len(ts); i += 2 {
+				t0, t1 := ts[i], ts[i+1]
+				if period <= 0 {
+					segs = append(segs, [2]geom.Vec2{at(t0), at(t1)})
+					continue
+				}
+				for pos := math.Floor(t0/period) * period; pos < t1; {
+					for _, d := range l.Dashes {
+						end := pos + math.Abs(d)
+						if d >= 0 {
+							a, b := math.Max(pos, t0), math.Min(end, t1)
+							// Dashes cut down to nothing at the boundary would
+							// show as dots.
+							if b-a > period*1e-9 || d == 0 && pos >= t0 && pos <= t1 {
+								segs = append(segs, [2]geom.Vec2{at(a), at(b)})
+							}
+						}
+						pos = end
+					}
+				}
+			}
+		}
+	}
+	return segs, true
+}
+
+// gradient builds the color blend of a gradient hatch in its OCS. The
+// pattern is laid out in the frame turned by the gradient angle, over
+// the extents of the loops in that frame.
+func gradient(g *drawing.Gradient, loops [][]geom.Vec2, st style) *Gradient {
+	c1 := st.rgb()
+	if len(g.Colors) > 0 {
+		c1 = st.plot(g.Colors[0])
+	}
+	var c2 RGB
+	switch {
+	case g.SingleColor || len(g.Colors) < 2:
+		// The tint runs from black through the color to white.
+		if g.Tint < 0.5 {
+			c2 = mix(RGB{}, c1, 2*g.Tint)
+		} else {
+			c2 = mix(c1, RGB{255, 255, 255}, 2*g.Tint-1)
+		}
+	default:
+		c2 = st.plot(g.Colors[1])
+	}
+
+	u := geom.Polar(g.Angle, 1)
+	v := u.Perp()
+	var box geom.Box
+	for _, l := range loops {
+		for _, p := range l {
+			box.Add(geom.Vec2{X: p.Dot(u), Y: p.Dot(v)})
+		}
+	}
+	at := func(x, y float64) geom.Vec2 { return u.Scale(x).Add(v.Scale(y)) }
+	c := box.Center()
+	w, h := box.Width(), box.Height()
+	shift := g.Shift * w / 2
+
+	name := strings.ToUpper(strings.TrimSpace(g.Name))
+	inverse := strings.HasPrefix(name, "INV")
+	if inverse {
+		c1, c2 = c2, c1
+	}
+	var out *Gradient
+	switch strings.TrimPrefix(name, "INV") {
+	case "CYLINDER":
+		mid := math.Min(math.Max(0.5+g.Shift/2, 0), 1)
+		out = &Gradient{
+			From:  at(box.Min.X, c.Y),
+			To:    at(box.Max.X, c.Y),
+			Stops: []Stop{{0, c1}, {mid, c2}, {1, c1}},
+		}
+	case "SPHERICAL":
+		centre := at(c.X+shift, c.Y)
+		out = &Gradient{
+			Radial: true,
+			From:   centre,
+			To:     centre,
+			R1:     math.Hypot(w, h)/2 + math.Abs(shift),
+			Stops:  []Stop{{0, c2}, {1, c1}},
+		}
+	case "HEMISPHERICAL":
+		centre := at(c.X+shift, box.Min.Y)
+		out = &Gradient{
+			Radial: true,
+			From:   centre,
+			To:     centre,
+			R1:     math.Hypot(w/2+math.Abs(shift), h),
+			Stops:  []Stop{{0, c2}, {1, c1}},
+		}
+	case "CURVED":
+		out = &Gradient{
+			From:  at(c.X, box.Min.Y),
+			To:    at(c.X, box.Max.Y),
+			Stops: []Stop{{0, c2}, {0.3, mix(c2, c1, 0.3)}, {1, c1}},
+		}
+	default: // LINEAR
+		out = &Gradient{
+			From:  at(box.Min.X, c.Y),
+			To:    at(box.Max.X, c.Y),
+			Stops: []Stop{{0, c1}, {math.Min(math.Max(0.5+g.Shift/2, 0), 1), mix(c1, c2, 0.5)}, {1, c2}},
+		}
+	}
+	return out
+}
+
+func mix(a, b RGB, t float64) RGB {
+	t = math.Min(math.Max(t, 0), 1)
+	lerp := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t)) }
+	return RGB{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B)}
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// image draws a raster image clipped to its boundary. Images whose file
+// cannot be found, and images set not to show, draw their frame instead.
+func (r *renderer) image(e *drawing.Image, s scope, st style) {
+	w, h := e.Size.X, e.Size.Y
+	def := r.d.ImageDef(e.Def)
+	if (w <= 0 || h <= 0) && def != nil {
+		w, h = def.Size.X, def.Size.Y
+	}
+	if w <= 0 || h <= 0 {
+		return
+	}
+	// pixel maps pixel coordinates, which start at the centre of the top
+	// left pixel and run down, to WCS.
+	pixel := func(v geom.Vec2) geom.Vec3 {
+		return e.Insertion.Add(e.U.Scale(v.X + 0.5)).Add(e.V.Scale(h - 0.5 - v.Y))
+	}
+	n := pen{&Path{}, s.m}
+	n.polygon(pixel(geom.Vec2{X: -0.5, Y: -0.5}), pixel(geom.Vec2{X: w - 0.5, Y: -0.5}),
+		pixel(geom.Vec2{X: w - 0.5, Y: h - 0.5}), pixel(geom.Vec2{X: -0.5, Y: h - 0.5}))
+	frame := *n.p
+
+	if e.Flags&drawing.ImageShow == 0 || def == nil || r.images == nil {
+		r.stroke(frame, st.continuous())
+		return
+	}
+	bm := r.images.Image(def.File)
+	if bm == nil {
+		r.stroke(frame, st.continuous())
+		return
+	}
+
+	it := Item{
+		Path:  frame,
+		Color: st.rgb(),
+		Layer: st.layer,
+		Image: &Image{
+			Bitmap: bm,
+			Matrix: s.m.
+				Mul(geom.Translate(e.Insertion)).
+				Mul(geom.Axes(e.U.Scale(w), e.V.Scale(h), geom.ZAxis)),
+			Transparent: e.Flags&drawing.ImageTransparency != 0,
+		},
+	}
+	if clip := e.Clip; e.Clipping && len(clip) >= 2 {
+		if len(clip) == 2 {
+			a, b := clip[0], clip[1]
+			clip = []geom.Vec2{a, {X: b.X, Y: a.Y}, b, {X: a.X, Y: b.Y}}
+		}
+		if len(clip) > 3 && clip[len(clip)-1] == clip[0] {
+			clip = clip[:len(clip)-1]
+		}
+		c := pen{&Path{}, s.m}
+		for i, v := range clip {
+			if i == 0 {
+				c.moveTo(pixel(v))
+			} else {
+				c.lineTo(pixel(v))
+			}
+		}
+		c.close()
+		if e.ClipInverted {
+			// The frame with the boundary as a hole.
+			it.Path.Ops = append(it.Path.Ops, c.p.Ops...)
+			it.Path.Pts = append(it.Path.Pts, c.p.Pts...)
+			it.EvenOdd = true
+		} else {
+			it.Path = *c.p
+		}
+	}
+	r.items = append(r.items, it)
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// maxNesting bounds block expansion in drawings that nest deeper than any
+// real drawing does, and maxCells the cells of a MINSERT array.
+const (
+	maxNesting = 64
+	maxCells   = 1 << 16
+)
+
+// insert expands a block reference, once per cell of a MINSERT array, and
+// draws its attributes.
+func (r *renderer) insert(e *drawing.Insert, s scope) {
+	if e.Invisible {
+		return
+	}
+	st, _ := r.resolve(&e.EntityProps, s)
+	// Entities on other layers stay visible when the insert's layer is
+	// off, but freezing it, also in a viewport, hides the whole reference.
+	if l := r.d.Layer(st.layer); l != nil && l.Frozen || r.frozenIn(st.layer) {
+		return
+	}
+	b := r.d.Block(e.Block)
+	if b == nil || len(s.blocks) >= maxNesting || slices.Contains(s.blocks, b) {
+		return
+	}
+
+	inner := scope{
+		layer:      st.layer,
+		color:      st.color,
+		lineweight: st.lineweight,
+		linetype:   st.linetype,
+		plotStyle:  st.plotStyle,
+		attribs:    make(map[string]bool, len(e.Attribs)),
+		blocks:     append(s.blocks[:len(s.blocks):len(s.blocks)], b),
+	}
+	for _, a := range e.Attribs {
+		inner.attribs[strings.ToUpper(a.Tag)] = true
+	}
+
+	// Array spacing applies in the rotated insert frame but is not scaled.
+	place := ocs(s, e.Extrusion).Mul(geom.Translate(e.Position)).Mul(geom.RotateZ(e.Rotation))
+	local := geom.Scale(e.Scale).Mul(geom.Translate(b.Base.Scale(-1)))
+	rows := min(max(e.Rows, 1), maxCells)
+	cols := min(max(e.Columns, 1), maxCells/rows)
+	for row := range rows {
+		for col := range cols {
+			offset := geom.Vec3{X: float64(col) * e.ColumnSpacing, Y: float64(row) * e.RowSpacing}
+			inner.m = place.Mul(geom.Translate(offset)).Mul(local)
+			r.block(b, inner)
+		}
+	}
+
+	// Attributes are stored in the insert's space, not the block's.
+	inner.m = s.m
+	for _, a := range e.Attribs {
+		if a.Flags&drawing.AttribInvisible != 0 {
+			continue
+		}
+		if ast, ok := r.resolve(&a.EntityProps, inner); ok {
+			r.text(&a.Text, a.Value, inner, ast)
+		}
+	}
+}
+
+// attdef draws an attribute definition. Outside of a block reference the
+// tag is shown; inside one, constant attributes show their value and the
+// others show their default only when the insert has no attribute with
+// that tag.
+func (r *renderer) attdef(e *drawing.AttDef, s scope, st style) {
+	if e.Flags&drawing.AttribInvisible != 0 {
+		return
+	}
+	switch {
+	case s.attribs == nil:
+		r.text(&e.Text, e.Tag, s, st)
+	case e.Flags&drawing.AttribConstant != 0 || !s.attribs[strings.ToUpper(e.Tag)]:
+		r.text(&e.Text, e.Value, s, st)
+	}
+}
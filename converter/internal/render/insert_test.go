@@ -0,0 +1,60 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+func testLine(invisible bool) *drawing.Line {
+	l := &drawing.Line{EntityProps: drawing.DefaultProps(), End: geom.Vec3{X: 1, Y: 1}}
+	l.Invisible = invisible
+	return l
+}
+
+func testInsert(block string, x float64) *drawing.Insert {
+	return &drawing.Insert{
+		EntityProps: drawing.DefaultProps(),
+		Block:       block,
+		Position:    geom.Vec3{X: x},
+		Scale:       geom.Vec3{X: 1, Y: 1, Z: 1},
+		Extrusion:   geom.ZAxis,
+	}
+}
+
+func TestRenderStopsExpandingBlocks(t *testing.T) {
+	// Nine levels of ten inserts each expand to 10^9 lines.
+	d := drawing.New()
+	d.EnsureDefaults()
+	d.AddBlock(&drawing.Block{Name: "B0", Entities: []drawing.Entity{testLine(true)}})
+	for level := 1; level <= 9; level++ {
+		b := &drawing.Block{Name: fmt.Sprintf("B%d", level)}
+		for i := range 10 {
+			b.Entities = append(b.Entities, testInsert(fmt.Sprintf("B%d", level-1), float64(i)))
+		}
+		d.AddBlock(b)
+	}
+	d.ModelSpace().Entities = []drawing.Entity{testInsert("B9", 0)}
+
+	_, warnings := Render(d, Options{})
+	if len(warnings) != 1 {
+		t.Fatalf("warnings = %q, want one", warnings)
+	}
+}
+
+func TestRenderClampsMInsert(t *testing.T) {
+	d := drawing.New()
+	d.EnsureDefaults()
+	d.AddBlock(&drawing.Block{Name: "B", Entities: []drawing.Entity{testLine(false)}})
+	ins := testInsert("B", 0)
+	ins.Rows, ins.Columns = 30000, 30000
+	ins.RowSpacing, ins.ColumnSpacing = 2, 2
+	d.ModelSpace().Entities = []drawing.Entity{ins}
+
+	sheets, _ := Render(d, Options{})
+	if n := len(sheets[0].Items); n == 0 || n > maxCells {
+		t.Errorf("the array draws %d items, want at most %d", n, maxCells)
+	}
+}
@@ -0,0 +1,51 @@
+package render
+
+import (
+	"path"
+	"strings"
+)
+
+// layerFilter decides which layers are drawn from lists of names and glob
+// patterns. A nil filter shows every layer.
+type layerFilter struct {
+	include, exclude []string
+	// shown caches the decision by upper-case layer name.
+	shown map[string]bool
+}
+
+func newLayerFilter(include, exclude []string) *layerFilter {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+	upper := func(list []string) []string {
+		out := make([]string, len(list))
+		for i, s := range list {
+			out[i] = strings.ToUpper(strings.TrimSpace(s))
+		}
+		return out
+	}
+	return &layerFilter{include: upper(include), exclude: upper(exclude), shown: make(map[string]bool)}
+}
+
+// shows reports whether entities on the layer are drawn.
+func (f *layerFilter) shows(layer string) bool {
+	if f == nil {
+		return true
+	}
+	name := strings.ToUpper(layer)
+	if v, ok := f.shown[name]; ok {
+		return v
+	}
+	v := (len(f.include) == 0 || matchAny(f.include, name)) && !matchAny(f.exclude, name)
+	f.shown[name] = v
+	return v
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
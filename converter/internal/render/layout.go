@@ -0,0 +1,180 @@
+package render
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// renderLayout plots a layout as its page setup says, with model space
+// seen through the viewports of paper space layouts. It returns nil when
+// a paper space layout has nothing to plot.
+func renderLayout(d *drawing.Drawing, l *drawing.Layout, opts Options, budget *expansion) *Sheet {
+	b := d.Block(l.Block)
+	if b == nil {
+		b = d.ModelSpace()
+	}
+	if !l.IsModel() && !plottable(b) {
+		return nil
+	}
+	r := newRenderer(d, opts, budget)
+	r.styles = plotStyles(&l.Plot, opts)
+	r.thin = thinLines(d, &l.Plot, opts)
+	top := r.top()
+	if !l.IsModel() {
+		r.paper = b
+	}
+	m, area, ok := plotView(d, &l.Plot)
+	if ok {
+		top.m = m
+	}
+	// Views of paper space are not kept per layout, so they go with the
+	// active one.
+	if l.IsModel() || strings.EqualFold(l.Block, drawing.PaperSpace) {
+		r.namedViews(!l.IsModel(), top.m, geom.Box{})
+	}
+	r.block(b, top)
+	if !ok {
+		area = plotArea(d, l, r.bounds())
+	}
+	return r.sheet(l.Name, area, newPage(&l.Plot, area, opts))
+}
+
+// plottable reports whether a layout has entities other than the viewport
+// that shows the layout itself.
+func plottable(b *drawing.Block) bool {
+	for _, e := range b.Entities {
+		if v, ok := e.(*drawing.Viewport); !ok || v.ID != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// viewport draws the border of a viewport and the model space seen
+// through it, clipped to the border. The contents show even when the
+// viewport's layer is off, which is how borders are usually hidden.
+func (r *renderer) viewport(v *drawing.Viewport, s scope) {
+	// Viewports only work directly in a layout, and not in the one that
+	// shows the layout itself.
+	if v.ID == 1 || len(s.blocks) > 0 || r.frozen != nil {
+		return
+	}
+	if v.Status&drawing.ViewportOff != 0 || v.Width <= 0 || v.Height <= 0 {
+		return
+	}
+	clip, custom := r.viewportClip(v, s)
+	if st, ok := r.resolve(&v.EntityProps, s); ok && !custom {
+		border := Path{Ops: slices.Clone(clip.Ops), Pts: slices.Clone(clip.Pts)}
+		r.stroke(border, st)
+	}
+	if v.ViewHeight <= 0 {
+		return
+	}
+
+	n, links, late, extra := len(r.items), len(r.links), r.late, r.extra
+	r.late = nil
+	r.frozen = make(map[string]bool, len(v.FrozenLayers))
+	for _, name := range v.FrozenLayers {
+		r.frozen[strings.ToUpper(strings.TrimSpace(name))] = true
+	}
+	model := r.top()
+	model.m = s.m.Mul(viewMatrix(v))
+	if r.d.Header.PSLTScale {
+		r.ltView = v.Height / v.ViewHeight
+	}
+	r.block(r.d.ModelSpace(), model)
+	box := clip.Bounds()
+	r.namedViews(false, model.m, box)
+	for _, f := range r.late {
+		f(box)
+	}
+	r.late, r.extra, r.frozen, r.ltView = late, extra, nil, 0
+
+	for i := n; i < len(r.items); i++ {
+		r.items[i].Clip = clip
+	}
+	// Links seen through the viewport are cut to it like their items.
+	kept := r.links[:links]
+	for _, l := range r.links[links:] {
+		if l.Area = intersect(l.Area, box); l.Area.Valid {
+			kept = append(kept, l)
+		}
+	}
+	r.links = kept
+	r.clips = append(r.clips, clip)
+}
+
+// viewportClip returns the outline of a viewport in sheet space and
+// whether it comes from a clipping entity, which draws itself.
+func (r *renderer) viewportClip(v *drawing.Viewport, s scope) (*Path, bool) {
+	if v.Status&drawing.ViewportNonRectClip != 0 && v.ClipBoundary != 0 && r.paper != nil {
+		for _, e := range r.paper.Entities {
+			if e.Props().Handle != v.ClipBoundary {
+				continue
+			}
+			if p := r.outline(e, s); !p.Empty() {
+				p.Close()
+				return p, true
+			}
+		}
+	}
+	w, h := v.Width/2, v.Height/2
+	p := &Path{}
+	pen{p, s.m}.polygon(
+		v.Center.Add(geom.Vec3{X: -w, Y: -h}),
+		v.Center.Add(geom.Vec3{X: w, Y: -h}),
+		v.Center.Add(geom.Vec3{X: w, Y: h}),
+		v.Center.Add(geom.Vec3{X: -w, Y: h}),
+	)
+	return p, false
+}
+
+// outline returns the paths an entity draws, joined into one.
+func (r *renderer) outline(e drawing.Entity, s scope) *Path {
+	n := len(r.items)
+	r.continuous = true
+	r.entity(e, s)
+	r.continuous = false
+	p := &Path{}
+	for _, it := range r.items[n:] {
+		if it.Text == nil {
+			p.Ops = append(p.Ops, it.Path.Ops...)
+			p.Pts = append(p.Pts, it.Path.Pts...)
+		}
+	}
+	r.items = r.items[:n]
+	return p
+}
+
+// viewMatrix maps WCS to the paper space of a viewport: the view's DCS
+// scaled and shifted to put the view center on the viewport center.
+func viewMatrix(v *drawing.Viewport) geom.Matrix {
+	k := v.Height / v.ViewHeight
+	return geom.Translate(v.Center).
+		Mul(geom.Scale(geom.Vec3{X: k, Y: k, Z: k})).
+		Mul(geom.Translate(v.ViewCenter.Vec3(0).Scale(-1))).
+		Mul(dcs(v.ViewTarget, v.ViewDirection, v.TwistAngle))
+}
+
+// dcs maps WCS to the display coordinate system of a view: a parallel
+// projection along dir onto the plane through target, with X horizontal
+// in the view before the twist.
+func dcs(target, dir geom.Vec3, twist float64) geom.Matrix {
+	z := dir.Unit()
+	if z.IsZero() {
+		z = geom.ZAxis
+	}
+	x := geom.Vec3{X: 1}
+	if math.Abs(z.X) > 1e-9 || math.Abs(z.Y) > 1e-9 {
+		x = geom.ZAxis.Cross(z).Unit()
+	}
+	y := z.Cross(x)
+	// The rows of the rotation are the DCS axes.
+	return geom.RotateZ(twist).
+		Mul(geom.Matrix{x.X, x.Y, x.Z, 0, y.X, y.Y, y.Z, 0, z.X, z.Y, z.Z, 0}).
+		Mul(geom.Translate(target.Scale(-1)))
+}
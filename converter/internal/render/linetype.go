@@ -0,0 +1,192 @@
+package render
+
+import (
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// maxDashes limits the elements a pattern is repeated into along one
+// subpath; denser patterns are drawn solid, as they would look on paper.
+const maxDashes = 20000
+
+// dashed strokes p with the pattern of a linetype, scaled by st.ltScale.
+// Each subpath starts the pattern afresh. Subpaths shorter than one
+// repetition are drawn solid so that short segments do not vanish into a
+// gap.
+func (r *renderer) dashed(p Path, lt *drawing.Linetype, st style) {
+	var period float64
+	for _, e := range lt.Elements {
+		period += math.Abs(e.Length)
+	}
+	period *= st.ltScale
+	if period <= 0 || math.IsInf(period, 0) || math.IsNaN(period) {
+		r.solid(p, st)
+		return
+	}
+
+	var out, solid Path
+	for _, sub := range subpaths(p) {
+		line := sub.Flatten()
+		if len(line) == 0 {
+			continue
+		}
+		pts := line[0]
+		if sub.Ops[len(sub.Ops)-1] == Close && len(pts) > 1 {
+			pts = append(pts, pts[0])
+		}
+		dist := cumulative(pts)
+		length := dist[len(dist)-1]
+		if length < period || length/period*float64(len(lt.Elements)) > maxDashes {
+			solid.Ops = append(solid.Ops, sub.Ops...)
+			solid.Pts = append(solid.Pts, sub.Pts...)
+			continue
+		}
+		r.pattern(&out, pts, dist, lt, st)
+	}
+	r.solid(out, st)
+	r.solid(solid, st)
+}
+
+// pattern repeats the elements of lt along the polyline pts, whose
+// cumulative lengths are dist, adding dashes and dots to out and drawing
+// the text and shapes of complex elements.
+func (r *renderer) pattern(out *Path, pts []geom.Vec2, dist []float64, lt *drawing.Linetype, st style) {
+	length := dist[len(dist)-1]
+	for pos, i := 0.0, 0; pos < length; i = (i + 1) % len(lt.Elements) {
+		e := lt.Elements[i]
+		end := pos + math.Abs(e.Length)*st.ltScale
+		if e.Length >= 0 {
+			along(out, pts, dist, pos, math.Min(end, length))
+		}
+		if e.Text != "" || e.Shape != 0 {
+			r.embedded(e, pts, dist, end, st)
+		}
+		pos = end
+	}
+}
+
+// embedded draws the text or shape of a complex element at distance d
+// along the polyline, moved by the element's offset along and across the
+// line and turned relative to it unless its rotation is absolute.
+func (r *renderer) embedded(e drawing.LinetypeElement, pts []geom.Vec2, dist []float64, d float64, st style) {
+	k := st.ltScale
+	at, dir := pointAt(pts, dist, d)
+	angle := e.Rotation
+	if !e.Absolute {
+		angle += dir.Angle()
+	}
+	origin := at.
+		Add(dir.Scale(e.Offset.X * k)).
+		Add(dir.Perp().Scale(e.Offset.Y * k))
+	frame := geom.Translate(origin.Vec3(0)).Mul(geom.RotateZ(angle))
+	size := e.Scale
+	if size == 0 {
+		size = 1
+	}
+	size *= k
+
+	if e.Text != "" {
+		f := format{font: r.font(e.Style), shx: r.shx(e.Style), fonts: r.fonts, height: size, width: 1}
+		if ts := r.d.Style(e.Style); ts != nil {
+			if ts.Height > 0 {
+				f.height *= ts.Height
+			}
+			if ts.WidthFactor > 0 {
+				f.width = ts.WidthFactor
+			}
+			f.oblique = ts.Oblique
+		}
+		r.emit([]run{{text: e.Text, format: f}}, frame, st)
+		return
+	}
+
+	shapes := r.fonts.Shapes(e.ShapeFile)
+	if shapes == nil {
+		return
+	}
+	lines, _, ok := shapes.Strokes(e.Shape)
+	if !ok {
+		return
+	}
+	if shapes.Above > 0 {
+		size /= shapes.Above
+	}
+	var p Path
+	n := pen{&p, frame.Mul(geom.Scale(geom.Vec3{X: size, Y: size, Z: 1}))}
+	for _, l := range lines {
+		for j, v := range l {
+			if j == 0 {
+				n.moveTo(v.Vec3(0))
+			} else {
+				n.lineTo(v.Vec3(0))
+			}
+		}
+	}
+	r.solid(p, st)
+}
+
+// subpaths splits p at each MoveTo.
+func subpaths(p Path) []Path {
+	var out []Path
+	pts := p.Pts
+	for _, op := range p.Ops {
+		if op == MoveTo || len(out) == 0 {
+			out = append(out, Path{})
+		}
+		cur := &out[len(out)-1]
+		n := 0
+		switch op {
+		case MoveTo, LineTo:
+			n = 1
+		case CubicTo:
+			n = 3
+		}
+		cur.Ops = append(cur.Ops, op)
+		cur.Pts = append(cur.Pts, pts[:n]...)
+		pts = pts[n:]
+	}
+	return out
+}
+
+// cumulative returns the distance along a polyline to each of its points.
+func cumulative(pts []geom.Vec2) []float64 {
+	dist := make([]float64, len(pts))
+	for i := 1; i < len(pts); i++ {
+		dist[i] = dist[i-1] + pts[i].Dist(pts[i-1])
+	}
+	return dist
+}
+
+// pointAt returns the point at distance d along a polyline and the unit
+// direction of the line there.
+func pointAt(pts []geom.Vec2, dist []float64, d float64) (geom.Vec2, geom.Vec2) {
+	if len(pts) < 2 {
+		return pts[0], geom.Vec2{X: 1}
+	}
+	i := 1
+	for i < len(pts)-1 && dist[i] < d {
+		i++
+	}
+	a, b := pts[i-1], pts[i]
+	dir := b.Sub(a).Unit()
+	if seg := dist[i] - dist[i-1]; seg > 0 {
+		return a.Lerp(b, (d-dist[i-1])/seg), dir
+	}
+	return a, dir
+}
+
+// along adds the part of a polyline from distance d0 to d1 to out; when
+// they are equal it adds a dot, which round caps draw.
+func along(out *Path, pts []geom.Vec2, dist []float64, d0, d1 float64) {
+	start, _ := pointAt(pts, dist, d0)
+	out.MoveTo(start)
+	for i := 1; i < len(pts)-1; i++ {
+		if dist[i] > d0 && dist[i] < d1 {
+			out.LineTo(pts[i])
+		}
+	}
+	end, _ := pointAt(pts, dist, d1)
+	out.LineTo(end)
+}
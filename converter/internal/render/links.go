@@ -0,0 +1,17 @@
+package render
+
+import (
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// link makes the area of the items drawn since the first n a link to h.
+func (r *renderer) link(h *drawing.Hyperlink, n int) {
+	var area geom.Box
+	for i := n; i < len(r.items); i++ {
+		area.Union(r.items[i].bounds())
+	}
+	if area.Valid {
+		r.links = append(r.links, Link{Hyperlink: h, Area: area})
+	}
+}
@@ -0,0 +1,576 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// Paragraph alignments.
+const (
+	alignLeft = iota
+	alignCenter
+	alignRight
+)
+
+// Proportions of stacked text relative to the line height.
+const (
+	stackScale = 0.7
+	stackGap   = 0.15
+)
+
+type pieceKind int
+
+const (
+	pieceText pieceKind = iota
+	pieceSpace
+	pieceTab
+	pieceParagraph
+	pieceColumn
+)
+
+// piece is a unit of MTEXT content after its formatting codes have been
+// applied. Consecutive text pieces form words, which are only broken at
+// spaces.
+type piece struct {
+	kind pieceKind
+	text string
+	format
+	// stack holds the upper and lower parts of stacked text and how they
+	// are stacked: '/' over a bar, '#' diagonally or '^' as tolerance.
+	stack     *[2]string
+	stackKind byte
+	align     int
+}
+
+// mtextParser expands the inline codes of MTEXT into pieces.
+type mtextParser struct {
+	r      *renderer
+	s      string
+	pos    int
+	cur    format
+	align  int
+	saved  []format
+	word   strings.Builder
+	pieces []piece
+}
+
+func (p *mtextParser) flush() {
+	if p.word.Len() > 0 {
+		p.pieces = append(p.pieces, piece{text: p.word.String(), format: p.cur, align: p.align})
+		p.word.Reset()
+	}
+}
+
+func (p *mtextParser) add(kind pieceKind) {
+	p.flush()
+	p.pieces = append(p.pieces, piece{kind: kind, format: p.cur, align: p.align})
+}
+
+// arg reads the argument of a code up to its terminating semicolon.
+func (p *mtextParser) arg() string {
+	end := strings.IndexByte(p.s[p.pos:], ';')
+	if end < 0 {
+		end = len(p.s) - p.pos
+	}
+	v := p.s[p.pos : p.pos+end]
+	p.pos = min(p.pos+end+1, len(p.s))
+	return v
+}
+
+// stackArg reads the argument of \S, where \; is an escaped semicolon.
+func (p *mtextParser) stackArg() string {
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == ';':
+			return b.String()
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte('\\')
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (p *mtextParser) parse() []piece {
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			p.code()
+		case '{':
+			p.flush()
+			p.saved = append(p.saved, p.cur)
+		case '}':
+			p.flush()
+			if n := len(p.saved); n > 0 {
+				p.cur, p.saved = p.saved[n-1], p.saved[:n-1]
+			}
+		case ' ':
+			p.add(pieceSpace)
+		case '\t':
+			p.add(pieceTab)
+		case '\n':
+			p.add(pieceParagraph)
+		case '\r':
+		case '^':
+			// Caret notation for control characters.
+			if p.pos < len(p.s) {
+				switch p.s[p.pos] {
+				case 'I':
+					p.pos++
+					p.add(pieceTab)
+					continue
+				case 'J':
+					p.pos++
+					p.add(pieceParagraph)
+					continue
+				case 'M':
+					p.pos++
+					continue
+				}
+			}
+			p.word.WriteByte(c)
+		case '%':
+			if strings.HasPrefix(p.s[p.pos:], "%") && p.pos+1 < len(p.s) {
+				if r, ok := special(p.s[p.pos+1]); ok {
+					p.word.WriteRune(r)
+					p.pos += 2
+					continue
+				}
+			}
+			p.word.WriteByte(c)
+		default:
+			p.word.WriteByte(c)
+		}
+	}
+	p.flush()
+	return p.pieces
+}
+
+// code handles the formatting code after a backslash.
+func (p *mtextParser) code() {
+	if p.pos >= len(p.s) {
+		return
+	}
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'P', 'X':
+		p.add(pieceParagraph)
+	case 'N':
+		p.add(pieceColumn)
+	case '~':
+		p.word.WriteRune(' ')
+	case '\\', '{', '}':
+		p.word.WriteByte(c)
+	case 'L', 'l', 'O', 'o', 'K', 'k':
+		p.flush()
+		on := c < 'a'
+		switch c | 0x20 {
+		case 'l':
+			p.cur.under = on
+		case 'o':
+			p.cur.over = on
+		case 'k':
+			p.cur.strike = on
+		}
+	case 'f', 'F':
+		p.flush()
+		name, _, _ := strings.Cut(p.arg(), "|")
+		if c == 'f' {
+			p.cur.font, p.cur.shx = p.r.fonts.Lookup("", name), nil
+		} else {
+			p.cur.font, p.cur.shx = p.r.fonts.Lookup(name, ""), p.r.shxFile(name, "")
+		}
+	case 'H':
+		p.flush()
+		if v, rel := relative(p.arg()); v > 0 {
+			if rel {
+				p.cur.height *= v
+			} else {
+				p.cur.height = v
+			}
+		}
+	case 'W':
+		p.flush()
+		if v, rel := relative(p.arg()); v > 0 {
+			if rel {
+				p.cur.width *= v
+			} else {
+				p.cur.width = v
+			}
+		}
+	case 'Q':
+		p.flush()
+		if v, err := strconv.ParseFloat(p.arg(), 64); err == nil {
+			p.cur.oblique = geom.Rad(v)
+		}
+	case 'C':
+		p.flush()
+		if v, err := strconv.Atoi(p.arg()); err == nil {
+			p.cur.color = nil
+			if v > 0 && v < 256 {
+				p.cur.color = &drawing.Color{Index: int16(v)}
+			}
+		}
+	case 'c':
+		p.flush()
+		// The true color is stored with red in the low byte.
+		if v, err := strconv.ParseUint(p.arg(), 10, 32); err == nil {
+			rgb := uint32(v)&0xff<<16 | uint32(v)&0xff00 | uint32(v)>>16&0xff
+			p.cur.color = &drawing.Color{True: true, RGB: rgb}
+		}
+	case 'p':
+		for _, prop := range strings.Split(p.arg(), ",") {
+			prop = strings.TrimPrefix(prop, "x")
+			if len(prop) == 2 && prop[0] == 'q' {
+				switch prop[1] {
+				case 'l', 'j', 'd':
+					p.align = alignLeft
+				case 'c':
+					p.align = alignCenter
+				case 'r':
+					p.align = alignRight
+				}
+			}
+		}
+	case 'T', 'A':
+		// Tracking and vertical alignment within the line are not drawn.
+		p.arg()
+	case 'S':
+		p.flush()
+		p.stackPiece(p.stackArg())
+	case 'U':
+		if strings.HasPrefix(p.s[p.pos:], "+") && p.pos+5 <= len(p.s) {
+			if v, err := strconv.ParseUint(p.s[p.pos+1:p.pos+5], 16, 32); err == nil {
+				p.word.WriteRune(rune(v))
+				p.pos += 5
+			}
+		}
+	case 'M':
+		// \M+nXXXX is a double-byte character of a code page.
+		if r, ok := dbcs(p.s[p.pos-2:]); ok {
+			p.word.WriteRune(r)
+		}
+		p.pos = min(p.pos+6, len(p.s))
+	}
+}
+
+func (p *mtextParser) stackPiece(v string) {
+	var upper, lower strings.Builder
+	kind := byte(0)
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == '\\' && i+1 < len(v) {
+			i++
+			c = v[i]
+		} else if kind == 0 && (c == '/' || c == '#' || c == '^') {
+			kind = c
+			continue
+		}
+		if kind == 0 {
+			upper.WriteByte(c)
+		} else {
+			lower.WriteByte(c)
+		}
+	}
+	if kind == 0 {
+		p.word.WriteString(upper.String())
+		return
+	}
+	p.pieces = append(p.pieces, piece{
+		format:    p.cur,
+		stack:     &[2]string{strings.TrimSpace(upper.String()), strings.TrimSpace(lower.String())},
+		stackKind: kind,
+		align:     p.align,
+	})
+}
+
+// relative parses a height or width factor, which is relative to the
+// current value when it ends in x.
+func relative(v string) (float64, bool) {
+	rel := strings.HasSuffix(v, "x") || strings.HasSuffix(v, "X")
+	f, err := strconv.ParseFloat(strings.TrimRight(v, "xX"), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, rel
+}
+
+// box is a piece laid out: runs and bars relative to the start of its
+// baseline.
+type box struct {
+	runs   []run
+	bars   [][2]geom.Vec2
+	width  float64
+	height float64
+	align  int
+}
+
+func (pc *piece) box() box {
+	b := box{height: pc.height, align: pc.align}
+	switch {
+	case pc.stack != nil:
+		f := pc.format
+		f.height *= stackScale
+		upper, lower := pc.stack[0], pc.stack[1]
+		wu, wl := f.advance(upper), f.advance(lower)
+		h := pc.height
+		switch pc.stackKind {
+		case '#':
+			slash := f.advance("/")
+			b.runs = []run{
+				{text: upper, format: f, y: h * (1 - stackScale)},
+				{text: lower, format: f, x: wu + slash},
+			}
+			b.bars = [][2]geom.Vec2{{{X: wu + slash*0.2, Y: -h * stackGap}, {X: wu + slash*0.8, Y: h}}}
+			b.width = wu + slash + wl
+		default:
+			b.width = max(wu, wl)
+			mid := h / 2
+			ux, lx := (b.width-wu)/2, (b.width-wl)/2
+			if pc.stackKind == '^' {
+				ux, lx = 0, 0
+			}
+			b.runs = []run{
+				{text: upper, format: f, x: ux, y: mid + h*stackGap/2},
+				{text: lower, format: f, x: lx, y: mid - h*stackGap/2 - f.height},
+			}
+			if pc.stackKind == '/' {
+				b.bars = [][2]geom.Vec2{{{Y: mid}, {X: b.width, Y: mid}}}
+			}
+		}
+	case pc.kind == pieceSpace:
+		b.width = pc.advance(" ")
+		b.runs = []run{{text: " ", format: pc.format}}
+	case pc.kind == pieceTab:
+		b.width = pc.advance("    ")
+	default:
+		b.width = pc.advance(pc.text)
+		b.runs = []run{{text: pc.text, format: pc.format}}
+	}
+	return b
+}
+
+// line is a laid out line of MTEXT.
+type line struct {
+	boxes  []box
+	width  float64
+	height float64
+	align  int
+	column int
+	// baseline is the offset of the baseline below the column top.
+	baseline float64
+	// columnBreak starts a new column with this line.
+	columnBreak bool
+}
+
+// breakLines lays out the pieces in lines, breaking at paragraphs and at
+// the spaces before words that would exceed the wrap width.
+func breakLines(pieces []piece, wrap float64, align int) []*line {
+	var lines []*line
+	cur := &line{align: align}
+	var pending []box // spaces before the next word
+	newLine := func() {
+		lines = append(lines, cur)
+		cur = &line{align: cur.align}
+		pending = nil
+	}
+	addWord := func(word []box) {
+		var ww, sw float64
+		for _, b := range word {
+			ww += b.width
+		}
+		for _, b := range pending {
+			sw += b.width
+		}
+		if wrap > 0 && len(cur.boxes) > 0 && cur.width+sw+ww > wrap {
+			newLine()
+		}
+		if len(cur.boxes) == 0 && len(word) > 0 {
+			cur.align = word[0].align
+		}
+		for _, b := range append(pending, word...) {
+			cur.boxes = append(cur.boxes, b)
+			cur.width += b.width
+			cur.height = max(cur.height, b.height)
+		}
+		pending = nil
+	}
+	var word []box
+	for i := range pieces {
+		pc := &pieces[i]
+		switch pc.kind {
+		case pieceSpace, pieceTab:
+			addWord(word)
+			word = nil
+			if len(cur.boxes) > 0 || pc.kind == pieceTab {
+				pending = append(pending, pc.box())
+			}
+		case pieceParagraph, pieceColumn:
+			addWord(word)
+			word = nil
+			if cur.height == 0 {
+				cur.height = pc.height
+			}
+			cur.align = pc.align
+			newLine()
+			cur.columnBreak = pc.kind == pieceColumn
+		default:
+			word = append(word, pc.box())
+		}
+	}
+	addWord(word)
+	if len(cur.boxes) > 0 {
+		newLine()
+	}
+	return lines
+}
+
+// mtext draws multiline text: it wraps words at the reference rectangle
+// or column width, flows lines into columns and places the block by its
+// attachment point.
+func (r *renderer) mtext(e *drawing.MText, s scope, st style) {
+	base := format{
+		font:   r.font(e.Style),
+		shx:    r.shx(e.Style),
+		fonts:  r.fonts,
+		height: r.textHeight(e.Height, e.Style),
+		width:  1,
+	}
+	if ts := r.d.Style(e.Style); ts != nil {
+		if ts.WidthFactor > 0 {
+			base.width = ts.WidthFactor
+		}
+		base.oblique = ts.Oblique
+	}
+	if base.height <= 0 {
+		return
+	}
+	attach := e.Attachment
+	if attach < drawing.MTextTopLeft || attach > drawing.MTextBottomRight {
+		attach = drawing.MTextTopLeft
+	}
+	hAttach, vAttach := (attach-1)%3, (attach-1)/3
+
+	p := &mtextParser{r: r, s: e.Value, cur: base, align: hAttach}
+	pieces := p.parse()
+
+	wrap := e.RectWidth
+	columns := e.ColumnType != 0 && e.ColumnWidth > 0
+	if columns {
+		wrap = e.ColumnWidth
+	}
+	columnHeight := func(i int) float64 {
+		if i < len(e.ColumnHeights) && e.ColumnHeights[i] > 0 {
+			return e.ColumnHeights[i]
+		}
+		if columns {
+			return e.RectHeight
+		}
+		return 0
+	}
+	spacing := e.LineSpacingFactor
+	if spacing <= 0 {
+		spacing = 1
+	}
+
+	lines := breakLines(pieces, wrap, hAttach)
+	if len(lines) == 0 {
+		return
+	}
+
+	// Stack the lines, moving to the next column when one is full.
+	column, baseline := 0, 0.0
+	var blockHeight, blockWidth float64
+	for i, l := range lines {
+		switch {
+		case i == 0:
+			baseline = -l.height
+		case columns && l.columnBreak:
+			column++
+			baseline = -l.height
+		default:
+			h := l.height
+			if e.LineSpacingStyle == 2 {
+				// Exact spacing ignores larger characters on the line.
+				h = base.height
+			}
+			baseline -= h * 5 / 3 * spacing
+			if ch := columnHeight(column); columns && ch > 0 && -baseline+l.height/3 > ch {
+				column++
+				baseline = -l.height
+			}
+		}
+		l.baseline, l.column = baseline, column
+		blockHeight = max(blockHeight, -baseline+l.height/3)
+		blockWidth = max(blockWidth, l.width)
+	}
+	if wrap > 0 {
+		blockWidth = wrap
+	}
+
+	var runs []run
+	var bars [][2]geom.Vec2
+	dy := float64(vAttach) * blockHeight / 2
+	dx := -float64(hAttach) * blockWidth / 2
+	if columns {
+		dx = -float64(hAttach) * (float64(column+1)*(e.ColumnWidth+e.ColumnGutter) - e.ColumnGutter) / 2
+	}
+	for _, l := range lines {
+		x := dx + (blockWidth-l.width)*float64(l.align)/2
+		if columns {
+			x += float64(l.column) * (e.ColumnWidth + e.ColumnGutter)
+		}
+		y := dy + l.baseline
+		for _, b := range l.boxes {
+			for _, rn := range b.runs {
+				rn.x += x
+				rn.y += y
+				runs = append(runs, rn)
+			}
+			for _, bar := range b.bars {
+				off := geom.Vec2{X: x, Y: y}
+				bars = append(bars, [2]geom.Vec2{bar[0].Add(off), bar[1].Add(off)})
+			}
+			x += b.width
+		}
+	}
+
+	frame := s.m.Mul(geom.Translate(e.Position)).Mul(mtextAxes(e))
+	r.emit(runs, frame, st)
+	if len(bars) > 0 {
+		var p Path
+		n := pen{&p, frame}
+		for _, bar := range bars {
+			n.moveTo(bar[0].Vec3(0))
+			n.lineTo(bar[1].Vec3(0))
+		}
+		r.stroke(p, st.continuous())
+	}
+}
+
+// mtextAxes returns the rotation of MTEXT: its X direction in the plane
+// of its extrusion.
+func mtextAxes(e *drawing.MText) geom.Matrix {
+	z := e.Extrusion.Unit()
+	if z.IsZero() {
+		z = geom.ZAxis
+	}
+	x := e.XDirection
+	x = x.Sub(z.Scale(x.Dot(z))).Unit()
+	if x.IsZero() {
+		x = geom.OCS(z).ApplyVec(geom.Vec3{X: 1})
+	}
+	return geom.Axes(x, z.Cross(x), z)
+}
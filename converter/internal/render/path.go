@@ -0,0 +1,170 @@
+package render
+
+import (
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+type Op uint8
+
+const (
+	MoveTo Op = iota
+	LineTo
+	// CubicTo consumes two control points and the end point.
+	CubicTo
+	Close
+)
+
+// Path is a sequence of subpaths. Pts holds the points of all operators
+// in order: one for MoveTo and LineTo, three for CubicTo, none for Close.
+type Path struct {
+	Ops []Op
+	Pts []geom.Vec2
+}
+
+func (p *Path) MoveTo(v geom.Vec2) {
+	p.Ops = append(p.Ops, MoveTo)
+	p.Pts = append(p.Pts, v)
+}
+
+func (p *Path) LineTo(v geom.Vec2) {
+	p.Ops = append(p.Ops, LineTo)
+	p.Pts = append(p.Pts, v)
+}
+
+func (p *Path) CubicTo(c1, c2, v geom.Vec2) {
+	p.Ops = append(p.Ops, CubicTo)
+	p.Pts = append(p.Pts, c1, c2, v)
+}
+
+func (p *Path) Close() { p.Ops = append(p.Ops, Close) }
+
+func (p *Path) Empty() bool { return len(p.Ops) == 0 }
+
+// Transform maps all points through the XY part of m.
+func (p *Path) Transform(m geom.Matrix) {
+	for i, v := range p.Pts {
+		p.Pts[i] = m.Apply2(v)
+	}
+}
+
+// Bounds returns the box of all points, control points included.
+func (p *Path) Bounds() geom.Box {
+	var b geom.Box
+	for _, v := range p.Pts {
+		b.Add(v)
+	}
+	return b
+}
+
+// pen appends geometry given in an entity's own coordinates to a path,
+// mapping every point through m first. Affine maps keep Bézier curves
+// exact, so arcs can be built before the transform.
+type pen struct {
+	p *Path
+	m geom.Matrix
+}
+
+func (n pen) at(v geom.Vec3) geom.Vec2 { return n.m.Apply(v).XY() }
+
+func (n pen) moveTo(v geom.Vec3) { n.p.MoveTo(n.at(v)) }
+func (n pen) lineTo(v geom.Vec3) { n.p.LineTo(n.at(v)) }
+func (n pen) close()             { n.p.Close() }
+
+func (n pen) polygon(pts ...geom.Vec3) {
+	for i, v := range pts {
+		if i == 0 {
+			n.moveTo(v)
+		} else {
+			n.lineTo(v)
+		}
+	}
+	n.close()
+}
+
+// ellipse appends the curve c + u·cos t + v·sin t for t from t0 to t1,
+// which may run backwards. It starts a new subpath when move is set and
+// otherwise continues from the current point, which must be at t0.
+func (n pen) ellipse(c, u, v geom.Vec3, t0, t1 float64, move bool) {
+	at := func(t float64) geom.Vec3 {
+		s, co := math.Sincos(t)
+		return c.Add(u.Scale(co)).Add(v.Scale(s))
+	}
+	tangent := func(t float64) geom.Vec3 {
+		s, co := math.Sincos(t)
+		return u.Scale(-s).Add(v.Scale(co))
+	}
+	if move {
+		n.moveTo(at(t0))
+	}
+	sweep := t1 - t0
+	segs := int(math.Ceil(math.Abs(sweep) / (math.Pi / 2)))
+	if segs == 0 {
+		return
+	}
+	dt := sweep / float64(segs)
+	k := 4.0 / 3 * math.Tan(dt/4)
+	for i := range segs {
+		a := t0 + float64(i)*dt
+		b := a + dt
+		p0, p1 := at(a), at(b)
+		n.p.CubicTo(n.at(p0.Add(tangent(a).Scale(k))), n.at(p1.Sub(tangent(b).Scale(k))), n.at(p1))
+	}
+}
+
+func (n pen) circle(c geom.Vec3, r float64) {
+	n.ellipse(c, geom.Vec3{X: r}, geom.Vec3{Y: r}, 0, 2*math.Pi, true)
+	n.close()
+}
+
+// curveSteps is the number of line segments a cubic Bézier is flattened
+// into.
+const curveSteps = 16
+
+// Flatten returns the subpaths as polylines with curves replaced by line
+// segments. Closed subpaths do not repeat their first point.
+func (p *Path) Flatten() [][]geom.Vec2 {
+	var out [][]geom.Vec2
+	var cur []geom.Vec2
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, cur)
+		}
+		cur = nil
+	}
+	pts := p.Pts
+	for _, op := range p.Ops {
+		switch op {
+		case MoveTo:
+			flush()
+			cur = []geom.Vec2{pts[0]}
+			pts = pts[1:]
+		case LineTo:
+			cur = append(cur, pts[0])
+			pts = pts[1:]
+		case CubicTo:
+			var p0 geom.Vec2
+			if len(cur) > 0 {
+				p0 = cur[len(cur)-1]
+			}
+			for i := 1; i <= curveSteps; i++ {
+				t := float64(i) / curveSteps
+				cur = append(cur, bezier(p0, pts[0], pts[1], pts[2], t))
+			}
+			pts = pts[3:]
+		case Close:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+func bezier(p0, p1, p2, p3 geom.Vec2, t float64) geom.Vec2 {
+	s := 1 - t
+	return p0.Scale(s * s * s).
+		Add(p1.Scale(3 * s * s * t)).
+		Add(p2.Scale(3 * s * t * t)).
+		Add(p3.Scale(t * t * t))
+}
@@ -0,0 +1,199 @@
+package render
+
+import (
+	"math"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/plotstyle"
+)
+
+// Orientation turns the paper of every sheet.
+type Orientation int
+
+const (
+	// OrientationDefault keeps the orientation of the page setup.
+	OrientationDefault Orientation = iota
+	Portrait
+	Landscape
+)
+
+// page is a sheet of paper and where the plot area goes on it, in points.
+// The sheet is the paper as the plot sees it, already turned.
+type page struct {
+	width, height float64
+	// printable is the part of the sheet inside the margins.
+	printable geom.Box
+	// scale is in points per drawing unit; zero fits the plot area into
+	// the printable area.
+	scale float64
+	// centered puts the plot area in the middle of the printable area;
+	// otherwise anchor goes to its lower left corner, moved by offset.
+	centered bool
+	anchor   geom.Vec2
+	offset   geom.Vec2
+	// upsideDown turns the plot half around on the sheet.
+	upsideDown bool
+}
+
+// newPage sets up the paper for a plot of area with the page setup ps
+// and the overrides in opts. Layouts without a paper size get an A4 sheet
+// turned to match the area, with the margin of opts. Overrides centre the
+// plot, as the page setup's offset belongs to its own paper and scale.
+func newPage(ps *drawing.PlotSettings, area geom.Box, opts Options) page {
+	w, h := A4Width, A4Height
+	// Left, bottom, right and top.
+	margins := [4]float64{opts.Margin, opts.Margin, opts.Margin, opts.Margin}
+	landscape := area.Width() >= area.Height()
+	var pg page
+	if ps.HasPaper() {
+		w, h = ps.PaperWidth*PointsPerMM, ps.PaperHeight*PointsPerMM
+		margins = [4]float64{ps.MarginLeft, ps.MarginBottom, ps.MarginRight, ps.MarginTop}
+		for i := range margins {
+			margins[i] *= PointsPerMM
+		}
+		for range ps.Rotation {
+			w, h = h, w
+			margins = [4]float64{margins[1], margins[2], margins[3], margins[0]}
+		}
+		landscape = w > h
+		pg.upsideDown = ps.Rotation >= 2
+	}
+
+	switch opts.Orientation {
+	case Portrait:
+		landscape = false
+	case Landscape:
+		landscape = true
+	}
+	if opts.PageWidth > 0 && opts.PageHeight > 0 {
+		w, h = opts.PageWidth, opts.PageHeight
+	}
+	if w != h && (w > h) != landscape {
+		w, h = h, w
+		margins = [4]float64{margins[1], margins[2], margins[3], margins[0]}
+	}
+	pg.width, pg.height = w, h
+	pg.printable.Add(geom.Vec2{X: margins[0], Y: margins[1]})
+	pg.printable.Add(geom.Vec2{X: w - margins[2], Y: h - margins[3]})
+
+	unit := PointsPerMM
+	if ps.Inches && ps.HasPaper() {
+		unit = 72
+	}
+	switch s, ok := ps.Scale(); {
+	case opts.Fit:
+	case opts.Scale > 0:
+		pg.scale = opts.Scale * unit
+	case ok && ps.HasPaper():
+		pg.scale = s * unit
+	}
+
+	overridden := opts.PageWidth > 0 || opts.Orientation != OrientationDefault || opts.Scale > 0
+	pg.centered = !ps.HasPaper() || ps.Flags&drawing.PlotCentered != 0 || overridden
+	pg.anchor = area.Min
+	if ps.Area == drawing.PlotLayout {
+		pg.anchor = geom.Vec2{}
+	}
+	pg.offset = ps.Origin.Scale(PointsPerMM)
+	return pg
+}
+
+// transform returns the transform that puts area on the sheet.
+func (pg page) transform(area geom.Box) geom.Matrix {
+	var m geom.Matrix
+	switch {
+	case !area.Valid:
+		m = geom.Identity()
+	case pg.scale <= 0:
+		m = fit(area, pg.printable)
+	case pg.centered:
+		m = place(area.Center(), pg.printable.Center(), pg.scale)
+	default:
+		m = place(pg.anchor, pg.printable.Min.Add(pg.offset), pg.scale)
+	}
+	if pg.upsideDown {
+		m = geom.Translate(geom.Vec3{X: pg.width, Y: pg.height}).Mul(geom.RotateZ(math.Pi)).Mul(m)
+	}
+	return m
+}
+
+// place returns the transform that scales by k and moves from to to.
+func place(from, to geom.Vec2, k float64) geom.Matrix {
+	return geom.Translate(to.Vec3(0)).
+		Mul(geom.Scale(geom.Vec3{X: k, Y: k, Z: 1})).
+		Mul(geom.Translate(from.Vec3(0).Scale(-1)))
+}
+
+// fit returns the transform that centres box in the printable area,
+// scaled to fit inside it.
+func fit(box, printable geom.Box) geom.Matrix {
+	scale := 1.0
+	if bw, bh := box.Width(), box.Height(); bw > 0 || bh > 0 {
+		scale = math.Min(printable.Width()/math.Max(bw, 1e-9), printable.Height()/math.Max(bh, 1e-9))
+	}
+	return place(box.Center(), printable.Center(), scale)
+}
+
+// plotView returns the transform into the display coordinates of the
+// view a layout plots and the area of the view, or false when the layout
+// does not plot a view it has.
+func plotView(d *drawing.Drawing, ps *drawing.PlotSettings) (geom.Matrix, geom.Box, bool) {
+	if ps.Area != drawing.PlotView {
+		return geom.Matrix{}, geom.Box{}, false
+	}
+	v := d.View(ps.ViewName)
+	if v == nil || v.Width <= 0 || v.Height <= 0 {
+		return geom.Matrix{}, geom.Box{}, false
+	}
+	var area geom.Box
+	area.Add(v.Center.Sub(geom.Vec2{X: v.Width / 2, Y: v.Height / 2}))
+	area.Add(v.Center.Add(geom.Vec2{X: v.Width / 2, Y: v.Height / 2}))
+	return dcs(v.Target, v.Direction, v.Twist), area, true
+}
+
+// plotArea returns the area of a layout that the page setup plots, given
+// the extents of what was drawn. Areas that cannot be found fall back to
+// the extents.
+func plotArea(d *drawing.Drawing, l *drawing.Layout, extents geom.Box) geom.Box {
+	var area geom.Box
+	ps := &l.Plot
+	switch {
+	case ps.Area == drawing.PlotWindow:
+		area.Add(ps.WindowMin)
+		area.Add(ps.WindowMax)
+	case ps.Area == drawing.PlotLimits, ps.Area == drawing.PlotLayout, !l.IsModel() && !ps.HasPaper():
+		lo, hi := l.LimMin, l.LimMax
+		if l.IsModel() && (hi.X <= lo.X || hi.Y <= lo.Y) {
+			lo, hi = d.Header.LimMin, d.Header.LimMax
+		}
+		area.Add(lo)
+		area.Add(hi)
+	}
+	if !area.Valid || area.Width() <= 0 || area.Height() <= 0 {
+		return extents
+	}
+	return area
+}
+
+// thinLines reports whether a layout strokes every line thin: on screen
+// when the drawing hides lineweights, and in a plot whose page setup
+// plots neither object lineweights nor plot styles.
+func thinLines(d *drawing.Drawing, ps *drawing.PlotSettings, opts Options) bool {
+	if opts.Lineweights == LineweightsDisplay {
+		return !d.Header.LWDisplay
+	}
+	return ps.HasPaper() && ps.Flags&(drawing.PlotLineweights|drawing.PlotPlotStyles) == 0
+}
+
+// plotStyles returns the plot style table a layout plots with: the one of
+// opts, or else the one the page setup names, when it is installed.
+func plotStyles(ps *drawing.PlotSettings, opts Options) *plotstyle.Table {
+	if opts.PlotStyle != nil {
+		return opts.PlotStyle
+	}
+	if ps.Flags&drawing.PlotPlotStyles == 0 || ps.StyleSheet == "" {
+		return nil
+	}
+	return opts.PlotStyles.Lookup(ps.StyleSheet)
+}
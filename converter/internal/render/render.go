@@ -0,0 +1,648 @@
+// Package render turns a drawing into sheets: display lists of filled and
+// stroked paths in page points that the output writers draw as they are.
+package render
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/bitmap"
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+	"github.com/you-humble/dwgtopdf/converter/internal/pattern"
+	"github.com/you-humble/dwgtopdf/converter/internal/plotstyle"
+)
+
+// PointsPerMM converts millimetres on paper to points.
+const PointsPerMM = 72 / 25.4
+
+// Paper sizes in points.
+const (
+	A4Width  = 210 * PointsPerMM
+	A4Height = 297 * PointsPerMM
+)
+
+type RGB struct {
+	R, G, B uint8
+}
+
+func rgb(v uint32) RGB { return RGB{uint8(v >> 16), uint8(v >> 8), uint8(v)} }
+
+// Sheet is one output page with the origin at its lower left corner.
+type Sheet struct {
+	Name          string
+	Width, Height float64
+	Items         []Item
+	// Layers lists the layers of the items in the order of the layer
+	// table.
+	Layers []Layer
+	// Views are the named views shown on the sheet.
+	Views []View
+	// Links are the areas of the entities that have hyperlinks.
+	Links []Link
+}
+
+// Layer is a layer items are on. Off layers only have items when
+// Options.OffLayers keeps them.
+type Layer struct {
+	Name string
+	Off  bool
+}
+
+// View is a named view and the area of the sheet it shows, in points.
+type View struct {
+	Name string
+	Area geom.Box
+}
+
+// Link is the area of the sheet an entity with a hyperlink covers, in
+// points.
+type Link struct {
+	Hyperlink *drawing.Hyperlink
+	Area      geom.Box
+}
+
+// Item is a path that is either filled or stroked, or a line of text
+// filled with Color. A filled path with a Gradient is painted with the
+// gradient instead; Color is then its first color. An Image is drawn
+// clipped to Path, by the even-odd rule when EvenOdd is set. Items seen
+// through a viewport are clipped to its outline, a path shared between
+// them.
+type Item struct {
+	Path    Path
+	Fill    bool
+	EvenOdd bool
+	Color   RGB
+	// Width is the stroke width in points.
+	Width    float64
+	Layer    string
+	Text     *Text
+	Gradient *Gradient
+	Image    *Image
+	Clip     *Path
+}
+
+// Text is a run of text placed by Matrix, which maps text space to the
+// sheet. In text space the baseline starts at the origin and one unit is
+// the text height, the height of capitals in Font.
+type Text struct {
+	Value string
+	// Font is nil when no font could be loaded; writers then use a font
+	// of their own with similar metrics.
+	Font   *font.Font
+	Matrix geom.Matrix
+	// Width is the advance of Value in text space.
+	Width float64
+	// Invisible text is laid over text drawn as strokes, so it can be
+	// searched and copied without being seen.
+	Invisible bool
+}
+
+// Gradient blends colors along the axis from From to To, or, when Radial
+// is set, outwards from the circle around From with radius R0 to the
+// circle around To with radius R1. Stops are in increasing order of
+// Offset, from 0 to 1; the end colors extend beyond the axis.
+type Gradient struct {
+	Radial   bool
+	From, To geom.Vec2
+	R0, R1   float64
+	Stops    []Stop
+}
+
+type Stop struct {
+	Offset float64
+	Color  RGB
+}
+
+// Image is a raster image placed by Matrix, which maps the unit square
+// to the sheet with the lower left corner of the image at the origin.
+// Bilevel images are drawn in the color of their item. Unless
+// Transparent is set, transparent pixels and the background of bilevel
+// images are white.
+type Image struct {
+	Bitmap      *bitmap.Image
+	Matrix      geom.Matrix
+	Transparent bool
+}
+
+// ImageSource finds the files of raster images.
+type ImageSource interface {
+	// Image returns the image of a file as a drawing names it, or nil when
+	// it cannot be found or read.
+	Image(file string) *bitmap.Image
+}
+
+func (g *Gradient) transform(m geom.Matrix) {
+	g.From, g.To = m.Apply2(g.From), m.Apply2(g.To)
+	s := m.ScaleXY()
+	g.R0, g.R1 = g.R0*s, g.R1*s
+}
+
+func (it *Item) transform(m geom.Matrix) {
+	it.Path.Transform(m)
+	if it.Text != nil {
+		it.Text.Matrix = m.Mul(it.Text.Matrix)
+	}
+	if it.Gradient != nil {
+		it.Gradient.transform(m)
+	}
+	if it.Image != nil {
+		it.Image.Matrix = m.Mul(it.Image.Matrix)
+	}
+}
+
+func (it *Item) bounds() geom.Box {
+	b := it.Path.Bounds()
+	if it.Text != nil {
+		w := it.Text.Width
+		for _, p := range []geom.Vec2{{}, {X: w}, {Y: 1}, {X: w, Y: 1}} {
+			b.Add(it.Text.Matrix.Apply2(p))
+		}
+	}
+	if it.Clip != nil && b.Valid {
+		c := it.Clip.Bounds()
+		if !b.Intersects(c) {
+			return geom.Box{}
+		}
+		b.Min = geom.Vec2{X: math.Max(b.Min.X, c.Min.X), Y: math.Max(b.Min.Y, c.Min.Y)}
+		b.Max = geom.Vec2{X: math.Min(b.Max.X, c.Max.X), Y: math.Min(b.Max.Y, c.Max.Y)}
+	}
+	return b
+}
+
+type Options struct {
+	// PageWidth and PageHeight in points replace the paper of the page
+	// setups; when zero each layout is plotted on its own paper, or on an
+	// A4 sheet turned to match the drawing when it has none.
+	PageWidth, PageHeight float64
+	// Orientation turns the paper of every sheet.
+	Orientation Orientation
+	// Scale in paper units per drawing unit replaces the plot scale of
+	// the page setups; Fit scales every sheet to fit its paper instead.
+	// Paper units are millimetres unless a layout plots in inches.
+	Scale float64
+	Fit   bool
+	// Margin in points kept free around the drawing on sheets whose
+	// layout sets no paper.
+	Margin float64
+	// Fonts resolves text styles to fonts; without it text is measured
+	// with built-in metrics.
+	Fonts *font.Set
+	// Patterns defines the hatch patterns drawings name without storing
+	// them; without it only the standard patterns are known.
+	Patterns *pattern.Library
+	// PlotStyle replaces the plot style tables of the page setups, which
+	// are looked up in PlotStyles. Without a table objects plot with
+	// their own colors and lineweights.
+	PlotStyle  *plotstyle.Table
+	PlotStyles *plotstyle.Library
+	// Lineweights chooses between the lineweights of the plot and those
+	// shown on screen.
+	Lineweights LineweightMode
+	// IncludeLayers and ExcludeLayers are layer names and glob patterns,
+	// matched regardless of case. When IncludeLayers is set only the
+	// layers it matches are drawn; layers ExcludeLayers matches are not.
+	// Like layers turned off, filtered layers still show the entities
+	// other layers have in blocks inserted on them.
+	IncludeLayers []string
+	ExcludeLayers []string
+	// OffLayers draws the entities of layers that are turned off, for
+	// writers that let viewers turn layers on; Sheet.Layers tells which
+	// layers are off. Frozen layers stay hidden.
+	OffLayers bool
+	// Images finds the files of raster images; without it, and for files
+	// it cannot find, images are drawn as their frame.
+	Images ImageSource
+	// SHXText chooses how text in SHX fonts is drawn. SHX fonts that are
+	// not installed are always drawn with the fallback TrueType font.
+	SHXText SHXTextMode
+	// MaxEntities lowers the number of entities drawn, for quick
+	// previews; zero draws as many as a render allows.
+	MaxEntities int
+}
+
+// SHXTextMode chooses how text in SHX fonts is drawn.
+type SHXTextMode int
+
+const (
+	// SHXFont draws SHX text with the fallback TrueType font.
+	SHXFont SHXTextMode = iota
+	// SHXStrokes draws the strokes of the SHX font, as AutoCAD plots it.
+	SHXStrokes
+	// SHXOverlay draws the strokes with invisible text over them.
+	SHXOverlay
+)
+
+// LineweightMode chooses how lineweights become stroke widths.
+type LineweightMode int
+
+const (
+	// LineweightsPlot strokes with the lineweights of the plot: those of
+	// the plot style table, else those of the objects, unless the page
+	// setup plots neither.
+	LineweightsPlot LineweightMode = iota
+	// LineweightsDisplay strokes with the lineweights of the objects when
+	// the drawing displays lineweights, and every line thin when it does
+	// not, as on screen.
+	LineweightsDisplay
+)
+
+// maxEntities bounds the entities a render draws, counting those of a
+// block once for every insert of it, so that drawings whose inserts
+// multiply stop before they exhaust memory.
+const maxEntities = 1 << 20
+
+// Render draws each paper space layout that has something to plot on a
+// sheet of its own, in tab order, and model space when there is none.
+// The warnings say when the drawing was too large to draw in full.
+func Render(d *drawing.Drawing, opts Options) ([]*Sheet, []string) {
+	limit := maxEntities
+	if opts.MaxEntities > 0 {
+		limit = min(limit, opts.MaxEntities)
+	}
+	budget := &expansion{left: limit}
+	var sheets []*Sheet
+	for _, l := range d.PaperLayouts() {
+		if s := renderLayout(d, l, opts, budget); s != nil {
+			sheets = append(sheets, s)
+		}
+	}
+	if len(sheets) == 0 {
+		sheets = []*Sheet{renderLayout(d, d.ModelLayout(), opts, budget)}
+	}
+	var warnings []string
+	if budget.stopped {
+		warnings = append(warnings, fmt.Sprintf("the drawing expands to more than %d entities; the rest were left out", limit))
+	}
+	return sheets, warnings
+}
+
+// expansion counts down the entities a render may still draw.
+type expansion struct {
+	left    int
+	stopped bool
+}
+
+func newRenderer(d *drawing.Drawing, opts Options, budget *expansion) *renderer {
+	return &renderer{
+		d:           d,
+		fonts:       opts.Fonts,
+		patterns:    opts.Patterns,
+		lineweights: opts.Lineweights,
+		styleFonts:  make(map[string]*font.Font),
+		styleShapes: make(map[string]*shxFont),
+		shxText:     opts.SHXText,
+		layers:      newLayerFilter(opts.IncludeLayers, opts.ExcludeLayers),
+		offLayers:   opts.OffLayers,
+		images:      opts.Images,
+		budget:      budget,
+	}
+}
+
+// sheet puts what has been drawn on a page, with area where pg says.
+func (r *renderer) sheet(name string, area geom.Box, pg page) *Sheet {
+	for _, f := range r.late {
+		f(area)
+	}
+
+	s := &Sheet{Name: name, Width: pg.width, Height: pg.height, Items: r.items, Layers: r.sheetLayers()}
+	m := pg.transform(area)
+	for i := range s.Items {
+		s.Items[i].transform(m)
+	}
+	for _, v := range r.views {
+		s.Views = append(s.Views, View{Name: v.Name, Area: transformBox(v.Area, m)})
+	}
+	for _, l := range r.links {
+		s.Links = append(s.Links, Link{Hyperlink: l.Hyperlink, Area: transformBox(l.Area, m)})
+	}
+	for _, c := range r.clips {
+		c.Transform(m)
+	}
+	return s
+}
+
+// sheetLayers lists the layers of what has been drawn.
+func (r *renderer) sheetLayers() []Layer {
+	used := make(map[string]bool)
+	for i := range r.items {
+		used[strings.ToUpper(r.items[i].Layer)] = true
+	}
+	var layers []Layer
+	for _, l := range r.d.Layers {
+		if used[strings.ToUpper(l.Name)] {
+			layers = append(layers, Layer{Name: l.Name, Off: l.Off})
+		}
+	}
+	return layers
+}
+
+type renderer struct {
+	d     *drawing.Drawing
+	items []Item
+	fonts *font.Set
+	// patterns resolves hatch pattern names.
+	patterns *pattern.Library
+	// styleFonts caches the font of each text style, and styleShapes its
+	// SHX font when SHX text is drawn as strokes.
+	styleFonts  map[string]*font.Font
+	styleShapes map[string]*shxFont
+	shxText     SHXTextMode
+	// late holds entities that depend on the extents of everything else,
+	// such as rays and points sized relative to the view; extra holds
+	// their anchor points so they still count towards the extents.
+	late  []func(extents geom.Box)
+	extra geom.Box
+
+	// paper is the layout block being drawn, if any; clips holds the
+	// outlines of its viewports.
+	paper *drawing.Block
+	clips []*Path
+	// views are the named views seen on the sheet so far, and links the
+	// areas of the entities with hyperlinks.
+	views []View
+	links []Link
+	// frozen holds the upper-case names of the layers frozen in the
+	// viewport being drawn; it is nil outside of viewports.
+	frozen map[string]bool
+	// styles is the plot style table of the layout, if any.
+	styles *plotstyle.Table
+	// lineweights is how lineweights are stroked; thin is set when the
+	// layout strokes every line thin.
+	lineweights LineweightMode
+	thin        bool
+	// ltView is the scale of the viewport being drawn when PSLTSCALE
+	// scales linetypes to paper space, and zero otherwise.
+	ltView float64
+	// continuous is set while entities are drawn for their outline, which
+	// must not be broken into dashes.
+	continuous bool
+	// layers filters layers by name; offLayers draws layers that are
+	// off.
+	layers    *layerFilter
+	offLayers bool
+	images    ImageSource
+	// budget is shared by the layouts of a render.
+	budget *expansion
+}
+
+// scope is the context a block is drawn in: the transform to WCS and the
+// properties of the enclosing insert, which ByBlock entities and entities
+// on layer 0 inherit.
+type scope struct {
+	m          geom.Matrix
+	layer      string
+	color      drawing.Color
+	lineweight drawing.Lineweight
+	linetype   string
+	plotStyle  string
+	// attribs holds the tags of the attributes the enclosing insert
+	// carries; it is nil outside of block references.
+	attribs map[string]bool
+	// blocks lists the blocks being expanded, outermost first.
+	blocks []*drawing.Block
+}
+
+func (r *renderer) top() scope {
+	return scope{
+		m:          geom.Identity(),
+		color:      drawing.Color{Index: 7},
+		lineweight: drawing.LineweightDefault,
+		linetype:   "Continuous",
+	}
+}
+
+func (r *renderer) block(b *drawing.Block, s scope) {
+	for _, e := range b.Entities {
+		if r.budget.left == 0 {
+			r.budget.stopped = true
+			return
+		}
+		r.budget.left--
+		r.entity(e, s)
+	}
+}
+
+func (r *renderer) bounds() geom.Box {
+	box := r.extra
+	for i := range r.items {
+		box.Union(r.items[i].bounds())
+	}
+	return box
+}
+
+// style holds the properties of an entity with ByLayer and ByBlock
+// resolved, and the plot style table they go through.
+type style struct {
+	layer      string
+	color      drawing.Color
+	lineweight drawing.Lineweight
+	linetype   string
+	plotStyle  string
+	table      *plotstyle.Table
+	// ltScale scales the linetype pattern to the coordinates the entity
+	// is drawn in.
+	ltScale float64
+	// thin strokes with the thinnest line, ignoring lineweights.
+	thin bool
+}
+
+func (st style) rgb() RGB { return st.plot(st.color) }
+
+func (st style) width() float64 {
+	if st.thin {
+		return 0
+	}
+	if pen := st.pen(st.color); pen != nil && pen.Lineweight >= 0 {
+		return pen.Lineweight * PointsPerMM
+	}
+	return lineweightPt(st.lineweight)
+}
+
+// continuous returns the style with a solid linetype, for lines that are
+// part of something else, such as hatch patterns and underlines.
+func (st style) continuous() style {
+	st.linetype = "Continuous"
+	return st
+}
+
+// plot returns the color on paper of an entity color.
+func (st style) plot(c drawing.Color) RGB {
+	v := plotColor(c)
+	pen := st.pen(c)
+	if pen == nil {
+		return v
+	}
+	return rgb(pen.Plot(uint32(v.R)<<16 | uint32(v.G)<<8 | uint32(v.B)))
+}
+
+// pen returns the plot style that objects of color c use: the named one
+// in named tables and that of the color in color-dependent ones, where
+// true colors take the style of the nearest ACI color.
+func (st style) pen(c drawing.Color) *plotstyle.Style {
+	switch {
+	case st.table == nil:
+		return nil
+	case st.table.Named:
+		return st.table.ByName(st.plotStyle)
+	case c.True && (c.Index < 1 || c.Index > 255):
+		return st.table.ByColor(drawing.NearestACI(c.RGB))
+	}
+	i := int(c.Index)
+	if i < 0 {
+		i = -i
+	}
+	if i == 0 || i == 256 {
+		i = 7
+	}
+	return st.table.ByColor(i)
+}
+
+// resolve applies layer 0 inheritance, ByLayer and ByBlock to the entity
+// properties. It reports false when the entity's layer is not plotted: it
+// is off, frozen, also in the viewport being drawn, set not to plot, or
+// filtered out.
+func (r *renderer) resolve(p *drawing.EntityProps, s scope) (style, bool) {
+	st := style{
+		layer:      p.Layer,
+		color:      p.Color,
+		lineweight: p.Lineweight,
+		linetype:   p.Linetype,
+		plotStyle:  p.PlotStyle,
+		table:      r.styles,
+		ltScale:    r.ltScale(p, s),
+		thin:       r.thin,
+	}
+	if r.lineweights == LineweightsDisplay {
+		// The screen shows lineweights without plot styles.
+		st.table = nil
+	}
+	if s.layer != "" && (st.layer == "" || st.layer == "0") {
+		st.layer = s.layer
+	}
+	layer := r.d.Layer(st.layer)
+	if layer == nil {
+		layer = r.d.Layer("0")
+	}
+
+	switch {
+	case st.color.IsByLayer() && layer != nil:
+		st.color = layer.Color
+	case st.color.IsByBlock():
+		st.color = s.color
+	}
+	switch st.lineweight {
+	case drawing.LineweightByLayer:
+		st.lineweight = drawing.LineweightDefault
+		if layer != nil {
+			st.lineweight = layer.Lineweight
+		}
+	case drawing.LineweightByBlock:
+		st.lineweight = s.lineweight
+	}
+	switch {
+	case strings.EqualFold(st.linetype, "ByLayer") || st.linetype == "":
+		st.linetype = "Continuous"
+		if layer != nil && layer.Linetype != "" {
+			st.linetype = layer.Linetype
+		}
+	case strings.EqualFold(st.linetype, "ByBlock"):
+		st.linetype = s.linetype
+	}
+	switch {
+	case st.plotStyle == "" || strings.EqualFold(st.plotStyle, "ByLayer"):
+		st.plotStyle = "Normal"
+		if layer != nil && layer.PlotStyle != "" {
+			st.plotStyle = layer.PlotStyle
+		}
+	case strings.EqualFold(st.plotStyle, "ByBlock"):
+		st.plotStyle = s.plotStyle
+	}
+
+	visible := !p.Invisible && r.plots(layer) && !r.frozenIn(st.layer) && r.layers.shows(st.layer)
+	return st, visible
+}
+
+// ltScale returns the factor linetype patterns of an entity scale by:
+// LTSCALE, the entity's own scale, which CELTSCALE set when it was drawn,
+// and the scale of the scope, less that of the viewport when PSLTSCALE
+// keeps patterns the same size on paper in every viewport.
+func (r *renderer) ltScale(p *drawing.EntityProps, s scope) float64 {
+	k := r.d.Header.LTScale
+	if k <= 0 {
+		k = 1
+	}
+	if p.LinetypeScale > 0 {
+		k *= p.LinetypeScale
+	}
+	k *= s.m.ScaleXY()
+	if r.ltView > 0 {
+		k /= r.ltView
+	}
+	return k
+}
+
+// plots reports whether entities on a layer plot. The DEFPOINTS layer of
+// dimension definition points never does.
+func (r *renderer) plots(l *drawing.Layer) bool {
+	return l == nil || (!l.Off || r.offLayers) && !l.Frozen && l.Plot && !strings.EqualFold(l.Name, "Defpoints")
+}
+
+// frozenIn reports whether the viewport being drawn freezes the layer.
+func (r *renderer) frozenIn(layer string) bool {
+	return r.frozen[strings.ToUpper(layer)]
+}
+
+// plotColor maps a drawing color to the color on white paper, where ACI 7
+// is black.
+func plotColor(c drawing.Color) RGB {
+	if c.True {
+		return rgb(c.RGB)
+	}
+	i := int(c.Index)
+	if i < 0 {
+		i = -i
+	}
+	if i == 7 || i == 0 || i == 256 {
+		return RGB{}
+	}
+	return rgb(drawing.ACI(i))
+}
+
+// lineweightPt converts a lineweight to points; the default weight is
+// 0.25 mm.
+func lineweightPt(lw drawing.Lineweight) float64 {
+	if lw < 0 {
+		lw = 25
+	}
+	return float64(lw) / 100 * PointsPerMM
+}
+
+// stroke draws p with the linetype of st.
+func (r *renderer) stroke(p Path, st style) {
+	if p.Empty() {
+		return
+	}
+	if lt := r.d.Linetype(st.linetype); !r.continuous && !lt.IsContinuous() {
+		r.dashed(p, lt, st)
+		return
+	}
+	r.solid(p, st)
+}
+
+// solid draws p as a solid line.
+func (r *renderer) solid(p Path, st style) {
+	if p.Empty() {
+		return
+	}
+	r.items = append(r.items, Item{Path: p, Color: st.rgb(), Width: st.width(), Layer: st.layer})
+}
+
+func (r *renderer) fill(p Path, st style) {
+	if p.Empty() {
+		return
+	}
+	r.items = append(r.items, Item{Path: p, Fill: true, Color: st.rgb(), Layer: st.layer})
+}
@@ -0,0 +1,170 @@
+package render
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// shxFont is an SHX font drawn as its strokes, with the big font that
+// holds the double-byte characters of a style, if any.
+type shxFont struct {
+	main, big *font.Shapes
+	// enc is the code page of the drawing, which fonts that are not
+	// Unicode number their characters in.
+	enc    encoding.Encoding
+	glyphs map[rune]*shxGlyph
+}
+
+// shxGlyph is a character of an SHX font in text height units.
+type shxGlyph struct {
+	lines   [][]geom.Vec2
+	advance float64
+}
+
+// shxSymbols are the codes fonts that are not Unicode give the symbols
+// of %%d, %%p and %%c.
+var shxSymbols = map[rune]int{'°': 127, '±': 128, 'Ø': 129}
+
+// shx returns the SHX font of a text style when SHX text is drawn as
+// strokes and the font is installed, and nil otherwise.
+func (r *renderer) shx(style string) *shxFont {
+	if f, ok := r.styleShapes[style]; ok {
+		return f
+	}
+	var f *shxFont
+	if ts := r.d.Style(style); ts != nil {
+		f = r.shxFile(ts.FontFile, ts.BigFontFile)
+	}
+	r.styleShapes[style] = f
+	return f
+}
+
+// shxFile returns the SHX font of a font file, as shx does for styles.
+func (r *renderer) shxFile(file, bigFile string) *shxFont {
+	if r.shxText == SHXFont {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case "", ".shx":
+	default:
+		return nil
+	}
+	main := r.fonts.Shapes(file)
+	if main == nil || main.Above <= 0 {
+		return nil
+	}
+	f := &shxFont{main: main, enc: r.d.Header.Encoding, glyphs: make(map[rune]*shxGlyph)}
+	if f.enc == nil {
+		f.enc = charmap.Windows1252
+	}
+	if big := r.fonts.Shapes(bigFile); big != nil {
+		f.big = big
+	}
+	return f
+}
+
+// glyph returns the character c, or a question mark when the font does
+// not have it; nil when it has neither.
+func (f *shxFont) glyph(c rune) *shxGlyph {
+	if g, ok := f.glyphs[c]; ok {
+		return g
+	}
+	g := f.lookup(c)
+	if g == nil && c != '?' {
+		g = f.glyph('?')
+	}
+	f.glyphs[c] = g
+	return g
+}
+
+func (f *shxFont) lookup(c rune) *shxGlyph {
+	code := int(c)
+	if !f.main.Unicode() && c >= 0x80 {
+		code = -1
+		if b, err := f.enc.NewEncoder().Bytes([]byte(string(c))); err == nil {
+			switch len(b) {
+			case 1:
+				code = int(b[0])
+			case 2:
+				if f.big != nil {
+					if g := shxShape(f.big, int(b[0])<<8|int(b[1]), f.main.Above); g != nil {
+						return g
+					}
+				}
+			}
+		}
+	}
+	if g := shxShape(f.main, code, f.main.Above); g != nil {
+		return g
+	}
+	if alt, ok := shxSymbols[c]; ok && !f.main.Unicode() {
+		return shxShape(f.main, alt, f.main.Above)
+	}
+	return nil
+}
+
+// shxShape scales a shape of s to text height units, where above is the
+// height of capitals unless s has its own.
+func shxShape(s *font.Shapes, code int, above float64) *shxGlyph {
+	if code < 0 || !s.Has(code) {
+		return nil
+	}
+	lines, end, _ := s.Strokes(code)
+	if s.Above > 0 {
+		above = s.Above
+	}
+	k := 1 / above
+	for _, l := range lines {
+		for i := range l {
+			l[i] = l[i].Scale(k)
+		}
+	}
+	return &shxGlyph{lines: lines, advance: end.X * k}
+}
+
+// advance returns the width of s in text height units.
+func (f *shxFont) advance(s string) float64 {
+	var w float64
+	for _, c := range s {
+		if g := f.glyph(c); g != nil {
+			w += g.advance
+		}
+	}
+	return w
+}
+
+// descent returns the depth of descenders below the baseline in text
+// height units.
+func (f *shxFont) descent() float64 {
+	return f.main.Below / f.main.Above
+}
+
+// strokes draws s in text space, placed by m.
+func (f *shxFont) strokes(s string, m geom.Matrix) Path {
+	var p Path
+	n := pen{&p, m}
+	var x float64
+	for _, c := range s {
+		g := f.glyph(c)
+		if g == nil {
+			continue
+		}
+		for _, l := range g.lines {
+			for i, v := range l {
+				if i == 0 {
+					n.moveTo(geom.Vec3{X: x + v.X, Y: v.Y})
+				} else {
+					n.lineTo(geom.Vec3{X: x + v.X, Y: v.Y})
+				}
+			}
+		}
+		x += g.advance
+	}
+	return p
+}
@@ -0,0 +1,71 @@
+package render
+
+import (
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// samplesPerSpan is the number of line segments each non-empty knot span
+// of a NURBS curve is flattened into.
+const samplesPerSpan = 16
+
+// spline draws the NURBS curve from the control points when the knot
+// vector is consistent, otherwise a smooth curve through the fit points.
+func spline(n pen, e *drawing.Spline) {
+	deg, ctrl, knots := e.Degree, e.Control, e.Knots
+	switch {
+	case deg >= 1 && len(ctrl) > deg && len(knots) == len(ctrl)+deg+1:
+		weights := e.Weights
+		if len(weights) != len(ctrl) {
+			weights = nil
+		}
+		n.moveTo(geom.NURBS(deg, knots, ctrl, weights, knots[deg]))
+		for i := deg; i < len(ctrl); i++ {
+			u0, u1 := knots[i], knots[i+1]
+			if u1 <= u0 {
+				continue
+			}
+			for k := 1; k <= samplesPerSpan; k++ {
+				u := u0 + (u1-u0)*float64(k)/samplesPerSpan
+				n.lineTo(geom.NURBS(deg, knots, ctrl, weights, u))
+			}
+		}
+	case len(e.Fit) >= 2:
+		through(n, e.Fit, e.Flags&drawing.SplineClosed != 0)
+	case len(ctrl) >= 2:
+		n.moveTo(ctrl[0])
+		for _, c := range ctrl[1:] {
+			n.lineTo(c)
+		}
+	}
+}
+
+// through draws a Catmull-Rom curve through the points as cubic Béziers.
+func through(n pen, pts []geom.Vec3, closed bool) {
+	count := len(pts)
+	at := func(i int) geom.Vec3 {
+		switch {
+		case closed:
+			return pts[(i%count+count)%count]
+		case i < 0:
+			return pts[0].Scale(2).Sub(pts[1])
+		case i >= count:
+			return pts[count-1].Scale(2).Sub(pts[count-2])
+		}
+		return pts[i]
+	}
+	segs := count - 1
+	if closed {
+		segs = count
+	}
+	n.moveTo(pts[0])
+	for i := range segs {
+		p0, p1, p2, p3 := at(i-1), at(i), at(i+1), at(i+2)
+		c1 := p1.Add(p2.Sub(p0).Scale(1.0 / 6))
+		c2 := p2.Sub(p3.Sub(p1).Scale(1.0 / 6))
+		n.p.CubicTo(n.at(c1), n.at(c2), n.at(p2))
+	}
+	if closed {
+		n.close()
+	}
+}
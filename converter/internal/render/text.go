@@ -0,0 +1,401 @@
+package render
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/drawing"
+	"github.com/you-humble/dwgtopdf/converter/internal/font"
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// Metrics used when no font is available, those of Helvetica in text
+// height units.
+const (
+	noFontAdvance = 0.55 / 0.718
+	noFontDescent = 0.21 / 0.718
+)
+
+// advance returns the width of s in text height units. Characters f does
+// not have are measured in the font of fonts that fills in for them.
+func advance(fonts *font.Set, f *font.Font, s string) float64 {
+	if f == nil {
+		return float64(len([]rune(s))) * noFontAdvance
+	}
+	var w float64
+	for _, r := range s {
+		cf := fonts.Covering(f, r)
+		g, _ := cf.Glyph(r)
+		w += cf.Advance(g) / cf.CapHeight
+	}
+	return w
+}
+
+// covered splits s into the pieces drawn in one font, f or the font of
+// fonts that fills in for characters f does not have.
+func covered(fonts *font.Set, f *font.Font, s string) []coveredText {
+	if f == nil {
+		return []coveredText{{nil, s}}
+	}
+	var out []coveredText
+	start := 0
+	var cur *font.Font
+	for i, r := range s {
+		cf := fonts.Covering(f, r)
+		if i > 0 && cf != cur {
+			out = append(out, coveredText{cur, s[start:i]})
+			start = i
+		}
+		cur = cf
+	}
+	if start < len(s) {
+		out = append(out, coveredText{cur, s[start:]})
+	}
+	return out
+}
+
+type coveredText struct {
+	font *font.Font
+	text string
+}
+
+// descent returns the depth of descenders below the baseline in text
+// height units.
+func descent(f *font.Font) float64 {
+	if f == nil {
+		return noFontDescent
+	}
+	return -f.Descent / f.CapHeight
+}
+
+// font returns the font of a text style.
+func (r *renderer) font(style string) *font.Font {
+	if f, ok := r.styleFonts[style]; ok {
+		return f
+	}
+	var f *font.Font
+	if ts := r.d.Style(style); ts != nil {
+		f = r.fonts.Lookup(ts.FontFile, ts.FontFamily)
+	} else {
+		f = r.fonts.Fallback()
+	}
+	r.styleFonts[style] = f
+	return f
+}
+
+// textHeight returns height, or the style's fixed height or the drawing
+// default when it is not set.
+func (r *renderer) textHeight(height float64, style string) float64 {
+	if height > 0 {
+		return height
+	}
+	if ts := r.d.Style(style); ts != nil && ts.Height > 0 {
+		return ts.Height
+	}
+	return r.d.Header.TextSize
+}
+
+// run is a piece of text in one font, size and color, placed in the text
+// frame of its entity.
+type run struct {
+	text string
+	format
+	// x and y locate the start of the baseline in the frame.
+	x, y float64
+}
+
+// format is the character formatting a run is drawn with.
+type format struct {
+	font *font.Font
+	// shx is set when the text is drawn as the strokes of an SHX font;
+	// font then only places the invisible text over them, if any.
+	shx *shxFont
+	// fonts fills in for the characters font does not have.
+	fonts *font.Set
+	// height is the cap height and width the width factor.
+	height, width float64
+	oblique       float64
+	// color overrides the entity color when set.
+	color               *drawing.Color
+	under, over, strike bool
+}
+
+// advance returns the width of the run's text in drawing units.
+func (f format) advance(s string) float64 { return f.units(s) * f.height * f.width }
+
+// units returns the width of s in text height units.
+func (f format) units(s string) float64 {
+	if f.shx != nil {
+		return f.shx.advance(s)
+	}
+	return advance(f.fonts, f.font, s)
+}
+
+// descent returns the depth of descenders in text height units.
+func (f format) descent() float64 {
+	if f.shx != nil {
+		return f.shx.descent()
+	}
+	return descent(f.font)
+}
+
+// emit adds the runs, placed by frame, to the sheet, with their
+// underlines, overlines and strike-throughs.
+func (r *renderer) emit(runs []run, frame geom.Matrix, st style) {
+	for _, rn := range runs {
+		if rn.text == "" {
+			continue
+		}
+		rst := st
+		if rn.color != nil {
+			rst.color = *rn.color
+		}
+		m := frame.
+			Mul(geom.Translate(geom.Vec3{X: rn.x, Y: rn.y})).
+			Mul(shear(rn.oblique)).
+			Mul(geom.Scale(geom.Vec3{X: rn.height * rn.width, Y: rn.height, Z: 1}))
+		if rn.shx != nil {
+			r.solid(rn.shx.strokes(rn.text, m), rst)
+		}
+		if strings.TrimSpace(rn.text) != "" && (rn.shx == nil || r.shxText == SHXOverlay) {
+			r.texts(rn, m, rst)
+		}
+		w := rn.units(rn.text)
+		var p Path
+		n := pen{&p, m}
+		for _, line := range []struct {
+			on bool
+			y  float64
+		}{{rn.under, -0.2}, {rn.over, 1.2}, {rn.strike, 0.5}} {
+			if line.on {
+				n.moveTo(geom.Vec3{Y: line.y})
+				n.lineTo(geom.Vec3{X: w, Y: line.y})
+			}
+		}
+		r.stroke(p, rst.continuous())
+	}
+}
+
+// texts adds the text items of a run, one for each font it is drawn in.
+// Over SHX strokes the text is invisible and stretched to the strokes.
+func (r *renderer) texts(rn run, m geom.Matrix, st style) {
+	var x float64
+	for _, c := range covered(rn.fonts, rn.font, rn.text) {
+		w := advance(nil, c.font, c.text)
+		tm := m.Mul(geom.Translate(geom.Vec3{X: x}))
+		if rn.shx != nil {
+			sw := rn.shx.advance(c.text)
+			if w > 0 {
+				tm = tm.Mul(geom.Scale(geom.Vec3{X: sw / w, Y: 1, Z: 1}))
+			}
+			x += sw
+		} else {
+			x += w
+		}
+		if strings.TrimSpace(c.text) == "" {
+			continue
+		}
+		r.items = append(r.items, Item{
+			Text:  &Text{Value: c.text, Font: c.font, Matrix: tm, Width: w, Invisible: rn.shx != nil},
+			Fill:  true,
+			Color: st.rgb(),
+			Layer: st.layer,
+		})
+	}
+}
+
+// text draws single-line text. The justification places the text relative
+// to the alignment point; aligned and fit text is stretched between the
+// start and alignment points.
+func (r *renderer) text(t *drawing.Text, value string, s scope, st style) {
+	if value == "" {
+		return
+	}
+	f := format{
+		font:    r.font(t.Style),
+		shx:     r.shx(t.Style),
+		fonts:   r.fonts,
+		height:  r.textHeight(t.Height, t.Style),
+		width:   t.WidthFactor,
+		oblique: t.Oblique,
+	}
+	if f.width <= 0 {
+		f.width = 1
+	}
+	runs := textRuns(value, f)
+	var width float64
+	for i := range runs {
+		runs[i].x = width
+		width += runs[i].advance(runs[i].text)
+	}
+	if width == 0 {
+		return
+	}
+
+	anchor, rotation := t.AlignPoint, t.Rotation
+	var dx, dy float64
+	stretch := geom.Vec3{X: 1, Y: 1, Z: 1}
+	switch t.HAlign {
+	case drawing.HAlignCenter:
+		dx = -width / 2
+	case drawing.HAlignRight:
+		dx = -width
+	case drawing.HAlignMiddle:
+		dx = -width / 2
+		dy = -f.height * (1 - f.descent()) / 2
+	case drawing.HAlignAligned, drawing.HAlignFit:
+		d := t.AlignPoint.Sub(t.Position)
+		if l := d.XY().Len(); l > 0 {
+			anchor, rotation = t.Position, d.XY().Angle()
+			stretch.X = l / width
+			if t.HAlign == drawing.HAlignAligned {
+				stretch.Y = stretch.X
+			}
+		}
+	}
+	if t.HAlign == drawing.HAlignLeft && t.VAlign == drawing.VAlignBaseline {
+		anchor = t.Position
+	}
+	if t.HAlign <= drawing.HAlignRight {
+		switch t.VAlign {
+		case drawing.VAlignBottom:
+			dy = f.height * f.descent()
+		case drawing.VAlignMiddle:
+			dy = -f.height / 2
+		case drawing.VAlignTop:
+			dy = -f.height
+		}
+	}
+
+	mirror := geom.Vec3{X: 1, Y: 1, Z: 1}
+	if t.Generation&drawing.TextBackward != 0 {
+		mirror.X = -1
+	}
+	if t.Generation&drawing.TextUpsideDown != 0 {
+		mirror.Y = -1
+	}
+	frame := ocs(s, t.Extrusion).
+		Mul(geom.Translate(anchor)).
+		Mul(geom.RotateZ(rotation)).
+		Mul(geom.Scale(mirror)).
+		Mul(geom.Scale(stretch)).
+		Mul(geom.Translate(geom.Vec3{X: dx, Y: dy}))
+	r.emit(runs, frame, st)
+}
+
+// textRuns splits single-line text at its %% control codes, which toggle
+// underline, overline and strike-through, and expands the special and
+// double-byte characters.
+func textRuns(value string, f format) []run {
+	var runs []run
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			runs = append(runs, run{text: b.String(), format: f})
+			b.Reset()
+		}
+	}
+	for i := 0; i < len(value); i++ {
+		if r, ok := dbcs(value[i:]); ok {
+			b.WriteRune(r)
+			i += 7
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "%%") || i+2 >= len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		c := value[i+2]
+		switch c | 0x20 {
+		case 'u':
+			flush()
+			f.under = !f.under
+		case 'o':
+			flush()
+			f.over = !f.over
+		case 'k':
+			flush()
+			f.strike = !f.strike
+		default:
+			if r, ok := special(c); ok {
+				b.WriteRune(r)
+				break
+			}
+			// %%nnn is a character code.
+			j := i + 2
+			for j < len(value) && j < i+5 && value[j] >= '0' && value[j] <= '9' {
+				j++
+			}
+			if n, err := strconv.Atoi(value[i+2 : j]); err == nil {
+				b.WriteRune(rune(n))
+				i = j - 1
+				continue
+			}
+			b.WriteString("%%")
+			i++
+			continue
+		}
+		i += 2
+	}
+	flush()
+	return runs
+}
+
+// dbcsEncodings are the code pages of \M+nXXXX codes by n.
+var dbcsEncodings = map[byte]encoding.Encoding{
+	'1': japanese.ShiftJIS,
+	'2': traditionalchinese.Big5,
+	'3': korean.EUCKR,
+	'5': simplifiedchinese.GBK,
+}
+
+// dbcs decodes the character of a \M+nXXXX code, which s starts with: a
+// double-byte character of code page n, as older files store text their
+// own code page cannot represent.
+func dbcs(s string) (rune, bool) {
+	if len(s) < 8 || !strings.HasPrefix(s, `\M+`) {
+		return 0, false
+	}
+	enc, ok := dbcsEncodings[s[3]]
+	v, err := strconv.ParseUint(s[4:8], 16, 16)
+	if !ok || err != nil {
+		return 0, false
+	}
+	out, err := enc.NewDecoder().Bytes([]byte{byte(v >> 8), byte(v)})
+	r, _ := utf8.DecodeRune(out)
+	if err != nil || r == utf8.RuneError {
+		return 0, false
+	}
+	return r, true
+}
+
+// special returns the character of a %% code such as %%d for degrees.
+func special(c byte) (rune, bool) {
+	switch c | 0x20 {
+	case 'd':
+		return '°', true
+	case 'p':
+		return '±', true
+	case 'c':
+		return 'Ø', true
+	}
+	if c == '%' {
+		return '%', true
+	}
+	return 0, false
+}
+
+// shear slants the Y axis by the oblique angle.
+func shear(oblique float64) geom.Matrix {
+	m := geom.Identity()
+	m[1] = math.Tan(oblique)
+	return m
+}
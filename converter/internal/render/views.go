@@ -0,0 +1,58 @@
+package render
+
+import (
+	"slices"
+
+	"github.com/you-humble/dwgtopdf/converter/internal/geom"
+)
+
+// namedViews adds the named views of paper or model space, with m the
+// transform from WCS to the sheet. Inside a viewport, whose outline on
+// the sheet is within, only the views centred in the viewport are added,
+// cut to it; a view seen through several viewports goes with the first.
+func (r *renderer) namedViews(paper bool, m geom.Matrix, within geom.Box) {
+	for _, v := range r.d.Views {
+		if v.PaperSpace != paper || v.Width <= 0 || v.Height <= 0 {
+			continue
+		}
+		if slices.ContainsFunc(r.views, func(o View) bool { return o.Name == v.Name }) {
+			continue
+		}
+		toWCS, ok := dcs(v.Target, v.Direction, v.Twist).Inverse()
+		if !ok {
+			continue
+		}
+		var dcsArea geom.Box
+		dcsArea.Add(v.Center.Sub(geom.Vec2{X: v.Width / 2, Y: v.Height / 2}))
+		dcsArea.Add(v.Center.Add(geom.Vec2{X: v.Width / 2, Y: v.Height / 2}))
+		area := transformBox(dcsArea, m.Mul(toWCS))
+		if within.Valid {
+			if !within.Contains(area.Center()) {
+				continue
+			}
+			area = intersect(area, within)
+		}
+		r.views = append(r.views, View{Name: v.Name, Area: area})
+	}
+}
+
+// transformBox returns the box around the corners of b transformed by m.
+func transformBox(b geom.Box, m geom.Matrix) geom.Box {
+	var out geom.Box
+	if !b.Valid {
+		return out
+	}
+	for _, p := range []geom.Vec2{b.Min, {X: b.Max.X, Y: b.Min.Y}, b.Max, {X: b.Min.X, Y: b.Max.Y}} {
+		out.Add(m.Apply2(p))
+	}
+	return out
+}
+
+func intersect(a, b geom.Box) geom.Box {
+	var out geom.Box
+	if a.Intersects(b) {
+		out.Add(geom.Vec2{X: max(a.Min.X, b.Min.X), Y: max(a.Min.Y, b.Min.Y)})
+		out.Add(geom.Vec2{X: min(a.Max.X, b.Max.X), Y: min(a.Max.Y, b.Max.Y)})
+	}
+	return out
+}
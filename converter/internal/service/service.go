@@ -5,11 +5,14 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/you-humble/dwgtopdf/converter/internal/domain"
+
 	converterpb "github.com/you-humble/dwgtopdf/core/grpc/gen"
 )
 
 type Converter interface {
-	Convert(ctx context.Context, inputPath string, suggestedName string) (string, error)
+	Convert(ctx context.Context, p domain.ConvertParams) (domain.ConvertResult, error)
+	Thumbnail(ctx context.Context, p domain.ThumbnailParams) (string, error)
 }
 
 type ConverterService struct {
@@ -25,22 +28,78 @@ func (s *ConverterService) Convert(ctx context.Context, req *converterpb.Convert
 	convCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	pdfName, err := s.converter.Convert(convCtx, req.GetInputPath(), req.GetSuggestedName())
+	res, err := s.converter.Convert(convCtx, domain.ConvertParams{
+		InputPath:     req.GetInputPath(),
+		SuggestedName: req.GetSuggestedName(),
+		InputFormat:   domain.InputFormat(req.GetInputFormat()),
+		Options: domain.ConvertOptions{
+			PaperSize:     req.GetOptions().GetPaperSize(),
+			Orientation:   req.GetOptions().GetOrientation(),
+			Scale:         req.GetOptions().GetScale(),
+			PlotStyle:     req.GetOptions().GetPlotStyle(),
+			Lineweights:   req.GetOptions().GetLineweights(),
+			IncludeLayers: req.GetOptions().GetIncludeLayers(),
+			ExcludeLayers: req.GetOptions().GetExcludeLayers(),
+			PDFLayers:     req.GetOptions().GetPdfLayers(),
+			SHXText:       req.GetOptions().GetShxText(),
+			NoHyperlinks:  req.GetOptions().GetNoHyperlinks(),
+			OutputFormat:  req.GetOptions().GetOutputFormat(),
+			DPI:           req.GetOptions().GetDpi(),
+			ImageSize:     req.GetOptions().GetImageSize(),
+			Background:    req.GetOptions().GetBackground(),
+			NoAntialias:   req.GetOptions().GetNoAntialias(),
+			DXFVersion:    req.GetOptions().GetDxfVersion(),
+		},
+		PlotStylePath: req.GetPlotStylePath(),
+		TaskID:        req.GetTaskId(),
+	})
 	if err != nil {
 		slog.Error("convert failed",
 			slog.String("input_path", req.GetInputPath()),
 			slog.String("suggested_name", req.GetSuggestedName()),
+			slog.String("input_format", req.GetInputFormat()),
 			slog.String("error", err.Error()),
 		)
 		return nil, err
 	}
 
 	slog.Info("convert success",
-		slog.String("pdf_name", pdfName),
+		slog.String("result_name", res.ResultName),
 		slog.String("input_path", req.GetInputPath()),
+		slog.Any("layouts", res.Layouts),
+		slog.Any("warnings", res.Warnings),
+		slog.Any("unresolved_xrefs", res.UnresolvedXrefs),
 	)
 
 	return &converterpb.ConvertResponse{
-		PdfName: pdfName,
+		ResultName:      res.ResultName,
+		Layouts:         res.Layouts,
+		Warnings:        res.Warnings,
+		UnresolvedXrefs: res.UnresolvedXrefs,
 	}, nil
 }
+
+func (s *ConverterService) Thumbnail(ctx context.Context, req *converterpb.ThumbnailRequest) (*converterpb.ThumbnailResponse, error) {
+	thumbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	name, err := s.converter.Thumbnail(thumbCtx, domain.ThumbnailParams{
+		InputPath:     req.GetInputPath(),
+		SuggestedName: req.GetSuggestedName(),
+		InputFormat:   domain.InputFormat(req.GetInputFormat()),
+	})
+	if err != nil {
+		slog.Error("thumbnail failed",
+			slog.String("input_path", req.GetInputPath()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+
+	slog.Info("thumbnail success",
+		slog.String("thumbnail_name", name),
+		slog.String("input_path", req.GetInputPath()),
+	)
+
+	return &converterpb.ThumbnailResponse{ThumbnailName: name}, nil
+}
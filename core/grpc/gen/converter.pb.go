@@ -25,6 +25,10 @@ type ConvertRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	InputPath     string                 `protobuf:"bytes,1,opt,name=input_path,json=inputPath,proto3" json:"input_path,omitempty"`
 	SuggestedName string                 `protobuf:"bytes,2,opt,name=suggested_name,json=suggestedName,proto3" json:"suggested_name,omitempty"`
+	InputFormat   string                 `protobuf:"bytes,3,opt,name=input_format,json=inputFormat,proto3" json:"input_format,omitempty"`
+	Options       *ConvertOptions        `protobuf:"bytes,4,opt,name=options,proto3" json:"options,omitempty"`
+	PlotStylePath string                 `protobuf:"bytes,5,opt,name=plot_style_path,json=plotStylePath,proto3" json:"plot_style_path,omitempty"`
+	TaskId        string                 `protobuf:"bytes,6,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,16 +77,211 @@ func (x *ConvertRequest) GetSuggestedName() string {
 	return ""
 }
 
-type ConvertResponse struct {
+func (x *ConvertRequest) GetInputFormat() string {
+	if x != nil {
+		return x.InputFormat
+	}
+	return ""
+}
+
+func (x *ConvertRequest) GetOptions() *ConvertOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *ConvertRequest) GetPlotStylePath() string {
+	if x != nil {
+		return x.PlotStylePath
+	}
+	return ""
+}
+
+func (x *ConvertRequest) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+type ConvertOptions struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	PdfName       string                 `protobuf:"bytes,1,opt,name=pdf_name,json=pdfName,proto3" json:"pdf_name,omitempty"`
+	PaperSize     string                 `protobuf:"bytes,1,opt,name=paper_size,json=paperSize,proto3" json:"paper_size,omitempty"`
+	Orientation   string                 `protobuf:"bytes,2,opt,name=orientation,proto3" json:"orientation,omitempty"`
+	Scale         string                 `protobuf:"bytes,3,opt,name=scale,proto3" json:"scale,omitempty"`
+	PlotStyle     string                 `protobuf:"bytes,4,opt,name=plot_style,json=plotStyle,proto3" json:"plot_style,omitempty"`
+	Lineweights   string                 `protobuf:"bytes,5,opt,name=lineweights,proto3" json:"lineweights,omitempty"`
+	IncludeLayers []string               `protobuf:"bytes,6,rep,name=include_layers,json=includeLayers,proto3" json:"include_layers,omitempty"`
+	ExcludeLayers []string               `protobuf:"bytes,7,rep,name=exclude_layers,json=excludeLayers,proto3" json:"exclude_layers,omitempty"`
+	PdfLayers     bool                   `protobuf:"varint,8,opt,name=pdf_layers,json=pdfLayers,proto3" json:"pdf_layers,omitempty"`
+	ShxText       string                 `protobuf:"bytes,9,opt,name=shx_text,json=shxText,proto3" json:"shx_text,omitempty"`
+	NoHyperlinks  bool                   `protobuf:"varint,10,opt,name=no_hyperlinks,json=noHyperlinks,proto3" json:"no_hyperlinks,omitempty"`
+	OutputFormat  string                 `protobuf:"bytes,11,opt,name=output_format,json=outputFormat,proto3" json:"output_format,omitempty"`
+	Dpi           string                 `protobuf:"bytes,12,opt,name=dpi,proto3" json:"dpi,omitempty"`
+	ImageSize     string                 `protobuf:"bytes,13,opt,name=image_size,json=imageSize,proto3" json:"image_size,omitempty"`
+	Background    string                 `protobuf:"bytes,14,opt,name=background,proto3" json:"background,omitempty"`
+	NoAntialias   bool                   `protobuf:"varint,15,opt,name=no_antialias,json=noAntialias,proto3" json:"no_antialias,omitempty"`
+	DxfVersion    string                 `protobuf:"bytes,16,opt,name=dxf_version,json=dxfVersion,proto3" json:"dxf_version,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *ConvertOptions) Reset() {
+	*x = ConvertOptions{}
+	mi := &file_pkg_proto_converter_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertOptions) ProtoMessage() {}
+
+func (x *ConvertOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_converter_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertOptions.ProtoReflect.Descriptor instead.
+func (*ConvertOptions) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_converter_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ConvertOptions) GetPaperSize() string {
+	if x != nil {
+		return x.PaperSize
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetOrientation() string {
+	if x != nil {
+		return x.Orientation
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetScale() string {
+	if x != nil {
+		return x.Scale
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetPlotStyle() string {
+	if x != nil {
+		return x.PlotStyle
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetLineweights() string {
+	if x != nil {
+		return x.Lineweights
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetIncludeLayers() []string {
+	if x != nil {
+		return x.IncludeLayers
+	}
+	return nil
+}
+
+func (x *ConvertOptions) GetExcludeLayers() []string {
+	if x != nil {
+		return x.ExcludeLayers
+	}
+	return nil
+}
+
+func (x *ConvertOptions) GetPdfLayers() bool {
+	if x != nil {
+		return x.PdfLayers
+	}
+	return false
+}
+
+func (x *ConvertOptions) GetShxText() string {
+	if x != nil {
+		return x.ShxText
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetNoHyperlinks() bool {
+	if x != nil {
+		return x.NoHyperlinks
+	}
+	return false
+}
+
+func (x *ConvertOptions) GetOutputFormat() string {
+	if x != nil {
+		return x.OutputFormat
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetDpi() string {
+	if x != nil {
+		return x.Dpi
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetImageSize() string {
+	if x != nil {
+		return x.ImageSize
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetBackground() string {
+	if x != nil {
+		return x.Background
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetNoAntialias() bool {
+	if x != nil {
+		return x.NoAntialias
+	}
+	return false
+}
+
+func (x *ConvertOptions) GetDxfVersion() string {
+	if x != nil {
+		return x.DxfVersion
+	}
+	return ""
+}
+
+type ConvertResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ResultName      string                 `protobuf:"bytes,1,opt,name=result_name,json=resultName,proto3" json:"result_name,omitempty"`
+	Layouts         []string               `protobuf:"bytes,2,rep,name=layouts,proto3" json:"layouts,omitempty"`
+	Warnings        []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	UnresolvedXrefs []string               `protobuf:"bytes,4,rep,name=unresolved_xrefs,json=unresolvedXrefs,proto3" json:"unresolved_xrefs,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
 func (x *ConvertResponse) Reset() {
 	*x = ConvertResponse{}
-	mi := &file_pkg_proto_converter_proto_msgTypes[1]
+	mi := &file_pkg_proto_converter_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -94,7 +293,7 @@ func (x *ConvertResponse) String() string {
 func (*ConvertResponse) ProtoMessage() {}
 
 func (x *ConvertResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_proto_converter_proto_msgTypes[1]
+	mi := &file_pkg_proto_converter_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -107,12 +306,137 @@ func (x *ConvertResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ConvertResponse.ProtoReflect.Descriptor instead.
 func (*ConvertResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_proto_converter_proto_rawDescGZIP(), []int{1}
+	return file_pkg_proto_converter_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ConvertResponse) GetResultName() string {
+	if x != nil {
+		return x.ResultName
+	}
+	return ""
+}
+
+func (x *ConvertResponse) GetLayouts() []string {
+	if x != nil {
+		return x.Layouts
+	}
+	return nil
+}
+
+func (x *ConvertResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *ConvertResponse) GetUnresolvedXrefs() []string {
+	if x != nil {
+		return x.UnresolvedXrefs
+	}
+	return nil
+}
+
+type ThumbnailRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InputPath     string                 `protobuf:"bytes,1,opt,name=input_path,json=inputPath,proto3" json:"input_path,omitempty"`
+	SuggestedName string                 `protobuf:"bytes,2,opt,name=suggested_name,json=suggestedName,proto3" json:"suggested_name,omitempty"`
+	InputFormat   string                 `protobuf:"bytes,3,opt,name=input_format,json=inputFormat,proto3" json:"input_format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ThumbnailRequest) Reset() {
+	*x = ThumbnailRequest{}
+	mi := &file_pkg_proto_converter_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ThumbnailRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ThumbnailRequest) ProtoMessage() {}
+
+func (x *ThumbnailRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_converter_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ThumbnailRequest.ProtoReflect.Descriptor instead.
+func (*ThumbnailRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_converter_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ThumbnailRequest) GetInputPath() string {
+	if x != nil {
+		return x.InputPath
+	}
+	return ""
+}
+
+func (x *ThumbnailRequest) GetSuggestedName() string {
+	if x != nil {
+		return x.SuggestedName
+	}
+	return ""
+}
+
+func (x *ThumbnailRequest) GetInputFormat() string {
+	if x != nil {
+		return x.InputFormat
+	}
+	return ""
+}
+
+type ThumbnailResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ThumbnailName string                 `protobuf:"bytes,1,opt,name=thumbnail_name,json=thumbnailName,proto3" json:"thumbnail_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ConvertResponse) GetPdfName() string {
+func (x *ThumbnailResponse) Reset() {
+	*x = ThumbnailResponse{}
+	mi := &file_pkg_proto_converter_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ThumbnailResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ThumbnailResponse) ProtoMessage() {}
+
+func (x *ThumbnailResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_converter_proto_msgTypes[4]
 	if x != nil {
-		return x.PdfName
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ThumbnailResponse.ProtoReflect.Descriptor instead.
+func (*ThumbnailResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_converter_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ThumbnailResponse) GetThumbnailName() string {
+	if x != nil {
+		return x.ThumbnailName
 	}
 	return ""
 }
@@ -121,15 +445,56 @@ var File_pkg_proto_converter_proto protoreflect.FileDescriptor
 
 const file_pkg_proto_converter_proto_rawDesc = "" +
 	"\n" +
-	"\x19pkg/proto/converter.proto\x12\fconverter.v1\"V\n" +
+	"\x19pkg/proto/converter.proto\x12\fconverter.v1\"\xf8\x01\n" +
 	"\x0eConvertRequest\x12\x1d\n" +
 	"\n" +
 	"input_path\x18\x01 \x01(\tR\tinputPath\x12%\n" +
-	"\x0esuggested_name\x18\x02 \x01(\tR\rsuggestedName\",\n" +
-	"\x0fConvertResponse\x12\x19\n" +
-	"\bpdf_name\x18\x01 \x01(\tR\apdfName2\\\n" +
+	"\x0esuggested_name\x18\x02 \x01(\tR\rsuggestedName\x12!\n" +
+	"\finput_format\x18\x03 \x01(\tR\vinputFormat\x126\n" +
+	"\aoptions\x18\x04 \x01(\v2\x1c.converter.v1.ConvertOptionsR\aoptions\x12&\n" +
+	"\x0fplot_style_path\x18\x05 \x01(\tR\rplotStylePath\x12\x17\n" +
+	"\atask_id\x18\x06 \x01(\tR\x06taskIdJ\x04\b\a\x10\b\"\x8f\x04\n" +
+	"\x0eConvertOptions\x12\x1d\n" +
+	"\n" +
+	"paper_size\x18\x01 \x01(\tR\tpaperSize\x12 \n" +
+	"\vorientation\x18\x02 \x01(\tR\vorientation\x12\x14\n" +
+	"\x05scale\x18\x03 \x01(\tR\x05scale\x12\x1d\n" +
+	"\n" +
+	"plot_style\x18\x04 \x01(\tR\tplotStyle\x12 \n" +
+	"\vlineweights\x18\x05 \x01(\tR\vlineweights\x12%\n" +
+	"\x0einclude_layers\x18\x06 \x03(\tR\rincludeLayers\x12%\n" +
+	"\x0eexclude_layers\x18\a \x03(\tR\rexcludeLayers\x12\x1d\n" +
+	"\n" +
+	"pdf_layers\x18\b \x01(\bR\tpdfLayers\x12\x19\n" +
+	"\bshx_text\x18\t \x01(\tR\ashxText\x12#\n" +
+	"\rno_hyperlinks\x18\n" +
+	" \x01(\bR\fnoHyperlinks\x12#\n" +
+	"\routput_format\x18\v \x01(\tR\foutputFormat\x12\x10\n" +
+	"\x03dpi\x18\f \x01(\tR\x03dpi\x12\x1d\n" +
+	"\n" +
+	"image_size\x18\r \x01(\tR\timageSize\x12\x1e\n" +
+	"\n" +
+	"background\x18\x0e \x01(\tR\n" +
+	"background\x12!\n" +
+	"\fno_antialias\x18\x0f \x01(\bR\vnoAntialias\x12\x1f\n" +
+	"\vdxf_version\x18\x10 \x01(\tR\n" +
+	"dxfVersion\"\x99\x01\n" +
+	"\x0fConvertResponse\x12\x1f\n" +
+	"\vresult_name\x18\x01 \x01(\tR\n" +
+	"resultName\x12\x18\n" +
+	"\alayouts\x18\x02 \x03(\tR\alayouts\x12\x1a\n" +
+	"\bwarnings\x18\x03 \x03(\tR\bwarnings\x12)\n" +
+	"\x10unresolved_xrefs\x18\x04 \x03(\tR\x0funresolvedXrefsJ\x04\b\x05\x10\x06\"{\n" +
+	"\x10ThumbnailRequest\x12\x1d\n" +
+	"\n" +
+	"input_path\x18\x01 \x01(\tR\tinputPath\x12%\n" +
+	"\x0esuggested_name\x18\x02 \x01(\tR\rsuggestedName\x12!\n" +
+	"\finput_format\x18\x03 \x01(\tR\vinputFormat\":\n" +
+	"\x11ThumbnailResponse\x12%\n" +
+	"\x0ethumbnail_name\x18\x01 \x01(\tR\rthumbnailName2\xac\x01\n" +
 	"\x10ConverterService\x12H\n" +
-	"\aConvert\x12\x1c.converter.v1.ConvertRequest\x1a\x1d.converter.v1.ConvertResponse\"\x00B\x1aZ\x18pkg/grpc/gen;converterpbb\x06proto3"
+	"\aConvert\x12\x1c.converter.v1.ConvertRequest\x1a\x1d.converter.v1.ConvertResponse\"\x00\x12N\n" +
+	"\tThumbnail\x12\x1e.converter.v1.ThumbnailRequest\x1a\x1f.converter.v1.ThumbnailResponse\"\x00B\x1aZ\x18pkg/grpc/gen;converterpbb\x06proto3"
 
 var (
 	file_pkg_proto_converter_proto_rawDescOnce sync.Once
@@ -143,19 +508,25 @@ func file_pkg_proto_converter_proto_rawDescGZIP() []byte {
 	return file_pkg_proto_converter_proto_rawDescData
 }
 
-var file_pkg_proto_converter_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_pkg_proto_converter_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_pkg_proto_converter_proto_goTypes = []any{
-	(*ConvertRequest)(nil),  // 0: converter.v1.ConvertRequest
-	(*ConvertResponse)(nil), // 1: converter.v1.ConvertResponse
+	(*ConvertRequest)(nil),    // 0: converter.v1.ConvertRequest
+	(*ConvertOptions)(nil),    // 1: converter.v1.ConvertOptions
+	(*ConvertResponse)(nil),   // 2: converter.v1.ConvertResponse
+	(*ThumbnailRequest)(nil),  // 3: converter.v1.ThumbnailRequest
+	(*ThumbnailResponse)(nil), // 4: converter.v1.ThumbnailResponse
 }
 var file_pkg_proto_converter_proto_depIdxs = []int32{
-	0, // 0: converter.v1.ConverterService.Convert:input_type -> converter.v1.ConvertRequest
-	1, // 1: converter.v1.ConverterService.Convert:output_type -> converter.v1.ConvertResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1, // 0: converter.v1.ConvertRequest.options:type_name -> converter.v1.ConvertOptions
+	0, // 1: converter.v1.ConverterService.Convert:input_type -> converter.v1.ConvertRequest
+	3, // 2: converter.v1.ConverterService.Thumbnail:input_type -> converter.v1.ThumbnailRequest
+	2, // 3: converter.v1.ConverterService.Convert:output_type -> converter.v1.ConvertResponse
+	4, // 4: converter.v1.ConverterService.Thumbnail:output_type -> converter.v1.ThumbnailResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_pkg_proto_converter_proto_init() }
@@ -169,7 +540,7 @@ func file_pkg_proto_converter_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_proto_converter_proto_rawDesc), len(file_pkg_proto_converter_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ConverterService_Convert_FullMethodName = "/converter.v1.ConverterService/Convert"
+	ConverterService_Convert_FullMethodName   = "/converter.v1.ConverterService/Convert"
+	ConverterService_Thumbnail_FullMethodName = "/converter.v1.ConverterService/Thumbnail"
 )
 
 // ConverterServiceClient is the client API for ConverterService service.
@@ -27,6 +28,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ConverterServiceClient interface {
 	Convert(ctx context.Context, in *ConvertRequest, opts ...grpc.CallOption) (*ConvertResponse, error)
+	Thumbnail(ctx context.Context, in *ThumbnailRequest, opts ...grpc.CallOption) (*ThumbnailResponse, error)
 }
 
 type converterServiceClient struct {
@@ -47,11 +49,22 @@ func (c *converterServiceClient) Convert(ctx context.Context, in *ConvertRequest
 	return out, nil
 }
 
+func (c *converterServiceClient) Thumbnail(ctx context.Context, in *ThumbnailRequest, opts ...grpc.CallOption) (*ThumbnailResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ThumbnailResponse)
+	err := c.cc.Invoke(ctx, ConverterService_Thumbnail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ConverterServiceServer is the server API for ConverterService service.
 // All implementations must embed UnimplementedConverterServiceServer
 // for forward compatibility.
 type ConverterServiceServer interface {
 	Convert(context.Context, *ConvertRequest) (*ConvertResponse, error)
+	Thumbnail(context.Context, *ThumbnailRequest) (*ThumbnailResponse, error)
 	mustEmbedUnimplementedConverterServiceServer()
 }
 
@@ -65,6 +78,9 @@ type UnimplementedConverterServiceServer struct{}
 func (UnimplementedConverterServiceServer) Convert(context.Context, *ConvertRequest) (*ConvertResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Convert not implemented")
 }
+func (UnimplementedConverterServiceServer) Thumbnail(context.Context, *ThumbnailRequest) (*ThumbnailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Thumbnail not implemented")
+}
 func (UnimplementedConverterServiceServer) mustEmbedUnimplementedConverterServiceServer() {}
 func (UnimplementedConverterServiceServer) testEmbeddedByValue()                          {}
 
@@ -104,6 +120,24 @@ func _ConverterService_Convert_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ConverterService_Thumbnail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ThumbnailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConverterServiceServer).Thumbnail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConverterService_Thumbnail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConverterServiceServer).Thumbnail(ctx, req.(*ThumbnailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ConverterService_ServiceDesc is the grpc.ServiceDesc for ConverterService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +149,10 @@ var ConverterService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Convert",
 			Handler:    _ConverterService_Convert_Handler,
 		},
+		{
+			MethodName: "Thumbnail",
+			Handler:    _ConverterService_Thumbnail_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/proto/converter.proto",
@@ -0,0 +1,200 @@
+// Package bundle reads the ZIP archives a drawing can be uploaded in,
+// together with the files it references: raster images and external
+// references. Drawings store those references as paths on the machine
+// they were made on, so they are matched against the archive by their
+// trailing path elements.
+package bundle
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrNoDrawing = errors.New("bundle has no .dwg or .dxf drawing")
+	ErrAmbiguous = errors.New("bundle has more than one drawing at its top level")
+	ErrTooLarge  = errors.New("bundle file is too large")
+)
+
+// MaxFileSize limits the unpacked size of one file of a bundle.
+const MaxFileSize = 512 << 20
+
+// Bundle is an opened archive.
+type Bundle struct {
+	files []*zip.File
+	// nested is set when everything is in one folder, as in archives made
+	// of a folder rather than of its contents; its top level is then that
+	// of the folder.
+	nested bool
+}
+
+// Open reads the directory of an archive.
+func Open(r io.ReaderAt, size int64) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	b := &Bundle{}
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() {
+			b.files = append(b.files, f)
+		}
+	}
+	b.nested = nested(b.files)
+	return b, nil
+}
+
+// nested reports whether all files are in the same top-level folder.
+func nested(files []*zip.File) bool {
+	root := ""
+	for i, f := range files {
+		dir, _, ok := strings.Cut(clean(f.Name), "/")
+		if !ok {
+			return false
+		}
+		if i == 0 {
+			root = dir
+		} else if !strings.EqualFold(dir, root) {
+			return false
+		}
+	}
+	return root != ""
+}
+
+// Drawing returns the path of the drawing to convert: the only drawing
+// at the top level of the archive or, when there are several, the one
+// named like the archive itself.
+func (b *Bundle) Drawing(archiveName string) (string, error) {
+	var top []string
+	for _, f := range b.files {
+		name := clean(f.Name)
+		rel := name
+		if b.nested {
+			_, rel, _ = strings.Cut(name, "/")
+		}
+		if strings.Contains(rel, "/") || !IsDrawing(rel) {
+			continue
+		}
+		top = append(top, name)
+	}
+	switch len(top) {
+	case 0:
+		return "", ErrNoDrawing
+	case 1:
+		return top[0], nil
+	}
+	want := stem(path.Base(clean(archiveName)))
+	for _, name := range top {
+		if strings.EqualFold(stem(path.Base(name)), want) {
+			return name, nil
+		}
+	}
+	return "", ErrAmbiguous
+}
+
+// IsDrawing reports whether a file name has a drawing extension.
+func IsDrawing(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".dwg" || ext == ".dxf"
+}
+
+// Find returns the path of the file a reference made from the drawing at
+// from points to, or "" when the bundle does not have it. Relative
+// references are first looked up next to from; after that the file
+// whose path shares the most trailing elements with the reference wins,
+// down to a file of the same name anywhere in the archive. Names are
+// compared regardless of case, as on Windows.
+func (b *Bundle) Find(from, ref string) string {
+	ref = clean(ref)
+	if ref == "" {
+		return ""
+	}
+	if !strings.HasPrefix(ref, "/") && !strings.Contains(ref, ":") {
+		want := path.Join(path.Dir(clean(from)), ref)
+		for _, f := range b.files {
+			if strings.EqualFold(clean(f.Name), want) {
+				return clean(f.Name)
+			}
+		}
+	}
+	parts := strings.Split(strings.TrimPrefix(ref, "/"), "/")
+	if i := strings.LastIndex(parts[0], ":"); i >= 0 {
+		// A drive letter or URL scheme is no folder of the archive.
+		parts[0] = parts[0][i+1:]
+	}
+	best, bestLen := "", 0
+	for _, f := range b.files {
+		name := clean(f.Name)
+		if n := sharedTail(strings.Split(name, "/"), parts); n > bestLen {
+			best, bestLen = name, n
+		}
+	}
+	return best
+}
+
+// sharedTail counts the trailing elements two paths have in common.
+func sharedTail(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && strings.EqualFold(a[len(a)-1-n], b[len(b)-1-n]) {
+		n++
+	}
+	return n
+}
+
+// Open opens a file of the bundle for reading and returns its unpacked
+// size.
+func (b *Bundle) Open(name string) (io.ReadCloser, int64, error) {
+	i := slices.IndexFunc(b.files, func(f *zip.File) bool { return clean(f.Name) == name })
+	if i < 0 {
+		return nil, 0, fmt.Errorf("bundle: %s: file does not exist", name)
+	}
+	f := b.files[i]
+	if f.UncompressedSize64 > MaxFileSize {
+		return nil, 0, fmt.Errorf("%w: %s", ErrTooLarge, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, 0, fmt.Errorf("bundle: %s: %w", name, err)
+	}
+	return rc, int64(f.UncompressedSize64), nil
+}
+
+// ReadFile returns the contents of a file of the bundle.
+func (b *Bundle) ReadFile(name string) ([]byte, error) {
+	rc, _, err := b.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %s: %w", name, err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%w: %s", ErrTooLarge, name)
+	}
+	return data, nil
+}
+
+// clean brings a path as a drawing or an archive stores it to slash
+// separated form without dot elements.
+func clean(p string) string {
+	p = strings.TrimSpace(strings.ReplaceAll(p, `\`, "/"))
+	if p == "" {
+		return ""
+	}
+	p = path.Clean(p)
+	if p == "." {
+		return ""
+	}
+	return strings.TrimPrefix(p, "./")
+}
+
+func stem(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name))
+}
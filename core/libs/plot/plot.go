@@ -0,0 +1,362 @@
+// Package plot parses the plot settings a conversion request can override:
+// paper size, orientation, scale, plot style table, lineweights and the
+// layers to plot, and the format of the output and, for images, their
+// resolution and background and, for DXF files, their version.
+package plot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrPaper       = errors.New("unknown paper size")
+	ErrOrientation = errors.New("orientation must be portrait or landscape")
+	ErrScale       = errors.New("scale must be fit, a ratio such as 1:100 or a positive factor")
+	ErrPlotStyle   = errors.New("plot style table must be a .ctb or .stb file name")
+	ErrLineweights = errors.New("lineweights must be plot or display")
+	ErrSHXText     = errors.New("shx text must be font, strokes or overlay")
+	ErrOutput      = errors.New("output format must be pdf, pdfa, svg, png, jpeg or dxf")
+	ErrDPI         = errors.New("dpi must be a number from 10 to 2400")
+	ErrImageSize   = errors.New("image size must be a width, a height or both in pixels, such as 1920x1080, 1920 or x1080")
+	ErrBackground  = errors.New("background must be a color such as #ffffff, white, black or transparent")
+	ErrDXFVersion  = errors.New("dxf version must be r12, 2000 or 2018")
+	ErrLayers      = errors.New("layers must be a JSON array or a comma separated list of layer names or glob patterns")
+)
+
+// Paper is a paper size in millimetres, given portrait.
+type Paper struct {
+	Width, Height float64
+}
+
+const inch = 25.4
+
+var papers = map[string]Paper{
+	"A0": {841, 1189},
+	"A1": {594, 841},
+	"A2": {420, 594},
+	"A3": {297, 420},
+	"A4": {210, 297},
+	"A5": {148, 210},
+	"B4": {250, 353},
+	"B5": {176, 250},
+
+	"LETTER":  {8.5 * inch, 11 * inch},
+	"LEGAL":   {8.5 * inch, 14 * inch},
+	"TABLOID": {11 * inch, 17 * inch},
+	"LEDGER":  {11 * inch, 17 * inch},
+	"ANSI A":  {8.5 * inch, 11 * inch},
+	"ANSI B":  {11 * inch, 17 * inch},
+	"ANSI C":  {17 * inch, 22 * inch},
+	"ANSI D":  {22 * inch, 34 * inch},
+	"ANSI E":  {34 * inch, 44 * inch},
+	"ARCH A":  {9 * inch, 12 * inch},
+	"ARCH B":  {12 * inch, 18 * inch},
+	"ARCH C":  {18 * inch, 24 * inch},
+	"ARCH D":  {24 * inch, 36 * inch},
+	"ARCH E":  {36 * inch, 48 * inch},
+	"ARCH E1": {30 * inch, 42 * inch},
+}
+
+// ParsePaper reads a paper name such as A3, Letter or ANSI D, or a size
+// such as 420x297 in millimetres or 11x17in in inches.
+func ParsePaper(s string) (Paper, error) {
+	name := strings.Join(strings.Fields(strings.ToUpper(strings.ReplaceAll(s, "_", " "))), " ")
+	if p, ok := papers[name]; ok {
+		return p, nil
+	}
+
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(name, "MM"):
+		name = strings.TrimSuffix(name, "MM")
+	case strings.HasSuffix(name, "IN"):
+		name, unit = strings.TrimSuffix(name, "IN"), inch
+	}
+	w, h, ok := strings.Cut(name, "X")
+	if !ok {
+		return Paper{}, fmt.Errorf("%w: %q", ErrPaper, s)
+	}
+	width, ok1 := positive(w)
+	height, ok2 := positive(h)
+	if !ok1 || !ok2 {
+		return Paper{}, fmt.Errorf("%w: %q", ErrPaper, s)
+	}
+	return Paper{Width: width * unit, Height: height * unit}, nil
+}
+
+type Orientation string
+
+const (
+	Portrait  Orientation = "portrait"
+	Landscape Orientation = "landscape"
+)
+
+func ParseOrientation(s string) (Orientation, error) {
+	switch o := Orientation(strings.ToLower(strings.TrimSpace(s))); o {
+	case Portrait, Landscape:
+		return o, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrOrientation, s)
+}
+
+// Scale is a plot scale: either fit to the paper, or Factor paper units
+// per drawing unit.
+type Scale struct {
+	Fit    bool
+	Factor float64
+}
+
+// ParseScale reads "fit", a ratio of paper to drawing units such as 1:100
+// or 1/100, or a plain factor such as 0.01.
+func ParseScale(s string) (Scale, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "fit" {
+		return Scale{Fit: true}, nil
+	}
+	num, den := v, "1"
+	if i := strings.IndexAny(v, ":/"); i >= 0 {
+		num, den = v[:i], v[i+1:]
+	}
+	n, ok1 := positive(num)
+	d, ok2 := positive(den)
+	if !ok1 || !ok2 {
+		return Scale{}, fmt.Errorf("%w: %q", ErrScale, s)
+	}
+	return Scale{Factor: n / d}, nil
+}
+
+// ParsePlotStyle checks the file name of a plot style table, which may
+// leave out the .ctb or .stb extension.
+func ParsePlotStyle(s string) (string, error) {
+	name := strings.TrimSpace(s)
+	ext := strings.ToLower(filepath.Ext(name))
+	if name == "" || strings.ContainsAny(name, `/\`) || ext != "" && ext != ".ctb" && ext != ".stb" {
+		return "", fmt.Errorf("%w: %q", ErrPlotStyle, s)
+	}
+	return name, nil
+}
+
+// Lineweights chooses the lineweights lines are drawn with: those of the
+// plot, or those the drawing displays on screen.
+type Lineweights string
+
+const (
+	PlotLineweights    Lineweights = "plot"
+	DisplayLineweights Lineweights = "display"
+)
+
+func ParseLineweights(s string) (Lineweights, error) {
+	switch l := Lineweights(strings.ToLower(strings.TrimSpace(s))); l {
+	case PlotLineweights, DisplayLineweights:
+		return l, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrLineweights, s)
+}
+
+// SHXText chooses how text in SHX fonts is drawn: with a TrueType font
+// in its place, as the strokes of the SHX font, or as those strokes with
+// invisible text laid over them so it can still be searched and copied.
+type SHXText string
+
+const (
+	SHXFont    SHXText = "font"
+	SHXStrokes SHXText = "strokes"
+	SHXOverlay SHXText = "overlay"
+)
+
+func ParseSHXText(s string) (SHXText, error) {
+	switch v := SHXText(strings.ToLower(strings.TrimSpace(s))); v {
+	case SHXFont, SHXStrokes, SHXOverlay:
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrSHXText, s)
+}
+
+// Output is the kind of file a conversion makes: a PDF with a page per
+// layout, possibly one conforming to PDF/A-2b for archiving, an SVG, PNG
+// or JPEG image of each layout, or a DXF file of the whole drawing.
+type Output string
+
+const (
+	OutputPDF  Output = "pdf"
+	OutputPDFA Output = "pdfa"
+	OutputSVG  Output = "svg"
+	OutputPNG  Output = "png"
+	OutputJPEG Output = "jpeg"
+	OutputDXF  Output = "dxf"
+)
+
+// ParseOutput reads an output format; jpg stands for jpeg, and pdf/a and
+// pdfa-2b for pdfa.
+func ParseOutput(s string) (Output, error) {
+	switch v := Output(strings.ToLower(strings.TrimSpace(s))); v {
+	case OutputPDF, OutputPDFA, OutputSVG, OutputPNG, OutputJPEG, OutputDXF:
+		return v, nil
+	case "jpg":
+		return OutputJPEG, nil
+	case "pdf/a", "pdf/a-2b", "pdfa-2b":
+		return OutputPDFA, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrOutput, s)
+}
+
+// Raster tells whether the output is a raster image.
+func (o Output) Raster() bool { return o == OutputPNG || o == OutputJPEG }
+
+// Resolutions images can be drawn at, in dots per inch.
+const (
+	MinDPI = 10
+	MaxDPI = 2400
+)
+
+// ParseDPI reads the resolution of raster images in dots per inch.
+func ParseDPI(s string) (float64, error) {
+	v, ok := positive(s)
+	if !ok || v < MinDPI || v > MaxDPI {
+		return 0, fmt.Errorf("%w: %q", ErrDPI, s)
+	}
+	return v, nil
+}
+
+// MaxImageSide is the largest width or height of an image in pixels.
+const MaxImageSide = 20000
+
+// ImageSize is the size in pixels a raster image fits in. Either side is
+// zero when only the other one is given.
+type ImageSize struct {
+	Width, Height int
+}
+
+// ParseImageSize reads a width and height such as 1920x1080, or just a
+// width such as 1920 or a height such as x1080.
+func ParseImageSize(s string) (ImageSize, error) {
+	w, h, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	side := func(v string) (int, bool) {
+		if v = strings.TrimSpace(v); v == "" {
+			return 0, true
+		}
+		n, err := strconv.Atoi(v)
+		return n, err == nil && n > 0 && n <= MaxImageSide
+	}
+	width, ok1 := side(w)
+	height, ok2 := side(h)
+	if !ok1 || !ok2 || width == 0 && height == 0 {
+		return ImageSize{}, fmt.Errorf("%w: %q", ErrImageSize, s)
+	}
+	return ImageSize{Width: width, Height: height}, nil
+}
+
+func (s ImageSize) String() string {
+	var b strings.Builder
+	if s.Width > 0 {
+		b.WriteString(strconv.Itoa(s.Width))
+	}
+	b.WriteByte('x')
+	if s.Height > 0 {
+		b.WriteString(strconv.Itoa(s.Height))
+	}
+	return b.String()
+}
+
+// Background is the color raster images are drawn on.
+type Background struct {
+	R, G, B     uint8
+	Transparent bool
+}
+
+var backgrounds = map[string]Background{
+	"white":       {R: 255, G: 255, B: 255},
+	"black":       {},
+	"transparent": {Transparent: true},
+}
+
+// ParseBackground reads a color as #rrggbb, #rgb or white, black or
+// transparent.
+func ParseBackground(s string) (Background, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if b, ok := backgrounds[v]; ok {
+		return b, nil
+	}
+	hex := strings.TrimPrefix(v, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return Background{}, fmt.Errorf("%w: %q", ErrBackground, s)
+	}
+	return Background{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n)}, nil
+}
+
+func (b Background) String() string {
+	if b.Transparent {
+		return "transparent"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", b.R, b.G, b.B)
+}
+
+// DXFVersion is the release whose DXF format a drawing is written in.
+type DXFVersion string
+
+const (
+	DXFR12  DXFVersion = "r12"
+	DXF2000 DXFVersion = "2000"
+	DXF2018 DXFVersion = "2018"
+)
+
+// ParseDXFVersion reads a DXF version as r12, 2000 or 2018, with or
+// without an R in front, or as the AC1009, AC1015 or AC1032 tag of the
+// release.
+func ParseDXFVersion(s string) (DXFVersion, error) {
+	switch v := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "r"); v {
+	case "12", "ac1009":
+		return DXFR12, nil
+	case "2000", "ac1015":
+		return DXF2000, nil
+	case "2018", "ac1032":
+		return DXF2018, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrDXFVersion, s)
+}
+
+// ParseLayers reads a list of layer names and glob patterns such as
+// *-NOTES, given as a JSON array or separated by commas. Only names in a
+// JSON array may contain commas.
+func ParseLayers(s string) ([]string, error) {
+	var list []string
+	if v := strings.TrimSpace(s); strings.HasPrefix(v, "[") {
+		if err := json.Unmarshal([]byte(v), &list); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrLayers, s)
+		}
+	} else {
+		list = strings.Split(v, ",")
+	}
+	out := make([]string, 0, len(list))
+	for _, l := range list {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if _, err := path.Match(l, ""); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrLayers, s)
+		}
+		out = append(out, l)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrLayers, s)
+	}
+	return out, nil
+}
+
+// positive parses a finite number greater than zero.
+func positive(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v, err == nil && v > 0 && !math.IsInf(v, 0)
+}
@@ -0,0 +1,103 @@
+package thumbnail
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Compressions of device independent bitmaps that are read.
+const (
+	biRGB       = 0
+	biBitfields = 3
+)
+
+// maxSide bounds the width and height of a preview.
+const maxSide = 4096
+
+// decodeDIB decodes a device independent bitmap: a BITMAPINFOHEADER, a
+// palette and uncompressed rows of 1, 4, 8, 24 or 32 bits per pixel,
+// padded to 4 bytes and stored bottom up unless the height is negative.
+// A .bmp file header in front of it is skipped.
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) >= 14 && data[0] == 'B' && data[1] == 'M' {
+		data = data[14:]
+	}
+	if len(data) < 40 {
+		return nil, fmt.Errorf("%w: short bitmap header", ErrUnsupported)
+	}
+	le := binary.LittleEndian
+	headerSize := int(le.Uint32(data))
+	width := int(int32(le.Uint32(data[4:])))
+	height := int(int32(le.Uint32(data[8:])))
+	bits := int(le.Uint16(data[14:]))
+	compression := le.Uint32(data[16:])
+	colors := int(le.Uint32(data[32:]))
+
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	if headerSize < 40 || headerSize > len(data) || width <= 0 || height <= 0 || width > maxSide || height > maxSide {
+		return nil, fmt.Errorf("%w: bad bitmap header", ErrUnsupported)
+	}
+	if compression != biRGB && !(compression == biBitfields && bits == 32) {
+		return nil, fmt.Errorf("%w: compressed bitmap", ErrUnsupported)
+	}
+	off := headerSize
+	if compression == biBitfields && headerSize == 40 {
+		// The masks follow a short header; 32 bit previews use the usual
+		// ones.
+		off += 12
+	}
+
+	var palette color.Palette
+	switch bits {
+	case 1, 4, 8:
+		if colors == 0 || colors > 1<<bits {
+			colors = 1 << bits
+		}
+		if off+4*colors > len(data) {
+			return nil, fmt.Errorf("%w: short palette", ErrUnsupported)
+		}
+		palette = make(color.Palette, colors)
+		for i := range palette {
+			p := data[off+4*i:]
+			palette[i] = color.RGBA{p[2], p[1], p[0], 0xFF}
+		}
+		off += 4 * colors
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("%w: %d bits per pixel", ErrUnsupported, bits)
+	}
+
+	stride := (width*bits + 31) / 32 * 4
+	if off+stride*height > len(data) {
+		return nil, fmt.Errorf("%w: short bitmap", ErrUnsupported)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		row := data[off+stride*y:]
+		if !topDown {
+			row = data[off+stride*(height-1-y):]
+		}
+		for x := range width {
+			var c color.RGBA
+			switch bits {
+			case 24, 32:
+				p := row[x*bits/8:]
+				c = color.RGBA{p[2], p[1], p[0], 0xFF}
+			default:
+				perByte := 8 / bits
+				shift := uint(8 - bits*(x%perByte+1))
+				i := int(row[x/perByte]>>shift) & (1<<bits - 1)
+				if i < len(palette) {
+					c = palette[i].(color.RGBA)
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
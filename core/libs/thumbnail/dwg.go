@@ -0,0 +1,87 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// previewSentinel starts the preview of DWG files.
+var previewSentinel = []byte{
+	0x1F, 0x25, 0x6D, 0x07, 0xD4, 0x36, 0x28, 0x28,
+	0x9D, 0x57, 0xCA, 0x3F, 0x9D, 0x44, 0x10, 0x2B,
+}
+
+const (
+	// previewAddress is where the file header keeps the address of the
+	// preview.
+	previewAddress = 0x0D
+	// previewSlack is how far past its address the preview is looked for,
+	// as releases differ on whether the address includes the header of
+	// the page it is on.
+	previewSlack = 0x100
+)
+
+// Codes of the entries of a preview.
+const (
+	entryHeader = 1
+	entryBMP    = 2
+	entryWMF    = 3
+	entryPNG    = 6
+)
+
+// fromDWG reads the preview of a DWG file: after the sentinel, its size,
+// the number of entries and for each entry a code, the address and the
+// size of its data.
+func fromDWG(r io.ReaderAt, size int64) ([]byte, error) {
+	var head [previewAddress + 4]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil || !bytes.HasPrefix(head[:], []byte("AC")) {
+		return nil, fmt.Errorf("%w: not a DWG file", ErrUnsupported)
+	}
+	addr := int64(binary.LittleEndian.Uint32(head[previewAddress:]))
+	if addr == 0 || addr >= size {
+		return nil, ErrNoThumbnail
+	}
+	window := make([]byte, min(previewSlack+int64(len(previewSentinel))+5, size-addr))
+	n, _ := r.ReadAt(window, addr)
+	i := bytes.Index(window[:n], previewSentinel)
+	if i < 0 || i+len(previewSentinel)+5 > n {
+		return nil, ErrNoThumbnail
+	}
+	at := addr + int64(i+len(previewSentinel))
+	count := int(window[i+len(previewSentinel)+4])
+	entries := make([]byte, 9*count)
+	if _, err := r.ReadAt(entries, at+5); err != nil {
+		return nil, ErrNoThumbnail
+	}
+
+	var bmp, png []byte
+	for e := entries; len(e) >= 9; e = e[9:] {
+		code := e[0]
+		start := int64(binary.LittleEndian.Uint32(e[1:]))
+		n := int64(binary.LittleEndian.Uint32(e[5:]))
+		if code != entryBMP && code != entryPNG || n == 0 {
+			continue
+		}
+		if n > MaxSize || start+n > size {
+			return nil, fmt.Errorf("%w: preview out of the file", ErrUnsupported)
+		}
+		data := make([]byte, n)
+		if _, err := r.ReadAt(data, start); err != nil {
+			return nil, fmt.Errorf("read preview: %w", err)
+		}
+		if code == entryPNG {
+			png = data
+		} else {
+			bmp = data
+		}
+	}
+	switch {
+	case png != nil:
+		return fromPNG(png)
+	case bmp != nil:
+		return fromBMP(bmp)
+	}
+	return nil, ErrNoThumbnail
+}
@@ -0,0 +1,64 @@
+package thumbnail
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// binaryDXF starts binary DXF files, whose previews are not read.
+const binaryDXF = "AutoCAD Binary DXF"
+
+// fromDXF reads the preview of an ASCII DXF file: the hexadecimal 310
+// groups of its THUMBNAILIMAGE section.
+func fromDXF(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(binaryDXF)); string(head) == binaryDXF {
+		return nil, fmt.Errorf("%w: binary DXF", ErrUnsupported)
+	}
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	var data []byte
+	var prev string
+	inSection, isThumbnail := false, false
+	for sc.Scan() {
+		code := strings.TrimSpace(sc.Text())
+		if !sc.Scan() {
+			break
+		}
+		value := strings.TrimSpace(sc.Text())
+		switch {
+		case code == "0" && value == "SECTION":
+			inSection = true
+		case code == "2" && inSection && prev == "SECTION":
+			isThumbnail = value == "THUMBNAILIMAGE"
+		case code == "0" && value == "ENDSEC":
+			if isThumbnail {
+				return fromBMP(data)
+			}
+			inSection = false
+		case code == "0" && value == "EOF":
+			return nil, ErrNoThumbnail
+		case code == "310" && isThumbnail:
+			chunk, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+			}
+			if len(data)+len(chunk) > MaxSize {
+				return nil, fmt.Errorf("%w: preview too large", ErrUnsupported)
+			}
+			data = append(data, chunk...)
+		}
+		prev = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read dxf: %w", err)
+	}
+	if isThumbnail && len(data) > 0 {
+		return fromBMP(data)
+	}
+	return nil, ErrNoThumbnail
+}
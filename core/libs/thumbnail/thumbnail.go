@@ -0,0 +1,120 @@
+// Package thumbnail extracts the preview images drawings carry: DWG files
+// keep one after their file header, as a BMP, a WMF or, since R2013, a
+// PNG; DXF files in their THUMBNAILIMAGE section, as a BMP. Previews are
+// returned as PNG files; WMF previews, which browsers cannot show, are
+// left out.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/you-humble/dwgtopdf/core/libs/bundle"
+)
+
+var (
+	ErrNoThumbnail = errors.New("drawing has no preview image")
+	ErrUnsupported = errors.New("unsupported preview image")
+)
+
+const (
+	// MaxSize limits the size of a preview image, which AutoCAD keeps
+	// small.
+	MaxSize = 4 << 20
+	// maxHead limits how much of a drawing packed in a bundle is unpacked
+	// to find its preview, which DWG files keep right after their file
+	// header.
+	maxHead = 16 << 20
+)
+
+// Extract returns the preview of a .dwg or .dxf drawing, or of the
+// drawing a .zip bundle is uploaded for, as a PNG file. The name of the
+// upload tells its format.
+func Extract(r io.ReaderAt, size int64, name string) ([]byte, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".dwg":
+		return fromDWG(r, size)
+	case ".dxf":
+		return fromDXF(io.NewSectionReader(r, 0, size))
+	case ".zip":
+		b, err := bundle.Open(r, size)
+		if err != nil {
+			return nil, err
+		}
+		drawing, err := b.Drawing(name)
+		if err != nil {
+			return nil, err
+		}
+		rc, size, err := b.Open(drawing)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		// DXF files are scanned as they are unpacked; of DWG files only
+		// the start is unpacked.
+		if strings.EqualFold(path.Ext(drawing), ".dxf") {
+			return fromDXF(rc)
+		}
+		return fromDWG(&head{r: rc}, size)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupported, name)
+}
+
+// head gives random access to the start of a stream, which it reads only
+// as far as asked and no further than maxHead.
+type head struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (h *head) ReadAt(p []byte, off int64) (int, error) {
+	end := off + int64(len(p))
+	if off < 0 || end > maxHead {
+		return 0, fmt.Errorf("%w: preview past the first %d bytes", ErrUnsupported, maxHead)
+	}
+	if n := end - int64(len(h.buf)); n > 0 {
+		more := make([]byte, n)
+		m, _ := io.ReadFull(h.r, more)
+		h.buf = append(h.buf, more[:m]...)
+	}
+	if off >= int64(len(h.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fromBMP converts a BMP preview, which drawings keep without the file
+// header of .bmp files, to PNG.
+func fromBMP(data []byte) ([]byte, error) {
+	img, err := decodeDIB(data)
+	if err != nil {
+		return nil, err
+	}
+	return encode(img)
+}
+
+// fromPNG checks a PNG preview, which is kept as it is.
+func fromPNG(data []byte) ([]byte, error) {
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+	return data, nil
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode preview: %w", err)
+	}
+	return buf.Bytes(), nil
+}
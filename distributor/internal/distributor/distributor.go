@@ -7,6 +7,7 @@ import (
 	"time"
 
 	converterpb "github.com/you-humble/dwgtopdf/core/grpc/gen"
+	"github.com/you-humble/dwgtopdf/core/libs/plot"
 	"github.com/you-humble/dwgtopdf/distributor/internal/domain"
 
 	"github.com/nats-io/nats.go"
@@ -15,7 +16,8 @@ import (
 type TaskStore interface {
 	Task(id string) (domain.Task, bool)
 	UpdateStatus(id string, newStatus domain.TaskStatus, errReason string)
-	SetResult(id string, pdfName string)
+	SetResult(id string, resultName string, warnings, unresolvedXrefs []string)
+	SetThumbnail(id string, thumbnailName string)
 	ExpiredTasks(now time.Time) []string
 	DeleteExpired(now time.Time, ttl time.Duration) int
 }
@@ -184,6 +186,9 @@ func (d *natsDistributor) process(ctx context.Context, taskID string) error {
 
 	slog.Info("process start", slog.String("task_id", taskID))
 	d.taskStore.UpdateStatus(taskID, domain.StatusProcessing, "")
+	if task.ThumbnailFilename == "" {
+		d.thumbnail(ctx, task)
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, d.conversionTimeout)
 	defer cancel()
@@ -192,17 +197,62 @@ func (d *natsDistributor) process(ctx context.Context, taskID string) error {
 		&converterpb.ConvertRequest{
 			InputPath:     task.InputFilename,
 			SuggestedName: task.OriginalName,
+			InputFormat:   task.InputFormat,
+			Options: &converterpb.ConvertOptions{
+				PaperSize:     task.Options.PaperSize,
+				Orientation:   task.Options.Orientation,
+				Scale:         task.Options.Scale,
+				PlotStyle:     task.Options.PlotStyle,
+				Lineweights:   task.Options.Lineweights,
+				IncludeLayers: layers(task.Options.IncludeLayers),
+				ExcludeLayers: layers(task.Options.ExcludeLayers),
+				PdfLayers:     task.Options.PDFLayers,
+				ShxText:       task.Options.SHXText,
+				NoHyperlinks:  task.Options.NoHyperlinks,
+				OutputFormat:  task.Options.OutputFormat,
+				Dpi:           task.Options.DPI,
+				ImageSize:     task.Options.ImageSize,
+				Background:    task.Options.Background,
+				NoAntialias:   task.Options.NoAntialias,
+				DxfVersion:    task.Options.DXFVersion,
+			},
+			PlotStylePath: task.PlotStyleFilename,
+			TaskId:        task.ID,
 		})
 	if err != nil {
 		d.taskStore.UpdateStatus(taskID, domain.StatusFailed, err.Error())
 		return err
 	}
 
-	d.taskStore.SetResult(taskID, resp.PdfName)
-	slog.Info("process done", slog.String("task_id", taskID))
+	d.taskStore.SetResult(taskID, resp.GetResultName(), resp.GetWarnings(), resp.GetUnresolvedXrefs())
+	slog.Info("process done",
+		slog.String("task_id", taskID),
+		slog.Any("layouts", resp.GetLayouts()),
+		slog.Any("warnings", resp.GetWarnings()),
+		slog.Any("unresolved_xrefs", resp.GetUnresolvedXrefs()),
+	)
 	return nil
 }
 
+// thumbnail has the converter draw a preview of a drawing that carries
+// none, so that the task shows one while it is converted and even when
+// the conversion fails. Without one the task simply has no thumbnail.
+func (d *natsDistributor) thumbnail(ctx context.Context, task domain.Task) {
+	ctx, cancel := context.WithTimeout(ctx, d.conversionTimeout)
+	defer cancel()
+
+	resp, err := d.converter.Thumbnail(ctx, &converterpb.ThumbnailRequest{
+		InputPath:     task.InputFilename,
+		SuggestedName: task.OriginalName,
+		InputFormat:   task.InputFormat,
+	})
+	if err != nil {
+		slog.Warn("thumbnail", slog.String("task_id", task.ID), slog.String("error", err.Error()))
+		return
+	}
+	d.taskStore.SetThumbnail(task.ID, resp.GetThumbnailName())
+}
+
 func (d *natsDistributor) StartCleanup(ctx context.Context) {
 	ticker := time.NewTicker(d.taskCleanupInterval)
 
@@ -226,11 +276,21 @@ func (d *natsDistributor) StartCleanup(ctx context.Context) {
 					if err := d.fileCleaner.Delete(ctx, task.InputFilename); err != nil {
 						slog.Warn("cleanup input file", slog.String("error", err.Error()))
 					}
+					if task.PlotStyleFilename != "" {
+						if err := d.fileCleaner.Delete(ctx, task.PlotStyleFilename); err != nil {
+							slog.Warn("cleanup plot style table", slog.String("error", err.Error()))
+						}
+					}
 					if task.ResultFilename != "" {
 						if err := d.fileCleaner.Delete(ctx, task.ResultFilename); err != nil {
 							slog.Warn("cleanup result file", slog.String("error", err.Error()))
 						}
 					}
+					if task.ThumbnailFilename != "" {
+						if err := d.fileCleaner.Delete(ctx, task.ThumbnailFilename); err != nil {
+							slog.Warn("cleanup thumbnail", slog.String("error", err.Error()))
+						}
+					}
 				}
 				if n := d.taskStore.DeleteExpired(now, 2*d.taskTTL); n > 0 {
 					slog.Info("cleanup tasks map", slog.Int("deleted_tasks", n))
@@ -243,3 +303,16 @@ func (d *natsDistributor) StartCleanup(ctx context.Context) {
 		}
 	}()
 }
+
+// layers reads the layer list of a task, a JSON array, or a comma
+// separated list in tasks stored before that.
+func layers(s string) []string {
+	if s == "" {
+		return nil
+	}
+	list, err := plot.ParseLayers(s)
+	if err != nil {
+		slog.Warn("task layer list", slog.String("error", err.Error()))
+	}
+	return list
+}
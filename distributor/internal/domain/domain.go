@@ -22,8 +22,16 @@ type Task struct {
 
 	OriginalName  string `json:"original_name"`
 	InputFilename string `json:"input_filename"`
+	InputFormat   string `json:"input_format"`
+	// PlotStyleFilename is the plot style table uploaded with the file.
+	PlotStyleFilename string `json:"plot_style_filename,omitempty"`
+
+	Options ConvertOptions `json:"options"`
 
 	ResultFilename string `json:"result_filename"`
+	// ThumbnailFilename is the preview of the drawing, taken from the
+	// upload or drawn before it is converted.
+	ThumbnailFilename string `json:"thumbnail_filename,omitempty"`
 
 	// meta
 	FileSize       int64     `json:"file_size"`
@@ -35,6 +43,40 @@ type Task struct {
 	Error          string    `json:"error"`
 }
 
+// ConvertOptions override the page setups of the drawing; empty fields
+// keep them.
+type ConvertOptions struct {
+	PaperSize   string `json:"paper_size,omitempty"`
+	Orientation string `json:"orientation,omitempty"`
+	Scale       string `json:"scale,omitempty"`
+	// PlotStyle names a plot style table installed on the converter;
+	// PlotStyleHash is the SHA-256 of one uploaded with the file instead.
+	PlotStyle     string `json:"plot_style,omitempty"`
+	PlotStyleHash string `json:"plot_style_hash,omitempty"`
+	// Lineweights is plot or display.
+	Lineweights string `json:"lineweights,omitempty"`
+	// IncludeLayers and ExcludeLayers are JSON arrays of layer names and
+	// glob patterns.
+	IncludeLayers string `json:"include_layers,omitempty"`
+	ExcludeLayers string `json:"exclude_layers,omitempty"`
+	// PDFLayers makes each layer an optional content group of the PDF.
+	PDFLayers bool `json:"pdf_layers,omitempty"`
+	// SHXText is font, strokes or overlay.
+	SHXText string `json:"shx_text,omitempty"`
+	// NoHyperlinks leaves out the links of entity hyperlinks.
+	NoHyperlinks bool `json:"no_hyperlinks,omitempty"`
+	// OutputFormat is pdfa, svg, png, jpeg or dxf, or empty for pdf.
+	OutputFormat string `json:"output_format,omitempty"`
+	// DPI, ImageSize, Background and NoAntialias concern png and jpeg
+	// images.
+	DPI         string `json:"dpi,omitempty"`
+	ImageSize   string `json:"image_size,omitempty"`
+	Background  string `json:"background,omitempty"`
+	NoAntialias bool   `json:"no_antialias,omitempty"`
+	// DXFVersion is r12, 2000 or 2018 for dxf files, or empty for 2018.
+	DXFVersion string `json:"dxf_version,omitempty"`
+}
+
 var (
 	ErrTaskNotFound = errors.New("task not found")
 	ErrTaskExpired  = errors.New("task expired")
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/you-humble/dwgtopdf/distributor/internal/domain"
@@ -39,7 +40,29 @@ func (s *redisTaskStore) Task(id string) (domain.Task, bool) {
 	t.Status = domain.TaskStatus(res["status"])
 	t.OriginalName = res["original_name"]
 	t.InputFilename = res["input_filename"]
+	t.InputFormat = res["input_format"]
+	t.Options = domain.ConvertOptions{
+		PaperSize:     res["paper_size"],
+		Orientation:   res["orientation"],
+		Scale:         res["scale"],
+		PlotStyle:     res["plot_style"],
+		PlotStyleHash: res["plot_style_hash"],
+		Lineweights:   res["lineweights"],
+		IncludeLayers: res["include_layers"],
+		ExcludeLayers: res["exclude_layers"],
+		PDFLayers:     res["pdf_layers"] == "1",
+		SHXText:       res["shx_text"],
+		NoHyperlinks:  res["no_hyperlinks"] == "1",
+		OutputFormat:  res["output_format"],
+		DPI:           res["dpi"],
+		ImageSize:     res["image_size"],
+		Background:    res["background"],
+		NoAntialias:   res["no_antialias"] == "1",
+		DXFVersion:    res["dxf_version"],
+	}
+	t.PlotStyleFilename = res["plot_style_filename"]
 	t.ResultFilename = res["result_filename"]
+	t.ThumbnailFilename = res["thumbnail_filename"]
 	t.FileHashSHA = res["file_hash_sha"]
 	t.IdempotencyKey = res["idempotency_key"]
 	t.Error = res["error"]
@@ -85,14 +108,18 @@ func (s *redisTaskStore) UpdateStatus(id string, newStatus domain.TaskStatus, er
 	}
 }
 
-func (s *redisTaskStore) SetResult(id string, pdfName string) {
+// SetResult marks a task done; warnings and unresolved external
+// references are kept one per line.
+func (s *redisTaskStore) SetResult(id string, resultName string, warnings, unresolvedXrefs []string) {
 	ctx := context.Background()
 	hk := taskKey(id)
 
 	now := time.Now().UnixNano()
 
 	pipe := s.rdb.TxPipeline()
-	pipe.HSet(ctx, hk, "result_filename", pdfName)
+	pipe.HSet(ctx, hk, "result_filename", resultName)
+	pipe.HSet(ctx, hk, "warnings", strings.Join(warnings, "\n"))
+	pipe.HSet(ctx, hk, "unresolved_xrefs", strings.Join(unresolvedXrefs, "\n"))
 	pipe.HSet(ctx, hk, "error", "")
 	pipe.HSet(ctx, hk, "status", string(domain.StatusDone))
 	pipe.HSet(ctx, hk, "updated_at", now)
@@ -102,6 +129,21 @@ func (s *redisTaskStore) SetResult(id string, pdfName string) {
 	}
 }
 
+// SetThumbnail records the thumbnail drawn for a task before its
+// conversion.
+func (s *redisTaskStore) SetThumbnail(id string, thumbnailName string) {
+	ctx := context.Background()
+	hk := taskKey(id)
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, hk, "thumbnail_filename", thumbnailName)
+	pipe.HSet(ctx, hk, "updated_at", time.Now().UnixNano())
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Warn("redis SetThumbnail", slog.String("error", err.Error()))
+	}
+}
+
 func (s *redisTaskStore) ByIdempotencyKey(key string) (domain.Task, bool) {
 	if key == "" {
 		return domain.Task{}, false
@@ -175,7 +217,7 @@ func (s *redisTaskStore) DeleteExpired(now time.Time, ttl time.Duration) int {
 			pipe.Del(ctx, idempKey(t.IdempotencyKey))
 		}
 		if t.FileHashSHA != "" {
-			pipe.Del(ctx, hashKey(t.FileHashSHA))
+			pipe.Del(ctx, hashKey(contentKey(t.FileHashSHA, t.Options)))
 		}
 
 		if _, err := pipe.Exec(ctx); err == nil {
@@ -198,6 +240,49 @@ func hashKey(h string) string {
 	return "task:hash:" + h
 }
 
+// contentKey tells conversions of the same file with different options
+// apart; it matches the key ingress indexes tasks by.
+func contentKey(hash string, o domain.ConvertOptions) string {
+	if o == (domain.ConvertOptions{}) {
+		return hash
+	}
+	key := hash + ":" + o.PaperSize + ":" + o.Orientation + ":" + o.Scale
+	if o.PlotStyle != "" || o.PlotStyleHash != "" {
+		key += ":" + o.PlotStyle + ":" + o.PlotStyleHash
+	}
+	if o.Lineweights != "" {
+		key += ":" + o.Lineweights
+	}
+	if o.IncludeLayers != "" || o.ExcludeLayers != "" {
+		key += ":+" + o.IncludeLayers + ":-" + o.ExcludeLayers
+	}
+	if o.PDFLayers {
+		key += ":ocg"
+	}
+	if o.SHXText != "" {
+		key += ":shx=" + o.SHXText
+	}
+	if o.NoHyperlinks {
+		key += ":nolinks"
+	}
+	if o.OutputFormat != "" {
+		key += ":out=" + o.OutputFormat
+	}
+	if o.DPI != "" || o.ImageSize != "" {
+		key += ":dpi=" + o.DPI + ":px=" + o.ImageSize
+	}
+	if o.Background != "" {
+		key += ":bg=" + o.Background
+	}
+	if o.NoAntialias {
+		key += ":noaa"
+	}
+	if o.DXFVersion != "" {
+		key += ":dxf=" + o.DXFVersion
+	}
+	return key
+}
+
 func tasksByCreatedKey() string {
 	return "tasks:by_created"
 }
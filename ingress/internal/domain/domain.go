@@ -16,15 +16,37 @@ const (
 	StatusExpired    TaskStatus = "expired"
 )
 
+type InputFormat string
+
+const (
+	FormatDWG InputFormat = "dwg"
+	FormatDXF InputFormat = "dxf"
+	// FormatBundle is a ZIP archive of a drawing and the files it
+	// references.
+	FormatBundle InputFormat = "zip"
+)
+
 type Task struct {
 	ID string `json:"id"`
 
 	Status TaskStatus `json:"status"`
 
-	OriginalName  string `json:"original_name"`
-	InputFilename string `json:"input_filename"`
+	OriginalName  string      `json:"original_name"`
+	InputFilename string      `json:"input_filename"`
+	InputFormat   InputFormat `json:"input_format"`
+	// PlotStyleFilename is the plot style table uploaded with the file.
+	PlotStyleFilename string `json:"plot_style_filename,omitempty"`
+
+	Options ConvertOptions `json:"options"`
 
 	ResultFilename string `json:"result_filename"`
+	// ThumbnailFilename is the preview of the drawing, taken from the
+	// upload or, for drawings without one, drawn along with the result.
+	ThumbnailFilename string `json:"thumbnail_filename,omitempty"`
+	// Warnings describe what the conversion could not draw.
+	Warnings []string `json:"warnings,omitempty"`
+	// UnresolvedXrefs are the external references missing from the upload.
+	UnresolvedXrefs []string `json:"unresolved_xrefs,omitempty"`
 
 	// meta
 	FileSize       int64     `json:"file_size"`
@@ -37,25 +59,74 @@ type Task struct {
 }
 
 type CreateTaskParams struct {
-	OriginalName   string
-	InputFilename  string
-	FileSize       int64
-	FileHashSHA    string
-	IdempotencyKey string
+	OriginalName      string
+	InputFilename     string
+	InputFormat       InputFormat
+	Options           ConvertOptions
+	PlotStyleFilename string
+	ThumbnailFilename string
+	FileSize          int64
+	FileHashSHA       string
+	IdempotencyKey    string
 
 	TTL time.Duration
 }
 
+// ConvertOptions override the page setups of the drawing; empty fields
+// keep them.
+type ConvertOptions struct {
+	PaperSize   string `json:"paper_size,omitempty"`
+	Orientation string `json:"orientation,omitempty"`
+	Scale       string `json:"scale,omitempty"`
+	// PlotStyle names a plot style table installed on the converter;
+	// PlotStyleHash is the SHA-256 of one uploaded with the file instead.
+	PlotStyle     string `json:"plot_style,omitempty"`
+	PlotStyleHash string `json:"plot_style_hash,omitempty"`
+	// Lineweights is plot or display.
+	Lineweights string `json:"lineweights,omitempty"`
+	// IncludeLayers and ExcludeLayers are JSON arrays of layer names and
+	// glob patterns.
+	IncludeLayers string `json:"include_layers,omitempty"`
+	ExcludeLayers string `json:"exclude_layers,omitempty"`
+	// PDFLayers makes each layer an optional content group of the PDF.
+	PDFLayers bool `json:"pdf_layers,omitempty"`
+	// SHXText is font, strokes or overlay.
+	SHXText string `json:"shx_text,omitempty"`
+	// NoHyperlinks leaves out the links of entity hyperlinks.
+	NoHyperlinks bool `json:"no_hyperlinks,omitempty"`
+	// OutputFormat is pdfa, svg, png, jpeg or dxf, or empty for pdf.
+	OutputFormat string `json:"output_format,omitempty"`
+	// DPI, ImageSize, Background and NoAntialias concern png and jpeg
+	// images.
+	DPI         string `json:"dpi,omitempty"`
+	ImageSize   string `json:"image_size,omitempty"`
+	Background  string `json:"background,omitempty"`
+	NoAntialias bool   `json:"no_antialias,omitempty"`
+	// DXFVersion is r12, 2000 or 2018 for dxf files, or empty for 2018.
+	DXFVersion string `json:"dxf_version,omitempty"`
+}
+
+// PlotStyleUpload is a .ctb or .stb plot style table sent along with the
+// file to convert.
+type PlotStyleUpload struct {
+	File     io.Reader
+	Filename string
+	Size     int64
+}
+
 type ConvertResponse struct {
 	ID string `json:"id"`
 }
 
 type StatusResponse struct {
-	ID          string     `json:"id"`
-	Status      TaskStatus `json:"status"`
-	DownloadURL string     `json:"download_url,omitempty"`
-	FileName    string     `json:"file_name,omitempty"`
-	Error       string     `json:"error,omitempty"`
+	ID              string     `json:"id"`
+	Status          TaskStatus `json:"status"`
+	DownloadURL     string     `json:"download_url,omitempty"`
+	ThumbnailURL    string     `json:"thumbnail_url,omitempty"`
+	FileName        string     `json:"file_name,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	Warnings        []string   `json:"warnings,omitempty"`
+	UnresolvedXrefs []string   `json:"unresolved_xrefs,omitempty"`
 }
 
 type DownloadResult struct {
@@ -74,4 +145,9 @@ var (
 	ErrTaskFailed   = errors.New("task failed")
 	ErrTaskExpired  = errors.New("task expired")
 	ErrTaskNotReady = errors.New("task not ready")
+	ErrNoThumbnail  = errors.New("drawing has no thumbnail")
+
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	ErrInvalidOption     = errors.New("invalid conversion option")
+	ErrInvalidBundle     = errors.New("invalid bundle")
 )
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/you-humble/dwgtopdf/ingress/internal/domain"
@@ -31,7 +32,7 @@ func (s *redisTaskStore) CreateTask(p domain.CreateTaskParams) (string, error) {
 			if !ok {
 				_ = s.rdb.Del(ctx, idempKey(p.IdempotencyKey)).Err()
 			} else {
-				if t.FileHashSHA == p.FileHashSHA && t.FileSize == p.FileSize {
+				if t.FileHashSHA == p.FileHashSHA && t.FileSize == p.FileSize && t.Options == p.Options {
 					return existingID, nil
 				}
 				return "", fmt.Errorf("idempotency key %q reused with different payload", p.IdempotencyKey)
@@ -42,7 +43,7 @@ func (s *redisTaskStore) CreateTask(p domain.CreateTaskParams) (string, error) {
 	}
 
 	if p.FileHashSHA != "" {
-		existingID, err := s.rdb.Get(ctx, hashKey(p.FileHashSHA)).Result()
+		existingID, err := s.rdb.Get(ctx, hashKey(contentKey(p.FileHashSHA, p.Options))).Result()
 		if err == nil && existingID != "" {
 			return existingID, nil
 		} else if err != nil && err != redis.Nil {
@@ -53,16 +54,20 @@ func (s *redisTaskStore) CreateTask(p domain.CreateTaskParams) (string, error) {
 	taskID := uuid.NewString()
 	now := time.Now()
 	t := domain.Task{
-		ID:             taskID,
-		Status:         domain.StatusPending,
-		OriginalName:   p.OriginalName,
-		InputFilename:  p.InputFilename,
-		FileSize:       p.FileSize,
-		FileHashSHA:    p.FileHashSHA,
-		IdempotencyKey: p.IdempotencyKey,
-		CreatedAt:      now,
-		UpdatedAt:      now,
-		ExpiresAt:      now.Add(p.TTL),
+		ID:                taskID,
+		Status:            domain.StatusPending,
+		OriginalName:      p.OriginalName,
+		InputFilename:     p.InputFilename,
+		InputFormat:       p.InputFormat,
+		PlotStyleFilename: p.PlotStyleFilename,
+		ThumbnailFilename: p.ThumbnailFilename,
+		Options:           p.Options,
+		FileSize:          p.FileSize,
+		FileHashSHA:       p.FileHashSHA,
+		IdempotencyKey:    p.IdempotencyKey,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		ExpiresAt:         now.Add(p.TTL),
 	}
 
 	pipe := s.rdb.TxPipeline()
@@ -70,18 +75,38 @@ func (s *redisTaskStore) CreateTask(p domain.CreateTaskParams) (string, error) {
 	hk := taskKey(taskID)
 
 	pipe.HSet(ctx, hk, map[string]interface{}{
-		"id":              t.ID,
-		"status":          string(t.Status),
-		"original_name":   t.OriginalName,
-		"input_filename":  t.InputFilename,
-		"result_filename": t.ResultFilename,
-		"file_size":       t.FileSize,
-		"file_hash_sha":   t.FileHashSHA,
-		"idempotency_key": t.IdempotencyKey,
-		"error":           t.Error,
-		"created_at":      t.CreatedAt.UnixNano(),
-		"updated_at":      t.UpdatedAt.UnixNano(),
-		"expires_at":      t.ExpiresAt.UnixNano(),
+		"id":                  t.ID,
+		"status":              string(t.Status),
+		"original_name":       t.OriginalName,
+		"input_filename":      t.InputFilename,
+		"input_format":        string(t.InputFormat),
+		"paper_size":          t.Options.PaperSize,
+		"orientation":         t.Options.Orientation,
+		"scale":               t.Options.Scale,
+		"plot_style":          t.Options.PlotStyle,
+		"plot_style_hash":     t.Options.PlotStyleHash,
+		"plot_style_filename": t.PlotStyleFilename,
+		"lineweights":         t.Options.Lineweights,
+		"include_layers":      t.Options.IncludeLayers,
+		"exclude_layers":      t.Options.ExcludeLayers,
+		"pdf_layers":          t.Options.PDFLayers,
+		"shx_text":            t.Options.SHXText,
+		"no_hyperlinks":       t.Options.NoHyperlinks,
+		"output_format":       t.Options.OutputFormat,
+		"dpi":                 t.Options.DPI,
+		"image_size":          t.Options.ImageSize,
+		"background":          t.Options.Background,
+		"no_antialias":        t.Options.NoAntialias,
+		"dxf_version":         t.Options.DXFVersion,
+		"result_filename":     t.ResultFilename,
+		"thumbnail_filename":  t.ThumbnailFilename,
+		"file_size":           t.FileSize,
+		"file_hash_sha":       t.FileHashSHA,
+		"idempotency_key":     t.IdempotencyKey,
+		"error":               t.Error,
+		"created_at":          t.CreatedAt.UnixNano(),
+		"updated_at":          t.UpdatedAt.UnixNano(),
+		"expires_at":          t.ExpiresAt.UnixNano(),
 	})
 
 	pipe.ZAdd(ctx, tasksByCreatedKey(), redis.Z{
@@ -93,7 +118,7 @@ func (s *redisTaskStore) CreateTask(p domain.CreateTaskParams) (string, error) {
 		pipe.Set(ctx, idempKey(p.IdempotencyKey), t.ID, 0)
 	}
 	if p.FileHashSHA != "" {
-		pipe.Set(ctx, hashKey(p.FileHashSHA), t.ID, 0)
+		pipe.Set(ctx, hashKey(contentKey(p.FileHashSHA, p.Options)), t.ID, 0)
 	}
 
 	if _, err := pipe.Exec(ctx); err != nil {
@@ -122,7 +147,35 @@ func (s *redisTaskStore) Task(id string) (domain.Task, bool) {
 	t.Status = domain.TaskStatus(res["status"])
 	t.OriginalName = res["original_name"]
 	t.InputFilename = res["input_filename"]
+	t.InputFormat = domain.InputFormat(res["input_format"])
+	t.Options = domain.ConvertOptions{
+		PaperSize:     res["paper_size"],
+		Orientation:   res["orientation"],
+		Scale:         res["scale"],
+		PlotStyle:     res["plot_style"],
+		PlotStyleHash: res["plot_style_hash"],
+		Lineweights:   res["lineweights"],
+		IncludeLayers: res["include_layers"],
+		ExcludeLayers: res["exclude_layers"],
+		PDFLayers:     res["pdf_layers"] == "1",
+		SHXText:       res["shx_text"],
+		NoHyperlinks:  res["no_hyperlinks"] == "1",
+		OutputFormat:  res["output_format"],
+		DPI:           res["dpi"],
+		ImageSize:     res["image_size"],
+		Background:    res["background"],
+		NoAntialias:   res["no_antialias"] == "1",
+		DXFVersion:    res["dxf_version"],
+	}
+	t.PlotStyleFilename = res["plot_style_filename"]
 	t.ResultFilename = res["result_filename"]
+	t.ThumbnailFilename = res["thumbnail_filename"]
+	if v := res["warnings"]; v != "" {
+		t.Warnings = strings.Split(v, "\n")
+	}
+	if v := res["unresolved_xrefs"]; v != "" {
+		t.UnresolvedXrefs = strings.Split(v, "\n")
+	}
 	t.FileHashSHA = res["file_hash_sha"]
 	t.IdempotencyKey = res["idempotency_key"]
 	t.Error = res["error"]
@@ -168,14 +221,22 @@ func (s *redisTaskStore) UpdateStatus(id string, newStatus domain.TaskStatus, er
 	}
 }
 
-func (s *redisTaskStore) SetResult(id string, pdfName string) {
+// SetResult marks a task done; warnings and unresolved external
+// references are kept one per line. A thumbnail drawn with the result
+// is recorded when there is one.
+func (s *redisTaskStore) SetResult(id string, resultName, thumbnailName string, warnings, unresolvedXrefs []string) {
 	ctx := context.Background()
 	hk := taskKey(id)
 
 	now := time.Now().UnixNano()
 
 	pipe := s.rdb.TxPipeline()
-	pipe.HSet(ctx, hk, "result_filename", pdfName)
+	pipe.HSet(ctx, hk, "result_filename", resultName)
+	if thumbnailName != "" {
+		pipe.HSet(ctx, hk, "thumbnail_filename", thumbnailName)
+	}
+	pipe.HSet(ctx, hk, "warnings", strings.Join(warnings, "\n"))
+	pipe.HSet(ctx, hk, "unresolved_xrefs", strings.Join(unresolvedXrefs, "\n"))
 	pipe.HSet(ctx, hk, "error", "")
 	pipe.HSet(ctx, hk, "status", string(domain.StatusDone))
 	pipe.HSet(ctx, hk, "updated_at", now)
@@ -258,7 +319,7 @@ func (s *redisTaskStore) DeleteExpired(now time.Time, ttl time.Duration) int {
 			pipe.Del(ctx, idempKey(t.IdempotencyKey))
 		}
 		if t.FileHashSHA != "" {
-			pipe.Del(ctx, hashKey(t.FileHashSHA))
+			pipe.Del(ctx, hashKey(contentKey(t.FileHashSHA, t.Options)))
 		}
 
 		if _, err := pipe.Exec(ctx); err == nil {
@@ -281,6 +342,49 @@ func hashKey(h string) string {
 	return "task:hash:" + h
 }
 
+// contentKey tells conversions of the same file with different options
+// apart.
+func contentKey(hash string, o domain.ConvertOptions) string {
+	if o == (domain.ConvertOptions{}) {
+		return hash
+	}
+	key := hash + ":" + o.PaperSize + ":" + o.Orientation + ":" + o.Scale
+	if o.PlotStyle != "" || o.PlotStyleHash != "" {
+		key += ":" + o.PlotStyle + ":" + o.PlotStyleHash
+	}
+	if o.Lineweights != "" {
+		key += ":" + o.Lineweights
+	}
+	if o.IncludeLayers != "" || o.ExcludeLayers != "" {
+		key += ":+" + o.IncludeLayers + ":-" + o.ExcludeLayers
+	}
+	if o.PDFLayers {
+		key += ":ocg"
+	}
+	if o.SHXText != "" {
+		key += ":shx=" + o.SHXText
+	}
+	if o.NoHyperlinks {
+		key += ":nolinks"
+	}
+	if o.OutputFormat != "" {
+		key += ":out=" + o.OutputFormat
+	}
+	if o.DPI != "" || o.ImageSize != "" {
+		key += ":dpi=" + o.DPI + ":px=" + o.ImageSize
+	}
+	if o.Background != "" {
+		key += ":bg=" + o.Background
+	}
+	if o.NoAntialias {
+		key += ":noaa"
+	}
+	if o.DXFVersion != "" {
+		key += ":dxf=" + o.DXFVersion
+	}
+	return key
+}
+
 func tasksByCreatedKey() string {
 	return "tasks:by_created"
 }
@@ -3,9 +3,12 @@ package transport
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/you-humble/dwgtopdf/ingress/internal/domain"
@@ -14,9 +17,17 @@ import (
 )
 
 type Usecase interface {
-	Convert(ctx context.Context, file io.Reader, filename, idempotencyKey string, size int64) (string, error)
+	Convert(
+		ctx context.Context,
+		file io.Reader,
+		filename, idempotencyKey string,
+		size int64,
+		opts domain.ConvertOptions,
+		plotStyle *domain.PlotStyleUpload,
+	) (string, error)
 	GetStatus(ctx context.Context, taskID string) (domain.StatusResponse, error)
 	GetResultFile(ctx context.Context, taskID string) (domain.DownloadResult, error)
+	GetThumbnail(ctx context.Context, taskID string) (domain.DownloadResult, error)
 }
 
 type handler struct {
@@ -68,14 +79,79 @@ func (h *handler) convert(w http.ResponseWriter, r *http.Request) {
 		logger = logger.With(slog.String("idempotency_key", idempotencyKey))
 	}
 
+	opts := domain.ConvertOptions{
+		PaperSize:     r.FormValue("paper_size"),
+		Orientation:   r.FormValue("orientation"),
+		Scale:         r.FormValue("scale"),
+		PlotStyle:     r.FormValue("plot_style"),
+		Lineweights:   r.FormValue("lineweights"),
+		IncludeLayers: r.FormValue("include_layers"),
+		ExcludeLayers: r.FormValue("exclude_layers"),
+		SHXText:       r.FormValue("shx_text"),
+		OutputFormat:  r.FormValue("output_format"),
+		DPI:           r.FormValue("dpi"),
+		ImageSize:     r.FormValue("image_size"),
+		Background:    r.FormValue("background"),
+		DXFVersion:    r.FormValue("dxf_version"),
+	}
+	if v := r.FormValue("pdf_layers"); v != "" {
+		if opts.PDFLayers, err = strconv.ParseBool(v); err != nil {
+			logger.Warn("pdf_layers field", slog.String("error", err.Error()))
+			writeError(w, http.StatusBadRequest, "field `pdf_layers` must be true or false")
+			return
+		}
+	}
+	if v := r.FormValue("hyperlinks"); v != "" {
+		links, err := strconv.ParseBool(v)
+		if err != nil {
+			logger.Warn("hyperlinks field", slog.String("error", err.Error()))
+			writeError(w, http.StatusBadRequest, "field `hyperlinks` must be true or false")
+			return
+		}
+		opts.NoHyperlinks = !links
+	}
+	if v := r.FormValue("antialias"); v != "" {
+		antialias, err := strconv.ParseBool(v)
+		if err != nil {
+			logger.Warn("antialias field", slog.String("error", err.Error()))
+			writeError(w, http.StatusBadRequest, "field `antialias` must be true or false")
+			return
+		}
+		opts.NoAntialias = !antialias
+	}
+
+	var plotStyle *domain.PlotStyleUpload
+	styleFile, styleHeader, err := r.FormFile("plot_style_file")
+	switch {
+	case err == nil:
+		defer styleFile.Close()
+		plotStyle = &domain.PlotStyleUpload{File: styleFile, Filename: styleHeader.Filename, Size: styleHeader.Size}
+	case !errors.Is(err, http.ErrMissingFile):
+		logger.Warn("plot_style_file field", slog.String("error", err.Error()))
+		writeError(w, http.StatusBadRequest, "unable to read field `plot_style_file`")
+		return
+	}
+
 	taskID, err := h.usecase.Convert(
 		r.Context(),
 		file,
 		header.Filename,
 		idempotencyKey,
 		header.Size,
+		opts,
+		plotStyle,
 	)
 	if err != nil {
+		if errors.Is(err, domain.ErrUnsupportedFormat) {
+			logger.Warn("Convert usecase", slog.String("error", err.Error()))
+			writeError(w, http.StatusBadRequest, "only .dwg, .dxf and .zip files are supported")
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidOption) || errors.Is(err, domain.ErrInvalidBundle) {
+			logger.Warn("Convert usecase", slog.String("error", err.Error()))
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		logger.Error("Convert usecase", slog.String("error", err.Error()))
 		writeError(w, http.StatusInternalServerError, "cannot create conversion task")
 		return
@@ -170,7 +246,7 @@ func (h *handler) download(w http.ResponseWriter, r *http.Request) {
 	}
 	defer result.Content.Close()
 
-	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Type", contentType(result.FileName))
 	w.Header().Set("Content-Disposition",
 		`attachment; filename="`+result.FileName+`"`)
 
@@ -183,6 +259,78 @@ func (h *handler) download(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *handler) thumbnail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	requestID := uuid.NewString()
+	logger := slog.With(
+		slog.String("request_id", requestID),
+		slog.String("handler", "thumbnail"),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	taskID := strings.TrimPrefix(r.URL.Path, "/thumbnail/")
+	if taskID == "" {
+		logger.Error("missing ID")
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	thumb, err := h.usecase.GetThumbnail(r.Context(), taskID)
+	if err != nil {
+		switch err {
+		case domain.ErrTaskNotFound:
+			writeError(w, http.StatusNotFound, "task not found")
+		case domain.ErrNoThumbnail:
+			writeError(w, http.StatusNotFound, "drawing has no thumbnail")
+		case domain.ErrTaskExpired:
+			writeError(w, http.StatusGone, "task expired")
+		case domain.ErrTaskNotReady:
+			writeJSON(w, http.StatusTooEarly, domain.StatusResponse{
+				ID:     taskID,
+				Status: domain.StatusProcessing,
+				Error:  "thumbnail is not ready yet",
+			})
+		default:
+			logger.Error("GetThumbnail", slog.String("error", err.Error()))
+			writeError(w, http.StatusInternalServerError, "cannot get thumbnail")
+		}
+		return
+	}
+	defer thumb.Content.Close()
+
+	w.Header().Set("Content-Type", contentType(thumb.FileName))
+	w.Header().Set("Content-Disposition", `inline; filename="`+thumb.FileName+`"`)
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, thumb.Content); err != nil {
+		logger.Error("thumbnail: send file",
+			slog.String("task_id", taskID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// contentType tells the type of a result by its extension.
+func contentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".svg":
+		return "image/svg+xml"
+	case ".png":
+		return "image/png"
+	case ".jpg":
+		return "image/jpeg"
+	case ".dxf":
+		return "image/vnd.dxf"
+	case ".zip":
+		return "application/zip"
+	}
+	return "application/pdf"
+}
+
 func writeError(w http.ResponseWriter, status int, message string) {
 	if message == "" {
 		message = http.StatusText(status)
@@ -6,6 +6,7 @@ type Handler interface {
 	convert(w http.ResponseWriter, r *http.Request)
 	result(w http.ResponseWriter, r *http.Request)
 	download(w http.ResponseWriter, r *http.Request)
+	thumbnail(w http.ResponseWriter, r *http.Request)
 }
 
 type router struct {
@@ -20,6 +21,7 @@ func (r *router) MountRoutes(mux *http.ServeMux) *http.ServeMux {
 	mux.HandleFunc("/convert", r.h.convert)
 	mux.HandleFunc("/result/", r.h.result)
 	mux.HandleFunc("/download/", r.h.download)
+	mux.HandleFunc("/thumbnail/", r.h.thumbnail)
 
 	return mux
 }
@@ -1,17 +1,24 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/you-humble/dwgtopdf/ingress/internal/domain"
 
+	"github.com/you-humble/dwgtopdf/core/libs/bundle"
+	"github.com/you-humble/dwgtopdf/core/libs/plot"
+	"github.com/you-humble/dwgtopdf/core/libs/thumbnail"
+
 	"github.com/google/uuid"
 )
 
@@ -53,10 +60,42 @@ func New(
 	}
 }
 
-func (uc *usecase) Convert(ctx context.Context, file io.Reader, filename, idempotencyKey string, size int64) (string, error) {
+func (uc *usecase) Convert(
+	ctx context.Context,
+	file io.Reader,
+	filename, idempotencyKey string,
+	size int64,
+	opts domain.ConvertOptions,
+	plotStyle *domain.PlotStyleUpload,
+) (string, error) {
 	ext := strings.ToLower(filepath.Ext(filename))
-	if ext != ".dwg" {
-		return "", errors.New("supported only .dwg files")
+	var format domain.InputFormat
+	switch ext {
+	case ".dwg":
+		format = domain.FormatDWG
+	case ".dxf":
+		format = domain.FormatDXF
+	case ".zip":
+		format = domain.FormatBundle
+		if err := checkBundle(file, filename, size); err != nil {
+			return "", fmt.Errorf("%w: %w", domain.ErrInvalidBundle, err)
+		}
+	default:
+		return "", fmt.Errorf("%w: %q, supported .dwg, .dxf and .zip", domain.ErrUnsupportedFormat, ext)
+	}
+	if err := validateOptions(opts); err != nil {
+		return "", fmt.Errorf("%w: %w", domain.ErrInvalidOption, err)
+	}
+	opts = normalizeOptions(opts)
+	var styleExt string
+	if plotStyle != nil {
+		if _, err := plot.ParsePlotStyle(plotStyle.Filename); err != nil {
+			return "", fmt.Errorf("%w: %w", domain.ErrInvalidOption, err)
+		}
+		styleExt = strings.ToLower(filepath.Ext(plotStyle.Filename))
+		if styleExt == "" {
+			return "", fmt.Errorf("%w: %w: %q", domain.ErrInvalidOption, plot.ErrPlotStyle, plotStyle.Filename)
+		}
 	}
 
 	if idempotencyKey != "" {
@@ -77,17 +116,42 @@ func (uc *usecase) Convert(ctx context.Context, file io.Reader, filename, idempo
 		return "", fmt.Errorf("save file: %w", err)
 	}
 
+	// An uploaded plot style table replaces an installed one; its hash
+	// tells conversions with different tables apart.
+	var styleFilename string
+	if plotStyle != nil {
+		styleFilename = fileID + styleExt
+		_, styleHash, err := uc.fileStore.Save(ctx, plotStyle.File, styleFilename, plotStyle.Size)
+		if err != nil {
+			_ = uc.fileStore.Delete(ctx, inputFilename)
+			return "", fmt.Errorf("save plot style table: %w", err)
+		}
+		opts.PlotStyle, opts.PlotStyleHash = "", styleHash
+	}
+
+	thumbnailFilename := uc.saveThumbnail(ctx, file, filename, size, fileID)
+
 	taskID, err := uc.taskStore.CreateTask(
 		domain.CreateTaskParams{
-			OriginalName:   filename,
-			InputFilename:  inputFilename,
-			FileSize:       writen,
-			FileHashSHA:    hash,
-			IdempotencyKey: idempotencyKey,
-			TTL:            uc.taskTTL,
+			OriginalName:      filename,
+			InputFilename:     inputFilename,
+			InputFormat:       format,
+			Options:           opts,
+			PlotStyleFilename: styleFilename,
+			ThumbnailFilename: thumbnailFilename,
+			FileSize:          writen,
+			FileHashSHA:       hash,
+			IdempotencyKey:    idempotencyKey,
+			TTL:               uc.taskTTL,
 		})
 	if err != nil {
 		_ = uc.fileStore.Delete(ctx, inputFilename)
+		if styleFilename != "" {
+			_ = uc.fileStore.Delete(ctx, styleFilename)
+		}
+		if thumbnailFilename != "" {
+			_ = uc.fileStore.Delete(ctx, thumbnailFilename)
+		}
 		return "", fmt.Errorf("create task: %w", err)
 	}
 
@@ -96,6 +160,16 @@ func (uc *usecase) Convert(ctx context.Context, file io.Reader, filename, idempo
 			if err := uc.fileStore.Delete(ctx, inputFilename); err != nil {
 				slog.Warn("delete duplicated file", slog.String("error", err.Error()))
 			}
+			if styleFilename != "" {
+				if err := uc.fileStore.Delete(ctx, styleFilename); err != nil {
+					slog.Warn("delete duplicated plot style table", slog.String("error", err.Error()))
+				}
+			}
+			if thumbnailFilename != "" {
+				if err := uc.fileStore.Delete(ctx, thumbnailFilename); err != nil {
+					slog.Warn("delete duplicated thumbnail", slog.String("error", err.Error()))
+				}
+			}
 		}
 	}
 
@@ -112,6 +186,184 @@ func (uc *usecase) Convert(ctx context.Context, file io.Reader, filename, idempo
 	return taskID, nil
 }
 
+// checkBundle makes sure an uploaded archive has a drawing to convert.
+// Uploads that cannot be read at random are left to the converter.
+func checkBundle(file io.Reader, filename string, size int64) error {
+	r, ok := file.(io.ReaderAt)
+	if !ok {
+		return nil
+	}
+	b, err := bundle.Open(r, size)
+	if err != nil {
+		return err
+	}
+	_, err = b.Drawing(filename)
+	return err
+}
+
+// saveThumbnail stores the preview image the drawing carries next to it
+// and returns its name. Drawings without one, or whose upload cannot be
+// read at random, get one drawn by the converter before they are
+// converted.
+func (uc *usecase) saveThumbnail(ctx context.Context, file io.Reader, filename string, size int64, fileID string) string {
+	r, ok := file.(io.ReaderAt)
+	if !ok {
+		return ""
+	}
+	data, err := thumbnail.Extract(r, size, filename)
+	if err != nil {
+		if !errors.Is(err, thumbnail.ErrNoThumbnail) {
+			slog.Warn("extract thumbnail", slog.String("file_name", filename), slog.String("error", err.Error()))
+		}
+		return ""
+	}
+	name := fileID + "_thumbnail.png"
+	if _, _, err := uc.fileStore.Save(ctx, bytes.NewReader(data), name, int64(len(data))); err != nil {
+		slog.Warn("save thumbnail", slog.String("file_name", filename), slog.String("error", err.Error()))
+		return ""
+	}
+	return name
+}
+
+func validateOptions(o domain.ConvertOptions) error {
+	if o.PaperSize != "" {
+		if _, err := plot.ParsePaper(o.PaperSize); err != nil {
+			return err
+		}
+	}
+	if o.Orientation != "" {
+		if _, err := plot.ParseOrientation(o.Orientation); err != nil {
+			return err
+		}
+	}
+	if o.Scale != "" {
+		if _, err := plot.ParseScale(o.Scale); err != nil {
+			return err
+		}
+	}
+	if o.PlotStyle != "" {
+		if _, err := plot.ParsePlotStyle(o.PlotStyle); err != nil {
+			return err
+		}
+	}
+	if o.Lineweights != "" {
+		if _, err := plot.ParseLineweights(o.Lineweights); err != nil {
+			return err
+		}
+	}
+	if o.SHXText != "" {
+		if _, err := plot.ParseSHXText(o.SHXText); err != nil {
+			return err
+		}
+	}
+	if o.OutputFormat != "" {
+		if _, err := plot.ParseOutput(o.OutputFormat); err != nil {
+			return err
+		}
+	}
+	if o.DPI != "" {
+		if _, err := plot.ParseDPI(o.DPI); err != nil {
+			return err
+		}
+	}
+	if o.ImageSize != "" {
+		if _, err := plot.ParseImageSize(o.ImageSize); err != nil {
+			return err
+		}
+	}
+	if o.Background != "" {
+		if _, err := plot.ParseBackground(o.Background); err != nil {
+			return err
+		}
+	}
+	if o.DXFVersion != "" {
+		if _, err := plot.ParseDXFVersion(o.DXFVersion); err != nil {
+			return err
+		}
+	}
+	for _, layers := range []string{o.IncludeLayers, o.ExcludeLayers} {
+		if layers != "" {
+			if _, err := plot.ParseLayers(layers); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeOptions brings validated options to the form tasks keep, with
+// defaults left empty, so that the same options given either way convert
+// once.
+func normalizeOptions(o domain.ConvertOptions) domain.ConvertOptions {
+	o.IncludeLayers = layerList(o.IncludeLayers)
+	o.ExcludeLayers = layerList(o.ExcludeLayers)
+	o.OutputFormat = outputFormat(o.OutputFormat)
+	o.DPI = dpi(o.DPI)
+	o.ImageSize = imageSize(o.ImageSize)
+	o.Background = background(o.Background)
+	o.DXFVersion = dxfVersion(o.DXFVersion)
+	return o
+}
+
+// layerList keeps a list of layers as a JSON array, so that layer names
+// may contain commas.
+func layerList(s string) string {
+	if s == "" {
+		return ""
+	}
+	layers, _ := plot.ParseLayers(s)
+	b, _ := json.Marshal(layers)
+	return string(b)
+}
+
+// outputFormat leaves PDF, the default, empty.
+func outputFormat(s string) string {
+	if s == "" {
+		return ""
+	}
+	v, _ := plot.ParseOutput(s)
+	if v == plot.OutputPDF {
+		return ""
+	}
+	return string(v)
+}
+
+func dpi(s string) string {
+	if s == "" {
+		return ""
+	}
+	v, _ := plot.ParseDPI(s)
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func imageSize(s string) string {
+	if s == "" {
+		return ""
+	}
+	v, _ := plot.ParseImageSize(s)
+	return v.String()
+}
+
+func background(s string) string {
+	if s == "" {
+		return ""
+	}
+	v, _ := plot.ParseBackground(s)
+	return v.String()
+}
+
+// dxfVersion leaves 2018, the default, empty.
+func dxfVersion(s string) string {
+	if s == "" {
+		return ""
+	}
+	v, _ := plot.ParseDXFVersion(s)
+	if v == plot.DXF2018 {
+		return ""
+	}
+	return string(v)
+}
+
 func (uc *usecase) GetStatus(ctx context.Context, taskID string) (domain.StatusResponse, error) {
 	task, ok := uc.taskStore.Task(taskID)
 	if !ok {
@@ -127,9 +379,16 @@ func (uc *usecase) GetStatus(ctx context.Context, taskID string) (domain.StatusR
 	case domain.StatusDone:
 		resp.DownloadURL = fmt.Sprintf("/download/%s", task.ID)
 		resp.FileName = task.ResultFilename
+		resp.Warnings = task.Warnings
+		resp.UnresolvedXrefs = task.UnresolvedXrefs
 	case domain.StatusFailed, domain.StatusExpired:
 		resp.Error = task.Error
 	}
+	// The thumbnail of an upload is there from the start; one drawn by the
+	// converter comes before the conversion is done, even when it fails.
+	if task.ThumbnailFilename != "" && task.Status != domain.StatusExpired {
+		resp.ThumbnailURL = fmt.Sprintf("/thumbnail/%s", task.ID)
+	}
 
 	return resp, nil
 }
@@ -167,3 +426,31 @@ func (uc *usecase) GetResultFile(ctx context.Context, taskID string) (domain.Dow
 		return domain.DownloadResult{}, domain.ErrTaskNotReady
 	}
 }
+
+func (uc *usecase) GetThumbnail(ctx context.Context, taskID string) (domain.DownloadResult, error) {
+	task, ok := uc.taskStore.Task(taskID)
+	if !ok {
+		return domain.DownloadResult{}, domain.ErrTaskNotFound
+	}
+
+	switch {
+	case task.Status == domain.StatusExpired:
+		return domain.DownloadResult{}, domain.ErrTaskExpired
+	case task.ThumbnailFilename != "":
+	case task.Status == domain.StatusPending || task.Status == domain.StatusProcessing:
+		return domain.DownloadResult{}, domain.ErrTaskNotReady
+	default:
+		return domain.DownloadResult{}, domain.ErrNoThumbnail
+	}
+
+	f, size, err := uc.fileStore.Open(ctx, task.ThumbnailFilename)
+	if err != nil {
+		return domain.DownloadResult{}, fmt.Errorf("open thumbnail: %w", err)
+	}
+
+	return domain.DownloadResult{
+		FileName: task.ThumbnailFilename,
+		Size:     size,
+		Content:  f,
+	}, nil
+}